@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"gocloud.dev/server"
+	"gocloud.dev/server/health"
+
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// application is the servers run by the serve command: the API and
+// the admin server. They are served on separate ports, each with
+// its own handler chain, and shut down together. The Warmups are run
+// before they listen, and the Events of both are published on one
+// bus.
+type application struct {
+	API     *server.Server
+	Admin   adminServer
+	Warmups warmupHooks
+	// Events is the bus the events of the domain are published on
+	Events *event.InProcessBus
+}
+
+// adminServer is the server for the admin endpoints, see
+// handler.NewAdminRouter
+type adminServer struct {
+	*server.Server
+}
+
+// newAdminServer is an initializer for adminServer. The server also
+// serves the liveness and readiness (using checks) health checks at
+// /healthz/liveness and /healthz/readiness, as the API does.
+func newAdminServer(rtr handler.AdminRouter, checks []health.Checker) adminServer {
+	return adminServer{server.New(rtr, &server.Options{
+		HealthChecks: checks,
+		Driver:       server.NewDefaultDriver(),
+	})}
+}
+
+// secretFlags are the names of the flags whose values are redacted
+// from the settings served by the admin server
+var secretFlags = map[string]bool{
+	"db-password":          true,
+	"google-client-secret": true,
+	"pii-key":              true,
+	"redis-url":            true,
+	"sentry-dsn":           true,
+}
+
+// redacted replaces the value of a secret flag which is set
+const redacted string = "[REDACTED]"
+
+// newSettings returns the value of each flag in flgs by flag name,
+// with the values of secret flags redacted
+func newSettings(flgs flags) handler.Settings {
+	var current flags
+	fs := newFlagSet("settings", &current)
+	// the flags of fs hold pointers to the fields of current, so
+	// they have the values of flgs once it is copied over
+	current = flgs
+
+	settings := make(handler.Settings)
+	fs.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if secretFlags[f.Name] && strings.TrimSpace(v) != "" {
+			v = redacted
+		}
+		settings[f.Name] = v
+	})
+
+	return settings
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func Test_newSettings(t *testing.T) {
+	c := qt.New(t)
+
+	flgs, err := newFlags([]string{"server", "-port=8081", "-db-password=sosecret", "-db-user=postgres"})
+	c.Assert(err, qt.IsNil)
+
+	settings := newSettings(flgs)
+
+	c.Assert(settings["port"], qt.Equals, "8081")
+	c.Assert(settings["db-user"], qt.Equals, "postgres")
+	c.Assert(settings["admin-listen-host"], qt.Equals, "127.0.0.1")
+	// secrets are redacted, unless they are not set
+	c.Assert(settings["db-password"], qt.Equals, redacted)
+	c.Assert(settings["pii-key"], qt.Equals, "")
+	// every flag is a setting
+	c.Assert(settings, qt.HasLen, len(newSettings(flags{})))
+}
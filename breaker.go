@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+)
+
+// newBreaker returns a datastore.Breaker for db, which probes the
+// database in the background until the returned function is called
+func newBreaker(db *sql.DB, cfg datastore.BreakerConfig, logger zerolog.Logger) (*datastore.Breaker, func()) {
+	b := datastore.NewBreaker(db, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.Run(ctx, logger)
+
+	return b, cancel
+}
@@ -0,0 +1,344 @@
+// Command scaffold generates the files for a new resource following
+// the layering used for movies: the domain type, the datastore
+// Selector and Transactor with their mocks, the service, the request
+// and response bodies, the handlers and their routes, tests and the
+// DDL for the table. For example, from the root of the module:
+//
+//	go run ./cmd/scaffold --resource=actor
+//
+// Existing files are never overwritten. The steps which edit existing
+// files (adding the handlers to the router, wire and the bootstrap
+// DDL) are printed when the files have been generated.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// exitFail is the exit code if the program
+	// fails.
+	exitFail = 1
+)
+
+// templates holds the template for each generated file
+//
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// file is a file generated for a resource
+type file struct {
+	// tmpl is the name of the template in templates
+	tmpl string
+	// path is the template for the path of the generated file,
+	// relative to the root of the module
+	path string
+}
+
+// files are the files generated for a resource
+var files = []file{
+	{"domain.go.tmpl", "domain/[[.Name]]/[[.Name]].go"},
+	{"domain_test.go.tmpl", "domain/[[.Name]]/[[.Name]]_test.go"},
+	{"selector.go.tmpl", "datastore/[[.Name]]store/selector.go"},
+	{"transactor.go.tmpl", "datastore/[[.Name]]store/transactor.go"},
+	{"storetest.go.tmpl", "datastore/[[.Name]]store/[[.Name]]storetest/[[.Name]]storetest.go"},
+	{"service.go.tmpl", "service/[[.Name]]svc/[[.Name]]svc.go"},
+	{"service_test.go.tmpl", "service/[[.Name]]svc/[[.Name]]svc_test.go"},
+	{"dto.go.tmpl", "handler/dto/[[.Name]].go"},
+	{"handler.go.tmpl", "handler/[[.Name]]Handler.go"},
+	{"handler_test.go.tmpl", "handler/[[.Name]]Handler_test.go"},
+	{"routes.go.tmpl", "handler/[[.Name]]Routes.go"},
+	{"ddl.sql.tmpl", "scripts/ddl/[[.Name]]_ddl.sql"},
+}
+
+// resource holds the names used in the templates
+type resource struct {
+	// Module is the module path, e.g. github.com/gilcrest/go-api-basic
+	Module string
+	// Name is the singular, lower case name of the resource, used
+	// for packages, paths and tables, e.g. actor
+	Name string
+	// Plural is the plural of Name, e.g. actors
+	Plural string
+	// Type is the exported name of the domain type, e.g. Actor
+	Type string
+	// TypePlural is the plural of Type, e.g. Actors
+	TypePlural string
+	// Article is the indefinite article for Name, e.g. an
+	Article string
+	// Recv is the receiver and variable name for the domain type
+	Recv string
+}
+
+// nameRegexp matches a valid resource name. A resource name is used
+// as a package name, so is a single lower case word.
+var nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// reservedNames are the packages imported by the generated code, which
+// cannot be the name of a resource
+var reservedNames = map[string]bool{
+	"audit": true, "auth": true, "bytes": true, "clock": true, "context": true,
+	"datastore": true, "dto": true, "errors": true, "errs": true,
+	"handler": true, "hlog": true, "http": true, "httptest": true,
+	"io": true, "json": true, "logger": true, "mux": true, "os": true,
+	"qt": true, "quota": true, "random": true, "sql": true,
+	"testing": true, "time": true, "user": true, "uuid": true,
+}
+
+// reservedRecv are names already used in the generated code, which
+// cannot be used as the receiver or variable name for the domain type
+var reservedRecv = map[string]bool{
+	"c": true, "d": true, "h": true, "r": true, "s": true,
+	"t": true, "u": true, "w": true,
+}
+
+// newResource returns the resource for name and plural. If plural
+// is empty, it is name with an "s" appended.
+func newResource(module, name, plural string) (resource, error) {
+	if !nameRegexp.MatchString(name) {
+		return resource{}, errors.Errorf("resource %q must be a single lower case word, e.g. actor", name)
+	}
+	if reservedNames[name] {
+		return resource{}, errors.Errorf("resource %q is the name of a package used by the generated code", name)
+	}
+	if plural == "" {
+		plural = name + "s"
+	}
+	if !nameRegexp.MatchString(plural) || plural == name {
+		return resource{}, errors.Errorf("plural %q must be a single lower case word different to the resource", plural)
+	}
+
+	article := "a"
+	if strings.ContainsAny(name[:1], "aeiou") {
+		article = "an"
+	}
+
+	// the receiver is the first letter of the name, unless that
+	// letter is already used in the generated code, in which case
+	// more letters are used. It cannot be the name itself, as that
+	// is the package of the domain type.
+	recv := name[:1]
+	for i := 2; reservedRecv[recv] && i <= len(name); i++ {
+		recv = name[:i]
+	}
+	if reservedRecv[recv] || recv == name {
+		recv = "x"
+	}
+
+	return resource{
+		Module:     module,
+		Name:       name,
+		Plural:     plural,
+		Type:       strings.ToUpper(name[:1]) + name[1:],
+		TypePlural: strings.ToUpper(plural[:1]) + plural[1:],
+		Article:    article,
+		Recv:       recv,
+	}, nil
+}
+
+func main() {
+	if err := run(os.Args, os.Stdout); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(exitFail)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		name   = fs.String("resource", "", "singular, lower case name of the resource, e.g. actor")
+		plural = fs.String("plural", "", "plural of the resource (default resource + \"s\")")
+		dir    = fs.String("dir", ".", "root directory of the module")
+	)
+	err := fs.Parse(args[1:])
+	// the usage has already been printed when help is requested
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	module, err := modulePath(*dir)
+	if err != nil {
+		return err
+	}
+
+	res, err := newResource(module, *name, *plural)
+	if err != nil {
+		return err
+	}
+
+	paths, err := generate(*dir, res)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		_, _ = fmt.Fprintf(stdout, "created %s\n", p)
+	}
+
+	return nextSteps(stdout, res)
+}
+
+// modulePath returns the module path from the go.mod file in dir
+func modulePath(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", errors.Wrap(err, "scaffold must be run from the root of the module (or given --dir)")
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return "", errors.Errorf("no module directive in %s", f.Name())
+}
+
+// generate executes the templates for res, writing the files under
+// dir, and returns the paths of the files written. No file is written
+// if any of the files already exists.
+func generate(dir string, res resource) ([]string, error) {
+	type output struct {
+		path    string
+		content []byte
+	}
+
+	// execute all the templates before writing anything, so a
+	// template error or existing file does not leave a partial
+	// resource behind
+	var outputs []output
+	for _, f := range files {
+		p, err := execute("path", f.path, res)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(string(p)))
+
+		if _, err := os.Stat(path); err == nil {
+			return nil, errors.Errorf("%s already exists", path)
+		} else if !os.IsNotExist(err) {
+			return nil, errors.WithStack(err)
+		}
+
+		text, err := templates.ReadFile("templates/" + f.tmpl)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		content, err := execute(f.tmpl, string(text), res)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(path, ".go") {
+			content, err = format.Source(content)
+			if err != nil {
+				return nil, errors.Wrapf(err, "formatting %s", path)
+			}
+		}
+
+		outputs = append(outputs, output{path, content})
+	}
+
+	var paths []string
+	for _, o := range outputs {
+		err := os.MkdirAll(filepath.Dir(o.path), 0o755)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = os.WriteFile(o.path, o.content, 0o644)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		paths = append(paths, o.path)
+	}
+
+	return paths, nil
+}
+
+// execute executes the template text for res. The templates use
+// [[ and ]] as delimiters, as {{ and }} are common in Go code.
+func execute(name, text string, res resource) ([]byte, error) {
+	t, err := template.New(name).Delims("[[", "]]").Parse(text)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, res)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// nextStepsTemplate are the steps which edit existing files, which
+// are left to the developer
+const nextStepsTemplate = `
+Next steps:
+
+  1. Add a [[.Type]]Handlers field to handler.Handlers and register
+     the routes in handler.NewMuxRouter:
+
+       register[[.Type]]Routes(rtr, c, auditHandler, authHandler, rc, handlers.[[.Type]]Handlers)
+
+  2. Add the providers to wire in inject_main.go and regenerate
+     wire_gen.go:
+
+       wire.Struct(new([[.Name]]svc.Service), "*"),
+       wire.Struct(new(handler.Default[[.Type]]Handlers), "*"),
+       wire.Struct(new(handler.[[.Type]]Handlers), "*"),
+       [[.Name]]store.NewDefaultTransactor, [[.Name]]store.NewDefaultSelector,
+       wire.Bind(new([[.Name]]store.Transactor), new([[.Name]]store.DefaultTransactor)),
+       wire.Bind(new([[.Name]]store.Selector), new([[.Name]]store.DefaultSelector)),
+       handler.ProvideCreate[[.Type]]Handler, handler.ProvideUpdate[[.Type]]Handler,
+       handler.ProvideDelete[[.Type]]Handler, handler.ProvideFind[[.Type]]ByIDHandler,
+       handler.ProvideFindAll[[.TypePlural]]Handler,
+
+  3. Append scripts/ddl/[[.Name]]_ddl.sql to scripts/ddl/demo_ddl.sql
+     and datastore/ddl/bootstrap.sql and run it against your database.
+
+  4. Allow the [[.Plural]] resource in the Authorizer
+     (auth.DefaultAuthorizer).
+
+  5. Add the [[.Type]] fields beyond Name to the domain type, store,
+     service input and request and response bodies.
+
+  6. Add an operation for each of the [[.Plural]] routes to
+     contractOperations in handler/handlertest/contract_test.go.
+`
+
+// nextSteps writes the steps left to the developer for res to w
+func nextSteps(w io.Writer, res resource) error {
+	b, err := execute("next steps", nextStepsTemplate, res)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return errors.WithStack(err)
+}
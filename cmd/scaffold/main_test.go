@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func Test_newResource(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     string
+		plural  string
+		want    resource
+		wantErr bool
+	}{
+		{"actor", "actor", "", resource{Module: "m", Name: "actor", Plural: "actors", Type: "Actor", TypePlural: "Actors", Article: "an", Recv: "a"}, false},
+		{"given plural", "person", "people", resource{Module: "m", Name: "person", Plural: "people", Type: "Person", TypePlural: "People", Article: "a", Recv: "p"}, false},
+		{"reserved receiver", "studio", "", resource{Module: "m", Name: "studio", Plural: "studios", Type: "Studio", TypePlural: "Studios", Article: "a", Recv: "st"}, false},
+		{"receiver is name", "do", "", resource{Module: "m", Name: "do", Plural: "dos", Type: "Do", TypePlural: "Dos", Article: "a", Recv: "x"}, false},
+		{"empty", "", "", resource{}, true},
+		{"upper case", "Actor", "", resource{}, true},
+		{"two words", "movie_review", "", resource{}, true},
+		{"imported package", "time", "", resource{}, true},
+		{"plural same as name", "sheep", "sheep", resource{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := newResource("m", tt.res, tt.plural)
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_run(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/api\n\ngo 1.16\n"), 0o644)
+	c.Assert(err, qt.IsNil)
+
+	var out bytes.Buffer
+	err = run([]string{"scaffold", "--resource=actor", "--dir=" + dir}, &out)
+	c.Assert(err, qt.IsNil)
+	c.Assert(out.String(), qt.Contains, "registerActorRoutes(rtr, c, auditHandler, authHandler, rc, handlers.ActorHandlers)")
+
+	// every file is created, and the Go files parse and import the
+	// module's packages
+	for _, f := range files {
+		p, err := execute("path", f.path, resource{Name: "actor"})
+		c.Assert(err, qt.IsNil)
+		path := filepath.Join(dir, string(p))
+		c.Assert(out.String(), qt.Contains, "created "+path)
+
+		b, err := os.ReadFile(path)
+		c.Assert(err, qt.IsNil)
+		if !strings.HasSuffix(path, ".go") {
+			continue
+		}
+		af, err := parser.ParseFile(token.NewFileSet(), path, b, parser.ImportsOnly)
+		c.Assert(err, qt.IsNil, qt.Commentf("%s", path))
+		for _, imp := range af.Imports {
+			c.Assert(imp.Path.Value, qt.Not(qt.Contains), "gilcrest")
+		}
+	}
+
+	// existing files are not overwritten
+	err = run([]string{"scaffold", "--resource=actor", "--dir=" + dir}, &out)
+	c.Assert(err, qt.ErrorMatches, ".*already exists")
+
+	// no go.mod
+	err = run([]string{"scaffold", "--resource=actor", "--dir=" + t.TempDir()}, &out)
+	c.Assert(err, qt.ErrorMatches, "scaffold must be run from the root of the module.*")
+}
@@ -0,0 +1,759 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/backfill"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/gateway/authgateway"
+	"github.com/gilcrest/go-api-basic/gateway/errorgateway"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// version is the version of the program. It is set at build time,
+// e.g. go build -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// commandFunc is run for a command with the parsed flags and a
+// logger setup using them. Any output is written to out.
+type commandFunc func(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error
+
+// newRootCommand returns the root command for the program, named
+// name, with its subcommands. Every subcommand loads its
+// configuration from the same flags (or environment variables) and
+// logs the same way. If no subcommand is given, the server is
+// started, so running the program with only flags works as it
+// always has.
+func newRootCommand(name string, out io.Writer) *ffcli.Command {
+	root := newCommand(name, "", "", out, serve)
+	root.Name = name
+	root.ShortUsage = name + " [flags] <subcommand> [flags]"
+
+	root.Subcommands = []*ffcli.Command{
+		newCommand(name, "serve", "start the API server (the default)", out, serve),
+		newCommand(name, "migrate", "create any missing database objects", out, migrate),
+		newCommand(name, "seed", "add or refresh the sample movies in the database", out, seed),
+		newBackfillCommand(name, out),
+		newCommand(name, "routes", "list the routes registered with the router", out, routes),
+		newCommand(name, "version", "print the version", out, printVersion),
+	}
+
+	return root
+}
+
+// newCommand returns a command named name (under the program prog)
+// which parses the shared flags and runs fn. If name is empty, the
+// command is the root command.
+func newCommand(prog, name, help string, out io.Writer, fn commandFunc) *ffcli.Command {
+	fsName := strings.TrimSpace(prog + " " + name)
+
+	var flgs flags
+	return &ffcli.Command{
+		Name:       name,
+		ShortUsage: fsName + " [flags]",
+		ShortHelp:  help,
+		FlagSet:    newFlagSet(fsName, &flgs),
+		Options:    ffOptions,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return errors.Errorf("unknown command or argument %q", args[0])
+			}
+			lgr, flush := newCommandLogger(flgs)
+			defer flush()
+
+			return fn(ctx, flgs, lgr, out)
+		},
+	}
+}
+
+// backfills are the backfills which can be run by the backfill
+// command
+var backfills = []backfill.Backfill{
+	moviestore.SearchVectorBackfill,
+}
+
+// newBackfillCommand returns the backfill command, which lists the
+// backfills, with a subcommand to run each of them
+func newBackfillCommand(prog string, out io.Writer) *ffcli.Command {
+	cmd := newCommand(prog, "backfill", "fill derived columns of existing rows in batches", out, listBackfills)
+	cmd.ShortUsage = prog + " backfill [flags] <backfill>"
+
+	for _, b := range backfills {
+		b := b
+		sub := newCommand(prog+" backfill", b.Name, b.Description, out,
+			func(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+				return runBackfill(ctx, flgs, lgr, b)
+			})
+		cmd.Subcommands = append(cmd.Subcommands, sub)
+	}
+
+	return cmd
+}
+
+// listBackfills writes the name and description of each backfill
+func listBackfills(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKFILL\tDESCRIPTION")
+	for _, b := range backfills {
+		fmt.Fprintf(tw, "%s\t%s\n", b.Name, b.Description)
+	}
+
+	return tw.Flush()
+}
+
+// runBackfill runs b against the database. An interrupt or SIGTERM
+// stops the backfill after the batch running, logging the key to
+// resume from with the backfill-after flag.
+func runBackfill(ctx context.Context, flgs flags, lgr zerolog.Logger, b backfill.Backfill) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, cleanup, err := datastore.NewDB(newDSN(flgs), lgr)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	cfg := backfill.Config{
+		BatchSize: flgs.backfillbatchsize,
+		Pause:     flgs.backfillpause,
+		After:     flgs.backfillafter,
+	}
+	_, err = backfill.Run(ctx, datastore.NewDefaultDatastore(db), b, cfg, lgr)
+
+	return err
+}
+
+// newCommandLogger returns a logger setup using the logging flags
+// and a function which writes any log lines still buffered, to be
+// called before the command returns
+func newCommandLogger(flgs flags) (zerolog.Logger, func()) {
+	// write logs from a background goroutine if asked to, so a slow
+	// stdout never blocks requests
+	var (
+		w     io.Writer = os.Stdout
+		flush           = func() {}
+	)
+	if flgs.logasync {
+		w, flush = logger.NewAsyncWriter(os.Stdout, logger.DefaultAsyncBufferSize)
+	}
+
+	// setup logger with appropriate defaults
+	lgr := logger.NewLogger(w, true)
+
+	// determine logging level
+	loglevel := newLogLevel(flgs.loglvl)
+
+	// set global logging level based on flag input
+	zerolog.SetGlobalLevel(loglevel)
+
+	// set global logging time field format to Unix timestamp. Cloud
+	// Logging only reads the time of a structured log entry given in
+	// RFC 3339 format, so it is used instead on Cloud Run.
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	if onCloudRun() {
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+	}
+
+	// enable debug logging of SQL statements if flag is set
+	datastore.LogStatementsGlobal(flgs.logsql)
+	if flgs.logsql {
+		lgr.Info().Msg("sql statement logging enabled (logged at debug level)")
+	}
+
+	return lgr, flush
+}
+
+// newDSN returns the PostgreSQL datasource name details from flgs
+func newDSN(flgs flags) datastore.PGDatasourceName {
+	dsn := datastore.NewPGDatasourceName(flgs.dbhost, flgs.dbname, flgs.dbuser, flgs.dbpassword, flgs.dbport)
+	dsn.PasswordFile = flgs.dbpasswordfile
+	dsn.ConnectWait = flgs.dbconnectwait
+	dsn.ApplicationName = flgs.dbapplicationname
+	dsn.CommentRequestID = flgs.dbcommentrequestid
+	dsn.StatementCacheSize = flgs.dbstatementcache
+	dsn.MaxIdleConns = flgs.dbmaxidleconns
+
+	return dsn
+}
+
+// newPIICipher returns the cipher user emails and names are encrypted
+// with in the database, using the key in the pii-key-file flag file
+// or else the pii-key flag
+func newPIICipher(flgs flags) (pii.Cipher, error) {
+	key := flgs.piikey
+	if flgs.piikeyfile != "" {
+		b, err := ioutil.ReadFile(flgs.piikeyfile)
+		if err != nil {
+			return nil, errs.E(errs.Validation, errors.Wrap(err, "pii key file cannot be read"))
+		}
+		key = string(b)
+	}
+	return pii.NewCipher(key)
+}
+
+// serve starts the API server
+func serve(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	lgr.Info().Msgf("logging level set to %s", zerolog.GlobalLevel())
+
+	// validate port in acceptable range
+	err := portRange(flgs.port)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("portRange() error")
+	}
+
+	// setup connection pool statistics reporting
+	poolCfg := datastore.PoolStatsConfig{
+		Interval:      flgs.dbstatsinterval,
+		WaitThreshold: flgs.dbwaitthreshold,
+	}
+
+	// turn requests away while the database is down or its pool is
+	// exhausted
+	breakerCfg := datastore.BreakerConfig{
+		Interval:      flgs.dbprobeinterval,
+		Timeout:       flgs.dbprobetimeout,
+		Threshold:     flgs.dbbreakerthreshold,
+		WaitThreshold: flgs.dbwaitthreshold,
+	}
+
+	// setup the per route cache policies
+	cachePolicies := handler.CachePolicies{
+		FindMovieByID: handler.CachePolicy{MaxAge: flgs.moviecachemaxage},
+		FindAllMovies: handler.CachePolicy{MaxAge: flgs.moviescachemaxage},
+	}
+
+	// setup the page limits of the list routes, with any overrides
+	// for a route
+	pageRoutes, err := handler.ParsePageLimits(flgs.pagelimits)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("ParsePageLimits() error")
+	}
+	pagination := handler.Pagination{
+		Defaults: handler.PageLimits{
+			DefaultSize:   flgs.pagesizedefault,
+			MaxSize:       flgs.pagesizemax,
+			MaxExportRows: flgs.exportmaxrows,
+		},
+		Routes: pageRoutes,
+	}
+
+	// setup the per user request quotas, which can be reloaded
+	limits := quota.NewReloadableLimits(quota.Limits{
+		Daily:   flgs.quotadaily,
+		Monthly: flgs.quotamonthly,
+	})
+
+	// setup JSON request body decoding
+	decodeOpts := handler.DecodeOptions{
+		Strict:       flgs.strictjson,
+		MaxArrayLen:  flgs.jsonmaxarraylen,
+		MaxDepth:     flgs.jsonmaxdepth,
+		MaxBodyBytes: flgs.maxbodybytes,
+	}
+
+	// setup JSON response body encoding
+	naming, err := handler.ParseFieldNaming(flgs.jsonfieldnaming)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("ParseFieldNaming() error")
+	}
+	envelope, err := handler.ParseEnvelope(flgs.envelopefields)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("ParseEnvelope() error")
+	}
+	encodeOpts := handler.EncodeOptions{
+		FieldNaming: naming,
+		NoEnvelope:  flgs.noenvelope,
+		Envelope:    envelope,
+	}
+
+	// setup the bounds movies are validated against, any bound
+	// not set is the default
+	policy := movie.ValidationPolicy{
+		Ratings:        movie.ParseRatings(flgs.ratings),
+		MinReleaseYear: flgs.minreleaseyear,
+		MaxYearsAhead:  flgs.maxyearsahead,
+		MinRunTime:     flgs.minruntime,
+		MaxRunTime:     flgs.maxruntime,
+	}
+
+	// setup the sinks audit events are sent to
+	auditCfg := audit.ParseSinkConfig(flgs.auditsinks)
+
+	// setup the URLs sent with error responses
+	supportURLs, err := errs.ParseSupportURLs(flgs.supporturl, flgs.errorurls)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("ParseSupportURLs() error")
+	}
+	errs.SetSupportURLs(supportURLs)
+
+	// choose the requests whose bodies are logged
+	handler.SetBodyLogging(handler.BodyLogging{
+		Routes: handler.ParseBodyLogRoutes(flgs.logbodyroutes),
+		Header: flgs.logbodyheader,
+	})
+
+	// setup the service server errors are reported to
+	reportCfg := errorgateway.Config{
+		Reporter:  flgs.errorreporter,
+		SentryDSN: flgs.sentrydsn,
+		Version:   version,
+	}
+
+	// choose the HTTP versions served
+	if (flgs.tlscertfile == "") != (flgs.tlskeyfile == "") {
+		lgr.Fatal().Msg("tls-cert-file and tls-key-file must be set together")
+	}
+	tlsEnabled := flgs.tlscertfile != ""
+	if tlsEnabled && flgs.h2c {
+		lgr.Warn().Msg("h2c is only served without TLS and is ignored")
+	}
+	protoCfg := protocolConfig{
+		HTTP2: flgs.http2,
+		H2C:   flgs.h2c && !tlsEnabled,
+	}
+
+	// normalize request paths before routing
+	pathOpts := handler.PathNormalization{
+		TrimTrailingSlash: flgs.pathtrimtrailingslash,
+		CollapseSlashes:   flgs.pathcollapseslashes,
+		CaseInsensitive:   flgs.pathcaseinsensitive,
+	}
+
+	// trust the X-Forwarded-For and X-Forwarded-Proto headers of
+	// requests from these proxies only
+	proxies, err := handler.ParseTrustedProxies(flgs.trustedproxies)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("ParseTrustedProxies() error")
+	}
+
+	// serve the reads of the catalog without an access token, if
+	// enabled
+	publicRead := handler.PublicRead(flgs.publicread)
+	if publicRead {
+		lgr.Info().Msg("public read mode: movies and people are served without an access token")
+	}
+
+	// cache the responses of GET requests, if enabled
+	respCacheCfg := responseCacheConfig{
+		Store:    flgs.responsecache,
+		TTL:      flgs.responsecachettl,
+		RedisURL: flgs.redisurl,
+	}
+
+	// route the queries of each tenant's requests to its database
+	tenantDBs, err := parseTenants(flgs.tenants)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("parseTenants() error")
+	}
+	// and the tenant of each user from their hosted domain
+	tenantDomains, err := parseTenantDomains(flgs.tenantdomains, tenantDBs)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("parseTenantDomains() error")
+	}
+	tenantCfg := tenantConfig{
+		Databases:    tenantDBs,
+		Domains:      tenantDomains,
+		MaxOpenConns: flgs.tenantmaxopenconns,
+	}
+
+	// serve the reads from a replica of the database, if configured
+	replicaCfg := replicaConfig{
+		Host: flgs.dbreplicahost,
+		Port: flgs.dbreplicaport,
+		Wait: flgs.dbreplicawait,
+	}
+
+	// write the files of export jobs to a bucket, if configured
+	exportCfg := exportConfig{
+		BucketURL: flgs.exportbucket,
+		URLExpiry: flgs.exporturlexpiry,
+	}
+	if exportCfg.BucketURL == "" {
+		lgr.Info().Msg("no export bucket configured: export jobs are not available")
+	}
+
+	// the settings served by the admin server
+	settings := newSettings(flgs)
+
+	// ping and metrics are only served by the admin server when
+	// there is one
+	adminServed := handler.AdminServed(flgs.adminport != 0)
+
+	var (
+		app     *application
+		cleanup func()
+	)
+	if flgs.mock {
+		// in mock mode the sample movies are held in memory and any
+		// access token is accepted, so no database is needed
+		lgr.Warn().Msg("mock mode: data is held in memory and any access token is accepted")
+		if len(tenantCfg.Databases) > 0 {
+			lgr.Warn().Msg("mock mode: tenants are ignored")
+			tenantCfg = tenantConfig{}
+		}
+		if replicaCfg.Host != "" {
+			lgr.Warn().Msg("mock mode: the database replica is ignored")
+		}
+
+		app, cleanup, err = newMockServer(ctx, lgr, cachePolicies, pagination, limits, decodeOpts, encodeOpts, policy, auditCfg, reportCfg, protoCfg, pathOpts, proxies, publicRead, respCacheCfg, tenantCfg, exportCfg, settings, adminServed)
+		if err != nil {
+			lgr.Fatal().Err(err).Msg("Error returned from newMockServer")
+		}
+	} else {
+		//get struct holding PostgreSQL datasource name details
+		dsn := newDSN(flgs)
+
+		// create any missing database objects before the server starts
+		if flgs.bootstrapdb {
+			err = bootstrapDB(ctx, dsn, lgr)
+			if err != nil {
+				lgr.Fatal().Err(err).Msg("Error returned from bootstrapDB")
+			}
+		}
+
+		// the email and names of users are encrypted in the database
+		// if a pii key is configured
+		var piiCipher pii.Cipher
+		piiCipher, err = newPIICipher(flgs)
+		if err != nil {
+			lgr.Fatal().Err(err).Msg("Error returned from newPIICipher")
+		}
+		if _, ok := piiCipher.(pii.PlainCipher); ok {
+			lgr.Warn().Msg("no pii key configured: user emails and names are stored in plain text")
+		}
+
+		// the audience and issuer access tokens are checked for
+		tokenCfg := authgateway.NewTokenValidation(flgs.tokenaudiences, flgs.tokenissuer)
+		if err = tokenCfg.CheckIssuer(authgateway.GoogleMetadata); err != nil {
+			lgr.Fatal().Err(err).Msg("CheckIssuer() error")
+		}
+		if len(tokenCfg.Audiences) == 0 {
+			lgr.Warn().Msg("no token audiences configured: access tokens issued to any client are accepted")
+		}
+
+		// the OAuth client the refresh tokens of integration
+		// sessions are issued to, which are only stored encrypted
+		oauthClient := authgateway.OAuthClient{ID: flgs.googleclientid, Secret: flgs.googleclientsecret}
+
+		// newServer function returns the API and admin servers, a
+		// cleanup function and an error
+		app, cleanup, err = newServer(ctx, lgr, dsn, poolCfg, breakerCfg, cachePolicies, pagination, limits, decodeOpts, encodeOpts, policy, auditCfg, reportCfg, piiCipher, tokenCfg, oauthClient, protoCfg, pathOpts, proxies, publicRead, respCacheCfg, tenantCfg, replicaCfg, exportCfg, settings, adminServed)
+		if err != nil {
+			lgr.Fatal().Err(err).Msg("Error returned from newServer")
+		}
+
+		// publish the movies changed by any instance of the server
+		stopListening, err := moviestore.ListenChanges(dsn, app.Events, lgr)
+		if err != nil {
+			lgr.Fatal().Err(err).Msg("Error returned from moviestore.ListenChanges")
+		}
+		defer stopListening()
+	}
+	defer cleanup()
+
+	// log the movies changed
+	event.OnMovieChanged(app.Events, func(ctx context.Context, e event.MovieChanged) {
+		lgr.Debug().Msgf("movie %s: %s", e.ExternalID, e.Action)
+	})
+
+	// warm up before listening, so the first requests are not slow
+	if flgs.warmuptimeout > 0 {
+		failed := app.Warmups.run(ctx, flgs.warmuptimeout, lgr)
+		if len(failed) > 0 {
+			lgr.Warn().Strs("failed", failed).Msg("warm-up incomplete, the first requests may be slow")
+		}
+	}
+
+	// reload the log level and quotas on SIGHUP
+	rl, err := newReloader(flgs, limits, lgr)
+	if err != nil {
+		return err
+	}
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	defer signal.Stop(hups)
+	reloadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go rl.watch(reloadCtx, hups)
+
+	// Listen and serve HTTP until a SIGTERM (or interrupt) is
+	// received, then drain in-flight requests
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	api := listener{name: "api", srv: app.API, addr: net.JoinHostPort(flgs.listenhost, strconv.Itoa(flgs.port))}
+	if tlsEnabled {
+		api.srv = tlsServer{app.API, flgs.tlscertfile, flgs.tlskeyfile}
+		lgr.Info().Bool("http2", protoCfg.HTTP2).Msgf("listening with TLS on %s", api.addr)
+	} else {
+		lgr.Info().Bool("h2c", protoCfg.H2C).Msgf("listening on %s", api.addr)
+	}
+	listeners := []listener{api}
+
+	// serve the admin endpoints on their own port, if enabled
+	if flgs.adminport != 0 {
+		err = portRange(flgs.adminport)
+		if err != nil {
+			lgr.Fatal().Err(err).Msg("admin portRange() error")
+		}
+		if flgs.adminport == flgs.port {
+			lgr.Fatal().Msg("admin-port must not be the same as port")
+		}
+		admin := listener{name: "admin", srv: app.Admin, addr: net.JoinHostPort(flgs.adminlistenhost, strconv.Itoa(flgs.adminport))}
+		lgr.Info().Msgf("admin server listening on %s", admin.addr)
+		listeners = append(listeners, admin)
+	}
+
+	return listenAndServe(ctx, listeners, sigs, flgs.shutdowntimeout, lgr)
+}
+
+// httpServer is the part of server.Server used by listenAndServe
+type httpServer interface {
+	ListenAndServe(addr string) error
+	Shutdown(ctx context.Context) error
+}
+
+// listener is a server served by listenAndServe on addr, named for
+// logs and errors
+type listener struct {
+	name string
+	srv  httpServer
+	addr string
+}
+
+// listenerErr is the error a listener's server stopped serving with
+type listenerErr struct {
+	name string
+	err  error
+}
+
+// listenAndServe serves HTTP with each of listeners until one of the
+// servers fails or a signal is received on sigs. The servers are
+// stopped together: if one fails, the others are shut down, and on
+// a signal every server stops accepting connections and in-flight
+// requests are given up to drain to finish. Platforms such as Cloud
+// Run and Kubernetes send a SIGTERM and wait a few seconds before
+// killing the process, so drain should be shorter than that window.
+func listenAndServe(ctx context.Context, listeners []listener, sigs <-chan os.Signal, drain time.Duration, lgr zerolog.Logger) error {
+	errc := make(chan listenerErr, len(listeners))
+	for _, l := range listeners {
+		go func(l listener) {
+			errc <- listenerErr{name: l.name, err: l.srv.ListenAndServe(l.addr)}
+		}(l)
+	}
+
+	select {
+	case le := <-errc:
+		// the process exits, so the other servers are not drained
+		// for long
+		var others []listener
+		for _, l := range listeners {
+			if l.name != le.name {
+				others = append(others, l)
+			}
+		}
+		if err := shutdown(ctx, others, drain); err != nil {
+			lgr.Error().Err(err).Msg("shutdown after server error")
+		}
+
+		return errors.Wrapf(le.err, "%s server error", le.name)
+	case sig := <-sigs:
+		lgr.Info().Msgf("received %s, draining requests for up to %s", sig, drain)
+
+		if err := shutdown(ctx, listeners, drain); err != nil {
+			return err
+		}
+		lgr.Info().Msg("server shutdown complete")
+
+		return nil
+	}
+}
+
+// shutdown shuts down the servers of listeners at the same time,
+// giving in-flight requests up to drain to finish, and returns the
+// first error
+func shutdown(ctx context.Context, listeners []listener, drain time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, drain)
+	defer cancel()
+
+	failures := make([]error, len(listeners))
+	var wg sync.WaitGroup
+	for i, l := range listeners {
+		wg.Add(1)
+		go func(i int, l listener) {
+			defer wg.Done()
+			if err := l.srv.Shutdown(ctx); err != nil {
+				failures[i] = errors.Wrapf(err, "%s server shutdown error", l.name)
+			}
+		}(i, l)
+	}
+	wg.Wait()
+
+	for _, err := range failures {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate creates any missing database objects
+func migrate(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	return bootstrapDB(ctx, newDSN(flgs), lgr)
+}
+
+// seedUser is the user the sample movies are created by
+var seedUser = user.User{
+	Email:     "seed@go-api-basic.local",
+	FirstName: "Seed",
+	LastName:  "Data",
+	FullName:  "Seed Data",
+}
+
+// seedMovies are the sample movies added by the seed command. The
+// external IDs are fixed, so seeding again updates the same movies
+// instead of adding duplicates.
+var seedMovies = []struct {
+	extlID   string
+	title    string
+	rated    string
+	released string
+	runTime  int
+	director string
+	writer   string
+}{
+	{"seedRepoMan", "Repo Man", "R", "1984-03-02T00:00:00Z", 92, "Alex Cox", "Alex Cox"},
+	{"seedSidAndNancy", "Sid and Nancy", "R", "1986-10-03T00:00:00Z", 112, "Alex Cox", "Alex Cox"},
+	{"seedBladeRunner", "Blade Runner", "R", "1982-06-25T00:00:00Z", 117, "Ridley Scott", "Hampton Fancher"},
+	{"seedTheThing", "The Thing", "R", "1982-06-25T00:00:00Z", 109, "John Carpenter", "Bill Lancaster"},
+}
+
+// newSeedMovies returns the sample movies, created by seedUser
+func newSeedMovies() ([]*movie.Movie, error) {
+	clk := clock.DefaultClock{}
+	idg := idgen.DefaultUUIDGenerator{Clock: clk}
+
+	movies := make([]*movie.Movie, 0, len(seedMovies))
+	for _, sm := range seedMovies {
+		id, err := idg.NewUUID()
+		if err != nil {
+			return nil, err
+		}
+		m, err := movie.NewMovie(id, sm.extlID, seedUser, clk)
+		if err != nil {
+			return nil, err
+		}
+		m, err = m.SetReleased(sm.released)
+		if err != nil {
+			return nil, err
+		}
+		m.SetTitle(sm.title).
+			SetRated(sm.rated).
+			SetRunTime(sm.runTime).
+			SetDirector(sm.director).
+			SetWriter(sm.writer)
+
+		movies = append(movies, m)
+	}
+
+	return movies, nil
+}
+
+// seed adds the sample movies to the database, updating them if
+// they already exist
+func seed(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	db, cleanup, err := datastore.NewDB(newDSN(flgs), lgr)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	transactor := moviestore.NewDefaultTransactor(datastore.NewDefaultDatastore(db))
+
+	movies, err := newSeedMovies()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range movies {
+		err = transactor.Upsert(ctx, m)
+		if err != nil {
+			return err
+		}
+		lgr.Info().Msgf("seeded movie %s (%s)", m.Title, m.ExternalID)
+	}
+
+	return nil
+}
+
+// routes writes the method(s), path, required query parameters,
+// metadata (see handler.RouteMeta) and middleware of every route
+// registered with the router, in the order they are matched
+func routes(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	// the handlers are never called, only the routes are needed
+	rl := handler.NewRouteList()
+	_ = handler.NewMuxRouter(lgr, handler.Handlers{}, handler.AuthMiddleware{}, nil, nil, rl, handler.EncodeOptions{}, handler.DecodeOptions{}, nil, handler.Tenants{}, nil, false, false)
+
+	rts, err := rl.Routes()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHODS\tPATH\tQUERIES\tRESOURCE\tSCOPES\tROLE\tAUDIT\tPUBLIC\tMIDDLEWARE")
+	for _, rt := range rts {
+		var public string
+		if rt.Public {
+			public = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			strings.Join(rt.Methods, ","),
+			rt.Path,
+			strings.Join(rt.Queries, "&"),
+			rt.Resource,
+			strings.Join(rt.Scopes, ","),
+			rt.Role,
+			rt.AuditCategory,
+			public,
+			strings.Join(rt.Middleware, ","))
+	}
+
+	return tw.Flush()
+}
+
+// printVersion writes the program version and the version of Go it
+// was built with
+func printVersion(ctx context.Context, flgs flags, lgr zerolog.Logger, out io.Writer) error {
+	_, err := fmt.Fprintf(out, "%s (%s)\n", version, runtime.Version())
+	return err
+}
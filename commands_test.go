@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func Test_newRootCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantLines []string
+	}{
+		{"version", []string{"version"}, []string{"dev ("}},
+		{"backfill", []string{"backfill"}, []string{
+			"BACKFILL",
+			"movie-search-vector",
+		}},
+		{"routes", []string{"routes"}, []string{
+			"METHODS",
+			"POST     /api/v1/movies",
+			"GET      /api/v1/movies                                  ids={ids}",
+			"GET      /api/v1/ping",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			out := new(bytes.Buffer)
+			root := newRootCommand("server", out)
+
+			err := root.ParseAndRun(context.Background(), tt.args)
+			c.Assert(err, qt.IsNil)
+
+			lines := strings.Split(out.String(), "\n")
+			for _, want := range tt.wantLines {
+				var found bool
+				for _, l := range lines {
+					if strings.HasPrefix(l, want) {
+						found = true
+						break
+					}
+				}
+				c.Assert(found, qt.IsTrue, qt.Commentf("no line starting with %q in:\n%s", want, out))
+			}
+		})
+	}
+}
+
+// fakeServer serves until it is shut down, or fails with err
+type fakeServer struct {
+	err     error
+	stopped chan struct{}
+	addr    string
+	drain   time.Duration
+}
+
+func (s *fakeServer) ListenAndServe(addr string) error {
+	s.addr = addr
+	if s.err != nil {
+		return s.err
+	}
+	<-s.stopped
+	return nil
+}
+
+func (s *fakeServer) Shutdown(ctx context.Context) error {
+	deadline, _ := ctx.Deadline()
+	s.drain = time.Until(deadline)
+	close(s.stopped)
+	return nil
+}
+
+func Test_listenAndServe(t *testing.T) {
+	lgr := zerolog.Nop()
+
+	t.Run("sigterm", func(t *testing.T) {
+		c := qt.New(t)
+		api := &fakeServer{stopped: make(chan struct{})}
+		admin := &fakeServer{stopped: make(chan struct{})}
+		sigs := make(chan os.Signal, 1)
+		sigs <- syscall.SIGTERM
+
+		err := listenAndServe(context.Background(), []listener{
+			{name: "api", srv: api, addr: "0.0.0.0:8080"},
+			{name: "admin", srv: admin, addr: "127.0.0.1:9090"},
+		}, sigs, 8*time.Second, lgr)
+		c.Assert(err, qt.IsNil)
+		// both servers are drained
+		for _, srv := range []*fakeServer{api, admin} {
+			c.Assert(srv.drain > 7*time.Second && srv.drain <= 8*time.Second, qt.IsTrue, qt.Commentf("drain = %s", srv.drain))
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		c := qt.New(t)
+		srvErr := errors.New("address already in use")
+		srv := &fakeServer{err: srvErr, stopped: make(chan struct{})}
+
+		err := listenAndServe(context.Background(), []listener{{name: "api", srv: srv, addr: "0.0.0.0:8080"}}, make(chan os.Signal), time.Second, lgr)
+		c.Assert(errors.Is(err, srvErr), qt.IsTrue)
+		c.Assert(err, qt.ErrorMatches, "api server error: address already in use")
+		c.Assert(srv.addr, qt.Equals, "0.0.0.0:8080")
+	})
+
+	t.Run("admin server error", func(t *testing.T) {
+		c := qt.New(t)
+		srvErr := errors.New("address already in use")
+		api := &fakeServer{stopped: make(chan struct{})}
+		admin := &fakeServer{err: srvErr, stopped: make(chan struct{})}
+
+		err := listenAndServe(context.Background(), []listener{
+			{name: "api", srv: api, addr: "0.0.0.0:8080"},
+			{name: "admin", srv: admin, addr: "127.0.0.1:9090"},
+		}, make(chan os.Signal), time.Second, lgr)
+		c.Assert(err, qt.ErrorMatches, "admin server error: address already in use")
+		// the API is shut down with the admin server
+		select {
+		case <-api.stopped:
+		default:
+			c.Fatal("api server not shut down")
+		}
+	})
+}
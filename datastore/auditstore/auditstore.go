@@ -0,0 +1,255 @@
+// Package auditstore persists API request audit records
+package auditstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// defaultFindLimit is the number of records returned by Find if
+// no Limit is given
+const defaultFindLimit = 100
+
+// NewDefaultStore is an initializer for DefaultStore
+func NewDefaultStore(ds datastore.Datastorer) DefaultStore {
+	return DefaultStore{ds}
+}
+
+// DefaultStore is the database implementation of audit.Store
+type DefaultStore struct {
+	datastore.Datastorer
+}
+
+// Insert writes the records to the api_audit table in a single
+// transaction. If the transaction fails, e.g. as the database rejects
+// one of the records, each record is written in a transaction of its
+// own, so a bad record does not lose the others of the batch. The
+// error returned is then that of the first record not written.
+func (d DefaultStore) Insert(ctx context.Context, records []audit.Record) error {
+	err := d.insert(ctx, records)
+	if err == nil || len(records) == 1 {
+		return err
+	}
+
+	var (
+		failed   int
+		firstErr error
+	)
+	for _, r := range records {
+		if err := d.insert(ctx, []audit.Record{r}); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+	}
+	if firstErr != nil {
+		return errs.E(errs.Database, errors.Wrapf(firstErr, "%d of %d audit records not written", failed, len(records)))
+	}
+
+	return nil
+}
+
+// insert writes the records in a single transaction
+func (d DefaultStore) insert(ctx context.Context, records []audit.Record) error {
+	return datastore.WithTx(ctx, d.Datastorer, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx,
+			`insert into demo.api_audit (request_id, method, path, username, status, latency_us, request_timestamp, user_agent, client_version, resource_type, resource_id, after_state, tenant)
+			 values ($1, $2, $3, $4, $5, $6, $7, $8, $9, nullif($10, ''), nullif($11, ''), $12, nullif($13, ''))`)
+		if err != nil {
+			return errs.E(errs.Database, err)
+		}
+		defer stmt.Close()
+
+		for _, r := range records {
+			_, err = stmt.ExecContext(ctx,
+				r.RequestID,
+				r.Method,
+				r.Path,
+				r.Username,
+				r.Status,
+				r.Latency.Microseconds(),
+				r.Timestamp,
+				r.UserAgent,
+				r.ClientVersion,
+				r.ResourceType,
+				r.ResourceID,
+				nullState(r.State),
+				r.Tenant)
+			if err != nil {
+				return errs.E(errs.Database, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Find returns the records matching the Filter, most recent first
+func (d DefaultStore) Find(ctx context.Context, f audit.Filter) ([]audit.Record, error) {
+	var s []audit.Record
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = find(ctx, tx, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// find returns the records matching the Filter using tx
+func find(ctx context.Context, tx *sql.Tx, f audit.Filter) ([]audit.Record, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultFindLimit
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`select request_id,
+				method,
+				path,
+				username,
+				status,
+				latency_us,
+				request_timestamp,
+				coalesce(user_agent, ''),
+				coalesce(client_version, '')
+		   from demo.api_audit
+		  where ($1 = '' or username = $1)
+		    and ($2 = '' or method = $2)
+		    and ($3 = '' or left(path, length($3)) = $3)
+		    and ($4::timestamptz is null or request_timestamp >= $4)
+		    and ($5::timestamptz is null or request_timestamp < $5)
+		  order by request_timestamp desc, audit_id desc
+		  limit $6`,
+		f.Username,
+		f.Method,
+		f.PathPrefix,
+		nullTime(f.Since),
+		nullTime(f.Until),
+		limit)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]audit.Record, 0)
+	for rows.Next() {
+		var (
+			r         audit.Record
+			latencyUS int64
+		)
+		err = rows.Scan(
+			&r.RequestID,
+			&r.Method,
+			&r.Path,
+			&r.Username,
+			&r.Status,
+			&latencyUS,
+			&r.Timestamp,
+			&r.UserAgent,
+			&r.ClientVersion)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		r.Latency = time.Duration(latencyUS) * time.Microsecond
+
+		s = append(s, r)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
+
+// Versions returns the versions of the resource of type typ with
+// the external ID id, oldest first, see audit.Store. The records of
+// every tenant are in the default database, so only those of the
+// tenant of ctx are read.
+func (d DefaultStore) Versions(ctx context.Context, typ, id string) ([]audit.Version, error) {
+	tenant, _ := requestinfo.TenantFromContext(ctx)
+
+	var s []audit.Version
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = versions(ctx, tx, tenant, typ, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// versions returns the versions of a resource of tenant using tx
+func versions(ctx context.Context, tx *sql.Tx, tenant, typ, id string) ([]audit.Version, error) {
+	rows, err := tx.QueryContext(ctx,
+		`select coalesce(request_id, ''),
+				request_timestamp,
+				after_state
+		   from demo.api_audit
+		  where resource_type = $1
+		    and resource_id = $2
+		    and status < 400
+		    and after_state is not null
+		    and coalesce(tenant, '') = $3
+		  order by request_timestamp, audit_id`,
+		typ,
+		id,
+		tenant)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]audit.Version, 0)
+	for rows.Next() {
+		v := audit.Version{Number: len(s) + 1}
+		var state []byte
+		err = rows.Scan(
+			&v.RequestID,
+			&v.Timestamp,
+			&state)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		v.State = state
+
+		s = append(s, v)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
+
+// nullState returns the state as a value for a jsonb column, null if
+// there is none
+func nullState(state json.RawMessage) interface{} {
+	if len(state) == 0 {
+		return nil
+	}
+	return string(state)
+}
+
+// nullTime returns a null sql.NullTime for the zero time
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
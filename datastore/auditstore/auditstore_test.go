@@ -0,0 +1,171 @@
+package auditstore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestDefaultStore(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// use a unique username so the test is independent of any
+	// existing records
+	username := uuid.New().String() + "@example.com"
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.api_audit where username = $1`, username)
+		if err != nil {
+			t.Errorf("delete api_audit error = %v", err)
+		}
+		cleanup()
+	})
+
+	d := NewDefaultStore(ds)
+
+	// postgres timestamps have microsecond precision
+	ts := time.Now().UTC().Truncate(time.Microsecond)
+	records := []audit.Record{
+		{RequestID: "req1", Method: "POST", Path: "/api/v1/movies", Username: username, Status: 200, Latency: 1500 * time.Microsecond, Timestamp: ts, UserAgent: "curl/7.64.1", ClientVersion: "2.3.1"},
+		{RequestID: "req2", Method: "DELETE", Path: "/api/v1/movies/abc", Username: username, Status: 404, Latency: time.Millisecond, Timestamp: ts.Add(time.Second)},
+	}
+
+	err := d.Insert(ctx, records)
+	c.Assert(err, qt.IsNil)
+
+	got, err := d.Find(ctx, audit.Filter{Username: username})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 2)
+	// most recent first
+	c.Assert(got[0].RequestID, qt.Equals, "req2")
+	c.Assert(got[1].Latency, qt.Equals, 1500*time.Microsecond)
+	c.Assert(got[1].Timestamp.Equal(ts), qt.IsTrue)
+	c.Assert(got[1].UserAgent, qt.Equals, "curl/7.64.1")
+	c.Assert(got[1].ClientVersion, qt.Equals, "2.3.1")
+	c.Assert(got[0].ClientVersion, qt.Equals, "")
+
+	got, err = d.Find(ctx, audit.Filter{Username: username, Method: "POST"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+
+	got, err = d.Find(ctx, audit.Filter{Username: username, PathPrefix: "/api/v1/movies/"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+
+	got, err = d.Find(ctx, audit.Filter{Username: username, Since: ts.Add(time.Millisecond)})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+
+	got, err = d.Find(ctx, audit.Filter{Username: username, Limit: 1})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+}
+
+// a record the database rejects does not lose the others of its
+// batch
+func TestDefaultStore_InsertBadRecord(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	username := uuid.New().String() + "@example.com"
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.api_audit where username = $1`, username)
+		if err != nil {
+			t.Errorf("delete api_audit error = %v", err)
+		}
+		cleanup()
+	})
+
+	d := NewDefaultStore(ds)
+
+	ts := time.Now().UTC().Truncate(time.Microsecond)
+	records := []audit.Record{
+		{RequestID: "req1", Method: "POST", Path: "/api/v1/movies", Username: username, Status: 200, Timestamp: ts},
+		{RequestID: "req2", Method: "DELETE", Path: "/api/v1/movies/\xff\xfe", Username: username, Status: 404, Timestamp: ts},
+		{RequestID: "req3", Method: "DELETE", Path: "/api/v1/movies/abc", Username: username, Status: 404, Timestamp: ts},
+	}
+
+	err := d.Insert(ctx, records)
+	c.Assert(errs.KindIs(errs.Database, err), qt.IsTrue)
+	c.Assert(err, qt.ErrorMatches, "1 of 3 audit records not written.*")
+
+	got, err := d.Find(ctx, audit.Filter{Username: username})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 2)
+}
+
+func TestDefaultStore_Versions(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// use a unique resource ID so the test is independent of any
+	// existing records
+	id := uuid.New().String()
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.api_audit where resource_id = $1`, id)
+		if err != nil {
+			t.Errorf("delete api_audit error = %v", err)
+		}
+		cleanup()
+	})
+
+	d := NewDefaultStore(ds)
+
+	ts := time.Now().UTC().Truncate(time.Microsecond)
+	records := []audit.Record{
+		{RequestID: "req1", Method: "POST", Path: "/api/v1/movies", Status: 200, Timestamp: ts, ResourceType: "movies", ResourceID: id, State: json.RawMessage(`{"Title": "Repo Man"}`)},
+		{RequestID: "req2", Method: "PUT", Path: "/api/v1/movies/" + id, Status: 422, Timestamp: ts.Add(time.Second), ResourceType: "movies", ResourceID: id, State: json.RawMessage(`{"Title": ""}`)},
+		{RequestID: "req3", Method: "PUT", Path: "/api/v1/movies/" + id, Status: 200, Timestamp: ts.Add(2 * time.Second), ResourceType: "movies", ResourceID: id, State: json.RawMessage(`{"Title": "Repo Man!"}`)},
+		{RequestID: "req4", Method: "DELETE", Path: "/api/v1/movies/" + id, Status: 200, Timestamp: ts.Add(3 * time.Second), ResourceType: "movies", ResourceID: id},
+		{RequestID: "req5", Method: "POST", Path: "/api/v1/movies", Status: 200, Timestamp: ts, ResourceType: "movies", ResourceID: id, State: json.RawMessage(`{"Title": "Alien"}`), Tenant: "acme"},
+	}
+	c.Assert(d.Insert(ctx, records), qt.IsNil)
+
+	// the failed update and the delete are not versions
+	got, err := d.Versions(ctx, "movies", id)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 2)
+	c.Assert(got[0].Number, qt.Equals, 1)
+	c.Assert(got[0].RequestID, qt.Equals, "req1")
+	c.Assert(got[0].Timestamp.Equal(ts), qt.IsTrue)
+	c.Assert([]byte(got[0].State), qt.JSONEquals, map[string]string{"Title": "Repo Man"})
+	c.Assert(got[1].Number, qt.Equals, 2)
+	c.Assert(got[1].RequestID, qt.Equals, "req3")
+
+	got, err = d.Versions(ctx, "people", id)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+
+	// a tenant only finds the versions of its own resources
+	got, err = d.Versions(requestinfo.WithTenant(ctx, "acme"), "movies", id)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+	c.Assert(got[0].RequestID, qt.Equals, "req5")
+
+	got, err = d.Versions(requestinfo.WithTenant(ctx, "globex"), "movies", id)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+}
@@ -0,0 +1,212 @@
+// Package backfill fills a derived column (e.g. a tsvector for full
+// text search, or a column added by a schema change) for rows which
+// existed before the column did. Rows are filled in batches, each in
+// its own statement, with a pause between batches so the backfill
+// does not crowd out requests, and progress is logged as it goes.
+//
+// A Backfill is a single SQL statement filling one batch of rows,
+// walked in key order, so a new one only needs its statement, e.g.:
+//
+//	with batch as (
+//	    select movie_id
+//	      from demo.movie
+//	     where ($1::uuid is null or movie_id > $1::uuid)
+//	     order by movie_id
+//	     limit $2),
+//	filled as (
+//	    update demo.movie m
+//	       set genre = ...
+//	      from batch
+//	     where m.movie_id = batch.movie_id
+//	       and m.genre is null
+//	    returning m.movie_id)
+//	select (select count(*) from batch),
+//	       (select count(*) from filled),
+//	       (select movie_id::text from batch order by movie_id desc limit 1)
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+const (
+	// DefaultBatchSize is the number of rows in a batch when no
+	// size is given
+	DefaultBatchSize int = 500
+	// DefaultPause is the default time waited between batches
+	DefaultPause time.Duration = 100 * time.Millisecond
+)
+
+// rowsFilled counts the rows filled by each backfill
+var rowsFilled = metrics.NewCounter("go_api_basic_backfill_rows_filled_total",
+	"Total number of rows filled by a backfill.", "backfill")
+
+// Backfill fills a derived column of existing rows
+type Backfill struct {
+	// Name identifies the backfill, e.g. movie-search-vector
+	Name string
+	// Description is a short description of what is filled
+	Description string
+	// Count is a statement returning the number of rows the
+	// backfill walks, to log progress as a percentage
+	Count string
+	// Batch is the statement filling a batch of rows. $1 is the key
+	// (as text) of the last row of the previous batch, or null for
+	// the first batch, and $2 is the batch size. It returns a single
+	// row: the number of rows in the batch, the number of them
+	// filled and the key (as text) of the last row in the batch.
+	Batch string
+}
+
+// Config configures how a backfill is run
+type Config struct {
+	// BatchSize is the number of rows in a batch. If not positive,
+	// DefaultBatchSize is used.
+	BatchSize int
+	// Pause is the time waited between batches, to limit the load
+	// put on the database. If zero, batches are run back to back.
+	Pause time.Duration
+	// After is the key of the last row already processed, to resume
+	// a backfill which was stopped. If empty, it starts from the
+	// first row.
+	After string
+}
+
+// Progress is how far a backfill got
+type Progress struct {
+	// Batches is the number of batches run
+	Batches int
+	// Scanned is the number of rows walked
+	Scanned int64
+	// Filled is the number of rows filled; rows already filled are
+	// walked but not filled again
+	Filled int64
+	// Last is the key of the last row walked, to resume from
+	Last string
+}
+
+// batch is the result of filling one batch of rows
+type batch struct {
+	scanned int64
+	filled  int64
+	last    sql.NullString
+}
+
+// batchFunc fills the batch of size rows after the row keyed after
+type batchFunc func(ctx context.Context, after sql.NullString, size int) (batch, error)
+
+// Run runs b against the database in batches, until every row has
+// been walked or ctx is cancelled. Rows already filled are left
+// alone, so a backfill can be run again, e.g. after it was stopped.
+// The Progress made is returned whether or not there is an error.
+func Run(ctx context.Context, ds datastore.Datastorer, b Backfill, cfg Config, lgr zerolog.Logger) (Progress, error) {
+	if b.Batch == "" {
+		return Progress{}, errs.E(errs.Validation, errors.Errorf("backfill %s has no batch statement", b.Name))
+	}
+
+	var total int64
+	if b.Count != "" {
+		err := ds.DB().QueryRowContext(ctx, b.Count).Scan(&total)
+		if err != nil {
+			return Progress{}, errs.E(errs.Database, err)
+		}
+	}
+
+	fill := func(ctx context.Context, after sql.NullString, size int) (batch, error) {
+		var bt batch
+		err := ds.DB().QueryRowContext(ctx, b.Batch, after, size).Scan(&bt.scanned, &bt.filled, &bt.last)
+		if err != nil {
+			return batch{}, errs.E(errs.Database, err)
+		}
+		return bt, nil
+	}
+
+	return run(ctx, b.Name, total, cfg, fill, lgr)
+}
+
+// run calls fill for each batch, pausing between batches, and logs
+// the progress made after each. total is the number of rows to walk,
+// or zero if not known.
+func run(ctx context.Context, name string, total int64, cfg Config, fill batchFunc, lgr zerolog.Logger) (Progress, error) {
+	size := cfg.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	p := Progress{Last: cfg.After}
+	after := datastore.NewNullString(cfg.After)
+
+	lgr = lgr.With().Str("backfill", name).Logger()
+	lgr.Info().Int64("total", total).Int("batch_size", size).Dur("pause", cfg.Pause).Msg("backfill started")
+
+	for {
+		bt, err := fill(ctx, after, size)
+		if err != nil {
+			lgr.Error().Err(err).Str("last", p.Last).Msg("backfill stopped, run again with the last key to resume")
+			return p, err
+		}
+
+		p.Batches++
+		p.Scanned += bt.scanned
+		p.Filled += bt.filled
+		if bt.last.Valid {
+			p.Last = bt.last.String
+			after = bt.last
+		}
+		rowsFilled.Add(float64(bt.filled), name)
+
+		e := lgr.Info().
+			Int("batch", p.Batches).
+			Int64("scanned", p.Scanned).
+			Int64("filled", p.Filled).
+			Str("last", p.Last)
+		if total > 0 {
+			e = e.Float64("percent", float64(p.Scanned)*100/float64(total))
+		}
+		e.Msg("backfill batch complete")
+
+		// a short batch is the last one
+		if bt.scanned < int64(size) {
+			break
+		}
+
+		if err = wait(ctx, cfg.Pause); err != nil {
+			lgr.Warn().Str("last", p.Last).Msg("backfill cancelled, run again with the last key to resume")
+			return p, err
+		}
+	}
+
+	lgr.Info().
+		Int("batches", p.Batches).
+		Int64("scanned", p.Scanned).
+		Int64("filled", p.Filled).
+		Msg("backfill complete")
+
+	return p, nil
+}
+
+// wait waits for d, or until ctx is cancelled
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// fakeTable fills batches of a table of n rows keyed 1 to n, where
+// the rows keyed in done are already filled
+type fakeTable struct {
+	n      int
+	done   map[int]bool
+	afters []sql.NullString
+	failAt int
+}
+
+func (ft *fakeTable) fill(ctx context.Context, after sql.NullString, size int) (batch, error) {
+	ft.afters = append(ft.afters, after)
+	if ft.failAt > 0 && len(ft.afters) == ft.failAt {
+		return batch{}, errors.New("connection reset")
+	}
+
+	start := 1
+	if after.Valid {
+		k, _ := strconv.Atoi(after.String)
+		start = k + 1
+	}
+	var bt batch
+	for k := start; k <= ft.n && bt.scanned < int64(size); k++ {
+		bt.scanned++
+		if !ft.done[k] {
+			bt.filled++
+		}
+		bt.last = sql.NullString{String: strconv.Itoa(k), Valid: true}
+	}
+	return bt, nil
+}
+
+func TestRun(t *testing.T) {
+	key := func(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+
+	tests := []struct {
+		name       string
+		table      *fakeTable
+		cfg        Config
+		want       Progress
+		wantAfters []sql.NullString
+		wantErr    bool
+	}{
+		{"batches", &fakeTable{n: 5}, Config{BatchSize: 2},
+			Progress{Batches: 3, Scanned: 5, Filled: 5, Last: "5"},
+			[]sql.NullString{key(""), key("2"), key("4")}, false},
+		{"last batch full", &fakeTable{n: 4}, Config{BatchSize: 2},
+			Progress{Batches: 3, Scanned: 4, Filled: 4, Last: "4"},
+			[]sql.NullString{key(""), key("2"), key("4")}, false},
+		{"already filled", &fakeTable{n: 3, done: map[int]bool{1: true, 3: true}}, Config{BatchSize: 5},
+			Progress{Batches: 1, Scanned: 3, Filled: 1, Last: "3"},
+			[]sql.NullString{key("")}, false},
+		{"resume", &fakeTable{n: 5}, Config{BatchSize: 2, After: "3"},
+			Progress{Batches: 2, Scanned: 2, Filled: 2, Last: "5"},
+			[]sql.NullString{key("3"), key("5")}, false},
+		{"empty", &fakeTable{n: 0}, Config{},
+			Progress{Batches: 1},
+			[]sql.NullString{key("")}, false},
+		{"error", &fakeTable{n: 5, failAt: 2}, Config{BatchSize: 2},
+			Progress{Batches: 1, Scanned: 2, Filled: 2, Last: "2"},
+			[]sql.NullString{key(""), key("2")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			got, err := run(context.Background(), "test", int64(tt.table.n), tt.cfg, tt.table.fill, logger.NewLogger(&buf, false))
+			if tt.wantErr {
+				c.Assert(err, qt.ErrorMatches, "connection reset")
+				c.Assert(buf.String(), qt.Contains, "run again with the last key to resume")
+			} else {
+				c.Assert(err, qt.IsNil)
+				c.Assert(buf.String(), qt.Contains, "backfill complete")
+			}
+			c.Assert(got, qt.DeepEquals, tt.want)
+			c.Assert(tt.table.afters, qt.DeepEquals, tt.wantAfters)
+		})
+	}
+}
+
+func TestRun_pause(t *testing.T) {
+	c := qt.New(t)
+
+	ft := &fakeTable{n: 3}
+	start := time.Now()
+	_, err := run(context.Background(), "test", 0, Config{BatchSize: 1, Pause: 20 * time.Millisecond}, ft.fill, logger.NewLogger(&bytes.Buffer{}, false))
+	c.Assert(err, qt.IsNil)
+
+	// 4 batches, the last one empty, with a pause between each
+	c.Assert(len(ft.afters), qt.Equals, 4)
+	c.Assert(time.Since(start) >= 60*time.Millisecond, qt.IsTrue)
+}
+
+func TestRun_cancelled(t *testing.T) {
+	c := qt.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ft := &fakeTable{n: 10}
+	fill := func(ctx context.Context, after sql.NullString, size int) (batch, error) {
+		// cancelled while the first batch runs, which completes
+		cancel()
+		return ft.fill(ctx, after, size)
+	}
+
+	got, err := run(ctx, "test", 10, Config{BatchSize: 2, Pause: time.Hour}, fill, logger.NewLogger(&bytes.Buffer{}, false))
+	c.Assert(errors.Is(err, context.Canceled), qt.IsTrue)
+	c.Assert(got, qt.DeepEquals, Progress{Batches: 1, Scanned: 2, Filled: 2, Last: "2"})
+}
+
+func TestRun_noBatch(t *testing.T) {
+	c := qt.New(t)
+
+	_, err := Run(context.Background(), nil, Backfill{Name: "test"}, Config{}, logger.NewLogger(&bytes.Buffer{}, false))
+	c.Assert(err, qt.ErrorMatches, "backfill test has no batch statement")
+}
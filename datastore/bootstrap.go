@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	_ "embed" // embed is used for the bootstrap DDL
+
+	"github.com/rs/zerolog"
+)
+
+// bootstrapDDL is the idempotent DDL for all database objects used
+// by the application
+//
+//go:embed ddl/bootstrap.sql
+var bootstrapDDL string
+
+// bootstrapLockID is the key for the PostgreSQL advisory lock held
+// while bootstrapping, so that instances starting at the same time
+// do not run the DDL concurrently
+const bootstrapLockID int64 = 0x676f617069
+
+// Bootstrap creates any of the schema, tables, indexes and functions
+// used by the application which do not already exist in the
+// database. The DDL is embedded in the binary and every statement in
+// it is idempotent, so Bootstrap can safely be run on each startup.
+// All statements are run in a single transaction.
+func Bootstrap(ctx context.Context, ds Datastorer, logger zerolog.Logger) error {
+	err := WithTx(ctx, ds, func(tx *sql.Tx) error {
+		// the lock is released when the transaction ends
+		_, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock($1)`, bootstrapLockID)
+		if err != nil {
+			return err
+		}
+
+		// the DDL has no bind parameters, so all statements are
+		// sent at once using the simple query protocol
+		_, err = tx.ExecContext(ctx, bootstrapDDL)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Msg("database bootstrap complete")
+
+	return nil
+}
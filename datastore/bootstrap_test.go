@@ -0,0 +1,37 @@
+package datastore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestBootstrap(t *testing.T) {
+	c := qt.New(t)
+
+	dsn := testDSN(t)
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	db, cleanup, err := NewDB(dsn, lgr)
+	t.Cleanup(cleanup)
+	if err != nil {
+		t.Fatalf("datastore.NewDB error = %v", err)
+	}
+	ds := NewDefaultDatastore(db)
+	ctx := context.Background()
+
+	// Bootstrap must be idempotent, so running it against a database
+	// where the objects already exist must not fail
+	for i := 0; i < 2; i++ {
+		err = Bootstrap(ctx, ds, lgr)
+		c.Assert(err, qt.IsNil)
+	}
+
+	var n int
+	err = db.QueryRowContext(ctx, `select count(*) from demo.movie`).Scan(&n)
+	c.Assert(err, qt.IsNil)
+}
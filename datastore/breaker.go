@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// BreakerConfig configures a Breaker
+type BreakerConfig struct {
+	// Interval is how often the database is probed and the pool
+	// checked. Zero disables the Breaker.
+	Interval time.Duration
+	// Timeout is how long a probe may take before it fails
+	Timeout time.Duration
+	// Threshold is the number of consecutive failed probes which
+	// open the Breaker
+	Threshold int
+	// WaitThreshold is the total time spent waiting for a connection
+	// within an Interval which, with every connection of the pool in
+	// use, means the pool is exhausted. Zero does not check the pool.
+	WaitThreshold time.Duration
+}
+
+// NewBreaker is an initializer for Breaker
+func NewBreaker(db *sql.DB, cfg BreakerConfig) *Breaker {
+	if cfg.Threshold < 1 {
+		cfg.Threshold = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = cfg.Interval
+	}
+	return &Breaker{db: db, cfg: cfg, prev: db.Stats()}
+}
+
+// Breaker is a circuit breaker for a database. It probes the database
+// every Interval and opens after Threshold consecutive probes fail,
+// closing again on the first probe which succeeds. It also tracks
+// whether the connection pool is exhausted: every connection is in
+// use and more than WaitThreshold was spent waiting for one during
+// the last Interval. While either is the case, requests are better
+// turned away at once than left to wait for a connection they will
+// not get (see Available) and the server should not be sent traffic
+// (see CheckHealth). It is safe for concurrent use.
+type Breaker struct {
+	db  *sql.DB
+	cfg BreakerConfig
+
+	mu        sync.RWMutex
+	failures  int
+	open      bool
+	exhausted bool
+	prev      sql.DBStats
+}
+
+// Run probes the database every Interval until ctx is done
+func (b *Breaker) Run(ctx context.Context, lgr zerolog.Logger) {
+	if b.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.probe(ctx, lgr)
+		}
+	}
+}
+
+// probe pings the database and checks the pool statistics, updating
+// the state of b
+func (b *Breaker) probe(ctx context.Context, lgr zerolog.Logger) {
+	pctx, cancel := context.WithTimeout(ctx, b.cfg.Timeout)
+	err := b.db.PingContext(pctx)
+	cancel()
+	s := b.db.Stats()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	waited := s.WaitDuration - b.prev.WaitDuration
+	exhausted := b.cfg.WaitThreshold > 0 && s.MaxOpenConnections > 0 &&
+		s.InUse >= s.MaxOpenConnections && waited > b.cfg.WaitThreshold
+	b.prev = s
+	if exhausted != b.exhausted {
+		if exhausted {
+			lgr.Warn().Dur("wait_duration", waited).Int("in_use", s.InUse).Msg("database connection pool exhausted, requests are turned away")
+		} else {
+			lgr.Info().Msg("database connection pool no longer exhausted")
+		}
+		b.exhausted = exhausted
+	}
+
+	if err == nil {
+		if b.open {
+			lgr.Info().Msg("database available, circuit breaker closed")
+		}
+		b.failures, b.open = 0, false
+		return
+	}
+
+	b.failures++
+	if !b.open && b.failures >= b.cfg.Threshold {
+		lgr.Error().Err(err).Int("failures", b.failures).Msg("database unavailable, circuit breaker opened")
+		b.open = true
+	}
+}
+
+// Available returns an errs.Unavailable error, telling the client to
+// retry after the next probe, if the Breaker is open or the pool is
+// exhausted, or else nil
+func (b *Breaker) Available() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	retry := errs.RetryAfter(b.cfg.Interval)
+	switch {
+	case b.open:
+		return errs.E(errs.Unavailable, errs.Code("datastore_unavailable"), retry, errors.New("the database is unavailable, retry later"))
+	case b.exhausted:
+		return errs.E(errs.Unavailable, errs.Code("datastore_busy"), retry, errors.New("the database is too busy, retry later"))
+	}
+	return nil
+}
+
+// CheckHealth satisfies the health.Checker interface, so the server
+// is not ready while the database is not Available
+func (b *Breaker) CheckHealth() error {
+	return b.Available()
+}
@@ -0,0 +1,92 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// breakerConnector opens fake connections, or fails to if down is
+// set, so the Breaker can be tested without a database
+type breakerConnector struct {
+	down *int32
+}
+
+func (c breakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if atomic.LoadInt32(c.down) == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return breakerConn{}, nil
+}
+
+func (c breakerConnector) Driver() driver.Driver { return nil }
+
+type breakerConn struct{}
+
+func (breakerConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (breakerConn) Close() error              { return nil }
+func (breakerConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func TestBreaker(t *testing.T) {
+	t.Run("opens and closes", func(t *testing.T) {
+		c := qt.New(t)
+
+		var down int32
+		db := sql.OpenDB(breakerConnector{down: &down})
+		defer db.Close()
+		b := NewBreaker(db, BreakerConfig{Interval: 5 * time.Second, Threshold: 2})
+		ctx := context.Background()
+
+		b.probe(ctx, zerolog.Nop())
+		c.Assert(b.Available(), qt.IsNil)
+
+		atomic.StoreInt32(&down, 1)
+		// idle connections would still be pinged successfully
+		db.SetMaxIdleConns(0)
+		b.probe(ctx, zerolog.Nop())
+		c.Assert(b.Available(), qt.IsNil)
+		b.probe(ctx, zerolog.Nop())
+
+		err := b.Available()
+		c.Assert(errs.Match(errs.E(errs.Unavailable, errs.Code("datastore_unavailable"), errs.RetryAfter(5*time.Second)), err), qt.IsTrue, qt.Commentf("%v", err))
+		c.Assert(b.CheckHealth(), qt.Not(qt.IsNil))
+
+		atomic.StoreInt32(&down, 0)
+		b.probe(ctx, zerolog.Nop())
+		c.Assert(b.Available(), qt.IsNil)
+	})
+
+	t.Run("pool exhausted", func(t *testing.T) {
+		c := qt.New(t)
+
+		var down int32
+		db := sql.OpenDB(breakerConnector{down: &down})
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		b := NewBreaker(db, BreakerConfig{Interval: time.Second, Timeout: 50 * time.Millisecond, Threshold: 3, WaitThreshold: 10 * time.Millisecond})
+		ctx := context.Background()
+
+		// hold the only connection, so the probe waits for it
+		conn, err := db.Conn(ctx)
+		c.Assert(err, qt.IsNil)
+		b.probe(ctx, zerolog.Nop())
+
+		err = b.Available()
+		c.Assert(errs.Match(errs.E(errs.Unavailable, errs.Code("datastore_busy")), err), qt.IsTrue, qt.Commentf("%v", err))
+
+		c.Assert(conn.Close(), qt.IsNil)
+		b.probe(ctx, zerolog.Nop())
+		c.Assert(b.Available(), qt.IsNil)
+	})
+}
@@ -0,0 +1,200 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+const (
+	// credentialCheckInterval is how often the password file is
+	// checked for changes when credential rotation is enabled
+	credentialCheckInterval = 30 * time.Second
+
+	// defaultMaxIdleConns is the database/sql default for the
+	// maximum number of idle connections kept in the pool, used when
+	// PGDatasourceName.MaxIdleConns is not set
+	defaultMaxIdleConns = 2
+)
+
+// currentPassword returns the password to use for a new connection.
+// If PasswordFile is set, the file is read each time so that a
+// rotated password (e.g. a mounted Kubernetes or Cloud Run secret)
+// is picked up without a restart, otherwise Password is returned.
+func (dsn PGDatasourceName) currentPassword() (string, error) {
+	if dsn.PasswordFile == "" {
+		return dsn.Password, nil
+	}
+
+	b, err := ioutil.ReadFile(dsn.PasswordFile)
+	if err != nil {
+		return "", errs.E(errs.Database, errs.Code("password_file_read"), err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// pgConnector satisfies the driver.Connector interface and builds
+// each new connection using the current credentials for the
+// datasource name
+type pgConnector struct {
+	dsn    PGDatasourceName
+	logger zerolog.Logger
+}
+
+// Connect resolves the current password and opens a new connection
+// using the pq driver
+func (c pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// decorate the connection to comment statements with the
+	// request ID, and name its transactions for it, if enabled
+	if c.dsn.CommentRequestID {
+		conn = requestIDConn{Conn: conn, appName: c.dsn.ApplicationName}
+	}
+
+	// decorate the connection to cache prepared statements if
+	// enabled. The cache is outside of the request ID comment, as a
+	// commented statement would never be run twice: the statements
+	// run from the cache are not commented, but their transactions
+	// are still named for the request.
+	if c.dsn.StatementCacheSize > 0 {
+		pid, err := backendPID(ctx, conn)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = newStmtCacheConn(conn, c.dsn.StatementCacheSize, func(ctx context.Context) error {
+			return c.cancelBackend(ctx, pid)
+		})
+	}
+
+	// decorate the connection to log statements if enabled
+	if statementLogging() {
+		return loggingConn{Conn: conn, logger: c.logger}, nil
+	}
+
+	return conn, nil
+}
+
+// open opens a new connection using the pq driver and the current
+// password
+func (c pgConnector) open(ctx context.Context) (driver.Conn, error) {
+	dsn := c.dsn
+
+	pw, err := dsn.currentPassword()
+	if err != nil {
+		return nil, err
+	}
+	dsn.Password = pw
+
+	connector, err := pq.NewConnector(dsn.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(ctx)
+}
+
+// Driver returns the underlying pq driver
+func (c pgConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// watchCredentials re-reads the password file on SIGHUP or when the
+// file's modification time changes. When the password changes, idle
+// connections in the pool are closed so that new connections are
+// opened with the rotated password. Connections which are in use
+// are allowed to finish their work, which means credentials can be
+// rotated without any downtime. watchCredentials blocks until the
+// context is cancelled.
+func watchCredentials(ctx context.Context, db *sql.DB, dsn PGDatasourceName, lgr zerolog.Logger) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	ticker := time.NewTicker(credentialCheckInterval)
+	defer ticker.Stop()
+
+	lastMod := passwordFileModTime(dsn.PasswordFile)
+	lastPassword, _ := dsn.currentPassword()
+
+	reload := func(reason string) {
+		pw, err := dsn.currentPassword()
+		if err != nil {
+			lgr.Error().Err(err).Msg("unable to re-read database password file")
+			return
+		}
+		if pw == lastPassword {
+			return
+		}
+		lastPassword = pw
+
+		lgr.Info().Str("reason", reason).Msg("database credentials changed, recycling idle connections")
+		recycleIdleConns(db, dsn.maxIdleConns())
+
+		if err = db.PingContext(ctx); err != nil {
+			lgr.Error().Err(err).Msg("database Ping failed after credential rotation")
+			return
+		}
+		lgr.Info().Msg("database Ping returned successfully after credential rotation")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			reload("SIGHUP")
+		case <-ticker.C:
+			mod := passwordFileModTime(dsn.PasswordFile)
+			if mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			reload("password file modified")
+		}
+	}
+}
+
+// recycleIdleConns closes all idle connections in the pool by
+// temporarily setting the max idle connections to zero, then
+// restoring it to maxIdle. Connections currently in use are
+// unaffected.
+func recycleIdleConns(db *sql.DB, maxIdle int) {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(maxIdle)
+}
+
+// maxIdleConns returns the maximum number of idle connections of
+// the pool, MaxIdleConns or else the database/sql default
+func (dsn PGDatasourceName) maxIdleConns() int {
+	if dsn.MaxIdleConns > 0 {
+		return dsn.MaxIdleConns
+	}
+	return defaultMaxIdleConns
+}
+
+// passwordFileModTime returns the modification time of the password
+// file or the zero time if the file cannot be read
+func passwordFileModTime(name string) time.Time {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,57 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestPGDatasourceName_currentPassword(t *testing.T) {
+	c := qt.New(t)
+
+	dir := t.TempDir()
+	pwFile := filepath.Join(dir, "db-password")
+	err := ioutil.WriteFile(pwFile, []byte("rotated\n"), 0600)
+	c.Assert(err, qt.IsNil)
+
+	tests := []struct {
+		name     string
+		dsn      PGDatasourceName
+		want     string
+		wantCode errs.Code
+	}{
+		{"password", PGDatasourceName{Password: "supahsecret"}, "supahsecret", ""},
+		{"password file", PGDatasourceName{Password: "supahsecret", PasswordFile: pwFile}, "rotated", ""},
+		{"missing password file", PGDatasourceName{PasswordFile: filepath.Join(dir, "nope")}, "", "password_file_read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			got, err := tt.dsn.currentPassword()
+			if tt.wantCode != "" {
+				c.Assert(errs.Match(errs.E(tt.wantCode), err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+
+	// rotate the password and ensure it is picked up
+	err = ioutil.WriteFile(pwFile, []byte("rotatedagain"), 0600)
+	c.Assert(err, qt.IsNil)
+	got, err := PGDatasourceName{PasswordFile: pwFile}.currentPassword()
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "rotatedagain")
+}
+
+func TestPGDatasourceName_maxIdleConns(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(PGDatasourceName{}.maxIdleConns(), qt.Equals, defaultMaxIdleConns)
+	c.Assert(PGDatasourceName{MaxIdleConns: 10}.maxIdleConns(), qt.Equals, 10)
+}
@@ -7,6 +7,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gilcrest/go-api-basic/domain/errs"
 
@@ -18,8 +20,9 @@ import (
 type Datastorer interface {
 	// DB returns a sql.DB
 	DB() *sql.DB
-	// BeginTx starts a sql.Tx using the input context
-	BeginTx(context.Context) (*sql.Tx, error)
+	// BeginTx starts a sql.Tx using the input context and options.
+	// If the options are nil, the database defaults are used.
+	BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
 	// RollbackTx rolls back the input sql.Tx
 	RollbackTx(*sql.Tx, error) error
 	// CommitTx commits the Tx
@@ -45,6 +48,34 @@ type PGDatasourceName struct {
 	DBName   string
 	User     string
 	Password string
+	// PasswordFile is an optional path to a file holding the
+	// password. If set, it takes precedence over Password and is
+	// re-read when credentials are rotated.
+	PasswordFile string
+	// ConnectWait is how long NewDB retries connecting to a database
+	// which is not available yet, e.g. when it is started at the
+	// same time as the server. If zero, NewDB fails on the first
+	// error.
+	ConnectWait time.Duration
+	// ApplicationName is set as the application_name of each
+	// connection, so the connections of the server can be told apart
+	// in pg_stat_activity and the server logs
+	ApplicationName string
+	// CommentRequestID sets the application_name of each
+	// transaction begun for a request to hold the request ID, and
+	// prefixes each statement run for a request with a comment
+	// holding it (see requestIDConn). The statements run from the
+	// statement cache are prepared, and so are not commented, but
+	// their transactions are named.
+	CommentRequestID bool
+	// StatementCacheSize is how many prepared statements are cached
+	// per connection, so a statement is parsed and planned once per
+	// connection rather than on every call (see stmtCacheConn). If
+	// zero, statements are not cached.
+	StatementCacheSize int
+	// MaxIdleConns is the maximum number of idle connections kept
+	// in the pool. If zero, the database/sql default is used.
+	MaxIdleConns int
 }
 
 // String returns a formatted PostgreSQL datasource name. If you are
@@ -52,12 +83,26 @@ type PGDatasourceName struct {
 // string, otherwise the connection will fail.
 func (dsn PGDatasourceName) String() string {
 	// Craft string for database connection
+	var s string
 	switch dsn.Password {
 	case "":
-		return fmt.Sprintf("host=%s port=%d dbname=%s user=%s sslmode=disable", dsn.Host, dsn.Port, dsn.DBName, dsn.User)
+		s = fmt.Sprintf("host=%s port=%d dbname=%s user=%s sslmode=disable", dsn.Host, dsn.Port, dsn.DBName, dsn.User)
 	default:
-		return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable", dsn.Host, dsn.Port, dsn.DBName, dsn.User, dsn.Password)
+		s = fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=disable", dsn.Host, dsn.Port, dsn.DBName, dsn.User, dsn.Password)
 	}
+
+	if dsn.ApplicationName != "" {
+		s += " application_name=" + quoteDSNValue(dsn.ApplicationName)
+	}
+
+	return s
+}
+
+// quoteDSNValue quotes v as a value of a key=value datasource name,
+// escaping any single quotes and backslashes
+func quoteDSNValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(v) + "'"
 }
 
 // NewDefaultDatastore is an initializer for the default Datastore struct
@@ -77,12 +122,12 @@ func (ds DefaultDatastore) DB() *sql.DB {
 
 // BeginTx is a wrapper for sql.DB.BeginTx in order to expose from
 // the Datastore interface
-func (ds DefaultDatastore) BeginTx(ctx context.Context) (*sql.Tx, error) {
+func (ds DefaultDatastore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
 	if ds.db == nil {
 		return nil, errs.E(errs.Database, errors.New("DB cannot be nil"))
 	}
 
-	tx, err := ds.db.BeginTx(ctx, nil)
+	tx, err := ds.db.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, errs.E(errs.Database, err)
 	}
@@ -139,3 +184,73 @@ func NewNullInt64(i int64) sql.NullInt64 {
 		Valid: true,
 	}
 }
+
+// NewNullTime returns a null if t is the zero time, otherwise it
+// returns the time which was input
+func NewNullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{
+		Time:  t,
+		Valid: true,
+	}
+}
+
+// NullStringFromPtr returns a null if p is nil, otherwise it returns
+// the string p points to, even if empty. It is for optional values
+// where empty and not set differ.
+func NullStringFromPtr(p *string) sql.NullString {
+	if p == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *p, Valid: true}
+}
+
+// StringPtr returns nil if ns is null, otherwise it returns a
+// pointer to its string
+func StringPtr(ns sql.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	s := ns.String
+	return &s
+}
+
+// NullInt64FromPtr returns a null if p is nil, otherwise it returns
+// the int64 p points to, even if zero
+func NullInt64FromPtr(p *int64) sql.NullInt64 {
+	if p == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *p, Valid: true}
+}
+
+// Int64Ptr returns nil if ni is null, otherwise it returns a pointer
+// to its int64
+func Int64Ptr(ni sql.NullInt64) *int64 {
+	if !ni.Valid {
+		return nil
+	}
+	i := ni.Int64
+	return &i
+}
+
+// NullTimeFromPtr returns a null if p is nil, otherwise it returns
+// the time p points to, even if zero
+func NullTimeFromPtr(p *time.Time) sql.NullTime {
+	if p == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *p, Valid: true}
+}
+
+// TimePtr returns nil if nt is null, otherwise it returns a pointer
+// to its time
+func TimePtr(nt sql.NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	t := nt.Time
+	return &t
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 	"github.com/pkg/errors"
@@ -32,11 +33,12 @@ func TestNewPGDatasourceName(t *testing.T) {
 
 func TestPGDatasourceName_String(t *testing.T) {
 	type fields struct {
-		Host     string
-		Port     int
-		DBName   string
-		User     string
-		Password string
+		Host            string
+		Port            int
+		DBName          string
+		User            string
+		Password        string
+		ApplicationName string
 	}
 	tests := []struct {
 		name   string
@@ -45,15 +47,18 @@ func TestPGDatasourceName_String(t *testing.T) {
 	}{
 		{"with password", fields{Host: "localhost", Port: 8080, DBName: "go_api_basic", User: "postgres", Password: "supahsecret"}, "host=localhost port=8080 dbname=go_api_basic user=postgres password=supahsecret sslmode=disable"},
 		{"without password", fields{Host: "localhost", Port: 8080, DBName: "go_api_basic", User: "postgres", Password: ""}, "host=localhost port=8080 dbname=go_api_basic user=postgres sslmode=disable"},
+		{"with application name", fields{Host: "localhost", Port: 8080, DBName: "go_api_basic", User: "postgres", ApplicationName: "go-api-basic"}, "host=localhost port=8080 dbname=go_api_basic user=postgres sslmode=disable application_name='go-api-basic'"},
+		{"quoted application name", fields{Host: "localhost", Port: 8080, DBName: "go_api_basic", User: "postgres", ApplicationName: `otto's api`}, `host=localhost port=8080 dbname=go_api_basic user=postgres sslmode=disable application_name='otto\'s api'`},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dsn := PGDatasourceName{
-				Host:     tt.fields.Host,
-				Port:     tt.fields.Port,
-				DBName:   tt.fields.DBName,
-				User:     tt.fields.User,
-				Password: tt.fields.Password,
+				Host:            tt.fields.Host,
+				Port:            tt.fields.Port,
+				DBName:          tt.fields.DBName,
+				User:            tt.fields.User,
+				Password:        tt.fields.Password,
+				ApplicationName: tt.fields.ApplicationName,
 			}
 			if got := dsn.String(); got != tt.want {
 				t.Errorf("String() = %v, want %v", got, tt.want)
@@ -67,7 +72,7 @@ func TestDatastore_DB(t *testing.T) {
 
 	logger := logger.NewLogger(os.Stdout, true)
 
-	ogdb, cleanup, err := NewDB(NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432), logger)
+	ogdb, cleanup, err := NewDB(testDSN(t), logger)
 	defer cleanup()
 	if err != nil {
 		t.Fatal(err)
@@ -83,7 +88,7 @@ func TestNewDatastore(t *testing.T) {
 
 	logger := logger.NewLogger(os.Stdout, true)
 
-	db, cleanup, err := NewDB(NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432), logger)
+	db, cleanup, err := NewDB(testDSN(t), logger)
 	defer cleanup()
 	if err != nil {
 		t.Fatal(err)
@@ -125,7 +130,7 @@ func TestDatastore_BeginTx(t *testing.T) {
 		ctx context.Context
 	}
 
-	dsn := NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432)
+	dsn := testDSN(t)
 	lgr := logger.NewLogger(os.Stdout, true)
 
 	db, cleanup, err := NewDB(dsn, lgr)
@@ -151,7 +156,7 @@ func TestDatastore_BeginTx(t *testing.T) {
 			if tt.wantErr == true {
 				tt.fields.cleanup()
 			}
-			got, err := ds.BeginTx(tt.args.ctx)
+			got, err := ds.BeginTx(tt.args.ctx, nil)
 			t.Logf("BeginTx error = %v", err)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("BeginTx() error = %v, wantErr %v", err, tt.wantErr)
@@ -174,7 +179,7 @@ func TestDatastore_RollbackTx(t *testing.T) {
 		err error
 	}
 
-	dsn := NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432)
+	dsn := testDSN(t)
 	lgr := logger.NewLogger(os.Stdout, true)
 
 	db, cleanup, err := NewDB(dsn, lgr)
@@ -235,7 +240,7 @@ func TestDatastore_CommitTx(t *testing.T) {
 	type args struct {
 		tx *sql.Tx
 	}
-	dsn := NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432)
+	dsn := testDSN(t)
 	lgr := logger.NewLogger(os.Stdout, true)
 
 	db, cleanup, err := NewDB(dsn, lgr)
@@ -310,3 +315,48 @@ func TestNewNullString(t *testing.T) {
 		})
 	}
 }
+
+func TestNewNullTime(t *testing.T) {
+	c := qt.New(t)
+
+	released := time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    time.Time
+		want sql.NullTime
+	}{
+		{"has value", released, sql.NullTime{Time: released, Valid: true}},
+		{"zero value", time.Time{}, sql.NullTime{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Assert(NewNullTime(tt.t), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestPtrConversions(t *testing.T) {
+	c := qt.New(t)
+
+	// nil round-trips as null
+	c.Assert(NullStringFromPtr(nil), qt.Equals, sql.NullString{})
+	c.Assert(StringPtr(sql.NullString{}), qt.IsNil)
+	c.Assert(NullInt64FromPtr(nil), qt.Equals, sql.NullInt64{})
+	c.Assert(Int64Ptr(sql.NullInt64{}), qt.IsNil)
+	c.Assert(NullTimeFromPtr(nil), qt.Equals, sql.NullTime{})
+	c.Assert(TimePtr(sql.NullTime{}), qt.IsNil)
+
+	// zero values are not null
+	s := ""
+	c.Assert(NullStringFromPtr(&s), qt.Equals, sql.NullString{Valid: true})
+	c.Assert(*StringPtr(sql.NullString{Valid: true}), qt.Equals, "")
+	i := int64(0)
+	c.Assert(NullInt64FromPtr(&i), qt.Equals, sql.NullInt64{Valid: true})
+	c.Assert(*Int64Ptr(sql.NullInt64{Valid: true}), qt.Equals, int64(0))
+	tm := time.Time{}
+	c.Assert(NullTimeFromPtr(&tm), qt.Equals, sql.NullTime{Valid: true})
+	c.Assert(TimePtr(sql.NullTime{Valid: true}).IsZero(), qt.IsTrue)
+
+	writer := "Alex Cox"
+	c.Assert(*StringPtr(NullStringFromPtr(&writer)), qt.Equals, writer)
+}
@@ -5,18 +5,53 @@
 package datastoretest
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"strconv"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/internal/pgcontainer"
 )
 
+// ContainerDSN returns the PGDatasourceName of a throwaway database
+// in a container, started by the first test of the package asking
+// for it and bootstrapped using datastore.Bootstrap (see
+// pgcontainer.Start). The test is skipped if no container can be
+// started.
+func ContainerDSN(t testing.TB) datastore.PGDatasourceName {
+	t.Helper()
+
+	d, err := pgcontainer.Start(bootstrap)
+	if errors.Cause(err) == pgcontainer.ErrUnavailable {
+		t.Skipf("skipping db dependent test, no DB_HOST and %v", err)
+	}
+	if err != nil {
+		t.Fatalf("pgcontainer.Start() error = %v", err)
+	}
+
+	return datastore.NewPGDatasourceName(d.Host, d.Name, d.User, d.Password, d.Port)
+}
+
+// bootstrap creates the database objects in the container database d
+func bootstrap(ctx context.Context, d pgcontainer.DB) error {
+	dsn := datastore.NewPGDatasourceName(d.Host, d.Name, d.User, d.Password, d.Port)
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return datastore.Bootstrap(ctx, datastore.NewDefaultDatastore(db), zerolog.Nop())
+}
+
 // newPGDatasourceName is a test helper to get a PGDatasourceName
-// from environment variables
+// from environment variables, or of a database in a container if
+// DB_HOST is not set (see ContainerDSN)
 func newPGDatasourceName(t *testing.T) datastore.PGDatasourceName {
 	t.Helper()
 
@@ -41,7 +76,7 @@ func newPGDatasourceName(t *testing.T) datastore.PGDatasourceName {
 
 	dbHost, ok = os.LookupEnv(pgDBHost)
 	if !ok {
-		t.Fatalf("No environment variable found for %s", pgDBHost)
+		return ContainerDSN(t)
 	}
 
 	p, ok := os.LookupEnv(pgDBPort)
@@ -79,6 +114,10 @@ func newPGDatasourceName(t *testing.T) datastore.PGDatasourceName {
 //		DB Name     = DB_NAME
 //		DB User     = DB_USER
 //		DB Password = DB_PASSWORD
+//
+// If DB_HOST is not set, a database in a container is used instead,
+// or the test is skipped when there is no container (see
+// ContainerDSN).
 func NewDB(t *testing.T, lgr zerolog.Logger) (*sql.DB, func()) {
 	t.Helper()
 
@@ -108,6 +147,9 @@ func NewDB(t *testing.T, lgr zerolog.Logger) (*sql.DB, func()) {
 //		DB Name     = DB_NAME
 //		DB User     = DB_USER
 //		DB Password = DB_PASSWORD
+//
+// If DB_HOST is not set, a database in a container is used instead,
+// as for NewDB.
 func NewDefaultDatastore(t *testing.T, lgr zerolog.Logger) (datastore.DefaultDatastore, func()) {
 	t.Helper()
 
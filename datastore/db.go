@@ -1,7 +1,9 @@
 package datastore
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -14,21 +16,73 @@ func NewDB(dsn PGDatasourceName, logger zerolog.Logger) (*sql.DB, func(), error)
 
 	f := func() {}
 
-	// Open the postgres database using the postgres driver (pq)
-	// func Open(driverName, dataSourceName string) (*DB, error)
-	db, err := sql.Open("postgres", dsn.String())
-	if err != nil {
-		return nil, f, errs.E(errs.Database, err)
-	}
+	// Open the postgres database using a connector for the postgres
+	// driver (pq). The connector resolves the current credentials
+	// each time a new connection is opened.
+	db := sql.OpenDB(pgConnector{dsn: dsn, logger: logger})
+	db.SetMaxIdleConns(dsn.maxIdleConns())
 
 	logger.Info().Msgf("sql database opened for %s on port %d", dsn.Host, dsn.Port)
 
-	err = validateDB(db, logger)
+	err := retryConnect(dsn.ConnectWait, connectBackoff, func() error {
+		return validateDB(db, logger)
+	}, logger)
 	if err != nil {
+		db.Close()
 		return nil, f, err
 	}
 
-	return db, func() { db.Close() }, nil
+	// If the password is read from a file, watch the file (and
+	// SIGHUP) for changes so credentials can be rotated without
+	// restarting the server
+	if dsn.PasswordFile == "" {
+		return db, func() { db.Close() }, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go watchCredentials(ctx, db, dsn, logger)
+	logger.Info().Msgf("watching %s for database credential rotation", dsn.PasswordFile)
+
+	return db, func() {
+		cancel()
+		db.Close()
+	}, nil
+}
+
+// connectBackoff is the wait before the first retry of a failed
+// connection. It is doubled for each retry after, up to
+// maxConnectBackoff.
+const (
+	connectBackoff    = 250 * time.Millisecond
+	maxConnectBackoff = 10 * time.Second
+)
+
+// retryConnect calls connect until it succeeds or wait has passed,
+// starting with backoff between attempts and doubling it for each
+// retry. The last error from connect is returned.
+func retryConnect(wait, backoff time.Duration, connect func() error, logger zerolog.Logger) error {
+	deadline := time.Now().Add(wait)
+	for {
+		err := connect()
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return err
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		logger.Warn().Err(err).Msgf("database not available, retrying in %s", backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
 }
 
 // validateDB pings the database and logs the current user and database
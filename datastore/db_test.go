@@ -1,23 +1,67 @@
 package datastore
 
 import (
+	"context"
+	"database/sql"
 	"os"
+	"strconv"
 	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
 
 	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/internal/pgcontainer"
 
 	"github.com/rs/zerolog"
 )
 
+// testDSN returns the PGDatasourceName of the database in the DB_*
+// environment variables, or of a database in a container if DB_HOST
+// is not set. The test is skipped if there is no database. It is
+// datastoretest.ContainerDSN, which cannot be imported here as
+// datastoretest imports datastore.
+func testDSN(t testing.TB) PGDatasourceName {
+	t.Helper()
+
+	host, ok := os.LookupEnv("DB_HOST")
+	if !ok {
+		d, err := pgcontainer.Start(func(ctx context.Context, d pgcontainer.DB) error {
+			db, err := sql.Open("postgres", NewPGDatasourceName(d.Host, d.Name, d.User, d.Password, d.Port).String())
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			return Bootstrap(ctx, NewDefaultDatastore(db), zerolog.Nop())
+		})
+		if errors.Cause(err) == pgcontainer.ErrUnavailable {
+			t.Skipf("skipping db dependent test, no DB_HOST and %v", err)
+		}
+		if err != nil {
+			t.Fatalf("pgcontainer.Start() error = %v", err)
+		}
+		return NewPGDatasourceName(d.Host, d.Name, d.User, d.Password, d.Port)
+	}
+	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+	if err != nil {
+		t.Fatalf("Unable to convert db port %s to int", os.Getenv("DB_PORT"))
+	}
+
+	return NewPGDatasourceName(host, os.Getenv("DB_NAME"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), port)
+}
+
 func Test_NewDB(t *testing.T) {
 	type args struct {
-		pgds PGDatasourceName
+		pgds func(t *testing.T) PGDatasourceName
 		l    zerolog.Logger
 	}
 
 	lgr := logger.NewLogger(os.Stdout, true)
-	dsn := NewPGDatasourceName("localhost", "go_api_basic", "postgres", "", 5432)
-	baddsn := NewPGDatasourceName("badhost", "go_api_basic", "postgres", "", 5432)
+	dsn := func(t *testing.T) PGDatasourceName { return testDSN(t) }
+	baddsn := func(t *testing.T) PGDatasourceName {
+		return NewPGDatasourceName("badhost", "go_api_basic", "postgres", "", 5432)
+	}
 
 	tests := []struct {
 		name    string
@@ -29,7 +73,7 @@ func Test_NewDB(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, cleanup, err := NewDB(tt.args.pgds, tt.args.l)
+			db, cleanup, err := NewDB(tt.args.pgds(t), tt.args.l)
 			defer cleanup()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewDB() error = %v, wantErr %v", err, tt.wantErr)
@@ -44,3 +88,37 @@ func Test_NewDB(t *testing.T) {
 		})
 	}
 }
+
+func Test_retryConnect(t *testing.T) {
+	connErr := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		failures  int
+		wantCalls int
+		wantErr   error
+	}{
+		{"no wait", 0, 1, 1, connErr},
+		{"available", time.Second, 0, 1, nil},
+		{"available after retries", time.Second, 2, 3, nil},
+		{"wait over", 20 * time.Millisecond, 100, 0, connErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			var calls int
+			err := retryConnect(tt.wait, time.Millisecond, func() error {
+				calls++
+				if calls <= tt.failures {
+					return connErr
+				}
+				return nil
+			}, zerolog.Nop())
+			c.Assert(err, qt.Equals, tt.wantErr)
+			if tt.wantCalls > 0 {
+				c.Assert(calls, qt.Equals, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+-- Idempotent DDL for the objects used by the application. It is
+-- embedded in the binary and run by datastore.Bootstrap, so every
+-- statement must be safe to run against a database where some or
+-- all of the objects already exist. Keep in line with
+-- scripts/ddl/demo_ddl.sql.
+
+create schema if not exists demo;
+
+create table if not exists demo.movie
+(
+    movie_id uuid not null
+        constraint movie_pk
+            primary key,
+    extl_id varchar(250) not null,
+    title varchar(1000) not null,
+    rated varchar(10),
+    released date,
+    run_time integer,
+    director varchar(1000),
+    writer varchar(1000),
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+create unique index if not exists movie_extl_id_uindex
+    on demo.movie (extl_id);
+
+-- title suggestions match a prefix of the lower case title, which
+-- text_pattern_ops lets an index be used for whatever the collation
+create index if not exists movie_title_prefix_index
+    on demo.movie (lower(title) text_pattern_ops);
+
+create table if not exists demo.person
+(
+    person_id uuid not null
+        constraint person_pk
+            primary key,
+    extl_id varchar(250) not null,
+    name varchar(1000) not null,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+create unique index if not exists person_extl_id_uindex
+    on demo.person (extl_id);
+
+-- the person who directed the movie. The movie is kept when the
+-- person is deleted.
+alter table demo.movie
+    add column if not exists director_id uuid
+        constraint movie_director_fk
+            references demo.person (person_id)
+            on delete set null;
+
+create index if not exists movie_director_id_index
+    on demo.movie (director_id);
+
+create or replace function demo.create_movie(p_id uuid, p_extl_id character varying, p_title character varying, p_rated character varying, p_released date, p_run_time integer, p_director character varying, p_writer character varying, p_create_client_id uuid, p_create_username character varying)
+    returns TABLE(o_create_timestamp timestamp without time zone, o_update_timestamp timestamp without time zone)
+    language plpgsql
+as
+$$
+DECLARE
+    v_dml_timestamp TIMESTAMP;
+    v_create_timestamp timestamp;
+    v_update_timestamp timestamp;
+BEGIN
+
+    v_dml_timestamp := now() at time zone 'utc';
+
+    INSERT INTO demo.movie (movie_id,
+                            extl_id,
+                            title,
+                            rated,
+                            released,
+                            run_time,
+                            director,
+                            writer,
+                            create_username,
+                            create_timestamp,
+                            update_username,
+                            update_timestamp)
+    VALUES (p_id,
+            p_extl_id,
+            p_title,
+            p_rated,
+            p_released,
+            p_run_time,
+            p_director,
+            p_writer,
+            p_create_username,
+            v_dml_timestamp,
+            p_create_username,
+            v_dml_timestamp)
+    RETURNING create_timestamp, update_timestamp
+        into v_create_timestamp, v_update_timestamp;
+
+    o_create_timestamp := v_create_timestamp;
+    o_update_timestamp := v_update_timestamp;
+
+    RETURN NEXT;
+
+END;
+
+$$;
+
+create table if not exists demo.user_request_count
+(
+    username varchar not null,
+    window_type varchar(10) not null,
+    window_start date not null,
+    request_count bigint not null,
+    constraint user_request_count_pk
+        primary key (username, window_type, window_start)
+);
+
+create table if not exists demo.api_audit
+(
+    audit_id bigserial not null
+        constraint api_audit_pk
+            primary key,
+    request_id varchar(100),
+    method varchar(10) not null,
+    path varchar(2000) not null,
+    username varchar,
+    status integer not null,
+    latency_us bigint not null,
+    request_timestamp timestamp with time zone not null
+);
+
+create index if not exists api_audit_request_timestamp_index
+    on demo.api_audit (request_timestamp);
+
+-- the user agent and client app release of each request, so the
+-- requests of old releases can be tracked. Requests audited before
+-- the columns existed have neither.
+alter table demo.api_audit
+    add column if not exists user_agent varchar(512),
+    add column if not exists client_version varchar(64);
+
+-- the words of a movie's title, director and writer for full text
+-- search, kept up to date by a trigger. Movies added before the
+-- column existed are filled by the movie-search-vector backfill.
+alter table demo.movie
+    add column if not exists search_vector tsvector;
+
+create index if not exists movie_search_vector_index
+    on demo.movie using gin (search_vector);
+
+create or replace function demo.movie_search_vector(p_title character varying, p_director character varying, p_writer character varying)
+    returns tsvector
+    language sql
+    immutable
+as
+$$
+SELECT setweight(to_tsvector('pg_catalog.english', coalesce(p_title, '')), 'A') ||
+       to_tsvector('pg_catalog.english', coalesce(p_director, '') || ' ' || coalesce(p_writer, ''));
+$$;
+
+create or replace function demo.movie_search_vector_trigger()
+    returns trigger
+    language plpgsql
+as
+$$
+BEGIN
+    NEW.search_vector := demo.movie_search_vector(NEW.title, NEW.director, NEW.writer);
+    RETURN NEW;
+END;
+$$;
+
+drop trigger if exists movie_search_vector_trigger on demo.movie;
+
+create trigger movie_search_vector_trigger
+    before insert or update of title, director, writer
+    on demo.movie
+    for each row
+execute procedure demo.movie_search_vector_trigger();
+
+-- the users who have used the API. The email and names are personal
+-- data, so they may be encrypted by the application (see
+-- datastore/pii), in which case the email is found by its hash.
+create table if not exists demo.app_user
+(
+    user_id uuid not null
+        constraint app_user_pk
+            primary key,
+    email_hash varchar(64) not null,
+    email text not null,
+    first_name text,
+    last_name text,
+    full_name text,
+    subject varchar(255),
+    hosted_domain varchar(255),
+    create_timestamp timestamp with time zone not null,
+    update_timestamp timestamp with time zone not null
+);
+
+create unique index if not exists app_user_email_hash_uindex
+    on demo.app_user (email_hash);
+
+-- when the personal data of a user was erased. The email and names
+-- of an erased user are removed and their email hash is replaced, so
+-- they are recorded as a new user if they use the API again.
+alter table demo.app_user
+    add column if not exists erase_timestamp timestamp with time zone;
+
+-- the long-lived sessions of server-side integrations (see
+-- auth.Session). The refresh token is a credential, so it is always
+-- encrypted by the application (see datastore/pii), and the secret
+-- the integration is given access tokens for is only held as a hash.
+create table if not exists demo.integration_session
+(
+    session_id uuid not null
+        constraint integration_session_pk
+            primary key,
+    name varchar(255) not null,
+    username varchar not null,
+    secret_hash varchar(64) not null,
+    refresh_token text not null,
+    create_timestamp timestamp with time zone not null,
+    refresh_timestamp timestamp with time zone
+);
+
+create index if not exists integration_session_username_index
+    on demo.integration_session (lower(username));
+
+-- the export jobs writing large exports to files in object storage
+-- in the background (see export.Job). The file is in the bucket
+-- under object_key, and is downloaded using a signed URL.
+create table if not exists demo.export_job
+(
+    job_id uuid not null
+        constraint export_job_pk
+            primary key,
+    resource varchar(50) not null,
+    format varchar(10) not null,
+    status varchar(10) not null,
+    username varchar not null,
+    object_key varchar not null,
+    row_count integer not null default 0,
+    error_message text,
+    create_timestamp timestamp with time zone not null,
+    finish_timestamp timestamp with time zone
+);
+
+create index if not exists export_job_username_index
+    on demo.export_job (lower(username));
+
+-- the resource each audited request acted on and its JSON state after
+-- the request, so the versions of a resource can be compared (see
+-- audit.Version). Requests audited before the columns existed have
+-- none, and are not versions. The audit records of every tenant are
+-- in the default database, so each has the tenant the request was
+-- made for, null for the default database, and the versions of a
+-- resource are only those of its tenant.
+alter table demo.api_audit
+    add column if not exists resource_type varchar(50),
+    add column if not exists resource_id varchar(100),
+    add column if not exists after_state jsonb,
+    add column if not exists tenant varchar(100);
+
+create index if not exists api_audit_resource_index
+    on demo.api_audit (resource_type, resource_id);
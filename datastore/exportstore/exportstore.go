@@ -0,0 +1,87 @@
+// Package exportstore persists export jobs in the demo.export_job
+// table
+package exportstore
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
+	"github.com/gilcrest/go-api-basic/domain/export"
+)
+
+// NewDefaultStore is an initializer for DefaultStore
+func NewDefaultStore(ds datastore.Datastorer) DefaultStore {
+	return DefaultStore{Datastorer: ds}
+}
+
+// DefaultStore is the database implementation of export.JobStore
+type DefaultStore struct {
+	datastore.Datastorer
+}
+
+// Create adds j
+func (s DefaultStore) Create(ctx context.Context, j export.Job) error {
+	_, err := repo.Exec(ctx, s.DB(),
+		`insert into demo.export_job (job_id, resource, format, status, username,
+		                              object_key, row_count, create_timestamp)
+		 values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		j.ID,
+		j.Resource,
+		j.Format,
+		j.Status,
+		j.Username,
+		j.Key,
+		j.Rows,
+		j.CreateTime)
+
+	return err
+}
+
+// Update records the status, rows, error and finish time of j
+func (s DefaultStore) Update(ctx context.Context, j export.Job) error {
+	return repo.ExecOne(ctx, s.DB(),
+		`update demo.export_job
+		    set status           = $2,
+		        row_count        = $3,
+		        error_message    = nullif($4, ''),
+		        finish_timestamp = $5
+		  where job_id = $1`,
+		j.ID,
+		j.Status,
+		j.Rows,
+		j.Error,
+		datastore.NewNullTime(j.FinishTime))
+}
+
+// FindByID returns the job with id created by username. A job which
+// is not found, or was created by another user, is an errs.NotExist
+// error.
+func (s DefaultStore) FindByID(ctx context.Context, id uuid.UUID, username string) (export.Job, error) {
+	var j export.Job
+	err := repo.Get(ctx, s.DB(),
+		func(row repo.Scanner) error {
+			return row.Scan(
+				&j.ID,
+				&j.Resource,
+				&j.Format,
+				&j.Status,
+				&j.Username,
+				&j.Key,
+				&j.Rows,
+				repo.String(&j.Error),
+				&j.CreateTime,
+				repo.Time(&j.FinishTime))
+		},
+		`select job_id, resource, format, status, username, object_key,
+		        row_count, error_message, create_timestamp, finish_timestamp
+		   from demo.export_job
+		  where job_id = $1
+		    and lower(username) = lower($2)`,
+		id,
+		username)
+
+	return j, err
+}
@@ -0,0 +1,65 @@
+package exportstore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestDefaultStore(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	j := export.NewJob(uuid.New(), "movies", export.FormatNDJSON, "Otto.Maddox@example.com", time.Now().UTC().Truncate(time.Microsecond))
+	s := NewDefaultStore(ds)
+
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.export_job where job_id = $1`, j.ID)
+		if err != nil {
+			t.Errorf("delete export_job error = %v", err)
+		}
+		cleanup()
+	})
+
+	c.Assert(s.Create(ctx, j), qt.IsNil)
+
+	// only the user who created the job, whatever the case of their
+	// email, finds it
+	got, err := s.FindByID(ctx, j.ID, strings.ToLower(j.Username))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Status, qt.Equals, export.StatusPending)
+	c.Assert(got.Key, qt.Equals, j.Key)
+	c.Assert(got.CreateTime.Equal(j.CreateTime), qt.IsTrue)
+	c.Assert(got.FinishTime.IsZero(), qt.IsTrue)
+	_, err = s.FindByID(ctx, j.ID, "someone.else@example.com")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	j.Status = export.StatusFailed
+	j.Rows = 12
+	j.Error = "the export file could not be written"
+	j.FinishTime = time.Now().UTC().Truncate(time.Microsecond)
+	c.Assert(s.Update(ctx, j), qt.IsNil)
+	got, err = s.FindByID(ctx, j.ID, j.Username)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Status, qt.Equals, export.StatusFailed)
+	c.Assert(got.Rows, qt.Equals, 12)
+	c.Assert(got.Error, qt.Equals, j.Error)
+	c.Assert(got.FinishTime.Equal(j.FinishTime), qt.IsTrue)
+
+	err = s.Update(ctx, export.Job{ID: uuid.New(), Status: export.StatusRunning})
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
@@ -0,0 +1,119 @@
+package datastore
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+)
+
+// likeEscaper escapes the characters which have a meaning in a like
+// pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes s so it matches itself in a like pattern which
+// uses the default escape character (\)
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// sqlOperators are the SQL operators for the filter operators which
+// compare to a single value
+var sqlOperators = map[filter.Operator]string{
+	filter.Equal:          "=",
+	filter.NotEqual:       "<>",
+	filter.LessThan:       "<",
+	filter.LessOrEqual:    "<=",
+	filter.GreaterThan:    ">",
+	filter.GreaterOrEqual: ">=",
+}
+
+// FilterSQL returns the filter expression e as a SQL boolean
+// expression to be used in a where clause, along with its values.
+// The values are bind variables numbered from n+1, so n is the number
+// of bind variables before the expression in the statement. columns
+// maps each filter field to its column; only column names (and never
+// values) are added to the statement text. A nil Expr is true.
+func FilterSQL(e *filter.Expr, columns map[string]string, n int) (string, []interface{}, error) {
+	var (
+		b    strings.Builder
+		args []interface{}
+	)
+
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(n+len(args))
+	}
+
+	var write func(e *filter.Expr) error
+	write = func(e *filter.Expr) error {
+		if len(e.Exprs) > 0 {
+			sep := " and "
+			if e.Logic == filter.Or {
+				sep = " or "
+			}
+			b.WriteByte('(')
+			for i, x := range e.Exprs {
+				if i > 0 {
+					b.WriteString(sep)
+				}
+				if err := write(x); err != nil {
+					return err
+				}
+			}
+			b.WriteByte(')')
+			return nil
+		}
+
+		col, ok := columns[e.Field]
+		if !ok {
+			return errs.E(errs.Internal, errors.Errorf("no column for filter field %q", e.Field))
+		}
+
+		switch {
+		case e.IsWildcard():
+			op := " like "
+			if e.Operator == filter.NotEqual {
+				op = " not like "
+			}
+			parts := strings.Split(e.Values[0].(string), filter.Wildcard)
+			for i := range parts {
+				parts[i] = EscapeLike(parts[i])
+			}
+			b.WriteString(col + op + bind(strings.Join(parts, "%")) + ` escape '\'`)
+		case e.Operator == filter.In || e.Operator == filter.NotIn:
+			op := " in ("
+			if e.Operator == filter.NotIn {
+				op = " not in ("
+			}
+			b.WriteString(col + op)
+			for i, v := range e.Values {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(bind(v))
+			}
+			b.WriteByte(')')
+		default:
+			op, ok := sqlOperators[e.Operator]
+			if !ok {
+				return errs.E(errs.Internal, errors.Errorf("unknown filter operator %q", e.Operator))
+			}
+			b.WriteString(col + " " + op + " " + bind(e.Values[0]))
+		}
+
+		return nil
+	}
+
+	if e == nil {
+		return "true", nil, nil
+	}
+	if err := write(e); err != nil {
+		return "", nil, err
+	}
+
+	return b.String(), args, nil
+}
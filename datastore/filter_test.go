@@ -0,0 +1,63 @@
+package datastore
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+)
+
+func TestFilterSQL(t *testing.T) {
+	fields := filter.Fields{"title": filter.String, "rated": filter.String, "run_time": filter.Int}
+	columns := map[string]string{"title": "m.title", "rated": "m.rated", "run_time": "m.run_time"}
+
+	tests := []struct {
+		name     string
+		s        string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"compare", "run_time=ge=90", "m.run_time >= $3", []interface{}{90}},
+		{"and", "rated==R;run_time=gt=90", "(m.rated = $3 and m.run_time > $4)", []interface{}{"R", 90}},
+		{"or in group", "(rated==G,rated!=R);title==x", "((m.rated = $3 or m.rated <> $4) and m.title = $5)", []interface{}{"G", "R", "x"}},
+		{"in", "rated=in=(G,R)", "m.rated in ($3, $4)", []interface{}{"G", "R"}},
+		{"not in", "rated=out=(G)", "m.rated not in ($3)", []interface{}{"G"}},
+		{"wildcard", "title==Rep*_%", `m.title like $3 escape '\'`, []interface{}{`Rep%\_\%`}},
+		{"not wildcard", "title!=*Man", `m.title not like $3 escape '\'`, []interface{}{"%Man"}},
+		{"values are never in the statement", `title=="x' or 1=1 --"`, "m.title = $3", []interface{}{"x' or 1=1 --"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			e, err := filter.Parse(tt.s, fields)
+			c.Assert(err, qt.IsNil)
+
+			gotSQL, gotArgs, err := FilterSQL(e, columns, 2)
+			c.Assert(err, qt.IsNil)
+			c.Assert(gotSQL, qt.Equals, tt.wantSQL)
+			c.Assert(gotArgs, qt.DeepEquals, tt.wantArgs)
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		c := qt.New(t)
+
+		gotSQL, gotArgs, err := FilterSQL(nil, columns, 0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(gotSQL, qt.Equals, "true")
+		c.Assert(gotArgs, qt.IsNil)
+	})
+
+	t.Run("no column", func(t *testing.T) {
+		c := qt.New(t)
+
+		e, err := filter.Parse("run_time==90", fields)
+		c.Assert(err, qt.IsNil)
+
+		_, _, err = FilterSQL(e, map[string]string{}, 0)
+		c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+	})
+}
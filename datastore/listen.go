@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+const (
+	// minListenReconnect and maxListenReconnect bound the wait
+	// before a lost listener connection is re-established
+	minListenReconnect = 10 * time.Second
+	maxListenReconnect = time.Minute
+
+	// listenPingInterval is how often an idle listener connection
+	// is checked
+	listenPingInterval = 90 * time.Second
+)
+
+// NotifyFunc is called by Listen with the payload of each
+// notification. ok is false if the listener connection was lost and
+// re-established, as notifications sent in the meantime are lost.
+type NotifyFunc func(payload string, ok bool)
+
+// Listen listens for notifications on the Postgres channel using a
+// dedicated connection, outside of the sql.DB pool, and calls fn
+// with each notification in order. A lost connection is
+// re-established using the credentials current when Listen was
+// called. Listening stops when the returned function is called.
+func Listen(dsn PGDatasourceName, channel string, logger zerolog.Logger, fn NotifyFunc) (func(), error) {
+	pw, err := dsn.currentPassword()
+	if err != nil {
+		return nil, err
+	}
+	dsn.Password = pw
+
+	l := pq.NewListener(dsn.String(), minListenReconnect, maxListenReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Error().Err(err).Msgf("listener connection error for channel %s", channel)
+		}
+	})
+	if err = l.Listen(channel); err != nil {
+		_ = l.Close()
+		return nil, errs.E(errs.Database, err)
+	}
+	logger.Info().Msgf("listening for notifications on channel %s", channel)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case n := <-l.Notify:
+				// a nil notification is sent once a lost connection
+				// is re-established
+				if n == nil {
+					fn("", false)
+					continue
+				}
+				fn(n.Extra, true)
+			case <-time.After(listenPingInterval):
+				go func() {
+					_ = l.Ping()
+				}()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			_ = l.Close()
+		})
+	}, nil
+}
@@ -0,0 +1,85 @@
+package memstore
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+)
+
+// NewExportJobStore is an initializer for ExportJobStore
+func NewExportJobStore() *ExportJobStore {
+	return &ExportJobStore{byID: make(map[uuid.UUID]export.Job)}
+}
+
+// ExportJobStore holds export jobs in memory. It satisfies the
+// export.JobStore interface and is safe for concurrent use.
+type ExportJobStore struct {
+	mu   sync.RWMutex
+	byID map[uuid.UUID]export.Job
+}
+
+// Create adds j
+func (s *ExportJobStore) Create(ctx context.Context, j export.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[j.ID]; ok {
+		return errs.E(errs.Exist, "export job already exists")
+	}
+	s.byID[j.ID] = j
+
+	return nil
+}
+
+// Update records the status, rows, error and finish time of j
+func (s *ExportJobStore) Update(ctx context.Context, j export.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, ok := s.byID[j.ID]
+	if !ok {
+		return errs.E(errs.NotExist, "No record found for given ID")
+	}
+	found.Status = j.Status
+	found.Rows = j.Rows
+	found.Error = j.Error
+	found.FinishTime = j.FinishTime
+	s.byID[j.ID] = found
+
+	return nil
+}
+
+// FindByID returns the job with id created by username
+func (s *ExportJobStore) FindByID(ctx context.Context, id uuid.UUID, username string) (export.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	j, ok := s.byID[id]
+	if !ok || !strings.EqualFold(j.Username, username) {
+		return export.Job{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return j, nil
+}
+
+// replaceUsername replaces username (whatever its case) with
+// replacement as the user who created the jobs and returns the
+// number of jobs changed
+func (s *ExportJobStore) replaceUsername(username, replacement string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, j := range s.byID {
+		if strings.EqualFold(j.Username, username) {
+			j.Username = replacement
+			s.byID[id] = j
+			n++
+		}
+	}
+	return n
+}
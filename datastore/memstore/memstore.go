@@ -0,0 +1,840 @@
+// Package memstore has in-memory implementations of the datastore
+// interfaces. They are used to run the server without a database
+// (see the -mock flag) and hold nothing once the process exits.
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// NewMovieStore is an initializer for MovieStore, which is loaded
+// with movies
+func NewMovieStore(movies ...*movie.Movie) *MovieStore {
+	ms := &MovieStore{byExtlID: make(map[string]*movie.Movie)}
+	for _, m := range movies {
+		ms.put(m)
+	}
+	return ms
+}
+
+// MovieStore holds movies in memory. It satisfies both the
+// moviestore.Transactor and moviestore.Selector interfaces and is
+// safe for concurrent use. In a dry run (see requestinfo.WithDryRun),
+// writes are checked but not made, as the database rolls them back.
+type MovieStore struct {
+	mu       sync.RWMutex
+	byExtlID map[string]*movie.Movie
+	// order holds the external IDs in the order the movies were
+	// added, so lists are returned in a stable order
+	order []string
+}
+
+// put adds a copy of m, or replaces the movie with the same
+// external ID. The caller must hold the write lock (or have sole
+// access to the store).
+func (ms *MovieStore) put(m *movie.Movie) {
+	if _, ok := ms.byExtlID[m.ExternalID]; !ok {
+		ms.order = append(ms.order, m.ExternalID)
+	}
+	c := *m
+	ms.byExtlID[m.ExternalID] = &c
+}
+
+// Create adds the Movie. An error is returned if a Movie with the
+// same external ID already exists.
+func (ms *MovieStore) Create(ctx context.Context, m *movie.Movie) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.byExtlID[m.ExternalID]; ok {
+		return errs.E(errs.Exist, errors.Errorf("movie %s already exists", m.ExternalID))
+	}
+	if requestinfo.DryRun(ctx) {
+		return nil
+	}
+	ms.put(m)
+
+	return nil
+}
+
+// Update replaces the Movie with the same external ID. The ID,
+// create user and create time of the existing Movie are set to m.
+func (ms *MovieStore) Update(ctx context.Context, m *movie.Movie) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	existing, ok := ms.byExtlID[m.ExternalID]
+	if !ok {
+		return errs.E(errs.NotExist, "No record found for given ID")
+	}
+	m.ID = existing.ID
+	m.CreateUser = existing.CreateUser
+	m.CreateTime = existing.CreateTime
+	if requestinfo.DryRun(ctx) {
+		return nil
+	}
+	ms.put(m)
+
+	return nil
+}
+
+// Delete removes the Movie with the same ID
+func (ms *MovieStore) Delete(ctx context.Context, m *movie.Movie) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for i, extlID := range ms.order {
+		if ms.byExtlID[extlID].ID == m.ID {
+			if requestinfo.DryRun(ctx) {
+				return nil
+			}
+			delete(ms.byExtlID, extlID)
+			ms.order = append(ms.order[:i], ms.order[i+1:]...)
+			return nil
+		}
+	}
+
+	return errs.E(errs.NotExist, "No record found for given ID")
+}
+
+// FindByID returns the Movie with the external ID
+func (ms *MovieStore) FindByID(ctx context.Context, extlID string) (*movie.Movie, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	m, ok := ms.byExtlID[extlID]
+	if !ok {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	c := *m
+
+	return &c, nil
+}
+
+// FindAll returns all movies in the order they were added
+func (ms *MovieStore) FindAll(ctx context.Context) ([]*movie.Movie, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.list(ms.order), nil
+}
+
+// FindAllIter returns a MovieIterator over copies of all movies,
+// in the order they were added
+func (ms *MovieStore) FindAllIter(ctx context.Context) (moviestore.MovieIterator, error) {
+	movies, err := ms.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return moviestore.NewSliceIterator(movies), nil
+}
+
+// FindPage returns up to limit movies matching the filter f (all
+// movies if f is nil), skipping the first offset movies, and the
+// total number of matching movies
+func (ms *MovieStore) FindPage(ctx context.Context, f *filter.Expr, limit, offset int) ([]*movie.Movie, int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	ids := make([]string, 0, len(ms.order))
+	for _, id := range ms.order {
+		if filter.Match(f, ms.byExtlID[id].FilterValue) {
+			ids = append(ids, id)
+		}
+	}
+
+	total := len(ids)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return ms.list(ids[offset:end]), total, nil
+}
+
+// StreamPage calls fn for each movie in the page FindPage returns.
+// The page is copied before fn is called, so fn can be slow (e.g.
+// writing to a client) without holding up writes to the store.
+func (ms *MovieStore) StreamPage(ctx context.Context, f *filter.Expr, limit, offset int, fn moviestore.PageFunc) (int, error) {
+	movies, total, err := ms.FindPage(ctx, f, limit, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range movies {
+		if err = fn(m, total); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// FindByIDs returns the movies with the external IDs, in the order
+// the IDs are given. IDs which are not found are skipped.
+func (ms *MovieStore) FindByIDs(ctx context.Context, extlIDs []string) ([]*movie.Movie, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	found := make([]string, 0, len(extlIDs))
+	for _, id := range extlIDs {
+		if _, ok := ms.byExtlID[id]; ok {
+			found = append(found, id)
+		}
+	}
+
+	return ms.list(found), nil
+}
+
+// Stats returns counts of movies grouped by rating, decade and
+// director, ordered by count descending
+func (ms *MovieStore) Stats(ctx context.Context) (*movie.Stats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var (
+		byRating   = make(map[string]int)
+		byDecade   = make(map[string]int)
+		byDirector = make(map[string]int)
+	)
+	for _, m := range ms.byExtlID {
+		byRating[m.Rated]++
+		byDirector[m.Director]++
+		var d string
+		if !m.Released.IsZero() {
+			d = decade(m.Released)
+		}
+		byDecade[d]++
+	}
+
+	return &movie.Stats{
+		ByRating:   groupCounts(byRating),
+		ByDecade:   groupCounts(byDecade),
+		ByDirector: groupCounts(byDirector),
+	}, nil
+}
+
+// Count returns the number of movies. The count is always exact, as
+// there is no cost to counting movies held in memory.
+func (ms *MovieStore) Count(ctx context.Context, mode movie.CountMode) (movie.Count, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return movie.Count{Count: int64(len(ms.byExtlID))}, nil
+}
+
+// ListVersion returns the latest update time and the count of the
+// movies matching the filter f (all movies if f is nil)
+func (ms *MovieStore) ListVersion(ctx context.Context, f *filter.Expr) (movie.ListVersion, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var v movie.ListVersion
+	for _, m := range ms.byExtlID {
+		if !filter.Match(f, m.FilterValue) {
+			continue
+		}
+		v.Count++
+		if m.UpdateTime.After(v.LastUpdate) {
+			v.LastUpdate = m.UpdateTime
+		}
+	}
+
+	return v, nil
+}
+
+// Suggest returns up to limit movies whose title starts with
+// prefix, ignoring case, ordered by title
+func (ms *MovieStore) Suggest(ctx context.Context, prefix string, limit int) ([]movie.Suggestion, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	prefix = strings.ToLower(prefix)
+	s := make([]movie.Suggestion, 0)
+	for _, m := range ms.byExtlID {
+		if strings.HasPrefix(strings.ToLower(m.Title), prefix) {
+			s = append(s, movie.Suggestion{ExternalID: m.ExternalID, Title: m.Title})
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		ti, tj := strings.ToLower(s[i].Title), strings.ToLower(s[j].Title)
+		if ti != tj {
+			return ti < tj
+		}
+		return s[i].Title < s[j].Title
+	})
+	if len(s) > limit {
+		s = s[:limit]
+	}
+
+	return s, nil
+}
+
+// list returns copies of the movies with the external IDs. The
+// caller must hold the read lock.
+func (ms *MovieStore) list(extlIDs []string) []*movie.Movie {
+	s := make([]*movie.Movie, 0, len(extlIDs))
+	for _, id := range extlIDs {
+		c := *ms.byExtlID[id]
+		s = append(s, &c)
+	}
+	return s
+}
+
+// decade returns the decade of t formatted as the first year of the
+// decade followed by an "s", e.g. 1980s
+func decade(t time.Time) string {
+	return fmt.Sprintf("%d0s", t.Year()/10)
+}
+
+// groupCounts returns the counts ordered by count descending, then
+// value
+func groupCounts(counts map[string]int) []movie.GroupCount {
+	s := make([]movie.GroupCount, 0, len(counts))
+	for v, n := range counts {
+		s = append(s, movie.GroupCount{Value: v, Count: n})
+	}
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Count != s[j].Count {
+			return s[i].Count > s[j].Count
+		}
+		return s[i].Value < s[j].Value
+	})
+	return s
+}
+
+// NewPersonStore is an initializer for PersonStore. Movies are set
+// as directed by a person using AddMovie and are found in movies.
+func NewPersonStore(movies moviestore.Selector) *PersonStore {
+	return &PersonStore{
+		byExtlID: make(map[string]*person.Person),
+		directed: make(map[uuid.UUID][]string),
+		movies:   movies,
+	}
+}
+
+// PersonStore holds people in memory. It satisfies both the
+// personstore.Transactor and personstore.Selector interfaces and is
+// safe for concurrent use. Like MovieStore, it makes no writes in a
+// dry run.
+type PersonStore struct {
+	mu       sync.RWMutex
+	byExtlID map[string]*person.Person
+	// order holds the external IDs in the order the people were
+	// added, so lists are returned in a stable order
+	order []string
+	// directed holds the external IDs of the movies directed by
+	// each person, by person ID. A movie has one director, so an
+	// external ID is in at most one list.
+	directed map[uuid.UUID][]string
+	movies   moviestore.Selector
+}
+
+// Create adds the Person. An error is returned if a Person with the
+// same external ID already exists.
+func (ps *PersonStore) Create(ctx context.Context, p *person.Person) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if _, ok := ps.byExtlID[p.ExternalID]; ok {
+		return errs.E(errs.Exist, errors.Errorf("person %s already exists", p.ExternalID))
+	}
+	if requestinfo.DryRun(ctx) {
+		return nil
+	}
+	c := *p
+	ps.byExtlID[p.ExternalID] = &c
+	ps.order = append(ps.order, p.ExternalID)
+
+	return nil
+}
+
+// Update replaces the Person with the same external ID. The ID,
+// create user and create time of the existing Person are set to p.
+func (ps *PersonStore) Update(ctx context.Context, p *person.Person) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	existing, ok := ps.byExtlID[p.ExternalID]
+	if !ok {
+		return errs.E(errs.NotExist, "No record found for given ID")
+	}
+	p.ID = existing.ID
+	p.CreateUser = existing.CreateUser
+	p.CreateTime = existing.CreateTime
+	if requestinfo.DryRun(ctx) {
+		return nil
+	}
+	c := *p
+	ps.byExtlID[p.ExternalID] = &c
+
+	return nil
+}
+
+// Delete removes the Person with the same ID. The movies the Person
+// directed are kept, as the database keeps them with no director.
+func (ps *PersonStore) Delete(ctx context.Context, p *person.Person) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for i, extlID := range ps.order {
+		if ps.byExtlID[extlID].ID == p.ID {
+			if requestinfo.DryRun(ctx) {
+				return nil
+			}
+			delete(ps.byExtlID, extlID)
+			delete(ps.directed, p.ID)
+			ps.order = append(ps.order[:i], ps.order[i+1:]...)
+			return nil
+		}
+	}
+
+	return errs.E(errs.NotExist, "No record found for given ID")
+}
+
+// AddMovie sets the Person as the director of the Movie with the
+// external ID movieExtlID, replacing any director already set
+func (ps *PersonStore) AddMovie(ctx context.Context, p *person.Person, movieExtlID string) error {
+	_, err := ps.movies.FindByID(ctx, movieExtlID)
+	if errs.KindIs(errs.NotExist, err) {
+		return errs.E(errs.NotExist, "No movie found for given ID")
+	} else if err != nil {
+		return err
+	}
+	if requestinfo.DryRun(ctx) {
+		return nil
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for id, extlIDs := range ps.directed {
+		for i, extlID := range extlIDs {
+			if extlID == movieExtlID {
+				ps.directed[id] = append(extlIDs[:i:i], extlIDs[i+1:]...)
+				break
+			}
+		}
+	}
+	ps.directed[p.ID] = append(ps.directed[p.ID], movieExtlID)
+
+	return nil
+}
+
+// FindByID returns the Person with the external ID
+func (ps *PersonStore) FindByID(ctx context.Context, extlID string) (*person.Person, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	p, ok := ps.byExtlID[extlID]
+	if !ok {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	c := *p
+
+	return &c, nil
+}
+
+// FindAll returns all people ordered by name
+func (ps *PersonStore) FindAll(ctx context.Context) ([]*person.Person, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	s := make([]*person.Person, 0, len(ps.order))
+	for _, extlID := range ps.order {
+		c := *ps.byExtlID[extlID]
+		s = append(s, &c)
+	}
+	sort.SliceStable(s, func(i, j int) bool { return s[i].Name < s[j].Name })
+
+	return s, nil
+}
+
+// FindMovies returns the Movies directed by the Person, ordered by
+// title. Movies which have since been deleted are skipped.
+func (ps *PersonStore) FindMovies(ctx context.Context, p *person.Person) ([]*movie.Movie, error) {
+	ps.mu.RLock()
+	extlIDs := append([]string(nil), ps.directed[p.ID]...)
+	ps.mu.RUnlock()
+
+	movies, err := ps.movies.FindByIDs(ctx, extlIDs)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(movies, func(i, j int) bool { return movies[i].Title < movies[j].Title })
+
+	return movies, nil
+}
+
+// Pinger satisfies the pingstore.Pinger interface. There is no
+// database, so PingDB always succeeds.
+type Pinger struct{}
+
+// PingDB returns nil
+func (Pinger) PingDB(ctx context.Context) error {
+	return nil
+}
+
+// NewCounter is an initializer for Counter
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[counterKey]int64)}
+}
+
+// Counter counts requests by user in memory. It satisfies the
+// quota.Counter interface and is safe for concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[counterKey]int64
+}
+
+// counterKey is the user and window start a count is kept for
+type counterKey struct {
+	username string
+	window   quota.Window
+	start    time.Time
+}
+
+// Increment adds one request for the user to the windows holding
+// at and returns the new counts
+func (c *Counter) Increment(ctx context.Context, username string, at time.Time) (quota.Counts, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[counterKey{username, quota.Day, quota.Day.Start(at)}]++
+	c.counts[counterKey{username, quota.Month, quota.Month.Start(at)}]++
+
+	return c.get(username, at), nil
+}
+
+// Counts returns the counts for the user for the windows holding at
+func (c *Counter) Counts(ctx context.Context, username string, at time.Time) (quota.Counts, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(username, at), nil
+}
+
+// get returns the counts for the user. The caller must hold the lock.
+func (c *Counter) get(username string, at time.Time) quota.Counts {
+	return quota.Counts{
+		Daily:   c.counts[counterKey{username, quota.Day, quota.Day.Start(at)}],
+		Monthly: c.counts[counterKey{username, quota.Month, quota.Month.Start(at)}],
+	}
+}
+
+// NewAuditStore is an initializer for AuditStore
+func NewAuditStore() *AuditStore {
+	return &AuditStore{}
+}
+
+// AuditStore holds audit records in memory. It satisfies the
+// audit.Store interface and is safe for concurrent use.
+type AuditStore struct {
+	mu      sync.RWMutex
+	records []audit.Record
+}
+
+// Insert adds the records
+func (s *AuditStore) Insert(ctx context.Context, records []audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, records...)
+
+	return nil
+}
+
+// defaultAuditLimit is the number of records returned by Find when
+// the Filter has no Limit, matching the database Store
+const defaultAuditLimit = 100
+
+// Find returns the records matching the Filter, most recent first
+func (s *AuditStore) Find(ctx context.Context, f audit.Filter) ([]audit.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	found := make([]audit.Record, 0)
+	for i := len(s.records) - 1; i >= 0 && len(found) < limit; i-- {
+		r := s.records[i]
+		switch {
+		case f.Username != "" && r.Username != f.Username:
+			continue
+		case f.Method != "" && r.Method != f.Method:
+			continue
+		case f.PathPrefix != "" && !strings.HasPrefix(r.Path, f.PathPrefix):
+			continue
+		case !f.Since.IsZero() && r.Timestamp.Before(f.Since):
+			continue
+		case !f.Until.IsZero() && !r.Timestamp.Before(f.Until):
+			continue
+		}
+		found = append(found, r)
+	}
+
+	return found, nil
+}
+
+// Versions returns the versions of the resource of type typ with
+// the external ID id, oldest first, see audit.Store
+func (s *AuditStore) Versions(ctx context.Context, typ, id string) ([]audit.Version, error) {
+	tenant, _ := requestinfo.TenantFromContext(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := make([]audit.Version, 0)
+	for _, r := range s.records {
+		if r.Tenant != tenant || r.ResourceType != typ || r.ResourceID != id || r.Status >= http.StatusBadRequest || len(r.State) == 0 {
+			continue
+		}
+		versions = append(versions, audit.Version{
+			Number:    len(versions) + 1,
+			RequestID: r.RequestID,
+			Timestamp: r.Timestamp,
+			State:     r.State,
+		})
+	}
+
+	return versions, nil
+}
+
+// NewUserStore is an initializer for UserStore
+func NewUserStore(clk clock.Clock) *UserStore {
+	return &UserStore{byEmail: make(map[string]*user.Account), clock: clk}
+}
+
+// UserStore holds the users recorded in memory. It satisfies the
+// user.Recorder interface and is safe for concurrent use.
+type UserStore struct {
+	mu      sync.RWMutex
+	byEmail map[string]*user.Account
+	// clock tells the time users are recorded at
+	clock clock.Clock
+}
+
+// Record adds u, or updates the user with the same email
+func (s *UserStore) Record(ctx context.Context, u user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now().UTC()
+	key := strings.ToLower(u.Email)
+	if a, ok := s.byEmail[key]; ok {
+		a.User = u
+		a.UpdateTime = now
+		return nil
+	}
+	s.byEmail[key] = &user.Account{ID: uuid.New(), User: u, CreateTime: now, UpdateTime: now}
+
+	return nil
+}
+
+// FindByID returns the user with id
+func (s *UserStore) FindByID(ctx context.Context, id uuid.UUID) (user.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, a := range s.byEmail {
+		if a.ID == id {
+			return *a, nil
+		}
+	}
+	return user.Account{}, errs.E(errs.NotExist, "No record found for given ID")
+}
+
+// FindByEmail returns the user with email
+func (s *UserStore) FindByEmail(ctx context.Context, email string) (user.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.byEmail[strings.ToLower(email)]
+	if !ok {
+		return user.Account{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return *a, nil
+}
+
+// erase removes the personal data of the user with id and returns
+// their email as it was before. If the user was already erased, the
+// email is empty.
+func (s *UserStore) erase(id uuid.UUID, at time.Time) (email string, a user.Account, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, acct := range s.byEmail {
+		if acct.ID != id {
+			continue
+		}
+		if !acct.EraseTime.IsZero() {
+			return "", *acct, nil
+		}
+		email = acct.User.Email
+		acct.User = user.User{}
+		acct.UpdateTime = at
+		acct.EraseTime = at
+		// keep the user under a key no email can have, so they are
+		// recorded anew if they return
+		delete(s.byEmail, key)
+		s.byEmail["erased:"+id.String()] = acct
+		return email, *acct, nil
+	}
+	return "", user.Account{}, errs.E(errs.NotExist, "No record found for given ID")
+}
+
+// NewUserEraser is an initializer for UserEraser
+func NewUserEraser(users *UserStore, movies *MovieStore, people *PersonStore, audits *AuditStore, counter *Counter, sessions *SessionStore, exportJobs *ExportJobStore, clk clock.Clock) *UserEraser {
+	return &UserEraser{users: users, movies: movies, people: people, audits: audits, counter: counter, sessions: sessions, exportJobs: exportJobs, clock: clk}
+}
+
+// UserEraser erases the personal data of users from the in-memory
+// stores. It satisfies the user.Eraser interface. Unlike the
+// database store, an erasure is not atomic across the stores.
+type UserEraser struct {
+	users      *UserStore
+	movies     *MovieStore
+	people     *PersonStore
+	audits     *AuditStore
+	counter    *Counter
+	sessions   *SessionStore
+	exportJobs *ExportJobStore
+	// clock tells the time users are erased at
+	clock clock.Clock
+}
+
+// Erase erases the personal data of the user with id, see user.Eraser
+func (e *UserEraser) Erase(ctx context.Context, id uuid.UUID) (user.ErasureReport, error) {
+	email, a, err := e.users.erase(id, e.clock.Now().UTC())
+	if err != nil {
+		return user.ErasureReport{}, err
+	}
+
+	rpt := user.ErasureReport{UserID: id, Tombstone: user.TombstoneUsername, EraseTime: a.EraseTime}
+	if email == "" {
+		return rpt, nil
+	}
+
+	tombstone := user.User{Email: user.TombstoneUsername}
+	rpt.AuditRecords = e.audits.replaceUsername(email, user.TombstoneUsername)
+	rpt.Movies = e.movies.replaceUser(email, tombstone)
+	rpt.People = e.people.replaceUser(email, tombstone)
+	rpt.RequestCounts = e.counter.deleteUser(email)
+	rpt.Sessions = e.sessions.deleteUser(email)
+	rpt.ExportJobs = e.exportJobs.replaceUsername(email, user.TombstoneUsername)
+
+	return rpt, nil
+}
+
+// replaceUsername replaces username (whatever its case) with
+// replacement in the records, as the user who made the request or a
+// user in the state of the resource (see audit.ReplaceUser), and
+// returns the number of records changed
+func (s *AuditStore) replaceUsername(username, replacement string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for i := range s.records {
+		r := &s.records[i]
+		state, stateChanged := audit.ReplaceUser(r.State, username, replacement)
+		userChanged := strings.EqualFold(r.Username, username)
+		if !userChanged && !stateChanged {
+			continue
+		}
+		if userChanged {
+			r.Username = replacement
+		}
+		r.State = state
+		n++
+	}
+	return n
+}
+
+// replaceUser replaces the create and update user with email
+// (whatever its case) with replacement and returns the number of
+// movies changed
+func (ms *MovieStore) replaceUser(email string, replacement user.User) int64 {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var n int64
+	for _, m := range ms.byExtlID {
+		if replaceUser(&m.CreateUser, &m.UpdateUser, email, replacement) {
+			n++
+		}
+	}
+	return n
+}
+
+// replaceUser replaces the create and update user with email
+// (whatever its case) with replacement and returns the number of
+// people changed
+func (ps *PersonStore) replaceUser(email string, replacement user.User) int64 {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var n int64
+	for _, p := range ps.byExtlID {
+		if replaceUser(&p.CreateUser, &p.UpdateUser, email, replacement) {
+			n++
+		}
+	}
+	return n
+}
+
+// replaceUser sets create and/or update to replacement if they have
+// email and reports whether either was set
+func replaceUser(create, update *user.User, email string, replacement user.User) bool {
+	var replaced bool
+	for _, u := range []*user.User{create, update} {
+		if strings.EqualFold(u.Email, email) {
+			*u = replacement
+			replaced = true
+		}
+	}
+	return replaced
+}
+
+// deleteUser deletes the counts of username (whatever its case) and
+// returns the number deleted
+func (c *Counter) deleteUser(username string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	for k := range c.counts {
+		if strings.EqualFold(k.username, username) {
+			delete(c.counts, k)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,615 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// newMovie returns a Movie with the external ID, rating, release
+// date and director
+func newMovie(t *testing.T, extlID, rated, released, director string) *movie.Movie {
+	t.Helper()
+
+	m, err := movie.NewMovie(uuid.New(), extlID, usertest.NewUser(t), clocktest.NewMockClock(t))
+	if err != nil {
+		t.Fatalf("movie.NewMovie() error = %v", err)
+	}
+	m, err = m.SetReleased(released)
+	if err != nil {
+		t.Fatalf("SetReleased() error = %v", err)
+	}
+	m.SetTitle(extlID).SetRated(rated).SetDirector(director)
+
+	return m
+}
+
+func TestMovieStore(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox")
+	m2 := newMovie(t, "m2", "R", "1986-10-03T00:00:00Z", "Alex Cox")
+	ms := NewMovieStore(m1, m2)
+
+	// Create
+	m3 := newMovie(t, "m3", "PG", "1982-06-25T00:00:00Z", "Ridley Scott")
+	c.Assert(ms.Create(ctx, m3), qt.IsNil)
+	c.Assert(errs.KindIs(errs.Exist, ms.Create(ctx, m3)), qt.IsTrue)
+
+	// the store holds a copy, so changes to m3 are not seen
+	m3.SetTitle("changed")
+	got, err := ms.FindByID(ctx, "m3")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Title, qt.Equals, "m3")
+
+	// Update keeps the ID and create details of the stored movie
+	u := newMovie(t, "m1", "PG-13", "1984-03-02T00:00:00Z", "Alex Cox")
+	c.Assert(ms.Update(ctx, u), qt.IsNil)
+	c.Assert(u.ID, qt.Equals, m1.ID)
+	got, err = ms.FindByID(ctx, "m1")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Rated, qt.Equals, "PG-13")
+	c.Assert(errs.KindIs(errs.NotExist, ms.Update(ctx, newMovie(t, "nope", "R", "1984-03-02T00:00:00Z", "x"))), qt.IsTrue)
+
+	c.Assert(ms.Create(ctx, newMovie(t, "m4", "R", "1982-06-25T00:00:00Z", "John Carpenter")), qt.IsNil)
+
+	// FindAll returns movies in the order they were added
+	all, err := ms.FindAll(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(extlIDs(all), qt.DeepEquals, []string{"m1", "m2", "m3", "m4"})
+
+	// Delete
+	c.Assert(ms.Delete(ctx, m3), qt.IsNil)
+	c.Assert(errs.KindIs(errs.NotExist, ms.Delete(ctx, m3)), qt.IsTrue)
+	_, err = ms.FindByID(ctx, "m3")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	// FindByIDs keeps the order of the IDs and skips those not found
+	byIDs, err := ms.FindByIDs(ctx, []string{"m4", "m3", "m1"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(extlIDs(byIDs), qt.DeepEquals, []string{"m4", "m1"})
+}
+
+func TestMovieStore_dryRun(t *testing.T) {
+	c := qt.New(t)
+	ctx := requestinfo.WithDryRun(context.Background())
+
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox")
+	ms := NewMovieStore(m1)
+
+	// writes are checked, but not made
+	c.Assert(ms.Create(ctx, newMovie(t, "m2", "PG", "1982-06-25T00:00:00Z", "Ridley Scott")), qt.IsNil)
+	c.Assert(errs.KindIs(errs.Exist, ms.Create(ctx, m1)), qt.IsTrue)
+	u := newMovie(t, "m1", "PG-13", "1984-03-02T00:00:00Z", "Alex Cox")
+	c.Assert(ms.Update(ctx, u), qt.IsNil)
+	c.Assert(u.ID, qt.Equals, m1.ID)
+	c.Assert(errs.KindIs(errs.NotExist, ms.Update(ctx, newMovie(t, "nope", "R", "1984-03-02T00:00:00Z", "x"))), qt.IsTrue)
+	c.Assert(ms.Delete(ctx, m1), qt.IsNil)
+
+	all, err := ms.FindAll(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(extlIDs(all), qt.DeepEquals, []string{"m1"})
+	c.Assert(all[0].Rated, qt.Equals, "R")
+
+	ps := NewPersonStore(ms)
+	p, err := person.NewPerson(uuid.New(), "p1", usertest.NewUser(t), clocktest.NewMockClock(t))
+	c.Assert(err, qt.IsNil)
+	c.Assert(ps.Create(ctx, p.SetName("Alex Cox")), qt.IsNil)
+	c.Assert(ps.AddMovie(ctx, p, "m1"), qt.IsNil)
+	c.Assert(errs.KindIs(errs.NotExist, ps.AddMovie(ctx, p, "nope")), qt.IsTrue)
+	_, err = ps.FindByID(ctx, "p1")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestPersonStore(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox")
+	m2 := newMovie(t, "m2", "R", "1986-10-03T00:00:00Z", "Alex Cox")
+	movies := NewMovieStore(m1, m2)
+	ps := NewPersonStore(movies)
+
+	newPerson := func(extlID, name string) *person.Person {
+		p, err := person.NewPerson(uuid.New(), extlID, usertest.NewUser(t), clocktest.NewMockClock(t))
+		c.Assert(err, qt.IsNil)
+		return p.SetName(name)
+	}
+
+	// Create
+	cox := newPerson("p1", "Alex Cox")
+	scott := newPerson("p2", "Ridley Scott")
+	c.Assert(ps.Create(ctx, scott), qt.IsNil)
+	c.Assert(ps.Create(ctx, cox), qt.IsNil)
+	c.Assert(errs.KindIs(errs.Exist, ps.Create(ctx, cox)), qt.IsTrue)
+
+	// FindAll is ordered by name
+	all, err := ps.FindAll(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(all, qt.HasLen, 2)
+	c.Assert(all[0].ExternalID, qt.Equals, "p1")
+
+	// Update keeps the ID of the stored person
+	u := newPerson("p1", "Alex Cox Jr.")
+	c.Assert(ps.Update(ctx, u), qt.IsNil)
+	c.Assert(u.ID, qt.Equals, cox.ID)
+	got, err := ps.FindByID(ctx, "p1")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Name, qt.Equals, "Alex Cox Jr.")
+
+	// AddMovie links the movies, which are found ordered by title
+	c.Assert(ps.AddMovie(ctx, cox, "m2"), qt.IsNil)
+	c.Assert(ps.AddMovie(ctx, cox, "m1"), qt.IsNil)
+	c.Assert(errs.KindIs(errs.NotExist, ps.AddMovie(ctx, cox, "nope")), qt.IsTrue)
+	directed, err := ps.FindMovies(ctx, cox)
+	c.Assert(err, qt.IsNil)
+	c.Assert(extlIDs(directed), qt.DeepEquals, []string{"m1", "m2"})
+
+	// a movie has one director
+	c.Assert(ps.AddMovie(ctx, scott, "m1"), qt.IsNil)
+	directed, err = ps.FindMovies(ctx, cox)
+	c.Assert(err, qt.IsNil)
+	c.Assert(extlIDs(directed), qt.DeepEquals, []string{"m2"})
+
+	// deleted movies are skipped
+	c.Assert(movies.Delete(ctx, m2), qt.IsNil)
+	directed, err = ps.FindMovies(ctx, cox)
+	c.Assert(err, qt.IsNil)
+	c.Assert(directed, qt.HasLen, 0)
+
+	// Delete
+	c.Assert(ps.Delete(ctx, cox), qt.IsNil)
+	c.Assert(errs.KindIs(errs.NotExist, ps.Delete(ctx, cox)), qt.IsTrue)
+	_, err = ps.FindByID(ctx, "p1")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestMovieStore_FindPage(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMovieStore(
+		newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "a"),
+		newMovie(t, "m2", "R", "1984-03-02T00:00:00Z", "a"),
+		newMovie(t, "m3", "R", "1984-03-02T00:00:00Z", "a"),
+	)
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   []string
+	}{
+		{"first page", 2, 0, []string{"m1", "m2"}},
+		{"last page", 2, 2, []string{"m3"}},
+		{"past the end", 2, 5, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			got, total, err := ms.FindPage(ctx, nil, tt.limit, tt.offset)
+			c.Assert(err, qt.IsNil)
+			c.Assert(total, qt.Equals, 3)
+			c.Assert(extlIDs(got), qt.DeepEquals, tt.want)
+		})
+	}
+
+	t.Run("filtered", func(t *testing.T) {
+		c := qt.New(t)
+
+		f, err := filter.Parse("title=out=(m2)", movie.FilterFields)
+		c.Assert(err, qt.IsNil)
+
+		got, total, err := ms.FindPage(ctx, f, 1, 1)
+		c.Assert(err, qt.IsNil)
+		c.Assert(total, qt.Equals, 2)
+		c.Assert(extlIDs(got), qt.DeepEquals, []string{"m3"})
+	})
+}
+
+func TestMovieStore_StreamPage(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	ms := NewMovieStore(
+		newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "a"),
+		newMovie(t, "m2", "R", "1984-03-02T00:00:00Z", "a"),
+		newMovie(t, "m3", "R", "1984-03-02T00:00:00Z", "a"),
+	)
+
+	var got []string
+	total, err := ms.StreamPage(ctx, nil, 2, 1, func(m *movie.Movie, total int) error {
+		c.Assert(total, qt.Equals, 3)
+		got = append(got, m.ExternalID)
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(total, qt.Equals, 3)
+	c.Assert(got, qt.DeepEquals, []string{"m2", "m3"})
+
+	// an error from fn stops the stream and is returned
+	stop := errors.New("stop")
+	var n int
+	_, err = ms.StreamPage(ctx, nil, 3, 0, func(m *movie.Movie, total int) error {
+		n++
+		return stop
+	})
+	c.Assert(err, qt.Equals, stop)
+	c.Assert(n, qt.Equals, 1)
+}
+
+func TestMovieStore_FindAllIter(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	ms := NewMovieStore(
+		newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "a"),
+		newMovie(t, "m2", "R", "1984-03-02T00:00:00Z", "a"),
+	)
+
+	it, err := ms.FindAllIter(ctx)
+	c.Assert(err, qt.IsNil)
+
+	// movies added after the iterator is returned are not seen
+	c.Assert(ms.Create(ctx, newMovie(t, "m3", "R", "1984-03-02T00:00:00Z", "a")), qt.IsNil)
+
+	var got []string
+	for it.Next() {
+		got = append(got, it.Movie().ExternalID)
+	}
+	c.Assert(it.Err(), qt.IsNil)
+	c.Assert(it.Close(), qt.IsNil)
+	c.Assert(got, qt.DeepEquals, []string{"m1", "m2"})
+}
+
+func TestMovieStore_Stats(t *testing.T) {
+	c := qt.New(t)
+	ms := NewMovieStore(
+		newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox"),
+		newMovie(t, "m2", "R", "1986-10-03T00:00:00Z", "Alex Cox"),
+		newMovie(t, "m3", "PG", "1979-06-25T00:00:00Z", "Ridley Scott"),
+	)
+
+	got, err := ms.Stats(context.Background())
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, &movie.Stats{
+		ByRating:   []movie.GroupCount{{Value: "R", Count: 2}, {Value: "PG", Count: 1}},
+		ByDecade:   []movie.GroupCount{{Value: "1980s", Count: 2}, {Value: "1970s", Count: 1}},
+		ByDirector: []movie.GroupCount{{Value: "Alex Cox", Count: 2}, {Value: "Ridley Scott", Count: 1}},
+	})
+}
+
+func TestMovieStore_ListVersion(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "a")
+	m2 := newMovie(t, "m2", "PG", "1984-03-02T00:00:00Z", "a")
+	m2.UpdateTime = m1.UpdateTime.Add(time.Hour)
+	ms := NewMovieStore(m1, m2)
+
+	got, err := ms.ListVersion(ctx, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, movie.ListVersion{LastUpdate: m2.UpdateTime, Count: 2})
+
+	f, err := filter.Parse("rated==R", movie.FilterFields)
+	c.Assert(err, qt.IsNil)
+	got, err = ms.ListVersion(ctx, f)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, movie.ListVersion{LastUpdate: m1.UpdateTime, Count: 1})
+
+	// deleting a movie changes the version
+	c.Assert(ms.Delete(ctx, m1), qt.IsNil)
+	got, err = ms.ListVersion(ctx, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, movie.ListVersion{LastUpdate: m2.UpdateTime, Count: 1})
+}
+
+func TestMovieStore_Suggest(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox")
+	m1.SetTitle("Repo Man")
+	m2 := newMovie(t, "m2", "R", "2008-06-20T00:00:00Z", "Darren Lynn Bousman")
+	m2.SetTitle("repo! The Genetic Opera")
+	m3 := newMovie(t, "m3", "R", "1982-06-25T00:00:00Z", "Ridley Scott")
+	m3.SetTitle("Blade Runner")
+	ms := NewMovieStore(m1, m2, m3)
+
+	got, err := ms.Suggest(ctx, "REP", 10)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, []movie.Suggestion{
+		{ExternalID: "m1", Title: "Repo Man"},
+		{ExternalID: "m2", Title: "repo! The Genetic Opera"},
+	})
+
+	// the limit caps the number of suggestions
+	got, err = ms.Suggest(ctx, "rep", 1)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+
+	got, err = ms.Suggest(ctx, "xyz", 10)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+}
+
+func TestCounter(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	counter := NewCounter()
+	at := time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	got, err := counter.Counts(ctx, "otto", at)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{})
+
+	for i := int64(1); i <= 2; i++ {
+		got, err = counter.Increment(ctx, "otto", at)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.Equals, quota.Counts{Daily: i, Monthly: i})
+	}
+
+	// the next day starts a new day window in the same month
+	got, err = counter.Increment(ctx, "otto", at.AddDate(0, 0, 1))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{Daily: 1, Monthly: 3})
+
+	// other users are counted separately
+	got, err = counter.Counts(ctx, "repo", at)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{})
+}
+
+func TestAuditStore_Find(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	s := NewAuditStore()
+	err := s.Insert(ctx, []audit.Record{
+		{RequestID: "1", Method: "POST", Path: "/api/v1/movies", Username: "otto", Timestamp: start},
+		{RequestID: "2", Method: "PUT", Path: "/api/v1/movies/a", Username: "repo", Timestamp: start.Add(time.Minute)},
+		{RequestID: "3", Method: "DELETE", Path: "/api/v1/movies/a", Username: "otto", Timestamp: start.Add(2 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter audit.Filter
+		want   []string
+	}{
+		{"all, most recent first", audit.Filter{}, []string{"3", "2", "1"}},
+		{"username", audit.Filter{Username: "otto"}, []string{"3", "1"}},
+		{"method", audit.Filter{Method: "PUT"}, []string{"2"}},
+		{"path prefix", audit.Filter{PathPrefix: "/api/v1/movies/"}, []string{"3", "2"}},
+		{"since until", audit.Filter{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)}, []string{"2"}},
+		{"limit", audit.Filter{Limit: 1}, []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			got, err := s.Find(ctx, tt.filter)
+			c.Assert(err, qt.IsNil)
+			ids := make([]string, 0, len(got))
+			for _, r := range got {
+				ids = append(ids, r.RequestID)
+			}
+			c.Assert(ids, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestAuditStore_Versions(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	start := time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	s := NewAuditStore()
+	err := s.Insert(ctx, []audit.Record{
+		{RequestID: "1", Method: "POST", Status: 200, Timestamp: start, ResourceType: "movies", ResourceID: "a", State: json.RawMessage(`{"Title":"Repo Man"}`)},
+		{RequestID: "2", Method: "POST", Status: 200, Timestamp: start, ResourceType: "movies", ResourceID: "b", State: json.RawMessage(`{"Title":"Alien"}`)},
+		{RequestID: "3", Method: "PUT", Status: 422, Timestamp: start.Add(time.Minute), ResourceType: "movies", ResourceID: "a", State: json.RawMessage(`{"Title":""}`)},
+		{RequestID: "4", Method: "PUT", Status: 200, Timestamp: start.Add(2 * time.Minute), ResourceType: "movies", ResourceID: "a", State: json.RawMessage(`{"Title":"Repo Man!"}`)},
+		{RequestID: "5", Method: "DELETE", Status: 200, Timestamp: start.Add(3 * time.Minute), ResourceType: "movies", ResourceID: "a"},
+		{RequestID: "6", Method: "POST", Status: 200, Timestamp: start, ResourceType: "movies", ResourceID: "a", State: json.RawMessage(`{"Title":"Alien"}`), Tenant: "acme"},
+	})
+	c.Assert(err, qt.IsNil)
+
+	// the failed update and the delete are not versions
+	got, err := s.Versions(ctx, "movies", "a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, []audit.Version{
+		{Number: 1, RequestID: "1", Timestamp: start, State: json.RawMessage(`{"Title":"Repo Man"}`)},
+		{Number: 2, RequestID: "4", Timestamp: start.Add(2 * time.Minute), State: json.RawMessage(`{"Title":"Repo Man!"}`)},
+	})
+
+	got, err = s.Versions(ctx, "people", "a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+
+	// a tenant only finds the versions of its own resources
+	got, err = s.Versions(requestinfo.WithTenant(ctx, "acme"), "movies", "a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, []audit.Version{
+		{Number: 1, RequestID: "6", Timestamp: start, State: json.RawMessage(`{"Title":"Alien"}`)},
+	})
+
+	got, err = s.Versions(requestinfo.WithTenant(ctx, "globex"), "movies", "a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+}
+
+// extlIDs returns the external IDs of the movies
+func extlIDs(movies []*movie.Movie) []string {
+	s := make([]string, 0, len(movies))
+	for _, m := range movies {
+		s = append(s, m.ExternalID)
+	}
+	return s
+}
+
+func TestUserStore(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	s := NewUserStore(clocktest.NewMockClock(t))
+
+	u := usertest.NewUser(t)
+	c.Assert(s.Record(ctx, u), qt.IsNil)
+
+	got, err := s.FindByEmail(ctx, "Otto.Maddox711@gmail.com")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.User, qt.DeepEquals, u)
+	c.Assert(got.CreateTime, qt.Equals, clocktest.Time)
+	c.Assert(got.UpdateTime, qt.Equals, clocktest.Time)
+
+	// recording the user again updates them
+	u.LastName = "Parker"
+	c.Assert(s.Record(ctx, u), qt.IsNil)
+	got2, err := s.FindByID(ctx, got.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got2.User.LastName, qt.Equals, "Parker")
+
+	_, err = s.FindByID(ctx, uuid.New())
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+	_, err = s.FindByEmail(ctx, "bud@example.com")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestUserEraser(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	otto := usertest.NewUser(t)
+	bud := user.User{Email: "bud@example.com", FirstName: "Bud", LastName: "Parker"}
+
+	// otto created both movies, bud updated the second
+	m1 := newMovie(t, "m1", "R", "1984-03-02T00:00:00Z", "Alex Cox")
+	m2 := newMovie(t, "m2", "R", "1986-10-03T00:00:00Z", "Alex Cox")
+	m2.SetUpdateUser(bud)
+	movies := NewMovieStore(m1, m2)
+	people := NewPersonStore(movies)
+	audits := NewAuditStore()
+	counter := NewCounter()
+	users := NewUserStore(clocktest.NewMockClock(t))
+
+	now := time.Now()
+	// bud's update of m2 recorded otto as its creator in its state
+	c.Assert(audits.Insert(ctx, []audit.Record{
+		{Username: otto.Email, Timestamp: now},
+		{Username: bud.Email, Timestamp: now, State: json.RawMessage(`{"CreateUser":{"email":"` + otto.Email + `"},"UpdateUser":{"email":"bud@example.com"}}`)},
+		{Username: bud.Email, Timestamp: now},
+	}), qt.IsNil)
+	_, err := counter.Increment(ctx, otto.Email, now)
+	c.Assert(err, qt.IsNil)
+	c.Assert(users.Record(ctx, otto), qt.IsNil)
+	c.Assert(users.Record(ctx, bud), qt.IsNil)
+
+	a, err := users.FindByEmail(ctx, otto.Email)
+	c.Assert(err, qt.IsNil)
+
+	sessions := NewSessionStore()
+	c.Assert(sessions.Create(ctx, auth.Session{ID: uuid.New(), Username: otto.Email}), qt.IsNil)
+	c.Assert(sessions.Create(ctx, auth.Session{ID: uuid.New(), Username: bud.Email}), qt.IsNil)
+
+	exportJobs := NewExportJobStore()
+	ottoJob := export.NewJob(uuid.New(), "movies", export.FormatCSV, otto.Email, now)
+	c.Assert(exportJobs.Create(ctx, ottoJob), qt.IsNil)
+
+	e := NewUserEraser(users, movies, people, audits, counter, sessions, exportJobs, clocktest.NewMockClock(t))
+	rpt, err := e.Erase(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rpt.UserID, qt.Equals, a.ID)
+	c.Assert(rpt.Tombstone, qt.Equals, user.TombstoneUsername)
+	c.Assert(rpt.EraseTime, qt.Equals, clocktest.Time)
+	c.Assert(rpt.AuditRecords, qt.Equals, int64(2))
+	c.Assert(rpt.Movies, qt.Equals, int64(2))
+	c.Assert(rpt.People, qt.Equals, int64(0))
+	c.Assert(rpt.RequestCounts, qt.Equals, int64(2))
+	c.Assert(rpt.Sessions, qt.Equals, int64(1))
+	c.Assert(rpt.ExportJobs, qt.Equals, int64(1))
+
+	// the references to otto are replaced, bud's are kept
+	got, err := movies.FindByID(ctx, "m2")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.CreateUser.Email, qt.Equals, user.TombstoneUsername)
+	c.Assert(got.UpdateUser, qt.DeepEquals, bud)
+	records, err := audits.Find(ctx, audit.Filter{Username: user.TombstoneUsername})
+	c.Assert(err, qt.IsNil)
+	c.Assert(records, qt.HasLen, 1)
+	records, err = audits.Find(ctx, audit.Filter{Username: bud.Email})
+	c.Assert(err, qt.IsNil)
+	c.Assert(records, qt.HasLen, 2)
+	c.Assert(string(records[1].State), qt.Equals, `{"CreateUser":{"email":"erased-user"},"UpdateUser":{"email":"bud@example.com"}}`)
+	counts, err := counter.Counts(ctx, otto.Email, now)
+	c.Assert(err, qt.IsNil)
+	c.Assert(counts, qt.Equals, quota.Counts{})
+	_, err = exportJobs.FindByID(ctx, ottoJob.ID, otto.Email)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	// otto is kept without their personal data
+	acct, err := users.FindByID(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(acct.User, qt.DeepEquals, user.User{})
+	c.Assert(acct.EraseTime, qt.Equals, rpt.EraseTime)
+	_, err = users.FindByEmail(ctx, otto.Email)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	// erasing otto again changes nothing
+	rpt2, err := e.Erase(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rpt2, qt.Equals, user.ErasureReport{UserID: a.ID, Tombstone: user.TombstoneUsername, EraseTime: rpt.EraseTime})
+
+	_, err = e.Erase(ctx, uuid.New())
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestResponseStore(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	rs := NewResponseStore(2)
+	rs.now = func() time.Time { return now }
+
+	c.Assert(rs.Set(ctx, "resp:movies:a", []byte("a"), time.Minute), qt.IsNil)
+	c.Assert(rs.Set(ctx, "resp:people:b", []byte("b"), time.Second), qt.IsNil)
+	got, ok, err := rs.Get(ctx, "resp:movies:a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(string(got), qt.Equals, "a")
+
+	// the store is full, so c is not stored
+	c.Assert(rs.Set(ctx, "resp:movies:c", []byte("c"), time.Minute), qt.IsNil)
+	_, ok, _ = rs.Get(ctx, "resp:movies:c")
+	c.Assert(ok, qt.IsFalse)
+
+	// b has expired, so c is stored in its place
+	now = now.Add(2 * time.Second)
+	_, ok, _ = rs.Get(ctx, "resp:people:b")
+	c.Assert(ok, qt.IsFalse)
+	c.Assert(rs.Set(ctx, "resp:movies:c", []byte("c"), time.Minute), qt.IsNil)
+	_, ok, _ = rs.Get(ctx, "resp:movies:c")
+	c.Assert(ok, qt.IsTrue)
+
+	c.Assert(rs.DeletePrefix(ctx, "resp:movies:"), qt.IsNil)
+	c.Assert(rs.entries, qt.HasLen, 0)
+}
@@ -0,0 +1,98 @@
+package memstore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxResponses is the number of responses a ResponseStore
+// holds at most, unless another maximum is given
+const DefaultMaxResponses int = 10000
+
+// NewResponseStore is an initializer for ResponseStore, holding at
+// most max responses (DefaultMaxResponses if max is zero)
+func NewResponseStore(max int) *ResponseStore {
+	if max <= 0 {
+		max = DefaultMaxResponses
+	}
+	return &ResponseStore{
+		max:     max,
+		entries: make(map[string]responseEntry),
+		now:     time.Now,
+	}
+}
+
+// ResponseStore holds cached responses in memory. It satisfies the
+// handler.ResponseStore interface and is safe for concurrent use.
+// Each server has its own store, so a response is only invalidated
+// on the server which handled the write; use a shared store (e.g.
+// Redis) with more than one server.
+type ResponseStore struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]responseEntry
+	now     func() time.Time
+}
+
+// responseEntry is a value held by ResponseStore
+type responseEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Get returns the value stored for key, if it has not expired
+func (rs *ResponseStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	e, ok := rs.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !rs.now().Before(e.expires) {
+		delete(rs.entries, key)
+		return nil, false, nil
+	}
+
+	return e.value, true, nil
+}
+
+// Set stores value for key until ttl has passed. If the store is
+// full, the expired values are removed first, and if it is still
+// full, value is not stored.
+func (rs *ResponseStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := rs.now()
+	if _, ok := rs.entries[key]; !ok && len(rs.entries) >= rs.max {
+		for k, e := range rs.entries {
+			if !now.Before(e.expires) {
+				delete(rs.entries, k)
+			}
+		}
+		if len(rs.entries) >= rs.max {
+			return nil
+		}
+	}
+
+	rs.entries[key] = responseEntry{value: value, expires: now.Add(ttl)}
+
+	return nil
+}
+
+// DeletePrefix deletes the values of every key starting with prefix
+func (rs *ResponseStore) DeletePrefix(ctx context.Context, prefix string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for k := range rs.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(rs.entries, k)
+		}
+	}
+
+	return nil
+}
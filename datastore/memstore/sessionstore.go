@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// NewSessionStore is an initializer for SessionStore
+func NewSessionStore() *SessionStore {
+	return &SessionStore{byID: make(map[uuid.UUID]*auth.Session)}
+}
+
+// SessionStore holds integration sessions in memory. It satisfies
+// the auth.SessionStore interface and is safe for concurrent use.
+// The refresh tokens are held in memory in plain text, the secrets
+// as hashes.
+type SessionStore struct {
+	mu   sync.RWMutex
+	byID map[uuid.UUID]*auth.Session
+}
+
+// secretHash returns the hash held for secret
+func secretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create adds s
+func (st *SessionStore) Create(ctx context.Context, s auth.Session) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, ok := st.byID[s.ID]; ok {
+		return errs.E(errs.Exist, "session already exists")
+	}
+	s.Secret = secretHash(s.Secret)
+	st.byID[s.ID] = &s
+
+	return nil
+}
+
+// FindBySecret returns the session with id, if secret is its secret
+func (st *SessionStore) FindBySecret(ctx context.Context, id uuid.UUID, secret string) (auth.Session, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	s, ok := st.byID[id]
+	if !ok || s.Secret != secretHash(secret) {
+		return auth.Session{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	found := *s
+	found.Secret = ""
+	return found, nil
+}
+
+// Refreshed records that an access token was issued for the session
+// with id at t, replacing its refresh token with refreshToken,
+// unless empty
+func (st *SessionStore) Refreshed(ctx context.Context, id uuid.UUID, refreshToken string, t time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.byID[id]
+	if !ok {
+		return errs.E(errs.NotExist, "No record found for given ID")
+	}
+	if refreshToken != "" {
+		s.RefreshToken = refreshToken
+	}
+	s.RefreshTime = t
+
+	return nil
+}
+
+// Delete deletes the session with id created by username
+func (st *SessionStore) Delete(ctx context.Context, id uuid.UUID, username string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.byID[id]
+	if !ok || !strings.EqualFold(s.Username, username) {
+		return errs.E(errs.NotExist, "No record found for given ID")
+	}
+	delete(st.byID, id)
+
+	return nil
+}
+
+// deleteUser deletes the sessions created by username (whatever its
+// case) and returns the number of sessions deleted
+func (st *SessionStore) deleteUser(username string) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var n int64
+	for id, s := range st.byID {
+		if strings.EqualFold(s.Username, username) {
+			delete(st.byID, id)
+			n++
+		}
+	}
+	return n
+}
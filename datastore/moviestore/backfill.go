@@ -0,0 +1,28 @@
+package moviestore
+
+import "github.com/gilcrest/go-api-basic/datastore/backfill"
+
+// SearchVectorBackfill fills the search_vector column of the movies
+// added before it existed. The column is kept up to date for new and
+// changed movies by a trigger, see datastore/ddl/bootstrap.sql.
+var SearchVectorBackfill = backfill.Backfill{
+	Name:        "movie-search-vector",
+	Description: "fill the full text search vector of existing movies",
+	Count:       `select count(*) from demo.movie`,
+	Batch: `with batch as (
+    select movie_id
+      from demo.movie
+     where ($1::uuid is null or movie_id > $1::uuid)
+     order by movie_id
+     limit $2),
+filled as (
+    update demo.movie m
+       set search_vector = demo.movie_search_vector(m.title, m.director, m.writer)
+      from batch
+     where m.movie_id = batch.movie_id
+       and m.search_vector is null
+    returning m.movie_id)
+select (select count(*) from batch),
+       (select count(*) from filled),
+       (select movie_id::text from batch order by movie_id desc limit 1)`,
+}
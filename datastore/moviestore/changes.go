@@ -0,0 +1,79 @@
+package moviestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/event"
+)
+
+// ChangeChannel is the Postgres channel changes to movies are
+// notified on
+const ChangeChannel = "movie_changes"
+
+// Actions of a Change
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+// Change is a change made to a movie. It is notified on
+// ChangeChannel as JSON when the transaction making the change
+// commits, so every instance of the server learns of it.
+type Change struct {
+	Action     string `json:"action"`
+	ExternalID string `json:"extl_id"`
+}
+
+// notifyChange notifies c on ChangeChannel as part of tx. Postgres
+// only delivers the notification if tx commits.
+func notifyChange(ctx context.Context, tx *sql.Tx, c Change) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `select pg_notify($1, $2)`, ChangeChannel, string(b))
+	return err
+}
+
+// changeNotifier publishes the changes notified on ChangeChannel
+// as events
+type changeNotifier struct {
+	pub    event.Publisher
+	logger zerolog.Logger
+}
+
+// notify is the datastore.NotifyFunc of the notifier. It decodes the
+// Change from the payload and publishes it as an event.MovieChanged,
+// or publishes an event.ChangesMissed if notifications may have been
+// missed.
+func (n changeNotifier) notify(payload string, ok bool) {
+	ctx := n.logger.WithContext(context.Background())
+	if !ok {
+		n.logger.Warn().Msg("movie change notifications may have been missed")
+		n.pub.Publish(ctx, event.ChangesMissed{Resource: "movies"})
+		return
+	}
+
+	var c Change
+	if err := json.Unmarshal([]byte(payload), &c); err != nil {
+		n.logger.Error().Err(err).Msgf("invalid movie change notification %q", payload)
+		return
+	}
+	n.pub.Publish(ctx, event.MovieChanged{Action: c.Action, ExternalID: c.ExternalID})
+}
+
+// ListenChanges listens for changes notified on ChangeChannel using
+// datastore.Listen and publishes them to pub, so every instance of
+// the server learns of them. Listening stops when the returned
+// function is called.
+func ListenChanges(dsn datastore.PGDatasourceName, pub event.Publisher, logger zerolog.Logger) (func(), error) {
+	return datastore.Listen(dsn, ChangeChannel, logger, changeNotifier{pub: pub, logger: logger}.notify)
+}
@@ -0,0 +1,38 @@
+package moviestore
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/event"
+)
+
+func TestChangeNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		ok      bool
+		want    []event.Event
+	}{
+		{"change", `{"action":"update","extl_id":"abc"}`, true, []event.Event{event.MovieChanged{Action: ChangeUpdate, ExternalID: "abc"}}},
+		{"reconnected", "", false, []event.Event{event.ChangesMissed{Resource: "movies"}}},
+		{"invalid payload", "not json", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			bus := event.NewInProcessBus()
+
+			var got []event.Event
+			bus.Subscribe(event.All, func(ctx context.Context, e event.Event) {
+				got = append(got, e)
+			})
+			changeNotifier{pub: bus, logger: zerolog.Nop()}.notify(tt.payload, tt.ok)
+
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
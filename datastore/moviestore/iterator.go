@@ -0,0 +1,176 @@
+package moviestore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+// MovieIterator yields Movies one at a time, as they are read, so
+// all Movies can be processed (e.g. exported) without being held in
+// memory. It is used as:
+//
+//	it, err := selector.FindAllIter(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		m := it.Movie()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type MovieIterator interface {
+	// Next advances to the next Movie. It returns false when there
+	// are no more Movies or an error occurs, see Err.
+	Next() bool
+	// Movie returns the Movie Next advanced to
+	Movie() *movie.Movie
+	// Err returns the error which stopped Next, if any
+	Err() error
+	// Close releases the resources held by the iterator. It must be
+	// called once the caller is done, even if Next returned false,
+	// and can be called more than once.
+	Close() error
+}
+
+// FindAllIter returns a MovieIterator over all Movies, ordered by when
+// they were created. Rows are read from the database cursor as Next
+// is called, in a read-only transaction which holds a connection of
+// the pool until the iterator is closed.
+func (d DefaultSelector) FindAllIter(ctx context.Context) (MovieIterator, error) {
+	tx, err := datastore.BeginTxOptions(ctx, d.Datastorer, datastore.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`select movie_id,
+				extl_id,
+				title,
+				rated,
+				released,
+				run_time,
+				director,
+				writer,
+				create_username,
+				create_timestamp,
+				update_username,
+				update_timestamp
+		   from demo.movie m
+		  order by create_timestamp, movie_id`)
+	if err != nil {
+		return nil, d.RollbackTx(tx, errs.E(errs.Database, err))
+	}
+
+	return &rowsIterator{ds: d.Datastorer, tx: tx, rows: rows}, nil
+}
+
+// rowsIterator is a MovieIterator reading Movies from rows selected
+// in tx
+type rowsIterator struct {
+	ds     datastore.Datastorer
+	tx     *sql.Tx
+	rows   *sql.Rows
+	m      *movie.Movie
+	err    error
+	closed bool
+}
+
+// Next scans the next row into a Movie
+func (it *rowsIterator) Next() bool {
+	if it.closed || it.err != nil {
+		return false
+	}
+	if !it.rows.Next() {
+		// Rows.Err will report the last error encountered by
+		// Rows.Next
+		if err := it.rows.Err(); err != nil {
+			it.err = errs.E(errs.Database, err)
+		}
+		return false
+	}
+
+	m := new(movie.Movie)
+	err := it.rows.Scan(ScanDest(m)...)
+	if err != nil {
+		it.err = errs.E(errs.Database, err)
+		return false
+	}
+	it.m = m
+
+	return true
+}
+
+// Movie returns the Movie scanned by Next
+func (it *rowsIterator) Movie() *movie.Movie {
+	return it.m
+}
+
+// Err returns the error which stopped Next
+func (it *rowsIterator) Err() error {
+	return it.err
+}
+
+// Close closes the rows and ends the transaction, which is rolled
+// back if iterating failed
+func (it *rowsIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+
+	if err := it.rows.Close(); err != nil && it.err == nil {
+		it.err = errs.E(errs.Database, err)
+	}
+	if it.err != nil {
+		return it.ds.RollbackTx(it.tx, it.err)
+	}
+	return it.ds.CommitTx(it.tx)
+}
+
+// NewSliceIterator returns a MovieIterator over movies, for
+// Selectors which already hold their Movies in memory
+func NewSliceIterator(movies []*movie.Movie) *SliceIterator {
+	return &SliceIterator{movies: movies, i: -1}
+}
+
+// SliceIterator is a MovieIterator over a slice of Movies
+type SliceIterator struct {
+	movies []*movie.Movie
+	i      int
+}
+
+// Next advances to the next Movie in the slice
+func (it *SliceIterator) Next() bool {
+	if it.i+1 >= len(it.movies) {
+		it.i = len(it.movies)
+		return false
+	}
+	it.i++
+	return true
+}
+
+// Movie returns the Movie Next advanced to
+func (it *SliceIterator) Movie() *movie.Movie {
+	if it.i < 0 || it.i >= len(it.movies) {
+		return nil
+	}
+	return it.movies[it.i]
+}
+
+// Err always returns nil
+func (it *SliceIterator) Err() error {
+	return nil
+}
+
+// Close ends the iteration
+func (it *SliceIterator) Close() error {
+	it.i = len(it.movies)
+	return nil
+}
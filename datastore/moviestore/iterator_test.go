@@ -0,0 +1,55 @@
+package moviestore
+
+import (
+	"database/sql"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+func TestSliceIterator(t *testing.T) {
+	c := qt.New(t)
+
+	m1 := &movie.Movie{ExternalID: "m1"}
+	m2 := &movie.Movie{ExternalID: "m2"}
+
+	it := NewSliceIterator([]*movie.Movie{m1, m2})
+	c.Assert(it.Movie(), qt.IsNil)
+	c.Assert(it.Next(), qt.IsTrue)
+	c.Assert(it.Movie(), qt.Equals, m1)
+	c.Assert(it.Next(), qt.IsTrue)
+	c.Assert(it.Movie(), qt.Equals, m2)
+	c.Assert(it.Next(), qt.IsFalse)
+	c.Assert(it.Movie(), qt.IsNil)
+	c.Assert(it.Next(), qt.IsFalse)
+	c.Assert(it.Err(), qt.IsNil)
+	c.Assert(it.Close(), qt.IsNil)
+
+	// Close ends the iteration
+	it = NewSliceIterator([]*movie.Movie{m1, m2})
+	c.Assert(it.Next(), qt.IsTrue)
+	c.Assert(it.Close(), qt.IsNil)
+	c.Assert(it.Next(), qt.IsFalse)
+
+	// an empty slice has nothing to iterate over
+	c.Assert(NewSliceIterator(nil).Next(), qt.IsFalse)
+}
+
+func TestScanDest(t *testing.T) {
+	c := qt.New(t)
+
+	m := &movie.Movie{Writer: "Alex Cox", RunTime: 92}
+	dest := ScanDest(m)
+	c.Assert(dest, qt.HasLen, 12)
+
+	// NULL writer and run_time columns are scanned as zero values
+	c.Assert(dest[5].(sql.Scanner).Scan(nil), qt.IsNil)
+	c.Assert(dest[7].(sql.Scanner).Scan(nil), qt.IsNil)
+	c.Assert(m.RunTime, qt.Equals, 0)
+	c.Assert(m.Writer, qt.Equals, "")
+
+	c.Assert(dest[7].(sql.Scanner).Scan("Alex Cox"), qt.IsNil)
+	c.Assert(m.Writer, qt.Equals, "Alex Cox")
+}
@@ -0,0 +1,261 @@
+// Package moviestoretest provides testing helper functions for the
+// moviestore package
+package moviestoretest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t testing.TB) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the moviestore.Transactor
+// interface
+type MockTransactor struct {
+	t testing.TB
+}
+
+// Create mocks creating a movie. Create never returns an error
+func (mt MockTransactor) Create(ctx context.Context, m *movie.Movie) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// Update mocks updating a movie. Update never returns an error
+func (mt MockTransactor) Update(ctx context.Context, m *movie.Movie) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// Delete mocks deleting a movie. Delete never returns an error
+func (mt MockTransactor) Delete(ctx context.Context, m *movie.Movie) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t testing.TB) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the moviestore.Selector
+// interface
+type MockSelector struct {
+	t testing.TB
+}
+
+// FindByID mocks finding a movie by External ID
+func (ms MockSelector) FindByID(ctx context.Context, s string) (*movie.Movie, error) {
+
+	// get test user
+	u := usertest.NewUser(ms.t)
+
+	// mock create/update timestamp
+	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
+
+	return &movie.Movie{
+		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+		CreateUser: u,
+		CreateTime: cuTime,
+		UpdateUser: u,
+		UpdateTime: cuTime,
+	}, nil
+}
+
+// FindAll mocks finding multiple movies by External ID
+func (ms MockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
+	// get test user
+	u := usertest.NewUser(ms.t)
+
+	// mock create/update timestamp
+	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
+
+	m1 := &movie.Movie{
+		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+		CreateUser: u,
+		CreateTime: cuTime,
+		UpdateUser: u,
+		UpdateTime: cuTime,
+	}
+
+	m2 := &movie.Movie{
+		ID:         uuid.MustParse("e883ebbb-c021-423b-954a-e94edb8b85b8"),
+		ExternalID: "RWn8zcaTA1gk3ybrBdQV",
+		Title:      "The Return of the Living Dead",
+		Rated:      "R",
+		Released:   time.Date(1985, 8, 16, 0, 0, 0, 0, time.UTC),
+		RunTime:    91,
+		Director:   "Dan O'Bannon",
+		Writer:     "Russell Streiner",
+		CreateUser: u,
+		CreateTime: cuTime,
+		UpdateUser: u,
+		UpdateTime: cuTime,
+	}
+
+	return []*movie.Movie{m1, m2}, nil
+}
+
+// FindAllIter mocks iterating over the movies returned by FindAll
+func (ms MockSelector) FindAllIter(ctx context.Context) (moviestore.MovieIterator, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return moviestore.NewSliceIterator(all), nil
+}
+
+// FindPage mocks finding a page of movies using the movies
+// returned by FindAll which match the filter f
+func (ms MockSelector) FindPage(ctx context.Context, f *filter.Expr, limit, offset int) ([]*movie.Movie, int, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	movies := make([]*movie.Movie, 0, len(all))
+	for _, m := range all {
+		if filter.Match(f, m.FilterValue) {
+			movies = append(movies, m)
+		}
+	}
+
+	total := len(movies)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return movies[offset:end], total, nil
+}
+
+// StreamPage mocks streaming a page of movies using the movies
+// returned by FindPage
+func (ms MockSelector) StreamPage(ctx context.Context, f *filter.Expr, limit, offset int, fn moviestore.PageFunc) (int, error) {
+	movies, total, err := ms.FindPage(ctx, f, limit, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range movies {
+		if err = fn(m, total); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// Stats mocks the aggregate statistics for the movies returned
+// by FindAll
+func (ms MockSelector) Stats(ctx context.Context) (*movie.Stats, error) {
+	return &movie.Stats{
+		ByRating:   []movie.GroupCount{{Value: "R", Count: 2}},
+		ByDecade:   []movie.GroupCount{{Value: "1980s", Count: 2}},
+		ByDirector: []movie.GroupCount{{Value: "Alex Cox", Count: 1}, {Value: "Dan O'Bannon", Count: 1}},
+	}, nil
+}
+
+// FindByIDs mocks finding movies by a list of External IDs. The
+// movies from FindAll are returned in the order of the IDs given.
+func (ms MockSelector) FindByIDs(ctx context.Context, extlIDs []string) ([]*movie.Movie, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]*movie.Movie, 0)
+	for _, id := range extlIDs {
+		for _, m := range all {
+			if m.ExternalID == id {
+				s = append(s, m)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Suggest mocks suggesting movies using the movies returned by
+// FindAll whose title starts with prefix, ignoring case
+func (ms MockSelector) Suggest(ctx context.Context, prefix string, limit int) ([]movie.Suggestion, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]movie.Suggestion, 0)
+	for _, m := range all {
+		if len(s) < limit && strings.HasPrefix(strings.ToLower(m.Title), strings.ToLower(prefix)) {
+			s = append(s, movie.Suggestion{ExternalID: m.ExternalID, Title: m.Title})
+		}
+	}
+
+	return s, nil
+}
+
+// Count mocks counting movies using the movies returned by FindAll.
+// The count is never an estimate.
+func (ms MockSelector) Count(ctx context.Context, mode movie.CountMode) (movie.Count, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return movie.Count{}, err
+	}
+
+	return movie.Count{Count: int64(len(all))}, nil
+}
+
+// ListVersion mocks the version of a list of movies using the movies
+// returned by FindAll which match the filter f
+func (ms MockSelector) ListVersion(ctx context.Context, f *filter.Expr) (movie.ListVersion, error) {
+	all, err := ms.FindAll(ctx)
+	if err != nil {
+		return movie.ListVersion{}, err
+	}
+
+	var v movie.ListVersion
+	for _, m := range all {
+		if !filter.Match(f, m.FilterValue) {
+			continue
+		}
+		v.Count++
+		if m.UpdateTime.After(v.LastUpdate) {
+			v.LastUpdate = m.UpdateTime
+		}
+	}
+
+	return v, nil
+}
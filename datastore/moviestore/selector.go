@@ -4,11 +4,16 @@ package moviestore
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
 	"github.com/gilcrest/go-api-basic/domain/movie"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -16,6 +21,53 @@ import (
 type Selector interface {
 	FindByID(context.Context, string) (*movie.Movie, error)
 	FindAll(context.Context) ([]*movie.Movie, error)
+	FindAllIter(context.Context) (MovieIterator, error)
+	FindPage(ctx context.Context, f *filter.Expr, limit, offset int) ([]*movie.Movie, int, error)
+	StreamPage(ctx context.Context, f *filter.Expr, limit, offset int, fn PageFunc) (int, error)
+	FindByIDs(context.Context, []string) ([]*movie.Movie, error)
+	Stats(context.Context) (*movie.Stats, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]movie.Suggestion, error)
+	Count(ctx context.Context, mode movie.CountMode) (movie.Count, error)
+	ListVersion(ctx context.Context, f *filter.Expr) (movie.ListVersion, error)
+}
+
+// filterColumns are the columns of the movie.FilterFields
+var filterColumns = map[string]string{
+	"title":        "m.title",
+	"rated":        "m.rated",
+	"release_date": "m.released",
+	"run_time":     "m.run_time",
+	"director":     "m.director",
+	"writer":       "m.writer",
+}
+
+// PageFunc is called by StreamPage for each Movie in a page as it
+// is read, with the total number of Movies. If PageFunc returns an
+// error, StreamPage stops and returns the error.
+type PageFunc func(m *movie.Movie, total int) error
+
+// ScanDest returns the destinations to scan the movie columns
+// selected by the stores into m, in order: movie_id, extl_id, title,
+// rated, released, run_time, director, writer, create_username,
+// create_timestamp, update_username and update_timestamp. A NULL in
+// a nullable column is scanned as the zero value of the field, e.g.
+// an empty Writer, which is written back as NULL (see
+// DefaultTransactor), so NULLs round-trip.
+func ScanDest(m *movie.Movie) []interface{} {
+	return []interface{}{
+		&m.ID,
+		&m.ExternalID,
+		&m.Title,
+		repo.String(&m.Rated),
+		repo.Time(&m.Released),
+		repo.Int(&m.RunTime),
+		repo.String(&m.Director),
+		repo.String(&m.Writer),
+		repo.String(&m.CreateUser.Email),
+		repo.Time(&m.CreateTime),
+		repo.String(&m.UpdateUser.Email),
+		repo.Time(&m.UpdateTime),
+	}
 }
 
 // NewDefaultSelector is an initializer for DefaultSelector
@@ -30,10 +82,23 @@ type DefaultSelector struct {
 
 // FindByID returns a Movie struct to populate the response
 func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*movie.Movie, error) {
-	db := d.Datastorer.DB()
+	var m *movie.Movie
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		m, err = findByID(ctx, tx, extlID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// findByID returns the Movie with the given external ID using tx
+func findByID(ctx context.Context, tx *sql.Tx, extlID string) (*movie.Movie, error) {
 
 	// Prepare the sql statement using bind variables
-	row := db.QueryRowContext(ctx,
+	row := tx.QueryRowContext(ctx,
 		`select movie_id,
 				extl_id,
 				title,
@@ -50,19 +115,7 @@ func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*movie.Mo
 		  where extl_id = $1`, extlID)
 
 	m := new(movie.Movie)
-	err := row.Scan(
-		&m.ID,
-		&m.ExternalID,
-		&m.Title,
-		&m.Rated,
-		&m.Released,
-		&m.RunTime,
-		&m.Director,
-		&m.Writer,
-		&m.CreateUser.Email,
-		&m.CreateTime,
-		&m.UpdateUser.Email,
-		&m.UpdateTime)
+	err := row.Scan(ScanDest(m)...)
 
 	if err == sql.ErrNoRows {
 		return nil, errs.E(errs.NotExist, "No record found for given ID")
@@ -75,10 +128,23 @@ func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*movie.Mo
 
 // FindAll returns a slice of Movie structs to populate the response
 func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
-	db := d.Datastorer.DB()
+	var s []*movie.Movie
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = findAll(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// findAll returns all Movies using tx
+func findAll(ctx context.Context, tx *sql.Tx) ([]*movie.Movie, error) {
 
 	// use QueryContext to get back sql.Rows
-	rows, err := db.QueryContext(ctx,
+	rows, err := tx.QueryContext(ctx,
 		`select movie_id,
 					  extl_id,
 					  title,
@@ -105,19 +171,7 @@ func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 	// defined above
 	for rows.Next() {
 		m := new(movie.Movie)
-		err = rows.Scan(
-			&m.ID,
-			&m.ExternalID,
-			&m.Title,
-			&m.Rated,
-			&m.Released,
-			&m.RunTime,
-			&m.Director,
-			&m.Writer,
-			&m.CreateUser.Email,
-			&m.CreateTime,
-			&m.UpdateUser.Email,
-			&m.UpdateTime)
+		err = rows.Scan(ScanDest(m)...)
 
 		if err != nil {
 			return nil, errs.E(errs.Database, err)
@@ -149,3 +203,406 @@ func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 	// return the slice
 	return s, nil
 }
+
+// FindPage returns up to limit Movies matching the filter f (all
+// Movies if f is nil), skipping the first offset Movies, as well as
+// the total number of matching Movies. Movies are ordered by when
+// they were created so pages are stable.
+func (d DefaultSelector) FindPage(ctx context.Context, f *filter.Expr, limit, offset int) ([]*movie.Movie, int, error) {
+	s := make([]*movie.Movie, 0, limit)
+	total, err := d.StreamPage(ctx, f, limit, offset, func(m *movie.Movie, total int) error {
+		s = append(s, m)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s, total, nil
+}
+
+// StreamPage calls fn for each of up to limit Movies matching the
+// filter f, skipping the first offset Movies, as each row is scanned,
+// so the page is never held in memory. The total number of matching
+// Movies is returned. Movies are ordered as in FindPage.
+func (d DefaultSelector) StreamPage(ctx context.Context, f *filter.Expr, limit, offset int, fn PageFunc) (int, error) {
+	var (
+		total int
+		fnErr error
+	)
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		total, err = streamPage(ctx, tx, f, limit, offset, func(m *movie.Movie, total int) error {
+			fnErr = fn(m, total)
+			return fnErr
+		})
+		return err
+	})
+	// the error from fn is the caller's, return it as is
+	if fnErr != nil {
+		return 0, fnErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// streamPage streams a page of Movies using tx as described by
+// StreamPage. The page and total count are selected in the same
+// read-only transaction, so they are consistent.
+func streamPage(ctx context.Context, tx *sql.Tx, f *filter.Expr, limit, offset int, fn PageFunc) (int, error) {
+
+	// the filter is added as a where clause with its values as bind
+	// variables, following limit and offset
+	where, args, err := datastore.FilterSQL(f, filterColumns, 2)
+	if err != nil {
+		return 0, err
+	}
+
+	// count(*) over() returns the total count of movies (before
+	// limit and offset are applied) with each row
+	rows, err := tx.QueryContext(ctx,
+		`select movie_id,
+				extl_id,
+				title,
+				rated,
+				released,
+				run_time,
+				director,
+				writer,
+				create_username,
+				create_timestamp,
+				update_username,
+				update_timestamp,
+				count(*) over() as total_count
+		   from demo.movie m
+		  where `+where+`
+		  order by create_timestamp, movie_id
+		  limit $1 offset $2`, append([]interface{}{limit, offset}, args...)...)
+	if err != nil {
+		return 0, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	var total, n int
+	for rows.Next() {
+		m := new(movie.Movie)
+		err = rows.Scan(append(ScanDest(m), &total)...)
+
+		if err != nil {
+			return 0, errs.E(errs.Database, err)
+		}
+
+		// the error from fn is the caller's, return it as is
+		if err = fn(m, total); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return 0, errs.E(errs.Database, err)
+	}
+
+	// If the offset is past the last row, no rows are returned, so
+	// the total count has to be selected separately
+	if n == 0 && offset > 0 {
+		where, args, err := datastore.FilterSQL(f, filterColumns, 0)
+		if err != nil {
+			return 0, err
+		}
+		err = tx.QueryRowContext(ctx, `select count(*) from demo.movie m where `+where, args...).Scan(&total)
+		if err != nil {
+			return 0, errs.E(errs.Database, err)
+		}
+	}
+
+	return total, nil
+}
+
+// FindByIDs returns the Movies for the given external IDs using a
+// single query. Movies are returned in the order of the IDs given
+// and IDs which are not found are omitted.
+func (d DefaultSelector) FindByIDs(ctx context.Context, extlIDs []string) ([]*movie.Movie, error) {
+	var s []*movie.Movie
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = findByIDs(ctx, tx, extlIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// findByIDs returns the Movies for the given external IDs using tx
+func findByIDs(ctx context.Context, tx *sql.Tx, extlIDs []string) ([]*movie.Movie, error) {
+
+	// use QueryContext to get back sql.Rows
+	rows, err := tx.QueryContext(ctx,
+		`select movie_id,
+				extl_id,
+				title,
+				rated,
+				released,
+				run_time,
+				director,
+				writer,
+				create_username,
+				create_timestamp,
+				update_username,
+				update_timestamp
+		   from demo.movie m
+		  where extl_id = any($1)
+		  order by array_position($1, extl_id)`, pq.Array(extlIDs))
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]*movie.Movie, 0, len(extlIDs))
+	for rows.Next() {
+		m := new(movie.Movie)
+		err = rows.Scan(ScanDest(m)...)
+
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+
+		s = append(s, m)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
+
+// Stats returns counts of movies grouped by rating, decade and
+// director. The grouping is done in the database, so the full set
+// of movies is not pulled back to be counted.
+func (d DefaultSelector) Stats(ctx context.Context) (*movie.Stats, error) {
+	var stats *movie.Stats
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		stats, err = findStats(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// findStats returns the movie counts using tx. The counts are
+// selected in the same read-only transaction, so they add up.
+func findStats(ctx context.Context, tx *sql.Tx) (*movie.Stats, error) {
+
+	byRating, err := countBy(ctx, tx, "rated")
+	if err != nil {
+		return nil, err
+	}
+
+	// decade is formatted as the first year of the decade followed
+	// by an "s", e.g. 1980s
+	byDecade, err := countBy(ctx, tx, "to_char(date_trunc('decade', released), 'YYYY') || 's'")
+	if err != nil {
+		return nil, err
+	}
+
+	byDirector, err := countBy(ctx, tx, "director")
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie.Stats{
+		ByRating:   byRating,
+		ByDecade:   byDecade,
+		ByDirector: byDirector,
+	}, nil
+}
+
+// Suggest returns up to limit Movies whose title starts with prefix,
+// ignoring case, ordered by title. The match uses the
+// movie_title_prefix_index, so it stays fast as movies are added.
+func (d DefaultSelector) Suggest(ctx context.Context, prefix string, limit int) ([]movie.Suggestion, error) {
+	var s []movie.Suggestion
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = suggest(ctx, tx, prefix, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// suggest returns the title suggestions for prefix using tx
+func suggest(ctx context.Context, tx *sql.Tx, prefix string, limit int) ([]movie.Suggestion, error) {
+
+	rows, err := tx.QueryContext(ctx,
+		`select extl_id,
+				title
+		   from demo.movie m
+		  where lower(title) like $1 escape '\'
+		  order by lower(title), title
+		  limit $2`, likePrefix(strings.ToLower(prefix)), limit)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]movie.Suggestion, 0)
+	for rows.Next() {
+		var ms movie.Suggestion
+		if err = rows.Scan(&ms.ExternalID, &ms.Title); err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, ms)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
+
+// Count returns the number of movies. With movie.CountEstimate, the
+// row estimate of the movie table in pg_class is returned, which
+// avoids reading the whole table. If the table has never been
+// analyzed, there is no estimate and the exact count is returned.
+func (d DefaultSelector) Count(ctx context.Context, mode movie.CountMode) (movie.Count, error) {
+	var c movie.Count
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		c, err = count(ctx, tx, mode)
+		return err
+	})
+	if err != nil {
+		return movie.Count{}, err
+	}
+
+	return c, nil
+}
+
+// RefreshStats analyzes the movie table, so the row estimate read by
+// Count with movie.CountEstimate is current, e.g. after a restore,
+// which does not restore the statistics of a table
+func (d DefaultSelector) RefreshStats(ctx context.Context) error {
+	return datastore.WithTx(ctx, d.Datastorer, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `analyze demo.movie`); err != nil {
+			return errs.E(errs.Database, err)
+		}
+		return nil
+	})
+}
+
+// count returns the number of movies using tx
+func count(ctx context.Context, tx *sql.Tx, mode movie.CountMode) (movie.Count, error) {
+	if mode == movie.CountEstimate {
+		// reltuples is -1 (0 before Postgres 14) if the table has
+		// never been analyzed
+		var estimate float64
+		err := tx.QueryRowContext(ctx,
+			`select reltuples
+			   from pg_class
+			  where oid = 'demo.movie'::regclass`).Scan(&estimate)
+		if err != nil {
+			return movie.Count{}, errs.E(errs.Database, err)
+		}
+		if estimate >= 0 {
+			return movie.Count{Count: int64(estimate), Estimated: true}, nil
+		}
+	}
+
+	var n int64
+	err := tx.QueryRowContext(ctx, `select count(*) from demo.movie`).Scan(&n)
+	if err != nil {
+		return movie.Count{}, errs.E(errs.Database, err)
+	}
+
+	return movie.Count{Count: n}, nil
+}
+
+// ListVersion returns the version of the list of Movies matching the
+// filter f (all Movies if f is nil): their latest update timestamp
+// and count, read in a single aggregate query
+func (d DefaultSelector) ListVersion(ctx context.Context, f *filter.Expr) (movie.ListVersion, error) {
+	var v movie.ListVersion
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		v, err = listVersion(ctx, tx, f)
+		return err
+	})
+	if err != nil {
+		return movie.ListVersion{}, err
+	}
+
+	return v, nil
+}
+
+// listVersion returns the version of the list of Movies matching the
+// filter f using tx
+func listVersion(ctx context.Context, tx *sql.Tx, f *filter.Expr) (movie.ListVersion, error) {
+	where, args, err := datastore.FilterSQL(f, filterColumns, 0)
+	if err != nil {
+		return movie.ListVersion{}, err
+	}
+
+	// max is null if no movie matches
+	var v movie.ListVersion
+	err = tx.QueryRowContext(ctx,
+		`select max(update_timestamp),
+				count(*)
+		   from demo.movie m
+		  where `+where, args...).Scan(repo.Time(&v.LastUpdate), &v.Count)
+	if err != nil {
+		return movie.ListVersion{}, errs.E(errs.Database, err)
+	}
+
+	return v, nil
+}
+
+// likePrefix returns the like pattern matching text starting with s
+func likePrefix(s string) string {
+	return datastore.EscapeLike(s) + "%"
+}
+
+// countBy returns the count of movies grouped by the given column
+// expression, ordered by count descending. expr must be a constant
+// expression and never user input as it is added to the statement
+// text.
+func countBy(ctx context.Context, tx *sql.Tx, expr string) ([]movie.GroupCount, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`select coalesce(%s, '') as value,
+				count(*)
+		   from demo.movie m
+		  group by 1
+		  order by 2 desc, 1`, expr))
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]movie.GroupCount, 0)
+	for rows.Next() {
+		var gc movie.GroupCount
+		if err = rows.Scan(&gc.Value, &gc.Count); err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, gc)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err = rows.Err(); err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
 
 	qt "github.com/frankban/quicktest"
 
@@ -90,6 +91,124 @@ func TestDefaultSelector_FindAll(t *testing.T) {
 	}
 }
 
+func TestDefaultSelector_FindPage(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// create two movies with the helper to ensure that there is more
+	// than one page of size 1
+	_, m1Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m1Cleanup)
+	_, m2Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m2Cleanup)
+
+	d := NewDefaultSelector(ds)
+
+	got, total, err := d.FindPage(ctx, nil, 1, 0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 1)
+	c.Assert(total >= 2, qt.IsTrue)
+
+	// a page past the end returns no movies, but still the total
+	got, pastTotal, err := d.FindPage(ctx, nil, 1, total)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 0)
+	c.Assert(pastTotal, qt.Equals, total)
+}
+
+func TestDefaultSelector_StreamPage(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	_, m1Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m1Cleanup)
+	_, m2Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m2Cleanup)
+
+	d := NewDefaultSelector(ds)
+
+	// the movies streamed are the same as the page found
+	want, wantTotal, err := d.FindPage(ctx, nil, 2, 0)
+	c.Assert(err, qt.IsNil)
+
+	var got []*movie.Movie
+	total, err := d.StreamPage(ctx, nil, 2, 0, func(m *movie.Movie, total int) error {
+		c.Assert(total, qt.Equals, wantTotal)
+		got = append(got, m)
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(total, qt.Equals, wantTotal)
+	c.Assert(got, qt.DeepEquals, want)
+
+	// an error from fn stops the stream and is returned as is
+	stop := errors.New("stop")
+	_, err = d.StreamPage(ctx, nil, 2, 0, func(m *movie.Movie, total int) error {
+		return stop
+	})
+	c.Assert(err, qt.Equals, stop)
+}
+
+func TestDefaultSelector_FindAllIter(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	_, m1Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m1Cleanup)
+	_, m2Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m2Cleanup)
+
+	d := NewDefaultSelector(ds)
+
+	// the movies iterated over are the same as the first page of
+	// all movies, in the same order
+	want, total, err := d.FindPage(ctx, nil, 1000, 0)
+	c.Assert(err, qt.IsNil)
+
+	it, err := d.FindAllIter(ctx)
+	c.Assert(err, qt.IsNil)
+	var got []*movie.Movie
+	for it.Next() {
+		got = append(got, it.Movie())
+	}
+	c.Assert(it.Err(), qt.IsNil)
+	c.Assert(it.Close(), qt.IsNil)
+	c.Assert(len(got), qt.Equals, total)
+	if total <= 1000 {
+		c.Assert(got, qt.DeepEquals, want)
+	}
+
+	// Close can be called more than once, and before iterating
+	// through all movies
+	it, err = d.FindAllIter(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(it.Next(), qt.IsTrue)
+	c.Assert(it.Close(), qt.IsNil)
+	c.Assert(it.Close(), qt.IsNil)
+	c.Assert(it.Next(), qt.IsFalse)
+}
+
 func TestDefaultSelector_FindByID(t *testing.T) {
 	c := qt.New(t)
 
@@ -143,3 +262,87 @@ func TestDefaultSelector_FindByID(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultSelector_Stats(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// create a movie with the helper to ensure that at least one
+	// row is counted in each group
+	m, movieCleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(movieCleanup)
+
+	d := NewDefaultSelector(ds)
+
+	got, err := d.Stats(ctx)
+	c.Assert(err, qt.IsNil)
+
+	// find returns the count for the given value within the groups
+	find := func(gcs []movie.GroupCount, value string) int {
+		for _, gc := range gcs {
+			if gc.Value == value {
+				return gc.Count
+			}
+		}
+		return 0
+	}
+
+	c.Assert(find(got.ByRating, m.Rated) >= 1, qt.IsTrue)
+	c.Assert(find(got.ByDecade, "1980s") >= 1, qt.IsTrue)
+	c.Assert(find(got.ByDirector, m.Director) >= 1, qt.IsTrue)
+}
+
+func TestDefaultSelector_RefreshStats(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	_, movieCleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(movieCleanup)
+
+	d := NewDefaultSelector(ds)
+
+	c.Assert(d.RefreshStats(ctx), qt.IsNil)
+
+	// the table has been analyzed, so there is an estimate
+	got, err := d.Count(ctx, movie.CountEstimate)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Estimated, qt.IsTrue)
+	c.Assert(got.Count >= 1, qt.IsTrue)
+}
+
+func TestDefaultSelector_FindByIDs(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	ds, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	m1, m1Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m1Cleanup)
+	m2, m2Cleanup := NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(m2Cleanup)
+
+	d := NewDefaultSelector(ds)
+
+	// movies should be returned in the order requested and unknown
+	// IDs should be ignored
+	got, err := d.FindByIDs(ctx, []string{m2.ExternalID, "doesnotexist", m1.ExternalID})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(got), qt.Equals, 2)
+	c.Assert(got[0].ExternalID, qt.Equals, m2.ExternalID)
+	c.Assert(got[1].ExternalID, qt.Equals, m1.ExternalID)
+}
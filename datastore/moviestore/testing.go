@@ -5,10 +5,11 @@ import (
 	"testing"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
 	"github.com/gilcrest/go-api-basic/domain/movie"
 	"github.com/gilcrest/go-api-basic/domain/random"
 	"github.com/gilcrest/go-api-basic/domain/user/usertest"
-	"github.com/google/uuid"
 )
 
 // NewMovieDBHelper creates/inserts a new movie in the db and optionally
@@ -39,14 +40,17 @@ func NewMovieDBHelper(t *testing.T, ctx context.Context, ds datastore.Datastorer
 func newMovie(t *testing.T) *movie.Movie {
 	t.Helper()
 
-	id := uuid.New()
-	rsg := random.DefaultStringGenerator{}
-	extlID, err := rsg.CryptoString(15)
+	clk := clock.DefaultClock{}
+	id, err := idgen.DefaultUUIDGenerator{Clock: clk}.NewUUID()
 	if err != nil {
-		t.Fatalf("random.CryptoString() error = %v", err)
+		t.Fatalf("idgen.NewUUID() error = %v", err)
+	}
+	extlID, err := random.DefaultStringGenerator{}.NewExternalID()
+	if err != nil {
+		t.Fatalf("random.NewExternalID() error = %v", err)
 	}
 	u := usertest.NewUser(t)
-	m, err := movie.NewMovie(id, extlID, u)
+	m, err := movie.NewMovie(id, extlID, u, clk)
 	if err != nil {
 		t.Fatalf("movie.NewMovie() error = %v", err)
 	}
@@ -2,6 +2,7 @@ package moviestore
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -20,24 +21,26 @@ type Transactor interface {
 
 // NewDefaultTransactor is an initializer for DefaultMovieStore
 func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
-	return DefaultTransactor{ds}
+	return DefaultTransactor{datastorer: ds, TxOptions: datastore.ReadWrite}
 }
 
 // DefaultTransactor is the default database implementation
 // for DML operations for a movie
 type DefaultTransactor struct {
 	datastorer datastore.Datastorer
+	// TxOptions are the options of the transaction each operation
+	// runs in. By default, an operation which fails with a
+	// serialization failure or deadlock is retried. Set to
+	// datastore.Serializable to run writes at the SERIALIZABLE
+	// isolation level.
+	TxOptions datastore.TxOptions
 }
 
 // Create inserts a record in the user table using a stored function
 func (dt DefaultTransactor) Create(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Prepare the sql statement using bind variables
-	stmt, err := tx.PrepareContext(ctx, `
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		// Prepare the sql statement using bind variables
+		stmt, err := tx.PrepareContext(ctx, `
 	select o_create_timestamp,
 		   o_update_timestamp
 	  from demo.create_movie (
@@ -52,65 +55,57 @@ func (dt DefaultTransactor) Create(ctx context.Context, m *movie.Movie) error {
 		p_create_client_id => $9,
 		p_create_username => $10)`)
 
-	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-	defer stmt.Close()
-
-	// At some point, I will add a whole client flow, but for now
-	// faking a client uuid....
-	fakeClientID := uuid.New()
-
-	// Execute stored function that returns the create_date timestamp,
-	// hence the use of QueryContext instead of Exec
-	rows, err := stmt.QueryContext(ctx,
-		m.ID,               //$1
-		m.ExternalID,       //$2
-		m.Title,            //$3
-		m.Rated,            //$4
-		m.Released,         //$5
-		m.RunTime,          //$6
-		m.Director,         //$7
-		m.Writer,           //$8
-		fakeClientID,       //$9
-		m.CreateUser.Email) //$10
-
-	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-	defer rows.Close()
-
-	// Iterate through the returned record(s)
-	for rows.Next() {
-		if err := rows.Scan(&m.CreateTime, &m.UpdateTime); err != nil {
-			return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-		}
-	}
-
-	// If any error was encountered while iterating through rows.Next above
-	// it will be returned here
-	if err := rows.Err(); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-
-	// Commit the Transaction
-	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-
-	return nil
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		// At some point, I will add a whole client flow, but for now
+		// faking a client uuid....
+		fakeClientID := uuid.New()
+
+		// Execute stored function that returns the create_date timestamp,
+		// hence the use of QueryContext instead of Exec
+		rows, err := stmt.QueryContext(ctx,
+			m.ID,                                     //$1
+			m.ExternalID,                             //$2
+			m.Title,                                  //$3
+			datastore.NewNullString(m.Rated),         //$4
+			datastore.NewNullTime(m.Released),        //$5
+			datastore.NewNullInt64(int64(m.RunTime)), //$6
+			datastore.NewNullString(m.Director),      //$7
+			datastore.NewNullString(m.Writer),        //$8
+			fakeClientID,                             //$9
+			m.CreateUser.Email)                       //$10
+
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Iterate through the returned record(s)
+		for rows.Next() {
+			if err := rows.Scan(&m.CreateTime, &m.UpdateTime); err != nil {
+				return err
+			}
+		}
+
+		// If any error was encountered while iterating through rows.Next above
+		// it will be returned here
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return notifyChange(ctx, tx, Change{Action: ChangeCreate, ExternalID: m.ExternalID})
+	})
 }
 
 // Update updates a record in the database using the external ID of
 // the Movie
 func (dt DefaultTransactor) Update(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Prepare the sql statement using bind variables
-	stmt, err := tx.PrepareContext(ctx, `
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		// Prepare the sql statement using bind variables
+		stmt, err := tx.PrepareContext(ctx, `
 	update demo.movie
 	   set title = $1,
 		   rated = $2,
@@ -123,93 +118,149 @@ func (dt DefaultTransactor) Update(ctx context.Context, m *movie.Movie) error {
 	 where extl_id = $9
 returning movie_id, create_username, create_timestamp`)
 
-	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-	defer stmt.Close()
-
-	// Execute stored function that returns the create_date timestamp,
-	// hence the use of QueryContext instead of Exec
-	rows, err := stmt.QueryContext(ctx,
-		m.Title,            //$1
-		m.Rated,            //$2
-		m.Released,         //$3
-		m.RunTime,          //$4
-		m.Director,         //$5
-		m.Writer,           //$6
-		m.UpdateUser.Email, //$7
-		m.UpdateTime,       //$8
-		m.ExternalID)       //$9
-
-	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-	defer rows.Close()
-
-	// Iterate through the returned record(s)
-	for rows.Next() {
-		if err := rows.Scan(&m.ID, &m.CreateUser.Email, &m.CreateTime); err != nil {
-			return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-		}
-	}
-
-	// If any error was encountered while iterating through rows.Next above
-	// it will be returned here
-	if err := rows.Err(); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-
-	// If the table's primary key is not returned as part of the
-	// RETURNING clause, this means the row was not actually updated.
-	// The update request does not contain this key (I don't believe
-	// in exposing primary keys), so this is a way of returning data
-	// from an update statement and checking whether or not the
-	// update was actually successful. Typically you would use
-	// db.Exec and check RowsAffected (like I do in delete below),
-	// but I wanted to show an alternative which can be useful here
-	if m.ID == uuid.Nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, errors.New("Invalid ID - no records updated")))
-	}
-
-	// Commit the Transaction
-	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-
-	return nil
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		// Execute stored function that returns the create_date timestamp,
+		// hence the use of QueryContext instead of Exec
+		rows, err := stmt.QueryContext(ctx,
+			m.Title,                                  //$1
+			datastore.NewNullString(m.Rated),         //$2
+			datastore.NewNullTime(m.Released),        //$3
+			datastore.NewNullInt64(int64(m.RunTime)), //$4
+			datastore.NewNullString(m.Director),      //$5
+			datastore.NewNullString(m.Writer),        //$6
+			m.UpdateUser.Email,                       //$7
+			m.UpdateTime,                             //$8
+			m.ExternalID)                             //$9
+
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Iterate through the returned record(s), counting each row
+		// returned by the RETURNING clause
+		var rowsAffected int
+		for rows.Next() {
+			if err := rows.Scan(&m.ID, &m.CreateUser.Email, &m.CreateTime); err != nil {
+				return err
+			}
+			rowsAffected++
+		}
+
+		// If any error was encountered while iterating through rows.Next above
+		// it will be returned here
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Each row updated is returned as part of the RETURNING
+		// clause, so the number of rows returned is the same as
+		// RowsAffected would be for db.Exec (like I use in delete
+		// below). If no rows are returned, the external ID given
+		// does not exist.
+		if rowsAffected == 0 {
+			return errs.E(errs.NotExist, "No record found for given ID")
+		} else if rowsAffected > 1 {
+			return errors.New("Too Many Rows Updated")
+		}
+
+		return notifyChange(ctx, tx, Change{Action: ChangeUpdate, ExternalID: m.ExternalID})
+	})
+}
+
+// Upsert inserts the Movie or, if a Movie with the same external ID
+// already exists, updates it in place, so the seed command can be run
+// more than once without a unique constraint violation. When the
+// Movie is updated, the ID, create user and create time of the
+// existing record are set to m. It is not part of Transactor, as no
+// endpoint upserts movies.
+func (dt DefaultTransactor) Upsert(ctx context.Context, m *movie.Movie) error {
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		// Prepare the sql statement using bind variables
+		stmt, err := tx.PrepareContext(ctx, `
+	insert into demo.movie (movie_id, extl_id, title, rated, released,
+		   run_time, director, writer, create_username, create_timestamp,
+		   update_username, update_timestamp)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $9, $10)
+	    on conflict (extl_id) do update
+	   set title = excluded.title,
+		   rated = excluded.rated,
+		   released = excluded.released,
+		   run_time = excluded.run_time,
+		   director = excluded.director,
+		   writer = excluded.writer,
+		   update_username = excluded.update_username,
+		   update_timestamp = excluded.update_timestamp
+returning movie_id, create_username, create_timestamp, update_timestamp`)
+
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		// The insert (or update) timestamp is taken from the
+		// UpdateTime of the Movie as the Movie may or may not exist
+		rows, err := stmt.QueryContext(ctx,
+			m.ID,                                     //$1
+			m.ExternalID,                             //$2
+			m.Title,                                  //$3
+			datastore.NewNullString(m.Rated),         //$4
+			datastore.NewNullTime(m.Released),        //$5
+			datastore.NewNullInt64(int64(m.RunTime)), //$6
+			datastore.NewNullString(m.Director),      //$7
+			datastore.NewNullString(m.Writer),        //$8
+			m.UpdateUser.Email,                       //$9
+			m.UpdateTime)                             //$10
+
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Iterate through the returned record(s)
+		for rows.Next() {
+			if err := rows.Scan(&m.ID, &m.CreateUser.Email, &m.CreateTime, &m.UpdateTime); err != nil {
+				return err
+			}
+		}
+
+		// If any error was encountered while iterating through rows.Next above
+		// it will be returned here
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return notifyChange(ctx, tx, Change{Action: ChangeUpsert, ExternalID: m.ExternalID})
+	})
 }
 
 // Delete removes the Movie record from the table
 func (dt DefaultTransactor) Delete(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
-	if err != nil {
-		return err
-	}
-
-	result, execErr := tx.ExecContext(ctx,
-		`DELETE from demo.movie
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE from demo.movie
 		        WHERE movie_id = $1`, m.ID)
 
-	if execErr != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, execErr))
-	}
-
-	// Only 1 row should be deleted, check the result count to
-	// ensure this is correct
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-	if rowsAffected == 0 {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, errors.New("No Rows Deleted")))
-	} else if rowsAffected > 1 {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, errors.New("Too Many Rows Deleted")))
-	}
-
-	// Commit the Transaction
-	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
-	}
-
-	return nil
+		if err != nil {
+			return err
+		}
+
+		// Only 1 row should be deleted, check the result count to
+		// ensure this is correct
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return errs.E(errs.NotExist, "No record found for given ID")
+		} else if rowsAffected > 1 {
+			return errors.New("Too Many Rows Deleted")
+		}
+
+		return notifyChange(ctx, tx, Change{Action: ChangeDelete, ExternalID: m.ExternalID})
+	})
 }
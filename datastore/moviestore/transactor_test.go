@@ -8,6 +8,7 @@ import (
 
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/logger"
 	"github.com/gilcrest/go-api-basic/domain/movie"
 	"github.com/google/uuid"
@@ -21,7 +22,7 @@ func TestNewDefaultTransactor(t *testing.T) {
 
 	defaultDatastore, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
 	t.Cleanup(cleanup)
-	defaultTransactor := DefaultTransactor{defaultDatastore}
+	defaultTransactor := DefaultTransactor{datastorer: defaultDatastore}
 
 	tests := []struct {
 		name string
@@ -124,13 +125,62 @@ func TestDefaultTransactor_Update(t *testing.T) {
 			dt := DefaultTransactor{
 				datastorer: tt.fields.datastorer,
 			}
-			if err := dt.Update(tt.args.ctx, tt.args.m); (err != nil) != tt.wantErr {
+			err := dt.Update(tt.args.ctx, tt.args.m)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("DefaultTransactor.Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			// an unknown external ID should be reported as NotExist
+			if tt.wantErr && !errs.KindIs(errs.NotExist, err) {
+				t.Errorf("DefaultTransactor.Update() error = %v, want Kind %s", err, errs.NotExist)
+			}
 		})
 	}
 }
 
+func TestDefaultTransactor_Upsert(t *testing.T) {
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// I am intentionally not using the cleanup function that is
+	// returned as I need the DB to stay open for the test
+	// t.Cleanup function
+	defaultDatastore, _ := datastoretest.NewDefaultDatastore(t, lgr)
+	dt := NewDefaultTransactor(defaultDatastore)
+	ctx := context.Background()
+
+	// upsert a new movie, which should be inserted
+	m := newMovie(t)
+	id := m.ID
+	if err := dt.Upsert(ctx, m); err != nil {
+		t.Fatalf("DefaultTransactor.Upsert() insert error = %v", err)
+	}
+	t.Cleanup(func() {
+		err := dt.Delete(ctx, m)
+		if err != nil {
+			t.Fatalf("defaultTransactor.Delete error = %v", err)
+		}
+	})
+
+	// upsert the same movie with a new ID (as a client re-sending
+	// it would not know the ID), which should update the existing
+	// record and keep its ID
+	m.ID = uuid.New()
+	m.SetDirector("Alex Cox (Director)")
+	if err := dt.Upsert(ctx, m); err != nil {
+		t.Fatalf("DefaultTransactor.Upsert() update error = %v", err)
+	}
+	if m.ID != id {
+		t.Errorf("DefaultTransactor.Upsert() ID = %s, want %s", m.ID, id)
+	}
+
+	got, err := NewDefaultSelector(defaultDatastore).FindByID(ctx, m.ExternalID)
+	if err != nil {
+		t.Fatalf("DefaultSelector.FindByID() error = %v", err)
+	}
+	if got.Director != m.Director {
+		t.Errorf("DefaultTransactor.Upsert() Director = %s, want %s", got.Director, m.Director)
+	}
+}
+
 func TestDefaultTransactor_Delete(t *testing.T) {
 	type fields struct {
 		datastorer datastore.Datastorer
@@ -167,10 +217,15 @@ func TestDefaultTransactor_Delete(t *testing.T) {
 			dt := DefaultTransactor{
 				datastorer: tt.fields.datastorer,
 			}
-			if err := dt.Delete(tt.args.ctx, tt.args.m); (err != nil) != tt.wantErr {
+			err := dt.Delete(tt.args.ctx, tt.args.m)
+			if (err != nil) != tt.wantErr {
 				t.Logf("%s yieled dt.Delete error = %v", tt.name, err)
 				t.Errorf("DefaultTransactor.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			// an unknown ID should be reported as NotExist
+			if tt.wantErr && !errs.KindIs(errs.NotExist, err) {
+				t.Errorf("DefaultTransactor.Delete() error = %v, want Kind %s", err, errs.NotExist)
+			}
 		})
 	}
 }
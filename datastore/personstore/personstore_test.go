@@ -0,0 +1,99 @@
+package personstore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// newPerson returns a Person with a random external ID
+func newPerson(t *testing.T) *person.Person {
+	t.Helper()
+
+	extlID, err := random.DefaultStringGenerator{}.NewExternalID()
+	if err != nil {
+		t.Fatalf("random.NewExternalID() error = %v", err)
+	}
+	p, err := person.NewPerson(uuid.New(), extlID, usertest.NewUser(t), clock.DefaultClock{})
+	if err != nil {
+		t.Fatalf("person.NewPerson() error = %v", err)
+	}
+
+	return p.SetName("Alex Cox")
+}
+
+func TestDefaultTransactor(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	t.Cleanup(cleanup)
+	transactor := NewDefaultTransactor(ds)
+	selector := NewDefaultSelector(ds)
+
+	p := newPerson(t)
+	c.Assert(transactor.Create(ctx, p), qt.IsNil)
+
+	got, err := selector.FindByID(ctx, p.ExternalID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Name, qt.Equals, "Alex Cox")
+
+	p.SetName("Alex Cox Jr.").SetUpdateTime(clock.DefaultClock{})
+	c.Assert(transactor.Update(ctx, p), qt.IsNil)
+	got, err = selector.FindByID(ctx, p.ExternalID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Name, qt.Equals, "Alex Cox Jr.")
+
+	c.Assert(transactor.Delete(ctx, p), qt.IsNil)
+	_, err = selector.FindByID(ctx, p.ExternalID)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+	c.Assert(errs.KindIs(errs.NotExist, transactor.Delete(ctx, p)), qt.IsTrue)
+}
+
+func TestDefaultTransactor_AddMovie(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	t.Cleanup(cleanup)
+	transactor := NewDefaultTransactor(ds)
+	selector := NewDefaultSelector(ds)
+
+	m, movieCleanup := moviestore.NewMovieDBHelper(t, ctx, ds)
+	t.Cleanup(movieCleanup)
+
+	p := newPerson(t)
+	c.Assert(transactor.Create(ctx, p), qt.IsNil)
+
+	// no movies are linked yet
+	movies, err := selector.FindMovies(ctx, p)
+	c.Assert(err, qt.IsNil)
+	c.Assert(movies, qt.HasLen, 0)
+
+	c.Assert(transactor.AddMovie(ctx, p, m.ExternalID), qt.IsNil)
+	c.Assert(errs.KindIs(errs.NotExist, transactor.AddMovie(ctx, p, "notAMovie")), qt.IsTrue)
+
+	movies, err = selector.FindMovies(ctx, p)
+	c.Assert(err, qt.IsNil)
+	c.Assert(movies, qt.HasLen, 1)
+	c.Assert(movies[0].ExternalID, qt.Equals, m.ExternalID)
+
+	// deleting the person keeps the movie
+	c.Assert(transactor.Delete(ctx, p), qt.IsNil)
+	_, err = moviestore.NewDefaultSelector(ds).FindByID(ctx, m.ExternalID)
+	c.Assert(err, qt.IsNil)
+}
@@ -0,0 +1,112 @@
+// Package personstoretest provides testing helper functions for the
+// personstore package
+package personstoretest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// NewPerson returns the Person found by the MockSelector
+func NewPerson(t testing.TB) *person.Person {
+	t.Helper()
+
+	// get test user
+	u := usertest.NewUser(t)
+
+	// mock create/update timestamp
+	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
+
+	return &person.Person{
+		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Name:       "Alex Cox",
+		CreateUser: u,
+		CreateTime: cuTime,
+		UpdateUser: u,
+		UpdateTime: cuTime,
+	}
+}
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t testing.TB) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the personstore.Transactor
+// interface
+type MockTransactor struct {
+	t testing.TB
+}
+
+// Create mocks creating a person. Create never returns an error
+func (mt MockTransactor) Create(ctx context.Context, p *person.Person) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// Update mocks updating a person. Update never returns an error
+func (mt MockTransactor) Update(ctx context.Context, p *person.Person) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// Delete mocks deleting a person. Delete never returns an error
+func (mt MockTransactor) Delete(ctx context.Context, p *person.Person) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// AddMovie mocks setting a person as the director of a movie. AddMovie
+// never returns an error
+func (mt MockTransactor) AddMovie(ctx context.Context, p *person.Person, movieExtlID string) error {
+	mt.t.Helper()
+
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t testing.TB) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the personstore.Selector
+// interface
+type MockSelector struct {
+	t testing.TB
+}
+
+// FindByID mocks finding a person by External ID, always
+// returning the Person from NewPerson
+func (ms MockSelector) FindByID(ctx context.Context, extlID string) (*person.Person, error) {
+	ms.t.Helper()
+
+	return NewPerson(ms.t), nil
+}
+
+// FindAll mocks finding all people, always returning the Person
+// from NewPerson
+func (ms MockSelector) FindAll(ctx context.Context) ([]*person.Person, error) {
+	ms.t.Helper()
+
+	return []*person.Person{NewPerson(ms.t)}, nil
+}
+
+// FindMovies mocks finding the movies directed by a person, always
+// returning the movies from the moviestoretest MockSelector
+func (ms MockSelector) FindMovies(ctx context.Context, p *person.Person) ([]*movie.Movie, error) {
+	ms.t.Helper()
+
+	return moviestoretest.NewMockSelector(ms.t).FindAll(ctx)
+}
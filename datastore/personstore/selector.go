@@ -0,0 +1,171 @@
+// Package personstore performs all DML and select operations for a
+// person. A person is linked to the movies they directed by the
+// director_id foreign key of the movie table.
+package personstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/person"
+)
+
+// Selector reads records from the db
+type Selector interface {
+	FindByID(context.Context, string) (*person.Person, error)
+	FindAll(context.Context) ([]*person.Person, error)
+	FindMovies(context.Context, *person.Person) ([]*movie.Movie, error)
+}
+
+// NewDefaultSelector is an initializer for DefaultSelector
+func NewDefaultSelector(ds datastore.Datastorer) DefaultSelector {
+	return DefaultSelector{ds}
+}
+
+// DefaultSelector is the database implementation for READ operations for a person
+type DefaultSelector struct {
+	datastore.Datastorer
+}
+
+// FindByID returns a Person with the given external ID
+func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*person.Person, error) {
+	var p *person.Person
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		p, err = findByID(ctx, tx, extlID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// findByID returns the Person with the given external ID using tx
+func findByID(ctx context.Context, tx *sql.Tx, extlID string) (*person.Person, error) {
+	p := new(person.Person)
+	err := repo.Get(ctx, tx, scanPerson(p),
+		`select person_id,
+				extl_id,
+				name,
+				create_username,
+				create_timestamp,
+				update_username,
+				update_timestamp
+		   from demo.person
+		  where extl_id = $1`, extlID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// scanPerson returns a repo.ScanFunc scanning the columns selected
+// for a Person into p
+func scanPerson(p *person.Person) repo.ScanFunc {
+	return func(row repo.Scanner) error {
+		return row.Scan(
+			&p.ID,
+			&p.ExternalID,
+			&p.Name,
+			&p.CreateUser.Email,
+			&p.CreateTime,
+			&p.UpdateUser.Email,
+			&p.UpdateTime)
+	}
+}
+
+// FindAll returns all People, ordered by name
+func (d DefaultSelector) FindAll(ctx context.Context) ([]*person.Person, error) {
+	var s []*person.Person
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = findAll(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// findAll returns all People using tx
+func findAll(ctx context.Context, tx *sql.Tx) ([]*person.Person, error) {
+	s := make([]*person.Person, 0)
+	err := repo.List(ctx, tx, func(row repo.Scanner) error {
+		p := new(person.Person)
+		if err := scanPerson(p)(row); err != nil {
+			return err
+		}
+		s = append(s, p)
+		return nil
+	},
+		`select person_id,
+				extl_id,
+				name,
+				create_username,
+				create_timestamp,
+				update_username,
+				update_timestamp
+		   from demo.person
+		  order by name`)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// FindMovies returns the Movies directed by the Person, ordered by
+// title
+func (d DefaultSelector) FindMovies(ctx context.Context, p *person.Person) ([]*movie.Movie, error) {
+	var s []*movie.Movie
+	err := datastore.WithTxOptions(ctx, d.Datastorer, datastore.ReadOnly, func(tx *sql.Tx) (err error) {
+		s, err = findMovies(ctx, tx, p)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// findMovies returns the Movies directed by the Person using tx
+func findMovies(ctx context.Context, tx *sql.Tx, p *person.Person) ([]*movie.Movie, error) {
+	s := make([]*movie.Movie, 0)
+	err := repo.List(ctx, tx, func(row repo.Scanner) error {
+		m := new(movie.Movie)
+		err := row.Scan(moviestore.ScanDest(m)...)
+		if err != nil {
+			return err
+		}
+		s = append(s, m)
+		return nil
+	},
+		`select m.movie_id,
+				m.extl_id,
+				m.title,
+				m.rated,
+				m.released,
+				m.run_time,
+				m.director,
+				m.writer,
+				m.create_username,
+				m.create_timestamp,
+				m.update_username,
+				m.update_timestamp
+		   from demo.movie m
+		  where m.director_id = $1
+		  order by m.title`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,140 @@
+package personstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/person"
+)
+
+// Transactor performs DML actions against the DB
+type Transactor interface {
+	Create(ctx context.Context, p *person.Person) error
+	Update(ctx context.Context, p *person.Person) error
+	Delete(ctx context.Context, p *person.Person) error
+	AddMovie(ctx context.Context, p *person.Person, movieExtlID string) error
+}
+
+// NewDefaultTransactor is an initializer for DefaultTransactor
+func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
+	return DefaultTransactor{datastorer: ds, TxOptions: datastore.ReadWrite}
+}
+
+// DefaultTransactor is the default database implementation
+// for DML operations for a person
+type DefaultTransactor struct {
+	datastorer datastore.Datastorer
+	// TxOptions are the options of the transaction each operation
+	// runs in. By default, an operation which fails with a
+	// serialization failure or deadlock is retried. Set to
+	// datastore.Serializable to run writes at the SERIALIZABLE
+	// isolation level.
+	TxOptions datastore.TxOptions
+}
+
+// Create inserts a record in the person table
+func (dt DefaultTransactor) Create(ctx context.Context, p *person.Person) error {
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+	insert into demo.person (person_id, extl_id, name, create_username,
+		   create_timestamp, update_username, update_timestamp)
+	values ($1, $2, $3, $4, $5, $6, $7)`,
+			p.ID,               //$1
+			p.ExternalID,       //$2
+			p.Name,             //$3
+			p.CreateUser.Email, //$4
+			p.CreateTime,       //$5
+			p.UpdateUser.Email, //$6
+			p.UpdateTime)       //$7
+
+		return err
+	})
+}
+
+// Update updates a record in the database using the external ID of
+// the Person
+func (dt DefaultTransactor) Update(ctx context.Context, p *person.Person) error {
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+	update demo.person
+	   set name = $1,
+		   update_username = $2,
+		   update_timestamp = $3
+	 where extl_id = $4
+returning person_id, create_username, create_timestamp`,
+			p.Name,             //$1
+			p.UpdateUser.Email, //$2
+			p.UpdateTime,       //$3
+			p.ExternalID)       //$4
+
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Iterate through the returned record(s), counting each row
+		// returned by the RETURNING clause
+		var rowsAffected int
+		for rows.Next() {
+			if err := rows.Scan(&p.ID, &p.CreateUser.Email, &p.CreateTime); err != nil {
+				return err
+			}
+			rowsAffected++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return errs.E(errs.NotExist, "No record found for given ID")
+		} else if rowsAffected > 1 {
+			return errors.New("Too Many Rows Updated")
+		}
+
+		return nil
+	})
+}
+
+// Delete removes the Person record from the table. The movies the
+// Person directed are kept, the director_id foreign key is set to
+// null by the database.
+func (dt DefaultTransactor) Delete(ctx context.Context, p *person.Person) error {
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		// Only 1 row should be deleted
+		return repo.ExecOne(ctx, tx,
+			`DELETE from demo.person
+		        WHERE person_id = $1`, p.ID)
+	})
+}
+
+// AddMovie sets the Person as the director of the Movie with the
+// external ID movieExtlID, replacing any director already set
+func (dt DefaultTransactor) AddMovie(ctx context.Context, p *person.Person, movieExtlID string) error {
+	return datastore.WithTxOptions(ctx, dt.datastorer, dt.TxOptions, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`update demo.movie
+			    set director_id = $1
+			  where extl_id = $2`, p.ID, movieExtlID)
+
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return errs.E(errs.NotExist, "No movie found for given ID")
+		} else if rowsAffected > 1 {
+			return errors.New("Too Many Rows Updated")
+		}
+
+		return nil
+	})
+}
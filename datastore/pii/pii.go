@@ -0,0 +1,171 @@
+// Package pii encrypts the personally identifiable information (PII)
+// held in database columns, e.g. a user's email and name, so it is
+// not readable from the database, its backups or its logs. The
+// stores encrypt the values they write and decrypt the values they
+// read using a Cipher, so the layers above only ever see plain text.
+//
+// Values are encrypted using AES-256-GCM with a key held outside the
+// database, e.g. in Secret Manager (see ParseKey). An encrypted value
+// is stored as text prefixed with its format version, so values
+// written before encryption was enabled can still be read.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// KeySize is the size in bytes of the key used to encrypt PII
+const KeySize int = 32
+
+// prefix marks a value encrypted by AESGCMCipher, followed by the
+// base64 encoded nonce and sealed value
+const prefix string = "enc:v1:"
+
+// Cipher encrypts and decrypts the values of PII columns and hashes
+// them, so a row can be found by a value without decrypting every row
+type Cipher interface {
+	// Encrypt returns the value to store for plaintext
+	Encrypt(plaintext string) (string, error)
+	// Decrypt returns the plain text of a stored value
+	Decrypt(value string) (string, error)
+	// Hash returns a hash of s to store and search by instead of s
+	Hash(s string) string
+}
+
+// ParseKey decodes a base64 encoded key of KeySize bytes, e.g. as
+// generated by: openssl rand -base64 32
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errs.E(errs.Validation, errors.Wrap(err, "pii key is not base64 encoded"))
+	}
+	if len(key) != KeySize {
+		return nil, errs.E(errs.Validation, errors.Errorf("pii key must be %d bytes, got %d", KeySize, len(key)))
+	}
+	return key, nil
+}
+
+// NewCipher returns an AESGCMCipher using the base64 encoded key, or
+// a PlainCipher if key is empty and PII is not to be encrypted
+func NewCipher(key string) (Cipher, error) {
+	if strings.TrimSpace(key) == "" {
+		return PlainCipher{}, nil
+	}
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewAESGCMCipher(k)
+}
+
+// NewAESGCMCipher is an initializer for AESGCMCipher. key must be
+// KeySize bytes.
+func NewAESGCMCipher(key []byte) (AESGCMCipher, error) {
+	if len(key) != KeySize {
+		return AESGCMCipher{}, errs.E(errs.Validation, errors.Errorf("pii key must be %d bytes, got %d", KeySize, len(key)))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return AESGCMCipher{}, errs.E(errs.Internal, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return AESGCMCipher{}, errs.E(errs.Internal, err)
+	}
+
+	// the key for hashing is derived from the key, so the hashes do
+	// not reveal anything about the key used for encryption
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("pii hash key"))
+
+	return AESGCMCipher{aead: aead, hashKey: mac.Sum(nil)}, nil
+}
+
+// AESGCMCipher encrypts values using AES-256-GCM with a random nonce,
+// so the same value is stored differently each time, and hashes them
+// using HMAC-SHA256
+type AESGCMCipher struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// Encrypt encrypts plaintext. An empty string is stored as is.
+func (c AESGCMCipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errs.E(errs.Internal, err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts value. A value which is not encrypted, e.g. one
+// written before encryption was enabled, is returned as is.
+func (c AESGCMCipher) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(value[len(prefix):])
+	if err != nil {
+		return "", errs.E(errs.Internal, errors.Wrap(err, "pii value is not base64 encoded"))
+	}
+	ns := c.aead.NonceSize()
+	if len(sealed) < ns {
+		return "", errs.E(errs.Internal, errors.New("pii value is too short"))
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:ns], sealed[ns:], nil)
+	if err != nil {
+		return "", errs.E(errs.Internal, errors.Wrap(err, "pii value cannot be decrypted with the key"))
+	}
+
+	return string(plaintext), nil
+}
+
+// Hash returns the hex encoded HMAC-SHA256 of s
+func (c AESGCMCipher) Hash(s string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PlainCipher stores values in plain text, for when no key is
+// configured
+type PlainCipher struct{}
+
+// Encrypt returns plaintext
+func (PlainCipher) Encrypt(plaintext string) (string, error) {
+	return plaintext, nil
+}
+
+// Decrypt returns value, or an error if value was encrypted, as it
+// cannot be read without the key
+func (PlainCipher) Decrypt(value string) (string, error) {
+	if strings.HasPrefix(value, prefix) {
+		return "", errs.E(errs.Internal, errors.New("pii value is encrypted, but no pii key is configured"))
+	}
+	return value, nil
+}
+
+// Hash returns the hex encoded SHA-256 of s
+func (PlainCipher) Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
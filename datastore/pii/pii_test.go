@@ -0,0 +1,126 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// testKey is a base64 encoded key for the tests
+var testKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, KeySize))
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{"valid", testKey + "\n", ""},
+		{"not base64", "not a key!", "pii key is not base64 encoded: .*"},
+		{"too short", base64.StdEncoding.EncodeToString([]byte("short")), "pii key must be 32 bytes, got 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			key, err := ParseKey(tt.key)
+			if tt.wantErr != "" {
+				c.Assert(err, qt.ErrorMatches, tt.wantErr)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(key, qt.HasLen, KeySize)
+		})
+	}
+}
+
+func TestNewCipher(t *testing.T) {
+	c := qt.New(t)
+
+	ci, err := NewCipher("")
+	c.Assert(err, qt.IsNil)
+	_, ok := ci.(PlainCipher)
+	c.Assert(ok, qt.IsTrue)
+
+	ci, err = NewCipher(testKey)
+	c.Assert(err, qt.IsNil)
+	_, ok = ci.(AESGCMCipher)
+	c.Assert(ok, qt.IsTrue)
+
+	_, err = NewCipher("abc")
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestAESGCMCipher(t *testing.T) {
+	c := qt.New(t)
+
+	ci, err := NewCipher(testKey)
+	c.Assert(err, qt.IsNil)
+
+	enc, err := ci.Encrypt("otto.maddox711@gmail.com")
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.HasPrefix(enc, prefix), qt.IsTrue)
+	c.Assert(enc, qt.Not(qt.Contains), "otto")
+
+	// the nonce is random, so a value is encrypted differently
+	// each time
+	enc2, err := ci.Encrypt("otto.maddox711@gmail.com")
+	c.Assert(err, qt.IsNil)
+	c.Assert(enc2, qt.Not(qt.Equals), enc)
+
+	got, err := ci.Decrypt(enc)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "otto.maddox711@gmail.com")
+
+	// empty and plain text values, e.g. written before encryption
+	// was enabled, are read as is
+	enc, err = ci.Encrypt("")
+	c.Assert(err, qt.IsNil)
+	c.Assert(enc, qt.Equals, "")
+	got, err = ci.Decrypt("Otto")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "Otto")
+
+	// a value which was tampered with or encrypted with another key
+	// cannot be decrypted
+	enc, err = ci.Encrypt("Otto")
+	c.Assert(err, qt.IsNil)
+	other, err := NewAESGCMCipher(bytes.Repeat([]byte{8}, KeySize))
+	c.Assert(err, qt.IsNil)
+	_, err = other.Decrypt(enc)
+	c.Assert(err, qt.ErrorMatches, "pii value cannot be decrypted with the key: .*")
+	_, err = ci.Decrypt(prefix + "AAAA")
+	c.Assert(err, qt.ErrorMatches, "pii value is too short")
+
+	// hashes are stable for a key and differ between keys
+	c.Assert(ci.Hash("otto"), qt.Equals, ci.Hash("otto"))
+	c.Assert(ci.Hash("otto"), qt.Not(qt.Equals), ci.Hash("otta"))
+	c.Assert(ci.Hash("otto"), qt.Not(qt.Equals), other.Hash("otto"))
+	c.Assert(ci.Hash("otto"), qt.HasLen, 64)
+}
+
+func TestPlainCipher(t *testing.T) {
+	c := qt.New(t)
+
+	var ci Cipher = PlainCipher{}
+
+	enc, err := ci.Encrypt("Otto")
+	c.Assert(err, qt.IsNil)
+	c.Assert(enc, qt.Equals, "Otto")
+	got, err := ci.Decrypt(enc)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, "Otto")
+
+	// an encrypted value cannot be read without the key
+	aes, err := NewCipher(testKey)
+	c.Assert(err, qt.IsNil)
+	enc, err = aes.Encrypt("Otto")
+	c.Assert(err, qt.IsNil)
+	_, err = ci.Decrypt(enc)
+	c.Assert(err, qt.ErrorMatches, "pii value is encrypted, but no pii key is configured")
+
+	c.Assert(ci.Hash("otto"), qt.HasLen, 64)
+}
@@ -0,0 +1,27 @@
+// Package pingstoretest provides testing helper functions for the
+// pingstore package
+package pingstoretest
+
+import (
+	"context"
+	"testing"
+)
+
+// NewMockPinger is an initializer for MockPinger
+func NewMockPinger(t testing.TB) MockPinger {
+	return MockPinger{t: t}
+}
+
+// MockPinger is a mock which satisfies the pingstore.Pinger
+// interface
+type MockPinger struct {
+	t testing.TB
+}
+
+// PingDB mocks pinging the database. PingDB never returns an error,
+// thus the database is always up
+func (m MockPinger) PingDB(ctx context.Context) error {
+	m.t.Helper()
+
+	return nil
+}
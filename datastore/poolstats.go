@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+// PoolStatsConfig configures how database connection pool
+// statistics are reported
+type PoolStatsConfig struct {
+	// Interval is how often pool statistics are recorded
+	Interval time.Duration
+	// WaitThreshold is the total time spent waiting for a
+	// connection within an Interval which, when exceeded, is
+	// logged as a warning. Zero disables the warning.
+	WaitThreshold time.Duration
+}
+
+var (
+	dbMaxOpenConnections = metrics.NewGauge("go_api_basic_db_max_open_connections",
+		"Maximum number of open connections to the database.")
+	dbOpenConnections = metrics.NewGauge("go_api_basic_db_open_connections",
+		"The number of established connections both in use and idle.")
+	dbInUseConnections = metrics.NewGauge("go_api_basic_db_in_use_connections",
+		"The number of connections currently in use.")
+	dbIdleConnections = metrics.NewGauge("go_api_basic_db_idle_connections",
+		"The number of idle connections.")
+	dbWaitCount = metrics.NewGauge("go_api_basic_db_wait_count",
+		"The total number of connections waited for.")
+	dbWaitDuration = metrics.NewGauge("go_api_basic_db_wait_duration_seconds",
+		"The total time blocked waiting for a new connection.")
+)
+
+// ReportPoolStats records the sql.DBStats for db as gauges every
+// cfg.Interval and logs a warning when the time spent waiting for
+// a connection during the interval exceeds cfg.WaitThreshold, which
+// is a sign the connection pool is exhausted. ReportPoolStats blocks
+// until the context is cancelled.
+func ReportPoolStats(ctx context.Context, db *sql.DB, cfg PoolStatsConfig, lgr zerolog.Logger) {
+	if cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	prev := db.Stats()
+	recordPoolStats(prev)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := db.Stats()
+			recordPoolStats(s)
+
+			waited := s.WaitDuration - prev.WaitDuration
+			if cfg.WaitThreshold > 0 && waited > cfg.WaitThreshold {
+				lgr.Warn().
+					Dur("wait_duration", waited).
+					Int64("wait_count", s.WaitCount-prev.WaitCount).
+					Int("open_connections", s.OpenConnections).
+					Int("in_use", s.InUse).
+					Int("max_open_connections", s.MaxOpenConnections).
+					Msgf("database connection wait exceeded %s, the connection pool may be exhausted", cfg.WaitThreshold)
+			}
+			prev = s
+		}
+	}
+}
+
+// recordPoolStats sets the pool gauges from s
+func recordPoolStats(s sql.DBStats) {
+	dbMaxOpenConnections.Set(float64(s.MaxOpenConnections))
+	dbOpenConnections.Set(float64(s.OpenConnections))
+	dbInUseConnections.Set(float64(s.InUse))
+	dbIdleConnections.Set(float64(s.Idle))
+	dbWaitCount.Set(float64(s.WaitCount))
+	dbWaitDuration.Set(s.WaitDuration.Seconds())
+}
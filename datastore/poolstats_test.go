@@ -0,0 +1,29 @@
+package datastore
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func Test_recordPoolStats(t *testing.T) {
+	c := qt.New(t)
+
+	recordPoolStats(sql.DBStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    4,
+		InUse:              3,
+		Idle:               1,
+		WaitCount:          12,
+		WaitDuration:       1500 * time.Millisecond,
+	})
+
+	c.Assert(dbMaxOpenConnections.Value(), qt.Equals, float64(10))
+	c.Assert(dbOpenConnections.Value(), qt.Equals, float64(4))
+	c.Assert(dbInUseConnections.Value(), qt.Equals, float64(3))
+	c.Assert(dbIdleConnections.Value(), qt.Equals, float64(1))
+	c.Assert(dbWaitCount.Value(), qt.Equals, float64(12))
+	c.Assert(dbWaitDuration.Value(), qt.Equals, 1.5)
+}
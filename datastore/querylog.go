@@ -0,0 +1,220 @@
+package datastore
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// logStatements is set to 1 when SQL statement logging is enabled
+var logStatements int32
+
+// LogStatementsGlobal enables or disables debug logging of SQL
+// statements and their (redacted) bind parameters for all
+// connections opened after it is called. Statements are logged at
+// debug level using the logger from the request context, so the
+// request ID is included, falling back to the logger given to NewDB.
+func LogStatementsGlobal(b bool) {
+	var v int32
+	if b {
+		v = 1
+	}
+	atomic.StoreInt32(&logStatements, v)
+}
+
+// statementLogging reports whether SQL statement logging is enabled
+func statementLogging() bool {
+	return atomic.LoadInt32(&logStatements) == 1
+}
+
+// loggingConn decorates a driver.Conn and logs each statement
+// executed through it
+type loggingConn struct {
+	driver.Conn
+	logger zerolog.Logger
+}
+
+// log writes the statement, redacted bind parameters, duration and
+// error (if any) at debug level
+func (c loggingConn) log(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	lgr := zerolog.Ctx(ctx)
+	if lgr.GetLevel() == zerolog.Disabled {
+		lgr = &c.logger
+	}
+
+	e := lgr.Debug()
+	if e == nil {
+		return
+	}
+	e.Str("sql", compactSQL(query)).
+		Strs("args", redactArgs(args)).
+		Dur("duration", time.Since(start))
+	if err != nil {
+		e.Err(err)
+	}
+	e.Msg("sql statement")
+}
+
+// PrepareContext prepares the statement using the underlying
+// connection and returns a Stmt which logs on execution
+func (c loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return loggingStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+// BeginTx starts a transaction using the underlying connection
+func (c loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// QueryContext executes a query using the underlying connection
+// and logs the statement
+func (c loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log(ctx, query, args, start, err)
+	}
+	return rows, err
+}
+
+// ExecContext executes a statement using the underlying connection
+// and logs the statement
+func (c loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	x, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := x.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.log(ctx, query, args, start, err)
+	}
+	return result, err
+}
+
+// Ping pings the underlying connection
+func (c loggingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// loggingStmt decorates a driver.Stmt and logs each execution
+type loggingStmt struct {
+	driver.Stmt
+	conn  loggingConn
+	query string
+}
+
+// ExecContext executes the prepared statement and logs it
+func (s loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var (
+		result driver.Result
+		err    error
+	)
+	if x, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = x.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	s.conn.log(ctx, s.query, args, start, err)
+
+	return result, err
+}
+
+// QueryContext executes the prepared query and logs it
+func (s loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	s.conn.log(ctx, s.query, args, start, err)
+
+	return rows, err
+}
+
+// namedValuesToValues converts named values to ordinal values for
+// drivers which do not support named parameters
+func namedValuesToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, fmt.Errorf("datastore: driver does not support the use of Named Parameters")
+		}
+		values[i] = nv.Value
+	}
+	return values, nil
+}
+
+// redactArgs formats bind parameters for logging. String and byte
+// values (which may hold personal data, e.g. usernames) are replaced
+// with their length, all other values are logged as is.
+func redactArgs(args []driver.NamedValue) []string {
+	s := make([]string, len(args))
+	for i, a := range args {
+		var v string
+		switch val := a.Value.(type) {
+		case nil:
+			v = "NULL"
+		case string:
+			v = fmt.Sprintf("[redacted string len=%d]", len(val))
+		case []byte:
+			v = fmt.Sprintf("[redacted bytes len=%d]", len(val))
+		case time.Time:
+			v = val.Format(time.RFC3339Nano)
+		default:
+			v = fmt.Sprintf("%v", val)
+		}
+		s[i] = fmt.Sprintf("$%d=%s", a.Ordinal, v)
+	}
+	return s
+}
+
+// compactSQL collapses whitespace in a statement so it is logged on
+// a single line
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,58 @@
+package datastore
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func Test_redactArgs(t *testing.T) {
+	c := qt.New(t)
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: "Repo Man"},
+		{Ordinal: 2, Value: int64(1984)},
+		{Ordinal: 3, Value: []byte("secret")},
+		{Ordinal: 4, Value: nil},
+		{Ordinal: 5, Value: ts},
+		{Ordinal: 6, Value: true},
+	}
+
+	want := []string{
+		"$1=[redacted string len=8]",
+		"$2=1984",
+		"$3=[redacted bytes len=6]",
+		"$4=NULL",
+		"$5=2020-01-02T03:04:05Z",
+		"$6=true",
+	}
+
+	c.Assert(redactArgs(args), qt.DeepEquals, want)
+}
+
+func Test_compactSQL(t *testing.T) {
+	c := qt.New(t)
+
+	query := `select movie_id,
+       title
+  from demo.movie
+ where extl_id = $1`
+
+	c.Assert(compactSQL(query), qt.Equals, "select movie_id, title from demo.movie where extl_id = $1")
+}
+
+func TestLogStatementsGlobal(t *testing.T) {
+	c := qt.New(t)
+
+	defer LogStatementsGlobal(false)
+
+	LogStatementsGlobal(true)
+	c.Assert(statementLogging(), qt.IsTrue)
+
+	LogStatementsGlobal(false)
+	c.Assert(statementLogging(), qt.IsFalse)
+}
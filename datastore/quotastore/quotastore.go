@@ -0,0 +1,93 @@
+// Package quotastore persists the request counts used to track
+// user quotas
+package quotastore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+// NewDefaultCounter is an initializer for DefaultCounter
+func NewDefaultCounter(ds datastore.Datastorer) DefaultCounter {
+	return DefaultCounter{ds}
+}
+
+// DefaultCounter is the database implementation of quota.Counter
+type DefaultCounter struct {
+	datastore.Datastorer
+}
+
+// Increment adds one request for the user to the day and month
+// holding at and returns the new counts. The counters are created
+// or incremented in a single statement, so concurrent requests are
+// counted correctly.
+func (d DefaultCounter) Increment(ctx context.Context, username string, at time.Time) (quota.Counts, error) {
+	rows, err := d.DB().QueryContext(ctx,
+		`insert into demo.user_request_count (username, window_type, window_start, request_count)
+		 values ($1, $2, $3, 1), ($1, $4, $5, 1)
+		 on conflict (username, window_type, window_start)
+		 do update set request_count = demo.user_request_count.request_count + 1
+		 returning window_type, request_count`,
+		username,
+		quota.Day, quota.Day.Start(at),
+		quota.Month, quota.Month.Start(at))
+	if err != nil {
+		return quota.Counts{}, errs.E(errs.Database, err)
+	}
+
+	return scanCounts(rows)
+}
+
+// Counts returns the counts for the user for the day and month
+// holding at
+func (d DefaultCounter) Counts(ctx context.Context, username string, at time.Time) (quota.Counts, error) {
+	rows, err := d.DB().QueryContext(ctx,
+		`select window_type, request_count
+		   from demo.user_request_count
+		  where username = $1
+		    and ((window_type = $2 and window_start = $3)
+		     or (window_type = $4 and window_start = $5))`,
+		username,
+		quota.Day, quota.Day.Start(at),
+		quota.Month, quota.Month.Start(at))
+	if err != nil {
+		return quota.Counts{}, errs.E(errs.Database, err)
+	}
+
+	return scanCounts(rows)
+}
+
+// scanCounts scans window_type, request_count rows into Counts
+func scanCounts(rows *sql.Rows) (quota.Counts, error) {
+	defer rows.Close()
+
+	var c quota.Counts
+	for rows.Next() {
+		var (
+			w     string
+			count int64
+		)
+		if err := rows.Scan(&w, &count); err != nil {
+			return quota.Counts{}, errs.E(errs.Database, err)
+		}
+
+		switch quota.Window(w) {
+		case quota.Day:
+			c.Daily = count
+		case quota.Month:
+			c.Monthly = count
+		}
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	if err := rows.Err(); err != nil {
+		return quota.Counts{}, errs.E(errs.Database, err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,57 @@
+package quotastore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+func TestDefaultCounter(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// use a unique username so the test is independent of any
+	// existing counts
+	username := uuid.New().String() + "@example.com"
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.user_request_count where username = $1`, username)
+		if err != nil {
+			t.Errorf("delete user_request_count error = %v", err)
+		}
+		cleanup()
+	})
+
+	d := NewDefaultCounter(ds)
+	at := time.Now()
+
+	got, err := d.Counts(ctx, username, at)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{})
+
+	for i := int64(1); i <= 2; i++ {
+		got, err = d.Increment(ctx, username, at)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.Equals, quota.Counts{Daily: i, Monthly: i})
+	}
+
+	// a request the next month starts new day and month windows
+	got, err = d.Increment(ctx, username, quota.Month.End(at))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{Daily: 1, Monthly: 1})
+
+	got, err = d.Counts(ctx, username, at)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, quota.Counts{Daily: 2, Monthly: 2})
+}
@@ -0,0 +1,188 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// replayPoll is how often the replica is checked while a read waits
+// for it to replay the writes the read must see
+const replayPoll = 10 * time.Millisecond
+
+// NewReplicaDatastore is an initializer for ReplicaDatastore. A read
+// with a consistency token the replica has not replayed is held for
+// up to wait before it is sent to primary instead. A nil replica
+// sends every transaction to primary.
+func NewReplicaDatastore(primary Datastorer, replica *sql.DB, wait time.Duration) ReplicaDatastore {
+	return ReplicaDatastore{
+		Datastorer: primary,
+		replica:    replica,
+		wait:       wait,
+		replayed:   replicaReplayed(replica),
+	}
+}
+
+// ReplicaDatastore is a Datastorer with a read replica of the
+// default database. The read-only transactions of requests which are
+// not made for a tenant are begun in the replica, any other in
+// primary (e.g. a TenantDatastore), as are the reads a write depends
+// on (see requestinfo.WithPrimary).
+//
+// A client reads its own writes using consistency tokens (see
+// requestinfo.Consistency): after each write committed for a request
+// with consistency tokens, the position of the primary's write-ahead
+// log is recorded as the token of the write. A read sent with a token
+// is held until the replica has replayed the log up to the token, or
+// sent to primary if it has not within the wait.
+type ReplicaDatastore struct {
+	Datastorer
+	replica *sql.DB
+	wait    time.Duration
+	// replayed reports whether the replica has replayed the
+	// write-ahead log up to lsn
+	replayed func(ctx context.Context, lsn string) (bool, error)
+}
+
+// BeginTx starts a sql.Tx in the replica or primary, see
+// ReplicaDatastore
+func (ds ReplicaDatastore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	ok, err := ds.useReplica(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return ds.Datastorer.BeginTx(ctx, opts)
+	}
+
+	tx, err := ds.replica.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return tx, nil
+}
+
+// WriteToken returns the consistency token of the writes committed
+// so far for the request of ctx: the current position of the
+// primary's write-ahead log. A request made for a tenant has no
+// replica, and so no token.
+func (ds ReplicaDatastore) WriteToken(ctx context.Context) (string, error) {
+	if ds.replica == nil {
+		return "", nil
+	}
+	if _, ok := requestinfo.TenantFromContext(ctx); ok {
+		return "", nil
+	}
+
+	var lsn string
+	err := ds.Datastorer.DB().QueryRowContext(ctx, `select pg_current_wal_lsn()::text`).Scan(&lsn)
+	if err != nil {
+		return "", errs.E(errs.Database, err)
+	}
+
+	return lsn, nil
+}
+
+// useReplica reports whether a transaction begun with opts for the
+// request of ctx is begun in the replica
+func (ds ReplicaDatastore) useReplica(ctx context.Context, opts *sql.TxOptions) (bool, error) {
+	if ds.replica == nil || opts == nil || !opts.ReadOnly {
+		return false, nil
+	}
+	if _, ok := requestinfo.TenantFromContext(ctx); ok || requestinfo.Primary(ctx) {
+		return false, nil
+	}
+
+	c, ok := requestinfo.ConsistencyFromContext(ctx)
+	if !ok || c.ReadAfter == "" {
+		return true, nil
+	}
+
+	ok, err := ds.waitReplay(ctx, c.ReadAfter)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		zerolog.Ctx(ctx).Debug().Str("consistency_token", c.ReadAfter).Msg("replica behind the consistency token, reading from the primary")
+	}
+
+	return ok, nil
+}
+
+// waitReplay waits up to the wait of ds for the replica to replay
+// the write-ahead log up to lsn and reports whether it has. It
+// returns false if ctx is done first.
+func (ds ReplicaDatastore) waitReplay(ctx context.Context, lsn string) (bool, error) {
+	deadline := time.Now().Add(ds.wait)
+	for {
+		ok, err := ds.replayed(ctx, lsn)
+		if err != nil || ok {
+			return ok, err
+		}
+		if time.Now().Add(replayPoll).After(deadline) {
+			return false, nil
+		}
+
+		t := time.NewTimer(replayPoll)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false, nil
+		case <-t.C:
+		}
+	}
+}
+
+// replicaReplayed returns a func reporting whether replica has
+// replayed the write-ahead log up to an lsn. A replica which is not
+// in recovery, e.g. the primary itself in development, has every
+// write.
+func replicaReplayed(replica *sql.DB) func(ctx context.Context, lsn string) (bool, error) {
+	return func(ctx context.Context, lsn string) (bool, error) {
+		var ok bool
+		err := replica.QueryRowContext(ctx,
+			`select coalesce(pg_last_wal_replay_lsn(), pg_current_wal_lsn()) >= $1::pg_lsn`, lsn).Scan(&ok)
+		if err != nil {
+			return false, errs.E(errs.Database, errors.Wrap(err, "replica replay position not read"))
+		}
+		return ok, nil
+	}
+}
+
+// writeTokener is a Datastorer giving the consistency token of the
+// writes committed for a request, see ReplicaDatastore
+type writeTokener interface {
+	WriteToken(ctx context.Context) (string, error)
+}
+
+// recordWriteToken records the consistency token of the writes
+// committed using ds for the request of ctx, if the request has
+// consistency tokens and ds gives one. The write is committed, so a
+// token which cannot be read is logged rather than failing the
+// request; the client then reads without it.
+func recordWriteToken(ctx context.Context, ds Datastorer) {
+	c, ok := requestinfo.ConsistencyFromContext(ctx)
+	if !ok {
+		return
+	}
+	wt, ok := ds.(writeTokener)
+	if !ok {
+		return
+	}
+
+	token, err := wt.WriteToken(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("consistency token not recorded")
+		return
+	}
+	if token != "" {
+		c.SetWritten(token)
+	}
+}
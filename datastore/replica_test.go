@@ -0,0 +1,123 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestReplicaDatastore_useReplica(t *testing.T) {
+	// sql.Open does not connect, so no database is needed
+	replica, err := sql.Open("postgres", "host=localhost dbname=unused")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = replica.Close() })
+
+	readOnly := &sql.TxOptions{ReadOnly: true}
+	withToken := func(token string) context.Context {
+		return requestinfo.WithConsistency(context.Background(), &requestinfo.Consistency{ReadAfter: token})
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		opts *sql.TxOptions
+		// replayedAfter is the number of checks after which the
+		// replica has replayed the token, never if negative
+		replayedAfter int
+		replayErr     error
+		want          bool
+		wantErr       bool
+	}{
+		{"read", context.Background(), readOnly, 0, nil, true, false},
+		{"write", context.Background(), nil, 0, nil, false, false},
+		{"read write", context.Background(), &sql.TxOptions{}, 0, nil, false, false},
+		{"tenant", requestinfo.WithTenant(context.Background(), "acme"), readOnly, 0, nil, false, false},
+		{"primary", requestinfo.WithPrimary(context.Background()), readOnly, 0, nil, false, false},
+		{"no token", withToken(""), readOnly, -1, nil, true, false},
+		{"token replayed", withToken("0/16B3748"), readOnly, 0, nil, true, false},
+		{"token replayed after waiting", withToken("0/16B3748"), readOnly, 2, nil, true, false},
+		{"token not replayed", withToken("0/16B3748"), readOnly, -1, nil, false, false},
+		{"replica error", withToken("0/16B3748"), readOnly, 0, errors.New("connection refused"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			ds := NewReplicaDatastore(NewDefaultDatastore(nil), replica, 50*time.Millisecond)
+			var checks int
+			ds.replayed = func(ctx context.Context, lsn string) (bool, error) {
+				c.Assert(lsn, qt.Equals, "0/16B3748")
+				checks++
+				if tt.replayErr != nil {
+					return false, errs.E(errs.Database, tt.replayErr)
+				}
+				return tt.replayedAfter >= 0 && checks > tt.replayedAfter, nil
+			}
+
+			got, err := ds.useReplica(tt.ctx, tt.opts)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Database, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+
+	t.Run("no replica", func(t *testing.T) {
+		ds := NewReplicaDatastore(NewDefaultDatastore(nil), nil, time.Second)
+		got, err := ds.useReplica(context.Background(), readOnly)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, got, qt.IsFalse)
+
+		// without a replica there is no token to record
+		token, err := ds.WriteToken(context.Background())
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, token, qt.Equals, "")
+	})
+}
+
+// tokenDatastore is a Datastorer giving a fixed consistency token
+type tokenDatastore struct {
+	DefaultDatastore
+	token string
+	err   error
+}
+
+func (ds tokenDatastore) WriteToken(ctx context.Context) (string, error) {
+	return ds.token, ds.err
+}
+
+func Test_recordWriteToken(t *testing.T) {
+	tests := []struct {
+		name string
+		ds   Datastorer
+		want string
+	}{
+		{"token", tokenDatastore{token: "0/16B37F0"}, "0/16B37F0"},
+		{"no token", tokenDatastore{}, ""},
+		{"error", tokenDatastore{token: "0/16B37F0", err: errors.New("connection refused")}, ""},
+		{"no replica", DefaultDatastore{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			cons := new(requestinfo.Consistency)
+			recordWriteToken(requestinfo.WithConsistency(context.Background(), cons), tt.ds)
+			c.Assert(cons.Written(), qt.Equals, tt.want)
+		})
+	}
+
+	// a request without consistency tokens is not given one
+	recordWriteToken(context.Background(), tokenDatastore{token: "0/16B37F0"})
+}
@@ -0,0 +1,187 @@
+// Package repo has helpers shared by the stores to run statements,
+// scan rows and map database errors to errs.Error, so a new store
+// does not duplicate them.
+//
+// The module targets Go 1.16, which has no type parameters, so
+// instead of Get[T] or List[T] the helpers take a ScanFunc which
+// scans a row into the caller's value, e.g.:
+//
+//	p := new(person.Person)
+//	err := repo.Get(ctx, tx, func(row repo.Scanner) error {
+//		return row.Scan(&p.ID, &p.ExternalID, &p.Name)
+//	}, `select person_id, extl_id, name from demo.person where extl_id = $1`, extlID)
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Querier runs statements. It is implemented by *sql.Tx, *sql.DB and
+// *sql.Conn.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Scanner scans the columns of a row. It is implemented by *sql.Row
+// and *sql.Rows.
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanFunc scans a row, usually into variables it closes over
+type ScanFunc func(row Scanner) error
+
+// notFound is the message of the errs.NotExist error returned when
+// no row is found or changed
+const notFound string = "No record found for given ID"
+
+// Get runs query, which selects a single row, and scans the row
+// using scan. If no row is selected, an errs.NotExist error is
+// returned. Errors from the database are returned as errs.Database
+// errors; an *errs.Error returned by scan is returned as is.
+func Get(ctx context.Context, q Querier, scan ScanFunc, query string, args ...interface{}) error {
+	err := scan(q.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return errs.E(errs.NotExist, notFound)
+	}
+	return dbError(err)
+}
+
+// List runs query and calls scan for each row selected, in order.
+// Errors are returned as by Get, except no rows is not an error.
+func List(ctx context.Context, q Querier, scan ScanFunc, query string, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return dbError(err)
+		}
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Next
+	if err = rows.Err(); err != nil {
+		return errs.E(errs.Database, err)
+	}
+
+	// If the database is being written to ensure to check for Close
+	// errors that may be returned from the driver
+	if err = rows.Close(); err != nil {
+		return errs.E(errs.Database, err)
+	}
+
+	return nil
+}
+
+// Exec runs query and returns the number of rows it changed
+func Exec(ctx context.Context, q Querier, query string, args ...interface{}) (int64, error) {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errs.E(errs.Database, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, errs.E(errs.Database, err)
+	}
+
+	return n, nil
+}
+
+// ExecOne runs query, which must change exactly one row, e.g. an
+// update or delete by ID. If no row is changed, an errs.NotExist
+// error is returned. If more than one row is changed, an
+// errs.Database error is returned, so the transaction running query
+// is rolled back.
+func ExecOne(ctx context.Context, q Querier, query string, args ...interface{}) error {
+	n, err := Exec(ctx, q, query, args...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.E(errs.NotExist, notFound)
+	} else if n > 1 {
+		return errs.E(errs.Database, errors.Errorf("%d rows changed, expected 1", n))
+	}
+
+	return nil
+}
+
+// dbError returns err as an errs.Database error, unless it is nil or
+// already an *errs.Error
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*errs.Error); ok {
+		return err
+	}
+	return errs.E(errs.Database, err)
+}
+
+// String returns a sql.Scanner which scans a nullable text column
+// into s, setting s to "" if the column is NULL
+func String(s *string) sql.Scanner {
+	return nullScanner{scanner: &sql.NullString{}, set: func(v sql.Scanner) {
+		*s = v.(*sql.NullString).String
+	}}
+}
+
+// Int64 returns a sql.Scanner which scans a nullable integer column
+// into i, setting i to 0 if the column is NULL
+func Int64(i *int64) sql.Scanner {
+	return nullScanner{scanner: &sql.NullInt64{}, set: func(v sql.Scanner) {
+		*i = v.(*sql.NullInt64).Int64
+	}}
+}
+
+// Int returns a sql.Scanner which scans a nullable integer column
+// into i, setting i to 0 if the column is NULL
+func Int(i *int) sql.Scanner {
+	return nullScanner{scanner: &sql.NullInt64{}, set: func(v sql.Scanner) {
+		*i = int(v.(*sql.NullInt64).Int64)
+	}}
+}
+
+// Bool returns a sql.Scanner which scans a nullable boolean column
+// into b, setting b to false if the column is NULL
+func Bool(b *bool) sql.Scanner {
+	return nullScanner{scanner: &sql.NullBool{}, set: func(v sql.Scanner) {
+		*b = v.(*sql.NullBool).Bool
+	}}
+}
+
+// Time returns a sql.Scanner which scans a nullable timestamp column
+// into t, setting t to the zero time if the column is NULL
+func Time(t *time.Time) sql.Scanner {
+	return nullScanner{scanner: &sql.NullTime{}, set: func(v sql.Scanner) {
+		*t = v.(*sql.NullTime).Time
+	}}
+}
+
+// nullScanner scans a column using a sql.Null* scanner, then sets
+// the value it scanned, the zero value if NULL, to the destination
+type nullScanner struct {
+	scanner sql.Scanner
+	set     func(sql.Scanner)
+}
+
+// Scan satisfies the sql.Scanner interface
+func (ns nullScanner) Scan(src interface{}) error {
+	if err := ns.scanner.Scan(src); err != nil {
+		return err
+	}
+	ns.set(ns.scanner)
+	return nil
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestGet(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	err := datastore.WithTxOptions(ctx, ds, datastore.ReadOnly, func(tx *sql.Tx) error {
+		var n int
+		scan := func(row Scanner) error { return row.Scan(&n) }
+
+		c.Assert(Get(ctx, tx, scan, `select $1::int`, 42), qt.IsNil)
+		c.Assert(n, qt.Equals, 42)
+
+		// no row is errs.NotExist
+		err := Get(ctx, tx, scan, `select 1 where false`)
+		c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+		// an *errs.Error from scan is returned as is
+		want := errs.E(errs.Validation, errors.New("bad row"))
+		err = Get(ctx, tx, func(row Scanner) error { return want }, `select 1`)
+		c.Assert(err, qt.Equals, want)
+
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+}
+
+func TestList(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	err := datastore.WithTxOptions(ctx, ds, datastore.ReadOnly, func(tx *sql.Tx) error {
+		var got []int
+		err := List(ctx, tx, func(row Scanner) error {
+			var n int
+			if err := row.Scan(&n); err != nil {
+				return err
+			}
+			got = append(got, n)
+			return nil
+		}, `select generate_series(1, $1::int)`, 3)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []int{1, 2, 3})
+
+		// no rows is not an error
+		err = List(ctx, tx, func(row Scanner) error {
+			c.Fatal("no row expected")
+			return nil
+		}, `select 1 where false`)
+		c.Assert(err, qt.IsNil)
+
+		// a scan error is a database error
+		err = List(ctx, tx, func(row Scanner) error {
+			var n int
+			return row.Scan(&n, &n)
+		}, `select 1`)
+		c.Assert(errs.KindIs(errs.Database, err), qt.IsTrue)
+
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+}
+
+func TestExecOne(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	// the changes are made to a temporary table, dropped when the
+	// transaction is rolled back
+	stop := errors.New("rollback")
+	err := datastore.WithTx(ctx, ds, func(tx *sql.Tx) error {
+		_, err := Exec(ctx, tx, `create temporary table repo_test (id int) on commit drop`)
+		c.Assert(err, qt.IsNil)
+		n, err := Exec(ctx, tx, `insert into repo_test values (1), (2), (2)`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, int64(3))
+
+		c.Assert(ExecOne(ctx, tx, `delete from repo_test where id = $1`, 1), qt.IsNil)
+		c.Assert(errs.KindIs(errs.NotExist, ExecOne(ctx, tx, `delete from repo_test where id = $1`, 1)), qt.IsTrue)
+		c.Assert(errs.KindIs(errs.Database, ExecOne(ctx, tx, `delete from repo_test where id = $1`, 2)), qt.IsTrue)
+
+		_, err = Exec(ctx, tx, `delete from no_such_table`)
+		c.Assert(errs.KindIs(errs.Database, err), qt.IsTrue)
+
+		return stop
+	})
+	c.Assert(errors.Is(err, stop), qt.IsTrue)
+}
+
+func TestNullScanners(t *testing.T) {
+	c := qt.New(t)
+
+	s := "set"
+	c.Assert(String(&s).Scan(nil), qt.IsNil)
+	c.Assert(s, qt.Equals, "")
+	c.Assert(String(&s).Scan("Alex Cox"), qt.IsNil)
+	c.Assert(s, qt.Equals, "Alex Cox")
+
+	i := int64(7)
+	c.Assert(Int64(&i).Scan(nil), qt.IsNil)
+	c.Assert(i, qt.Equals, int64(0))
+	c.Assert(Int64(&i).Scan(int64(92)), qt.IsNil)
+	c.Assert(i, qt.Equals, int64(92))
+
+	n := 7
+	c.Assert(Int(&n).Scan(nil), qt.IsNil)
+	c.Assert(n, qt.Equals, 0)
+	c.Assert(Int(&n).Scan(int64(92)), qt.IsNil)
+	c.Assert(n, qt.Equals, 92)
+
+	b := true
+	c.Assert(Bool(&b).Scan(nil), qt.IsNil)
+	c.Assert(b, qt.IsFalse)
+	c.Assert(Bool(&b).Scan(true), qt.IsNil)
+	c.Assert(b, qt.IsTrue)
+
+	tm := time.Now()
+	c.Assert(Time(&tm).Scan(nil), qt.IsNil)
+	c.Assert(tm.IsZero(), qt.IsTrue)
+	want := time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC)
+	c.Assert(Time(&tm).Scan(want), qt.IsNil)
+	c.Assert(tm, qt.Equals, want)
+
+	// a value of the wrong type is an error and leaves the
+	// destination unchanged
+	c.Assert(Int64(&i).Scan("not a number"), qt.Not(qt.IsNil))
+	c.Assert(i, qt.Equals, int64(92))
+}
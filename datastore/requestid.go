@@ -0,0 +1,136 @@
+package datastore
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/lib/pq"
+
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// maxApplicationName is the length of the longest application_name
+// Postgres keeps (NAMEDATALEN - 1), a longer one is truncated
+const maxApplicationName = 63
+
+// requestIDComment returns query prefixed with a comment holding the
+// ID of the request in ctx, e.g.
+//
+//	/* request_id=c0ffee1kmd4kvt4g6pfg */ select ...
+//
+// or query as is if ctx has no request ID. The comment is part of
+// the query text shown in pg_stat_activity and the slow query log,
+// but not of the normalized query of pg_stat_statements.
+func requestIDComment(ctx context.Context, query string) string {
+	id, ok := requestinfo.RequestIDFromContext(ctx)
+	if !ok {
+		return query
+	}
+	// an xid is only lower case letters and digits, so it cannot
+	// end the comment
+	return "/* request_id=" + id + " */ " + query
+}
+
+// requestApplicationName returns the application_name of a
+// transaction begun for the request with the ID id on a connection
+// named appName, e.g.
+//
+//	go-api-basic request_id=c0ffee1kmd4kvt4g6pfg
+//
+// appName is shortened if need be, so the ID is never truncated.
+func requestApplicationName(appName, id string) string {
+	suffix := "request_id=" + id
+	if appName == "" {
+		return suffix
+	}
+	if max := maxApplicationName - len(suffix) - 1; len(appName) > max {
+		appName = appName[:max]
+	}
+	return appName + " " + suffix
+}
+
+// requestIDConn decorates a driver.Conn and prefixes each statement
+// run through it with a comment holding the request ID (see
+// requestIDComment). Prepared statements are not commented, as a
+// prepared statement outlives the request it was prepared for, so
+// the request ID is also set as the application_name of each
+// transaction begun for a request (see requestApplicationName),
+// which covers the statements run from the statement cache.
+type requestIDConn struct {
+	driver.Conn
+	// appName is the application_name of the connection
+	appName string
+}
+
+// PrepareContext prepares the statement using the underlying
+// connection
+func (c requestIDConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx starts a transaction using the underlying connection and,
+// if ctx has a request ID, sets the application_name of the
+// transaction to hold it. SET LOCAL lasts until the transaction ends,
+// so the connection has its own name again once it is back in the
+// pool.
+func (c requestIDConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := requestinfo.RequestIDFromContext(ctx)
+	x, isExecer := c.Conn.(driver.ExecerContext)
+	if !ok || !isExecer {
+		return tx, nil
+	}
+
+	// a statement without arguments is sent with the simple query
+	// protocol, so it adds a single round trip
+	_, err = x.ExecContext(ctx, "set local application_name = "+pq.QuoteLiteral(requestApplicationName(c.appName, id)), nil)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// QueryContext executes the commented query using the underlying
+// connection
+func (c requestIDConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return q.QueryContext(ctx, requestIDComment(ctx, query), args)
+}
+
+// ExecContext executes the commented statement using the underlying
+// connection
+func (c requestIDConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	x, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return x.ExecContext(ctx, requestIDComment(ctx, query), args)
+}
+
+// Ping pings the underlying connection
+func (c requestIDConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
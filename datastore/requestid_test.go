@@ -0,0 +1,132 @@
+package datastore
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog/hlog"
+)
+
+// queryConn is a driver.Conn which records the last query run
+type queryConn struct {
+	driver.Conn
+	query string
+}
+
+func (c *queryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.query = query
+	return nil, nil
+}
+
+func (c *queryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.query = query
+	return nil, nil
+}
+
+// txConn is a queryConn beginning transactions, recording whether
+// the last one was rolled back
+type txConn struct {
+	queryConn
+	rolledBack bool
+}
+
+func (c *txConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *txConn) Commit() error {
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.rolledBack = true
+	return nil
+}
+
+// requestContext returns the context of a request given a request ID
+// by hlog.RequestIDHandler
+func requestContext(t *testing.T) context.Context {
+	var ctx context.Context
+	h := hlog.RequestIDHandler("request_id", "Request-Id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if ctx == nil {
+		t.Fatal("handler not called")
+	}
+	return ctx
+}
+
+func Test_requestIDConn(t *testing.T) {
+	const query = "select title from demo.movie where extl_id = $1"
+	commented := regexp.QuoteMeta(query)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"request", requestContext(t), `/\* request_id=[0-9a-v]{20} \*/ ` + commented},
+		{"no request", context.Background(), commented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			qc := &queryConn{}
+			conn := requestIDConn{Conn: qc}
+
+			_, err := conn.QueryContext(tt.ctx, query, nil)
+			c.Assert(err, qt.IsNil)
+			c.Assert(qc.query, qt.Matches, tt.want)
+
+			_, err = conn.ExecContext(tt.ctx, query, nil)
+			c.Assert(err, qt.IsNil)
+			c.Assert(qc.query, qt.Matches, tt.want)
+		})
+	}
+}
+
+func Test_requestIDConn_BeginTx(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		appName string
+		want    string
+	}{
+		{"request", requestContext(t), "go-api-basic", `^set local application_name = 'go-api-basic request_id=[0-9a-v]{20}'$`},
+		{"no app name", requestContext(t), "", `^set local application_name = 'request_id=[0-9a-v]{20}'$`},
+		{"quoted", requestContext(t), "otto's api", `^set local application_name = 'otto''s api request_id=[0-9a-v]{20}'$`},
+		{"no request", context.Background(), "go-api-basic", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			tc := &txConn{}
+			conn := requestIDConn{Conn: tc, appName: tt.appName}
+
+			tx, err := conn.BeginTx(tt.ctx, driver.TxOptions{})
+			c.Assert(err, qt.IsNil)
+			c.Assert(tx, qt.Not(qt.IsNil))
+			c.Assert(tc.query, qt.Matches, tt.want)
+			c.Assert(tc.rolledBack, qt.IsFalse)
+		})
+	}
+}
+
+func Test_requestApplicationName(t *testing.T) {
+	c := qt.New(t)
+
+	const id = "c0ffee1kmd4kvt4g6pfg"
+	c.Assert(requestApplicationName("go-api-basic", id), qt.Equals, "go-api-basic request_id="+id)
+
+	// a long name is shortened rather than the ID truncated
+	got := requestApplicationName(strings.Repeat("a", maxApplicationName), id)
+	c.Assert(got, qt.HasLen, maxApplicationName)
+	c.Assert(strings.HasSuffix(got, " request_id="+id), qt.IsTrue)
+}
@@ -0,0 +1,126 @@
+// Package sessionstore persists the long-lived sessions of
+// server-side integrations in the demo.integration_session table.
+// The refresh token of a session is encrypted using a pii.Cipher,
+// which must have a key: refresh tokens are never stored in plain
+// text. A session is found by the hash of its secret (see
+// pii.Cipher.Hash), the secret itself is not stored.
+package sessionstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// NewDefaultStore is an initializer for DefaultStore
+func NewDefaultStore(ds datastore.Datastorer, c pii.Cipher) DefaultStore {
+	return DefaultStore{Datastorer: ds, Cipher: c}
+}
+
+// DefaultStore is the database implementation of auth.SessionStore
+type DefaultStore struct {
+	datastore.Datastorer
+	Cipher pii.Cipher
+}
+
+// encryptToken returns the value to store for refreshToken. It is an
+// errs.Unavailable error if no pii key is configured.
+func (s DefaultStore) encryptToken(refreshToken string) (string, error) {
+	if _, ok := s.Cipher.(pii.PlainCipher); ok {
+		return "", errs.E(errs.Unavailable, errors.New("sessions need a pii key to encrypt refresh tokens with"))
+	}
+	return s.Cipher.Encrypt(refreshToken)
+}
+
+// Create adds the session ss, encrypting its refresh token
+func (s DefaultStore) Create(ctx context.Context, ss auth.Session) error {
+	token, err := s.encryptToken(ss.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Exec(ctx, s.DB(),
+		`insert into demo.integration_session (session_id, name, username, secret_hash,
+		                                       refresh_token, create_timestamp)
+		 values ($1, $2, $3, $4, $5, $6)`,
+		ss.ID,
+		ss.Name,
+		ss.Username,
+		s.Cipher.Hash(ss.Secret),
+		token,
+		ss.CreateTime)
+
+	return err
+}
+
+// FindBySecret returns the session with id and secret, decrypting
+// its refresh token. An unknown session, or a secret which does not
+// match, is an errs.NotExist error.
+func (s DefaultStore) FindBySecret(ctx context.Context, id uuid.UUID, secret string) (auth.Session, error) {
+	var ss auth.Session
+	err := repo.Get(ctx, s.DB(),
+		func(row repo.Scanner) error {
+			err := row.Scan(
+				&ss.ID,
+				&ss.Name,
+				&ss.Username,
+				&ss.RefreshToken,
+				&ss.CreateTime,
+				repo.Time(&ss.RefreshTime))
+			if err != nil {
+				return err
+			}
+			ss.RefreshToken, err = s.Cipher.Decrypt(ss.RefreshToken)
+			return err
+		},
+		`select session_id, name, username, refresh_token, create_timestamp, refresh_timestamp
+		   from demo.integration_session
+		  where session_id = $1
+		    and secret_hash = $2`,
+		id,
+		s.Cipher.Hash(secret))
+
+	return ss, err
+}
+
+// Refreshed records that an access token was issued for the session
+// with id at t, replacing its refresh token with refreshToken,
+// encrypted, unless empty
+func (s DefaultStore) Refreshed(ctx context.Context, id uuid.UUID, refreshToken string, t time.Time) error {
+	var token string
+	if refreshToken != "" {
+		var err error
+		if token, err = s.encryptToken(refreshToken); err != nil {
+			return err
+		}
+	}
+
+	return repo.ExecOne(ctx, s.DB(),
+		`update demo.integration_session
+		    set refresh_token     = coalesce(nullif($2, ''), refresh_token),
+		        refresh_timestamp = $3
+		  where session_id = $1`,
+		id,
+		token,
+		t)
+}
+
+// Delete deletes the session with id created by username. A session
+// which is not found, or was created by another user, is an
+// errs.NotExist error.
+func (s DefaultStore) Delete(ctx context.Context, id uuid.UUID, username string) error {
+	return repo.ExecOne(ctx, s.DB(),
+		`delete from demo.integration_session
+		  where session_id = $1
+		    and lower(username) = lower($2)`,
+		id,
+		username)
+}
@@ -0,0 +1,94 @@
+package sessionstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestDefaultStore(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	ss := auth.Session{
+		ID:           uuid.New(),
+		Name:         "nightly import",
+		Username:     "Otto.Maddox@example.com",
+		RefreshToken: "1//refreshToken",
+		Secret:       "sessionSecret",
+		CreateTime:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	ci, err := pii.NewAESGCMCipher(bytes.Repeat([]byte{7}, pii.KeySize))
+	c.Assert(err, qt.IsNil)
+	s := NewDefaultStore(ds, ci)
+
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.integration_session where session_id = $1`, ss.ID)
+		if err != nil {
+			t.Errorf("delete integration_session error = %v", err)
+		}
+		cleanup()
+	})
+
+	// refresh tokens are never stored in plain text
+	err = NewDefaultStore(ds, pii.PlainCipher{}).Create(ctx, ss)
+	c.Assert(errs.KindIs(errs.Unavailable, err), qt.IsTrue)
+
+	c.Assert(s.Create(ctx, ss), qt.IsNil)
+
+	var token, secretHash string
+	err = ds.DB().QueryRowContext(ctx,
+		`select refresh_token, secret_hash from demo.integration_session where session_id = $1`, ss.ID).
+		Scan(&token, &secretHash)
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.Contains(token, "refreshToken"), qt.IsFalse)
+	c.Assert(secretHash, qt.Not(qt.Equals), ss.Secret)
+
+	got, err := s.FindBySecret(ctx, ss.ID, ss.Secret)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.RefreshToken, qt.Equals, ss.RefreshToken)
+	c.Assert(got.Username, qt.Equals, ss.Username)
+	c.Assert(got.CreateTime.Equal(ss.CreateTime), qt.IsTrue)
+	c.Assert(got.RefreshTime.IsZero(), qt.IsTrue)
+
+	_, err = s.FindBySecret(ctx, ss.ID, "wrongSecret")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	// the refresh token is kept unless a new one is given
+	refreshed := time.Now().UTC().Truncate(time.Microsecond)
+	c.Assert(s.Refreshed(ctx, ss.ID, "", refreshed), qt.IsNil)
+	got, err = s.FindBySecret(ctx, ss.ID, ss.Secret)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.RefreshToken, qt.Equals, ss.RefreshToken)
+	c.Assert(got.RefreshTime.Equal(refreshed), qt.IsTrue)
+
+	c.Assert(s.Refreshed(ctx, ss.ID, "1//rotatedToken", refreshed), qt.IsNil)
+	got, err = s.FindBySecret(ctx, ss.ID, ss.Secret)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.RefreshToken, qt.Equals, "1//rotatedToken")
+
+	// only the user who created the session, whatever the case of
+	// their email, deletes it
+	err = s.Delete(ctx, ss.ID, "someone.else@example.com")
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+	c.Assert(s.Delete(ctx, ss.ID, strings.ToLower(ss.Username)), qt.IsNil)
+	_, err = s.FindBySecret(ctx, ss.ID, ss.Secret)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
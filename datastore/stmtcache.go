@@ -0,0 +1,304 @@
+package datastore
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// planChanged is the code of the error a cached statement fails with
+// once a table it reads is altered so the statement would return
+// other columns ("cached plan must not change result type")
+const planChanged pq.ErrorCode = "0A000"
+
+// stmtCacheConn decorates a driver.Conn and runs each statement with
+// arguments as a prepared statement, prepared the first time the
+// statement is run on the connection and reused after, so Postgres
+// parses and plans it once per connection rather than on every call.
+// Up to size statements are kept, the least recently used is closed
+// to make room for another. Statements without arguments are sent as
+// is, as the simple query protocol used for them is not parsed twice.
+//
+// The prepared statements are run without a context, so cancel is
+// called to cancel a statement when the context of its call is done
+// before it finishes. database/sql uses a connection for one call at
+// a time, so stmtCacheConn is not safe for concurrent use.
+type stmtCacheConn struct {
+	driver.Conn
+	size   int
+	stmts  map[string]*list.Element
+	lru    *list.List
+	cancel func(ctx context.Context) error
+}
+
+// cachedStmt is a prepared statement in the cache of a stmtCacheConn
+type cachedStmt struct {
+	query string
+	stmt  driver.Stmt
+}
+
+// newStmtCacheConn is an initializer for stmtCacheConn
+func newStmtCacheConn(conn driver.Conn, size int, cancel func(ctx context.Context) error) *stmtCacheConn {
+	return &stmtCacheConn{
+		Conn:   conn,
+		size:   size,
+		stmts:  make(map[string]*list.Element),
+		lru:    list.New(),
+		cancel: cancel,
+	}
+}
+
+// stmt returns the cached statement for query, preparing it using
+// the underlying connection if it is not cached
+func (c *stmtCacheConn) stmt(ctx context.Context, query string) (driver.Stmt, error) {
+	if e, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(cachedStmt).stmt, nil
+	}
+
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.stmts[query] = c.lru.PushFront(cachedStmt{query: query, stmt: stmt})
+	for c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+
+	return stmt, nil
+}
+
+// evict closes the statement of e and removes it from the cache
+func (c *stmtCacheConn) evict(e *list.Element) {
+	cs := c.lru.Remove(e).(cachedStmt)
+	delete(c.stmts, cs.query)
+	_ = cs.stmt.Close()
+}
+
+// forget evicts the statement for query if err shows it can no
+// longer be run, so it is prepared again the next time
+func (c *stmtCacheConn) forget(query string, err error) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != planChanged {
+		return
+	}
+	if e, ok := c.stmts[query]; ok {
+		c.evict(e)
+	}
+}
+
+// watchCancel cancels the statement running on the connection if ctx
+// is done before the returned func is called. The func waits for a
+// cancel being sent, so it cannot cancel the next statement.
+func (c *stmtCacheConn) watchCancel(ctx context.Context) func() {
+	if ctx.Done() == nil || c.cancel == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			_ = c.cancel(context.Background())
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// QueryContext executes a query with arguments using its cached
+// statement, or a query without arguments using the underlying
+// connection
+func (c *stmtCacheConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) == 0 {
+		q, ok := c.Conn.(driver.QueryerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		return q.QueryContext(ctx, query, args)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt, err := c.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	finish := c.watchCancel(ctx)
+	var rows driver.Rows
+	if q, ok := stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			rows, err = stmt.Query(values)
+		}
+	}
+	if err != nil {
+		finish()
+		c.forget(query, err)
+		return nil, err
+	}
+
+	// the rows are read from the connection until closed, so the
+	// query can still be cancelled while they are
+	return cancelRows{Rows: rows, finish: finish}, nil
+}
+
+// ExecContext executes a statement with arguments using its cached
+// statement, or a statement without arguments using the underlying
+// connection
+func (c *stmtCacheConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 0 {
+		x, ok := c.Conn.(driver.ExecerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		return x.ExecContext(ctx, query, args)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt, err := c.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	finish := c.watchCancel(ctx)
+	defer finish()
+
+	var result driver.Result
+	if x, ok := stmt.(driver.StmtExecContext); ok {
+		result, err = x.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		values, err = namedValuesToValues(args)
+		if err == nil {
+			result, err = stmt.Exec(values)
+		}
+	}
+	if err != nil {
+		c.forget(query, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// PrepareContext prepares the statement using the underlying
+// connection. The statement is not cached, as database/sql closes it
+// when the sql.Stmt it was prepared for is closed.
+func (c *stmtCacheConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx starts a transaction using the underlying connection
+func (c *stmtCacheConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping pings the underlying connection
+func (c *stmtCacheConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Close closes the cached statements and the underlying connection
+func (c *stmtCacheConn) Close() error {
+	for c.lru.Len() > 0 {
+		c.evict(c.lru.Back())
+	}
+	return c.Conn.Close()
+}
+
+// backendPID returns the ID of the Postgres backend process of conn,
+// which a statement running on conn is cancelled by
+func backendPID(ctx context.Context, conn driver.Conn) (int64, error) {
+	q, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return 0, errors.New("datastore: driver cannot query the backend process ID")
+	}
+	rows, err := q.QueryContext(ctx, `select pg_backend_pid()`, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		return 0, err
+	}
+	pid, ok := dest[0].(int64)
+	if !ok {
+		return 0, errors.Errorf("datastore: unexpected backend process ID %v", dest[0])
+	}
+
+	return pid, nil
+}
+
+// cancelBackend cancels the statement running in the Postgres backend
+// process pid using a connection of its own, as pq does for a
+// statement run with a context
+func (c pgConnector) cancelBackend(ctx context.Context, pid int64) error {
+	conn, err := c.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return errors.New("datastore: driver cannot cancel a statement")
+	}
+	rows, err := q.QueryContext(ctx, `select pg_cancel_backend($1)`, []driver.NamedValue{{Ordinal: 1, Value: pid}})
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+// cancelRows is driver.Rows which stops watching for the
+// cancellation of its query when closed
+type cancelRows struct {
+	driver.Rows
+	finish func()
+}
+
+// Close stops watching for the cancellation of the query and closes
+// the rows
+func (r cancelRows) Close() error {
+	err := r.Rows.Close()
+	r.finish()
+	return err
+}
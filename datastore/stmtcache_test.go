@@ -0,0 +1,299 @@
+package datastore
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// prepareConn is a driver.Conn which records the statements it
+// prepares and the queries it runs without preparing
+type prepareConn struct {
+	driver.Conn
+	prepared []string
+	queried  []string
+	stmts    map[string]*fakeStmt
+	// err is returned by the statements prepared
+	err    error
+	closed bool
+}
+
+func (c *prepareConn) Prepare(query string) (driver.Stmt, error) {
+	c.prepared = append(c.prepared, query)
+	if c.stmts == nil {
+		c.stmts = make(map[string]*fakeStmt)
+	}
+	s := &fakeStmt{err: c.err}
+	c.stmts[query] = s
+	return s, nil
+}
+
+func (c *prepareConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.queried = append(c.queried, query)
+	return nil, nil
+}
+
+func (c *prepareConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.queried = append(c.queried, query)
+	return nil, nil
+}
+
+func (c *prepareConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// fakeStmt is a driver.Stmt returning err. An Exec calls onRun and
+// blocks until block is closed, if they are not nil.
+type fakeStmt struct {
+	err    error
+	onRun  func()
+	block  chan struct{}
+	runs   int
+	closed bool
+}
+
+func (s *fakeStmt) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.runs++
+	if s.onRun != nil {
+		s.onRun()
+	}
+	if s.block != nil {
+		<-s.block
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.runs++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return fakeRows{}, nil
+}
+
+// fakeRows is driver.Rows without a row
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"title"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+func Test_stmtCacheConn(t *testing.T) {
+	const (
+		q1 = "select title from demo.movie where extl_id = $1"
+		q2 = "select title from demo.movie where director = $1"
+		q3 = "update demo.movie set title = $1 where extl_id = $2"
+	)
+	args := []driver.NamedValue{{Ordinal: 1, Value: "kCBqDtyAkZIfdWjRDXQG"}}
+
+	run := func(c *qt.C, conn *stmtCacheConn, query string) {
+		rows, err := conn.QueryContext(context.Background(), query, args)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows.Close(), qt.IsNil)
+	}
+
+	c := qt.New(t)
+
+	c.Run("prepared once", func(c *qt.C) {
+		pc := &prepareConn{}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		run(c, conn, q1)
+		run(c, conn, q1)
+		_, err := conn.ExecContext(context.Background(), q1, args)
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(pc.prepared, qt.DeepEquals, []string{q1})
+		c.Assert(pc.stmts[q1].runs, qt.Equals, 3)
+	})
+
+	c.Run("no arguments", func(c *qt.C) {
+		pc := &prepareConn{}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		_, err := conn.QueryContext(context.Background(), "select 1", nil)
+		c.Assert(err, qt.IsNil)
+		_, err = conn.ExecContext(context.Background(), "listen movie_changes", nil)
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(pc.prepared, qt.HasLen, 0)
+		c.Assert(pc.queried, qt.DeepEquals, []string{"select 1", "listen movie_changes"})
+	})
+
+	c.Run("least recently used evicted", func(c *qt.C) {
+		pc := &prepareConn{}
+		conn := newStmtCacheConn(pc, 2, nil)
+
+		run(c, conn, q1)
+		run(c, conn, q2)
+		run(c, conn, q1)
+		run(c, conn, q3)
+
+		c.Assert(pc.stmts[q2].closed, qt.IsTrue)
+		c.Assert(pc.stmts[q1].closed, qt.IsFalse)
+		c.Assert(pc.stmts[q3].closed, qt.IsFalse)
+
+		// q2 is prepared again, evicting q1
+		run(c, conn, q2)
+		c.Assert(pc.prepared, qt.DeepEquals, []string{q1, q2, q3, q2})
+		c.Assert(pc.stmts[q1].closed, qt.IsTrue)
+	})
+
+	c.Run("plan changed", func(c *qt.C) {
+		pc := &prepareConn{err: &pq.Error{Code: "0A000", Message: "cached plan must not change result type"}}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		_, err := conn.QueryContext(context.Background(), q1, args)
+		c.Assert(err, qt.ErrorMatches, ".*cached plan must not change result type")
+		c.Assert(pc.stmts[q1].closed, qt.IsTrue)
+
+		pc.err = nil
+		run(c, conn, q1)
+		c.Assert(pc.prepared, qt.DeepEquals, []string{q1, q1})
+	})
+
+	c.Run("other error", func(c *qt.C) {
+		pc := &prepareConn{err: &pq.Error{Code: "23505"}}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		_, err := conn.ExecContext(context.Background(), q3, args)
+		c.Assert(err, qt.Not(qt.IsNil))
+		c.Assert(pc.stmts[q3].closed, qt.IsFalse)
+	})
+
+	c.Run("close", func(c *qt.C) {
+		pc := &prepareConn{}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		run(c, conn, q1)
+		run(c, conn, q2)
+		c.Assert(conn.Close(), qt.IsNil)
+
+		c.Assert(pc.stmts[q1].closed, qt.IsTrue)
+		c.Assert(pc.stmts[q2].closed, qt.IsTrue)
+		c.Assert(pc.closed, qt.IsTrue)
+	})
+
+	c.Run("context done", func(c *qt.C) {
+		pc := &prepareConn{}
+		conn := newStmtCacheConn(pc, 10, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := conn.QueryContext(ctx, q1, args)
+		c.Assert(err, qt.Equals, context.Canceled)
+		c.Assert(pc.prepared, qt.HasLen, 0)
+	})
+
+	c.Run("cancelled while running", func(c *qt.C) {
+		pc := &prepareConn{}
+		block := make(chan struct{})
+		conn := newStmtCacheConn(pc, 10, func(ctx context.Context) error {
+			// the backend cancels the statement, which returns
+			close(block)
+			return nil
+		})
+		pc.err = errors.New("pq: canceling statement due to user request")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stmt, err := conn.stmt(ctx, q3)
+		c.Assert(err, qt.IsNil)
+		stmt.(*fakeStmt).block = block
+		stmt.(*fakeStmt).onRun = cancel
+
+		_, err = conn.ExecContext(ctx, q3, args)
+		c.Assert(err, qt.ErrorMatches, ".*canceling statement due to user request")
+
+		select {
+		case <-block:
+		default:
+			c.Fatal("statement not cancelled")
+		}
+	})
+}
+
+func TestNewDB_statementCache(t *testing.T) {
+	c := qt.New(t)
+
+	dsn := testDSN(t)
+	dsn.StatementCacheSize = 10
+
+	db, cleanup, err := NewDB(dsn, logger.NewLogger(ioutil.Discard, true))
+	t.Cleanup(cleanup)
+	if err != nil {
+		t.Fatalf("datastore.NewDB error = %v", err)
+	}
+	ctx := context.Background()
+
+	// pin one connection, as statements are cached per connection
+	conn, err := db.Conn(ctx)
+	c.Assert(err, qt.IsNil)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	const query = `select $1::text as stmt_cache_test`
+	for i := 0; i < 3; i++ {
+		var s string
+		err = conn.QueryRowContext(ctx, query, "cached").Scan(&s)
+		c.Assert(err, qt.IsNil)
+		c.Assert(s, qt.Equals, "cached")
+	}
+
+	var n int
+	err = conn.QueryRowContext(ctx, `select count(*) from pg_prepared_statements where statement = $1`, query).Scan(&n)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 1)
+}
+
+// benchmarkStatementCache runs a query with an argument against the
+// local database, with a statement cache of size
+func benchmarkStatementCache(b *testing.B, size int) {
+	dsn := testDSN(b)
+	dsn.StatementCacheSize = size
+
+	db, cleanup, err := NewDB(dsn, logger.NewLogger(ioutil.Discard, true))
+	b.Cleanup(cleanup)
+	if err != nil {
+		b.Fatalf("datastore.NewDB error = %v", err)
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var n int
+		err := db.QueryRowContext(ctx, `select count(*) from pg_catalog.pg_class c join pg_catalog.pg_namespace n on n.oid = c.relnamespace where n.nspname = $1`, "demo").Scan(&n)
+		if err != nil {
+			b.Fatalf("QueryRowContext() error = %v", err)
+		}
+	}
+}
+
+func BenchmarkStatementCache_off(b *testing.B) {
+	benchmarkStatementCache(b, 0)
+}
+
+func BenchmarkStatementCache_on(b *testing.B) {
+	benchmarkStatementCache(b, 100)
+}
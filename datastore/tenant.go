@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// NewTenantDatastore is an initializer for TenantDatastore. Requests
+// which are not made for a tenant use db, and requests made for a
+// tenant use the database of tenants with the tenant's name.
+func NewTenantDatastore(db *sql.DB, tenants map[string]*sql.DB) TenantDatastore {
+	return TenantDatastore{DefaultDatastore: NewDefaultDatastore(db), tenants: tenants}
+}
+
+// TenantDatastore is a Datastorer with a database, and so a
+// connection pool, for each tenant. Transactions are begun in the
+// database of the tenant of the request (see
+// requestinfo.TenantFromContext), so the stores using it must only
+// use transactions: DB has no request and returns the default
+// database.
+type TenantDatastore struct {
+	DefaultDatastore
+	tenants map[string]*sql.DB
+}
+
+// TenantDB returns the database of the tenant the request of ctx is
+// made for, or the default database if it is not made for a tenant.
+// A tenant without a database is an error, rather than falling back
+// to the default database, so the data of one tenant cannot be read
+// or written for another.
+func (ds TenantDatastore) TenantDB(ctx context.Context) (*sql.DB, error) {
+	tenant, ok := requestinfo.TenantFromContext(ctx)
+	if !ok {
+		return ds.db, nil
+	}
+
+	db, ok := ds.tenants[tenant]
+	if !ok {
+		return nil, errs.E(errs.Internal, errs.Code("unknown_tenant"), errors.Errorf("no database for tenant %q", tenant))
+	}
+
+	return db, nil
+}
+
+// BeginTx starts a sql.Tx in the database of the tenant of ctx, see
+// TenantDB
+func (ds TenantDatastore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	db, err := ds.TenantDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if db == nil {
+		return nil, errs.E(errs.Database, errors.New("DB cannot be nil"))
+	}
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return tx, nil
+}
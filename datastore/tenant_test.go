@@ -0,0 +1,61 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestTenantDatastore_TenantDB(t *testing.T) {
+	// sql.Open does not connect, so no database is needed
+	open := func() *sql.DB {
+		db, err := sql.Open("postgres", "host=localhost dbname=unused")
+		if err != nil {
+			t.Fatalf("sql.Open() error = %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		return db
+	}
+	defaultDB, acmeDB, globexDB := open(), open(), open()
+	ds := NewTenantDatastore(defaultDB, map[string]*sql.DB{"acme": acmeDB, "globex": globexDB})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    *sql.DB
+		wantErr bool
+	}{
+		{"no tenant", context.Background(), defaultDB, false},
+		{"acme", requestinfo.WithTenant(context.Background(), "acme"), acmeDB, false},
+		{"globex", requestinfo.WithTenant(context.Background(), "globex"), globexDB, false},
+		// an unknown tenant must not fall back to the default database
+		{"unknown tenant", requestinfo.WithTenant(context.Background(), "initech"), nil, true},
+		{"empty tenant", requestinfo.WithTenant(context.Background(), ""), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ds.TenantDB(tt.ctx)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+				c.Assert(got, qt.IsNil)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got == tt.want, qt.IsTrue)
+		})
+	}
+
+	// DB has no request, so it is the default database
+	c := qt.New(t)
+	c.Assert(ds.DB() == defaultDB, qt.IsTrue)
+
+	_, err := ds.BeginTx(requestinfo.WithTenant(context.Background(), "initech"), nil)
+	c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+}
@@ -0,0 +1,177 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// Postgres error codes of transactions which failed because of a
+// concurrent transaction and are safe to retry
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// RetryPolicy is how a transaction which fails with a serialization
+// failure or deadlock is retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of times the transaction is
+	// attempted. Zero or one means the transaction is not retried.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It is doubled for
+	// each retry after.
+	Backoff time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy of writes
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond}
+
+// do calls fn until it returns an error which cannot be retried, the
+// attempts run out or ctx is done. The last error from fn is
+// returned.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if attempt >= p.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+// TxOptions are the options of a transaction begun by WithTxOptions
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction. The zero
+	// value is the database default, which is READ COMMITTED for
+	// Postgres.
+	Isolation sql.IsolationLevel
+	// ReadOnly begins a read-only transaction
+	ReadOnly bool
+	// Retry is how the transaction is retried after a serialization
+	// failure or deadlock. The zero value does not retry.
+	Retry RetryPolicy
+}
+
+// ReadOnly are the TxOptions for selects. The transaction is read
+// only and REPEATABLE READ, so all statements of an operation (e.g.
+// a page and its total count) see the same snapshot of the data.
+// It is not retried, as a select may have sent rows to the client.
+var ReadOnly = TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// ReadWrite are the default TxOptions for writes, which are retried
+// using the DefaultRetryPolicy
+var ReadWrite = TxOptions{Retry: DefaultRetryPolicy}
+
+// Serializable are the TxOptions for writes which must not interleave
+// with concurrent writes. A transaction which fails to serialize is
+// retried using the DefaultRetryPolicy.
+var Serializable = TxOptions{Isolation: sql.LevelSerializable, Retry: DefaultRetryPolicy}
+
+// sqlOptions returns the sql.TxOptions for o, or nil for the
+// database defaults
+func (o TxOptions) sqlOptions() *sql.TxOptions {
+	if o.Isolation == sql.LevelDefault && !o.ReadOnly {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+// WithTx begins a transaction using ds and calls fn with it. If fn
+// returns nil, the transaction is committed. If fn returns an error
+// or panics, the transaction is rolled back. A panic is re-raised
+// after the rollback. WithTx allows stores to compose multiple
+// operations atomically without re-implementing the transaction
+// lifecycle. In a dry run (see requestinfo.WithDryRun), the
+// transaction is rolled back even though fn returns nil, so the
+// writes of fn are checked by the database but not kept. Once a
+// write is committed, its consistency token is recorded for the
+// request (see ReplicaDatastore).
+//
+// Errors returned from fn which are not already an *errs.Error are
+// returned as an errs.Database error.
+func WithTx(ctx context.Context, ds Datastorer, fn func(tx *sql.Tx) error) error {
+	return WithTxOptions(ctx, ds, TxOptions{}, fn)
+}
+
+// WithTxOptions is WithTx with the given transaction options. If the
+// transaction fails with a serialization failure or deadlock, the
+// whole transaction, including fn, is retried as given by
+// opts.Retry, so fn must not have effects outside of tx.
+func WithTxOptions(ctx context.Context, ds Datastorer, opts TxOptions, fn func(tx *sql.Tx) error) error {
+	return opts.Retry.do(ctx, func() error {
+		return runTx(ctx, ds, opts, fn)
+	})
+}
+
+// BeginTxOptions begins a transaction with the given options using
+// ds, for a caller which holds the transaction open beyond a single
+// function call (e.g. an iterator over rows). The caller must commit
+// or roll back the transaction using ds. opts.Retry is ignored.
+func BeginTxOptions(ctx context.Context, ds Datastorer, opts TxOptions) (*sql.Tx, error) {
+	return ds.BeginTx(ctx, opts.sqlOptions())
+}
+
+// runTx runs fn in a single transaction as described by WithTx
+func runTx(ctx context.Context, ds Datastorer, opts TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := ds.BeginTx(ctx, opts.sqlOptions())
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		err = ds.RollbackTx(tx, err)
+		if _, ok := err.(*errs.Error); ok {
+			return err
+		}
+		return errs.E(errs.Database, err)
+	}
+
+	if requestinfo.DryRun(ctx) {
+		if err = tx.Rollback(); err != nil {
+			return errs.E(errs.Database, err)
+		}
+		return nil
+	}
+
+	if err = ds.CommitTx(tx); err != nil {
+		return err
+	}
+	if !opts.ReadOnly {
+		recordWriteToken(ctx, ds)
+	}
+
+	return nil
+}
+
+// isRetryable reports whether err is, or wraps, a Postgres
+// serialization failure or deadlock
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
@@ -0,0 +1,191 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestWithTx(t *testing.T) {
+	c := qt.New(t)
+
+	dsn := testDSN(t)
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	db, cleanup, err := NewDB(dsn, lgr)
+	t.Cleanup(cleanup)
+	if err != nil {
+		t.Fatalf("datastore.NewDB error = %v", err)
+	}
+	ds := NewDefaultDatastore(db)
+	ctx := context.Background()
+
+	c.Run("commit", func(c *qt.C) {
+		var got *sql.Tx
+		err := WithTx(ctx, ds, func(tx *sql.Tx) error {
+			got = tx
+			return nil
+		})
+		c.Assert(err, qt.IsNil)
+		// the transaction should be complete after commit
+		c.Assert(got.Commit(), qt.Equals, sql.ErrTxDone)
+	})
+
+	c.Run("rollback on error", func(c *qt.C) {
+		var got *sql.Tx
+		fnErr := errors.New("some error")
+		err := WithTx(ctx, ds, func(tx *sql.Tx) error {
+			got = tx
+			return fnErr
+		})
+		c.Assert(errors.Is(err, fnErr), qt.IsTrue)
+		c.Assert(err.(*errs.Error).Kind, qt.Equals, errs.Database)
+		c.Assert(got.Rollback(), qt.Equals, sql.ErrTxDone)
+	})
+
+	c.Run("keep errs kind", func(c *qt.C) {
+		err := WithTx(ctx, ds, func(tx *sql.Tx) error {
+			return errs.E(errs.NotExist, "not found")
+		})
+		c.Assert(err.(*errs.Error).Kind, qt.Equals, errs.NotExist)
+	})
+
+	c.Run("dry run", func(c *qt.C) {
+		// the table created in the dry run does not exist after it,
+		// as the transaction is rolled back
+		err := WithTx(requestinfo.WithDryRun(ctx), ds, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "create table dry_run_test (id int)")
+			return err
+		})
+		c.Assert(err, qt.IsNil)
+
+		var name sql.NullString
+		err = db.QueryRowContext(ctx, "select to_regclass('dry_run_test')::text").Scan(&name)
+		c.Assert(err, qt.IsNil)
+		c.Assert(name.Valid, qt.IsFalse)
+	})
+
+	c.Run("read only", func(c *qt.C) {
+		err := WithTxOptions(ctx, ds, ReadOnly, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "create temporary table read_only_test (id int)")
+			return err
+		})
+		c.Assert(err, qt.Not(qt.IsNil))
+		c.Assert(err.(*errs.Error).Kind, qt.Equals, errs.Database)
+	})
+
+	c.Run("serializable", func(c *qt.C) {
+		var level string
+		err := WithTxOptions(ctx, ds, Serializable, func(tx *sql.Tx) error {
+			return tx.QueryRowContext(ctx, "show transaction_isolation").Scan(&level)
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(level, qt.Equals, "serializable")
+	})
+
+	c.Run("rollback on panic", func(c *qt.C) {
+		var got *sql.Tx
+		c.Assert(func() {
+			_ = WithTx(ctx, ds, func(tx *sql.Tx) error {
+				got = tx
+				panic("boom")
+			})
+		}, qt.PanicMatches, "boom")
+		c.Assert(got.Rollback(), qt.Equals, sql.ErrTxDone)
+	})
+}
+
+func TestTxOptions_sqlOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TxOptions
+		want *sql.TxOptions
+	}{
+		{"default", TxOptions{}, nil},
+		{"read only", ReadOnly, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+		{"serializable", Serializable, &sql.TxOptions{Isolation: sql.LevelSerializable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(tt.opts.sqlOptions(), qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func Test_isRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"wrapped", errs.E(errs.Database, &pq.Error{Code: "40001"}), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"other error", errors.New("some error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(isRetryable(tt.err), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestRetryPolicy_do(t *testing.T) {
+	deadlock := errs.E(errs.Database, &pq.Error{Code: "40P01"})
+	other := errs.E(errs.Database, "some error")
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", DefaultRetryPolicy, []error{nil}, 1, nil},
+		{"success after retry", DefaultRetryPolicy, []error{deadlock, deadlock, nil}, 3, nil},
+		{"attempts run out", DefaultRetryPolicy, []error{deadlock, deadlock, deadlock, nil}, 3, deadlock},
+		{"not retryable", DefaultRetryPolicy, []error{other, nil}, 1, other},
+		{"no retry", RetryPolicy{}, []error{deadlock, nil}, 1, deadlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			policy := tt.policy
+			policy.Backoff = time.Millisecond
+			var calls int
+			err := policy.do(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			c.Assert(err, qt.Equals, tt.wantErr)
+			c.Assert(calls, qt.Equals, tt.wantCalls)
+		})
+	}
+
+	t.Run("context done", func(t *testing.T) {
+		c := qt.New(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var calls int
+		err := DefaultRetryPolicy.do(ctx, func() error {
+			calls++
+			return deadlock
+		})
+		c.Assert(err, qt.Equals, deadlock)
+		c.Assert(calls, qt.Equals, 1)
+	})
+}
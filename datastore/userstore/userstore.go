@@ -0,0 +1,259 @@
+// Package userstore persists the users who have used the API in the
+// demo.app_user table. The email and names of a user are encrypted
+// and decrypted by the store using a pii.Cipher, so they are never
+// held in the table in plain text when a key is configured. A user is
+// found by email using the hash of their email (see pii.Cipher.Hash).
+package userstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/datastore/repo"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// NewDefaultStore is an initializer for DefaultStore
+func NewDefaultStore(ds datastore.Datastorer, c pii.Cipher, clk clock.Clock) DefaultStore {
+	return DefaultStore{Datastorer: ds, Cipher: c, Clock: clk}
+}
+
+// DefaultStore is the database implementation of user.Recorder and
+// user.Eraser
+type DefaultStore struct {
+	datastore.Datastorer
+	Cipher pii.Cipher
+	// Clock tells the time users are recorded and erased at
+	Clock clock.Clock
+}
+
+// emailHash returns the hash the user with email is found by. Emails
+// are not case sensitive, so the hash is of the lower case email.
+func (s DefaultStore) emailHash(email string) string {
+	return s.Cipher.Hash(strings.ToLower(email))
+}
+
+// Record adds u to the users table, or updates the user with the
+// same email, encrypting the email and names
+func (s DefaultStore) Record(ctx context.Context, u user.User) error {
+	vals, err := s.encrypt(u.Email, u.FirstName, u.LastName, u.FullName)
+	if err != nil {
+		return err
+	}
+
+	now := s.Clock.Now().UTC()
+	_, err = repo.Exec(ctx, s.DB(),
+		`insert into demo.app_user (user_id, email_hash, email, first_name, last_name, full_name,
+		                            subject, hosted_domain, create_timestamp, update_timestamp)
+		 values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		 on conflict (email_hash) do update
+		    set email            = excluded.email,
+		        first_name       = excluded.first_name,
+		        last_name        = excluded.last_name,
+		        full_name        = excluded.full_name,
+		        subject          = excluded.subject,
+		        hosted_domain    = excluded.hosted_domain,
+		        update_timestamp = excluded.update_timestamp`,
+		uuid.New(),
+		s.emailHash(u.Email),
+		vals[0],
+		datastore.NewNullString(vals[1]),
+		datastore.NewNullString(vals[2]),
+		datastore.NewNullString(vals[3]),
+		datastore.NewNullString(u.Subject),
+		datastore.NewNullString(u.HostedDomain),
+		now)
+
+	return err
+}
+
+// selectAccount selects the columns scanned by scanAccount
+const selectAccount string = `select user_id, email, first_name, last_name, full_name,
+       subject, hosted_domain, create_timestamp, update_timestamp, erase_timestamp
+  from demo.app_user`
+
+// FindByID returns the user with id, decrypting their email and
+// names. A user who is not found is an errs.NotExist error.
+func (s DefaultStore) FindByID(ctx context.Context, id uuid.UUID) (user.Account, error) {
+	var a user.Account
+	err := repo.Get(ctx, s.DB(), s.scanAccount(&a), selectAccount+` where user_id = $1`, id)
+	return a, err
+}
+
+// FindByEmail returns the user with email, decrypting their email and
+// names. A user who is not found is an errs.NotExist error.
+func (s DefaultStore) FindByEmail(ctx context.Context, email string) (user.Account, error) {
+	var a user.Account
+	err := repo.Get(ctx, s.DB(), s.scanAccount(&a), selectAccount+` where email_hash = $1`, s.emailHash(email))
+	return a, err
+}
+
+// Erase erases the personal data of the user with id in a single
+// transaction: the email and names of the user are removed, the
+// audit records, movies, people and export jobs referring to the
+// user by email are rewritten to refer to user.TombstoneUsername and
+// the request
+// counts and integration sessions of the user are deleted. See
+// user.Eraser.
+func (s DefaultStore) Erase(ctx context.Context, id uuid.UUID) (user.ErasureReport, error) {
+	var rpt user.ErasureReport
+	err := datastore.WithTx(ctx, s.Datastorer, func(tx *sql.Tx) error {
+		rpt = user.ErasureReport{UserID: id, Tombstone: user.TombstoneUsername}
+
+		// lock the user, so they are not recorded again while their
+		// references are rewritten
+		var a user.Account
+		err := repo.Get(ctx, tx, s.scanAccount(&a), selectAccount+` where user_id = $1 for update`, id)
+		if err != nil {
+			return err
+		}
+		if !a.EraseTime.IsZero() {
+			rpt.EraseTime = a.EraseTime
+			return nil
+		}
+		rpt.EraseTime = s.Clock.Now().UTC()
+
+		// the references are usernames, which are the emails users
+		// had when they made the requests, whatever their case
+		email := a.User.Email
+		for _, st := range []struct {
+			n     *int64
+			query string
+			args  []interface{}
+		}{
+			// the user is also replaced in the states of the
+			// resources, as audit.ReplaceUser does
+			{&rpt.AuditRecords,
+				`update demo.api_audit
+				    set username = case when lower(username) = lower($1) then $2 else username end,
+				        after_state = after_state
+				            || case when lower(after_state->'CreateUser'->>'email') = lower($1)
+				                    then jsonb_build_object('CreateUser', jsonb_build_object('email', $2::text))
+				                    else '{}'::jsonb end
+				            || case when lower(after_state->'UpdateUser'->>'email') = lower($1)
+				                    then jsonb_build_object('UpdateUser', jsonb_build_object('email', $2::text))
+				                    else '{}'::jsonb end
+				  where lower(username) = lower($1)
+				     or lower(after_state->'CreateUser'->>'email') = lower($1)
+				     or lower(after_state->'UpdateUser'->>'email') = lower($1)`,
+				[]interface{}{email, user.TombstoneUsername}},
+			{&rpt.Movies,
+				`update demo.movie
+				    set create_username = case when lower(create_username) = lower($1) then $2 else create_username end,
+				        update_username = case when lower(update_username) = lower($1) then $2 else update_username end
+				  where lower(create_username) = lower($1)
+				     or lower(update_username) = lower($1)`,
+				[]interface{}{email, user.TombstoneUsername}},
+			{&rpt.People,
+				`update demo.person
+				    set create_username = case when lower(create_username) = lower($1) then $2 else create_username end,
+				        update_username = case when lower(update_username) = lower($1) then $2 else update_username end
+				  where lower(create_username) = lower($1)
+				     or lower(update_username) = lower($1)`,
+				[]interface{}{email, user.TombstoneUsername}},
+			{&rpt.RequestCounts,
+				`delete from demo.user_request_count
+				  where lower(username) = lower($1)`,
+				[]interface{}{email}},
+			{&rpt.Sessions,
+				`delete from demo.integration_session
+				  where lower(username) = lower($1)`,
+				[]interface{}{email}},
+			{&rpt.ExportJobs,
+				`update demo.export_job
+				    set username = $2
+				  where lower(username) = lower($1)`,
+				[]interface{}{email, user.TombstoneUsername}},
+		} {
+			*st.n, err = repo.Exec(ctx, tx, st.query, st.args...)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the email hash must stay unique, and must not be the hash
+		// of the email, so the user is recorded anew if they return
+		return repo.ExecOne(ctx, tx,
+			`update demo.app_user
+			    set email_hash      = $2,
+			        email           = '',
+			        first_name      = null,
+			        last_name       = null,
+			        full_name       = null,
+			        subject         = null,
+			        hosted_domain   = null,
+			        update_timestamp = $3,
+			        erase_timestamp = $3
+			  where user_id = $1`,
+			id,
+			"erased:"+id.String(),
+			rpt.EraseTime)
+	})
+	if err != nil {
+		return user.ErasureReport{}, err
+	}
+
+	return rpt, nil
+}
+
+// scanAccount returns a ScanFunc scanning a user row into a,
+// decrypting the email and names
+func (s DefaultStore) scanAccount(a *user.Account) repo.ScanFunc {
+	return func(row repo.Scanner) error {
+		u := &a.User
+		err := row.Scan(
+			&a.ID,
+			&u.Email,
+			repo.String(&u.FirstName),
+			repo.String(&u.LastName),
+			repo.String(&u.FullName),
+			repo.String(&u.Subject),
+			repo.String(&u.HostedDomain),
+			&a.CreateTime,
+			&a.UpdateTime,
+			repo.Time(&a.EraseTime))
+		if err != nil {
+			return err
+		}
+
+		vals, err := s.decrypt(u.Email, u.FirstName, u.LastName, u.FullName)
+		if err != nil {
+			return err
+		}
+		u.Email, u.FirstName, u.LastName, u.FullName = vals[0], vals[1], vals[2], vals[3]
+
+		return nil
+	}
+}
+
+// encrypt returns the values to store for the plain text values
+func (s DefaultStore) encrypt(plaintexts ...string) ([]string, error) {
+	vals := make([]string, len(plaintexts))
+	for i, p := range plaintexts {
+		v, err := s.Cipher.Encrypt(p)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// decrypt returns the plain text of the stored values
+func (s DefaultStore) decrypt(values ...string) ([]string, error) {
+	plaintexts := make([]string, len(values))
+	for i, v := range values {
+		p, err := s.Cipher.Decrypt(v)
+		if err != nil {
+			return nil, err
+		}
+		plaintexts[i] = p
+	}
+	return plaintexts, nil
+}
@@ -0,0 +1,174 @@
+package userstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+func TestDefaultStore(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	// use a unique email so the test is independent of any
+	// existing users
+	u := user.User{
+		Email:     uuid.New().String() + "@Example.com",
+		FirstName: "Otto",
+		LastName:  "Maddox",
+		FullName:  "Otto Maddox",
+		Subject:   "1234567890",
+	}
+
+	ci, err := pii.NewAESGCMCipher(bytes.Repeat([]byte{7}, pii.KeySize))
+	c.Assert(err, qt.IsNil)
+	s := NewDefaultStore(ds, ci, clock.DefaultClock{})
+
+	t.Cleanup(func() {
+		_, err := ds.DB().ExecContext(ctx, `delete from demo.app_user where email_hash = $1`, s.emailHash(u.Email))
+		if err != nil {
+			t.Errorf("delete app_user error = %v", err)
+		}
+		cleanup()
+	})
+
+	c.Assert(s.Record(ctx, u), qt.IsNil)
+
+	// the email and names are not stored in plain text
+	var email, fullName string
+	err = ds.DB().QueryRowContext(ctx,
+		`select email, full_name from demo.app_user where email_hash = $1`, s.emailHash(u.Email)).
+		Scan(&email, &fullName)
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.Contains(email, "example"), qt.IsFalse)
+	c.Assert(strings.Contains(fullName, "Otto"), qt.IsFalse)
+
+	// emails are found whatever their case
+	got, err := s.FindByEmail(ctx, strings.ToLower(u.Email))
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.User, qt.DeepEquals, u)
+	c.Assert(got.ID, qt.Not(qt.Equals), uuid.Nil)
+
+	// recording the user again updates them
+	u.LastName = "Parker"
+	c.Assert(s.Record(ctx, u), qt.IsNil)
+	got2, err := s.FindByID(ctx, got.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got2.User.LastName, qt.Equals, "Parker")
+	c.Assert(got2.CreateTime.Equal(got.CreateTime), qt.IsTrue)
+
+	// the users cannot be read without the key
+	_, err = NewDefaultStore(ds, pii.PlainCipher{}, clock.DefaultClock{}).FindByID(ctx, got.ID)
+	c.Assert(err, qt.ErrorMatches, "pii value is encrypted, but no pii key is configured")
+
+	_, err = s.FindByID(ctx, uuid.New())
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestDefaultStore_Erase(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	ds, cleanup := datastoretest.NewDefaultDatastore(t, lgr)
+	ctx := context.Background()
+
+	u := user.User{
+		Email:     uuid.New().String() + "@example.com",
+		FirstName: "Otto",
+		LastName:  "Maddox",
+	}
+	requestID := uuid.New().String()
+	otherRequestID := uuid.New().String()
+
+	s := NewDefaultStore(ds, pii.PlainCipher{}, clocktest.NewMockClock(t))
+	c.Assert(s.Record(ctx, u), qt.IsNil)
+	a, err := s.FindByEmail(ctx, u.Email)
+	c.Assert(err, qt.IsNil)
+
+	t.Cleanup(func() {
+		for _, q := range []string{
+			`delete from demo.app_user where user_id = '` + a.ID.String() + `'`,
+			`delete from demo.api_audit where request_id in ('` + requestID + `', '` + otherRequestID + `')`,
+		} {
+			if _, err := ds.DB().ExecContext(ctx, q); err != nil {
+				t.Errorf("cleanup error = %v", err)
+			}
+		}
+		cleanup()
+	})
+
+	// the user made a request, recorded with their email in another
+	// case, and has request counts
+	_, err = ds.DB().ExecContext(ctx,
+		`insert into demo.api_audit (request_id, method, path, username, status, latency_us, request_timestamp)
+		 values ($1, 'GET', '/api/v1/movies', $2, 200, 1, now())`, requestID, strings.ToUpper(u.Email))
+	c.Assert(err, qt.IsNil)
+	// another user updated a movie the user created
+	_, err = ds.DB().ExecContext(ctx,
+		`insert into demo.api_audit (request_id, method, path, username, status, latency_us, request_timestamp, resource_type, resource_id, after_state)
+		 values ($1, 'PUT', '/api/v1/movies/abc', 'bud@example.com', 200, 1, now(), 'movies', 'abc', $2)`,
+		otherRequestID, `{"Title": "Repo Man", "CreateUser": {"email": "`+u.Email+`", "first_name": "Otto"}, "UpdateUser": {"email": "bud@example.com"}}`)
+	c.Assert(err, qt.IsNil)
+	_, err = ds.DB().ExecContext(ctx,
+		`insert into demo.user_request_count (username, window_type, window_start, request_count)
+		 values ($1, 'day', current_date, 3)`, u.Email)
+	c.Assert(err, qt.IsNil)
+
+	rpt, err := s.Erase(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rpt.UserID, qt.Equals, a.ID)
+	c.Assert(rpt.Tombstone, qt.Equals, user.TombstoneUsername)
+	c.Assert(rpt.AuditRecords, qt.Equals, int64(2))
+	c.Assert(rpt.RequestCounts, qt.Equals, int64(1))
+	c.Assert(rpt.EraseTime.Equal(clocktest.Time), qt.IsTrue)
+
+	var username string
+	err = ds.DB().QueryRowContext(ctx, `select username from demo.api_audit where request_id = $1`, requestID).Scan(&username)
+	c.Assert(err, qt.IsNil)
+	c.Assert(username, qt.Equals, user.TombstoneUsername)
+	var state []byte
+	err = ds.DB().QueryRowContext(ctx, `select username, after_state from demo.api_audit where request_id = $1`, otherRequestID).Scan(&username, &state)
+	c.Assert(err, qt.IsNil)
+	c.Assert(username, qt.Equals, "bud@example.com")
+	c.Assert(state, qt.JSONEquals, map[string]interface{}{
+		"Title":      "Repo Man",
+		"CreateUser": map[string]string{"email": user.TombstoneUsername},
+		"UpdateUser": map[string]string{"email": "bud@example.com"},
+	})
+
+	// the user is kept, without their personal data, and is no
+	// longer found by email
+	got, err := s.FindByID(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.User, qt.DeepEquals, user.User{})
+	c.Assert(got.EraseTime.Equal(clocktest.Time), qt.IsTrue)
+	_, err = s.FindByEmail(ctx, u.Email)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+
+	// erasing the user again changes nothing
+	rpt2, err := s.Erase(ctx, a.ID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rpt2.AuditRecords, qt.Equals, int64(0))
+	c.Assert(rpt2.EraseTime.Equal(got.EraseTime), qt.IsTrue)
+
+	_, err = s.Erase(ctx, uuid.New())
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
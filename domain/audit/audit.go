@@ -0,0 +1,214 @@
+// Package audit records state-changing API requests. Each request is
+// described by a versioned Event, which is sent to one or more Sinks
+// (e.g. the database or a file). Events are sent asynchronously so
+// sending them does not add latency to the request being audited.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// bufferSize is the number of events which can be waiting to
+	// be sent before new events are dropped
+	bufferSize = 1000
+	// batchSize is the maximum number of events sent at once
+	batchSize = 100
+	// flushInterval is how often a partial batch is sent
+	flushInterval = time.Second
+)
+
+// Record is the audit record for a single request as held by a
+// Store, see Event.Record
+type Record struct {
+	RequestID string
+	Method    string
+	Path      string
+	Username  string
+	Status    int
+	Latency   time.Duration
+	Timestamp time.Time
+	// UserAgent is the User-Agent of the request and ClientVersion
+	// the release of the client app which made it, if it sent one
+	UserAgent     string
+	ClientVersion string
+	// ResourceType and ResourceID are the resource the request
+	// acted on and State is its JSON state after the request, if
+	// known (see Event)
+	ResourceType string
+	ResourceID   string
+	State        json.RawMessage
+	// Tenant is the tenant the request was made for, empty for the
+	// default database, so the versions of one tenant's resources
+	// are not found by another
+	Tenant string
+}
+
+// Version is the state of a resource after one of the successful
+// requests changing it. Versions are numbered from 1, the oldest,
+// in the order the requests were made.
+type Version struct {
+	Number    int
+	RequestID string
+	Timestamp time.Time
+	State     json.RawMessage
+}
+
+// Filter narrows the records returned by Store.Find. Zero valued
+// fields are not used to filter.
+type Filter struct {
+	Username string
+	Method   string
+	// PathPrefix matches records with a path starting with it
+	PathPrefix string
+	Since      time.Time
+	Until      time.Time
+	// Limit is the maximum number of records returned
+	Limit int
+}
+
+// Store persists and finds audit records
+type Store interface {
+	// Insert writes the records
+	Insert(ctx context.Context, records []Record) error
+	// Find returns the records matching the Filter, most recent first
+	Find(ctx context.Context, f Filter) ([]Record, error)
+	// Versions returns the versions of the resource of type typ
+	// with the external ID id, oldest first: the State of each
+	// record of a successful request with one, e.g. not a delete,
+	// made for the tenant of ctx (see requestinfo.TenantFromContext)
+	Versions(ctx context.Context, typ, id string) ([]Version, error)
+}
+
+// stateUserFields are the fields of the State of a resource holding
+// a user.User, e.g. as the user who created a movie
+var stateUserFields = []string{"CreateUser", "UpdateUser"}
+
+// ReplaceUser returns state with each user in it whose email is
+// username (whatever its case) replaced by a user with replacement
+// as their email and nothing else, e.g. to erase the user. It
+// reports whether state was changed.
+func ReplaceUser(state json.RawMessage, username, replacement string) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if len(state) == 0 || json.Unmarshal(state, &fields) != nil {
+		return state, false
+	}
+
+	changed := false
+	for _, f := range stateUserFields {
+		var u struct {
+			Email string `json:"email"`
+		}
+		if v, ok := fields[f]; !ok || json.Unmarshal(v, &u) != nil || !strings.EqualFold(u.Email, username) {
+			continue
+		}
+		u.Email = replacement
+		b, err := json.Marshal(u)
+		if err != nil {
+			return state, false
+		}
+		fields[f] = b
+		changed = true
+	}
+	if !changed {
+		return state, false
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return state, false
+	}
+	return b, true
+}
+
+// Writer writes audit events
+type Writer interface {
+	Write(e Event)
+}
+
+// NewAsyncWriter is an initializer for AsyncWriter. The returned
+// cleanup function stops the writer after sending any buffered
+// events.
+func NewAsyncWriter(sink Sink, logger zerolog.Logger) (*AsyncWriter, func()) {
+	w := &AsyncWriter{
+		sink:   sink,
+		logger: logger,
+		events: make(chan Event, bufferSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+
+	var once sync.Once
+	return w, func() {
+		once.Do(func() {
+			close(w.events)
+			<-w.done
+		})
+	}
+}
+
+// AsyncWriter buffers events and sends them to a Sink in batches
+// from a background goroutine
+type AsyncWriter struct {
+	sink   Sink
+	logger zerolog.Logger
+	events chan Event
+	done   chan struct{}
+}
+
+// Write queues the event to be sent. Write never blocks, if the
+// buffer is full the event is dropped and a warning is logged.
+func (w *AsyncWriter) Write(e Event) {
+	select {
+	case w.events <- e:
+	default:
+		w.logger.Warn().
+			Str("request_id", e.RequestID).
+			Str("method", e.Method).
+			Str("path", e.Resource.Path).
+			Msg("audit buffer full, event dropped")
+	}
+}
+
+// run sends events in batches of up to batchSize, sending any
+// partial batch every flushInterval, until the events channel is
+// closed
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.sink.Send(context.Background(), batch); err != nil {
+			w.logger.Error().Err(err).Int("events", len(batch)).Msg("audit events not sent")
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-w.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// fakeStore holds inserted records in memory
+type fakeStore struct {
+	mu      sync.Mutex
+	records []Record
+	batches int
+}
+
+func (f *fakeStore) Insert(ctx context.Context, records []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = append(f.records, records...)
+	f.batches++
+	return nil
+}
+
+func (f *fakeStore) Find(ctx context.Context, filter Filter) ([]Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.records, nil
+}
+
+func (f *fakeStore) Versions(ctx context.Context, typ, id string) ([]Version, error) {
+	return nil, nil
+}
+
+func TestAsyncWriter(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	store := &fakeStore{}
+
+	w, cleanup := NewAsyncWriter(NewStoreSink(store), lgr)
+
+	n := batchSize + 1
+	for i := 0; i < n; i++ {
+		w.Write(Event{Method: "POST", Resource: Resource{Path: "/api/v1/movies"}, Status: 200, Timestamp: time.Now()})
+	}
+
+	// cleanup sends the partial batch before returning and can be
+	// called more than once
+	cleanup()
+	cleanup()
+
+	c.Assert(store.records, qt.HasLen, n)
+	c.Assert(store.batches, qt.Equals, 2)
+	c.Assert(store.records[0].Path, qt.Equals, "/api/v1/movies")
+}
+
+func TestEventFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	type state struct {
+		Title string `json:"title"`
+	}
+
+	// nothing is recorded without NewContext
+	ctx := context.Background()
+	c.Assert(Recording(ctx), qt.IsFalse)
+	SetActor(ctx, Actor{Username: "otto.maddox711@gmail.com"})
+	SetResource(ctx, "movies", "kCBqDtyAkZIfdWjRDXQG")
+	SetBefore(ctx, state{"Repo Man"})
+	c.Assert(EventFromContext(ctx), qt.DeepEquals, Event{SchemaVersion: SchemaVersion})
+
+	ctx = NewContext(ctx)
+	c.Assert(Recording(ctx), qt.IsTrue)
+	SetActor(ctx, Actor{Username: "otto.maddox711@gmail.com", Subject: "108533491328720123456"})
+	SetTenant(ctx, "acme")
+	SetResource(ctx, "movies", "kCBqDtyAkZIfdWjRDXQG")
+	before := &state{"Repo Man"}
+	SetBefore(ctx, before)
+	// the state is recorded when set
+	before.Title = "Sid and Nancy"
+	SetAfter(ctx, before)
+
+	want := Event{
+		SchemaVersion: SchemaVersion,
+		Actor:         Actor{Username: "otto.maddox711@gmail.com", Subject: "108533491328720123456"},
+		Resource:      Resource{Type: "movies", ID: "kCBqDtyAkZIfdWjRDXQG"},
+		Before:        json.RawMessage(`{"title":"Repo Man"}`),
+		After:         json.RawMessage(`{"title":"Sid and Nancy"}`),
+		Tenant:        "acme",
+	}
+	c.Assert(EventFromContext(ctx), qt.DeepEquals, want)
+	c.Assert(want.Record().Tenant, qt.Equals, "acme")
+}
+
+func TestOutcomeFor(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Outcome
+	}{
+		{http.StatusOK, OutcomeSuccess},
+		{http.StatusUnauthorized, OutcomeDenied},
+		{http.StatusForbidden, OutcomeDenied},
+		{http.StatusNotFound, OutcomeFailure},
+		{http.StatusInternalServerError, OutcomeFailure},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			qt.Assert(t, OutcomeFor(tt.status), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestReplaceUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		want        string
+		wantChanged bool
+	}{
+		{"create user", `{"Title":"Repo Man","CreateUser":{"subject":"123","email":"Otto.Maddox711@gmail.com","full_name":"Otto Maddox"},"UpdateUser":{"email":"someone@example.com"}}`,
+			`{"CreateUser":{"email":"erased-user"},"Title":"Repo Man","UpdateUser":{"email":"someone@example.com"}}`, true},
+		{"both users", `{"CreateUser":{"email":"otto.maddox711@gmail.com"},"UpdateUser":{"email":"otto.maddox711@gmail.com","first_name":"Otto"}}`,
+			`{"CreateUser":{"email":"erased-user"},"UpdateUser":{"email":"erased-user"}}`, true},
+		{"another user", `{"CreateUser":{"email":"someone@example.com"}}`, `{"CreateUser":{"email":"someone@example.com"}}`, false},
+		{"no users", `{"Title":"Repo Man"}`, `{"Title":"Repo Man"}`, false},
+		{"not an object", `[1,2]`, `[1,2]`, false},
+		{"none", ``, ``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, changed := ReplaceUser(json.RawMessage(tt.state), "otto.maddox711@gmail.com", "erased-user")
+			c.Assert(changed, qt.Equals, tt.wantChanged)
+			c.Assert(string(got), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestActionFor(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{http.MethodPost, "create"},
+		{http.MethodPut, "update"},
+		{http.MethodPatch, "update"},
+		{http.MethodDelete, "delete"},
+		{http.MethodGet, "get"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			qt.Assert(t, ActionFor(tt.method), qt.Equals, tt.want)
+		})
+	}
+}
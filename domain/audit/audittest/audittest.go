@@ -0,0 +1,128 @@
+// Package audittest provides testing helper functions for the
+// audit package
+package audittest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+)
+
+// NewMockWriter is an initializer for MockWriter
+func NewMockWriter(t testing.TB) *MockWriter {
+	return &MockWriter{t: t}
+}
+
+// MockWriter holds the audit events written in memory
+type MockWriter struct {
+	t      testing.TB
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+// Write adds the event to the events held
+func (m *MockWriter) Write(e audit.Event) {
+	m.t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+}
+
+// Events returns the events written
+func (m *MockWriter) Events() []audit.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]audit.Event(nil), m.events...)
+}
+
+// NewMockStore is an initializer for MockStore
+func NewMockStore(t testing.TB) MockStore {
+	return MockStore{t: t}
+}
+
+// MockStore mocks persisting and finding audit records
+type MockStore struct {
+	t testing.TB
+}
+
+// Insert mocks writing audit records. Insert never returns an error
+func (m MockStore) Insert(ctx context.Context, records []audit.Record) error {
+	m.t.Helper()
+
+	return nil
+}
+
+// Find returns the static records from NewRecords
+func (m MockStore) Find(ctx context.Context, f audit.Filter) ([]audit.Record, error) {
+	m.t.Helper()
+
+	return NewRecords(m.t), nil
+}
+
+// Versions returns the static versions from NewVersions for the
+// movie kCBqDtyAkZIfdWjRDXQG, and no versions for anything else
+func (m MockStore) Versions(ctx context.Context, typ, id string) ([]audit.Version, error) {
+	m.t.Helper()
+
+	if typ != "movies" || id != "kCBqDtyAkZIfdWjRDXQG" {
+		return []audit.Version{}, nil
+	}
+	return NewVersions(m.t), nil
+}
+
+// NewVersions returns static versions of the movie
+// kCBqDtyAkZIfdWjRDXQG for testing: its creation and an update of
+// its run time and writer
+func NewVersions(t testing.TB) []audit.Version {
+	t.Helper()
+
+	ts := time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC)
+
+	return []audit.Version{
+		{
+			Number:    1,
+			RequestID: "c0mcbvst9kr3sp1ullcg",
+			Timestamp: ts,
+			State:     json.RawMessage(`{"ID":"f118f4bb-b345-4517-b463-f237630b1a07","ExternalID":"kCBqDtyAkZIfdWjRDXQG","Title":"Repo Man","Rated":"R","Released":"1984-03-02T00:00:00Z","RunTime":90,"Director":"Alex Cox","Writer":"Alex","CreateUser":{"email":"otto.maddox711@gmail.com"},"CreateTime":"2008-01-08T06:54:00Z","UpdateUser":{"email":"otto.maddox711@gmail.com"},"UpdateTime":"2008-01-08T06:54:00Z"}`),
+		},
+		{
+			Number:    2,
+			RequestID: "c0mcc0st9kr3sp1ulle0",
+			Timestamp: ts.Add(time.Minute),
+			State:     json.RawMessage(`{"ID":"f118f4bb-b345-4517-b463-f237630b1a07","ExternalID":"kCBqDtyAkZIfdWjRDXQG","Title":"Repo Man","Rated":"R","Released":"1984-03-02T00:00:00Z","RunTime":92,"Director":"Alex Cox","Writer":"Alex Cox","CreateUser":{"email":"otto.maddox711@gmail.com"},"CreateTime":"2008-01-08T06:54:00Z","UpdateUser":{"email":"otto.maddox711@gmail.com"},"UpdateTime":"2008-01-08T06:55:00Z"}`),
+		},
+	}
+}
+
+// NewRecords returns static audit records for testing
+func NewRecords(t testing.TB) []audit.Record {
+	t.Helper()
+
+	ts := time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC)
+
+	return []audit.Record{
+		{
+			RequestID: "c0mcc0st9kr3sp1ulle0",
+			Method:    "DELETE",
+			Path:      "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG",
+			Username:  "otto.maddox711@gmail.com",
+			Status:    200,
+			Latency:   1500 * time.Microsecond,
+			Timestamp: ts.Add(time.Minute),
+		},
+		{
+			RequestID: "c0mcbvst9kr3sp1ullcg",
+			Method:    "POST",
+			Path:      "/api/v1/movies",
+			Username:  "otto.maddox711@gmail.com",
+			Status:    200,
+			Latency:   3 * time.Millisecond,
+			Timestamp: ts,
+		},
+	}
+}
@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the Event schema. It is increased
+// whenever a field is removed or its meaning changes, so consumers
+// of the events can tell the versions apart.
+const SchemaVersion = 1
+
+// Outcome is the result of the audited request
+type Outcome string
+
+// Outcomes of an audited request
+const (
+	OutcomeSuccess Outcome = "success"
+	// OutcomeDenied is a request which was not authenticated or
+	// not authorized
+	OutcomeDenied  Outcome = "denied"
+	OutcomeFailure Outcome = "failure"
+)
+
+// OutcomeFor returns the Outcome of a request given the HTTP status
+// of the response
+func OutcomeFor(status int) Outcome {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// ActionFor returns the action of a request given its HTTP method,
+// i.e. create, update or delete
+func ActionFor(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return strings.ToLower(method)
+}
+
+// Actor is who made the audited request
+type Actor struct {
+	Username string `json:"username,omitempty"`
+	// Subject is the user's unique ID at the identity provider
+	Subject string `json:"subject,omitempty"`
+}
+
+// Resource is what the audited request acted on
+type Resource struct {
+	// Type is the type of resource, e.g. movies. It is empty if the
+	// request failed before the resource was known and its route
+	// does not declare one.
+	Type string `json:"type,omitempty"`
+	// ID is the external ID of the resource
+	ID   string `json:"id,omitempty"`
+	Path string `json:"path"`
+}
+
+// Event is the structured audit event for a single state-changing
+// request. Category groups the events of related routes, e.g. all
+// changes to the catalog, and is empty if the route has none. Before
+// and After are the JSON state of the resource
+// before and after the request, when known: a create has no Before
+// and a delete has no After. ClientVersion is the release of the
+// client app which made the request, if it sent one. Tenant is the
+// tenant the request was made for, if any.
+type Event struct {
+	SchemaVersion int             `json:"schema_version"`
+	RequestID     string          `json:"request_id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Actor         Actor           `json:"actor"`
+	Action        string          `json:"action"`
+	Category      string          `json:"category,omitempty"`
+	Resource      Resource        `json:"resource"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	Outcome       Outcome         `json:"outcome"`
+	Method        string          `json:"method"`
+	Status        int             `json:"status"`
+	Latency       time.Duration   `json:"latency_ns"`
+	UserAgent     string          `json:"user_agent,omitempty"`
+	ClientVersion string          `json:"client_version,omitempty"`
+	Tenant        string          `json:"tenant,omitempty"`
+}
+
+// Record returns the audit record of the request stored for e, with
+// the After state of the resource as its State
+func (e Event) Record() Record {
+	return Record{
+		RequestID:     e.RequestID,
+		Method:        e.Method,
+		Path:          e.Resource.Path,
+		Username:      e.Actor.Username,
+		Status:        e.Status,
+		Latency:       e.Latency,
+		Timestamp:     e.Timestamp,
+		UserAgent:     e.UserAgent,
+		ClientVersion: e.ClientVersion,
+		ResourceType:  e.Resource.Type,
+		ResourceID:    e.Resource.ID,
+		State:         e.After,
+		Tenant:        e.Tenant,
+	}
+}
+
+// entry holds the parts of the Event of a request which are set
+// while the request is handled
+type entry struct {
+	actor    Actor
+	resource Resource
+	before   json.RawMessage
+	after    json.RawMessage
+	tenant   string
+}
+
+type contextKey string
+
+const contextKeyEntry = contextKey("audit-entry")
+
+// NewContext returns a copy of ctx which can hold the actor,
+// resource and change of the request being audited, see SetActor,
+// SetTenant, SetResource, SetBefore and SetAfter
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyEntry, new(entry))
+}
+
+// Recording reports whether ctx was returned by NewContext, i.e. the
+// request is audited. It can be used to skip work only needed for
+// the audit event, such as reading the state before a change.
+func Recording(ctx context.Context) bool {
+	_, ok := ctx.Value(contextKeyEntry).(*entry)
+	return ok
+}
+
+// SetActor sets the actor for the audit event of the request. Users
+// are authenticated after the request is audited, so the actor is
+// set once the user is known. SetActor does nothing if ctx was not
+// returned by NewContext.
+func SetActor(ctx context.Context, a Actor) {
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		e.actor = a
+	}
+}
+
+// SetTenant sets the tenant the request is made for. Like the actor,
+// it is set once the user is known. SetTenant does nothing if ctx was
+// not returned by NewContext.
+func SetTenant(ctx context.Context, tenant string) {
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		e.tenant = tenant
+	}
+}
+
+// SetResource sets the type and external ID of the resource the
+// request acts on. SetResource does nothing if ctx was not returned
+// by NewContext.
+func SetResource(ctx context.Context, typ, id string) {
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		e.resource.Type = typ
+		e.resource.ID = id
+	}
+}
+
+// SetBefore sets the state of the resource before the request. The
+// state is encoded as JSON when set, so later changes to v are not
+// recorded; a state which cannot be encoded is left out. SetBefore
+// does nothing if ctx was not returned by NewContext.
+func SetBefore(ctx context.Context, v interface{}) {
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		e.before = marshalState(v)
+	}
+}
+
+// SetAfter sets the state of the resource after the request, the
+// same way as SetBefore
+func SetAfter(ctx context.Context, v interface{}) {
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		e.after = marshalState(v)
+	}
+}
+
+// marshalState returns v as JSON, or nil if v cannot be encoded
+func marshalState(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// EventFromContext returns an Event with the actor, tenant, resource
+// and change set to ctx. The fields describing the request itself are
+// left for the caller to set.
+func EventFromContext(ctx context.Context) Event {
+	ev := Event{SchemaVersion: SchemaVersion}
+	if e, ok := ctx.Value(contextKeyEntry).(*entry); ok {
+		ev.Actor = e.actor
+		ev.Resource = e.resource
+		ev.Before = e.before
+		ev.After = e.after
+		ev.Tenant = e.tenant
+	}
+	return ev
+}
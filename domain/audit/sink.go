@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Sink is where audit events are sent, e.g. the database, a file or
+// a Pub/Sub topic
+type Sink interface {
+	Send(ctx context.Context, events []Event) error
+}
+
+// NewStoreSink is an initializer for StoreSink
+func NewStoreSink(store Store) StoreSink {
+	return StoreSink{store}
+}
+
+// StoreSink sends events to a Store as Records, which can be read
+// back using Store.Find
+type StoreSink struct {
+	Store Store
+}
+
+// Send inserts the Record of each event
+func (s StoreSink) Send(ctx context.Context, events []Event) error {
+	records := make([]Record, 0, len(events))
+	for _, e := range events {
+		records = append(records, e.Record())
+	}
+	return s.Store.Insert(ctx, records)
+}
+
+// NewFileSink is an initializer for FileSink
+func NewFileSink(w io.Writer) *FileSink {
+	return &FileSink{w: w}
+}
+
+// FileSink writes events to an io.Writer (usually a file) as JSON,
+// one event per line
+type FileSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Send writes the events as JSON lines
+func (s *FileSink) Send(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := json.NewEncoder(s.w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return errs.E(errs.Internal, err)
+		}
+	}
+	return nil
+}
+
+// Publisher publishes a message with the given body and attributes,
+// e.g. to a Pub/Sub topic
+type Publisher interface {
+	Publish(ctx context.Context, body []byte, attributes map[string]string) error
+}
+
+// NewPublisherSink is an initializer for PublisherSink
+func NewPublisherSink(p Publisher) PublisherSink {
+	return PublisherSink{p}
+}
+
+// PublisherSink publishes each event as a JSON message using a
+// Publisher. The schema version, action, resource type and outcome
+// are set as message attributes so subscribers can filter on them
+// without decoding the message.
+type PublisherSink struct {
+	Publisher Publisher
+}
+
+// Send publishes the events, one message per event
+func (s PublisherSink) Send(ctx context.Context, events []Event) error {
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return errs.E(errs.Internal, err)
+		}
+		attrs := map[string]string{
+			"schema_version": strconv.Itoa(e.SchemaVersion),
+			"action":         e.Action,
+			"resource_type":  e.Resource.Type,
+			"outcome":        string(e.Outcome),
+		}
+		if err = s.Publisher.Publish(ctx, body, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MultiSink sends events to each of its sinks
+type MultiSink []Sink
+
+// Send sends the events to every sink, even if sending to one
+// fails. The first error is returned.
+func (m MultiSink) Send(ctx context.Context, events []Event) error {
+	var first error
+	for _, s := range m {
+		if err := s.Send(ctx, events); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// SinkConfig configures the sinks audit events are sent to
+type SinkConfig struct {
+	// Sinks are the names of the sinks: db sends events to the
+	// Store and file:<path> appends them to the file at path. If
+	// empty, events are sent to the Store.
+	Sinks []string
+}
+
+// ParseSinkConfig returns the SinkConfig for the comma separated
+// list of sinks s
+func ParseSinkConfig(s string) SinkConfig {
+	var cfg SinkConfig
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			cfg.Sinks = append(cfg.Sinks, name)
+		}
+	}
+	return cfg
+}
+
+// NewSink returns the Sink for cfg, sending to store for the db
+// sink. The returned cleanup function closes any file opened.
+func NewSink(store Store, cfg SinkConfig) (Sink, func(), error) {
+	names := cfg.Sinks
+	if len(names) == 0 {
+		names = []string{"db"}
+	}
+
+	var (
+		sinks MultiSink
+		files []*os.File
+	)
+	cleanup := func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+
+	for _, name := range names {
+		switch {
+		case name == "db":
+			sinks = append(sinks, NewStoreSink(store))
+		case strings.HasPrefix(name, "file:"):
+			path := strings.TrimPrefix(name, "file:")
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				cleanup()
+				return nil, nil, errs.E(errs.Internal, errors.Wrapf(err, "opening audit file %s", path))
+			}
+			files = append(files, f)
+			sinks = append(sinks, NewFileSink(f))
+		default:
+			cleanup()
+			return nil, nil, errs.E(errs.Validation, errs.Parameter("audit-sinks"),
+				errors.Errorf("unknown audit sink %q, must be db or file:<path>", name))
+		}
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], cleanup, nil
+	}
+	return sinks, cleanup, nil
+}
@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// newTestEvent returns an Event for testing
+func newTestEvent() Event {
+	return Event{
+		SchemaVersion: SchemaVersion,
+		RequestID:     "c0mcc0st9kr3sp1ulle0",
+		Timestamp:     time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		Actor:         Actor{Username: "otto.maddox711@gmail.com"},
+		Action:        "delete",
+		Resource:      Resource{Type: "movies", ID: "kCBqDtyAkZIfdWjRDXQG", Path: "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG"},
+		Before:        json.RawMessage(`{"title":"Repo Man"}`),
+		Outcome:       OutcomeSuccess,
+		Method:        "DELETE",
+		Status:        200,
+		Latency:       1500 * time.Microsecond,
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	s := NewFileSink(&buf)
+	c.Assert(s.Send(context.Background(), []Event{newTestEvent(), newTestEvent()}), qt.IsNil)
+
+	line := `{"schema_version":1,"request_id":"c0mcc0st9kr3sp1ulle0","timestamp":"2008-01-08T06:54:00Z",` +
+		`"actor":{"username":"otto.maddox711@gmail.com"},"action":"delete",` +
+		`"resource":{"type":"movies","id":"kCBqDtyAkZIfdWjRDXQG","path":"/api/v1/movies/kCBqDtyAkZIfdWjRDXQG"},` +
+		`"before":{"title":"Repo Man"},"outcome":"success","method":"DELETE","status":200,"latency_ns":1500000}` + "\n"
+	c.Assert(buf.String(), qt.Equals, line+line)
+}
+
+func TestStoreSink(t *testing.T) {
+	c := qt.New(t)
+
+	store := &fakeStore{}
+	c.Assert(NewStoreSink(store).Send(context.Background(), []Event{newTestEvent()}), qt.IsNil)
+
+	want := Record{
+		RequestID: "c0mcc0st9kr3sp1ulle0",
+		Method:    "DELETE",
+		Path:      "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG",
+		Username:  "otto.maddox711@gmail.com",
+		Status:    200,
+		Latency:   1500 * time.Microsecond,
+		Timestamp: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		// a delete has no state after the request
+		ResourceType: "movies",
+		ResourceID:   "kCBqDtyAkZIfdWjRDXQG",
+	}
+	c.Assert(store.records, qt.DeepEquals, []Record{want})
+}
+
+// fakePublisher holds published messages in memory
+type fakePublisher struct {
+	bodies []string
+	attrs  []map[string]string
+	err    error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, body []byte, attributes map[string]string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.bodies = append(p.bodies, string(body))
+	p.attrs = append(p.attrs, attributes)
+	return nil
+}
+
+func TestPublisherSink(t *testing.T) {
+	c := qt.New(t)
+
+	p := &fakePublisher{}
+	c.Assert(NewPublisherSink(p).Send(context.Background(), []Event{newTestEvent()}), qt.IsNil)
+
+	c.Assert(p.bodies, qt.HasLen, 1)
+	var got Event
+	c.Assert(json.Unmarshal([]byte(p.bodies[0]), &got), qt.IsNil)
+	c.Assert(got.Resource, qt.Equals, newTestEvent().Resource)
+	c.Assert(p.attrs[0], qt.DeepEquals, map[string]string{
+		"schema_version": "1",
+		"action":         "delete",
+		"resource_type":  "movies",
+		"outcome":        "success",
+	})
+}
+
+func TestMultiSink(t *testing.T) {
+	c := qt.New(t)
+
+	failing := &fakePublisher{err: errors.New("topic not found")}
+	store := &fakeStore{}
+
+	// a failing sink does not stop the others
+	err := MultiSink{NewPublisherSink(failing), NewStoreSink(store)}.Send(context.Background(), []Event{newTestEvent()})
+	c.Assert(err, qt.ErrorMatches, "topic not found")
+	c.Assert(store.records, qt.HasLen, 1)
+}
+
+func TestNewSink(t *testing.T) {
+	c := qt.New(t)
+
+	store := &fakeStore{}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	s, cleanup, err := NewSink(store, ParseSinkConfig("db, file:"+path))
+	c.Assert(err, qt.IsNil)
+	c.Assert(s, qt.HasLen, 2)
+
+	c.Assert(s.Send(context.Background(), []Event{newTestEvent()}), qt.IsNil)
+	cleanup()
+
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, `"request_id":"c0mcc0st9kr3sp1ulle0"`)
+	c.Assert(store.records, qt.HasLen, 1)
+
+	t.Run("default", func(t *testing.T) {
+		s, _, err := NewSink(store, SinkConfig{})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, s, qt.Equals, Sink(NewStoreSink(store)))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, _, err := NewSink(store, ParseSinkConfig("pubsub"))
+		qt.Assert(t, errs.KindIs(errs.Validation, err), qt.IsTrue)
+	})
+}
@@ -4,8 +4,6 @@ package auth
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"strings"
 
 	"github.com/rs/zerolog"
 
@@ -30,52 +28,125 @@ func (at AccessToken) NewGoogleOauth2Token() *oauth2.Token {
 	return &oauth2.Token{AccessToken: at.Token, TokenType: at.TokenType}
 }
 
+// The codes of the errs.Unauthenticated errors of an access token
+// which is not accepted. The code is sent to the client in the
+// WWW-Authenticate header of the 401 (see errs.HTTPErrorResponse).
+const (
+	// CodeInvalidToken is a token the identity provider does not
+	// accept, e.g. one which is malformed or has been revoked
+	CodeInvalidToken errs.Code = "invalid_token"
+	// CodeTokenExpired is a token which has expired
+	CodeTokenExpired errs.Code = "token_expired"
+	// CodeWrongAudience is a token issued to a client other than
+	// those the API accepts, i.e. minted for another service
+	CodeWrongAudience errs.Code = "wrong_audience"
+)
+
 // AccessTokenConverter interface is used to convert an access token
 // to a User
 type AccessTokenConverter interface {
 	Convert(ctx context.Context, token AccessToken) (user.User, error)
 }
 
-// Authorizer interface authorizes access to a resource given
-// a user and action
+// The actions a user can perform on a resource
+const (
+	// ActionRead is reading a resource, e.g. finding movies
+	ActionRead string = "read"
+	// ActionWrite is changing a resource, e.g. creating, updating
+	// or deleting a movie
+	ActionWrite string = "write"
+)
+
+// RoleResource is the resource a role is authorized on: a user has a
+// role if authorized for the role as the action, e.g. the admin
+// action on the role resource
+const RoleResource string = "role"
+
+// RoleAdmin is the role of the users who administer the API
+const RoleAdmin string = "admin"
+
+// Authorizer interface authorizes a user to perform an action on a
+// resource, e.g. to write (action) movies (resource). The resource
+// and action of a route are given by its scope, e.g. movies:write.
 type Authorizer interface {
-	Authorize(ctx context.Context, sub user.User, obj string, act string) error
+	Authorize(ctx context.Context, sub user.User, resource string, action string) error
+}
+
+// HasRole reports whether az authorizes u for role (the role action
+// on RoleResource). An Unauthorized error means u does not have the
+// role; any other error is returned as is.
+func HasRole(ctx context.Context, az Authorizer, u user.User, role string) (bool, error) {
+	err := az.Authorize(ctx, u, RoleResource, role)
+	switch {
+	case err == nil:
+		return true, nil
+	case errs.KindIs(errs.Unauthorized, err):
+		return false, nil
+	}
+	return false, err
 }
 
 // DefaultAuthorizer struct satisfies the Authorizer interface.
 // The DefaultAuthorizer.Authorize method ensures a subject (user)
-// can perform a particular action on an object. e.g. gilcrest can
-// read (GET) the resource at the /ping path. This is obviously
-// completely bogus right now, eventually need to look into something
-// like Casbin for ACL/RBAC
+// can perform a particular action on a resource. e.g. gilcrest can
+// read movies. This is obviously completely bogus right now,
+// eventually need to look into something like Casbin for ACL/RBAC
 type DefaultAuthorizer struct{}
 
 // Authorize authorizes a subject (user) can perform a particular
-// action on an object. e.g. gilcrest can read (GET) the resource
-// at the /ping path. This is obviously completely bogus right now,
-// eventually need to look into something like Casbin for ACL/RBAC
-func (a DefaultAuthorizer) Authorize(ctx context.Context, sub user.User, obj string, act string) error {
+// action on a resource. e.g. gilcrest can read movies. This is
+// obviously completely bogus right now, eventually need to look into
+// something like Casbin for ACL/RBAC
+func (a DefaultAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
 	logger := *zerolog.Ctx(ctx)
 
 	const (
-		movies string = "/api/v1/movies"
+		movies string = "movies"
+		people string = "people"
+		admin  string = "admin"
+		users  string = "users"
+		// usage and permissions are only ever the authenticated
+		// user's own, so any authenticated user can read them
+		usage       string = "usage"
+		permissions string = "permissions"
+		// sessions are the authenticated user's own, and act as the
+		// user, so any authenticated user can create them
+		sessions string = "sessions"
+		// export jobs are only ever found by the user who created
+		// them, so any authenticated user can read them
+		exports string = "exports"
 	)
 
 	var authorized bool
-	switch strings.HasPrefix(obj, movies) && (act == http.MethodPost || act == http.MethodPut || act == http.MethodDelete || act == http.MethodGet) {
-	case true:
-		switch sub.Email {
-		case "otto.maddox711@gmail.com":
-			authorized = true
-		}
+	switch {
+	// the anonymous user of a public route can only read the catalog
+	case sub.IsAnonymous():
+		authorized = (resource == movies || resource == people) && action == ActionRead
+	case (resource == movies || resource == people) && (action == ActionRead || action == ActionWrite):
+		authorized = sub.Email == "otto.maddox711@gmail.com"
+	// admin resources are read only
+	case resource == admin && action == ActionRead:
+		authorized = sub.Email == "otto.maddox711@gmail.com"
+	// users are only written to erase their personal data, which
+	// routes also require the admin role for
+	case resource == users && action == ActionWrite:
+		authorized = sub.Email == "otto.maddox711@gmail.com"
+	case resource == RoleResource && action == RoleAdmin:
+		authorized = sub.Email == "otto.maddox711@gmail.com"
+	case (resource == usage || resource == permissions) && action == ActionRead:
+		authorized = true
+	case resource == sessions && action == ActionWrite:
+		authorized = true
+	case resource == exports && action == ActionRead:
+		authorized = true
 	}
 
 	if authorized {
-		logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Msgf("Authorization Granted")
+		logger.Info().Str("sub", sub.Email).Str("resource", resource).Str("action", action).Msg("Authorization Granted")
 		return nil
 	}
 
-	logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Msgf("Authorization Denied")
+	logger.Warn().Str("sub", sub.Email).Str("resource", resource).Str("action", action).Msg("Authorization Denied")
 
 	// "In summary, a 401 Unauthorized response should be used for missing or
 	// bad authentication, and a 403 Forbidden response should be used afterwards,
@@ -83,37 +154,31 @@ func (a DefaultAuthorizer) Authorize(ctx context.Context, sub user.User, obj str
 	// requested operation on the given resource."
 	// If the user has gotten here, they have gotten through authentication
 	// but do have the right access, this they are Unauthorized
-	return errs.E(errs.Unauthorized, errors.New(fmt.Sprintf("user %s does not have %s permission for %s", sub.Email, act, obj)))
+	return errs.E(errs.Unauthorized, errors.New(fmt.Sprintf("user %s does not have %s permission for %s", sub.Email, action, resource)))
 }
 
-type contextKey string
-
-const contextKeyAccessToken = contextKey("access-token")
-
-// FromRequest gets the access token from the request
-func FromRequest(r *http.Request) (AccessToken, error) {
-	at := AccessToken{}
-	// retrieve the context from the http.Request
-	ctx := r.Context()
+// StaticAccessTokenConverter satisfies the AccessTokenConverter
+// interface. Any access token is converted to User without calling
+// an identity provider, so it must only be used when running
+// without real authentication (e.g. the server's -mock flag).
+type StaticAccessTokenConverter struct {
+	User user.User
+}
 
-	at, ok := ctx.Value(contextKeyAccessToken).(AccessToken)
-	if !ok {
-		return at, errs.E(errs.Unauthenticated, errors.New("Access Token not set properly to context"))
-	}
-	if at.Token == "" {
-		return at, errs.E(errs.Unauthenticated, errors.New("Access Token empty in context"))
-	}
-	return at, nil
+// Convert returns the User of the StaticAccessTokenConverter
+func (c StaticAccessTokenConverter) Convert(ctx context.Context, token AccessToken) (user.User, error) {
+	return c.User, nil
 }
 
-// SetAccessToken2Context sets the Access Token to the given context
-func SetAccessToken2Context(ctx context.Context, token, tokenType string) context.Context {
-	at := AccessToken{
-		Token:     token,
-		TokenType: tokenType,
-	}
+// AllowAllAuthorizer satisfies the Authorizer interface and
+// authorizes every user for every action, so it must only be used
+// when running without real authentication (e.g. the server's
+// -mock flag).
+type AllowAllAuthorizer struct{}
 
-	return context.WithValue(ctx, contextKeyAccessToken, at)
+// Authorize always returns nil
+func (a AllowAllAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
+	return nil
 }
 
 // AccessControlList (ACL) describes permissions for a given object
@@ -13,40 +13,40 @@ import (
 )
 
 // NewMockAuthorizer is an initializer for MockAuthorizer
-func NewMockAuthorizer(t *testing.T) MockAuthorizer {
+func NewMockAuthorizer(t testing.TB) MockAuthorizer {
 	return MockAuthorizer{t: t}
 }
 
-// MockAuthorizer mocks authorizing access for
-// a user to a given object to perform a given action.
+// MockAuthorizer mocks authorizing a user to perform a given
+// action on a given resource.
 type MockAuthorizer struct {
-	t *testing.T
+	t testing.TB
 }
 
-// Authorize mocks authorizing access for
-// a user to a given object to perform a given action. Authorize
-// never returns an error, thus everything sent in is always authorized
-func (ma MockAuthorizer) Authorize(ctx context.Context, sub user.User, obj string, act string) error {
+// Authorize mocks authorizing a user to perform a given action on
+// a given resource. Authorize never returns an error, thus
+// everything sent in is always authorized
+func (ma MockAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
 	ma.t.Helper()
 
 	return nil
 }
 
 // NewAccessToken returns a mock auth.AccessToken
-func NewAccessToken(t *testing.T) auth.AccessToken {
+func NewAccessToken(t testing.TB) auth.AccessToken {
 	t.Helper()
 
 	return auth.AccessToken{Token: "abc123def1", TokenType: auth.BearerTokenType}
 }
 
 // NewMockAccessTokenConverter is an initializer for a MockAccessTokenConverter
-func NewMockAccessTokenConverter(t *testing.T) MockAccessTokenConverter {
+func NewMockAccessTokenConverter(t testing.TB) MockAccessTokenConverter {
 	return MockAccessTokenConverter{t: t}
 }
 
 // MockAccessTokenConverter mocks converting an auth.AccessToken to a user.User
 type MockAccessTokenConverter struct {
-	t *testing.T
+	t testing.TB
 }
 
 // Convert returns a static test user.User
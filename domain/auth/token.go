@@ -0,0 +1,26 @@
+package auth
+
+import "strings"
+
+// bearerPrefixLen is the length of the "Bearer " prefix of an
+// Authorization header holding a bearer token
+const bearerPrefixLen int = len(BearerTokenType) + 1
+
+// ParseBearerToken returns the token of an Authorization header
+// value holding a bearer token, e.g. "Bearer abcdef123". The scheme
+// is not case sensitive (RFC 7235) and may be followed by more than
+// one space. ok is false if the value is not a bearer token or the
+// token is empty. ParseBearerToken does not allocate: the token is a
+// substring of header.
+func ParseBearerToken(header string) (token string, ok bool) {
+	if len(header) < bearerPrefixLen ||
+		!strings.EqualFold(header[:len(BearerTokenType)], BearerTokenType) ||
+		header[len(BearerTokenType)] != ' ' {
+		return "", false
+	}
+
+	token = strings.TrimLeft(header[bearerPrefixLen:], " ")
+	token = strings.TrimRight(token, " ")
+
+	return token, token != ""
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantOK    bool
+	}{
+		{"typical", "Bearer abcdef123", "abcdef123", true},
+		{"lower case scheme", "bearer abcdef123", "abcdef123", true},
+		{"extra spaces", "Bearer   abcdef123 ", "abcdef123", true},
+		{"empty token", "Bearer ", "", false},
+		{"only spaces", "Bearer    ", "", false},
+		{"no token", "Bearer", "", false},
+		{"no space", "Bearerabcdef123", "", false},
+		{"other scheme", "Basic b3R0bzpzZWNyZXQ=", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			token, ok := ParseBearerToken(tt.header)
+			c.Assert(token, qt.Equals, tt.wantToken)
+			c.Assert(ok, qt.Equals, tt.wantOK)
+		})
+	}
+}
+
+func BenchmarkParseBearerToken(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := ParseBearerToken("Bearer ya29.a0AfH6SMBx3kVtq9fOq2zTq"); !ok {
+			b.Fatal("token not parsed")
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Package clock tells the time, so the time used by the domain can
+// be controlled in tests
+package clock
+
+import "time"
+
+// Clock returns the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// DefaultClock is the Clock which returns the system time
+type DefaultClock struct{}
+
+// Now returns the current system time
+func (c DefaultClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,19 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDefaultClock_Now(t *testing.T) {
+	c := qt.New(t)
+
+	before := time.Now()
+	got := DefaultClock{}.Now()
+	after := time.Now()
+
+	c.Assert(got.Before(before), qt.IsFalse)
+	c.Assert(got.After(after), qt.IsFalse)
+}
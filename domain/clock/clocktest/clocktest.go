@@ -0,0 +1,27 @@
+// Package clocktest has test helpers for the clock package
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+// Time is the time returned by MockClock
+var Time = time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC)
+
+// NewMockClock is an initializer for MockClock
+func NewMockClock(t testing.TB) MockClock {
+	return MockClock{t: t}
+}
+
+// MockClock is a clock which is frozen at Time
+type MockClock struct {
+	t testing.TB
+}
+
+// Now returns Time
+func (c MockClock) Now() time.Time {
+	c.t.Helper()
+
+	return Time
+}
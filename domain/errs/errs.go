@@ -12,6 +12,7 @@ package errs
 import (
 	"fmt"
 	"runtime"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -29,12 +30,15 @@ type Error struct {
 	Param Parameter
 	// Code is a human-readable, short representation of the error
 	Code Code
+	// RetryAfter is how long the client should wait before retrying
+	// the request, if it is worth retrying
+	RetryAfter RetryAfter
 	// The underlying error that triggered this one, if any.
 	Err error
 }
 
 func (e *Error) isZero() bool {
-	return e.User == "" && e.Kind == 0 && e.Param == "" && e.Code == "" && e.Err == nil
+	return e.User == "" && e.Kind == 0 && e.Param == "" && e.Code == "" && e.RetryAfter == 0 && e.Err == nil
 }
 
 // Unwrap method allows for unwrapping errors using errors.As
@@ -59,6 +63,10 @@ type Parameter string
 // Code is a human-readable, short representation of the error
 type Code string
 
+// RetryAfter is how long the client should wait before retrying a
+// request which failed
+type RetryAfter time.Duration
+
 // Kinds of errors.
 //
 // The values of the error kinds are common between both
@@ -66,21 +74,27 @@ type Code string
 // any items since that will change their values.
 // New items must be added only to the end.
 const (
-	Other           Kind = iota // Unclassified error. This value is not printed in the error message.
-	Invalid                     // Invalid operation for this type of item.
-	Permission                  // Permission denied.
-	IO                          // External I/O error such as network failure.
-	Exist                       // Item already exists.
-	NotExist                    // Item does not exist.
-	Private                     // Information withheld.
-	Internal                    // Internal error or inconsistency.
-	BrokenLink                  // Link target does not exist.
-	Database                    // Error from database.
-	Validation                  // Input validation error.
-	Unanticipated               // Unanticipated error.
-	InvalidRequest              // Invalid Request
-	Unauthenticated             // User did not properly authenticate
-	Unauthorized                // User is not authorized for the resource
+	Other                Kind = iota // Unclassified error. This value is not printed in the error message.
+	Invalid                          // Invalid operation for this type of item.
+	Permission                       // Permission denied.
+	IO                               // External I/O error such as network failure.
+	Exist                            // Item already exists.
+	NotExist                         // Item does not exist.
+	Private                          // Information withheld.
+	Internal                         // Internal error or inconsistency.
+	BrokenLink                       // Link target does not exist.
+	Database                         // Error from database.
+	Validation                       // Input validation error.
+	Unanticipated                    // Unanticipated error.
+	InvalidRequest                   // Invalid Request
+	Unauthenticated                  // User did not properly authenticate
+	Unauthorized                     // User is not authorized for the resource
+	TooManyRequests                  // User has exceeded their request quota
+	Unprocessable                    // Request is well-formed but exceeds a limit
+	Unavailable                      // A service the request depends on is unavailable
+	MethodNotAllowed                 // The route does not allow the request method
+	UnsupportedMediaType             // The request body is of a type the route does not accept
+	TooLarge                         // The request body is larger than accepted
 )
 
 func (k Kind) String() string {
@@ -115,6 +129,18 @@ func (k Kind) String() string {
 		return "unauthenticated"
 	case Unauthorized:
 		return "unauthorized"
+	case TooManyRequests:
+		return "too_many_requests"
+	case Unprocessable:
+		return "unprocessable_entity"
+	case Unavailable:
+		return "service_unavailable"
+	case MethodNotAllowed:
+		return "method_not_allowed"
+	case UnsupportedMediaType:
+		return "unsupported_media_type"
+	case TooLarge:
+		return "request_entity_too_large"
 	}
 	return "unknown_error_kind"
 }
@@ -133,6 +159,8 @@ func (k Kind) String() string {
 //		Err field after a call to errors.New.
 //	errors.Kind
 //		The class of error, such as permission failure.
+//	RetryAfter
+//		How long the client should wait before retrying.
 //	error
 //		The underlying error that triggered this one.
 //
@@ -175,6 +203,8 @@ func E(args ...interface{}) error {
 			e.Code = arg
 		case Parameter:
 			e.Param = arg
+		case RetryAfter:
+			e.RetryAfter = arg
 		default:
 			_, file, line, _ := runtime.Caller(1)
 			return fmt.Errorf("errors.E: bad call from %s:%d: %v, unknown type %T, value %v in error call", file, line, args, arg, arg)
@@ -200,6 +230,12 @@ func E(args ...interface{}) error {
 		prev.Code = ""
 	}
 
+	// If this error has no RetryAfter, pull up the inner one.
+	if e.RetryAfter == 0 {
+		e.RetryAfter = prev.RetryAfter
+		prev.RetryAfter = 0
+	}
+
 	if prev.Param == e.Param {
 		prev.Param = ""
 	}
@@ -4,7 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
@@ -14,110 +19,257 @@ type ErrResponse struct {
 }
 
 // ServiceError has fields for Service errors. All fields with no data will
-// be omitted
+// be omitted. Errors lists each error of an InvalidParams error.
+// RequestID is the ID of the request which failed, for the user to
+// quote when asking for support, and SupportURL is the page
+// documenting the error (see SupportURLs). They are only set for the
+// error as a whole, not for each of Errors.
 type ServiceError struct {
-	Kind    string `json:"kind,omitempty"`
-	Code    string `json:"code,omitempty"`
-	Param   string `json:"param,omitempty"`
-	Message string `json:"message,omitempty"`
+	Kind       string         `json:"kind,omitempty"`
+	Code       string         `json:"code,omitempty"`
+	Param      string         `json:"param,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Errors     []ServiceError `json:"errors,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	SupportURL string         `json:"support_url,omitempty"`
 }
 
-// HTTPErrorResponse takes a writer, error and a logger, performs a
-// type switch to determine if the type is an Error (which meets
-// the Error interface as defined in this package), then sends the
-// Error as a response to the client. If the type does not meet the
-// Error interface as defined in this package, then a proper error
-// is still formed and sent to the client, however, the Kind and
-// Code will be Unanticipated. Logging of error is also done using
-// https://github.com/rs/zerolog
+// RequestIDHeader is the response header holding the request ID,
+// which is set before any handler is called (see
+// handler.LoggerHandlerChain)
+const RequestIDHeader string = "Request-Id"
+
+// SupportURLs are the documentation or support URLs sent with error
+// responses
+type SupportURLs struct {
+	// Codes maps an error code to the URL of the error. Errors
+	// without a code are looked up by their kind, e.g.
+	// input_validation_error.
+	Codes map[string]string
+	// Default is the URL of errors not in Codes. If empty, they are
+	// sent without a URL.
+	Default string
+}
+
+// URL returns the URL of an error with the given code and kind
+func (s SupportURLs) URL(code, kind string) string {
+	key := code
+	if key == "" {
+		key = kind
+	}
+	if u, ok := s.Codes[key]; ok {
+		return u
+	}
+	return s.Default
+}
+
+// ParseSupportURLs returns the SupportURLs with the default URL def
+// and the URLs of codes, a comma separated list of code=url pairs
+func ParseSupportURLs(def, codes string) (SupportURLs, error) {
+	s := SupportURLs{Default: def}
+	for _, pair := range strings.Split(codes, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return SupportURLs{}, E(Validation, Parameter("error-urls"),
+				errors.Errorf("%q must be code=url", pair))
+		}
+		if s.Codes == nil {
+			s.Codes = make(map[string]string)
+		}
+		s.Codes[pair[:i]] = pair[i+1:]
+	}
+	return s, nil
+}
+
+// supportURLs holds the SupportURLs set by SetSupportURLs
+var supportURLs atomic.Value
+
+// SetSupportURLs sets the URLs sent with error responses by
+// HTTPErrorResponse. It is safe to call while requests are being
+// served. Until it is called, errors are sent without a URL.
+func SetSupportURLs(s SupportURLs) {
+	supportURLs.Store(s)
+}
+
+// currentSupportURLs returns the SupportURLs set by SetSupportURLs
+func currentSupportURLs() SupportURLs {
+	s, _ := supportURLs.Load().(SupportURLs)
+	return s
+}
+
+// HTTPErrorResponse is the single function used by handlers to send
+// an error response, so no handler needs its own error plumbing. It
+// maps the error Kind to an HTTP status code (see
+// httpErrorStatusCode), logs the error using
+// https://github.com/rs/zerolog with the same fields every time and
+// sends the standard error response body (ErrResponse).
+//
+// If err is (or wraps) an *Error, the response body is built from
+// its Kind, Code, Param and message. If the *Error wraps
+// InvalidParams, each of them is listed in Errors as well. For Unauthenticated and
+// Unauthorized errors the body is empty, the reason is only logged;
+// the Code of an Unauthenticated error is sent in the
+// WWW-Authenticate header.
+// Every response body has the request ID, taken from the
+// RequestIDHeader of w, and the support URL of the error, if any (see
+// SetSupportURLs). A response with no body still has the request ID
+// in its RequestIDHeader. If w is an ErrorRecorder, a server error
+// (HTTP 5xx) is recorded to it so it can be reported (see
+// ErrorReporter).
+// If err is not an *Error, an HTTP 500 is sent with a Kind and Code
+// of Unanticipated and a generic message, so internal details are
+// not leaked to the caller. If err is nil, an HTTP 500 is sent with
+// no body.
 func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error) {
+	httpStatusCode, er := errResponse(err)
 
-	var httpStatusCode int
-
-	if err != nil {
-		// perform a "type switch" https://tour.golang.org/methods/16
-		// to determine the interface value type
-		switch e := err.(type) {
-		// If the interface value is of type Error (not a typical error, but
-		// the Error interface defined above), then
-		case *Error:
-			httpStatusCode = httpErrorStatusCode(e.Kind)
-			// We can retrieve the status here and write out a specific
-			// HTTP status code. If the error is empty, just
-			// send the HTTP Status Code as response
-			if e.isZero() {
-				logger.Error().Stack().Int("http_statuscode", httpStatusCode).Msg("empty error")
-				sendError(w, "", httpStatusCode)
-			} else if e.Kind == Unauthenticated {
-				// For Unauthenticated and Unauthorized errors,
-				// the response body should be empty. Use logger
-				// to log the error and then just send
-				// http.StatusUnauthorized (401) or http.StatusForbidden (403)
-				// depending on the circumstances. "In summary, a
-				// 401 Unauthorized response should be used for missing or bad authentication,
-				// and a 403 Forbidden response should be used afterwards, when the user is
-				// authenticated but isn’t authorized to perform the requested operation on
-				// the given resource."
-				logger.Error().Stack().Err(e.Err).
-					Int("http_statuscode", http.StatusUnauthorized).
-					Msg("Unauthenticated Request")
-				sendError(w, "", httpStatusCode)
-			} else if e.Kind == Unauthorized {
-				logger.Error().Stack().Err(e.Err).
-					Int("http_statuscode", http.StatusForbidden).
-					Msg("Unauthorized Request")
-				sendError(w, "", httpStatusCode)
-			} else {
-				// log the error with stacktrace
-				logger.Error().Stack().Err(e.Err).
-					Int("http_statuscode", httpStatusCode).
-					Str("Kind", e.Kind.String()).
-					Str("Parameter", string(e.Param)).
-					Str("Code", string(e.Code)).
-					Msg("Response Error Sent")
-
-				// setup ErrResponse
-				er := ErrResponse{
-					Error: ServiceError{
-						Kind:    e.Kind.String(),
-						Code:    string(e.Code),
-						Param:   string(e.Param),
-						Message: e.Error(),
-					},
-				}
-
-				// Marshal errResponse struct to JSON for the response body
-				errJSON, _ := json.Marshal(er)
-
-				sendError(w, string(errJSON), httpStatusCode)
-			}
+	logError(logger, httpStatusCode, err)
 
-		default:
-			// Any error types we don't specifically look out for default
-			// to serving a HTTP 500
-			cd := http.StatusInternalServerError
-			er := ErrResponse{
-				Error: ServiceError{
-					Kind:    Unanticipated.String(),
-					Code:    "Unanticipated",
-					Message: "Unexpected error - contact support",
-				},
-			}
+	if rec, ok := w.(ErrorRecorder); ok && err != nil && httpStatusCode >= http.StatusInternalServerError {
+		rec.RecordError(err)
+	}
+
+	setRetryAfter(w, err)
+	setWWWAuthenticate(w, err)
 
-			logger.Error().Msgf("Unknown Error - HTTP %d - %s", cd, err.Error())
+	if er == nil {
+		sendError(w, "", httpStatusCode)
+		return
+	}
 
-			// Marshal errResponse struct to JSON for the response body
-			errJSON, _ := json.Marshal(er)
+	er.Error.RequestID = w.Header().Get(RequestIDHeader)
+	er.Error.SupportURL = currentSupportURLs().URL(er.Error.Code, er.Error.Kind)
 
-			sendError(w, string(errJSON), cd)
+	// Marshal errResponse struct to JSON for the response body
+	errJSON, _ := json.Marshal(er)
+
+	sendError(w, string(errJSON), httpStatusCode)
+}
+
+// setRetryAfter sets the Retry-After header, in whole seconds
+// rounded up, if err says how long to wait before retrying
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) || e.RetryAfter <= 0 {
+		return
+	}
+	secs := (time.Duration(e.RetryAfter) + time.Second - 1) / time.Second
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(secs), 10))
+}
+
+// setWWWAuthenticate sets the WWW-Authenticate header of the 401 sent
+// for an Unauthenticated error, as RFC 6750 asks of a Bearer token
+// API. The Code of the error, if any, is sent as the
+// error_description of an invalid_token error, so a client can tell
+// an expired token from one issued for another service, even though
+// the response has no body.
+func setWWWAuthenticate(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != Unauthenticated {
+		return
+	}
+	if e.Code == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, e.Code))
+}
+
+// errResponse returns the HTTP status code and response body for
+// err. A nil ErrResponse means no response body is sent.
+func errResponse(err error) (int, *ErrResponse) {
+	if err == nil {
+		return httpErrorStatusCode(Other), nil
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		// Any error types we don't specifically look out for default
+		// to serving a HTTP 500
+		return http.StatusInternalServerError, &ErrResponse{
+			Error: ServiceError{
+				Kind:    Unanticipated.String(),
+				Code:    "Unanticipated",
+				Message: "Unexpected error - contact support",
+			},
 		}
-	} else {
-		httpStatusCode = httpErrorStatusCode(Other)
-		// if a nil error is passed, do not write a response body,
-		// just send the HTTP Status Code
-		logger.Error().Int("HTTP Error StatusCode", httpStatusCode).Msg("nil error - no response body sent")
-		sendError(w, "", httpStatusCode)
 	}
+
+	httpStatusCode := httpErrorStatusCode(e.Kind)
+
+	// "In summary, a 401 Unauthorized response should be used for
+	// missing or bad authentication, and a 403 Forbidden response
+	// should be used afterwards, when the user is authenticated but
+	// isn’t authorized to perform the requested operation on the
+	// given resource." In either case, the response body is empty.
+	if e.isZero() || e.Kind == Unauthenticated || e.Kind == Unauthorized {
+		return httpStatusCode, nil
+	}
+
+	return httpStatusCode, &ErrResponse{Error: newServiceError(e)}
+}
+
+// newServiceError returns the ServiceError for e
+func newServiceError(e *Error) ServiceError {
+	se := ServiceError{
+		Kind:    e.Kind.String(),
+		Code:    string(e.Code),
+		Param:   string(e.Param),
+		Message: e.Error(),
+	}
+
+	var ip InvalidParams
+	if errors.As(e.Err, &ip) {
+		for _, err := range ip {
+			var pe *Error
+			if errors.As(err, &pe) {
+				se.Errors = append(se.Errors, newServiceError(pe))
+			}
+		}
+	}
+
+	return se
+}
+
+// logError logs err with the HTTP status code sent for it
+func logError(logger zerolog.Logger, httpStatusCode int, err error) {
+	if err == nil {
+		logger.Error().
+			Int("http_statuscode", httpStatusCode).
+			Msg("nil error - no response body sent")
+		return
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		logger.Error().Err(err).
+			Int("http_statuscode", httpStatusCode).
+			Str("Kind", Unanticipated.String()).
+			Msg("Unknown Error")
+		return
+	}
+
+	msg := "Response Error Sent"
+	switch {
+	case e.isZero():
+		msg = "empty error"
+	case e.Kind == Unauthenticated:
+		msg = "Unauthenticated Request"
+	case e.Kind == Unauthorized:
+		msg = "Unauthorized Request"
+	}
+
+	// log the error with stacktrace
+	logger.Error().Stack().Err(e.Err).
+		Int("http_statuscode", httpStatusCode).
+		Str("Kind", e.Kind.String()).
+		Str("Parameter", string(e.Param)).
+		Str("Code", string(e.Code)).
+		Msg(msg)
 }
 
 // Taken from standard library, but changed to send application/json as header
@@ -130,11 +282,6 @@ func sendError(w http.ResponseWriter, errStr string, httpStatusCode int) {
 		w.Header().Set("Content-Type", "application/json")
 	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	// TODO - refactor this package to allow for WWW-Authenticate header on 401/403
-	//if httpStatusCode == 401 || httpStatusCode == 403 {
-	//	br := fmt.Sprintf(`Bearer realm="%s"`, realm)
-	//	w.Header().Set("WWW-Authenticate", br)
-	//}
 	w.WriteHeader(httpStatusCode)
 	// Only write response body if there is an error string populated
 	if errStr != "" {
@@ -149,7 +296,21 @@ func httpErrorStatusCode(k Kind) int {
 		return http.StatusUnauthorized
 	case Unauthorized, Permission:
 		return http.StatusForbidden
-	case Invalid, Exist, NotExist, Private, BrokenLink, Validation, InvalidRequest:
+	case NotExist:
+		return http.StatusNotFound
+	case TooManyRequests:
+		return http.StatusTooManyRequests
+	case Unprocessable:
+		return http.StatusUnprocessableEntity
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case MethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case TooLarge:
+		return http.StatusRequestEntityTooLarge
+	case Invalid, Exist, Private, BrokenLink, Validation, InvalidRequest:
 		return http.StatusBadRequest
 	// the zero value of Kind is Other, so if no Kind is present
 	// in the error, Other is used. Errors should always have a
@@ -2,10 +2,13 @@ package errs
 
 import (
 	"bytes"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -27,7 +30,13 @@ func Test_httpErrorStatusCode(t *testing.T) {
 		{"Permission", args{k: Permission}, http.StatusForbidden},
 		{"Exist", args{k: Exist}, http.StatusBadRequest},
 		{"Invalid", args{k: Invalid}, http.StatusBadRequest},
-		{"NotExist", args{k: NotExist}, http.StatusBadRequest},
+		{"NotExist", args{k: NotExist}, http.StatusNotFound},
+		{"TooManyRequests", args{k: TooManyRequests}, http.StatusTooManyRequests},
+		{"Unprocessable", args{k: Unprocessable}, http.StatusUnprocessableEntity},
+		{"Unavailable", args{k: Unavailable}, http.StatusServiceUnavailable},
+		{"MethodNotAllowed", args{k: MethodNotAllowed}, http.StatusMethodNotAllowed},
+		{"UnsupportedMediaType", args{k: UnsupportedMediaType}, http.StatusUnsupportedMediaType},
+		{"TooLarge", args{k: TooLarge}, http.StatusRequestEntityTooLarge},
 		{"Private", args{k: Private}, http.StatusBadRequest},
 		{"BrokenLink", args{k: BrokenLink}, http.StatusBadRequest},
 		{"Validation", args{k: Validation}, http.StatusBadRequest},
@@ -70,6 +79,10 @@ func TestHTTPErrorResponse_StatusCode(t *testing.T) {
 	}{
 		{"empty", args{httptest.NewRecorder(), l, &Error{}}, http.StatusInternalServerError},
 		{"unauthenticated", args{httptest.NewRecorder(), l, E(Unauthenticated)}, http.StatusUnauthorized},
+		{"unauthorized", args{httptest.NewRecorder(), l, E(Unauthorized)}, http.StatusForbidden},
+		{"not via E", args{httptest.NewRecorder(), l, errors.New("some error")}, http.StatusInternalServerError},
+		{"wrapped", args{httptest.NewRecorder(), l, errors.Wrap(E(NotExist, errors.New("gone")), "find")}, http.StatusNotFound},
+		{"nil error", args{httptest.NewRecorder(), l, nil}, http.StatusInternalServerError},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +116,11 @@ func TestHTTPErrorResponse_Body(t *testing.T) {
 		{"unauthorized", args{httptest.NewRecorder(), l, E(Unauthorized)}, ""},
 		{"normal", args{httptest.NewRecorder(), l, E(Exist, Parameter("some_param"), Code("some_code"), errors.New("some error"))}, `{"error":{"kind":"item_already_exists","code":"some_code","param":"some_param","message":"some error"}}`},
 		{"not via E", args{httptest.NewRecorder(), l, errors.New("some error")}, "{\"error\":{\"kind\":\"unanticipated_error\",\"code\":\"Unanticipated\",\"message\":\"Unexpected error - contact support\"}}"},
+		{"wrapped", args{httptest.NewRecorder(), l, errors.Wrap(E(Validation, Parameter("p"), errors.New("bad p")), "decode")}, `{"error":{"kind":"input_validation_error","param":"p","message":"bad p"}}`},
+		{"invalid params", args{httptest.NewRecorder(), l, E(Validation, Code("invalid_parameters"), InvalidParams{
+			E(Validation, Parameter("a"), MissingField("a")),
+			E(Validation, Parameter("b"), errors.New("b must be a number")),
+		})}, `{"error":{"kind":"input_validation_error","code":"invalid_parameters","message":"a is required; b must be a number","errors":[{"kind":"input_validation_error","param":"a","message":"a is required"},{"kind":"input_validation_error","param":"b","message":"b must be a number"}]}}`},
 		{"nil error", args{httptest.NewRecorder(), l, nil}, ""},
 	}
 
@@ -115,3 +133,147 @@ func TestHTTPErrorResponse_Body(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPErrorResponse_supportFields(t *testing.T) {
+	l := logger.NewLogger(ioutil.Discard, false)
+
+	SetSupportURLs(SupportURLs{
+		Codes:   map[string]string{"some_code": "https://example.com/errors/some_code"},
+		Default: "https://example.com/support",
+	})
+	defer SetSupportURLs(SupportURLs{})
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"code URL", E(Exist, Code("some_code"), errors.New("some error")),
+			`{"error":{"kind":"item_already_exists","code":"some_code","message":"some error","request_id":"c0mcc0st9kr3sp1ulle0","support_url":"https://example.com/errors/some_code"}}`},
+		{"default URL", E(Validation, Parameter("p"), errors.New("bad p")),
+			`{"error":{"kind":"input_validation_error","param":"p","message":"bad p","request_id":"c0mcc0st9kr3sp1ulle0","support_url":"https://example.com/support"}}`},
+		{"no body", E(Unauthorized), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set(RequestIDHeader, "c0mcc0st9kr3sp1ulle0")
+
+			HTTPErrorResponse(w, l, tt.err)
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("HTTPErrorResponse() body = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get(RequestIDHeader); got != "c0mcc0st9kr3sp1ulle0" {
+				t.Errorf("HTTPErrorResponse() %s = %v, want c0mcc0st9kr3sp1ulle0", RequestIDHeader, got)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_RetryAfter(t *testing.T) {
+	l := logger.NewLogger(ioutil.Discard, false)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"seconds", E(Unavailable, Code("datastore_unavailable"), RetryAfter(5*time.Second), errors.New("down")), "5"},
+		{"rounded up", E(Unavailable, RetryAfter(1500*time.Millisecond), errors.New("down")), "2"},
+		{"pulled up", E(Database, E(Unavailable, RetryAfter(time.Second), errors.New("down"))), "1"},
+		{"none", E(Unavailable, errors.New("down")), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, l, tt.err)
+			if got := w.Header().Get("Retry-After"); got != tt.want {
+				t.Errorf("HTTPErrorResponse() Retry-After = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorResponse_WWWAuthenticate(t *testing.T) {
+	l := logger.NewLogger(ioutil.Discard, false)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no code", E(Unauthenticated, errors.New("no token")), "Bearer"},
+		{"code", E(Unauthenticated, Code("token_expired"), errors.New("expired")), `Bearer error="invalid_token", error_description="token_expired"`},
+		{"forbidden", E(Unauthorized, Code("denied"), errors.New("denied")), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, l, tt.err)
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.want {
+				t.Errorf("HTTPErrorResponse() WWW-Authenticate = %q, want %q", got, tt.want)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("HTTPErrorResponse() body = %q, want empty", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSupportURLs_URL(t *testing.T) {
+	s := SupportURLs{
+		Codes: map[string]string{
+			"some_code":              "https://example.com/errors/some_code",
+			"input_validation_error": "https://example.com/errors/validation",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		s          SupportURLs
+		code, kind string
+		want       string
+	}{
+		{"by code", s, "some_code", "item_already_exists", "https://example.com/errors/some_code"},
+		{"by kind", s, "", "input_validation_error", "https://example.com/errors/validation"},
+		{"code not kind", s, "other_code", "input_validation_error", ""},
+		{"none", s, "", "internal_error", ""},
+		{"default", SupportURLs{Default: "https://example.com/support"}, "", "internal_error", "https://example.com/support"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.URL(tt.code, tt.kind); got != tt.want {
+				t.Errorf("URL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSupportURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		codes   string
+		want    SupportURLs
+		wantErr bool
+	}{
+		{"empty", "", "", SupportURLs{}, false},
+		{"default only", "https://example.com/support", "", SupportURLs{Default: "https://example.com/support"}, false},
+		{"codes", "", "a=https://example.com/a, b=https://example.com/b?x=1",
+			SupportURLs{Codes: map[string]string{"a": "https://example.com/a", "b": "https://example.com/b?x=1"}}, false},
+		{"no url", "", "a=", SupportURLs{}, true},
+		{"no code", "", "=https://example.com/a", SupportURLs{}, true},
+		{"no equals", "", "https://example.com/a", SupportURLs{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSupportURLs(tt.def, tt.codes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSupportURLs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSupportURLs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package errs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Report is a server error, i.e. one sent as an HTTP 5xx response,
+// to be reported to an error reporting service
+type Report struct {
+	// Err is the error sent by HTTPErrorResponse or recovered from
+	// a panic
+	Err       error
+	Time      time.Time
+	Status    int
+	RequestID string
+	// User is the email of the user who made the request, empty if
+	// the request failed before the user was authenticated
+	User   string
+	Method string
+	// Route is the path template of the route matched for the
+	// request, e.g. /api/v1/movies/{extlID}
+	Route string
+	URL   string
+}
+
+// ErrorReporter sends server errors to an error reporting service,
+// e.g. Sentry or Google Error Reporting
+type ErrorReporter interface {
+	Report(ctx context.Context, r Report) error
+}
+
+// NopReporter is an ErrorReporter which reports nothing, for when
+// no error reporting service is set up
+type NopReporter struct{}
+
+// Report does nothing
+func (NopReporter) Report(ctx context.Context, r Report) error {
+	return nil
+}
+
+// ErrorRecorder is implemented by an http.ResponseWriter which keeps
+// the server error sent by HTTPErrorResponse, so it can be reported
+// after the response is sent. Only the first error recorded for a
+// response is kept.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
+
+// StackTrace returns the stack trace of the innermost error wrapped
+// by err which has one, i.e. closest to where the error was created.
+// Errors created using E have a stack trace. StackTrace returns nil
+// if no error in the chain has one.
+func StackTrace(err error) errors.StackTrace {
+	type stackTracer interface {
+		StackTrace() errors.StackTrace
+	}
+
+	var st errors.StackTrace
+	for err != nil {
+		if s, ok := err.(stackTracer); ok {
+			st = s.StackTrace()
+		}
+		err = errors.Unwrap(err)
+	}
+	return st
+}
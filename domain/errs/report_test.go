@@ -0,0 +1,37 @@
+package errs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestStackTrace(t *testing.T) {
+	inner := errors.New("connection reset")
+	innerST := inner.(interface{ StackTrace() errors.StackTrace }).StackTrace()
+
+	tests := []struct {
+		name    string
+		err     error
+		want    errors.StackTrace
+		wantNil bool
+	}{
+		{"nil", nil, nil, true},
+		{"no stack trace", fmt.Errorf("plain"), nil, true},
+		{"pkg/errors", inner, innerST, false},
+		{"innermost of wrapped", E(Database, errors.WithMessage(inner, "query failed")), innerST, false},
+		{"wrapped by fmt", fmt.Errorf("select: %w", inner), innerST, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StackTrace(tt.err)
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("StackTrace() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("StackTrace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package errs
 
+import "strings"
+
 // MissingField is an error type that can be used when
 // validating input fields that do not have a value, but should
 type MissingField string
@@ -15,3 +17,18 @@ type InputUnwanted string
 func (e InputUnwanted) Error() string {
 	return string(e) + " has a value, but should be nil"
 }
+
+// InvalidParams is an error type that can be used when validating
+// several input parameters, so every parameter failing validation is
+// reported at once instead of only the first. Each error should be
+// built using E with the Parameter set. The errors are listed in the
+// response body (see HTTPErrorResponse).
+type InvalidParams []error
+
+func (e InvalidParams) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -85,3 +85,24 @@ func TestInputUnwanted_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestInvalidParams_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		e    InvalidParams
+		want string
+	}{
+		{"one", InvalidParams{E(Validation, Parameter("a"), MissingField("a"))}, "a is required"},
+		{"two", InvalidParams{
+			E(Validation, Parameter("a"), MissingField("a")),
+			E(Validation, Parameter("b"), "b must be a number"),
+		}, "a is required; b must be a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.Error(); got != tt.want {
+				t.Errorf("Error() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
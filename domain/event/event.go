@@ -0,0 +1,146 @@
+// Package event passes the events of the domain, such as a change to
+// a movie, from the code which publishes them to the code interested
+// in them, so neither has to know of the other. Events are typed:
+// each is a struct with a Name, and subscribers are called with the
+// events of a name only.
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// The names of the events
+const (
+	// All is not the name of an event: subscribing to All subscribes
+	// to every event
+	All = "*"
+	// MovieChangedName is the name of MovieChanged
+	MovieChangedName = "movie.changed"
+	// ChangesMissedName is the name of ChangesMissed
+	ChangesMissedName = "changes.missed"
+)
+
+// Event is an event of the domain
+type Event interface {
+	// Name returns the name of the event, which is the same for
+	// every event of a type
+	Name() string
+}
+
+// MovieChanged is published when a movie is created, updated or
+// deleted, by any instance of the server
+type MovieChanged struct {
+	// Action is create, update, upsert or delete
+	Action     string
+	ExternalID string
+}
+
+// Name returns MovieChangedName
+func (MovieChanged) Name() string { return MovieChangedName }
+
+// ChangesMissed is published when changes to Resource may have been
+// missed (e.g. the connection they are received on was lost), so
+// anything derived from the resource, such as a cache, should be
+// rebuilt
+type ChangesMissed struct {
+	Resource string
+}
+
+// Name returns ChangesMissedName
+func (ChangesMissed) Name() string { return ChangesMissedName }
+
+// Handler is called with each event it is subscribed to
+type Handler func(ctx context.Context, e Event)
+
+// Publisher publishes events
+type Publisher interface {
+	// Publish passes e to the handlers subscribed to its name
+	Publish(ctx context.Context, e Event)
+}
+
+// Subscriber subscribes handlers to events
+type Subscriber interface {
+	// Subscribe subscribes h to the events named name, or to every
+	// event if name is All. The returned function unsubscribes h.
+	Subscribe(name string, h Handler) func()
+}
+
+// Bus is a Publisher and a Subscriber
+type Bus interface {
+	Publisher
+	Subscriber
+}
+
+// OnMovieChanged subscribes fn to the MovieChanged events of s
+func OnMovieChanged(s Subscriber, fn func(ctx context.Context, e MovieChanged)) func() {
+	return s.Subscribe(MovieChangedName, func(ctx context.Context, e Event) {
+		if mc, ok := e.(MovieChanged); ok {
+			fn(ctx, mc)
+		}
+	})
+}
+
+// OnChangesMissed subscribes fn to the ChangesMissed events of s
+func OnChangesMissed(s Subscriber, fn func(ctx context.Context, e ChangesMissed)) func() {
+	return s.Subscribe(ChangesMissedName, func(ctx context.Context, e Event) {
+		if cm, ok := e.(ChangesMissed); ok {
+			fn(ctx, cm)
+		}
+	})
+}
+
+// NewInProcessBus is an initializer for InProcessBus
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{subs: make(map[string]map[int]Handler)}
+}
+
+// InProcessBus is a Bus passing events to the handlers subscribed in
+// the same process. The handlers are called in the goroutine of
+// Publish, one after the other, so they must not block. It is safe
+// for concurrent use.
+type InProcessBus struct {
+	mu     sync.RWMutex
+	subs   map[string]map[int]Handler
+	nextID int
+}
+
+// Subscribe subscribes h to the events named name, or to every event
+// if name is All. The returned function unsubscribes h.
+func (b *InProcessBus) Subscribe(name string, h Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	if b.subs[name] == nil {
+		b.subs[name] = make(map[int]Handler)
+	}
+	b.subs[name][id] = h
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[name], id)
+	}
+}
+
+// Publish calls the handlers subscribed to the name of e, and to
+// every event, with e
+func (b *InProcessBus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	var hs []Handler
+	for _, h := range b.subs[e.Name()] {
+		hs = append(hs, h)
+	}
+	for _, h := range b.subs[All] {
+		hs = append(hs, h)
+	}
+	b.mu.RUnlock()
+
+	// the lock is not held while the handlers run, so a handler can
+	// subscribe, unsubscribe or publish
+	for _, h := range hs {
+		h(ctx, e)
+	}
+}
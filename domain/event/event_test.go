@@ -0,0 +1,59 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestInProcessBus(t *testing.T) {
+	t.Run("by name", func(t *testing.T) {
+		c := qt.New(t)
+		bus := NewInProcessBus()
+
+		var (
+			changed []MovieChanged
+			missed  []ChangesMissed
+			all     []Event
+		)
+		OnMovieChanged(bus, func(ctx context.Context, e MovieChanged) { changed = append(changed, e) })
+		OnChangesMissed(bus, func(ctx context.Context, e ChangesMissed) { missed = append(missed, e) })
+		bus.Subscribe(All, func(ctx context.Context, e Event) { all = append(all, e) })
+
+		ctx := context.Background()
+		bus.Publish(ctx, MovieChanged{Action: "update", ExternalID: "abc"})
+		bus.Publish(ctx, ChangesMissed{Resource: "movies"})
+
+		c.Assert(changed, qt.DeepEquals, []MovieChanged{{Action: "update", ExternalID: "abc"}})
+		c.Assert(missed, qt.DeepEquals, []ChangesMissed{{Resource: "movies"}})
+		c.Assert(all, qt.DeepEquals, []Event{MovieChanged{Action: "update", ExternalID: "abc"}, ChangesMissed{Resource: "movies"}})
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		c := qt.New(t)
+		bus := NewInProcessBus()
+
+		var n int
+		unsubscribe := OnMovieChanged(bus, func(ctx context.Context, e MovieChanged) { n++ })
+		bus.Publish(context.Background(), MovieChanged{Action: "delete", ExternalID: "abc"})
+		unsubscribe()
+		bus.Publish(context.Background(), MovieChanged{Action: "delete", ExternalID: "abc"})
+
+		c.Assert(n, qt.Equals, 1)
+	})
+
+	t.Run("handler publishes", func(t *testing.T) {
+		c := qt.New(t)
+		bus := NewInProcessBus()
+
+		var missed int
+		OnChangesMissed(bus, func(ctx context.Context, e ChangesMissed) { missed++ })
+		OnMovieChanged(bus, func(ctx context.Context, e MovieChanged) {
+			bus.Publish(ctx, ChangesMissed{Resource: "people"})
+		})
+		bus.Publish(context.Background(), MovieChanged{Action: "create", ExternalID: "abc"})
+
+		c.Assert(missed, qt.Equals, 1)
+	})
+}
@@ -0,0 +1,190 @@
+// Package export runs export jobs: exports too large to be sent in a
+// response are written in the background to a file in object storage
+// (a Bucket), which the user downloads using a signed URL once the
+// job has succeeded.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Format is the format of an export file
+type Format string
+
+const (
+	// FormatCSV is a CSV file with a header row
+	FormatCSV Format = "csv"
+	// FormatNDJSON is a file of newline delimited JSON objects
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the Format named s, FormatCSV if s is empty
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON:
+		return FormatNDJSON, nil
+	}
+	return "", errs.E(errs.Validation, errs.Parameter("format"), errors.Errorf("format must be %s or %s, not %q", FormatCSV, FormatNDJSON, s))
+}
+
+// ContentType returns the media type of a file in the format
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Status is the status of a Job
+type Status string
+
+const (
+	// StatusPending is a job which has not started yet
+	StatusPending Status = "pending"
+	// StatusRunning is a job whose file is being written
+	StatusRunning Status = "running"
+	// StatusSucceeded is a job whose file can be downloaded
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed is a job which did not write its file, see
+	// Job.Error
+	StatusFailed Status = "failed"
+)
+
+// Done reports whether the job has finished, successfully or not
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Job is an export of a resource written to a file in the background
+type Job struct {
+	ID uuid.UUID
+	// Resource is what is exported, e.g. movies
+	Resource string
+	Format   Format
+	Status   Status
+	// Username is the email of the user who created the job, who is
+	// the only one who can find it
+	Username string
+	// Key is the key of the file in the Bucket
+	Key string
+	// Rows is the number of records written to the file
+	Rows int
+	// Error is why the job failed, as shown to the user
+	Error string
+	// CreateTime is when the job was created
+	CreateTime time.Time
+	// FinishTime is when the job finished, zero until it has
+	FinishTime time.Time
+}
+
+// NewJob returns a pending Job exporting resource for username in
+// format, with the key of its file in the Bucket
+func NewJob(id uuid.UUID, resource string, format Format, username string, now time.Time) Job {
+	return Job{
+		ID:         id,
+		Resource:   resource,
+		Format:     format,
+		Status:     StatusPending,
+		Username:   username,
+		Key:        fmt.Sprintf("exports/%s/%s.%s", resource, id, format),
+		CreateTime: now,
+	}
+}
+
+// JobStore persists Jobs
+type JobStore interface {
+	// Create adds j
+	Create(ctx context.Context, j Job) error
+	// Update records the status, rows, error and finish time of j
+	Update(ctx context.Context, j Job) error
+	// FindByID returns the job with id created by username. A job
+	// which is not found, or was created by another user, is an
+	// errs.NotExist error.
+	FindByID(ctx context.Context, id uuid.UUID, username string) (Job, error)
+}
+
+// Bucket is the object storage export files are written to
+type Bucket interface {
+	// NewWriter returns a writer for the object with key. The object
+	// is only written once the writer is closed, and is not written
+	// at all if ctx is canceled first.
+	NewWriter(ctx context.Context, key string, contentType string) (io.WriteCloser, error)
+	// SignedURL returns a URL the object with key can be downloaded
+	// from without credentials until expiry has passed
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Record is a record of an export file. It is written as its JSON
+// encoding to an NDJSON file and as its Fields to a CSV file.
+type Record interface {
+	// Fields returns the values of the record, in the order of the
+	// columns of the export
+	Fields() []string
+}
+
+// Source reads the records of an export, calling write with each
+// one. An error returned by write must be returned.
+type Source func(ctx context.Context, write func(Record) error) error
+
+// FileWriter writes Records to an export file in a Format
+type FileWriter struct {
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	header  bool
+}
+
+// NewFileWriter returns a FileWriter writing to w in format. The
+// columns are the header of a CSV file, which is written before the
+// first record, or on Flush if there are none.
+func NewFileWriter(w io.Writer, format Format, columns []string) *FileWriter {
+	if format == FormatNDJSON {
+		return &FileWriter{json: json.NewEncoder(w)}
+	}
+	return &FileWriter{columns: columns, csv: csv.NewWriter(w)}
+}
+
+// Write writes r as the next record of the file
+func (fw *FileWriter) Write(r Record) error {
+	if fw.json != nil {
+		return fw.json.Encode(r)
+	}
+	if err := fw.writeHeader(); err != nil {
+		return err
+	}
+	return fw.csv.Write(r.Fields())
+}
+
+// writeHeader writes the header of a CSV file, unless it has been
+// written
+func (fw *FileWriter) writeHeader() error {
+	if fw.header {
+		return nil
+	}
+	fw.header = true
+	return fw.csv.Write(fw.columns)
+}
+
+// Flush writes any buffered data to the file
+func (fw *FileWriter) Flush() error {
+	if fw.json != nil {
+		return nil
+	}
+	if err := fw.writeHeader(); err != nil {
+		return err
+	}
+	fw.csv.Flush()
+	return fw.csv.Error()
+}
@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// titleRecord is a Record of a movie title and rating
+type titleRecord struct {
+	Title string `json:"title"`
+	Rated string `json:"rated"`
+}
+
+func (r titleRecord) Fields() []string {
+	return []string{r.Title, r.Rated}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Format
+		wantErr bool
+	}{
+		{"", FormatCSV, false},
+		{"csv", FormatCSV, false},
+		{"ndjson", FormatNDJSON, false},
+		{"xlsx", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ParseFormat(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.Match(errs.E(errs.Validation, errs.Parameter("format")), err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	columns := []string{"title", "rated"}
+	records := []Record{titleRecord{"Repo Man", "R"}, titleRecord{"Sid, Nancy", "R"}}
+
+	tests := []struct {
+		name    string
+		format  Format
+		records []Record
+		want    string
+	}{
+		{"csv", FormatCSV, records, "title,rated\nRepo Man,R\n\"Sid, Nancy\",R\n"},
+		{"csv no records", FormatCSV, nil, "title,rated\n"},
+		{"ndjson", FormatNDJSON, records, `{"title":"Repo Man","rated":"R"}` + "\n" + `{"title":"Sid, Nancy","rated":"R"}` + "\n"},
+		{"ndjson no records", FormatNDJSON, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			fw := NewFileWriter(&buf, tt.format, columns)
+			for _, r := range tt.records {
+				c.Assert(fw.Write(r), qt.IsNil)
+			}
+			c.Assert(fw.Flush(), qt.IsNil)
+			c.Assert(buf.String(), qt.Equals, tt.want)
+
+			if tt.format == FormatNDJSON && len(tt.records) > 0 {
+				var r titleRecord
+				c.Assert(json.NewDecoder(&buf).Decode(&r), qt.IsNil)
+				c.Assert(r, qt.Equals, tt.records[0])
+			}
+		})
+	}
+}
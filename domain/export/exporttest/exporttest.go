@@ -0,0 +1,73 @@
+// Package exporttest provides testing helper functions for the
+// export package
+package exporttest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// NewBucket is an initializer for Bucket
+func NewBucket() *Bucket {
+	return &Bucket{objects: make(map[string][]byte)}
+}
+
+// Bucket is an export.Bucket holding its objects in memory. Its
+// signed URLs are https://storage.example.com/ followed by the key,
+// with the expiry as a query parameter.
+type Bucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewWriter returns a writer for the object with key, which is added
+// on Close unless ctx has been canceled
+func (b *Bucket) NewWriter(ctx context.Context, key string, contentType string) (io.WriteCloser, error) {
+	return &writer{ctx: ctx, b: b, key: key}, nil
+}
+
+// SignedURL returns the URL of the object with key
+func (b *Bucket) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://storage.example.com/" + key + "?expiry=" + url.QueryEscape(expiry.String()), nil
+}
+
+// Object returns the contents of the object with key, and whether
+// it was found
+func (b *Bucket) Object(key string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, ok := b.objects[key]
+	return o, ok
+}
+
+// writer buffers an object until it is closed
+type writer struct {
+	ctx context.Context
+	b   *Bucket
+	key string
+	buf bytes.Buffer
+}
+
+// Write adds p to the object
+func (w *writer) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Close adds the object to the bucket, unless the context of the
+// writer has been canceled
+func (w *writer) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	w.b.objects[w.key] = w.buf.Bytes()
+
+	return nil
+}
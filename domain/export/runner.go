@@ -0,0 +1,181 @@
+package export
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// finishTimeout is how long the status of a finished job is given to
+// be recorded, even when the Runner is stopping
+const finishTimeout = 10 * time.Second
+
+// NewRunner is an initializer for Runner. The returned func stops
+// the Runner: the jobs still running are canceled, and fail, and it
+// returns once they have finished. A nil bucket means exports are
+// not configured, and Start returns an errs.Unavailable error. The
+// finish time of each job is taken from clk.
+func NewRunner(bucket Bucket, store JobStore, clk clock.Clock, logger zerolog.Logger) (*Runner, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{bucket: bucket, store: store, clock: clk, logger: logger, ctx: ctx}
+
+	var once sync.Once
+	return r, func() {
+		once.Do(func() {
+			cancel()
+			r.wg.Wait()
+		})
+	}
+}
+
+// Runner runs export jobs in the background, writing each job's file
+// to the Bucket and recording its progress in the JobStore
+type Runner struct {
+	bucket Bucket
+	store  JobStore
+	clock  clock.Clock
+	logger zerolog.Logger
+	// ctx is canceled when the Runner is stopped
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+// Enabled reports whether a Bucket is configured, so jobs can be
+// started
+func (r *Runner) Enabled() bool {
+	return r != nil && r.bucket != nil
+}
+
+// SignedURL returns a URL the file of j, a job which has succeeded,
+// can be downloaded from until expiry has passed
+func (r *Runner) SignedURL(ctx context.Context, j Job, expiry time.Duration) (string, error) {
+	if !r.Enabled() {
+		return "", errs.E(errs.Unavailable, errs.Code("exports_unavailable"), errors.New("no bucket is configured for exports"))
+	}
+	if j.Status != StatusSucceeded {
+		return "", errs.E(errs.Internal, errors.Errorf("export job %s has not succeeded", j.ID))
+	}
+	return r.bucket.SignedURL(ctx, j.Key, expiry)
+}
+
+// Start adds the pending job j to the JobStore and runs it in the
+// background, writing the records read by src to its file, with
+// columns as the header of a CSV file. If src fails with an
+// errs.Unprocessable error, e.g. as there are more records than an
+// export can hold, the job fails with the error's message as its
+// Error. The job runs with the values
+// of ctx (e.g. the tenant of the request), but is not canceled with
+// it, so it outlives the request starting it.
+func (r *Runner) Start(ctx context.Context, j Job, columns []string, src Source) error {
+	if !r.Enabled() {
+		return errs.E(errs.Unavailable, errs.Code("exports_unavailable"), errors.New("no bucket is configured for exports"))
+	}
+
+	if err := r.store.Create(ctx, j); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(detach(r.ctx, ctx), j, columns, src)
+	}()
+
+	return nil
+}
+
+// run writes the file of j and records the job's status
+func (r *Runner) run(ctx context.Context, j Job, columns []string, src Source) {
+	logger := r.logger.With().Str("export_job_id", j.ID.String()).Logger()
+
+	j.Status = StatusRunning
+	if err := r.store.Update(ctx, j); err != nil {
+		logger.Error().Err(err).Msg("export job status not recorded")
+	}
+
+	err := r.write(ctx, &j, columns, src)
+
+	j.Status = StatusSucceeded
+	j.FinishTime = r.clock.Now().UTC()
+	switch {
+	case err == nil:
+		logger.Info().Int("rows", j.Rows).Msg("export job succeeded")
+	// an export exceeding a limit fails with the reason, e.g. there
+	// being more rows than an export can hold
+	case errs.KindIs(errs.Unprocessable, err):
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		logger.Info().Err(err).Msg("export job failed")
+	case r.ctx.Err() != nil:
+		j.Status = StatusFailed
+		j.Error = "the export was interrupted, it must be started again"
+		logger.Warn().Err(err).Msg("export job interrupted")
+	default:
+		j.Status = StatusFailed
+		j.Error = "the export file could not be written"
+		logger.Error().Err(err).Msg("export job failed")
+	}
+
+	// the status is recorded even if the Runner is stopping
+	fctx, cancel := context.WithTimeout(detach(context.Background(), ctx), finishTimeout)
+	defer cancel()
+	if err := r.store.Update(fctx, j); err != nil {
+		logger.Error().Err(err).Msg("export job status not recorded")
+	}
+}
+
+// write writes the records read by src to the file of j, counting
+// them in j.Rows. The file is not written if an error occurs.
+func (r *Runner) write(ctx context.Context, j *Job, columns []string, src Source) error {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := r.bucket.NewWriter(wctx, j.Key, j.Format.ContentType())
+	if err != nil {
+		return err
+	}
+
+	fw := NewFileWriter(w, j.Format, columns)
+	err = src(wctx, func(rec Record) error {
+		if err := fw.Write(rec); err != nil {
+			return errs.E(errs.Internal, err)
+		}
+		j.Rows++
+		return nil
+	})
+	if err == nil {
+		err = fw.Flush()
+	}
+	if err != nil {
+		// canceling the context before closing discards the object
+		cancel()
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// detachedContext is a context with the values of one context and
+// the deadline and cancellation of another
+type detachedContext struct {
+	context.Context
+	values context.Context
+}
+
+// detach returns a context canceled with parent, holding the values
+// of values
+func detach(parent, values context.Context) context.Context {
+	return detachedContext{Context: parent, values: values}
+}
+
+// Value returns the value for key from the values context
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
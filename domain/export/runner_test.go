@@ -0,0 +1,194 @@
+package export
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export/exporttest"
+)
+
+// mapStore is a JobStore holding jobs in a map
+type mapStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]Job
+}
+
+func (s *mapStore) Create(ctx context.Context, j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = j
+	return nil
+}
+
+func (s *mapStore) Update(ctx context.Context, j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = j
+	return nil
+}
+
+func (s *mapStore) FindByID(ctx context.Context, id uuid.UUID, username string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.Username != username {
+		return Job{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return j, nil
+}
+
+// waitDone waits for the job with id to finish and returns it
+func waitDone(t *testing.T, s JobStore, id uuid.UUID) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := s.FindByID(context.Background(), id, "otto@example.com")
+		if err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if j.Status.Done() {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s not done, status %s", id, j.Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// tenantKey is the key of a value the job must keep from the
+// context it is started with
+type tenantKey struct{}
+
+func TestRunner(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        Source
+		wantStatus Status
+		wantRows   int
+		wantFile   string
+		wantError  string
+	}{
+		{
+			name: "succeeded",
+			src: func(ctx context.Context, write func(Record) error) error {
+				// the values of the request context are kept
+				if ctx.Value(tenantKey{}) != "acme" {
+					return errors.New("tenant not set")
+				}
+				for _, r := range []Record{titleRecord{"Repo Man", "R"}, titleRecord{"Brazil", "R"}} {
+					if err := write(r); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantStatus: StatusSucceeded,
+			wantRows:   2,
+			wantFile:   "title,rated\nRepo Man,R\nBrazil,R\n",
+		},
+		{
+			name: "failed",
+			src: func(ctx context.Context, write func(Record) error) error {
+				if err := write(titleRecord{"Repo Man", "R"}); err != nil {
+					return err
+				}
+				return errs.E(errs.Database, "connection reset")
+			},
+			wantStatus: StatusFailed,
+			wantRows:   1,
+		},
+		{
+			name: "too many rows",
+			src: func(ctx context.Context, write func(Record) error) error {
+				return errs.E(errs.Unprocessable, "3 movies match, more than the 2 an export can hold")
+			},
+			wantStatus: StatusFailed,
+			wantError:  "3 movies match, more than the 2 an export can hold",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			store := &mapStore{jobs: make(map[uuid.UUID]Job)}
+			bucket := exporttest.NewBucket()
+			r, stop := NewRunner(bucket, store, clocktest.NewMockClock(t), zerolog.Nop())
+			t.Cleanup(stop)
+
+			// the job outlives the request starting it
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tenantKey{}, "acme"))
+			j := NewJob(uuid.New(), "movies", FormatCSV, "otto@example.com", time.Now())
+			c.Assert(r.Start(ctx, j, []string{"title", "rated"}, tt.src), qt.IsNil)
+			cancel()
+
+			got := waitDone(t, store, j.ID)
+			c.Assert(got.Status, qt.Equals, tt.wantStatus)
+			c.Assert(got.Rows, qt.Equals, tt.wantRows)
+			c.Assert(got.FinishTime, qt.Equals, clocktest.Time)
+
+			file, ok := bucket.Object(j.Key)
+			if tt.wantStatus == StatusFailed {
+				c.Assert(ok, qt.IsFalse)
+				c.Assert(got.Error, qt.Not(qt.Equals), "")
+				if tt.wantError != "" {
+					c.Assert(got.Error, qt.Equals, tt.wantError)
+				}
+				_, err := r.SignedURL(context.Background(), got, time.Minute)
+				c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+				return
+			}
+			c.Assert(string(file), qt.Equals, tt.wantFile)
+
+			u, err := r.SignedURL(context.Background(), got, time.Minute)
+			c.Assert(err, qt.IsNil)
+			c.Assert(u, qt.Equals, "https://storage.example.com/"+j.Key+"?expiry=1m0s")
+		})
+	}
+
+	t.Run("stopped", func(t *testing.T) {
+		c := qt.New(t)
+
+		store := &mapStore{jobs: make(map[uuid.UUID]Job)}
+		r, stop := NewRunner(exporttest.NewBucket(), store, clocktest.NewMockClock(t), zerolog.Nop())
+
+		started := make(chan struct{})
+		j := NewJob(uuid.New(), "movies", FormatNDJSON, "otto@example.com", time.Now())
+		c.Assert(r.Start(context.Background(), j, nil, func(ctx context.Context, write func(Record) error) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}), qt.IsNil)
+		<-started
+
+		// stopping waits for the job, which is recorded as failed
+		stop()
+		got, err := store.FindByID(context.Background(), j.ID, j.Username)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.Status, qt.Equals, StatusFailed)
+		c.Assert(got.Error, qt.Contains, "interrupted")
+	})
+
+	t.Run("no bucket", func(t *testing.T) {
+		r, stop := NewRunner(nil, &mapStore{jobs: make(map[uuid.UUID]Job)}, clocktest.NewMockClock(t), zerolog.Nop())
+		defer stop()
+
+		j := NewJob(uuid.New(), "movies", FormatCSV, "otto@example.com", time.Now())
+		err := r.Start(context.Background(), j, nil, nil)
+		qt.Assert(t, errs.KindIs(errs.Unavailable, err), qt.IsTrue)
+
+		j.Status = StatusSucceeded
+		_, err = r.SignedURL(context.Background(), j, time.Minute)
+		qt.Assert(t, errs.KindIs(errs.Unavailable, err), qt.IsTrue)
+	})
+}
@@ -0,0 +1,457 @@
+// Package filter parses filter expressions written in RSQL, a query
+// language based on FIQL, e.g. rated==R;run_time=gt=90. Comparisons
+// are joined with ; (and) or , (or), and can be grouped with
+// parentheses. And binds tighter than or.
+//
+// Only the fields given to Parse can be used and every value is
+// converted to the type of its field, so an Expr can be safely turned
+// into a parameterized where clause, or matched in memory with Match.
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// MaxComparisons is the maximum number of comparisons in an
+// expression
+const MaxComparisons = 20
+
+// Type is the type of a field
+type Type int
+
+// Field types. String fields are compared as text, Int fields as
+// numbers and Time fields as RFC 3339 timestamps or dates
+// (2006-01-02).
+const (
+	String Type = iota
+	Int
+	Time
+)
+
+// Fields are the fields which can be used in an expression, by name
+type Fields map[string]Type
+
+// Operator is a comparison operator
+type Operator string
+
+// Comparison operators. The RSQL aliases <, <=, > and >= are parsed
+// as LessThan, LessOrEqual, GreaterThan and GreaterOrEqual.
+const (
+	Equal          Operator = "=="
+	NotEqual       Operator = "!="
+	LessThan       Operator = "=lt="
+	LessOrEqual    Operator = "=le="
+	GreaterThan    Operator = "=gt="
+	GreaterOrEqual Operator = "=ge="
+	In             Operator = "=in="
+	NotIn          Operator = "=out="
+)
+
+// Wildcard matches any run of characters when in a String value
+// compared with Equal or NotEqual, e.g. title==Repo*
+const Wildcard = "*"
+
+// operators are the operators by their text in an expression
+var operators = map[string]Operator{
+	"==":    Equal,
+	"!=":    NotEqual,
+	"=lt=":  LessThan,
+	"<":     LessThan,
+	"=le=":  LessOrEqual,
+	"<=":    LessOrEqual,
+	"=gt=":  GreaterThan,
+	">":     GreaterThan,
+	"=ge=":  GreaterOrEqual,
+	">=":    GreaterOrEqual,
+	"=in=":  In,
+	"=out=": NotIn,
+}
+
+// Logic is how the Exprs of an Expr are joined
+type Logic int
+
+// Logic values
+const (
+	And Logic = iota
+	Or
+)
+
+// Expr is a parsed filter expression. If Exprs is empty, the Expr is
+// a comparison of Field to Values using Operator. Otherwise, the
+// Expr joins its Exprs using Logic.
+type Expr struct {
+	Logic Logic
+	Exprs []*Expr
+
+	Field    string
+	Type     Type
+	Operator Operator
+	// Values holds a string, int or time.Time for each value,
+	// depending on Type. Only In and NotIn have more than one value.
+	Values []interface{}
+}
+
+// IsWildcard reports whether the comparison is a String match using
+// a Wildcard
+func (e *Expr) IsWildcard() bool {
+	if e.Type != String || (e.Operator != Equal && e.Operator != NotEqual) {
+		return false
+	}
+	s, ok := e.Values[0].(string)
+	return ok && strings.Contains(s, Wildcard)
+}
+
+// Parse parses the expression s using fields. Any error is a
+// Validation error for the filter parameter.
+func Parse(s string, fields Fields) (*Expr, error) {
+	p := parser{s: s, fields: fields}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+
+	return e, nil
+}
+
+// parser is a recursive descent parser for an expression
+type parser struct {
+	s      string
+	pos    int
+	fields Fields
+	n      int
+}
+
+// errorf returns a Validation error with the message formatted
+// using format and args, followed by the position in the expression
+func (p *parser) errorf(format string, args ...interface{}) error {
+	msg := errors.Errorf(format, args...).Error()
+	return errs.E(errs.Validation, errs.Parameter("filter"), errors.Errorf("%s at position %d of filter", msg, p.pos+1))
+}
+
+// next reports whether the next character is c, consuming it if it is
+func (p *parser) next(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// or parses and expressions joined by ,
+func (p *parser) or() (*Expr, error) {
+	return p.join(Or, ',', p.and)
+}
+
+// and parses constraints joined by ;
+func (p *parser) and() (*Expr, error) {
+	return p.join(And, ';', p.constraint)
+}
+
+// join parses expressions using parse joined by sep
+func (p *parser) join(l Logic, sep byte, parse func() (*Expr, error)) (*Expr, error) {
+	var exprs []*Expr
+	for {
+		e, err := parse()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.next(sep) {
+			break
+		}
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &Expr{Logic: l, Exprs: exprs}, nil
+}
+
+// constraint parses a group in parentheses or a comparison
+func (p *parser) constraint() (*Expr, error) {
+	if !p.next('(') {
+		return p.comparison()
+	}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.next(')') {
+		return nil, p.errorf("expected )")
+	}
+
+	return e, nil
+}
+
+// comparison parses a field, operator and value(s)
+func (p *parser) comparison() (*Expr, error) {
+	p.n++
+	if p.n > MaxComparisons {
+		return nil, p.errorf("filter cannot have more than %d comparisons", MaxComparisons)
+	}
+
+	field := p.unreserved()
+	if field == "" {
+		return nil, p.errorf("expected a field")
+	}
+	typ, ok := p.fields[field]
+	if !ok {
+		return nil, p.errorf("unknown field %q", field)
+	}
+
+	op, err := p.operator()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Expr{Field: field, Type: typ, Operator: op}
+
+	// only In and NotIn take a list of values
+	if !p.next('(') {
+		v, err := p.value(typ)
+		if err != nil {
+			return nil, err
+		}
+		e.Values = []interface{}{v}
+		return e, nil
+	}
+	if op != In && op != NotIn {
+		return nil, p.errorf("only %s and %s take a list of values", In, NotIn)
+	}
+	for {
+		v, err := p.value(typ)
+		if err != nil {
+			return nil, err
+		}
+		e.Values = append(e.Values, v)
+		if !p.next(',') {
+			break
+		}
+	}
+	if !p.next(')') {
+		return nil, p.errorf("expected )")
+	}
+
+	return e, nil
+}
+
+// operator parses a comparison operator
+func (p *parser) operator() (Operator, error) {
+	rest := p.s[p.pos:]
+
+	var text string
+	switch {
+	case strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="),
+		strings.HasPrefix(rest, "<="), strings.HasPrefix(rest, ">="):
+		text = rest[:2]
+	case strings.HasPrefix(rest, "<"), strings.HasPrefix(rest, ">"):
+		text = rest[:1]
+	case strings.HasPrefix(rest, "="):
+		// =name=
+		end := strings.IndexByte(rest[1:], '=')
+		if end >= 0 {
+			text = rest[:end+2]
+		}
+	}
+
+	op, ok := operators[text]
+	if !ok {
+		return "", p.errorf("expected an operator")
+	}
+	p.pos += len(text)
+
+	return op, nil
+}
+
+// unreserved parses a run of characters with no meaning in an
+// expression
+func (p *parser) unreserved() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(`"'();,=!~<> `, rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// value parses a value, quoted or not, and converts it to typ
+func (p *parser) value(typ Type) (interface{}, error) {
+	start := p.pos
+
+	var s string
+	if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		var err error
+		s, err = p.quoted()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		s = p.unreserved()
+		if s == "" {
+			return nil, p.errorf("expected a value")
+		}
+	}
+
+	switch typ {
+	case Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("%q is not a number", s)
+		}
+		return i, nil
+	case Time:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("%q is not a date", s)
+		}
+		return t, nil
+	default:
+		return s, nil
+	}
+}
+
+// quoted parses a value in single or double quotes. A backslash
+// escapes the character following it.
+func (p *parser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", p.errorf("unterminated quoted value")
+}
+
+// Match reports whether the values returned by value match e. A nil
+// Expr matches everything. value returns the value of a field, of the
+// field's type.
+func Match(e *Expr, value func(field string) interface{}) bool {
+	if e == nil {
+		return true
+	}
+
+	if len(e.Exprs) > 0 {
+		for _, x := range e.Exprs {
+			m := Match(x, value)
+			if e.Logic == Or && m {
+				return true
+			}
+			if e.Logic == And && !m {
+				return false
+			}
+		}
+		return e.Logic == And
+	}
+
+	v := value(e.Field)
+	if e.IsWildcard() {
+		s, _ := v.(string)
+		m := wildcardMatch(e.Values[0].(string), s)
+		return m == (e.Operator == Equal)
+	}
+
+	switch e.Operator {
+	case Equal:
+		return compare(v, e.Values[0]) == 0
+	case NotEqual:
+		return compare(v, e.Values[0]) != 0
+	case LessThan:
+		return compare(v, e.Values[0]) < 0
+	case LessOrEqual:
+		return compare(v, e.Values[0]) <= 0
+	case GreaterThan:
+		return compare(v, e.Values[0]) > 0
+	case GreaterOrEqual:
+		return compare(v, e.Values[0]) >= 0
+	case In, NotIn:
+		in := false
+		for _, x := range e.Values {
+			if compare(v, x) == 0 {
+				in = true
+				break
+			}
+		}
+		return in == (e.Operator == In)
+	}
+
+	return false
+}
+
+// compare returns -1, 0 or 1 as a is less than, equal to or greater
+// than b, which must be of the same type
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b, _ := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		b, _ := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	}
+	return -1
+}
+
+// wildcardMatch reports whether s matches pattern, where each
+// Wildcard matches any run of characters. pattern must have at least
+// one Wildcard.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, Wildcard)
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+
+	return strings.HasSuffix(s, last)
+}
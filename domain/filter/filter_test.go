@@ -0,0 +1,141 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+var testFields = Fields{
+	"title":    String,
+	"rated":    String,
+	"released": Time,
+	"run_time": Int,
+}
+
+func TestParse(t *testing.T) {
+	released := time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		s    string
+		want *Expr
+	}{
+		{"equal", "rated==R", &Expr{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"R"}}},
+		{"int", "run_time=gt=90", &Expr{Field: "run_time", Type: Int, Operator: GreaterThan, Values: []interface{}{90}}},
+		{"alias", "run_time<=90", &Expr{Field: "run_time", Type: Int, Operator: LessOrEqual, Values: []interface{}{90}}},
+		{"date", "released=ge=1984-03-02", &Expr{Field: "released", Type: Time, Operator: GreaterOrEqual, Values: []interface{}{released}}},
+		{"timestamp", "released==1984-03-02T00:00:00Z", &Expr{Field: "released", Type: Time, Operator: Equal, Values: []interface{}{released}}},
+		{"quoted", `title=="Repo \"Man\""`, &Expr{Field: "title", Type: String, Operator: Equal, Values: []interface{}{`Repo "Man"`}}},
+		{"in", "rated=in=(R,'PG-13')", &Expr{Field: "rated", Type: String, Operator: In, Values: []interface{}{"R", "PG-13"}}},
+		{"and", "rated==R;run_time=gt=90", &Expr{Logic: And, Exprs: []*Expr{
+			{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"R"}},
+			{Field: "run_time", Type: Int, Operator: GreaterThan, Values: []interface{}{90}},
+		}}},
+		{"and binds tighter than or", "rated==G,rated==R;run_time<90", &Expr{Logic: Or, Exprs: []*Expr{
+			{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"G"}},
+			{Logic: And, Exprs: []*Expr{
+				{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"R"}},
+				{Field: "run_time", Type: Int, Operator: LessThan, Values: []interface{}{90}},
+			}},
+		}}},
+		{"group", "(rated==G,rated==R);run_time<90", &Expr{Logic: And, Exprs: []*Expr{
+			{Logic: Or, Exprs: []*Expr{
+				{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"G"}},
+				{Field: "rated", Type: String, Operator: Equal, Values: []interface{}{"R"}},
+			}},
+			{Field: "run_time", Type: Int, Operator: LessThan, Values: []interface{}{90}},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := Parse(tt.s, testFields)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tooMany := strings.Repeat("rated==R;", MaxComparisons) + "rated==R"
+
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"unknown field", "budget=gt=10"},
+		{"no operator", "rated"},
+		{"unknown operator", "rated=like=R"},
+		{"no value", "rated=="},
+		{"not a number", "run_time=gt=long"},
+		{"not a date", "released=gt=yesterday"},
+		{"list for equal", "rated==(R,G)"},
+		{"unclosed list", "rated=in=(R,G"},
+		{"unclosed group", "(rated==R"},
+		{"unterminated quote", `title=="Repo`},
+		{"trailing", "rated==R)"},
+		{"sql", "rated==R' or 1=1"},
+		{"too many comparisons", tooMany},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			_, err := Parse(tt.s, testFields)
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue, qt.Commentf("err = %v", err))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	values := map[string]interface{}{
+		"title":    "Repo Man",
+		"rated":    "R",
+		"released": time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		"run_time": 92,
+	}
+	value := func(field string) interface{} { return values[field] }
+
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"rated==R", true},
+		{"rated!=R", false},
+		{"run_time=gt=90", true},
+		{"run_time=lt=90", false},
+		{"run_time>=92", true},
+		{"released=lt=1985-01-01", true},
+		{"rated=in=(G,R)", true},
+		{"rated=out=(G,R)", false},
+		{"title==Repo*", true},
+		{"title==*Man", true},
+		{"title==R*o*n", true},
+		{"title==*Men", false},
+		{"title!=Rep*", false},
+		{"rated==R;run_time=gt=90", true},
+		{"rated==G;run_time=gt=90", false},
+		{"rated==G,run_time=gt=90", true},
+		{"rated==G,(rated==R;run_time<90)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			c := qt.New(t)
+
+			e, err := Parse(tt.s, testFields)
+			c.Assert(err, qt.IsNil)
+			c.Assert(Match(e, value), qt.Equals, tt.want)
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		qt.Assert(t, Match(nil, value), qt.IsTrue)
+	})
+}
@@ -0,0 +1,49 @@
+package idgen
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+)
+
+// fixedClock is a clock frozen at t
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
+
+func TestDefaultUUIDGenerator_NewUUID(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 2, 22, 14, 22, 22, 0, time.UTC)
+	g := DefaultUUIDGenerator{Clock: fixedClock{now}}
+
+	u, err := g.NewUUID()
+	c.Assert(err, qt.IsNil)
+	c.Assert(u.Version(), qt.Equals, uuid.Version(7))
+	c.Assert(u.Variant(), qt.Equals, uuid.RFC4122)
+
+	// the first 48 bits are the Unix time in milliseconds
+	var ms int64
+	for _, b := range u[:6] {
+		ms = ms<<8 | int64(b)
+	}
+	c.Assert(ms, qt.Equals, now.UnixNano()/int64(time.Millisecond))
+
+	// the remaining bits are random
+	u2, err := g.NewUUID()
+	c.Assert(err, qt.IsNil)
+	c.Assert(u2, qt.Not(qt.Equals), u)
+
+	// a UUID generated later sorts after
+	later, err := DefaultUUIDGenerator{Clock: fixedClock{now.Add(time.Millisecond)}}.NewUUID()
+	c.Assert(err, qt.IsNil)
+	c.Assert(bytes.Compare(later[:], u[:]), qt.Equals, 1)
+	c.Assert(later.String() > u.String(), qt.IsTrue)
+}
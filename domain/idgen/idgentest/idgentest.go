@@ -0,0 +1,28 @@
+// Package idgentest has test helpers for the idgen package
+package idgentest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// UUID is the UUID returned by MockUUIDGenerator
+var UUID = uuid.MustParse("017f22e2-79b0-7cc3-98c4-dc0c0c07398f")
+
+// NewMockUUIDGenerator is an initializer for MockUUIDGenerator
+func NewMockUUIDGenerator(t testing.TB) MockUUIDGenerator {
+	return MockUUIDGenerator{t: t}
+}
+
+// MockUUIDGenerator creates a static UUID for testing
+type MockUUIDGenerator struct {
+	t testing.TB
+}
+
+// NewUUID returns UUID
+func (g MockUUIDGenerator) NewUUID() (uuid.UUID, error) {
+	g.t.Helper()
+
+	return UUID, nil
+}
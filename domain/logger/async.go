@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+// DefaultAsyncBufferSize is the number of log lines an AsyncWriter
+// buffers when no size is given
+const DefaultAsyncBufferSize int = 10000
+
+// linesDropped counts the log lines dropped by an AsyncWriter
+// because its buffer was full
+var linesDropped = metrics.NewCounter("go_api_basic_log_lines_dropped_total",
+	"Total number of log lines dropped because the async log buffer was full.")
+
+// NewAsyncWriter is an initializer for AsyncWriter, which writes to w
+// from a background goroutine, buffering up to size lines (or
+// DefaultAsyncBufferSize if size is not positive). The returned
+// cleanup function writes any buffered lines and stops the writer;
+// lines logged afterwards are written to w directly.
+func NewAsyncWriter(w io.Writer, size int) (*AsyncWriter, func()) {
+	if size <= 0 {
+		size = DefaultAsyncBufferSize
+	}
+	aw := &AsyncWriter{
+		w:       w,
+		lines:   make(chan []byte, size),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go aw.run()
+
+	var once sync.Once
+	return aw, func() {
+		once.Do(func() {
+			aw.mu.Lock()
+			aw.closed = true
+			close(aw.lines)
+			aw.mu.Unlock()
+			<-aw.done
+		})
+	}
+}
+
+// AsyncWriter is a zerolog.LevelWriter which never blocks the
+// goroutine logging: lines are queued and written to the underlying
+// writer from a background goroutine, so a slow stdout or disk does
+// not hold up requests. If the buffer is full the line is dropped and
+// counted in the go_api_basic_log_lines_dropped_total metric. Fatal
+// and panic lines are written synchronously, after any buffered
+// lines, as the program is about to exit.
+type AsyncWriter struct {
+	w       io.Writer
+	lines   chan []byte
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	// mu guards closed; it is held for reading while queueing a
+	// line so lines is not closed during a send
+	mu     sync.RWMutex
+	closed bool
+
+	// writeMu serializes writes to w
+	writeMu sync.Mutex
+}
+
+// Write queues a copy of p (zerolog reuses its buffers) to be
+// written. Write never blocks and always reports p as written.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.closed {
+		return aw.write(p)
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+	select {
+	case aw.lines <- line:
+	default:
+		linesDropped.Inc()
+	}
+	return len(p), nil
+}
+
+// WriteLevel satisfies zerolog.LevelWriter. Fatal and panic lines
+// are written once the buffered lines are, before WriteLevel
+// returns; other levels are queued as by Write.
+func (aw *AsyncWriter) WriteLevel(l zerolog.Level, p []byte) (int, error) {
+	if l != zerolog.FatalLevel && l != zerolog.PanicLevel {
+		return aw.Write(p)
+	}
+
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if !aw.closed {
+		ack := make(chan struct{})
+		aw.flushes <- ack
+		<-ack
+	}
+	return aw.write(p)
+}
+
+// write writes p to the underlying writer
+func (aw *AsyncWriter) write(p []byte) (int, error) {
+	aw.writeMu.Lock()
+	defer aw.writeMu.Unlock()
+
+	return aw.w.Write(p)
+}
+
+// run writes queued lines until the lines channel is closed, and
+// writes all queued lines when a flush is asked for
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+
+	for {
+		select {
+		case line, ok := <-aw.lines:
+			if !ok {
+				return
+			}
+			_, _ = aw.write(line)
+		case ack := <-aw.flushes:
+			aw.drain()
+			close(ack)
+		}
+	}
+}
+
+// drain writes the lines queued, without waiting for more
+func (aw *AsyncWriter) drain() {
+	for {
+		select {
+		case line := <-aw.lines:
+			_, _ = aw.write(line)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+)
+
+// blockingWriter is an io.Writer which signals started on its first
+// Write and blocks every Write until unblock is closed
+type blockingWriter struct {
+	once    sync.Once
+	started chan struct{}
+	unblock chan struct{}
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{started: make(chan struct{}), unblock: make(chan struct{})}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	bw.once.Do(func() { close(bw.started) })
+	<-bw.unblock
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.buf.Write(p)
+}
+
+func (bw *blockingWriter) String() string {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	aw, flush := NewAsyncWriter(&buf, 0)
+	lgr := zerolog.New(aw)
+
+	lgr.Info().Msg("one")
+	lgr.Info().Msg("two")
+	flush()
+
+	c.Assert(buf.String(), qt.Equals, `{"level":"info","message":"one"}`+"\n"+`{"level":"info","message":"two"}`+"\n")
+
+	// lines logged once flushed are written directly
+	lgr.Info().Msg("three")
+	c.Assert(buf.String(), qt.Contains, `{"level":"info","message":"three"}`)
+
+	// flush can be called more than once
+	flush()
+}
+
+func TestAsyncWriter_dropped(t *testing.T) {
+	c := qt.New(t)
+
+	bw := newBlockingWriter()
+	aw, flush := NewAsyncWriter(bw, 1)
+
+	before := linesDropped.Value()
+
+	// the first line is taken by the background goroutine, which
+	// blocks writing it, the second fills the buffer and the third
+	// is dropped without blocking
+	n, err := aw.Write([]byte("one\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 4)
+	<-bw.started
+	_, _ = aw.Write([]byte("two\n"))
+	n, err = aw.Write([]byte("three\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 6)
+
+	c.Assert(linesDropped.Value()-before, qt.Equals, float64(1))
+
+	close(bw.unblock)
+	flush()
+	c.Assert(bw.String(), qt.Equals, "one\ntwo\n")
+}
+
+func TestAsyncWriter_WriteLevel(t *testing.T) {
+	c := qt.New(t)
+
+	bw := newBlockingWriter()
+	aw, flush := NewAsyncWriter(bw, 0)
+	defer flush()
+
+	_, _ = aw.WriteLevel(zerolog.InfoLevel, []byte("info\n"))
+	_, _ = aw.WriteLevel(zerolog.InfoLevel, []byte("info\n"))
+	close(bw.unblock)
+
+	// a fatal line is written after the lines buffered, before
+	// WriteLevel returns
+	_, err := aw.WriteLevel(zerolog.FatalLevel, []byte("fatal\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(bw.String(), qt.Equals, "info\ninfo\nfatal\n")
+}
@@ -0,0 +1,482 @@
+// Package metrics is a minimal registry of application metrics which
+// are exposed using the Prometheus text exposition format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/, or
+// the OpenMetrics format (with exemplars) when a scraper asks for it,
+// see https://github.com/OpenObservability/OpenMetrics
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// format is an exposition format
+type format int
+
+// Exposition formats. Exemplars are only written in the OpenMetrics
+// format, as the Prometheus text format has no syntax for them.
+const (
+	textFormat format = iota
+	openMetricsFormat
+)
+
+// Content types of the exposition formats
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// DefaultRegistry is the Registry used by the New* metric
+// initializers and served by the metrics handler
+var DefaultRegistry = NewRegistry()
+
+// collector is implemented by each metric type in order to be
+// written out by the Registry
+type collector interface {
+	name() string
+	write(buf *bytes.Buffer, f format)
+}
+
+// Registry holds a set of metrics
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry is an initializer for Registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// register adds the collector to the Registry. If a collector with
+// the same name is already registered, the existing one is returned
+// so metrics can be safely declared more than once (e.g. in tests).
+func (r *Registry) register(c collector) collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.collectors[c.name()]; ok {
+		return existing
+	}
+	r.collectors[c.name()] = c
+
+	return c
+}
+
+// WriteText writes all metrics in the Registry to buf using the
+// Prometheus text exposition format, sorted by metric name
+func (r *Registry) WriteText(buf *bytes.Buffer) {
+	r.writeAll(buf, textFormat)
+}
+
+// WriteOpenMetrics writes all metrics in the Registry to buf using
+// the OpenMetrics text format, sorted by metric name. Histogram
+// buckets are written with their exemplars.
+func (r *Registry) WriteOpenMetrics(buf *bytes.Buffer) {
+	r.writeAll(buf, openMetricsFormat)
+	buf.WriteString("# EOF\n")
+}
+
+// writeAll writes all metrics in the Registry to buf in format f
+func (r *Registry) writeAll(buf *bytes.Buffer, f format) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for n := range r.collectors {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	cs := make([]collector, 0, len(names))
+	for _, n := range names {
+		cs = append(cs, r.collectors[n])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range cs {
+		c.write(buf, f)
+	}
+}
+
+// Handler returns an http.Handler which serves the metrics in the
+// Registry using the Prometheus text exposition format, or the
+// OpenMetrics format if the Accept header asks for it (as Prometheus
+// does when exemplar storage is enabled)
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			r.WriteOpenMetrics(&buf)
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			r.WriteText(&buf)
+			w.Header().Set("Content-Type", textContentType)
+		}
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// vec holds the values of a metric by label values
+type vec struct {
+	mu         sync.Mutex
+	metricName string
+	help       string
+	labelNames []string
+	values     map[string]float64
+	labels     map[string][]string
+}
+
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// key returns the map key for the given label values and ensures
+// the number of label values matches the number of label names
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// writeType writes the HELP and TYPE lines of the metric family
+// named family
+func (v *vec) writeType(buf *bytes.Buffer, family, typ string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", family, escapeHelp(v.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", family, typ)
+}
+
+// writeValues writes a sample named sample for each set of label
+// values
+func (v *vec) writeValues(buf *bytes.Buffer, sample string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		buf.WriteString(sample)
+		writeLabels(buf, v.labelNames, v.labels[k], "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(formatFloat(v.values[k]))
+		buf.WriteByte('\n')
+	}
+}
+
+// Gauge is a metric which represents a single value that can go
+// up and down, optionally partitioned by labels
+type Gauge struct {
+	vec
+}
+
+// NewGauge initializes a Gauge and registers it with the
+// DefaultRegistry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.register(&Gauge{newVec(name, help, labelNames)}).(*Gauge)
+}
+
+// Set sets the Gauge to v for the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	k := g.key(labelValues)
+	g.mu.Lock()
+	g.values[k] = v
+	g.labels[k] = labelValues
+	g.mu.Unlock()
+}
+
+// Value returns the current value of the Gauge for the given label
+// values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	k := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[k]
+}
+
+func (g *Gauge) write(buf *bytes.Buffer, f format) {
+	g.writeType(buf, g.metricName, "gauge")
+	g.writeValues(buf, g.metricName)
+}
+
+// Counter is a metric which represents a cumulative value that only
+// goes up, optionally partitioned by labels
+type Counter struct {
+	vec
+}
+
+// NewCounter initializes a Counter and registers it with the
+// DefaultRegistry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.register(&Counter{newVec(name, help, labelNames)}).(*Counter)
+}
+
+// Inc increments the Counter by 1 for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the Counter by v for the given label values. Add
+// panics if v is negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.metricName))
+	}
+	k := c.key(labelValues)
+	c.mu.Lock()
+	c.values[k] += v
+	c.labels[k] = labelValues
+	c.mu.Unlock()
+}
+
+// Value returns the current value of the Counter for the given
+// label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	k := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[k]
+}
+
+func (c *Counter) write(buf *bytes.Buffer, f format) {
+	if f == openMetricsFormat {
+		// OpenMetrics names the counter family without the _total
+		// suffix, which every counter sample must have
+		family := strings.TrimSuffix(c.metricName, "_total")
+		c.writeType(buf, family, "counter")
+		c.writeValues(buf, family+"_total")
+		return
+	}
+	c.writeType(buf, c.metricName, "counter")
+	c.writeValues(buf, c.metricName)
+}
+
+// DefBuckets are the default Histogram buckets, suited to the
+// latency of a network service in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Labels are the labels of an exemplar, e.g. trace_id
+type Labels map[string]string
+
+// exemplar is an example observation of a Histogram bucket
+type exemplar struct {
+	labels Labels
+	value  float64
+	ts     time.Time
+}
+
+// histogramSeries holds the observations of a Histogram for one set
+// of label values
+type histogramSeries struct {
+	labels []string
+	// counts are the observations per bucket (not cumulative), the
+	// last being the +Inf bucket
+	counts    []uint64
+	exemplars []*exemplar
+	sum       float64
+	count     uint64
+}
+
+// Histogram is a metric which samples observations (e.g. request
+// latencies) into buckets, optionally partitioned by labels. Each
+// bucket keeps the latest exemplar observed for it, which links the
+// bucket to e.g. the trace of a request falling in it.
+type Histogram struct {
+	// vec holds the name, help and label names, its values are not
+	// used
+	vec
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+// now returns the current time, it is replaced in tests
+var now = time.Now
+
+// NewHistogram initializes a Histogram with the upper bounds buckets
+// (in increasing order, the +Inf bucket is added) and registers it
+// with the DefaultRegistry
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.register(newHistogram(name, help, buckets, labelNames)).(*Histogram)
+}
+
+func newHistogram(name, help string, buckets []float64, labelNames []string) *Histogram {
+	return &Histogram{
+		vec:     newVec(name, help, labelNames),
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds the observation v for the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.observe(v, nil, labelValues)
+}
+
+// ObserveWithExemplar adds the observation v for the given label
+// values, keeping it as the exemplar of its bucket with the labels e
+func (h *Histogram) ObserveWithExemplar(v float64, e Labels, labelValues ...string) {
+	h.observe(v, e, labelValues)
+}
+
+func (h *Histogram) observe(v float64, e Labels, labelValues []string) {
+	k := h.key(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{
+			labels:    labelValues,
+			counts:    make([]uint64, len(h.buckets)+1),
+			exemplars: make([]*exemplar, len(h.buckets)+1),
+		}
+		h.series[k] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	if len(e) > 0 {
+		s.exemplars[i] = &exemplar{labels: e, value: v, ts: now()}
+	}
+}
+
+// Count returns the number of observations for the given label
+// values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[k]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Sum returns the sum of the observations for the given label values
+func (h *Histogram) Sum(labelValues ...string) float64 {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[k]; ok {
+		return s.sum
+	}
+	return 0
+}
+
+func (h *Histogram) write(buf *bytes.Buffer, f format) {
+	h.writeType(buf, h.metricName, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatFloat(h.buckets[i])
+			}
+			buf.WriteString(h.metricName + "_bucket")
+			writeLabels(buf, h.labelNames, s.labels, "le", le)
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatUint(cumulative, 10))
+			if f == openMetricsFormat && s.exemplars[i] != nil {
+				writeExemplar(buf, s.exemplars[i])
+			}
+			buf.WriteByte('\n')
+		}
+
+		buf.WriteString(h.metricName + "_sum")
+		writeLabels(buf, h.labelNames, s.labels, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(formatFloat(s.sum))
+		buf.WriteByte('\n')
+
+		buf.WriteString(h.metricName + "_count")
+		writeLabels(buf, h.labelNames, s.labels, "", "")
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatUint(s.count, 10))
+		buf.WriteByte('\n')
+	}
+}
+
+// writeExemplar writes e in the OpenMetrics form
+// ` # {name="value",...} value timestamp`, the labels sorted by name
+func writeExemplar(buf *bytes.Buffer, e *exemplar) {
+	names := make([]string, 0, len(e.labels))
+	for n := range e.labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, n := range names {
+		values = append(values, e.labels[n])
+	}
+
+	buf.WriteString(" # ")
+	writeLabels(buf, names, values, "", "")
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(e.value))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatFloat(float64(e.ts.UnixNano())/float64(time.Second), 'f', 3, 64))
+}
+
+// writeLabels writes the label set in the form {name="value",...}.
+// An extra label (e.g. the "le" label for histogram buckets) is
+// appended when extraName is not empty.
+func writeLabels(buf *bytes.Buffer, names, values []string, extraName, extraValue string) {
+	if len(names) == 0 && extraName == "" {
+		return
+	}
+	buf.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%s=%q", n, values[i])
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%s=%q", extraName, extraValue)
+	}
+	buf.WriteByte('}')
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestGauge(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	g := r.register(&Gauge{newVec("test_gauge", "A test gauge.", []string{"pool"})}).(*Gauge)
+	g.Set(3, "primary")
+	g.Set(1.5, "replica")
+	g.Set(4, "primary")
+
+	c.Assert(g.Value("primary"), qt.Equals, float64(4))
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	want := `# HELP test_gauge A test gauge.
+# TYPE test_gauge gauge
+test_gauge{pool="primary"} 4
+test_gauge{pool="replica"} 1.5
+`
+	c.Assert(buf.String(), qt.Equals, want)
+}
+
+func TestCounter(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	ctr := r.register(&Counter{newVec("test_total", "A test counter.", []string{"method"})}).(*Counter)
+	ctr.Inc("GET")
+	ctr.Inc("GET")
+	ctr.Add(2.5, "POST")
+
+	c.Assert(ctr.Value("GET"), qt.Equals, float64(2))
+	c.Assert(func() { ctr.Add(-1, "GET") }, qt.PanicMatches, "metrics: counter test_total cannot decrease")
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	want := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{method="GET"} 2
+test_total{method="POST"} 2.5
+`
+	c.Assert(buf.String(), qt.Equals, want)
+}
+
+func TestRegistry_register(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	g1 := r.register(&Gauge{newVec("dup", "first", nil)})
+	g2 := r.register(&Gauge{newVec("dup", "second", nil)})
+
+	// registering the same name twice returns the original metric
+	c.Assert(g2, qt.Equals, g1)
+}
+
+func TestGauge_wrongLabelCount(t *testing.T) {
+	c := qt.New(t)
+
+	g := &Gauge{newVec("labels", "help", []string{"a", "b"})}
+	c.Assert(func() { g.Set(1, "a") }, qt.PanicMatches, "metrics: labels expects 2 label values, got 1")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	g := r.register(&Gauge{newVec("handler_gauge", "help", nil)}).(*Gauge)
+	g.Set(7)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Handler().ServeHTTP(rr, req)
+
+	body, err := ioutil.ReadAll(rr.Body)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "text/plain; version=0.0.4; charset=utf-8")
+	c.Assert(string(body), qt.Equals, "# HELP handler_gauge help\n# TYPE handler_gauge gauge\nhandler_gauge 7\n")
+}
+
+func TestHistogram(t *testing.T) {
+	c := qt.New(t)
+
+	ts := time.Date(2021, 3, 20, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return ts }
+	defer func() { now = time.Now }()
+
+	r := NewRegistry()
+	h := r.register(newHistogram("test_seconds", "A test histogram.", []float64{.1, 1}, []string{"route"})).(*Histogram)
+	h.Observe(.05, "/movies")
+	h.ObserveWithExemplar(.5, Labels{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}, "/movies")
+	h.Observe(1, "/movies")
+	h.Observe(3, "/movies")
+
+	c.Assert(h.Count("/movies"), qt.Equals, uint64(4))
+	c.Assert(h.Sum("/movies"), qt.Equals, 4.55)
+	c.Assert(h.Count("/ping"), qt.Equals, uint64(0))
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+
+	want := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="/movies",le="0.1"} 1
+test_seconds_bucket{route="/movies",le="1"} 3
+test_seconds_bucket{route="/movies",le="+Inf"} 4
+test_seconds_sum{route="/movies"} 4.55
+test_seconds_count{route="/movies"} 4
+`
+	c.Assert(buf.String(), qt.Equals, want)
+
+	buf.Reset()
+	r.WriteOpenMetrics(&buf)
+
+	want = `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="/movies",le="0.1"} 1
+test_seconds_bucket{route="/movies",le="1"} 3 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.5 1616198400.000
+test_seconds_bucket{route="/movies",le="+Inf"} 4
+test_seconds_sum{route="/movies"} 4.55
+test_seconds_count{route="/movies"} 4
+# EOF
+`
+	c.Assert(buf.String(), qt.Equals, want)
+}
+
+func TestCounter_openMetrics(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	ctr := r.register(&Counter{newVec("test_total", "A test counter.", nil)}).(*Counter)
+	ctr.Inc()
+
+	var buf bytes.Buffer
+	r.WriteOpenMetrics(&buf)
+
+	// the family is named without the _total suffix
+	want := `# HELP test A test counter.
+# TYPE test counter
+test_total 1
+# EOF
+`
+	c.Assert(buf.String(), qt.Equals, want)
+}
+
+func TestRegistry_Handler_openMetrics(t *testing.T) {
+	c := qt.New(t)
+
+	r := NewRegistry()
+	g := r.register(&Gauge{newVec("handler_gauge", "help", nil)}).(*Gauge)
+	g.Set(7)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	r.Handler().ServeHTTP(rr, req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	c.Assert(rr.Body.String(), qt.Equals, "# HELP handler_gauge help\n# TYPE handler_gauge gauge\nhandler_gauge 7\n# EOF\n")
+}
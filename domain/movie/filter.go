@@ -0,0 +1,34 @@
+package movie
+
+import "github.com/gilcrest/go-api-basic/domain/filter"
+
+// FilterFields are the fields Movies can be filtered on, named as in
+// the request and response bodies
+var FilterFields = filter.Fields{
+	"title":        filter.String,
+	"rated":        filter.String,
+	"release_date": filter.Time,
+	"run_time":     filter.Int,
+	"director":     filter.String,
+	"writer":       filter.String,
+}
+
+// FilterValue returns the value of the FilterFields field for m, to
+// be used with filter.Match
+func (m *Movie) FilterValue(field string) interface{} {
+	switch field {
+	case "title":
+		return m.Title
+	case "rated":
+		return m.Rated
+	case "release_date":
+		return m.Released
+	case "run_time":
+		return m.RunTime
+	case "director":
+		return m.Director
+	case "writer":
+		return m.Writer
+	}
+	return nil
+}
@@ -4,8 +4,11 @@ package movie
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"time"
 
+	"github.com/gilcrest/go-api-basic/domain/clock"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/user"
 	"github.com/google/uuid"
@@ -18,8 +21,9 @@ type Writer interface {
 	Update(ctx context.Context, id string) error
 }
 
-// NewMovie initializes a Movie struct for use in Movie creation
-func NewMovie(id uuid.UUID, extlID string, u user.User) (*Movie, error) {
+// NewMovie initializes a Movie struct for use in Movie creation. The
+// create and update times are the current time of clk.
+func NewMovie(id uuid.UUID, extlID string, u user.User, clk clock.Clock) (*Movie, error) {
 	switch {
 	case id == uuid.Nil:
 		return nil, errs.E(errs.Validation, errs.Parameter("ID"), errors.New(errs.MissingField("ID").Error()))
@@ -29,7 +33,7 @@ func NewMovie(id uuid.UUID, extlID string, u user.User) (*Movie, error) {
 		return nil, errs.E(errs.Validation, errs.Parameter("User"), errors.New("User is invalid"))
 	}
 
-	now := time.Now().UTC()
+	now := clk.Now().UTC()
 
 	return &Movie{
 		ID:         id,
@@ -84,10 +88,30 @@ func (m *Movie) SetReleased(r string) (*Movie, error) {
 			errs.Parameter("release_date"),
 			errors.WithStack(err))
 	}
+	// RFC3339 allows year 0000 and, with a negative offset, a time
+	// in year 10000 UTC, neither of which can be stored as a date
+	if !inDateRange(t) || !inDateRange(t.UTC()) {
+		return nil, errs.E(errs.Validation,
+			errs.Code("invalid_date"),
+			errs.Parameter("release_date"),
+			errors.Errorf("release_date year must be between %d and %d", minYear, maxYear))
+	}
 	m.Released = t
 	return m, nil
 }
 
+// The range of years accepted for a release date
+const (
+	minYear int = 1
+	maxYear int = 9999
+)
+
+// inDateRange reports whether the year of t is between minYear and
+// maxYear
+func inDateRange(t time.Time) bool {
+	return t.Year() >= minYear && t.Year() <= maxYear
+}
+
 // SetRunTime is a setter for a Movie run time in minutes
 func (m *Movie) SetRunTime(rt int) *Movie {
 	m.RunTime = rt
@@ -112,14 +136,25 @@ func (m *Movie) SetUpdateUser(u user.User) *Movie {
 	return m
 }
 
-// SetUpdateTime is a setter for a Movie update time
-func (m *Movie) SetUpdateTime() *Movie {
-	m.UpdateTime = time.Now().UTC()
+// SetUpdateTime is a setter for a Movie update time, which is set to
+// the current time of clk
+func (m *Movie) SetUpdateTime(clk clock.Clock) *Movie {
+	m.UpdateTime = clk.Now().UTC()
 	return m
 }
 
-// IsValid performs validation of the struct
+// IsValid performs validation of the struct using the default
+// ValidationPolicy and the system time
 func (m *Movie) IsValid() error {
+	return m.Validate(ValidationPolicy{}, clock.DefaultClock{})
+}
+
+// Validate performs validation of the struct using the bounds of p.
+// The latest release date allowed is relative to the current time of
+// clk.
+func (m *Movie) Validate(p ValidationPolicy, clk clock.Clock) error {
+	p = p.withDefaults()
+
 	switch {
 	case m.ExternalID == "":
 		return errs.E(errs.Validation, errs.Parameter("extlID"), errs.MissingField("extlID"))
@@ -129,13 +164,25 @@ func (m *Movie) IsValid() error {
 		return errs.E(errs.Validation, errs.Parameter("rated"), errs.MissingField("Rated"))
 	case m.Released.IsZero():
 		return errs.E(errs.Validation, errs.Parameter("release_date"), "Released must have a value")
+	case m.Released.Year() < p.MinReleaseYear:
+		return errs.E(errs.Validation, errs.Code("invalid_date"), errs.Parameter("release_date"),
+			fmt.Sprintf("Released must not be before %d", p.MinReleaseYear))
+	case m.Released.After(clk.Now().AddDate(p.MaxYearsAhead, 0, 0)):
+		return errs.E(errs.Validation, errs.Code("invalid_date"), errs.Parameter("release_date"),
+			fmt.Sprintf("Released must not be more than %d years in the future", p.MaxYearsAhead))
 	case m.RunTime <= 0:
 		return errs.E(errs.Validation, errs.Parameter("run_time"), "Run time must be greater than zero")
+	// run_time is stored in a 32 bit integer column
+	case m.RunTime > math.MaxInt32:
+		return errs.E(errs.Validation, errs.Parameter("run_time"), fmt.Sprintf("Run time must not be greater than %d", math.MaxInt32))
+	case m.RunTime < p.MinRunTime || m.RunTime > p.MaxRunTime:
+		return errs.E(errs.Validation, errs.Parameter("run_time"),
+			fmt.Sprintf("Run time must be between %d and %d minutes", p.MinRunTime, p.MaxRunTime))
 	case m.Director == "":
 		return errs.E(errs.Validation, errs.Parameter("director"), errs.MissingField("Director"))
 	case m.Writer == "":
 		return errs.E(errs.Validation, errs.Parameter("writer"), errs.MissingField("Writer"))
 	}
 
-	return nil
+	return m.ValidateRated(p.Ratings)
 }
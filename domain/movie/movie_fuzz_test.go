@@ -0,0 +1,73 @@
+//go:build go1.18
+// +build go1.18
+
+package movie_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+func FuzzMovie_SetReleased(f *testing.F) {
+	for _, s := range []string{
+		"1984-03-02T00:00:00Z",
+		"1984-03-02T00:00:00.999999999-05:00",
+		"0000-01-01T00:00:00Z",
+		"9999-12-31T23:59:59+23:59",
+		"1984-03-02",
+		"",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		m, err := newValidMovie().SetReleased(s)
+		if err != nil {
+			if !errs.KindIs(errs.Validation, err) {
+				t.Fatalf("SetReleased(%q) error = %v, want a Validation error", s, err)
+			}
+			return
+		}
+		// a release date which is accepted must be storable as a
+		// PostgreSQL date and formatted back the same way
+		if y := m.Released.Year(); y < 1 || y > 9999 {
+			t.Fatalf("SetReleased(%q) accepted year %d", s, y)
+		}
+		if _, err = time.Parse(time.RFC3339, m.Released.Format(time.RFC3339)); err != nil {
+			t.Fatalf("SetReleased(%q) released does not round trip: %v", s, err)
+		}
+	})
+}
+
+func FuzzMovie_IsValid(f *testing.F) {
+	f.Add("ExternalID", "Repo Man", "R", int64(462672000), 92, "Alex Cox", "Alex Cox")
+	f.Add("", "", "", int64(0), 0, "", "")
+	f.Add("x", "x", "x", int64(-62135596800), 1<<31, "x", "x")
+
+	f.Fuzz(func(t *testing.T, extlID, title, rated string, released int64, runTime int, director, writer string) {
+		m := newValidMovie()
+		m.SetExternalID(extlID).
+			SetTitle(title).
+			SetRated(rated).
+			SetRunTime(runTime).
+			SetDirector(director).
+			SetWriter(writer)
+		m.Released = time.Unix(released, 0).UTC()
+
+		err := m.IsValid()
+		if err != nil {
+			if !errs.KindIs(errs.Validation, err) {
+				t.Fatalf("IsValid() error = %v, want a Validation error", err)
+			}
+			return
+		}
+		// a valid run time must be in the default range, which fits
+		// the integer database column
+		if runTime < movie.DefaultMinRunTime || runTime > movie.DefaultMaxRunTime {
+			t.Fatalf("IsValid() accepted run time %d", runTime)
+		}
+	})
+}
@@ -1,13 +1,15 @@
 package movie_test
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
 
-	"github.com/google/go-cmp/cmp/cmpopts"
-
 	qt "github.com/frankban/quicktest"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/movie"
 	"github.com/gilcrest/go-api-basic/domain/user"
@@ -34,7 +36,7 @@ func newValidMovie() *movie.Movie {
 
 	u := newValidUser()
 
-	m, _ := movie.NewMovie(uid, externalID, u)
+	m, _ := movie.NewMovie(uid, externalID, u, clock.DefaultClock{})
 
 	return m
 }
@@ -58,7 +60,7 @@ func TestNewMovieErrorUuid(t *testing.T) {
 
 	u := newValidUser()
 	wantError := errs.E(errs.Validation, errs.Parameter("ID"), errors.New(errs.MissingField("ID").Error()))
-	if gotMovie, gotError := movie.NewMovie(uuid.UUID{}, "randomExternalId", u); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
+	if gotMovie, gotError := movie.NewMovie(uuid.UUID{}, "randomExternalId", u, clock.DefaultClock{}); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
 		t.Errorf("Want: %v\nGot: %v", wantError, gotError)
 	}
 }
@@ -70,7 +72,7 @@ func TestNewMovieErrorExtlID(t *testing.T) {
 	u := newValidUser()
 	uid, _ := uuid.NewUUID()
 	wantError := errs.E(errs.Validation, errs.Parameter("ID"), errors.New(errs.MissingField("ID").Error()))
-	if gotMovie, gotError := movie.NewMovie(uid, "", u); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
+	if gotMovie, gotError := movie.NewMovie(uid, "", u, clock.DefaultClock{}); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
 		t.Errorf("Want: %v\nGot: %v", wantError, gotError)
 	}
 }
@@ -84,7 +86,7 @@ func TestNewMovieErrorInvalidUser(t *testing.T) {
 
 	wantError := errs.E(errs.Validation, errs.Parameter("User"), errors.New("User is invalid"))
 
-	if gotMovie, gotError := movie.NewMovie(uid, "externalID", u); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
+	if gotMovie, gotError := movie.NewMovie(uid, "externalID", u, clock.DefaultClock{}); !reflect.DeepEqual(wantError.Error(), gotError.Error()) && gotMovie != nil {
 		t.Errorf("Want: %v\nGot: %v", wantError, gotError)
 	}
 }
@@ -103,7 +105,7 @@ func TestNewMovie(t *testing.T) {
 		CreateUser: u,
 		UpdateUser: u,
 	}
-	gotMovie, gotError := movie.NewMovie(uid, externalID, u)
+	gotMovie, gotError := movie.NewMovie(uid, externalID, u, clock.DefaultClock{})
 	if gotError != nil {
 
 		if gotMovie.ID != uid {
@@ -188,6 +190,27 @@ func TestSetReleasedWrong(t *testing.T) {
 	}
 }
 
+func TestSetReleasedOutOfRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		released string
+	}{
+		{"year zero", "0000-01-01T00:00:00Z"},
+		{"year 10000 UTC", "9999-12-31T23:59:59-01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			_, err := newValidMovie().SetReleased(tt.released)
+
+			want := errs.E(errs.Validation, errs.Code("invalid_date"), errs.Parameter("release_date"),
+				errors.New("release_date year must be between 1 and 9999"))
+			c.Assert(errs.Match(want, err), qt.IsTrue)
+		})
+	}
+}
+
 func TestSetRunTime(t *testing.T) {
 	rt := 1999
 
@@ -251,15 +274,10 @@ func TestSetUpdateTime(t *testing.T) {
 	// get a new movie
 	m := newValidMovie()
 
-	// UpdateTime is set to now in utc as part of NewMovie
-	originalTime := m.UpdateTime
+	// Call SetUpdateTime to update to the time of the clock in utc
+	m.SetUpdateTime(clocktest.NewMockClock(t))
 
-	// Call SetUpdateTime to update to now in utc
-	m.SetUpdateTime()
-
-	within1Second := cmpopts.EquateApproxTime(time.Second)
-
-	c.Assert(originalTime, qt.CmpEquals(within1Second), m.UpdateTime)
+	c.Assert(m.UpdateTime, qt.Equals, clocktest.Time)
 }
 
 type Tests struct {
@@ -385,6 +403,20 @@ func getMovieTests() []Tests {
 		wantErr: errs.E(errs.Validation, errs.Parameter("extlID"), errs.MissingField("extlID")),
 	})
 
+	m9 := newValidMovie()
+	m9, _ = m9.SetReleased("1996-12-19T16:39:57-08:00")
+	m9.
+		SetTitle("Movie Title").
+		SetRated("R").
+		SetRunTime(math.MaxInt32 + 1).
+		SetDirector("Movie Director").
+		SetWriter("Movie Writer")
+	tests = append(tests, Tests{
+		name:    "Run Time Too Large",
+		m:       m9,
+		wantErr: errs.E(errs.Validation, errs.Parameter("run_time"), fmt.Sprintf("Run time must not be greater than %d", math.MaxInt32)),
+	})
+
 	return tests
 }
 
@@ -0,0 +1,54 @@
+package movie
+
+// The defaults for a ValidationPolicy
+const (
+	// DefaultMinReleaseYear is the year of the earliest surviving
+	// motion picture, The Horse in Motion (1878)
+	DefaultMinReleaseYear int = 1878
+	// DefaultMaxYearsAhead is how far in the future a release date
+	// can be, for movies which have been announced
+	DefaultMaxYearsAhead int = 10
+	// DefaultMinRunTime is the shortest run time in minutes
+	DefaultMinRunTime int = 1
+	// DefaultMaxRunTime is the longest run time in minutes
+	DefaultMaxRunTime int = 1000
+)
+
+// ValidationPolicy holds the bounds a Movie is validated against
+// (see Movie.Validate). A zero field is set to its default, so the
+// zero ValidationPolicy is the default policy.
+type ValidationPolicy struct {
+	// Ratings are the ratings a Movie can be rated, MPAARatings by
+	// default
+	Ratings Ratings
+	// MinReleaseYear is the earliest year a Movie can be released,
+	// DefaultMinReleaseYear by default
+	MinReleaseYear int
+	// MaxYearsAhead is the number of years after the current time a
+	// Movie can be released, DefaultMaxYearsAhead by default
+	MaxYearsAhead int
+	// MinRunTime and MaxRunTime are the range of run times in
+	// minutes, DefaultMinRunTime and DefaultMaxRunTime by default
+	MinRunTime int
+	MaxRunTime int
+}
+
+// withDefaults returns p with any zero field set to its default
+func (p ValidationPolicy) withDefaults() ValidationPolicy {
+	if len(p.Ratings) == 0 {
+		p.Ratings = MPAARatings
+	}
+	if p.MinReleaseYear == 0 {
+		p.MinReleaseYear = DefaultMinReleaseYear
+	}
+	if p.MaxYearsAhead == 0 {
+		p.MaxYearsAhead = DefaultMaxYearsAhead
+	}
+	if p.MinRunTime == 0 {
+		p.MinRunTime = DefaultMinRunTime
+	}
+	if p.MaxRunTime == 0 {
+		p.MaxRunTime = DefaultMaxRunTime
+	}
+	return p
+}
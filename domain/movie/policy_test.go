@@ -0,0 +1,58 @@
+package movie_test
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+func TestMovie_Validate(t *testing.T) {
+	// clocktest.Time is in 2008
+	tests := []struct {
+		name      string
+		policy    movie.ValidationPolicy
+		released  time.Time
+		runTime   int
+		wantParam errs.Parameter
+		wantErr   string
+	}{
+		{"valid", movie.ValidationPolicy{}, time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC), 92, "", ""},
+		{"earliest release", movie.ValidationPolicy{}, time.Date(1878, 6, 19, 0, 0, 0, 0, time.UTC), 1, "", ""},
+		{"released too early", movie.ValidationPolicy{}, time.Date(1877, 12, 31, 0, 0, 0, 0, time.UTC), 1, "release_date", "Released must not be before 1878"},
+		{"released in the future", movie.ValidationPolicy{}, time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC), 92, "", ""},
+		{"released too far in the future", movie.ValidationPolicy{}, time.Date(2018, 1, 9, 0, 0, 0, 0, time.UTC), 92, "release_date", "Released must not be more than 10 years in the future"},
+		{"longest run time", movie.ValidationPolicy{}, time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC), 1000, "", ""},
+		{"run time too long", movie.ValidationPolicy{}, time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC), 1001, "run_time", "Run time must be between 1 and 1000 minutes"},
+		{"policy release year", movie.ValidationPolicy{MinReleaseYear: 1950}, time.Date(1949, 3, 2, 0, 0, 0, 0, time.UTC), 92, "release_date", "Released must not be before 1950"},
+		{"policy years ahead", movie.ValidationPolicy{MaxYearsAhead: 1}, time.Date(2009, 3, 2, 0, 0, 0, 0, time.UTC), 92, "release_date", "Released must not be more than 1 years in the future"},
+		{"policy run time", movie.ValidationPolicy{MinRunTime: 40, MaxRunTime: 240}, time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC), 39, "run_time", "Run time must be between 40 and 240 minutes"},
+		{"policy ratings", movie.ValidationPolicy{Ratings: movie.Ratings{"TV-MA"}}, time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC), 92, "rated", "rated must be one of TV-MA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			m := newValidMovie()
+			m.SetTitle("Repo Man").
+				SetRated("R").
+				SetRunTime(tt.runTime).
+				SetDirector("Alex Cox").
+				SetWriter("Alex Cox")
+			m.Released = tt.released
+
+			err := m.Validate(tt.policy, clocktest.NewMockClock(t))
+			if tt.wantParam == "" {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, tt.wantParam)
+			c.Assert(err, qt.ErrorMatches, tt.wantErr)
+		})
+	}
+}
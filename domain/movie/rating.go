@@ -0,0 +1,49 @@
+package movie
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Ratings are the ratings a Movie can be rated
+type Ratings []string
+
+// MPAARatings are the Motion Picture Association film ratings, with
+// NR for a movie which has not been rated
+var MPAARatings = Ratings{"G", "PG", "PG-13", "R", "NC-17", "NR"}
+
+// ParseRatings returns the Ratings in s, a comma separated list of
+// ratings, e.g. "G,PG,R". Spaces around each rating are trimmed and
+// empty ratings are ignored, so an empty s returns nil Ratings.
+func ParseRatings(s string) Ratings {
+	var rs Ratings
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r != "" {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// ValidateRated returns an error of Kind errs.Validation if m is not
+// rated one of the ratings in allowed. If allowed is empty, the
+// MPAARatings are allowed.
+func (m *Movie) ValidateRated(allowed Ratings) error {
+	if len(allowed) == 0 {
+		allowed = MPAARatings
+	}
+	for _, r := range allowed {
+		if m.Rated == r {
+			return nil
+		}
+	}
+
+	return errs.E(errs.Validation,
+		errs.Code("invalid_rating"),
+		errs.Parameter("rated"),
+		errors.Errorf("rated must be one of %s", strings.Join(allowed, ", ")))
+}
@@ -0,0 +1,60 @@
+package movie_test
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+func TestParseRatings(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want movie.Ratings
+	}{
+		{"empty", "", nil},
+		{"one", "R", movie.Ratings{"R"}},
+		{"many", "G, PG ,R", movie.Ratings{"G", "PG", "R"}},
+		{"empty ratings", ",G,,", movie.Ratings{"G"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(movie.ParseRatings(tt.s), qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestMovie_ValidateRated(t *testing.T) {
+	tests := []struct {
+		name    string
+		rated   string
+		allowed movie.Ratings
+		wantErr string
+	}{
+		{"MPAA", "NC-17", nil, ""},
+		{"not MPAA", "TV-14", nil, "rated must be one of G, PG, PG-13, R, NC-17, NR"},
+		{"allowed", "TV-14", movie.Ratings{"TV-14", "TV-MA"}, ""},
+		{"not allowed", "G", movie.Ratings{"TV-14", "TV-MA"}, "rated must be one of TV-14, TV-MA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			m := newValidMovie()
+			m.SetRated(tt.rated)
+
+			err := m.ValidateRated(tt.allowed)
+			if tt.wantErr == "" {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(err, qt.ErrorMatches, tt.wantErr)
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Code, qt.Equals, errs.Code("invalid_rating"))
+		})
+	}
+}
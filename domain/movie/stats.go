@@ -0,0 +1,50 @@
+package movie
+
+import "time"
+
+// Stats holds counts of movies grouped by rating, decade and
+// director
+type Stats struct {
+	ByRating   []GroupCount
+	ByDecade   []GroupCount
+	ByDirector []GroupCount
+}
+
+// GroupCount is the number of movies for a group value, e.g. the
+// number of movies rated "R"
+type GroupCount struct {
+	Value string
+	Count int
+}
+
+// CountMode is how movies are counted
+type CountMode string
+
+// Count modes
+const (
+	// CountExact counts every movie, which reads the whole table
+	CountExact CountMode = "exact"
+	// CountEstimate uses the row count estimated by the database
+	// from its table statistics. It is fast on a large table, but
+	// only as current as the last time the table was analyzed.
+	CountEstimate CountMode = "estimate"
+)
+
+// Count is the number of movies
+type Count struct {
+	Count int64
+	// Estimated is true if Count is an estimate
+	Estimated bool
+}
+
+// ListVersion identifies the state of a list of movies, e.g. those
+// matching a filter. It changes whenever a movie in the list is
+// created, updated or deleted: a created or updated movie has the
+// latest update time and a deleted movie lowers the count.
+type ListVersion struct {
+	// LastUpdate is the latest update time of the movies, or zero if
+	// the list is empty
+	LastUpdate time.Time
+	// Count is the number of movies
+	Count int
+}
@@ -0,0 +1,8 @@
+package movie
+
+// Suggestion is a movie whose title starts with the text searched
+// for, e.g. to autocomplete a title as it is typed
+type Suggestion struct {
+	ExternalID string
+	Title      string
+}
@@ -0,0 +1,86 @@
+// Package person contains the business or "domain" logic for
+// a Person
+package person
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// NewPerson initializes a Person struct for use in Person creation.
+// The create and update times are the current time of clk.
+func NewPerson(id uuid.UUID, extlID string, u user.User, clk clock.Clock) (*Person, error) {
+	switch {
+	case id == uuid.Nil:
+		return nil, errs.E(errs.Validation, errs.Parameter("ID"), errors.New(errs.MissingField("ID").Error()))
+	case extlID == "":
+		return nil, errs.E(errs.Validation, errs.Parameter("ID"), errors.New(errs.MissingField("ID").Error()))
+	case !u.IsValid():
+		return nil, errs.E(errs.Validation, errs.Parameter("User"), errors.New("User is invalid"))
+	}
+
+	now := clk.Now().UTC()
+
+	return &Person{
+		ID:         id,
+		ExternalID: extlID,
+		CreateUser: u,
+		CreateTime: now,
+		UpdateUser: u,
+		UpdateTime: now,
+	}, nil
+}
+
+// Person holds details of a person, e.g. the director of a Movie
+type Person struct {
+	ID         uuid.UUID
+	ExternalID string
+	Name       string
+	CreateUser user.User
+	CreateTime time.Time
+	UpdateUser user.User
+	UpdateTime time.Time
+}
+
+// SetExternalID is a setter for a Person External ID
+func (p *Person) SetExternalID(id string) *Person {
+	p.ExternalID = id
+	return p
+}
+
+// SetName is a setter for a Person name
+func (p *Person) SetName(name string) *Person {
+	p.Name = name
+	return p
+}
+
+// SetUpdateUser is a setter for a Person update user
+func (p *Person) SetUpdateUser(u user.User) *Person {
+	p.UpdateUser = u
+	return p
+}
+
+// SetUpdateTime is a setter for a Person update time, which is set to
+// the current time of clk
+func (p *Person) SetUpdateTime(clk clock.Clock) *Person {
+	p.UpdateTime = clk.Now().UTC()
+	return p
+}
+
+// IsValid performs validation of the struct
+func (p *Person) IsValid() error {
+	switch {
+	case p.ExternalID == "":
+		return errs.E(errs.Validation, errs.Parameter("extlID"), errs.MissingField("extlID"))
+	case p.Name == "":
+		return errs.E(errs.Validation, errs.Parameter("name"), errs.MissingField("name"))
+	}
+
+	return nil
+}
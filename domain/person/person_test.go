@@ -0,0 +1,80 @@
+package person_test
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+func TestNewPerson(t *testing.T) {
+	u := usertest.NewUser(t)
+
+	tests := []struct {
+		name      string
+		id        uuid.UUID
+		extlID    string
+		u         user.User
+		wantParam errs.Parameter
+	}{
+		{"valid", uuid.New(), "ExternalID", u, ""},
+		{"nil ID", uuid.Nil, "ExternalID", u, "ID"},
+		{"empty external ID", uuid.New(), "", u, "ID"},
+		{"invalid user", uuid.New(), "ExternalID", user.User{}, "User"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := person.NewPerson(tt.id, tt.extlID, tt.u, clocktest.NewMockClock(t))
+			if tt.wantParam == "" {
+				c.Assert(err, qt.IsNil)
+				c.Assert(got.ID, qt.Equals, tt.id)
+				c.Assert(got.ExternalID, qt.Equals, tt.extlID)
+				c.Assert(got.CreateUser, qt.DeepEquals, tt.u)
+				c.Assert(got.UpdateUser, qt.DeepEquals, tt.u)
+				c.Assert(got.CreateTime, qt.Equals, clocktest.Time)
+				c.Assert(got.UpdateTime, qt.Equals, clocktest.Time)
+				return
+			}
+			c.Assert(got, qt.IsNil)
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, tt.wantParam)
+		})
+	}
+}
+
+func TestPerson_IsValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		extlID    string
+		n         string
+		wantParam errs.Parameter
+	}{
+		{"valid", "ExternalID", "Name", ""},
+		{"empty external ID", "", "Name", "extlID"},
+		{"empty name", "ExternalID", "", "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			x := new(person.Person)
+			x.SetExternalID(tt.extlID).SetName(tt.n)
+
+			err := x.IsValid()
+			if tt.wantParam == "" {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, tt.wantParam)
+		})
+	}
+}
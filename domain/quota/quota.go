@@ -0,0 +1,183 @@
+// Package quota tracks the number of requests made by each user
+// over daily and monthly windows and enforces request quotas
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Window is a period of time over which requests are counted
+type Window string
+
+const (
+	// Day is a calendar day (UTC)
+	Day Window = "day"
+	// Month is a calendar month (UTC)
+	Month Window = "month"
+)
+
+// Start returns the start of the window holding t
+func (w Window) Start(t time.Time) time.Time {
+	t = t.UTC()
+	if w == Month {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// End returns the end of the window holding t, which is also the
+// start of the next window
+func (w Window) End(t time.Time) time.Time {
+	if w == Month {
+		return w.Start(t).AddDate(0, 1, 0)
+	}
+	return w.Start(t).AddDate(0, 0, 1)
+}
+
+// Limits are the maximum number of requests a user can make in
+// each window. A zero limit means the user has no limit for the
+// window.
+type Limits struct {
+	Daily   int64
+	Monthly int64
+}
+
+// NewReloadableLimits is an initializer for ReloadableLimits
+func NewReloadableLimits(l Limits) *ReloadableLimits {
+	return &ReloadableLimits{limits: l}
+}
+
+// ReloadableLimits holds Limits which can be replaced while requests
+// are tracked, e.g. when the configuration is reloaded. It is safe
+// for concurrent use.
+type ReloadableLimits struct {
+	mu     sync.RWMutex
+	limits Limits
+}
+
+// Load returns the current Limits
+func (r *ReloadableLimits) Load() Limits {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limits
+}
+
+// Store replaces the Limits
+func (r *ReloadableLimits) Store(l Limits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits = l
+}
+
+// Counts are the number of requests a user has made in the current
+// windows
+type Counts struct {
+	Daily   int64
+	Monthly int64
+}
+
+// Counter counts requests by user. The counts are persisted so
+// they survive restarts and are shared between instances.
+type Counter interface {
+	// Increment adds one request for the user to the windows
+	// holding at and returns the new counts
+	Increment(ctx context.Context, username string, at time.Time) (Counts, error)
+	// Counts returns the counts for the user for the windows
+	// holding at
+	Counts(ctx context.Context, username string, at time.Time) (Counts, error)
+}
+
+// Usage is a user's usage of their quota for a window
+type Usage struct {
+	Window Window
+	// Limit is the maximum number of requests for the window, zero
+	// if there is no limit
+	Limit int64
+	// Used is the number of requests made in the window
+	Used int64
+	// ResetsAt is when the window ends and Used is reset
+	ResetsAt time.Time
+}
+
+// Remaining returns the number of requests left in the window or -1
+// if there is no limit
+func (u Usage) Remaining() int64 {
+	if u.Limit <= 0 {
+		return -1
+	}
+	if u.Used >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used
+}
+
+// Exceeded reports whether more requests than the limit have been
+// made in the window
+func (u Usage) Exceeded() bool {
+	return u.Limit > 0 && u.Used > u.Limit
+}
+
+// Tracker tracks requests against a user's quota
+type Tracker interface {
+	// Track counts a request for the user and returns the user's
+	// usage. If the user has exceeded a quota, an error of Kind
+	// errs.TooManyRequests is returned along with the usage.
+	Track(ctx context.Context, u user.User) ([]Usage, error)
+	// Usage returns the user's usage without counting a request
+	Usage(ctx context.Context, u user.User) ([]Usage, error)
+}
+
+// DefaultTracker is the default implementation of Tracker which
+// counts requests using a Counter and enforces Limits
+type DefaultTracker struct {
+	Counter Counter
+	Limits  *ReloadableLimits
+}
+
+// Track counts a request for the user and enforces the Limits.
+// Requests which exceed a quota are still counted.
+func (t DefaultTracker) Track(ctx context.Context, u user.User) ([]Usage, error) {
+	now := time.Now()
+
+	counts, err := t.Counter.Increment(ctx, u.Email, now)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := t.usage(counts, now)
+	for _, wu := range usage {
+		if wu.Exceeded() {
+			return usage, errs.E(errs.TooManyRequests, errs.Code("quota_exceeded"),
+				fmt.Sprintf("%s request quota of %d exceeded, resets at %s", wu.Window, wu.Limit, wu.ResetsAt.Format(time.RFC3339)))
+		}
+	}
+
+	return usage, nil
+}
+
+// Usage returns the user's usage for the current day and month
+func (t DefaultTracker) Usage(ctx context.Context, u user.User) ([]Usage, error) {
+	now := time.Now()
+
+	counts, err := t.Counter.Counts(ctx, u.Email, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.usage(counts, now), nil
+}
+
+// usage returns the Usage for each window given the counts
+func (t DefaultTracker) usage(c Counts, now time.Time) []Usage {
+	limits := t.Limits.Load()
+	return []Usage{
+		{Window: Day, Limit: limits.Daily, Used: c.Daily, ResetsAt: Day.End(now)},
+		{Window: Month, Limit: limits.Monthly, Used: c.Monthly, ResetsAt: Month.End(now)},
+	}
+}
@@ -0,0 +1,97 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// fakeCounter counts requests in memory
+type fakeCounter struct {
+	counts map[string]Counts
+}
+
+func (f *fakeCounter) Increment(ctx context.Context, username string, at time.Time) (Counts, error) {
+	c := f.counts[username]
+	c.Daily++
+	c.Monthly++
+	f.counts[username] = c
+	return c, nil
+}
+
+func (f *fakeCounter) Counts(ctx context.Context, username string, at time.Time) (Counts, error) {
+	return f.counts[username], nil
+}
+
+func TestWindow_Start_End(t *testing.T) {
+	c := qt.New(t)
+
+	at := time.Date(2020, 12, 31, 23, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	// 2020-12-31 23:30 EST is 2021-01-01 04:30 UTC
+	c.Assert(Day.Start(at), qt.Equals, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(Day.End(at), qt.Equals, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+	c.Assert(Month.Start(at), qt.Equals, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(Month.End(at), qt.Equals, time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestUsage_Remaining(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage Usage
+		want  int64
+	}{
+		{"no limit", Usage{Limit: 0, Used: 10}, -1},
+		{"under limit", Usage{Limit: 10, Used: 4}, 6},
+		{"at limit", Usage{Limit: 10, Used: 10}, 0},
+		{"over limit", Usage{Limit: 10, Used: 12}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(tt.usage.Remaining(), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestDefaultTracker_Track(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+
+	tracker := DefaultTracker{
+		Counter: &fakeCounter{counts: make(map[string]Counts)},
+		Limits:  NewReloadableLimits(Limits{Daily: 2}),
+	}
+
+	for i := 1; i <= 2; i++ {
+		usage, err := tracker.Track(ctx, u)
+		c.Assert(err, qt.IsNil)
+		c.Assert(usage[0].Used, qt.Equals, int64(i))
+	}
+
+	// the third request exceeds the daily quota
+	usage, err := tracker.Track(ctx, u)
+	c.Assert(errs.KindIs(errs.TooManyRequests, err), qt.IsTrue)
+	c.Assert(usage[0].Window, qt.Equals, Day)
+	c.Assert(usage[0].Remaining(), qt.Equals, int64(0))
+	c.Assert(usage[1].Remaining(), qt.Equals, int64(-1))
+
+	// Usage does not count a request
+	usage, err = tracker.Usage(ctx, u)
+	c.Assert(err, qt.IsNil)
+	c.Assert(usage[0].Used, qt.Equals, int64(3))
+	c.Assert(usage[1].Used, qt.Equals, int64(3))
+
+	// a new limit applies to the next request
+	tracker.Limits.Store(Limits{Daily: 10})
+	usage, err = tracker.Track(ctx, u)
+	c.Assert(err, qt.IsNil)
+	c.Assert(usage[0].Remaining(), qt.Equals, int64(6))
+}
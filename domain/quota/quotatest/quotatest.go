@@ -0,0 +1,49 @@
+// Package quotatest provides testing helper functions for the
+// quota package
+package quotatest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// NewMockTracker is an initializer for MockTracker
+func NewMockTracker(t testing.TB) MockTracker {
+	return MockTracker{t: t}
+}
+
+// MockTracker mocks tracking requests against a user's quota
+type MockTracker struct {
+	t testing.TB
+}
+
+// Track mocks counting a request for the user. Track never returns
+// an error, thus the user never exceeds their quota
+func (m MockTracker) Track(ctx context.Context, u user.User) ([]quota.Usage, error) {
+	m.t.Helper()
+
+	return NewUsage(m.t), nil
+}
+
+// Usage mocks returning the user's usage
+func (m MockTracker) Usage(ctx context.Context, u user.User) ([]quota.Usage, error) {
+	m.t.Helper()
+
+	return NewUsage(m.t), nil
+}
+
+// NewUsage returns a static usage for testing
+func NewUsage(t testing.TB) []quota.Usage {
+	t.Helper()
+
+	resetsAt := time.Date(2008, 1, 9, 0, 0, 0, 0, time.UTC)
+
+	return []quota.Usage{
+		{Window: quota.Day, Limit: 1000, Used: 1, ResetsAt: resetsAt},
+		{Window: quota.Month, Limit: 0, Used: 1, ResetsAt: time.Date(2008, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+}
@@ -1,4 +1,6 @@
-// Package random has helper functions to create random strings or bytes
+// Package random has helper functions to create random strings or
+// bytes, and generates the random IDs and secrets of the API, each
+// using its own generator
 package random
 
 import (
@@ -13,6 +15,26 @@ type StringGenerator interface {
 	CryptoString(n int) (string, error)
 }
 
+// The number of random bytes in each kind of generated string
+const (
+	ExternalIDBytes int = 15
+	SecretBytes     int = 32
+)
+
+// ExternalIDGenerator generates the external IDs of resources (e.g.
+// movies), which identify them in the API in place of their
+// primary keys
+type ExternalIDGenerator interface {
+	NewExternalID() (string, error)
+}
+
+// SecretGenerator generates the secrets of integration sessions,
+// which an integration sends to exchange its session for an access
+// token
+type SecretGenerator interface {
+	NewSecret() (string, error)
+}
+
 // GenerateRandomBytes returns securely generated random bytes.
 // It will return an error if the system's secure random
 // number generator fails to function correctly, in which
@@ -29,7 +51,9 @@ func GenerateRandomBytes(n int) ([]byte, error) {
 	return b, nil
 }
 
-// DefaultStringGenerator generates random strings
+// DefaultStringGenerator generates random strings using crypto/rand.
+// It satisfies StringGenerator and each of the ID generator
+// interfaces.
 type DefaultStringGenerator struct{}
 
 // CryptoString returns a URL-safe, base64 encoded
@@ -46,3 +70,25 @@ func (g DefaultStringGenerator) CryptoString(n int) (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b), err
 }
+
+// NewExternalID returns a random external ID of ExternalIDBytes
+// bytes, base64 encoded in 20 URL-safe characters
+func (g DefaultStringGenerator) NewExternalID() (string, error) {
+	return g.CryptoString(ExternalIDBytes)
+}
+
+// NewSecret returns a random session secret of SecretBytes bytes
+func (g DefaultStringGenerator) NewSecret() (string, error) {
+	return rawString(SecretBytes)
+}
+
+// rawString returns n securely generated random bytes, base64
+// encoded without padding, so the string is URL-safe and can be
+// sent in a header unquoted
+func rawString(n int) (string, error) {
+	b, err := GenerateRandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
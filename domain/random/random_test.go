@@ -1,6 +1,7 @@
 package random
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -48,3 +49,33 @@ func TestCryptoString(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultStringGenerator_IDs(t *testing.T) {
+	g := DefaultStringGenerator{}
+	tests := []struct {
+		name    string
+		newID   func() (string, error)
+		wantLen int
+	}{
+		{"external id", g.NewExternalID, 20},
+		{"secret", g.NewSecret, 43},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := tt.newID()
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if len(id) != tt.wantLen {
+				t.Errorf("len(%q) = %d, want %d", id, len(id), tt.wantLen)
+			}
+			if strings.ContainsAny(id, "+/=") {
+				t.Errorf("%q is not URL-safe", id)
+			}
+			id2, _ := tt.newID()
+			if id2 == id {
+				t.Errorf("generated %q twice", id)
+			}
+		})
+	}
+}
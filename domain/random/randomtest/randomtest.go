@@ -4,13 +4,13 @@ package randomtest
 import "testing"
 
 // NewMockStringGenerator is an initializer for MockStringGenerator
-func NewMockStringGenerator(t *testing.T) MockStringGenerator {
+func NewMockStringGenerator(t testing.TB) MockStringGenerator {
 	return MockStringGenerator{t: t}
 }
 
 // MockStringGenerator creates a static string for testing
 type MockStringGenerator struct {
-	t *testing.T
+	t testing.TB
 }
 
 // CryptoString creates a static string for testing
@@ -19,3 +19,17 @@ func (g MockStringGenerator) CryptoString(n int) (string, error) {
 
 	return "superRandomString", nil
 }
+
+// NewExternalID returns a static external ID for testing
+func (g MockStringGenerator) NewExternalID() (string, error) {
+	g.t.Helper()
+
+	return "superRandomString", nil
+}
+
+// NewSecret returns a static session secret for testing
+func (g MockStringGenerator) NewSecret() (string, error) {
+	g.t.Helper()
+
+	return "superRandomSecret", nil
+}
@@ -0,0 +1,51 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TombstoneUsername is the identity an erased user is replaced by
+// wherever they are recorded as the user who did something, e.g. as
+// the user who created a movie or made an audited request
+const TombstoneUsername string = "erased-user"
+
+// ErasureReport reports what was erased for a user, e.g. for a
+// request to erase their personal data under the GDPR
+type ErasureReport struct {
+	// UserID is the ID of the user erased
+	UserID uuid.UUID
+	// Tombstone is the identity the user was replaced by
+	Tombstone string
+	// AuditRecords is the number of audit records rewritten
+	AuditRecords int64
+	// Movies is the number of movies rewritten, as created or last
+	// updated by the user
+	Movies int64
+	// People is the number of people rewritten, as created or last
+	// updated by the user
+	People int64
+	// RequestCounts is the number of request counts (see quota)
+	// deleted
+	RequestCounts int64
+	// Sessions is the number of integration sessions (see
+	// auth.Session), holding the user's refresh tokens, deleted
+	Sessions int64
+	// ExportJobs is the number of export jobs rewritten, as created
+	// by the user
+	ExportJobs int64
+	// EraseTime is when the user was erased. A user who was already
+	// erased is not erased again, and the report has the time they
+	// were first erased and no changes.
+	EraseTime time.Time
+}
+
+// Eraser erases the personal data of a user: their email and names
+// are removed from the users table and every reference to them is
+// replaced by TombstoneUsername. A user who is not found is an
+// errs.NotExist error.
+type Eraser interface {
+	Erase(ctx context.Context, id uuid.UUID) (ErasureReport, error)
+}
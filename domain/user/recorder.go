@@ -0,0 +1,105 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Account is a user recorded in the users table
+type Account struct {
+	// ID is the unique ID of the user in the users table
+	ID uuid.UUID
+	// User is the user as last seen
+	User User
+	// CreateTime is when the user was first seen
+	CreateTime time.Time
+	// UpdateTime is when the user was last recorded
+	UpdateTime time.Time
+	// EraseTime is when the personal data of the user was erased,
+	// or zero if it was not (see Eraser)
+	EraseTime time.Time
+}
+
+// Recorder records the users who use the application, so they can
+// be looked up later, e.g. by an admin
+type Recorder interface {
+	Record(ctx context.Context, u User) error
+}
+
+// DefaultRecordInterval is how often a user is recorded by a
+// ThrottledRecorder when no interval is given
+const DefaultRecordInterval time.Duration = time.Hour
+
+// NewThrottledRecorder is an initializer for ThrottledRecorder,
+// which records users with r at most once per interval (or
+// DefaultRecordInterval if interval is not positive)
+func NewThrottledRecorder(r Recorder, interval time.Duration) *ThrottledRecorder {
+	if interval <= 0 {
+		interval = DefaultRecordInterval
+	}
+	return &ThrottledRecorder{
+		recorder: r,
+		interval: interval,
+		now:      time.Now,
+		recorded: make(map[string]time.Time),
+	}
+}
+
+// ThrottledRecorder records each user at most once per interval, so
+// a user making many requests is not written to the users table on
+// every request. It satisfies the Recorder interface and is safe for
+// concurrent use.
+type ThrottledRecorder struct {
+	recorder Recorder
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	recorded map[string]time.Time
+}
+
+// Record records u unless it was recorded within the interval. If
+// recording fails, u is recorded again on the next call.
+func (tr *ThrottledRecorder) Record(ctx context.Context, u User) error {
+	key := strings.ToLower(u.Email)
+	now := tr.now()
+
+	tr.mu.Lock()
+	last, ok := tr.recorded[key]
+	if ok && now.Sub(last) < tr.interval {
+		tr.mu.Unlock()
+		return nil
+	}
+	tr.recorded[key] = now
+	tr.prune(now)
+	tr.mu.Unlock()
+
+	if err := tr.recorder.Record(ctx, u); err != nil {
+		tr.mu.Lock()
+		delete(tr.recorded, key)
+		tr.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// maxThrottled is the number of users remembered before the users
+// recorded longer than the interval ago are forgotten
+const maxThrottled int = 10000
+
+// prune forgets the users recorded longer than the interval ago once
+// there are too many. The caller must hold the lock.
+func (tr *ThrottledRecorder) prune(now time.Time) {
+	if len(tr.recorded) <= maxThrottled {
+		return
+	}
+	for k, t := range tr.recorded {
+		if now.Sub(t) >= tr.interval {
+			delete(tr.recorded, k)
+		}
+	}
+}
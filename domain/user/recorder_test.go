@@ -0,0 +1,56 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// countingRecorder counts the users recorded, failing if err is set
+type countingRecorder struct {
+	count int
+	err   error
+}
+
+func (cr *countingRecorder) Record(ctx context.Context, u User) error {
+	cr.count++
+	return cr.err
+}
+
+func TestThrottledRecorder(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	cr := &countingRecorder{}
+	tr := NewThrottledRecorder(cr, time.Hour)
+	tr.now = func() time.Time { return now }
+
+	otto := User{Email: "otto.maddox711@gmail.com"}
+
+	// a user is recorded once per interval, whatever the case of
+	// their email
+	c.Assert(tr.Record(ctx, otto), qt.IsNil)
+	c.Assert(tr.Record(ctx, User{Email: "Otto.Maddox711@gmail.com"}), qt.IsNil)
+	c.Assert(cr.count, qt.Equals, 1)
+
+	// other users are recorded
+	c.Assert(tr.Record(ctx, User{Email: "bud@example.com"}), qt.IsNil)
+	c.Assert(cr.count, qt.Equals, 2)
+
+	// and the user again once the interval has passed
+	now = now.Add(time.Hour)
+	c.Assert(tr.Record(ctx, otto), qt.IsNil)
+	c.Assert(cr.count, qt.Equals, 3)
+
+	// a user who could not be recorded is recorded on the next call
+	now = now.Add(time.Hour)
+	cr.err = errors.New("connection reset")
+	c.Assert(tr.Record(ctx, otto), qt.ErrorMatches, "connection reset")
+	cr.err = nil
+	c.Assert(tr.Record(ctx, otto), qt.IsNil)
+	c.Assert(cr.count, qt.Equals, 5)
+}
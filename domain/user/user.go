@@ -1,8 +1,14 @@
 // Package user holds details about a person who is using the application
 package user
 
+import "context"
+
 // User holds details of a User from Google
 type User struct {
+	// Subject: The user's unique ID at the identity provider. Unlike
+	// the email address, it never changes.
+	Subject string `json:"subject,omitempty"`
+
 	// Email: The user's email address.
 	Email string `json:"email,omitempty"`
 
@@ -29,6 +35,23 @@ type User struct {
 	ProfileLink string `json:"profile_link,omitempty"`
 }
 
+// AnonymousUsername is the identity of a request made without an
+// access token, which is only served by the public routes when the
+// API runs in public read mode
+const AnonymousUsername string = "anonymous"
+
+// Anonymous returns the User of a request made without an access
+// token
+func Anonymous() User {
+	return User{Email: AnonymousUsername}
+}
+
+// IsAnonymous reports whether u is the Anonymous user, rather than a
+// user authenticated by the identity provider
+func (u User) IsAnonymous() bool {
+	return u.Email == AnonymousUsername && u.Subject == ""
+}
+
 // IsValid determines whether or not the User has proper
 // data to be considered valid
 func (u User) IsValid() bool {
@@ -42,3 +65,22 @@ func (u User) IsValid() bool {
 	}
 	return true
 }
+
+// contextKey is the type of the key the user is set to a context
+// with, so it cannot collide with the keys of other packages
+type contextKey struct{}
+
+// CtxWithUser returns a copy of ctx with u set as the user making
+// the request, see FromContext. It is the same as
+// requestinfo.WithUser.
+func CtxWithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, contextKey{}, u)
+}
+
+// FromContext returns the user set to ctx using CtxWithUser or
+// requestinfo.WithUser. The boolean is false if no user has been
+// set, e.g. the request has not been authenticated.
+func FromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(contextKey{}).(User)
+	return u, ok
+}
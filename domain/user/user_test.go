@@ -1,6 +1,9 @@
 package user
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestUser_IsValid(t *testing.T) {
 	type fields struct {
@@ -80,3 +83,40 @@ func TestUser_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_IsAnonymous(t *testing.T) {
+	tests := []struct {
+		name string
+		u    User
+		want bool
+	}{
+		{"anonymous", Anonymous(), true},
+		{"typical", User{Email: "otto.maddox@helpinghandacceptanceco.com", Subject: "118162736419485722931"}, false},
+		{"subject", User{Email: AnonymousUsername, Subject: "118162736419485722931"}, false},
+		{"zero", User{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.u.IsAnonymous(); got != tt.want {
+				t.Errorf("IsAnonymous() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	u := User{Email: "otto.maddox@helpinghandacceptanceco.com", FirstName: "Otto", LastName: "Maddox"}
+
+	_, ok := FromContext(context.Background())
+	if ok {
+		t.Fatal("FromContext() ok = true without CtxWithUser")
+	}
+
+	got, ok := FromContext(CtxWithUser(context.Background(), u))
+	if !ok {
+		t.Fatal("FromContext() ok = false after CtxWithUser")
+	}
+	if got != u {
+		t.Errorf("FromContext() = %v, want %v", got, u)
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // NewUser provides a User for testing
-func NewUser(t *testing.T) user.User {
+func NewUser(t testing.TB) user.User {
 	t.Helper()
 
 	return user.User{Email: "otto.maddox711@gmail.com",
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/gateway/blobgateway"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// exportConfig sets the bucket export files are written to, if any,
+// and how long their download URLs work
+type exportConfig struct {
+	// BucketURL is the URL of the bucket, e.g.
+	// file:///var/exports?base_url=...&secret_key_path=... Exports
+	// are not available if it is empty.
+	BucketURL string
+	// URLExpiry is how long the download URL of a file works
+	URLExpiry time.Duration
+}
+
+// newExportRunner returns the export.Runner writing the files of
+// export jobs to the bucket configured by cfg, recording the jobs in
+// store, with the time taken from clk. Without a bucket, the Runner
+// starts no jobs. The returned
+// cleanup function stops the jobs still running and closes the
+// bucket.
+func newExportRunner(ctx context.Context, cfg exportConfig, store export.JobStore, clk clock.Clock, logger zerolog.Logger) (*export.Runner, func(), error) {
+	if cfg.BucketURL == "" {
+		r, stop := export.NewRunner(nil, store, clk, logger)
+		return r, stop, nil
+	}
+
+	b, cleanup, err := blobgateway.OpenBucket(ctx, blobgateway.Config{URL: cfg.BucketURL})
+	if err != nil {
+		return nil, cleanup, err
+	}
+	r, stop := export.NewRunner(b, store, clk, logger)
+	return r, func() {
+		stop()
+		cleanup()
+	}, nil
+}
+
+// newExportURLExpiry returns how long the download URL of an export
+// file works, as configured by cfg
+func newExportURLExpiry(cfg exportConfig) handler.ExportURLExpiry {
+	return handler.ExportURLExpiry(cfg.URLExpiry)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func Test_newExportRunner(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         exportConfig
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{"none", exportConfig{}, false, false},
+		{"file", exportConfig{BucketURL: "file://" + t.TempDir()}, true, false},
+		{"memory", exportConfig{BucketURL: "mem://"}, true, false},
+		{"unknown", exportConfig{BucketURL: "ftp://exports"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			r, cleanup, err := newExportRunner(context.Background(), tt.cfg, memstore.NewExportJobStore(), clock.DefaultClock{}, zerolog.Nop())
+			defer cleanup()
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(r.Enabled(), qt.Equals, tt.wantEnabled)
+		})
+	}
+}
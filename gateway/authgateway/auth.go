@@ -4,24 +4,59 @@ package authgateway
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/user"
-
-	"golang.org/x/oauth2"
-	googleoauth "google.golang.org/api/oauth2/v2"
-	"google.golang.org/api/option"
 )
 
+// maxUserinfoBytes is the size of the largest userinfo response read
+const maxUserinfoBytes int64 = 1 << 20
+
 // GoogleAccessTokenConverter is used to convert an auth.AccessToken to a User
 // through Google's API
-type GoogleAccessTokenConverter struct{}
+type GoogleAccessTokenConverter struct {
+	// Client is used for outbound calls to Google (see the
+	// httpclient package). If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Metadata is Google's OpenID Connect metadata, which holds the
+	// userinfo endpoint. If nil, GoogleMetadata is used.
+	Metadata *MetadataCache
+	// Validation sets the audience a token must have. Its issuer is
+	// checked at startup (see TokenValidation.CheckIssuer).
+	Validation TokenValidation
+
+	// tokeninfoEndpoint is the tokeninfo endpoint called to check
+	// the audience, GoogleTokeninfoEndpoint if empty
+	tokeninfoEndpoint string `wire:"-"`
+}
 
-// Convert calls the Google Userinfo API with the access token and converts
-// the Userinfo struct to a User struct
+// Convert checks the audience of the access token, if set, then
+// calls the Google userinfo endpoint with the access token
+// and converts the userinfo to a User struct. A token which is not
+// accepted is an errs.Unauthenticated error whose Code (see the
+// auth package) tells why.
 func (c GoogleAccessTokenConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, error) {
-	ui, err := userInfo(ctx, token.NewGoogleOauth2Token())
+	md := GoogleMetadata
+	if c.Metadata != nil {
+		md = c.Metadata.Metadata(ctx)
+	}
+
+	tokeninfo := c.tokeninfoEndpoint
+	if tokeninfo == "" {
+		tokeninfo = GoogleTokeninfoEndpoint
+	}
+	if err := c.Validation.checkAudience(ctx, c.Client, tokeninfo, token); err != nil {
+		return user.User{}, err
+	}
+
+	ui, err := userInfo(ctx, c.Client, md.UserinfoEndpoint, token)
 	if err != nil {
 		return user.User{}, err
 	}
@@ -29,41 +64,78 @@ func (c GoogleAccessTokenConverter) Convert(ctx context.Context, token auth.Acce
 	return newUser(ui), nil
 }
 
-// userInfo makes an outbound https call to Google using their
-// Oauth2 v2 api and returns a Userinfo struct which has most
+// googleUserinfo is the response of the userinfo endpoint, holding
+// the standard OpenID Connect claims of the user and Google's hosted
+// domain
+type googleUserinfo struct {
+	Subject      string `json:"sub"`
+	Email        string `json:"email"`
+	FamilyName   string `json:"family_name"`
+	GivenName    string `json:"given_name"`
+	Name         string `json:"name"`
+	HostedDomain string `json:"hd"`
+	Picture      string `json:"picture"`
+	Profile      string `json:"profile"`
+}
+
+// userInfo makes an outbound https call to the userinfo endpoint
+// with the access token and returns the userinfo, which has most
 // profile data elements you typically need
-func userInfo(ctx context.Context, token *oauth2.Token) (*googleoauth.Userinfo, error) {
+func userInfo(ctx context.Context, client *http.Client, endpoint string, token auth.AccessToken) (*googleUserinfo, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
 
-	oauthService, err := googleoauth.NewService(ctx, option.WithTokenSource(oauth2.StaticTokenSource(token)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return nil, errs.E(err)
+		return nil, errs.E(errs.Internal, err)
 	}
+	req.Header.Set("Authorization", auth.BearerTokenType+" "+token.Token)
+	req.Header.Set("Accept", "application/json")
 
-	userInfo, err := oauthService.Userinfo.Get().Do()
+	// "In summary, a 401 Unauthorized response should be used for missing or
+	// bad authentication, and a 403 Forbidden response should be used afterwards,
+	// when the user is authenticated but isn’t authorized to perform the
+	// requested operation on the given resource."
+	// In this case, we are getting a bad response from Google service, assume
+	// they are not able to authenticate properly
+	resp, err := client.Do(req)
 	if err != nil {
-		// "In summary, a 401 Unauthorized response should be used for missing or
-		// bad authentication, and a 403 Forbidden response should be used afterwards,
-		// when the user is authenticated but isn’t authorized to perform the
-		// requested operation on the given resource."
-		// In this case, we are getting a bad response from Google service, assume
-		// they are not able to authenticate properly
-		return nil, errs.E(errs.Unauthenticated, err)
+		return nil, errs.E(errs.Unauthenticated, errors.Wrap(err, "calling userinfo endpoint"))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		code := errs.Code("")
+		if resp.StatusCode == http.StatusUnauthorized {
+			code = auth.CodeInvalidToken
+		}
+		return nil, errs.E(errs.Unauthenticated, code, errors.Errorf("userinfo endpoint: %s", resp.Status))
+	}
+
+	ui := new(googleUserinfo)
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxUserinfoBytes)).Decode(ui); err != nil {
+		return nil, errs.E(errs.Unauthenticated, errors.Wrap(err, "decoding userinfo"))
+	}
+	if ui.Subject == "" {
+		return nil, errs.E(errs.Unauthenticated, errors.New("userinfo has no subject"))
 	}
 
-	return userInfo, nil
+	return ui, nil
 }
 
-// newUser initializes the user.User struct given a Userinfo struct
-// from Google
-func newUser(userinfo *googleoauth.Userinfo) user.User {
+// newUser initializes the user.User struct given the userinfo from
+// Google
+func newUser(userinfo *googleUserinfo) user.User {
 	return user.User{
-		Email:     userinfo.Email,
-		LastName:  userinfo.FamilyName,
-		FirstName: userinfo.GivenName,
-		FullName:  userinfo.Name,
-		//Gender:       userinfo.Gender,
-		HostedDomain: userinfo.Hd,
+		Subject:      userinfo.Subject,
+		Email:        userinfo.Email,
+		LastName:     userinfo.FamilyName,
+		FirstName:    userinfo.GivenName,
+		FullName:     userinfo.Name,
+		HostedDomain: userinfo.HostedDomain,
 		PictureURL:   userinfo.Picture,
-		ProfileLink:  userinfo.Link,
+		ProfileLink:  userinfo.Profile,
 	}
 }
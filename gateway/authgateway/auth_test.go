@@ -2,31 +2,39 @@ package authgateway
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
 
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/user"
-	googleoauth "google.golang.org/api/oauth2/v2"
 )
 
 func Test_newUser(t *testing.T) {
 	type args struct {
-		userinfo *googleoauth.Userinfo
+		userinfo *googleUserinfo
 	}
 
-	ui := &googleoauth.Userinfo{
-		Email:      "otto.maddox@helpinghandacceptanceco.com",
-		FamilyName: "Maddox",
-		GivenName:  "Otto",
-		Name:       "Otto Maddox",
-		Hd:         "helpinghand.com",
-		Link:       "google.com/ottoprofile",
-		Picture:    "google.com/picture",
+	ui := &googleUserinfo{
+		Subject:      "108533491328720123456",
+		Email:        "otto.maddox@helpinghandacceptanceco.com",
+		FamilyName:   "Maddox",
+		GivenName:    "Otto",
+		Name:         "Otto Maddox",
+		HostedDomain: "helpinghand.com",
+		Profile:      "google.com/ottoprofile",
+		Picture:      "google.com/picture",
 	}
 
 	u := user.User{
+		Subject:      "108533491328720123456",
 		Email:        "otto.maddox@helpinghandacceptanceco.com",
 		LastName:     "Maddox",
 		FirstName:    "Otto",
@@ -110,3 +118,47 @@ func TestGoogleToken2User_User(t *testing.T) {
 		})
 	}
 }
+
+func TestGoogleAccessTokenConverter_Convert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/userinfo" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer goodToken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sub": "108533491328720123456", "email": "otto.maddox@helpinghandacceptanceco.com", "given_name": "Otto", "family_name": "Maddox", "name": "Otto Maddox", "hd": "helpinghand.com"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	// the discovery document is not found, so the fallback metadata,
+	// with the userinfo endpoint of srv, is used
+	md := NewMetadataCache(srv.URL+"/.well-known/openid-configuration", srv.Client(), 0,
+		ProviderMetadata{Issuer: srv.URL, UserinfoEndpoint: srv.URL + "/userinfo"}, zerolog.Nop())
+	conv := GoogleAccessTokenConverter{Client: srv.Client(), Metadata: md}
+
+	t.Run("valid token", func(t *testing.T) {
+		c := qt.New(t)
+
+		got, err := conv.Convert(context.Background(), auth.AccessToken{Token: "goodToken", TokenType: auth.BearerTokenType})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, user.User{
+			Subject:      "108533491328720123456",
+			Email:        "otto.maddox@helpinghandacceptanceco.com",
+			LastName:     "Maddox",
+			FirstName:    "Otto",
+			FullName:     "Otto Maddox",
+			HostedDomain: "helpinghand.com",
+		})
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		c := qt.New(t)
+
+		_, err := conv.Convert(context.Background(), auth.AccessToken{Token: "badToken", TokenType: auth.BearerTokenType})
+		c.Assert(errs.Match(errs.E(errs.Unauthenticated, auth.CodeInvalidToken), err), qt.IsTrue)
+	})
+}
@@ -0,0 +1,211 @@
+package authgateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// GoogleDiscoveryURL is the URL of Google's OpenID Connect discovery
+// document
+const GoogleDiscoveryURL string = "https://accounts.google.com/.well-known/openid-configuration"
+
+// DefaultMetadataTTL is how long fetched provider metadata is used
+// before it is refreshed
+const DefaultMetadataTTL = time.Hour
+
+// metadataRetryInterval is how long after a failed fetch of the
+// metadata the next fetch is tried
+const metadataRetryInterval = 30 * time.Second
+
+// metadataFetchTimeout is how long a fetch of the metadata in the
+// background may take
+const metadataFetchTimeout = 10 * time.Second
+
+// maxMetadataBytes is the size of the largest discovery document read
+const maxMetadataBytes int64 = 1 << 20
+
+// ProviderMetadata is the part of the OpenID Connect discovery
+// document of an identity provider used by the gateway
+type ProviderMetadata struct {
+	Issuer           string `json:"issuer"`
+	UserinfoEndpoint string `json:"userinfo_endpoint"`
+	TokenEndpoint    string `json:"token_endpoint"`
+}
+
+// GoogleMetadata is Google's metadata as published in its discovery
+// document. It is used until the document has been fetched, so
+// Google's discovery endpoint being slow or down when the server
+// starts does not fail requests.
+var GoogleMetadata = ProviderMetadata{
+	Issuer:           "https://accounts.google.com",
+	UserinfoEndpoint: "https://openidconnect.googleapis.com/v1/userinfo",
+	TokenEndpoint:    "https://oauth2.googleapis.com/token",
+}
+
+// NewMetadataCache is an initializer for MetadataCache, caching the
+// discovery document at discoveryURL for ttl (DefaultMetadataTTL if
+// zero) and using fallback until it is first fetched. If client is
+// nil, http.DefaultClient is used.
+func NewMetadataCache(discoveryURL string, client *http.Client, ttl time.Duration, fallback ProviderMetadata, logger zerolog.Logger) *MetadataCache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if ttl <= 0 {
+		ttl = DefaultMetadataTTL
+	}
+	return &MetadataCache{
+		url:      discoveryURL,
+		client:   client,
+		ttl:      ttl,
+		fallback: fallback,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// NewGoogleMetadataCache is an initializer for the MetadataCache of
+// Google's discovery document
+func NewGoogleMetadataCache(client *http.Client, logger zerolog.Logger) *MetadataCache {
+	return NewMetadataCache(GoogleDiscoveryURL, client, DefaultMetadataTTL, GoogleMetadata, logger)
+}
+
+// MetadataCache caches the metadata of an identity provider. The
+// metadata is fetched lazily, on first use, and refreshed once it is
+// older than the TTL, always in the background: until the first
+// fetch succeeds the fallback metadata is used, and after that the
+// stale metadata is used while it is refreshed (stale while
+// revalidate), so a request never waits for, or fails because of,
+// the provider's discovery endpoint. A failed fetch is logged and
+// retried no sooner than 30 seconds later. MetadataCache is safe for
+// concurrent use.
+type MetadataCache struct {
+	url      string
+	client   *http.Client
+	ttl      time.Duration
+	fallback ProviderMetadata
+	logger   zerolog.Logger
+	now      func() time.Time
+
+	mu sync.Mutex
+	md ProviderMetadata
+	// fetched is when md was fetched, zero if it has never been
+	fetched time.Time
+	// tried is when the last fetch was started
+	tried      time.Time
+	refreshing bool
+}
+
+// Metadata returns the cached metadata, or the fallback metadata if
+// it has not been fetched yet. If the metadata has not been fetched
+// or is older than the TTL, it is fetched in the background.
+func (mc *MetadataCache) Metadata(ctx context.Context) ProviderMetadata {
+	now := mc.now()
+
+	mc.mu.Lock()
+	md, fetched := mc.md, mc.fetched
+	stale := fetched.IsZero() || now.Sub(fetched) >= mc.ttl
+	refresh := stale && !mc.refreshing && (mc.tried.IsZero() || now.Sub(mc.tried) >= metadataRetryInterval)
+	if refresh {
+		mc.refreshing = true
+		mc.tried = now
+	}
+	mc.mu.Unlock()
+
+	if refresh {
+		go mc.refreshInBackground()
+	}
+
+	if fetched.IsZero() {
+		return mc.fallback
+	}
+	return md
+}
+
+// refreshInBackground fetches the metadata for Metadata, logging a
+// failure
+func (mc *MetadataCache) refreshInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), metadataFetchTimeout)
+	defer cancel()
+
+	err := mc.fetch(ctx)
+
+	mc.mu.Lock()
+	mc.refreshing = false
+	mc.mu.Unlock()
+
+	if err != nil {
+		mc.logger.Warn().Err(err).Msg("identity provider metadata refresh failed, using the cached metadata")
+	}
+}
+
+// Refresh fetches the metadata and caches it. It is used to fetch
+// the metadata before the server listens (see the warm-up hooks),
+// rather than on first use.
+func (mc *MetadataCache) Refresh(ctx context.Context) error {
+	mc.mu.Lock()
+	mc.tried = mc.now()
+	mc.mu.Unlock()
+
+	return mc.fetch(ctx)
+}
+
+// fetch fetches the discovery document and caches its metadata
+func (mc *MetadataCache) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mc.url, nil)
+	if err != nil {
+		return errs.E(errs.Internal, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return errs.E(errs.IO, errors.Wrap(err, "fetching identity provider metadata"))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return errs.E(errs.IO, errors.Errorf("fetching identity provider metadata: %s", resp.Status))
+	}
+
+	var md ProviderMetadata
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxMetadataBytes)).Decode(&md); err != nil {
+		return errs.E(errs.IO, errors.Wrap(err, "decoding identity provider metadata"))
+	}
+	if err = md.validate(); err != nil {
+		return err
+	}
+
+	mc.mu.Lock()
+	mc.md = md
+	mc.fetched = mc.now()
+	mc.mu.Unlock()
+
+	return nil
+}
+
+// validate returns an error if md has no issuer or its endpoints are
+// not https URLs, so a broken discovery document is never used
+func (md ProviderMetadata) validate() error {
+	if md.Issuer == "" {
+		return errs.E(errs.IO, errors.New("identity provider metadata has no issuer"))
+	}
+	for name, endpoint := range map[string]string{"userinfo_endpoint": md.UserinfoEndpoint, "token_endpoint": md.TokenEndpoint} {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errs.E(errs.IO, errors.Errorf("identity provider metadata %s %q is not an https URL", name, endpoint))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package authgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+)
+
+// discoveryServer serves a discovery document whose issuer is the
+// value of issuer, or a 503 while fail is true
+type discoveryServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	issuer   string
+	fail     bool
+	requests int
+}
+
+func newDiscoveryServer(t *testing.T) *discoveryServer {
+	ds := &discoveryServer{issuer: "https://issuer1.example.com"}
+	ds.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ds.mu.Lock()
+		defer ds.mu.Unlock()
+		ds.requests++
+		if ds.fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"issuer": %q, "userinfo_endpoint": "https://example.com/userinfo", "token_endpoint": "https://example.com/token", "jwks_uri": "https://example.com/certs"}`, ds.issuer)
+	}))
+	t.Cleanup(ds.Close)
+	return ds
+}
+
+func (ds *discoveryServer) count() int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.requests
+}
+
+func (ds *discoveryServer) set(issuer string, fail bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.issuer, ds.fail = issuer, fail
+}
+
+// waitRefreshed waits for the background refresh of mc to finish
+func waitRefreshed(t *testing.T, mc *MetadataCache) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mc.mu.Lock()
+		refreshing := mc.refreshing
+		mc.mu.Unlock()
+		if !refreshing {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("metadata refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMetadataCache_Metadata(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	ds := newDiscoveryServer(t)
+	fallback := ProviderMetadata{Issuer: "https://fallback.example.com"}
+	mc := NewMetadataCache(ds.URL, ds.Client(), time.Hour, fallback, zerolog.Nop())
+	// the clock is read by the background refresh too
+	var clockMu sync.Mutex
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	mc.now = func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		now = now.Add(d)
+	}
+
+	// a provider failing at startup does not fail requests: the
+	// fallback is used and the fetch is retried later
+	ds.set("https://issuer1.example.com", true)
+	c.Assert(mc.Metadata(ctx), qt.DeepEquals, fallback)
+	waitRefreshed(t, mc)
+	c.Assert(mc.Metadata(ctx), qt.DeepEquals, fallback)
+	waitRefreshed(t, mc)
+	c.Assert(ds.count(), qt.Equals, 1)
+
+	// once the retry interval has passed, the metadata is fetched
+	ds.set("https://issuer1.example.com", false)
+	advance(metadataRetryInterval)
+	c.Assert(mc.Metadata(ctx), qt.DeepEquals, fallback)
+	waitRefreshed(t, mc)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer1.example.com")
+	c.Assert(ds.count(), qt.Equals, 2)
+
+	// fresh metadata is not fetched again
+	advance(time.Hour - time.Second)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer1.example.com")
+	c.Assert(ds.count(), qt.Equals, 2)
+
+	// stale metadata is used while it is refreshed
+	ds.set("https://issuer2.example.com", false)
+	advance(time.Second)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer1.example.com")
+	waitRefreshed(t, mc)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer2.example.com")
+
+	// and kept if the refresh fails
+	ds.set("https://issuer3.example.com", true)
+	advance(time.Hour)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer2.example.com")
+	waitRefreshed(t, mc)
+	c.Assert(mc.Metadata(ctx).Issuer, qt.Equals, "https://issuer2.example.com")
+}
+
+func TestMetadataCache_Refresh(t *testing.T) {
+	ds := newDiscoveryServer(t)
+
+	tests := []struct {
+		name    string
+		issuer  string
+		fail    bool
+		wantErr bool
+	}{
+		{"ok", "https://issuer.example.com", false, false},
+		{"unavailable", "https://issuer.example.com", true, true},
+		{"no issuer", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			ds.set(tt.issuer, tt.fail)
+			mc := NewMetadataCache(ds.URL, ds.Client(), 0, GoogleMetadata, zerolog.Nop())
+
+			err := mc.Refresh(context.Background())
+			if tt.wantErr {
+				c.Assert(err, qt.Not(qt.IsNil))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(mc.Metadata(context.Background()), qt.DeepEquals, ProviderMetadata{
+				Issuer:           tt.issuer,
+				UserinfoEndpoint: "https://example.com/userinfo",
+				TokenEndpoint:    "https://example.com/token",
+			})
+		})
+	}
+}
+
+func TestProviderMetadata_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      ProviderMetadata
+		wantErr bool
+	}{
+		{"google", GoogleMetadata, false},
+		{"no issuer", ProviderMetadata{UserinfoEndpoint: "https://example.com/userinfo", TokenEndpoint: "https://example.com/token"}, true},
+		{"http endpoint", ProviderMetadata{Issuer: "https://example.com", UserinfoEndpoint: "http://example.com/userinfo", TokenEndpoint: "https://example.com/token"}, true},
+		{"no token endpoint", ProviderMetadata{Issuer: "https://example.com", UserinfoEndpoint: "https://example.com/userinfo"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, tt.md.validate() != nil, qt.Equals, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package authgateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// OAuthClient is the API's OAuth client at Google, which the
+// authorization codes and refresh tokens of sessions are issued to
+type OAuthClient struct {
+	ID     string
+	Secret string
+}
+
+// GoogleTokenRefresher satisfies the auth.TokenRefresher interface
+// using Google's token endpoint
+type GoogleTokenRefresher struct {
+	// Client is the HTTP client the token endpoint is called with.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Metadata is Google's OpenID Connect metadata, which holds the
+	// token endpoint. If nil, GoogleMetadata is used.
+	Metadata *MetadataCache
+	// OAuthClient is the client the tokens are issued to
+	OAuthClient OAuthClient
+}
+
+// Exchange exchanges an authorization code, which was given to
+// redirectURI, for tokens. The code must have been issued for
+// offline access for a refresh token to be issued.
+func (r GoogleTokenRefresher) Exchange(ctx context.Context, code, redirectURI string) (auth.SessionToken, error) {
+	cfg, ctx, err := r.config(ctx, redirectURI)
+	if err != nil {
+		return auth.SessionToken{}, err
+	}
+	tk, err := cfg.Exchange(ctx, code)
+	if err != nil {
+		return auth.SessionToken{}, tokenError(err)
+	}
+	return newSessionToken(tk), nil
+}
+
+// Refresh returns a new access token for refreshToken
+func (r GoogleTokenRefresher) Refresh(ctx context.Context, refreshToken string) (auth.SessionToken, error) {
+	cfg, ctx, err := r.config(ctx, "")
+	if err != nil {
+		return auth.SessionToken{}, err
+	}
+	tk, err := cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return auth.SessionToken{}, tokenError(err)
+	}
+
+	st := newSessionToken(tk)
+	// the refresh token is given back as is unless a new one was
+	// issued
+	if st.RefreshToken == refreshToken {
+		st.RefreshToken = ""
+	}
+	return st, nil
+}
+
+// config returns the oauth2.Config of the OAuth client for the
+// current token endpoint, and ctx set with the HTTP client oauth2
+// calls it with
+func (r GoogleTokenRefresher) config(ctx context.Context, redirectURI string) (*oauth2.Config, context.Context, error) {
+	if r.OAuthClient.ID == "" || r.OAuthClient.Secret == "" {
+		return nil, ctx, errs.E(errs.Unavailable, errors.New("no OAuth client is configured for sessions"))
+	}
+
+	md := GoogleMetadata
+	if r.Metadata != nil {
+		md = r.Metadata.Metadata(ctx)
+	}
+	if r.Client != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, r.Client)
+	}
+
+	return &oauth2.Config{
+		ClientID:     r.OAuthClient.ID,
+		ClientSecret: r.OAuthClient.Secret,
+		Endpoint:     oauth2.Endpoint{TokenURL: md.TokenEndpoint, AuthStyle: oauth2.AuthStyleInParams},
+		RedirectURL:  redirectURI,
+	}, ctx, nil
+}
+
+// newSessionToken converts tk to an auth.SessionToken
+func newSessionToken(tk *oauth2.Token) auth.SessionToken {
+	return auth.SessionToken{
+		AccessToken:  auth.AccessToken{Token: tk.AccessToken, TokenType: auth.BearerTokenType},
+		Expiry:       tk.Expiry,
+		RefreshToken: tk.RefreshToken,
+	}
+}
+
+// tokenError returns the error of a failed call to the token
+// endpoint. A grant the endpoint does not accept is an
+// errs.Unauthenticated error with code auth.CodeInvalidGrant, any
+// other failure is errs.Unavailable.
+func tokenError(err error) error {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return errs.E(errs.Unavailable, errors.Wrap(err, "calling token endpoint"))
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(re.Body, &body)
+	if body.Error == "invalid_grant" || re.Response.StatusCode == http.StatusUnauthorized {
+		return errs.E(errs.Unauthenticated, auth.CodeInvalidGrant, errors.Errorf("token endpoint: %s %s", re.Response.Status, body.Error))
+	}
+	return errs.E(errs.Unavailable, errors.Errorf("token endpoint: %s %s", re.Response.Status, body.Error))
+}
@@ -0,0 +1,95 @@
+package authgateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestGoogleTokenRefresher(t *testing.T) {
+	// the token endpoint of srv issues tokens for goodCode and the
+	// refresh tokens goodRefreshToken and rotatedRefreshToken
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" || r.PostFormValue("client_id") != "clientID" || r.PostFormValue("client_secret") != "clientSecret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("grant_type") + " " + r.PostFormValue("code") + r.PostFormValue("refresh_token") {
+		case "authorization_code goodCode":
+			_, _ = w.Write([]byte(`{"access_token": "accessToken1", "token_type": "Bearer", "expires_in": 3599, "refresh_token": "goodRefreshToken"}`))
+		case "refresh_token goodRefreshToken":
+			_, _ = w.Write([]byte(`{"access_token": "accessToken2", "token_type": "Bearer", "expires_in": 3599}`))
+		case "refresh_token rotatedRefreshToken":
+			_, _ = w.Write([]byte(`{"access_token": "accessToken3", "token_type": "Bearer", "expires_in": 3599, "refresh_token": "newRefreshToken"}`))
+		case "refresh_token unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been expired or revoked."}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	md := NewMetadataCache(srv.URL+"/.well-known/openid-configuration", srv.Client(), 0,
+		ProviderMetadata{Issuer: srv.URL, TokenEndpoint: srv.URL + "/token"}, zerolog.Nop())
+	r := GoogleTokenRefresher{
+		Client:      srv.Client(),
+		Metadata:    md,
+		OAuthClient: OAuthClient{ID: "clientID", Secret: "clientSecret"},
+	}
+	ctx := context.Background()
+
+	t.Run("exchange", func(t *testing.T) {
+		c := qt.New(t)
+
+		st, err := r.Exchange(ctx, "goodCode", "https://example.com/callback")
+		c.Assert(err, qt.IsNil)
+		c.Assert(st.AccessToken, qt.DeepEquals, auth.AccessToken{Token: "accessToken1", TokenType: auth.BearerTokenType})
+		c.Assert(st.RefreshToken, qt.Equals, "goodRefreshToken")
+		c.Assert(st.Expiry.After(time.Now().Add(time.Hour-time.Minute)), qt.IsTrue)
+
+		_, err = r.Exchange(ctx, "usedCode", "https://example.com/callback")
+		c.Assert(errs.Match(errs.E(errs.Unauthenticated, auth.CodeInvalidGrant), err), qt.IsTrue, qt.Commentf("%v", err))
+	})
+
+	tests := []struct {
+		name             string
+		refreshToken     string
+		wantToken        string
+		wantRefreshToken string
+		wantErr          error
+	}{
+		{"refresh", "goodRefreshToken", "accessToken2", "", nil},
+		{"rotated", "rotatedRefreshToken", "accessToken3", "newRefreshToken", nil},
+		{"revoked", "revokedRefreshToken", "", "", errs.E(errs.Unauthenticated, auth.CodeInvalidGrant)},
+		{"unavailable", "unavailable", "", "", errs.E(errs.Unavailable)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			st, err := r.Refresh(ctx, tt.refreshToken)
+			if tt.wantErr != nil {
+				c.Assert(errs.Match(tt.wantErr, err), qt.IsTrue, qt.Commentf("%v", err))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(st.Token, qt.Equals, tt.wantToken)
+			c.Assert(st.RefreshToken, qt.Equals, tt.wantRefreshToken)
+		})
+	}
+
+	t.Run("no OAuth client", func(t *testing.T) {
+		_, err := GoogleTokenRefresher{Client: srv.Client(), Metadata: md}.Refresh(ctx, "goodRefreshToken")
+		qt.Assert(t, errs.KindIs(errs.Unavailable, err), qt.IsTrue)
+	})
+}
@@ -0,0 +1,104 @@
+package authgateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewTokenValidation(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(NewTokenValidation("", ""), qt.DeepEquals, TokenValidation{})
+	c.Assert(NewTokenValidation(" app1.apps.googleusercontent.com, ,app2.apps.googleusercontent.com", " https://accounts.google.com "), qt.DeepEquals, TokenValidation{
+		Audiences: []string{"app1.apps.googleusercontent.com", "app2.apps.googleusercontent.com"},
+		Issuer:    "https://accounts.google.com",
+	})
+}
+
+func TestTokenValidation_CheckIssuer(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(TokenValidation{}.CheckIssuer(GoogleMetadata), qt.IsNil)
+	c.Assert(TokenValidation{Issuer: "https://accounts.google.com"}.CheckIssuer(GoogleMetadata), qt.IsNil)
+
+	err := TokenValidation{Issuer: "https://issuer.example.com"}.CheckIssuer(GoogleMetadata)
+	c.Assert(errs.Match(errs.E(errs.Validation, errs.Parameter("token-issuer")), err), qt.IsTrue, qt.Commentf("%v", err))
+}
+
+func TestGoogleAccessTokenConverter_Convert_validation(t *testing.T) {
+	// the tokeninfo endpoint of srv reports the token as issued to
+	// app1, expired or invalid depending on the token, which must be
+	// posted and never be in the URL
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/tokeninfo":
+			if r.Method != http.MethodPost || r.URL.RawQuery != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_request"}`))
+				return
+			}
+			switch r.PostFormValue("access_token") {
+			case "goodToken":
+				_, _ = w.Write([]byte(`{"aud": "app1", "azp": "app1", "expires_in": "3599", "scope": "openid email"}`))
+			case "expiredToken":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_token", "error_description": "Token expired or revoked"}`))
+			case "lastSecondToken":
+				_, _ = w.Write([]byte(`{"aud": "app1", "azp": "app1", "expires_in": "0"}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_token", "error_description": "Invalid Value"}`))
+			}
+		case "/userinfo":
+			_, _ = w.Write([]byte(`{"sub": "108533491328720123456", "email": "otto.maddox@helpinghandacceptanceco.com"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	md := NewMetadataCache(srv.URL+"/.well-known/openid-configuration", srv.Client(), 0,
+		ProviderMetadata{Issuer: "https://accounts.google.com", UserinfoEndpoint: srv.URL + "/userinfo"}, zerolog.Nop())
+
+	tests := []struct {
+		name       string
+		validation TokenValidation
+		token      string
+		wantCode   errs.Code
+	}{
+		{"not checked", TokenValidation{}, "otherToken", ""},
+		{"audience", TokenValidation{Audiences: []string{"app2", "app1"}}, "goodToken", ""},
+		{"wrong audience", TokenValidation{Audiences: []string{"app2"}}, "goodToken", auth.CodeWrongAudience},
+		{"expired", TokenValidation{Audiences: []string{"app1"}}, "expiredToken", auth.CodeTokenExpired},
+		{"expires now", TokenValidation{Audiences: []string{"app1"}}, "lastSecondToken", auth.CodeTokenExpired},
+		{"invalid", TokenValidation{Audiences: []string{"app1"}}, "otherToken", auth.CodeInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			conv := GoogleAccessTokenConverter{
+				Client:            srv.Client(),
+				Metadata:          md,
+				Validation:        tt.validation,
+				tokeninfoEndpoint: srv.URL + "/tokeninfo",
+			}
+			got, err := conv.Convert(context.Background(), auth.AccessToken{Token: tt.token, TokenType: auth.BearerTokenType})
+			if tt.wantCode == "" {
+				c.Assert(err, qt.IsNil)
+				c.Assert(got.Subject, qt.Equals, "108533491328720123456")
+				return
+			}
+			c.Assert(errs.Match(errs.E(errs.Unauthenticated, tt.wantCode), err), qt.IsTrue, qt.Commentf("%v", err))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package blobgateway writes export files to object storage using
+// the Go CDK (gocloud.dev/blob), so the bucket is chosen by its URL.
+// The file:// and mem:// schemes are registered. The bucket of a
+// cloud provider, e.g. gs://, is used by importing the provider's
+// driver, e.g. gocloud.dev/blob/gcsblob, for its side effects.
+package blobgateway
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob" // file:// buckets
+	_ "gocloud.dev/blob/memblob"  // mem:// buckets
+	"gocloud.dev/gcerrors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Config configures the bucket export files are written to
+type Config struct {
+	// URL is the URL of the bucket, e.g. gs://my-exports or
+	// file:///var/exports?base_url=https://files.example.com&secret_key_path=/etc/exports.key,
+	// where the file server at base_url verifies the signed URLs
+	// with the key
+	URL string
+}
+
+// OpenBucket is an initializer for Bucket. The returned function
+// closes the Bucket.
+func OpenBucket(ctx context.Context, cfg Config) (*Bucket, func(), error) {
+	b, err := blob.OpenBucket(ctx, cfg.URL)
+	if err != nil {
+		return nil, func() {}, errs.E(errs.Validation, errs.Parameter("export-bucket"), err)
+	}
+
+	return &Bucket{bucket: b}, func() { _ = b.Close() }, nil
+}
+
+// Bucket is a bucket of an object storage service. It satisfies the
+// export.Bucket interface and is safe for concurrent use.
+type Bucket struct {
+	bucket *blob.Bucket
+}
+
+// NewWriter returns a writer for the object with key, see
+// blob.Bucket.NewWriter
+func (b *Bucket) NewWriter(ctx context.Context, key string, contentType string) (io.WriteCloser, error) {
+	w, err := b.bucket.NewWriter(ctx, key, &blob.WriterOptions{ContentType: contentType})
+	if err != nil {
+		return nil, blobError(err)
+	}
+	return w, nil
+}
+
+// SignedURL returns a URL the object with key can be downloaded from
+// until expiry has passed. A bucket which cannot sign URLs, e.g. a
+// mem:// bucket or a file:// bucket without a base_url, is an
+// errs.Unavailable error.
+func (b *Bucket) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := b.bucket.SignedURL(ctx, key, &blob.SignedURLOptions{Expiry: expiry})
+	if err != nil {
+		return "", blobError(err)
+	}
+	return u, nil
+}
+
+// blobError returns err, returned by the Go CDK, as an *errs.Error
+func blobError(err error) error {
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound:
+		return errs.E(errs.NotExist, err)
+	case gcerrors.Unimplemented:
+		return errs.E(errs.Unavailable, errors.Wrap(err, "bucket does not support the operation"))
+	}
+	return errs.E(errs.IO, err)
+}
@@ -0,0 +1,56 @@
+package blobgateway
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestBucket(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	keyPath := filepath.Join(t.TempDir(), "exports.key")
+	c.Assert(ioutil.WriteFile(keyPath, []byte("secretKey"), 0600), qt.IsNil)
+
+	b, closeBucket, err := OpenBucket(ctx, Config{URL: "file://" + filepath.ToSlash(dir) + "?base_url=https://files.example.com/exports&secret_key_path=" + keyPath})
+	c.Assert(err, qt.IsNil)
+	t.Cleanup(closeBucket)
+
+	w, err := b.NewWriter(ctx, "exports/movies/1.csv", "text/csv")
+	c.Assert(err, qt.IsNil)
+	_, err = w.Write([]byte("title\nRepo Man\n"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(w.Close(), qt.IsNil)
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "exports", "movies", "1.csv"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(got), qt.Equals, "title\nRepo Man\n")
+
+	u, err := b.SignedURL(ctx, "exports/movies/1.csv", time.Hour)
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.HasPrefix(u, "https://files.example.com/exports?"), qt.IsTrue, qt.Commentf("%s", u))
+	c.Assert(u, qt.Contains, "signature=")
+
+	c.Run("cannot sign", func(c *qt.C) {
+		mb, closeBucket, err := OpenBucket(ctx, Config{URL: "mem://"})
+		c.Assert(err, qt.IsNil)
+		defer closeBucket()
+
+		_, err = mb.SignedURL(ctx, "exports/movies/1.csv", time.Hour)
+		c.Assert(errs.KindIs(errs.Unavailable, err), qt.IsTrue, qt.Commentf("%v", err))
+	})
+
+	c.Run("unknown scheme", func(c *qt.C) {
+		_, _, err := OpenBucket(ctx, Config{URL: "nope://bucket"})
+		c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+	})
+}
@@ -0,0 +1,89 @@
+// Package errorgateway reports server errors to an error reporting
+// service: Sentry or Google Error Reporting
+package errorgateway
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// The error reporting services errors can be reported to
+const (
+	ReporterNone   string = "none"
+	ReporterSentry string = "sentry"
+	ReporterGoogle string = "google"
+)
+
+// defaultService is the name of the service errors are reported for
+// when no name is given
+const defaultService string = "go-api-basic"
+
+// Config configures where server errors are reported
+type Config struct {
+	// Reporter is the service errors are reported to: none (the
+	// default when empty), sentry or google
+	Reporter string
+	// SentryDSN is the DSN of the Sentry project errors are
+	// reported to by the sentry reporter
+	SentryDSN string
+	// Service and Version identify the server in reports. If
+	// Service is empty, the Cloud Run service name (K_SERVICE) is
+	// used, or go-api-basic if not on Cloud Run.
+	Service string
+	Version string
+}
+
+// NewReporter returns the errs.ErrorReporter for cfg. The sentry
+// reporter sends errors to Sentry using client. The google reporter
+// writes errors to stdout as structured logs in the format picked up
+// by Google Error Reporting, so it needs the server to run on Google
+// Cloud (e.g. Cloud Run) with its logs sent to Cloud Logging.
+func NewReporter(cfg Config, client *http.Client) (errs.ErrorReporter, error) {
+	if cfg.Service == "" {
+		cfg.Service = os.Getenv("K_SERVICE")
+	}
+	if cfg.Service == "" {
+		cfg.Service = defaultService
+	}
+
+	switch cfg.Reporter {
+	case "", ReporterNone:
+		return errs.NopReporter{}, nil
+	case ReporterSentry:
+		return NewSentryReporter(cfg.SentryDSN, client, cfg.Service, cfg.Version)
+	case ReporterGoogle:
+		return NewGoogleReporter(os.Stdout, cfg.Service, cfg.Version), nil
+	}
+	return nil, errs.E(errs.Validation, errs.Parameter("error-reporter"),
+		errors.Errorf("unknown error reporter %q, must be none, sentry or google", cfg.Reporter))
+}
+
+// frame is a frame of the stack trace of an error
+type frame struct {
+	function string
+	file     string
+	line     int
+}
+
+// stackFrames returns the frames of the stack trace of err (see
+// errs.StackTrace), innermost first
+func stackFrames(err error) []frame {
+	st := errs.StackTrace(err)
+	frames := make([]frame, 0, len(st))
+	for _, f := range st {
+		// errors.Frame is the program counter plus one
+		pc := uintptr(f) - 1
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			continue
+		}
+		file, line := fn.FileLine(pc)
+		frames = append(frames, frame{function: fn.Name(), file: file, line: line})
+	}
+	return frames
+}
@@ -0,0 +1,55 @@
+package errorgateway
+
+import (
+	"fmt"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewReporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr string
+	}{
+		{"empty", Config{}, "errs.NopReporter", ""},
+		{"none", Config{Reporter: ReporterNone}, "errs.NopReporter", ""},
+		{"google", Config{Reporter: ReporterGoogle}, "*errorgateway.GoogleReporter", ""},
+		{"sentry", Config{Reporter: ReporterSentry, SentryDSN: "https://abc@o1.ingest.sentry.io/42"}, "*errorgateway.SentryReporter", ""},
+		{"sentry without dsn", Config{Reporter: ReporterSentry}, "", "sentry-dsn"},
+		{"unknown", Config{Reporter: "bugsnag"}, "", "error-reporter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := NewReporter(tt.cfg, nil)
+			if tt.wantErr != "" {
+				var e *errs.Error
+				c.Assert(errors.As(err, &e), qt.IsTrue)
+				c.Assert(e.Kind, qt.Equals, errs.Validation)
+				c.Assert(string(e.Param), qt.Equals, tt.wantErr)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(fmt.Sprintf("%T", got), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestStackFrames(t *testing.T) {
+	c := qt.New(t)
+
+	frames := stackFrames(errs.E(errs.Database, errors.New("connection reset")))
+	c.Assert(len(frames) > 0, qt.IsTrue)
+	// the innermost frame is where the error was created
+	c.Assert(frames[0].function, qt.Equals, "github.com/gilcrest/go-api-basic/gateway/errorgateway.TestStackFrames")
+	c.Assert(frames[0].file, qt.Matches, `.*/errorgateway_test\.go`)
+
+	c.Assert(stackFrames(errors.WithStack(nil)), qt.HasLen, 0)
+}
@@ -0,0 +1,110 @@
+package errorgateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// reportedErrorEventType is the @type of a log entry which Google
+// Error Reporting reads as an error
+const reportedErrorEventType string = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// NewGoogleReporter is an initializer for GoogleReporter
+func NewGoogleReporter(w io.Writer, service, version string) *GoogleReporter {
+	return &GoogleReporter{w: w, service: service, version: version}
+}
+
+// GoogleReporter reports errors to Google Error Reporting by writing
+// them to w (usually stdout) as structured log entries, one per line,
+// in the format of a ReportedErrorEvent. On Cloud Run the entries are
+// sent to Cloud Logging, where Error Reporting picks them up, so no
+// API calls or credentials are needed.
+type GoogleReporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	service string
+	version string
+}
+
+// googleEvent is the log entry of an error for Google Error Reporting
+type googleEvent struct {
+	Severity       string             `json:"severity"`
+	Type           string             `json:"@type"`
+	EventTime      time.Time          `json:"eventTime"`
+	Message        string             `json:"message"`
+	ServiceContext googleService      `json:"serviceContext"`
+	Context        googleErrorContext `json:"context"`
+	RequestID      string             `json:"request_id,omitempty"`
+	Route          string             `json:"route,omitempty"`
+}
+
+type googleService struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
+
+type googleErrorContext struct {
+	HTTPRequest googleHTTPRequest `json:"httpRequest"`
+	User        string            `json:"user,omitempty"`
+}
+
+type googleHTTPRequest struct {
+	Method             string `json:"method"`
+	URL                string `json:"url"`
+	ResponseStatusCode int    `json:"responseStatusCode"`
+}
+
+// Report writes r as a log entry
+func (g *GoogleReporter) Report(ctx context.Context, r errs.Report) error {
+	ev := googleEvent{
+		Severity:       "ERROR",
+		Type:           reportedErrorEventType,
+		EventTime:      r.Time,
+		Message:        googleMessage(r.Err),
+		ServiceContext: googleService{Service: g.service, Version: g.version},
+		Context: googleErrorContext{
+			HTTPRequest: googleHTTPRequest{
+				Method:             r.Method,
+				URL:                r.URL,
+				ResponseStatusCode: r.Status,
+			},
+			User: r.User,
+		},
+		RequestID: r.RequestID,
+		Route:     r.Route,
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := json.NewEncoder(g.w).Encode(ev); err != nil {
+		return errs.E(errs.Internal, err)
+	}
+	return nil
+}
+
+// googleMessage returns the message of err followed by its stack
+// trace, formatted like the stack of a goroutine (see
+// runtime.Stack), which is how Error Reporting groups Go errors. An
+// error without a stack trace has the message only.
+func googleMessage(err error) string {
+	frames := stackFrames(err)
+	if len(frames) == 0 {
+		return err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+	b.WriteString("\n\ngoroutine 1 [running]:\n")
+	for _, f := range frames {
+		fmt.Fprintf(&b, "%s()\n\t%s:%d\n", f.function, f.file, f.line)
+	}
+	return b.String()
+}
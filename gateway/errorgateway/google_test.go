@@ -0,0 +1,70 @@
+package errorgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestGoogleReporter_Report(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	g := NewGoogleReporter(&buf, "go-api-basic", "v1.2.3")
+
+	r := errs.Report{
+		Err:       errs.E(errs.Database, errors.New("connection reset")),
+		Time:      time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+		Status:    http.StatusInternalServerError,
+		RequestID: "c0umr8a5ngsfpb2r0mb0",
+		User:      "otto.maddox711@gmail.com",
+		Method:    http.MethodGet,
+		Route:     "/api/v1/movies/{extlID}",
+		URL:       "/api/v1/movies/abc",
+	}
+	c.Assert(g.Report(context.Background(), r), qt.IsNil)
+
+	var got map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &got), qt.IsNil)
+	c.Assert(got["severity"], qt.Equals, "ERROR")
+	c.Assert(got["@type"], qt.Equals, reportedErrorEventType)
+	c.Assert(got["eventTime"], qt.Equals, "2021-03-01T12:00:00Z")
+	c.Assert(got["serviceContext"], qt.DeepEquals, map[string]interface{}{"service": "go-api-basic", "version": "v1.2.3"})
+	c.Assert(got["context"], qt.DeepEquals, map[string]interface{}{
+		"httpRequest": map[string]interface{}{
+			"method":             "GET",
+			"url":                "/api/v1/movies/abc",
+			"responseStatusCode": float64(500),
+		},
+		"user": "otto.maddox711@gmail.com",
+	})
+	c.Assert(got["request_id"], qt.Equals, "c0umr8a5ngsfpb2r0mb0")
+	c.Assert(got["route"], qt.Equals, "/api/v1/movies/{extlID}")
+
+	msg := got["message"].(string)
+	c.Assert(strings.HasPrefix(msg, "connection reset\n\ngoroutine 1 [running]:\n"), qt.IsTrue, qt.Commentf("message: %s", msg))
+	c.Assert(msg, qt.Contains, "errorgateway.TestGoogleReporter_Report()\n\t")
+}
+
+func TestGoogleReporter_Report_noStack(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	g := NewGoogleReporter(&buf, "go-api-basic", "")
+	c.Assert(g.Report(context.Background(), errs.Report{Err: fmt.Errorf("panic: boom")}), qt.IsNil)
+
+	// an error without a stack trace is sent as the message only
+	var got googleEvent
+	c.Assert(json.Unmarshal(buf.Bytes(), &got), qt.IsNil)
+	c.Assert(got.Message, qt.Equals, "panic: boom")
+}
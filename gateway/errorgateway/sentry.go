@@ -0,0 +1,186 @@
+package errorgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// sentryClient identifies the reporter to Sentry
+const sentryClient string = "go-api-basic/1.0"
+
+// NewSentryReporter returns a SentryReporter sending errors to the
+// Sentry project of dsn, e.g. https://<key>@o1.ingest.sentry.io/<project>.
+// If client is nil, http.DefaultClient is used.
+func NewSentryReporter(dsn string, client *http.Client, service, version string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User == nil || u.User.Username() == "" {
+		return nil, errs.E(errs.Validation, errs.Parameter("sentry-dsn"),
+			errors.New("sentry DSN must be like https://<key>@<host>/<project>"))
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, errs.E(errs.Validation, errs.Parameter("sentry-dsn"),
+			errors.New("sentry DSN has no project ID"))
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &SentryReporter{
+		client:   client,
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:i], path[i+1:]),
+		key:      u.User.Username(),
+		service:  service,
+		version:  version,
+	}, nil
+}
+
+// SentryReporter reports errors to Sentry using its HTTP API
+type SentryReporter struct {
+	client   *http.Client
+	storeURL string
+	key      string
+	service  string
+	version  string
+}
+
+// sentryEvent is the body of a request sending an error to Sentry
+type sentryEvent struct {
+	EventID    string            `json:"event_id"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Level      string            `json:"level"`
+	Platform   string            `json:"platform"`
+	ServerName string            `json:"server_name,omitempty"`
+	Release    string            `json:"release,omitempty"`
+	Exception  sentryExceptions  `json:"exception"`
+	User       *sentryUser       `json:"user,omitempty"`
+	Request    sentryRequest     `json:"request"`
+	Tags       map[string]string `json:"tags"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+}
+
+type sentryUser struct {
+	Email string `json:"email"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// Report sends r to Sentry
+func (s *SentryReporter) Report(ctx context.Context, r errs.Report) error {
+	body, err := json.Marshal(s.event(r))
+	if err != nil {
+		return errs.E(errs.Internal, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return errs.E(errs.Internal, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", sentryClient, s.key))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errs.E(errs.IO, errors.Wrap(err, "sending error to Sentry"))
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return errs.E(errs.IO, errors.Errorf("sending error to Sentry: %s", resp.Status))
+	}
+	return nil
+}
+
+// event returns the Sentry event for r
+func (s *SentryReporter) event(r errs.Report) sentryEvent {
+	ex := sentryException{
+		Type:  errorType(r.Err),
+		Value: r.Err.Error(),
+	}
+
+	// Sentry lists the frames of a stack trace oldest first
+	frames := stackFrames(r.Err)
+	if len(frames) > 0 {
+		ex.Stacktrace = &sentryStacktrace{Frames: make([]sentryFrame, len(frames))}
+		for i, f := range frames {
+			ex.Stacktrace.Frames[len(frames)-1-i] = sentryFrame{Function: f.function, AbsPath: f.file, Lineno: f.line}
+		}
+	}
+
+	ev := sentryEvent{
+		EventID:    strings.Replace(uuid.New().String(), "-", "", -1),
+		Timestamp:  r.Time.UTC(),
+		Level:      "error",
+		Platform:   "go",
+		ServerName: s.service,
+		Release:    s.version,
+		Exception:  sentryExceptions{Values: []sentryException{ex}},
+		Request:    sentryRequest{Method: r.Method, URL: r.URL},
+		Tags: map[string]string{
+			"request_id": r.RequestID,
+			"route":      r.Route,
+			"status":     strconv.Itoa(r.Status),
+		},
+	}
+	if r.User != "" {
+		ev.User = &sentryUser{Email: r.User}
+	}
+	return ev
+}
+
+// errorType returns the type of err shown by Sentry: the kind of an
+// *errs.Error, e.g. database_error, or else the Go type of the
+// innermost error it wraps
+func errorType(err error) string {
+	var e *errs.Error
+	if errors.As(err, &e) {
+		return e.Kind.String()
+	}
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
@@ -0,0 +1,128 @@
+package errorgateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewSentryReporter(t *testing.T) {
+	tests := []struct {
+		name         string
+		dsn          string
+		wantStoreURL string
+		wantKey      string
+		wantErr      bool
+	}{
+		{"typical", "https://abc@o1.ingest.sentry.io/42", "https://o1.ingest.sentry.io/api/42/store/", "abc", false},
+		{"path prefix", "http://abc@sentry.local:9000/sentry/7/", "http://sentry.local:9000/sentry/api/7/store/", "abc", false},
+		{"empty", "", "", "", true},
+		{"no key", "https://o1.ingest.sentry.io/42", "", "", true},
+		{"no project", "https://abc@o1.ingest.sentry.io/", "", "", true},
+		{"not a url", "abc@sentry", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := NewSentryReporter(tt.dsn, nil, "go-api-basic", "")
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.storeURL, qt.Equals, tt.wantStoreURL)
+			c.Assert(got.key, qt.Equals, tt.wantKey)
+		})
+	}
+}
+
+func TestSentryReporter_Report(t *testing.T) {
+	c := qt.New(t)
+
+	var (
+		gotPath string
+		gotAuth string
+		got     sentryEvent
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("X-Sentry-Auth")
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://abc@", 1) + "/42"
+	s, err := NewSentryReporter(dsn, srv.Client(), "go-api-basic", "v1.2.3")
+	c.Assert(err, qt.IsNil)
+
+	r := errs.Report{
+		Err:       errs.E(errs.Database, errors.New("connection reset")),
+		Time:      time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+		Status:    http.StatusInternalServerError,
+		RequestID: "c0umr8a5ngsfpb2r0mb0",
+		User:      "otto.maddox711@gmail.com",
+		Method:    http.MethodGet,
+		Route:     "/api/v1/movies/{extlID}",
+		URL:       "/api/v1/movies/abc",
+	}
+	c.Assert(s.Report(context.Background(), r), qt.IsNil)
+
+	c.Assert(gotPath, qt.Equals, "/api/42/store/")
+	c.Assert(gotAuth, qt.Equals, "Sentry sentry_version=7, sentry_client=go-api-basic/1.0, sentry_key=abc")
+	c.Assert(got.EventID, qt.HasLen, 32)
+	c.Assert(got.Timestamp.Equal(r.Time), qt.IsTrue)
+	c.Assert(got.ServerName, qt.Equals, "go-api-basic")
+	c.Assert(got.Release, qt.Equals, "v1.2.3")
+	c.Assert(got.User, qt.DeepEquals, &sentryUser{Email: "otto.maddox711@gmail.com"})
+	c.Assert(got.Request, qt.DeepEquals, sentryRequest{Method: "GET", URL: "/api/v1/movies/abc"})
+	c.Assert(got.Tags, qt.DeepEquals, map[string]string{
+		"request_id": "c0umr8a5ngsfpb2r0mb0",
+		"route":      "/api/v1/movies/{extlID}",
+		"status":     "500",
+	})
+
+	c.Assert(got.Exception.Values, qt.HasLen, 1)
+	ex := got.Exception.Values[0]
+	c.Assert(ex.Type, qt.Equals, "database_error")
+	c.Assert(ex.Value, qt.Equals, "connection reset")
+	// the frame where the error was created is last
+	c.Assert(ex.Stacktrace, qt.Not(qt.IsNil))
+	frames := ex.Stacktrace.Frames
+	c.Assert(frames[len(frames)-1].Function, qt.Equals, "github.com/gilcrest/go-api-basic/gateway/errorgateway.TestSentryReporter_Report")
+}
+
+func TestSentryReporter_Report_error(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://abc@", 1) + "/42"
+	s, err := NewSentryReporter(dsn, srv.Client(), "go-api-basic", "")
+	c.Assert(err, qt.IsNil)
+
+	err = s.Report(context.Background(), errs.Report{Err: errors.New("boom")})
+	c.Assert(errs.KindIs(errs.IO, err), qt.IsTrue)
+	c.Assert(err, qt.ErrorMatches, ".*429 Too Many Requests")
+}
+
+func TestErrorType(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(errorType(errs.E(errs.Database, errors.New("x"))), qt.Equals, "database_error")
+	c.Assert(errorType(errors.WithMessage(&json.SyntaxError{}, "decoding")), qt.Equals, "*json.SyntaxError")
+}
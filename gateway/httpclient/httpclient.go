@@ -0,0 +1,257 @@
+// Package httpclient provides a shared, instrumented http.Client for
+// outbound calls to external services. Requests are traced (with the
+// trace context propagated to the callee using the W3C Trace Context
+// headers), retried with exponential backoff on transport errors and
+// 5xx responses and guarded by a per host circuit breaker.
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+)
+
+// ErrCircuitOpen is returned when a request is not sent as the
+// circuit breaker for the host is open
+var ErrCircuitOpen = errors.New("httpclient: circuit breaker is open")
+
+// Config holds the options for a Client
+type Config struct {
+	// Timeout is the overall time limit for a request, including
+	// all retries
+	Timeout time.Duration
+	// MaxRetries is the maximum number of times a request is
+	// retried. Zero disables retries.
+	MaxRetries int
+	// MinBackoff is the wait before the first retry. The wait is
+	// doubled for each subsequent retry (with jitter).
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum wait between retries
+	MaxBackoff time.Duration
+	// BreakerThreshold is the number of consecutive failures to a
+	// host before the circuit breaker opens. Zero disables the
+	// circuit breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit breaker stays open
+	// before a trial request is allowed through
+	BreakerCooldown time.Duration
+}
+
+// DefaultConfig returns the Config used for outbound calls unless
+// otherwise specified
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          10 * time.Second,
+		MaxRetries:       2,
+		MinBackoff:       100 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// New returns an http.Client configured with cfg which should be
+// used for all outbound calls instead of http.DefaultClient
+func New(cfg Config) *http.Client {
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &ochttp.Transport{
+			Base:        NewTransport(http.DefaultTransport, cfg),
+			Propagation: &tracecontext.HTTPFormat{},
+		},
+	}
+}
+
+// NewTransport wraps base with retries and a circuit breaker as
+// configured by cfg
+func NewTransport(base http.RoundTripper, cfg Config) http.RoundTripper {
+	return &transport{
+		base:     base,
+		cfg:      cfg,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// transport is an http.RoundTripper which retries failed requests
+// and tracks failures by host
+type transport struct {
+	base     http.RoundTripper
+	cfg      Config
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// RoundTrip sends the request, retrying idempotent requests on
+// transport errors and 5xx responses
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	lgr := zerolog.Ctx(req.Context())
+	b := t.breaker(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		if !b.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		r := req
+		if attempt > 0 {
+			var err error
+			r, err = rewind(req)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		b.record(!failed)
+
+		if !failed || attempt >= t.cfg.MaxRetries || !retryable(req) {
+			return resp, err
+		}
+
+		// drain and close the body of the failed response so the
+		// connection can be reused
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait := backoff(t.cfg.MinBackoff, t.cfg.MaxBackoff, attempt)
+		lgr.Debug().
+			Str("host", req.URL.Host).
+			Str("path", req.URL.Path).
+			Int("attempt", attempt+1).
+			Dur("backoff", wait).
+			Msg("retrying outbound request")
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// breaker returns the circuit breaker for host
+func (t *transport) breaker(host string) *breaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{threshold: t.cfg.BreakerThreshold, cooldown: t.cfg.BreakerCooldown, now: time.Now}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+// retryable reports whether the request can be safely sent again.
+// Only idempotent methods are retried and the body must be able to
+// be re-read.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req with a fresh body for a retry
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// backoff returns the wait before the given retry attempt using
+// exponential backoff with full jitter
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	d := min << uint(attempt)
+	if d > max || d <= 0 {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d))) + 1
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// breaker is a circuit breaker which opens after threshold
+// consecutive failures. Once cooldown has passed, a single trial
+// request is allowed through. If it succeeds the breaker closes,
+// otherwise it stays open for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+// allow reports whether a request may be sent
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.now().Sub(b.openedAt) >= b.cooldown {
+		// allow a single trial request through, any others wait
+		// for another cooldown
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// record records the outcome of a request
+func (b *breaker) record(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
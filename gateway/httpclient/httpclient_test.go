@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+)
+
+// testConfig returns a Config with short backoffs for testing
+func testConfig() Config {
+	return Config{
+		Timeout:          5 * time.Second,
+		MaxRetries:       2,
+		MinBackoff:       time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		BreakerThreshold: 0,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+// newStatusServer returns a test server which responds with the
+// given status codes in order, repeating the last one, and a
+// pointer to the number of requests received
+func newStatusServer(t *testing.T, codes ...int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(codes) {
+			n = len(codes)
+		}
+		w.WriteHeader(codes[n-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestClient_Retry(t *testing.T) {
+	t.Run("retries 5xx", func(t *testing.T) {
+		c := qt.New(t)
+
+		srv, calls := newStatusServer(t, http.StatusServiceUnavailable, http.StatusOK)
+
+		resp, err := New(testConfig()).Get(srv.URL)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusOK)
+		c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(2))
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		c := qt.New(t)
+
+		srv, calls := newStatusServer(t, http.StatusInternalServerError)
+
+		resp, err := New(testConfig()).Get(srv.URL)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusInternalServerError)
+		c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(3))
+	})
+
+	t.Run("no retry on 4xx", func(t *testing.T) {
+		c := qt.New(t)
+
+		srv, calls := newStatusServer(t, http.StatusNotFound)
+
+		resp, err := New(testConfig()).Get(srv.URL)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(1))
+	})
+
+	t.Run("no retry on POST", func(t *testing.T) {
+		c := qt.New(t)
+
+		srv, calls := newStatusServer(t, http.StatusInternalServerError, http.StatusOK)
+
+		resp, err := New(testConfig()).Post(srv.URL, "application/json", strings.NewReader("{}"))
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, qt.Equals, http.StatusInternalServerError)
+		c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(1))
+	})
+
+	t.Run("PUT body is resent", func(t *testing.T) {
+		c := qt.New(t)
+
+		var bodies []string
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"a":1}`))
+		c.Assert(err, qt.IsNil)
+		resp, err := New(testConfig()).Do(req)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+		c.Assert(bodies, qt.DeepEquals, []string{`{"a":1}`, `{"a":1}`})
+	})
+}
+
+func TestClient_Tracecontext(t *testing.T) {
+	c := qt.New(t)
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := New(testConfig()).Get(srv.URL)
+	c.Assert(err, qt.IsNil)
+	resp.Body.Close()
+	c.Assert(traceparent, qt.Not(qt.Equals), "")
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	c := qt.New(t)
+
+	srv, calls := newStatusServer(t, http.StatusInternalServerError)
+
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 2
+	client := New(cfg)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+	}
+
+	// the breaker is now open, the request should not be sent
+	_, err := client.Get(srv.URL)
+	c.Assert(errors.Is(err, ErrCircuitOpen), qt.IsTrue)
+	c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(2))
+}
+
+func Test_breaker(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &breaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}
+
+	b.record(false)
+	c.Assert(b.allow(), qt.IsTrue)
+	b.record(false)
+	c.Assert(b.allow(), qt.IsFalse)
+
+	// after the cooldown a single trial is let through
+	now = now.Add(time.Minute)
+	c.Assert(b.allow(), qt.IsTrue)
+	c.Assert(b.allow(), qt.IsFalse)
+
+	// a successful trial closes the breaker
+	b.record(true)
+	c.Assert(b.allow(), qt.IsTrue)
+}
+
+func Test_backoff(t *testing.T) {
+	c := qt.New(t)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		d := backoff(10*time.Millisecond, 50*time.Millisecond, attempt)
+		c.Assert(d > 0, qt.IsTrue)
+		c.Assert(d <= 50*time.Millisecond, qt.IsTrue)
+	}
+}
+
+func Test_sleep(t *testing.T) {
+	c := qt.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c.Assert(sleep(ctx, time.Hour), qt.Equals, context.Canceled)
+}
@@ -0,0 +1,322 @@
+// Package redisgateway stores cached values in Redis. It speaks the
+// Redis protocol (RESP) itself over a small pool of connections, for
+// the few commands it needs: GET, SET, SCAN and UNLINK.
+package redisgateway
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// defaultTimeout is how long a command may take, including dialing a
+// connection, if the context has no earlier deadline
+const defaultTimeout = time.Second
+
+// maxIdleConns is the number of idle connections kept for reuse
+const maxIdleConns int = 8
+
+// scanCount is the number of keys SCAN is asked to look at per call
+const scanCount int = 500
+
+// Config configures the connection to Redis
+type Config struct {
+	// URL is the URL of the Redis server, e.g.
+	// redis://:password@localhost:6379/0, with the password and
+	// database number optional. rediss:// connects using TLS, which
+	// is not supported.
+	URL string
+}
+
+// NewStore is an initializer for Store. The server is pinged, so an
+// error is returned if it cannot be reached. The returned function
+// closes the connections of the Store.
+func NewStore(ctx context.Context, cfg Config) (*Store, func(), error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, func() {}, errs.E(errs.Validation, errs.Parameter("redis-url"), errors.New("redis url must be redis://[:password@]host:port[/db]"))
+	}
+
+	s := &Store{
+		addr: u.Host,
+		idle: make(chan *conn, maxIdleConns),
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, func() {}, errs.E(errs.Validation, errs.Parameter("redis-url"), errors.Errorf("invalid redis database %q", db))
+		}
+	}
+
+	if _, err = s.do(ctx, "PING"); err != nil {
+		return nil, func() {}, err
+	}
+
+	return s, s.close, nil
+}
+
+// Store stores values in Redis. It satisfies the handler.ResponseStore
+// interface and is safe for concurrent use.
+type Store struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *conn
+}
+
+// Get returns the value stored for key
+func (s *Store) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := s.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	b, ok := v.([]byte)
+	return b, ok, nil
+}
+
+// Set stores value for key, expiring after ttl (to the millisecond)
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := int64(ttl / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	_, err := s.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// DeletePrefix deletes the values of every key starting with prefix.
+// The keys are found using SCAN, which does not block the server as
+// KEYS does, and are deleted using UNLINK, which frees their memory
+// in the background.
+func (s *Store) DeletePrefix(ctx context.Context, prefix string) error {
+	pattern := escapeGlob(prefix) + "*"
+	cursor := "0"
+	for {
+		v, err := s.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(scanCount))
+		if err != nil {
+			return err
+		}
+		reply, ok := v.([]interface{})
+		if !ok || len(reply) != 2 {
+			return errs.E(errs.Internal, errors.New("unexpected redis SCAN reply"))
+		}
+		next, _ := reply[0].([]byte)
+		keys, _ := reply[1].([]interface{})
+
+		if len(keys) > 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "UNLINK")
+			for _, k := range keys {
+				if b, ok := k.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			if _, err = s.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// escapeGlob escapes the characters of s which have a meaning in a
+// Redis glob-style pattern
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\', '^':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// do sends the command args to the server using a pooled connection
+// and returns its reply: nil, a []byte, an int64 or a []interface{}
+// of those. An error reply is returned as an error.
+func (s *Store) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if d := time.Now().Add(defaultTimeout); !ok || d.Before(deadline) {
+		deadline = d
+	}
+
+	c, err := s.get(ctx, deadline)
+	if err != nil {
+		return nil, errs.E(errs.Internal, errors.Wrap(err, "redis connection"))
+	}
+
+	v, err := c.do(deadline, args...)
+	if err != nil {
+		var re redisError
+		if errors.As(err, &re) {
+			// the connection is still usable after an error reply
+			s.put(c)
+			return nil, errs.E(errs.Internal, err)
+		}
+		_ = c.Close()
+		return nil, errs.E(errs.Internal, errors.Wrapf(err, "redis %s", args[0]))
+	}
+	s.put(c)
+
+	return v, nil
+}
+
+// get returns an idle connection, or else dials a new one,
+// authenticated and with the database selected
+func (s *Store) get(ctx context.Context, deadline time.Time) (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+
+	d := net.Dialer{Deadline: deadline}
+	nc, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	if s.password != "" {
+		if _, err = c.do(deadline, "AUTH", s.password); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err = c.do(deadline, "SELECT", strconv.Itoa(s.db)); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// put returns c to the pool, or closes it if the pool is full
+func (s *Store) put(c *conn) {
+	select {
+	case s.idle <- c:
+	default:
+		_ = c.Close()
+	}
+}
+
+// close closes the idle connections
+func (s *Store) close() {
+	for {
+		select {
+		case c := <-s.idle:
+			_ = c.Close()
+		default:
+			return
+		}
+	}
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// conn is a connection to the server
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do sends the command args and reads its reply, see Store.do
+func (c *conn) do(deadline time.Time, args ...string) (interface{}, error) {
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// a command is sent as an array of bulk strings
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n")
+		c.w.WriteString(a)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return c.read()
+}
+
+// read reads a reply
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("invalid reply")
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return []byte(rest), nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = c.read(); err != nil {
+				// the rest of the array is unread, so the connection
+				// cannot be reused even after an error reply
+				return nil, errors.Errorf("reading array: %v", err)
+			}
+		}
+		return a, nil
+	}
+
+	return nil, errors.Errorf("invalid reply type %q", kind)
+}
@@ -0,0 +1,216 @@
+package redisgateway
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// fakeServer is a Redis server holding values in memory, speaking
+// enough of the protocol for Store. SCAN returns every key in one
+// call and MATCH only supports a trailing *.
+type fakeServer struct {
+	mu       sync.Mutex
+	values   map[string]string
+	password string
+	commands []string
+}
+
+// newFakeServer starts a fakeServer on a local port and returns it
+// and its address
+func newFakeServer(t *testing.T, password string) (*fakeServer, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	fs := &fakeServer{values: make(map[string]string), password: password}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go fs.serve(nc)
+		}
+	}()
+
+	return fs, l.Addr().String()
+}
+
+func (fs *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	authed := fs.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+
+		fs.mu.Lock()
+		fs.commands = append(fs.commands, cmd)
+		var reply string
+		switch {
+		case cmd == "AUTH":
+			authed = args[1] == fs.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			reply = "+PONG\r\n"
+		case cmd == "SELECT":
+			reply = "+OK\r\n"
+		case cmd == "GET":
+			v, ok := fs.values[args[1]]
+			reply = "$-1\r\n"
+			if ok {
+				reply = bulk(v)
+			}
+		case cmd == "SET":
+			fs.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case cmd == "SCAN":
+			prefix := strings.TrimSuffix(strings.ReplaceAll(args[3], `\`, ""), "*")
+			var keys []string
+			for k := range fs.values {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, bulk(k))
+				}
+			}
+			reply = "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(keys)) + "\r\n" + strings.Join(keys, "")
+		case cmd == "UNLINK":
+			for _, k := range args[1:] {
+				delete(fs.values, k)
+			}
+			reply = ":" + strconv.Itoa(len(args)-1) + "\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		fs.mu.Unlock()
+
+		if _, err = io.WriteString(nc, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestNewStore(t *testing.T) {
+	_, addr := newFakeServer(t, "secret")
+
+	tests := []struct {
+		name     string
+		url      string
+		wantErr  bool
+		wantKind errs.Kind
+	}{
+		{"valid", "redis://:secret@" + addr + "/1", false, 0},
+		{"wrong password", "redis://:wrong@" + addr, true, errs.Internal},
+		{"no password", "redis://" + addr, true, errs.Internal},
+		{"scheme", "http://" + addr, true, errs.Validation},
+		{"tls", "rediss://" + addr, true, errs.Validation},
+		{"database", "redis://" + addr + "/zero", true, errs.Validation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			s, cleanup, err := NewStore(context.Background(), Config{URL: tt.url})
+			defer cleanup()
+			if tt.wantErr {
+				c.Assert(errs.KindIs(tt.wantKind, err), qt.IsTrue, qt.Commentf("error = %v", err))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(s.db, qt.Equals, 1)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	c := qt.New(t)
+
+	fs, addr := newFakeServer(t, "")
+	ctx := context.Background()
+	s, cleanup, err := NewStore(ctx, Config{URL: "redis://" + addr})
+	c.Assert(err, qt.IsNil)
+	defer cleanup()
+
+	_, ok, err := s.Get(ctx, "resp:movies:a")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	c.Assert(s.Set(ctx, "resp:movies:a", []byte(`{"a":1}`), time.Minute), qt.IsNil)
+	c.Assert(s.Set(ctx, "resp:movies:b", []byte("\r\n"), time.Minute), qt.IsNil)
+	c.Assert(s.Set(ctx, "resp:people:c", []byte("c"), time.Minute), qt.IsNil)
+
+	got, ok, err := s.Get(ctx, "resp:movies:b")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(string(got), qt.Equals, "\r\n")
+
+	c.Assert(s.DeletePrefix(ctx, "resp:movies:"), qt.IsNil)
+	fs.mu.Lock()
+	c.Assert(fs.values, qt.DeepEquals, map[string]string{"resp:people:c": "c"})
+	fs.mu.Unlock()
+
+	// an error reply is returned, and the connection is reused
+	_, err = s.do(ctx, "FLUSHALL")
+	c.Assert(err, qt.ErrorMatches, "redis: ERR unknown command")
+	_, _, err = s.Get(ctx, "resp:people:c")
+	c.Assert(err, qt.IsNil)
+}
+
+func Test_escapeGlob(t *testing.T) {
+	qt.Assert(t, escapeGlob(`resp:[a]*?\^`), qt.Equals, `resp:\[a\]\*\?\\\^`)
+}
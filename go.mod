@@ -1,12 +1,11 @@
 module github.com/gilcrest/go-api-basic
 
-go 1.13
+go 1.16
 
 require (
 	github.com/frankban/quicktest v1.11.3
-	github.com/golang/protobuf v1.5.1 // indirect
 	github.com/google/go-cmp v0.5.5
-	github.com/google/uuid v1.2.0
+	github.com/google/uuid v1.3.0
 	github.com/google/wire v0.5.0
 	github.com/gorilla/mux v1.8.0
 	github.com/justinas/alice v1.2.0
@@ -14,11 +13,11 @@ require (
 	github.com/peterbourgon/ff/v3 v3.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/rs/zerolog v1.20.0
+	github.com/testcontainers/testcontainers-go v0.13.0
 	go.opencensus.io v0.23.0
 	gocloud.dev v0.22.0
-	golang.org/x/net v0.0.0-20210316092652-d523dce5a7f4 // indirect
+	golang.org/x/net v0.0.0-20211108170745-6635138e15ea
 	golang.org/x/oauth2 v0.0.0-20210313182246-cd4f82c27b84
-	golang.org/x/sys v0.0.0-20210317225723-c4fcb01b228e // indirect
 	google.golang.org/api v0.42.0
 	google.golang.org/genproto v0.0.0-20210318145829-90b20ab00860 // indirect
 )
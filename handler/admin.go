@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// AdminRouter is the Handler of the admin server, which serves the
+// operational endpoints (metrics, profiling, configuration, log
+// level and resync) on a port separate from the API
+type AdminRouter http.Handler
+
+// AdminHandlers are the handlers of the admin server shared with
+// the API
+type AdminHandlers struct {
+	PingHandler    PingHandler
+	MetricsHandler MetricsHandler
+}
+
+// AdminServed is whether the admin server is served. When it is,
+// ping and metrics are only served by the admin server, so they are
+// not reachable without authentication on the API.
+type AdminServed bool
+
+// Settings are the configuration settings of the server by name,
+// with any secret values redacted, as served by the admin server
+type Settings map[string]string
+
+// NewAdminRouter returns the AdminRouter with the routes of the admin
+// server. The admin server has no authentication, so it must only
+// be reachable by operators (it listens on localhost by default).
+// Its requests are logged, but not audited, metered or counted
+// against a quota, so scraping metrics or profiling does not skew
+// the API metrics. POST /resync runs the resync steps.
+func NewAdminRouter(logger zerolog.Logger, handlers AdminHandlers, settings Settings, resync ResyncSteps) AdminRouter {
+	rtr := mux.NewRouter()
+
+	c := LoggerHandlerChain(logger, alice.New()).
+		Append(RecoveryHandler)
+
+	rtr.Handle("/ping", c.Then(handlers.PingHandler)).
+		Methods(http.MethodGet)
+
+	rtr.Handle("/metrics", c.Then(handlers.MetricsHandler)).
+		Methods(http.MethodGet)
+
+	rtr.Handle("/config", c.Append(JSONContentTypeHandler).
+		ThenFunc(configHandler(settings))).
+		Methods(http.MethodGet)
+
+	rtr.Handle("/loglevel", c.Append(JSONContentTypeHandler).
+		ThenFunc(findLogLevel)).
+		Methods(http.MethodGet)
+
+	rtr.Handle("/loglevel", c.Append(JSONContentTypeHandler).
+		ThenFunc(updateLogLevel)).
+		Methods(http.MethodPut)
+
+	rtr.Handle("/resync", c.Append(JSONContentTypeHandler).
+		ThenFunc(resyncHandler(resync))).
+		Methods(http.MethodPost)
+
+	// the profiles are served explicitly, as importing pprof only
+	// registers them with http.DefaultServeMux
+	rtr.Handle("/debug/pprof/cmdline", c.ThenFunc(pprof.Cmdline))
+	rtr.Handle("/debug/pprof/profile", c.ThenFunc(pprof.Profile))
+	rtr.Handle("/debug/pprof/symbol", c.ThenFunc(pprof.Symbol))
+	rtr.Handle("/debug/pprof/trace", c.ThenFunc(pprof.Trace))
+	rtr.PathPrefix("/debug/pprof/").Handler(c.ThenFunc(pprof.Index))
+
+	return rtr
+}
+
+// configHandler returns a handler for GET requests for the /config
+// endpoint, responding with settings
+func configHandler(settings Settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := *hlog.FromRequest(r)
+
+		response, err := NewStandardResponse(r, settings)
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, err)
+			return
+		}
+
+		err = writeJSON(w, response)
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+			return
+		}
+	}
+}
+
+// logLevels are the log levels which can be set, by name
+var logLevels = map[string]zerolog.Level{
+	"trace":    zerolog.TraceLevel,
+	"debug":    zerolog.DebugLevel,
+	"info":     zerolog.InfoLevel,
+	"warn":     zerolog.WarnLevel,
+	"error":    zerolog.ErrorLevel,
+	"fatal":    zerolog.FatalLevel,
+	"panic":    zerolog.PanicLevel,
+	"disabled": zerolog.Disabled,
+}
+
+// logLevelName returns the name of lvl in logLevels
+func logLevelName(lvl zerolog.Level) string {
+	for name, l := range logLevels {
+		if l == lvl {
+			return name
+		}
+	}
+	return lvl.String()
+}
+
+// findLogLevel handles GET requests for the /loglevel endpoint,
+// responding with the global log level
+func findLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeLogLevel(w, r)
+}
+
+// updateLogLevel handles PUT requests for the /loglevel endpoint,
+// setting the global log level until the server is restarted or the
+// log level is reloaded from the config file (on SIGHUP)
+func updateLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	var rb dto.LogLevel
+	err := decodeJSON(r.Body, &rb, DecodeOptions{Strict: true})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	lvl, ok := logLevels[rb.Level]
+	if !ok {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("level"),
+			errors.Errorf("level must be one of trace, debug, info, warn, error, fatal, panic or disabled, not %q", rb.Level)))
+		return
+	}
+
+	prev := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(lvl)
+	logger.Info().Msgf("logging level changed from %s to %s", logLevelName(prev), logLevelName(lvl))
+
+	writeLogLevel(w, r)
+}
+
+// writeLogLevel responds with the global log level
+func writeLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	response, err := NewStandardResponse(r, dto.LogLevel{Level: logLevelName(zerolog.GlobalLevel())})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
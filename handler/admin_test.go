@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func TestNewAdminRouter(t *testing.T) {
+	lvl := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(lvl) })
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	ok := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		}
+	}
+	rtr := NewAdminRouter(zerolog.Nop(),
+		AdminHandlers{PingHandler: ok("ping"), MetricsHandler: ok("metrics")},
+		Settings{"port": "8080", "db-password": "[REDACTED]"},
+		ResyncSteps{{Name: "cache", Run: func(ctx context.Context) error { return nil }}})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantLevel  zerolog.Level
+	}{
+		{"ping", http.MethodGet, "/ping", "", http.StatusOK, "ping", zerolog.InfoLevel},
+		{"metrics", http.MethodGet, "/metrics", "", http.StatusOK, "metrics", zerolog.InfoLevel},
+		{"config", http.MethodGet, "/config", "", http.StatusOK, `"db-password":"[REDACTED]"`, zerolog.InfoLevel},
+		{"pprof", http.MethodGet, "/debug/pprof/", "", http.StatusOK, "goroutine", zerolog.InfoLevel},
+		{"find log level", http.MethodGet, "/loglevel", "", http.StatusOK, `"level":"info"`, zerolog.InfoLevel},
+		{"update log level", http.MethodPut, "/loglevel", `{"level": "debug"}`, http.StatusOK, `"level":"debug"`, zerolog.DebugLevel},
+		{"disable logging", http.MethodPut, "/loglevel", `{"level": "disabled"}`, http.StatusOK, `"level":"disabled"`, zerolog.Disabled},
+		{"unknown log level", http.MethodPut, "/loglevel", `{"level": "verbose"}`, http.StatusBadRequest, "level must be one of", zerolog.InfoLevel},
+		{"unknown field", http.MethodPut, "/loglevel", `{"lvl": "debug"}`, http.StatusBadRequest, "", zerolog.InfoLevel},
+		{"resync", http.MethodPost, "/resync", "", http.StatusOK, `"step":"cache","status":"complete"`, zerolog.InfoLevel},
+		// the API routes are not served
+		{"api", http.MethodGet, pathPrefix + moviesV1PathRoot, "", http.StatusNotFound, "", zerolog.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			rtr.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus, qt.Commentf("body: %s", rr.Body))
+			c.Assert(rr.Body.String(), qt.Contains, tt.wantBody)
+			c.Assert(zerolog.GlobalLevel(), qt.Equals, tt.wantLevel)
+		})
+	}
+}
+
+func Test_updateLogLevel_response(t *testing.T) {
+	c := qt.New(t)
+	lvl := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(lvl) })
+
+	rtr := NewAdminRouter(zerolog.Nop(), AdminHandlers{}, nil, nil)
+	req := httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level": "warn"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	rtr.ServeHTTP(rr, req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/json")
+
+	var got struct {
+		Data dto.LogLevel `json:"data"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&got), qt.IsNil)
+	c.Assert(got.Data, qt.Equals, dto.LogLevel{Level: "warn"})
+}
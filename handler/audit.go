@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// AuditHandler returns middleware which writes an audit.Event of
+// each request using aw, with the action, outcome, status, latency,
+// request ID, user agent and client version. It must be added after LoggerHandlerChain so the
+// request ID is set. The actor is set by AuthMiddleware using
+// audit.SetActor once the user is authenticated and the resource and
+// its change are set by the service handling the request. The audit
+// category and, if the service has not set one, the resource type
+// are taken from the route's RouteMeta. A dry run (see
+// DryRunHandler) changes nothing, so it is not audited. The path and
+// resource ID are decoded from the URL, so any invalid UTF-8 in them
+// is replaced, as the database rejects it.
+func AuditHandler(aw audit.Writer) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if requestinfo.DryRun(r.Context()) {
+					h.ServeHTTP(w, r)
+					return
+				}
+
+				start := time.Now()
+
+				ctx := audit.NewContext(r.Context())
+				sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+				// the record is written in a deferred function so a
+				// request which panics is still audited. The panic is
+				// re-raised for RecoveryHandler, which sends a 500
+				defer func() {
+					p := recover()
+					if p != nil && !sr.wroteHeader {
+						sr.status = http.StatusInternalServerError
+					}
+
+					requestID, _ := requestinfo.RequestIDFromContext(ctx)
+
+					meta := routeMeta(ctx)
+
+					e := audit.EventFromContext(ctx)
+					e.RequestID = requestID
+					e.Timestamp = start
+					e.Action = audit.ActionFor(r.Method)
+					e.Category = meta.AuditCategory
+					if e.Resource.Type == "" {
+						e.Resource.Type = meta.Resource
+					}
+					e.Resource.ID = strings.ToValidUTF8(e.Resource.ID, "\uFFFD")
+					e.Resource.Path = strings.ToValidUTF8(r.URL.Path, "\uFFFD")
+					e.Outcome = audit.OutcomeFor(sr.status)
+					e.Method = r.Method
+					e.Status = sr.status
+					e.Latency = time.Since(start)
+					c := requestClient(r)
+					e.UserAgent = c.UserAgent
+					e.ClientVersion = c.Version
+					aw.Write(e)
+
+					if p != nil {
+						panic(p)
+					}
+				}()
+
+				h.ServeHTTP(sr, r.WithContext(ctx)) // call original
+			})
+	}
+}
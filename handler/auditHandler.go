@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// maxAuditLimit is the maximum number of audit records which can be
+// requested at once
+const maxAuditLimit = 1000
+
+// FindAuditRecordsHandler is a Handler that returns API audit records
+type FindAuditRecordsHandler http.Handler
+
+// ProvideFindAuditRecordsHandler is a provider for the
+// FindAuditRecordsHandler for wire
+func ProvideFindAuditRecordsHandler(h DefaultAuditHandler) FindAuditRecordsHandler {
+	return http.HandlerFunc(h.FindAuditRecords)
+}
+
+// DefaultAuditHandler handles requests for API audit records
+type DefaultAuditHandler struct {
+	Store audit.Store
+}
+
+// FindAuditRecords handles GET requests for the /admin/audit
+// endpoint and returns audit records, most recent first. Records
+// can be filtered using the user, method, path (prefix), since and
+// until (RFC 3339) query parameters. The number of records returned
+// is set using the limit query parameter.
+func (h DefaultAuditHandler) FindAuditRecords(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	f, err := newAuditFilter(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	records, err := h.Store.Find(ctx, f)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	response, err := NewStandardResponse(r, dto.NewAuditRecordResponses(records))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// newAuditFilter parses the audit.Filter from the query parameters
+func newAuditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+
+	f := audit.Filter{
+		Username:   q.Get("user"),
+		Method:     q.Get("method"),
+		PathPrefix: q.Get("path"),
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"since", &f.Since},
+		{"until", &f.Until},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return audit.Filter{}, errs.E(errs.Validation, errs.Parameter(p.name), errors.Errorf("%s must be an RFC 3339 timestamp", p.name))
+		}
+		*p.t = t
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return audit.Filter{}, errs.E(errs.Validation, errs.Parameter("limit"), errors.Errorf("limit must be a number between 1 and %d", maxAuditLimit))
+		}
+		f.Limit = limit
+	}
+
+	return f, nil
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func TestDefaultAuditHandler_FindAuditRecords(t *testing.T) {
+	t.Run("mock DB", func(t *testing.T) {
+		// initialize quickest checker
+		c := qt.New(t)
+
+		// initialize a zerolog Logger
+		lgr := logger.NewLogger(os.Stdout, true)
+
+		// initialize DefaultAuditHandler
+		dah := DefaultAuditHandler{
+			Store: audittest.NewMockStore(t),
+		}
+
+		// setup path
+		path := pathPrefix + adminV1PathRoot + "/audit"
+
+		// form request using httptest
+		req := httptest.NewRequest(http.MethodGet, path+"?method=DELETE&limit=10", nil)
+
+		// add test access token
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+		// create middleware to extract the request ID from
+		// the request context for testing comparison
+		var requestID string
+		requestIDMiddleware := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rID, ok := hlog.IDFromRequest(r)
+				if !ok {
+					t.Fatal("Request ID not set to request context")
+				}
+				requestID = rID.String()
+
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		// initialize ResponseRecorder to use with ServeHTTP as it
+		// satisfies ResponseWriter interface and records the response
+		// for testing
+		rr := httptest.NewRecorder()
+
+		// setup full handler chain needed for request
+		h := LoggerHandlerChain(lgr, alice.New()).
+			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeAdminRead)).
+			Append(JSONContentTypeHandler).
+			Append(requestIDMiddleware).
+			Then(ProvideFindAuditRecordsHandler(dah))
+
+		// call the handler ServeHTTP method to execute the request
+		// and record the response
+		h.ServeHTTP(rr, req)
+
+		// Assert that Response Status Code equals 200 (StatusOK)
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		// standardResponse decodes the response with Data as the dto type
+		type standardResponse struct {
+			Path      string                    `json:"path"`
+			RequestID string                    `json:"request_id"`
+			Data      []dto.AuditRecordResponse `json:"data"`
+		}
+
+		// setup the expected response data from the mocked records
+		var arr []dto.AuditRecordResponse
+		for _, ar := range audittest.NewRecords(t) {
+			arr = append(arr, dto.AuditRecordResponse{
+				RequestID: ar.RequestID,
+				Method:    ar.Method,
+				Path:      ar.Path,
+				Username:  ar.Username,
+				Status:    ar.Status,
+				LatencyMS: float64(ar.Latency) / float64(time.Millisecond),
+				Timestamp: ar.Timestamp.Format(time.RFC3339Nano),
+			})
+		}
+		wantBody := standardResponse{
+			Path:      path,
+			RequestID: requestID,
+			Data:      arr,
+		}
+
+		// decode the response body into the standardResponse (gotBody)
+		gotBody := standardResponse{}
+		err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+		defer rr.Result().Body.Close()
+
+		// Assert that there is no error after decoding the response body
+		c.Assert(err, qt.IsNil)
+
+		// Assert that the response body (gotBody) is as expected (wantBody).
+		c.Assert(gotBody, qt.DeepEquals, wantBody)
+	})
+}
+
+func Test_newAuditFilter(t *testing.T) {
+	since := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		want      audit.Filter
+		wantParam errs.Parameter
+	}{
+		{"empty", "", audit.Filter{}, ""},
+		{"all", "?user=otto.maddox711@gmail.com&method=POST&path=/api/v1/movies&since=2021-01-02T03:04:05Z&limit=5",
+			audit.Filter{Username: "otto.maddox711@gmail.com", Method: "POST", PathPrefix: "/api/v1/movies", Since: since, Limit: 5}, ""},
+		{"bad since", "?since=yesterday", audit.Filter{}, "since"},
+		{"bad until", "?until=2021-01-02", audit.Filter{}, "until"},
+		{"limit too large", "?limit=1001", audit.Filter{}, "limit"},
+		{"limit zero", "?limit=0", audit.Filter{}, "limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit"+tt.query, nil)
+
+			got, err := newAuditFilter(req)
+			c.Assert(got, qt.DeepEquals, tt.want)
+			if tt.wantParam == "" {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, tt.wantParam)
+		})
+	}
+}
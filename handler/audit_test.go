@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"unicode/utf8"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestAuditHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		// status is written by the handler, 0 writes nothing
+		// and -1 panics
+		status      int
+		wantStatus  int
+		wantOutcome audit.Outcome
+	}{
+		{"implicit 200", "otto.maddox711@gmail.com", 0, http.StatusOK, audit.OutcomeSuccess},
+		{"error status", "otto.maddox711@gmail.com", http.StatusNotFound, http.StatusNotFound, audit.OutcomeFailure},
+		{"unauthenticated", "", http.StatusUnauthorized, http.StatusUnauthorized, audit.OutcomeDenied},
+		{"panic", "otto.maddox711@gmail.com", -1, http.StatusInternalServerError, audit.OutcomeFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			aw := audittest.NewMockWriter(t)
+
+			var requestID string
+			h := LoggerHandlerChain(lgr, alice.New()).
+				Append(RecoveryHandler).
+				Append(AuditHandler(aw)).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					id, _ := hlog.IDFromRequest(r)
+					requestID = id.String()
+					if tt.username != "" {
+						audit.SetActor(r.Context(), audit.Actor{Username: tt.username})
+						audit.SetResource(r.Context(), "movies", "abc")
+					}
+					switch {
+					case tt.status < 0:
+						panic("handler panic")
+					case tt.status > 0:
+						w.WriteHeader(tt.status)
+					}
+				})
+
+			path := pathPrefix + moviesV1PathRoot + "/abc"
+			req := httptest.NewRequest(http.MethodDelete, path, nil)
+			req.Header.Set("User-Agent", "movie-app/2.3.1 (iOS)")
+			req.Header.Set(ClientVersionHeader, "ios/2.3.1")
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+
+			events := aw.Events()
+			c.Assert(events, qt.HasLen, 1)
+			got := events[0]
+			c.Assert(got.SchemaVersion, qt.Equals, audit.SchemaVersion)
+			c.Assert(got.RequestID, qt.Equals, requestID)
+			c.Assert(got.Method, qt.Equals, http.MethodDelete)
+			c.Assert(got.Action, qt.Equals, "delete")
+			c.Assert(got.Resource.Path, qt.Equals, path)
+			c.Assert(got.Actor.Username, qt.Equals, tt.username)
+			c.Assert(got.Status, qt.Equals, tt.wantStatus)
+			c.Assert(got.Outcome, qt.Equals, tt.wantOutcome)
+			c.Assert(got.Latency > 0, qt.IsTrue)
+			c.Assert(got.Timestamp.IsZero(), qt.IsFalse)
+			c.Assert(got.UserAgent, qt.Equals, "movie-app/2.3.1 (iOS)")
+			c.Assert(got.ClientVersion, qt.Equals, "ios/2.3.1")
+		})
+	}
+}
+
+// a path which is not valid UTF-8 once decoded is audited with the
+// invalid bytes replaced, so the event can be stored
+func TestAuditHandler_invalidPath(t *testing.T) {
+	c := qt.New(t)
+
+	aw := audittest.NewMockWriter(t)
+	h := LoggerHandlerChain(logger.NewLogger(os.Stdout, true), alice.New()).
+		Append(AuditHandler(aw)).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit.SetResource(r.Context(), "movies", "\xff\xfe")
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+	req := httptest.NewRequest(http.MethodDelete, pathPrefix+moviesV1PathRoot+"/%ff%fe", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	events := aw.Events()
+	c.Assert(events, qt.HasLen, 1)
+	c.Assert(events[0].Resource.Path, qt.Equals, pathPrefix+moviesV1PathRoot+"/\uFFFD")
+	c.Assert(events[0].Resource.ID, qt.Equals, "\uFFFD")
+}
+
+// a User-Agent which is not valid UTF-8 is audited with the invalid
+// bytes replaced, so the event can be stored
+func TestAuditHandler_invalidUserAgent(t *testing.T) {
+	c := qt.New(t)
+
+	aw := audittest.NewMockWriter(t)
+	h := LoggerHandlerChain(logger.NewLogger(os.Stdout, true), alice.New()).
+		Append(AuditHandler(aw)).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodDelete, pathPrefix+moviesV1PathRoot+"/abc", nil)
+	req.Header.Set("User-Agent", "curl/\xff\xfe")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	events := aw.Events()
+	c.Assert(events, qt.HasLen, 1)
+	c.Assert(events[0].UserAgent, qt.Equals, "curl/\uFFFD")
+	c.Assert(utf8.ValidString(events[0].Record().UserAgent), qt.IsTrue)
+}
+
+func TestAuditHandler_routeMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		wantType string
+	}{
+		{"from route", "", "movies"},
+		{"set by service", "people", "people"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			aw := audittest.NewMockWriter(t)
+
+			h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+				Append("audit", AuditHandler(aw)).
+				Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tt.resource != "" {
+						audit.SetResource(r.Context(), tt.resource, "abc")
+					}
+				}), metaMoviesWrite)
+
+			req := httptest.NewRequest(http.MethodDelete, pathPrefix+moviesV1PathRoot+"/abc", nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			events := aw.Events()
+			c.Assert(events, qt.HasLen, 1)
+			c.Assert(events[0].Category, qt.Equals, auditCategoryCatalog)
+			c.Assert(events[0].Resource.Type, qt.Equals, tt.wantType)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// AuthMiddleware authenticates and authorizes requests, so the
+// handlers behind it only deal with an authenticated and authorized
+// user.
+type AuthMiddleware struct {
+	AccessTokenConverter auth.AccessTokenConverter
+	Authorizer           auth.Authorizer
+	// UserRecorder, if set, records each authenticated user in the
+	// users table
+	UserRecorder user.Recorder
+	// PublicRead is whether the public routes are served without an
+	// access token
+	PublicRead PublicRead
+}
+
+// PublicRead is whether the API runs in public read mode, where the
+// routes declared public in their RouteMeta (the reads of the movie
+// and person catalog) can be called without an access token, as
+// user.Anonymous, for a public catalog. Every other route still
+// needs an access token.
+type PublicRead bool
+
+// Handler is middleware which converts the access token set to the
+// request context by AccessTokenHandler to a user.User and
+// authorizes the user to call the route. The resource and action
+// given to the Authorizer are taken from each of the scopes declared
+// in the route's RouteMeta, e.g. movies:write is the write action on
+// the movies resource. A route declaring a role also needs the user
+// to have the role. A route without scopes is an error, so a route
+// cannot be left open by mistake. The user is set
+// to the request context (see requestinfo.UserFromContext) and to the audit
+// record of the request. The user's identity and the authorization
+// decision are set to the logger in the request context, so they
+// are part of the access log. A request without an access token to
+// a public route is served as user.Anonymous in public read mode
+// (see PublicRead), and authorized like any other user. It must be
+// added after AccessTokenHandler.
+func (am AuthMiddleware) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+			ctx := r.Context()
+			meta := routeMeta(ctx)
+
+			u, err := am.authenticate(ctx, meta)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// add the user to every log written for the request,
+			// including the access log
+			hlog.FromRequest(r).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				return identityLogContext(c, u)
+			})
+			logger = *hlog.FromRequest(r)
+
+			// set the user as the actor of the audit event of the
+			// request, so requests which are not authorized are
+			// audited with the user who made them
+			audit.SetActor(ctx, audit.Actor{Username: u.Email, Subject: u.Subject})
+
+			// and as the user of any server error reported
+			setReportUser(ctx, u.Email)
+
+			// a user who cannot be recorded can still be served
+			if am.UserRecorder != nil && !u.IsAnonymous() {
+				if err = am.UserRecorder.Record(ctx, u); err != nil {
+					logger.Warn().Err(err).Msg("user could not be recorded")
+				}
+			}
+
+			err = authorizeRoute(ctx, am.Authorizer, u, meta)
+			hlog.FromRequest(r).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				c = c.Str("authz", authzDecision(err)).Strs("authz_scopes", meta.Scopes)
+				if meta.Role != "" {
+					c = c.Str("authz_role", meta.Role)
+				}
+				return c
+			})
+			logger = *hlog.FromRequest(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding the user to the request context
+			h.ServeHTTP(w, r.WithContext(requestinfo.WithUser(ctx, u)))
+		})
+}
+
+// authenticate returns the user of the access token set to ctx by
+// AccessTokenHandler or, if there is none, the anonymous user when
+// the route described by meta is public and the API runs in public
+// read mode
+func (am AuthMiddleware) authenticate(ctx context.Context, meta RouteMeta) (user.User, error) {
+	accessToken, ok := requestinfo.AccessTokenFromContext(ctx)
+	if !ok || accessToken.Token == "" {
+		if bool(am.PublicRead) && meta.Public {
+			return user.Anonymous(), nil
+		}
+		return user.User{}, errs.E(errs.Unauthenticated, errors.New("Access Token not set properly to context"))
+	}
+
+	return am.AccessTokenConverter.Convert(ctx, accessToken)
+}
+
+// identityLogContext adds the identity of u to c: the email, the
+// subject at the identity provider and the hosted domain of a Google
+// Workspace user. The subject and hosted domain are left out if they
+// are not known. The tenant the request is made for is logged by
+// TenantHandler.
+func identityLogContext(c zerolog.Context, u user.User) zerolog.Context {
+	c = c.Str("user", u.Email)
+	if u.Subject != "" {
+		c = c.Str("user_subject", u.Subject)
+	}
+	if u.HostedDomain != "" {
+		c = c.Str("hosted_domain", u.HostedDomain)
+	}
+	return c
+}
+
+// Authorization decisions, as logged for a request
+const (
+	authzAllow string = "allow"
+	authzDeny  string = "deny"
+	authzError string = "error"
+)
+
+// authzDecision returns the authorization decision for err, the
+// error returned by authorizeScopes. An error which is not an
+// Unauthorized error means no decision could be made.
+func authzDecision(err error) string {
+	switch {
+	case err == nil:
+		return authzAllow
+	case errs.KindIs(errs.Unauthorized, err):
+		return authzDeny
+	}
+	return authzError
+}
+
+// authorizeRoute authorizes u to call the route described by meta
+// using az: u must have the route's role, if it has one, and be
+// authorized for each of its scopes
+func authorizeRoute(ctx context.Context, az auth.Authorizer, u user.User, meta RouteMeta) error {
+	if meta.Role != "" {
+		if err := az.Authorize(ctx, u, auth.RoleResource, meta.Role); err != nil {
+			return err
+		}
+	}
+	return authorizeScopes(ctx, az, u, meta.Scopes)
+}
+
+// authorizeScopes authorizes u for each of scopes using az
+func authorizeScopes(ctx context.Context, az auth.Authorizer, u user.User, scopes []string) error {
+	if len(scopes) == 0 {
+		return errs.E(errs.Internal, errors.New("no scopes declared for route"))
+	}
+
+	for _, scope := range scopes {
+		i := strings.Index(scope, ":")
+		if i < 0 {
+			return errs.E(errs.Internal, errors.Errorf("scope %q must be resource:action", scope))
+		}
+
+		err := az.Authorize(ctx, u, scope[:i], scope[i+1:])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// userFromRequest returns the user set to the request context by
+// AuthMiddleware
+func userFromRequest(r *http.Request) (user.User, error) {
+	u, ok := requestinfo.UserFromContext(r.Context())
+	if !ok {
+		return user.User{}, errs.E(errs.Unauthenticated, errors.New("User not set properly to context"))
+	}
+	return u, nil
+}
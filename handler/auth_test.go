@@ -0,0 +1,352 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// newMockAuthMiddleware returns an AuthMiddleware which
+// authenticates any access token as usertest.NewUser and
+// authorizes every request
+func newMockAuthMiddleware(t *testing.T) AuthMiddleware {
+	return AuthMiddleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		Authorizer:           authtest.NewMockAuthorizer(t),
+	}
+}
+
+// newMockAuthHandler returns the handler of newMockAuthMiddleware
+// for a route declaring scope, for testing handlers without routing
+// requests through a mux.Router
+func newMockAuthHandler(t *testing.T, scope string) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return routeChain{}.Append("auth", newMockAuthMiddleware(t).Handler).Then(h, RouteMeta{Scopes: []string{scope}})
+	}
+}
+
+// recordingAuthorizer records the resources and actions it is asked
+// to authorize and authorizes everything
+type recordingAuthorizer struct {
+	got *[]string
+}
+
+func (ra recordingAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
+	*ra.got = append(*ra.got, resource+" "+action)
+	return nil
+}
+
+func TestAuthMiddleware_Handler(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		role       string
+		token      string
+		wantStatus int
+	}{
+		{"authorized", []string{scopeMoviesWrite}, "", "abc123def1", http.StatusOK},
+		{"not authorized", []string{"studios:read"}, "", "abc123def1", http.StatusForbidden},
+		{"all scopes authorized", []string{scopeMoviesRead, scopeAdminRead}, "", "abc123def1", http.StatusOK},
+		{"one scope not authorized", []string{scopeMoviesRead, "admin:write"}, "", "abc123def1", http.StatusForbidden},
+		{"role authorized", []string{scopeAdminRead}, auth.RoleAdmin, "abc123def1", http.StatusOK},
+		{"role not authorized", []string{scopeAdminRead}, "owner", "abc123def1", http.StatusForbidden},
+		{"no token", []string{scopeMoviesWrite}, "", "", http.StatusUnauthorized},
+		{"no scopes", nil, "", "abc123def1", http.StatusInternalServerError},
+		{"role without scopes", nil, auth.RoleAdmin, "abc123def1", http.StatusInternalServerError},
+		{"bad scope", []string{"movies"}, "", "abc123def1", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           auth.DefaultAuthorizer{},
+			}
+
+			// the handler asserts the user is set to the request
+			// context by the middleware
+			var called bool
+			h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+				Append("access_token", AccessTokenHandler).
+				Append("auth", am.Handler).
+				Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					called = true
+					u, err := userFromRequest(r)
+					c.Assert(err, qt.IsNil)
+					c.Assert(u, qt.DeepEquals, usertest.NewUser(t))
+				}), RouteMeta{Scopes: tt.scopes, Role: tt.role})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tt.token != "" {
+				req.Header.Add("Authorization", auth.BearerTokenType+" "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(called, qt.Equals, tt.wantStatus == http.StatusOK)
+		})
+	}
+
+	t.Run("route scopes", func(t *testing.T) {
+		c := qt.New(t)
+
+		lgr := logger.NewLogger(os.Stdout, true)
+
+		var got []string
+		am := AuthMiddleware{
+			AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+			Authorizer:           recordingAuthorizer{got: &got},
+		}
+
+		h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+			Append("access_token", AccessTokenHandler).
+			Append("auth", am.Handler).
+			Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+				RouteMeta{Scopes: []string{scopeMoviesWrite, scopeAdminRead}, Role: auth.RoleAdmin})
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/movies/BDylwy3BnPazC4Casn5M", nil)
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+		rr := httptest.NewRecorder()
+
+		h.ServeHTTP(rr, req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+		c.Assert(got, qt.DeepEquals, []string{"role admin", "movies write", "admin read"})
+	})
+}
+
+func TestAuthMiddleware_Handler_logger(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    string
+		wantLogs []string
+	}{
+		{"allowed", scopeMoviesRead, []string{`"authz":"allow"`, `"authz_scopes":["movies:read"]`}},
+		{"denied", "studios:read", []string{`"authz":"deny"`, `"authz_scopes":["studios:read"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			// logs written for the request after the middleware have
+			// the user and the authorization decision
+			buf := new(bytes.Buffer)
+			lgr := logger.NewLogger(buf, false)
+
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           auth.DefaultAuthorizer{},
+			}
+
+			h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+				Append("access_token", AccessTokenHandler).
+				Append("auth", am.Handler).
+				Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					hlog.FromRequest(r).Info().Msg("in handler")
+				}), RouteMeta{Scopes: []string{tt.scope}})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// the access log is the last line written
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			accessLog := lines[len(lines)-1]
+			c.Assert(accessLog, qt.Contains, `"status":`)
+			c.Assert(accessLog, qt.Contains, `"user":"`+usertest.NewUser(t).Email+`"`)
+			for _, want := range tt.wantLogs {
+				c.Assert(accessLog, qt.Contains, want)
+			}
+		})
+	}
+}
+
+// stubRecorder records the users it is given, failing with err if set
+type stubRecorder struct {
+	got []user.User
+	err error
+}
+
+func (sr *stubRecorder) Record(ctx context.Context, u user.User) error {
+	sr.got = append(sr.got, u)
+	return sr.err
+}
+
+func TestAuthMiddleware_Handler_recorder(t *testing.T) {
+	tests := []struct {
+		name       string
+		scope      string
+		err        error
+		wantStatus int
+	}{
+		{"recorded", scopeMoviesRead, nil, http.StatusOK},
+		{"not recorded", scopeMoviesRead, errors.New("connection reset"), http.StatusOK},
+		{"not authorized", "studios:read", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			// the authenticated user is recorded whether or not they
+			// are authorized, and a user who cannot be recorded is
+			// still served
+			sr := &stubRecorder{err: tt.err}
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           auth.DefaultAuthorizer{},
+				UserRecorder:         sr,
+			}
+
+			h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+				Append("access_token", AccessTokenHandler).
+				Append("auth", am.Handler).
+				Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+					RouteMeta{Scopes: []string{tt.scope}})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(sr.got, qt.DeepEquals, []user.User{usertest.NewUser(t)})
+		})
+	}
+}
+
+func TestAuthMiddleware_Handler_public(t *testing.T) {
+	tests := []struct {
+		name          string
+		publicRead    PublicRead
+		meta          RouteMeta
+		header        string
+		wantStatus    int
+		wantAnonymous bool
+	}{
+		{"anonymous", true, metaMoviesRead, "", http.StatusOK, true},
+		{"with token", true, metaMoviesRead, auth.BearerTokenType + " abc123def1", http.StatusOK, false},
+		{"empty token", true, metaMoviesRead, auth.BearerTokenType + " ", http.StatusUnauthorized, false},
+		{"other scheme", true, metaMoviesRead, "Basic b3R0bzpzZWNyZXQ=", http.StatusUnauthorized, false},
+		{"route not public", true, metaMoviesWrite, "", http.StatusUnauthorized, false},
+		{"public read off", false, metaMoviesRead, "", http.StatusUnauthorized, false},
+		{"anonymous not authorized", true, RouteMeta{Scopes: []string{scopeMoviesWrite}, Public: true}, "", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			// the anonymous user is not recorded
+			sr := &stubRecorder{}
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           auth.DefaultAuthorizer{},
+				UserRecorder:         sr,
+				PublicRead:           tt.publicRead,
+			}
+
+			var got user.User
+			h := routeChain{chain: LoggerHandlerChain(lgr, alice.New())}.
+				Append("access_token", AccessTokenHandler).
+				Append("auth", am.Handler).
+				Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					var err error
+					got, err = userFromRequest(r)
+					c.Assert(err, qt.IsNil)
+				}), tt.meta)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tt.header != "" {
+				req.Header.Add("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			c.Assert(got.IsAnonymous(), qt.Equals, tt.wantAnonymous)
+			wantRecorded := 1
+			if tt.wantAnonymous {
+				wantRecorded = 0
+			}
+			c.Assert(sr.got, qt.HasLen, wantRecorded)
+		})
+	}
+}
+
+func Test_identityLogContext(t *testing.T) {
+	tests := []struct {
+		name string
+		u    user.User
+		want string
+	}{
+		{"email only", user.User{Email: "otto.maddox711@gmail.com"}, `{"user":"otto.maddox711@gmail.com"}`},
+		{"subject and tenant", user.User{Subject: "108533491328720123456", Email: "otto.maddox@helpinghand.com", HostedDomain: "helpinghand.com"},
+			`{"user":"otto.maddox@helpinghand.com","user_subject":"108533491328720123456","hosted_domain":"helpinghand.com"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			lgr := identityLogContext(zerolog.New(buf).With(), tt.u).Logger()
+			lgr.Log().Msg("")
+
+			qt.Assert(t, strings.TrimSpace(buf.String()), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_authzDecision(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, "allow"},
+		{"unauthorized", errs.E(errs.Unauthorized, "denied"), "deny"},
+		{"internal", errs.E(errs.Internal, "no scopes declared for route"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, authzDecision(tt.err), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_userFromRequest(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+
+	_, err := userFromRequest(req)
+	c.Assert(err, qt.ErrorMatches, "User not set properly to context")
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Availability reports whether the services the API depends on, such
+// as the database, are available
+type Availability interface {
+	// Available returns an errs.Unavailable error, with how long to
+	// wait before retrying, if a service is not available
+	Available() error
+}
+
+// AvailabilityHandler middleware turns requests away with a 503
+// Service Unavailable and a Retry-After header while av is not
+// available, instead of leaving them to fail with a 500 after
+// waiting for the service
+func AvailabilityHandler(av Availability) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := av.Available(); err != nil {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
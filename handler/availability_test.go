@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// fakeAvailability returns err from Available
+type fakeAvailability struct {
+	err error
+}
+
+func (f fakeAvailability) Available() error { return f.err }
+
+func TestAvailabilityHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"available", nil, http.StatusOK, ""},
+		{"unavailable", errs.E(errs.Unavailable, errs.Code("datastore_unavailable"), errs.RetryAfter(5*time.Second), errors.New("the database is unavailable, retry later")), http.StatusServiceUnavailable, "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := hlog.NewHandler(zerolog.Nop())(AvailabilityHandler(fakeAvailability{tt.err})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(rr.Header().Get("Retry-After"), qt.Equals, tt.wantRetryAfter)
+			if tt.err != nil {
+				c.Assert(rr.Body.String(), qt.Contains, `"code":"datastore_unavailable"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
+	"github.com/gilcrest/go-api-basic/handler/handlertest"
+)
+
+// benchmarkRoute serves requests for the route through the full
+// router and middleware chain. The stores are mocks, so the
+// benchmark measures the handler and middleware overhead only.
+func benchmarkRoute(b *testing.B, method, path, body string) {
+	// log as the server does, but discard the output
+	lgr := logger.NewLogger(io.Discard, true)
+
+	rtr := handlertest.NewRouter(b, handlertest.Deps{
+		ExternalIDGenerator: randomtest.NewMockStringGenerator(b),
+		Transactor:          moviestoretest.NewMockTransactor(b),
+		Selector:            moviestoretest.NewMockSelector(b),
+		Logger:              &lgr,
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var rb io.Reader
+		if body != "" {
+			rb = strings.NewReader(body)
+		}
+		rr := handlertest.Serve(b, rtr, handlertest.NewRequest(b, method, path, rb))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+}
+
+func BenchmarkCreateMovie(b *testing.B) {
+	benchmarkRoute(b, http.MethodPost, "/api/v1/movies",
+		`{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`)
+}
+
+func BenchmarkFindMovieByID(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG", "")
+}
+
+func BenchmarkFindAllMovies(b *testing.B) {
+	benchmarkRoute(b, http.MethodGet, "/api/v1/movies", "")
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// BodyLimitHandler middleware turns away a request body larger than
+// max bytes with a 413 Request Entity Too Large. A body whose
+// Content-Length is larger is turned away before it is read; any
+// other body is read through http.MaxBytesReader, so reading more
+// than max bytes of it is an errs.TooLarge error, which decoding the
+// body returns.
+func BodyLimitHandler(max int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), bodyTooLargeErr(max))
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, max), max: max}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody is a request body read through http.MaxBytesReader,
+// returning an errs.TooLarge error in place of its error once more
+// than max bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	max int64
+	// n is the number of bytes read
+	n int64
+}
+
+// Read reads from the body. http.MaxBytesReader returns max bytes
+// and then an error, so an error once max bytes are read is the body
+// being too large.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && b.n >= b.max {
+		return n, bodyTooLargeErr(b.max)
+	}
+	return n, err
+}
+
+// bodyTooLargeErr returns the error for a request body larger than
+// max bytes
+func bodyTooLargeErr(max int64) error {
+	return errs.E(errs.TooLarge, errs.Code("body_too_large"), errors.Errorf("request body cannot be larger than %d bytes", max))
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestBodyLimitHandler(t *testing.T) {
+	type requestBody struct {
+		IDs []string `json:"ids"`
+	}
+	body := `{"ids": ["a", "b", "c"]}`
+
+	tests := []struct {
+		name          string
+		max           int64
+		contentLength int64
+		wantStatus    int
+	}{
+		{"within limit", int64(len(body)), int64(len(body)), http.StatusOK},
+		{"content length too large", int64(len(body)) - 1, int64(len(body)), http.StatusRequestEntityTooLarge},
+		// a chunked body is only found too large when it is read
+		{"body too large", int64(len(body)) - 1, -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var read int
+			h := hlog.NewHandler(zerolog.Nop())(BodyLimitHandler(tt.max)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var rb requestBody
+				if err := decodeJSON(r.Body, &rb, DecodeOptions{MaxArrayLen: 10, MaxDepth: 10}); err != nil {
+					errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+					return
+				}
+				read = len(rb.IDs)
+				w.WriteHeader(http.StatusOK)
+			})))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/movies", strings.NewReader(body))
+			req.ContentLength = tt.contentLength
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			if tt.wantStatus == http.StatusOK {
+				c.Assert(read, qt.Equals, 3)
+				return
+			}
+			c.Assert(rr.Body.String(), qt.Contains, `"code":"body_too_large"`)
+		})
+	}
+}
+
+func Test_decodeJSON_bodyTooLarge(t *testing.T) {
+	c := qt.New(t)
+
+	// no more than the limit of a body is read, and so buffered, by
+	// checking its limits
+	src := bytes.NewBufferString(`{"ids": ["a", "b"]}` + strings.Repeat(" ", 1000))
+	body := &limitedBody{ReadCloser: http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(src), 16), max: 16}
+
+	var v interface{}
+	err := decodeJSON(body, &v, DecodeOptions{MaxArrayLen: 2, MaxDepth: 2})
+	c.Assert(errs.KindIs(errs.TooLarge, err), qt.IsTrue, qt.Commentf("error = %v", err))
+	c.Assert(body.n <= 16, qt.IsTrue, qt.Commentf("read = %d", body.n))
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+// LogBodyHeader is the header which marks a request to have its
+// request and response bodies logged, if enabled (see BodyLogging)
+const LogBodyHeader string = "Debug-Log-Body"
+
+const (
+	// maxLoggedBodyBytes is the size a body logged is cut to
+	maxLoggedBodyBytes int = 4096
+	// maxCapturedBodyBytes is the size of a body captured for
+	// logging. Larger bodies are logged by size only, as they cannot
+	// be redacted.
+	maxCapturedBodyBytes int = 64 << 10
+)
+
+// redactedFields are the JSON object fields whose values are never
+// logged. A field is redacted if its name, lower cased, contains
+// any of them.
+var redactedFields = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "email"}
+
+// BodyLogging configures which requests have their request and
+// response bodies logged by BodyLoggingHandler
+type BodyLogging struct {
+	// Routes are the path templates of the routes whose bodies are
+	// logged, e.g. /api/v1/movies/{extlID}, or * for every route
+	Routes []string
+	// Header logs the bodies of requests with the LogBodyHeader
+	// header set to true
+	Header bool
+}
+
+// ParseBodyLogRoutes parses a comma separated list of route path
+// templates for BodyLogging
+func ParseBodyLogRoutes(s string) []string {
+	var routes []string
+	for _, rt := range strings.Split(s, ",") {
+		rt = strings.TrimSpace(rt)
+		if rt != "" {
+			routes = append(routes, rt)
+		}
+	}
+	return routes
+}
+
+// bodyLogging holds the BodyLogging in use
+var bodyLogging atomic.Value
+
+// SetBodyLogging sets which requests have their bodies logged by
+// BodyLoggingHandler. Bodies are not logged until it is called.
+func SetBodyLogging(bl BodyLogging) {
+	bodyLogging.Store(bl)
+}
+
+// logsBody reports whether the bodies of r are to be logged
+func logsBody(r *http.Request) bool {
+	bl, _ := bodyLogging.Load().(BodyLogging)
+	if bl.Header {
+		if b, err := strconv.ParseBool(r.Header.Get(LogBodyHeader)); err == nil && b {
+			return true
+		}
+	}
+	if len(bl.Routes) == 0 {
+		return false
+	}
+	tpl := routeTemplate(r)
+	for _, rt := range bl.Routes {
+		if rt == "*" || rt == tpl {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyLoggingHandler middleware logs the request and response bodies
+// of the requests chosen by SetBodyLogging, for reproducing issues
+// with the payloads sent by a client. JSON bodies are logged with the
+// values of sensitive fields (e.g. password or email) redacted and
+// cut to 4KB; other bodies, and bodies over 64KB, are logged by size
+// only. It must be added after LoggerHandlerChain so the log entry
+// has the request ID.
+func BodyLoggingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !logsBody(r) {
+				h.ServeHTTP(w, r) // call original
+				return
+			}
+
+			reqBody := &bodyCapture{}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = readCloser{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+			}
+			br := &bodyRecorder{statusRecorder: &statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+
+			h.ServeHTTP(br, r) // call original
+
+			// read what the handler left unread, so the request
+			// body is logged in full
+			if r.Body != nil && r.Body != http.NoBody {
+				_, _ = io.Copy(ioutil.Discard, io.LimitReader(r.Body, int64(maxCapturedBodyBytes)))
+			}
+
+			lgr := hlog.FromRequest(r)
+			e := lgr.Info().
+				Str("route", routeTemplate(r)).
+				Int("status", br.status)
+			e = reqBody.log(e, "request_body")
+			e = br.body.log(e, "response_body")
+			e.Msg("request and response bodies")
+		})
+}
+
+// readCloser is an io.ReadCloser made of a Reader and a Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyRecorder is an http.ResponseWriter which captures the response
+// body written, for BodyLoggingHandler
+type bodyRecorder struct {
+	*statusRecorder
+	body bodyCapture
+}
+
+// Write captures b and writes it to the underlying ResponseWriter
+func (br *bodyRecorder) Write(b []byte) (int, error) {
+	_, _ = br.body.Write(b)
+	return br.statusRecorder.Write(b)
+}
+
+// bodyCapture is an io.Writer which keeps the first
+// maxCapturedBodyBytes of a body and counts its size
+type bodyCapture struct {
+	buf  bytes.Buffer
+	size int
+}
+
+// Write captures p
+func (bc *bodyCapture) Write(p []byte) (int, error) {
+	n := len(p)
+	bc.size += n
+	if room := maxCapturedBodyBytes - bc.buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		bc.buf.Write(p)
+	}
+	return n, nil
+}
+
+// log adds the body to e as the field named key, with its size as
+// key_bytes. A body which is not JSON, or too large to be captured
+// in full, is logged by size only.
+func (bc *bodyCapture) log(e *zerolog.Event, key string) *zerolog.Event {
+	e = e.Int(key+"_bytes", bc.size)
+	if bc.size == 0 || bc.size > bc.buf.Len() {
+		return e
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(bc.buf.Bytes(), &v); err != nil {
+		return e
+	}
+	b, err := json.Marshal(redact(v))
+	if err != nil {
+		return e
+	}
+	if len(b) > maxLoggedBodyBytes {
+		// a body cut short is no longer JSON, so it is logged as a
+		// string
+		return e.Str(key, string(b[:maxLoggedBodyBytes])).Bool(key+"_truncated", true)
+	}
+	return e.RawJSON(key, b)
+}
+
+// redact returns v, a value decoded from JSON, with the values of
+// the object fields named in redactedFields replaced
+func redact(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range val {
+			if isRedactedField(k) {
+				val[k] = "[redacted]"
+				continue
+			}
+			val[k] = redact(fv)
+		}
+	case []interface{}:
+		for i, ev := range val {
+			val[i] = redact(ev)
+		}
+	}
+	return v
+}
+
+// isRedactedField reports whether the value of the JSON object field
+// named name is redacted
+func isRedactedField(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range redactedFields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
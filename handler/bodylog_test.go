@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestBodyLoggingHandler(t *testing.T) {
+	// echo responds with the request body
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(b)
+	})
+
+	tests := []struct {
+		name    string
+		bl      BodyLogging
+		header  string
+		body    string
+		wantLog bool
+		wantReq interface{}
+	}{
+		{"disabled", BodyLogging{}, "true", `{"title":"Repo Man"}`, false, nil},
+		{"route", BodyLogging{Routes: []string{"/api/v1/movies/{extlID}"}}, "", `{"title":"Repo Man"}`, true, map[string]interface{}{"title": "Repo Man"}},
+		{"all routes", BodyLogging{Routes: []string{"*"}}, "", `{"title":"Repo Man"}`, true, map[string]interface{}{"title": "Repo Man"}},
+		{"other route", BodyLogging{Routes: []string{"/api/v1/movies"}}, "", `{"title":"Repo Man"}`, false, nil},
+		{"header", BodyLogging{Header: true}, "true", `{"title":"Repo Man"}`, true, map[string]interface{}{"title": "Repo Man"}},
+		{"header not set", BodyLogging{Header: true}, "", `{"title":"Repo Man"}`, false, nil},
+		{"header not enabled", BodyLogging{}, "true", `{"title":"Repo Man"}`, false, nil},
+		{"redacted", BodyLogging{Routes: []string{"*"}}, "", `{"title":"Repo Man","cast":[{"name":"Otto","Email":"otto@example.com"}],"access_token":"abc"}`, true,
+			map[string]interface{}{"title": "Repo Man", "cast": []interface{}{map[string]interface{}{"name": "Otto", "Email": "[redacted]"}}, "access_token": "[redacted]"}},
+		{"not JSON", BodyLogging{Routes: []string{"*"}}, "", `title=Repo Man`, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			SetBodyLogging(tt.bl)
+			defer SetBodyLogging(BodyLogging{})
+
+			var buf bytes.Buffer
+			lgr := logger.NewLogger(&buf, false)
+			rtr := mux.NewRouter()
+			rtr.Handle("/api/v1/movies/{extlID}", LoggerHandlerChain(lgr, alice.New()).Append(BodyLoggingHandler).Then(echo))
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/movies/abc", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(LogBodyHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			rtr.ServeHTTP(rr, req)
+
+			// the response is sent unchanged
+			c.Assert(rr.Code, qt.Equals, http.StatusCreated)
+			c.Assert(rr.Body.String(), qt.Equals, tt.body)
+
+			got := bodyLogEntry(c, &buf)
+			if !tt.wantLog {
+				c.Assert(got, qt.IsNil)
+				return
+			}
+			c.Assert(got, qt.Not(qt.IsNil))
+			c.Assert(got["route"], qt.Equals, "/api/v1/movies/{extlID}")
+			c.Assert(got["status"], qt.Equals, float64(http.StatusCreated))
+			c.Assert(got["request_body_bytes"], qt.Equals, float64(len(tt.body)))
+			c.Assert(got["response_body_bytes"], qt.Equals, float64(len(tt.body)))
+			c.Assert(got["request_body"], qt.DeepEquals, tt.wantReq)
+			c.Assert(got["response_body"], qt.DeepEquals, tt.wantReq)
+		})
+	}
+}
+
+func TestBodyLoggingHandler_large(t *testing.T) {
+	c := qt.New(t)
+
+	SetBodyLogging(BodyLogging{Routes: []string{"*"}})
+	defer SetBodyLogging(BodyLogging{})
+
+	// the request body is cut once redacted, the response body is
+	// too large to be captured and is logged by size only
+	reqBody := `{"title":"` + strings.Repeat("a", maxLoggedBodyBytes) + `"}`
+	respBody := `{"title":"` + strings.Repeat("a", maxCapturedBodyBytes) + `"}`
+
+	var buf bytes.Buffer
+	lgr := logger.NewLogger(&buf, false)
+	h := LoggerHandlerChain(lgr, alice.New()).Append(BodyLoggingHandler).
+		Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(respBody))
+		}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/movies", strings.NewReader(reqBody))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := bodyLogEntry(c, &buf)
+	c.Assert(got, qt.Not(qt.IsNil))
+	c.Assert(got["request_body"], qt.Equals, reqBody[:maxLoggedBodyBytes])
+	c.Assert(got["request_body_truncated"], qt.Equals, true)
+	c.Assert(got["response_body_bytes"], qt.Equals, float64(len(respBody)))
+	_, ok := got["response_body"]
+	c.Assert(ok, qt.IsFalse)
+}
+
+func TestParseBodyLogRoutes(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(ParseBodyLogRoutes(""), qt.IsNil)
+	c.Assert(ParseBodyLogRoutes(" /api/v1/movies, ,/api/v1/movies/{extlID} "), qt.DeepEquals, []string{"/api/v1/movies", "/api/v1/movies/{extlID}"})
+}
+
+// bodyLogEntry returns the log entry written by BodyLoggingHandler
+// to buf, or nil if there is none
+func bodyLogEntry(c *qt.C, buf *bytes.Buffer) map[string]interface{} {
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry map[string]interface{}
+		c.Assert(dec.Decode(&entry), qt.IsNil)
+		if entry["message"] == "request and response bodies" {
+			return entry
+		}
+	}
+	return nil
+}
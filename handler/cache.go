@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CachePolicies holds the CachePolicy for each cacheable route
+type CachePolicies struct {
+	// FindMovieByID is the policy for GET /movies/{id}
+	FindMovieByID CachePolicy
+	// FindAllMovies is the policy for GET /movies
+	FindAllMovies CachePolicy
+}
+
+// CachePolicy determines the Cache-Control header sent with a
+// successful response
+type CachePolicy struct {
+	// MaxAge is how long a response may be used from a cache. If
+	// zero, a cached response must be revalidated before each use
+	// (e.g. using If-Modified-Since).
+	MaxAge time.Duration
+	// Public allows shared caches (e.g. a CDN) to store the
+	// response. Responses are private by default, as they are
+	// returned to an authenticated user.
+	Public bool
+}
+
+// CacheControl returns the Cache-Control header value for the policy
+func (p CachePolicy) CacheControl() string {
+	scope := "private"
+	if p.Public {
+		scope = "public"
+	}
+
+	if p.MaxAge <= 0 {
+		// constant strings, so nothing is allocated per response
+		if p.Public {
+			return "public, no-cache"
+		}
+		return "private, no-cache"
+	}
+
+	return fmt.Sprintf("%s, max-age=%d", scope, int64(p.MaxAge/time.Second))
+}
+
+// setCacheHeaders sets the Cache-Control header using the policy
+// and, if lastModified is not zero, the Last-Modified header. If the
+// request has an If-Modified-Since header which is not before
+// lastModified, a 304 Not Modified is written and true is returned,
+// in which case the caller must not write a response body.
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, p CachePolicy, lastModified time.Time) bool {
+	w.Header().Set("Cache-Control", p.CacheControl())
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	// HTTP dates have a resolution of one second
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	// most requests have no If-Modified-Since header, don't try to
+	// parse it (a failed parse allocates an error per time format)
+	v := r.Header.Get("If-Modified-Since")
+	if v == "" {
+		return false
+	}
+
+	ims, err := http.ParseTime(v)
+	if err != nil || lastModified.After(ims) {
+		return false
+	}
+
+	// a 304 response has no body, so no Content-Type either
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// setETag sets the ETag header to etag, a quoted entity tag such as
+// W/"1f3a". If the request has an If-None-Match header matching etag,
+// a 304 Not Modified is written and true is returned, in which case
+// the caller must not write a response body.
+func setETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	if !etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	// a 304 response has no body, so no Content-Type either
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// etagMatch reports whether the If-None-Match header value
+// ifNoneMatch, a comma separated list of entity tags or *, matches
+// etag. Tags are compared weakly (RFC 7232, section 3.2): W/"a"
+// matches "a".
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCachePolicy_CacheControl(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		want   string
+	}{
+		{"zero value", CachePolicy{}, "private, no-cache"},
+		{"private max-age", CachePolicy{MaxAge: time.Minute}, "private, max-age=60"},
+		{"public max-age", CachePolicy{MaxAge: 5 * time.Minute, Public: true}, "public, max-age=300"},
+		{"public no-cache", CachePolicy{Public: true}, "public, no-cache"},
+	}
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			c.Assert(tt.policy.CacheControl(), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_setCacheHeaders(t *testing.T) {
+	lastModified := time.Date(2008, 1, 8, 6, 54, 0, 500, time.UTC)
+	policy := CachePolicy{MaxAge: time.Minute}
+
+	tests := []struct {
+		name            string
+		ifModifiedSince string
+		lastModified    time.Time
+		wantNotModified bool
+	}{
+		{"no If-Modified-Since", "", lastModified, false},
+		{"not modified", "Tue, 08 Jan 2008 06:54:00 GMT", lastModified, true},
+		{"modified", "Tue, 08 Jan 2008 06:53:59 GMT", lastModified, false},
+		{"invalid If-Modified-Since", "yesterday", lastModified, false},
+		{"no last modified", "Tue, 08 Jan 2008 06:54:00 GMT", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies/abc", nil)
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			rr := httptest.NewRecorder()
+
+			got := setCacheHeaders(rr, req, policy, tt.lastModified)
+			c.Assert(got, qt.Equals, tt.wantNotModified)
+			c.Assert(rr.Header().Get("Cache-Control"), qt.Equals, "private, max-age=60")
+
+			if tt.lastModified.IsZero() {
+				c.Assert(rr.Header().Get("Last-Modified"), qt.Equals, "")
+			} else {
+				c.Assert(rr.Header().Get("Last-Modified"), qt.Equals, "Tue, 08 Jan 2008 06:54:00 GMT")
+			}
+
+			if tt.wantNotModified {
+				c.Assert(rr.Code, qt.Equals, http.StatusNotModified)
+			}
+		})
+	}
+}
+
+func Test_setETag(t *testing.T) {
+	const etag = `W/"1f3a"`
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		wantNotModified bool
+	}{
+		{"no If-None-Match", "", false},
+		{"match", `W/"1f3a"`, true},
+		{"strong match", `"1f3a"`, true},
+		{"one of many", `"abc", W/"1f3a"`, true},
+		{"any", "*", true},
+		{"no match", `W/"1f3b"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Content-Type", "application/json")
+
+			got := setETag(rr, req, etag)
+			c.Assert(got, qt.Equals, tt.wantNotModified)
+			c.Assert(rr.Header().Get("ETag"), qt.Equals, etag)
+
+			if tt.wantNotModified {
+				c.Assert(rr.Code, qt.Equals, http.StatusNotModified)
+				c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "")
+			}
+		})
+	}
+}
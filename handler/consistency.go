@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// ConsistencyTokenHeader is the header a consistency token is sent
+// with on the response of a write, and sent back with the requests
+// which must read the write
+const ConsistencyTokenHeader string = "X-Consistency-Token"
+
+// ConsistencyTokens is whether consistency tokens are exchanged with
+// clients, which is when reads are served by a read replica (see
+// datastore.ReplicaDatastore)
+type ConsistencyTokens bool
+
+// ConsistencyHandler middleware lets a client read its own writes
+// when reads are served by a read replica, which may lag behind the
+// primary database. The response of a request which committed a
+// write has the X-Consistency-Token header, the position in the
+// primary's write-ahead log after the write. A request sent with the
+// header reads from the replica once it has replayed the log up to
+// the token, or from the primary if it has not in time (see
+// requestinfo.Consistency). A token which is not a log position is
+// rejected with a 400 Bad Request.
+func ConsistencyHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := new(requestinfo.Consistency)
+		if v := r.Header.Get(ConsistencyTokenHeader); v != "" {
+			token, ok := parseConsistencyToken(v)
+			if !ok {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), errs.E(errs.Validation, errs.Parameter(ConsistencyTokenHeader), errs.Code("invalid_consistency_token"), errors.Errorf("%s must be a token sent by the API, not %q", ConsistencyTokenHeader, v)))
+				return
+			}
+			c.ReadAfter = token
+		}
+
+		cw := &consistencyWriter{ResponseWriter: w, c: c}
+		h.ServeHTTP(cw, r.WithContext(requestinfo.WithConsistency(r.Context(), c)))
+	})
+}
+
+// parseConsistencyToken returns token in upper case and reports
+// whether it is a Postgres LSN: two hexadecimal numbers of up to 8
+// digits separated by a slash, e.g. 16/B374D848
+func parseConsistencyToken(token string) (string, bool) {
+	parts := strings.Split(token, "/")
+	if len(parts) != 2 {
+		return "", false
+	}
+	for _, p := range parts {
+		if p == "" || len(p) > 8 {
+			return "", false
+		}
+		for _, c := range p {
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return "", false
+			}
+		}
+	}
+	return strings.ToUpper(token), true
+}
+
+// consistencyWriter is an http.ResponseWriter which sets the
+// consistency token of the writes of the request to the response
+// header when the header is written
+type consistencyWriter struct {
+	http.ResponseWriter
+	c           *requestinfo.Consistency
+	wroteHeader bool
+}
+
+// WriteHeader sets the consistency token header, if the request has
+// written, and writes the header to the underlying ResponseWriter
+func (cw *consistencyWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if token := cw.c.Written(); token != "" {
+			cw.Header().Set(ConsistencyTokenHeader, token)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes b to the underlying ResponseWriter, writing the
+// header with a 200 status first if not already written
+func (cw *consistencyWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter, if it can, so a list
+// is streamed through the writer
+func (cw *consistencyWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// RecordError records err to the underlying ResponseWriter if it is
+// an errs.ErrorRecorder, see statusRecorder.RecordError
+func (cw *consistencyWriter) RecordError(err error) {
+	if rec, ok := cw.ResponseWriter.(errs.ErrorRecorder); ok {
+		rec.RecordError(err)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func Test_parseConsistencyToken(t *testing.T) {
+	tests := []struct {
+		token  string
+		want   string
+		wantOK bool
+	}{
+		{"16/B374D848", "16/B374D848", true},
+		{"0/16b3748", "0/16B3748", true},
+		{"FFFFFFFF/FFFFFFFF", "FFFFFFFF/FFFFFFFF", true},
+		{"16B374D848", "", false},
+		{"16/", "", false},
+		{"/B374D848", "", false},
+		{"1/2/3", "", false},
+		{"16/B374D84G", "", false},
+		{"100000000/0", "", false},
+		{"0/16B3748'; select 1", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, ok := parseConsistencyToken(tt.token)
+			c.Assert(ok, qt.Equals, tt.wantOK)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestConsistencyHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		written       string
+		wantStatus    int
+		wantReadAfter string
+		wantHeader    string
+	}{
+		{"read", "", "", http.StatusOK, "", ""},
+		{"read after a write", "16/b374d848", "", http.StatusOK, "16/B374D848", ""},
+		{"write", "", "16/B374D9A0", http.StatusOK, "", "16/B374D9A0"},
+		{"invalid token", "yesterday", "", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var served bool
+			h := LoggerHandlerChain(logger.NewLogger(ioutil.Discard, true), alice.New()).
+				Append(ConsistencyHandler).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					served = true
+					cons, ok := requestinfo.ConsistencyFromContext(r.Context())
+					c.Assert(ok, qt.IsTrue)
+					c.Assert(cons.ReadAfter, qt.Equals, tt.wantReadAfter)
+					if tt.written != "" {
+						cons.SetWritten(tt.written)
+					}
+					_, _ = w.Write([]byte(`{}`))
+				})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tt.token != "" {
+				req.Header.Set(ConsistencyTokenHeader, tt.token)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(rr.Header().Get(ConsistencyTokenHeader), qt.Equals, tt.wantHeader)
+			if tt.wantStatus != http.StatusOK {
+				c.Assert(served, qt.IsFalse)
+				c.Assert(rr.Body.String(), qt.Contains, `"invalid_consistency_token"`)
+				return
+			}
+			c.Assert(served, qt.IsTrue)
+		})
+	}
+}
+
+func TestNewMuxRouter_consistencyTokens(t *testing.T) {
+	for _, ct := range []ConsistencyTokens{false, true} {
+		rl := NewRouteList()
+		_ = NewMuxRouter(logger.NewLogger(ioutil.Discard, true), Handlers{}, AuthMiddleware{}, audittest.NewMockWriter(t), errs.NopReporter{}, rl, EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, ct, false)
+
+		routes, err := rl.Routes()
+		qt.Assert(t, err, qt.IsNil)
+		// the tokens are exchanged on every route, only when there is
+		// a replica
+		for _, rt := range routes {
+			var has bool
+			for _, mw := range rt.Middleware {
+				has = has || mw == "consistency"
+			}
+			qt.Assert(t, has, qt.Equals, bool(ct), qt.Commentf("route %s", rt.Path))
+		}
+	}
+}
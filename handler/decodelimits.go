@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// decodeFrame is an array or object being read by checkDecodeLimits
+type decodeFrame struct {
+	// path is the path of the array or object in the body, e.g.
+	// movies[2].cast, or empty for the body itself
+	path  string
+	array bool
+	// n is the number of elements read, for an array
+	n int
+	// key is the key of the value being read, for an object, and
+	// wantKey is true if the next token is a key
+	key     string
+	wantKey bool
+}
+
+// childPath returns the path of the value being read in f
+func (f *decodeFrame) childPath() string {
+	if f.array {
+		return f.path + "[" + strconv.Itoa(f.n-1) + "]"
+	}
+	if f.path == "" {
+		return f.key
+	}
+	return f.path + "." + f.key
+}
+
+// checkDecodeLimits reads the next JSON value from dec, token by
+// token, and returns an errs.Unprocessable error as soon as an array
+// in it has more than opts.MaxArrayLen elements or its arrays and
+// objects nest deeper than opts.MaxDepth, so a body exceeding the
+// limits is rejected before it is decoded into the request value.
+// decodeJSON buffers the body read by the check to decode it after,
+// so the memory taken by the body is bounded by its size, which
+// BodyLimitHandler limits. A body larger than that limit is an
+// errs.TooLarge error. Malformed JSON is not reported here, but by
+// decoding the body.
+func checkDecodeLimits(dec *json.Decoder, opts DecodeOptions) error {
+	var stack []*decodeFrame
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errs.KindIs(errs.TooLarge, err) {
+				return err
+			}
+			return nil
+		}
+		delim, isDelim := tok.(json.Delim)
+
+		path := ""
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			switch {
+			case isDelim && (delim == ']' || delim == '}'):
+				stack = stack[:len(stack)-1]
+				if len(stack) == 0 {
+					return nil
+				}
+				continue
+			case top.array:
+				top.n++
+				if opts.MaxArrayLen > 0 && top.n > opts.MaxArrayLen {
+					return arrayLenErr(top.path, opts.MaxArrayLen)
+				}
+			case top.wantKey:
+				top.key, _ = tok.(string)
+				top.wantKey = false
+				continue
+			default:
+				top.wantKey = true
+			}
+			path = top.childPath()
+		}
+
+		if !isDelim {
+			if len(stack) == 0 {
+				return nil
+			}
+			continue
+		}
+
+		// an array or object starts
+		if opts.MaxDepth > 0 && len(stack) >= opts.MaxDepth {
+			return errs.E(errs.Unprocessable, errs.Parameter(paramName(path)), errs.Code("too_deep"),
+				errors.Errorf("request body cannot nest arrays and objects more than %d deep", opts.MaxDepth))
+		}
+		stack = append(stack, &decodeFrame{path: path, array: delim == '[', wantKey: delim == '{'})
+	}
+}
+
+// arrayLenErr returns the error for the array at path having more
+// than max elements
+func arrayLenErr(path string, max int) error {
+	p := paramName(path)
+	return errs.E(errs.Unprocessable, errs.Parameter(p), errs.Code("array_too_long"),
+		errors.Errorf("%s cannot have more than %d elements", p, max))
+}
+
+// paramName returns the parameter name of the value at path, which
+// is body for the body itself
+func paramName(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func Test_checkDecodeLimits(t *testing.T) {
+	limits := DecodeOptions{MaxArrayLen: 2, MaxDepth: 4}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"scalar", `"Repo Man"`, nil},
+		{"within limits", `{"ids": ["a", "b"], "movie": {"cast": [{"name": "Emilio Estevez"}]}}`, nil},
+		{"empty array", `{"ids": []}`, nil},
+		{"array too long", `{"ids": ["a", "b", "c"]}`,
+			errs.E(errs.Unprocessable, errs.Parameter("ids"), errs.Code("array_too_long"), errors.New("ids cannot have more than 2 elements"))},
+		{"nested array too long", `{"movies": [{"cast": ["a"]}, {"cast": ["a", "b", "c"]}]}`,
+			errs.E(errs.Unprocessable, errs.Parameter("movies[1].cast"), errs.Code("array_too_long"), errors.New("movies[1].cast cannot have more than 2 elements"))},
+		{"body too long", `[1, 2, 3]`,
+			errs.E(errs.Unprocessable, errs.Parameter("body"), errs.Code("array_too_long"), errors.New("body cannot have more than 2 elements"))},
+		{"too deep", `{"a": {"b": [{"c": [1]}]}}`,
+			errs.E(errs.Unprocessable, errs.Parameter("a.b[0].c"), errs.Code("too_deep"), errors.New("request body cannot nest arrays and objects more than 4 deep"))},
+		// reported by decoding the body instead
+		{"malformed", `{"ids": ["a", "b"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			err := checkDecodeLimits(json.NewDecoder(bytes.NewBufferString(tt.body)), limits)
+			if tt.wantErr == nil {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.Match(tt.wantErr, err), qt.IsTrue, qt.Commentf("error = %v", err))
+		})
+	}
+}
+
+func Test_decodeJSON_limits(t *testing.T) {
+	c := qt.New(t)
+
+	type requestBody struct {
+		IDs []string `json:"ids"`
+	}
+	opts := DecodeOptions{MaxArrayLen: 2, MaxDepth: 2}
+
+	// a body within the limits is decoded in full after it is checked
+	var got requestBody
+	c.Assert(decodeJSON(bytes.NewBufferString(`{"ids": ["a", "b"]}`), &got, opts), qt.IsNil)
+	c.Assert(got.IDs, qt.DeepEquals, []string{"a", "b"})
+
+	err := decodeJSON(bytes.NewBufferString(`{"ids": ["a", "b", "c"]}`), &got, opts)
+	c.Assert(errs.KindIs(errs.Unprocessable, err), qt.IsTrue)
+
+	// malformed JSON is still reported by DecoderErr
+	err = decodeJSON(bytes.NewBufferString(`{"ids": ["a"`), &got, opts)
+	c.Assert(errs.Match(errs.E(errs.InvalidRequest, errors.New("Malformed JSON")), err), qt.IsTrue, qt.Commentf("error = %v", err))
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// DryRunHeader is the header asking for a write to be made as a dry
+// run. It is also set on the response of a dry run.
+const DryRunHeader string = "X-Dry-Run"
+
+// DryRunHandler middleware marks a request sent with the X-Dry-Run
+// header set to true as a dry run (see requestinfo.WithDryRun): it is
+// authorized, validated and written to the database as usual, but
+// the transaction is rolled back, so nothing is changed. The
+// response is the one the write would have had, with the X-Dry-Run
+// header set to true. A dry run is not audited and does not
+// invalidate cached responses, and dry_run is set to the access log.
+// A header which is not a boolean is rejected with a 400 Bad
+// Request, rather than the write being made. It must be added before
+// AuditHandler.
+func DryRunHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(DryRunHeader)
+		if v == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.HTTPErrorResponse(w, *hlog.FromRequest(r), errs.E(errs.Validation, errs.Parameter(DryRunHeader), errs.Code("invalid_dry_run"), errors.Errorf("%s must be true or false, not %q", DryRunHeader, v)))
+			return
+		}
+		if !dryRun {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		lgr := zerolog.Ctx(r.Context())
+		lgr.UpdateContext(func(zc zerolog.Context) zerolog.Context {
+			return zc.Bool("dry_run", true)
+		})
+		w.Header().Set(DryRunHeader, "true")
+		h.ServeHTTP(w, r.WithContext(requestinfo.WithDryRun(r.Context())))
+	})
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestDryRunHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantDryRun bool
+	}{
+		{"no header", "", http.StatusOK, false},
+		{"dry run", "true", http.StatusOK, true},
+		{"dry run 1", "1", http.StatusOK, true},
+		{"not dry run", "false", http.StatusOK, false},
+		{"invalid", "yes", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			var called, gotDryRun bool
+			h := LoggerHandlerChain(zerolog.New(&buf), alice.New()).
+				Append(DryRunHandler).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					called = true
+					gotDryRun = requestinfo.DryRun(r.Context())
+					zerolog.Ctx(r.Context()).Info().Msg("handled")
+				})
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/movies", nil)
+			if tt.header != "" {
+				r.Header.Set(DryRunHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(called, qt.Equals, tt.wantStatus == http.StatusOK)
+			c.Assert(gotDryRun, qt.Equals, tt.wantDryRun)
+			if tt.wantStatus == http.StatusBadRequest {
+				c.Assert(rr.Body.String(), qt.Contains, `"invalid_dry_run"`)
+				return
+			}
+			if tt.wantDryRun {
+				c.Assert(rr.Header().Get(DryRunHeader), qt.Equals, "true")
+				c.Assert(buf.String(), qt.Contains, `"dry_run":true`)
+			} else {
+				c.Assert(rr.Header().Get(DryRunHeader), qt.Equals, "")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package dto
+
+// LogLevel is the request and response body for the admin log
+// level endpoint
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// ResyncStep is the result of a step of the admin resync endpoint,
+// written to the response as soon as the step finishes. Status is
+// complete or failed, with Error set if the step failed.
+type ResyncStep struct {
+	Step       string  `json:"step"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// ResyncMeta is the meta of the admin resync endpoint response,
+// listing the steps which failed
+type ResyncMeta struct {
+	Failed []string `json:"failed"`
+}
@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+)
+
+// AuditRecordResponse is the response body for an audit record
+type AuditRecordResponse struct {
+	RequestID string  `json:"request_id"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Username  string  `json:"username"`
+	Status    int     `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Timestamp string  `json:"timestamp"`
+	// UserAgent and ClientVersion are left out for requests which
+	// sent neither
+	UserAgent     string `json:"user_agent,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+}
+
+// NewAuditRecordResponses converts audit Records to
+// AuditRecordResponses
+func NewAuditRecordResponses(records []audit.Record) []AuditRecordResponse {
+	arr := make([]AuditRecordResponse, 0, len(records))
+	for _, ar := range records {
+		arr = append(arr, AuditRecordResponse{
+			RequestID:     ar.RequestID,
+			Method:        ar.Method,
+			Path:          ar.Path,
+			Username:      ar.Username,
+			Status:        ar.Status,
+			LatencyMS:     float64(ar.Latency) / float64(time.Millisecond),
+			Timestamp:     ar.Timestamp.Format(time.RFC3339Nano),
+			UserAgent:     ar.UserAgent,
+			ClientVersion: ar.ClientVersion,
+		})
+	}
+	return arr
+}
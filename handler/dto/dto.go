@@ -0,0 +1,11 @@
+// Package dto holds the request and response bodies (data transfer
+// objects) of the API, along with functions to convert domain types
+// to them. The handler package uses these types rather than
+// declaring its own, so tests and Go clients can decode responses
+// (or build requests) without redefining them.
+//
+// The types are the v1 formats. A field can be added to a type, but
+// a change which would break a client (renaming or removing a field,
+// or changing its type) belongs in a new type for a new API version,
+// e.g. MovieResponseV2, leaving the v1 type as it is.
+package dto
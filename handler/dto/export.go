@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/export"
+)
+
+// CreateExportJobRequest is the request body to create an export
+// job. Format is csv (the default) or ndjson. Filter narrows what is
+// exported, as the filter query parameter of the list route does.
+type CreateExportJobRequest struct {
+	Format string `json:"format"`
+	Filter string `json:"filter"`
+}
+
+// ExportJobResponse is the response body for an export job. Once the
+// job has succeeded, the file is downloaded from DownloadURL until
+// DownloadURLExpiry, and a new URL is sent each time the job is
+// found.
+type ExportJobResponse struct {
+	ID                string `json:"id"`
+	Resource          string `json:"resource"`
+	Format            string `json:"format"`
+	Status            string `json:"status"`
+	Rows              int    `json:"rows"`
+	Error             string `json:"error,omitempty"`
+	DownloadURL       string `json:"download_url,omitempty"`
+	DownloadURLExpiry string `json:"download_url_expiry,omitempty"`
+	CreateTime        string `json:"create_time"`
+	FinishTime        string `json:"finish_time,omitempty"`
+}
+
+// NewExportJobResponse converts an export.Job to an
+// ExportJobResponse, with the download URL of its file, if any,
+// which expires at expiry
+func NewExportJobResponse(j export.Job, downloadURL string, expiry time.Time) ExportJobResponse {
+	r := ExportJobResponse{
+		ID:         j.ID.String(),
+		Resource:   j.Resource,
+		Format:     string(j.Format),
+		Status:     string(j.Status),
+		Rows:       j.Rows,
+		Error:      j.Error,
+		CreateTime: j.CreateTime.UTC().Format(time.RFC3339),
+	}
+	if !j.FinishTime.IsZero() {
+		r.FinishTime = j.FinishTime.UTC().Format(time.RFC3339)
+	}
+	if downloadURL != "" {
+		r.DownloadURL = downloadURL
+		r.DownloadURLExpiry = expiry.UTC().Format(time.RFC3339)
+	}
+	return r
+}
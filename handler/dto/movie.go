@@ -0,0 +1,266 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+// MovieRequest is the request body to create or update a Movie
+type MovieRequest struct {
+	Title    string `json:"title"`
+	Rated    string `json:"rated"`
+	Released string `json:"release_date"`
+	RunTime  int    `json:"run_time"`
+	Director string `json:"director"`
+	Writer   string `json:"writer"`
+}
+
+// MovieResponse is the response body for a Movie
+type MovieResponse struct {
+	ExternalID      string `json:"external_id"`
+	Title           string `json:"title"`
+	Rated           string `json:"rated"`
+	Released        string `json:"release_date"`
+	RunTime         int    `json:"run_time"`
+	Director        string `json:"director"`
+	Writer          string `json:"writer"`
+	CreateUsername  string `json:"create_username"`
+	CreateTimestamp string `json:"create_timestamp"`
+	UpdateUsername  string `json:"update_username"`
+	UpdateTimestamp string `json:"update_timestamp"`
+}
+
+// NewMovieResponse converts a Movie to a MovieResponse
+func NewMovieResponse(m *movie.Movie) MovieResponse {
+	return MovieResponse{
+		ExternalID:      m.ExternalID,
+		Title:           m.Title,
+		Rated:           m.Rated,
+		Released:        m.Released.Format(time.RFC3339),
+		RunTime:         m.RunTime,
+		Director:        m.Director,
+		Writer:          m.Writer,
+		CreateUsername:  m.CreateUser.Email,
+		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+		UpdateUsername:  m.UpdateUser.Email,
+		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
+	}
+}
+
+// NewMovieResponses converts a slice of Movies to MovieResponses
+func NewMovieResponses(movies []*movie.Movie) []MovieResponse {
+	s := make([]MovieResponse, 0, len(movies))
+	for _, m := range movies {
+		s = append(s, NewMovieResponse(m))
+	}
+	return s
+}
+
+// MovieShape is the shape of the movies in a list of movies
+// response, chosen by the role of the user asking
+type MovieShape int
+
+const (
+	// MovieShapePublic has the public fields of a movie only
+	MovieShapePublic MovieShape = iota
+	// MovieShapeAdmin has the internal fields of a movie as well:
+	// its UUID and who created and last updated it when
+	MovieShapeAdmin
+)
+
+// PublicMovieResponse is the response body for a Movie in a list,
+// for a user who is not an admin
+type PublicMovieResponse struct {
+	ExternalID string `json:"external_id"`
+	Title      string `json:"title"`
+	Rated      string `json:"rated"`
+	Released   string `json:"release_date"`
+	RunTime    int    `json:"run_time"`
+	Director   string `json:"director"`
+	Writer     string `json:"writer"`
+}
+
+// AdminMovieResponse is the response body for a Movie in a list,
+// for an admin
+type AdminMovieResponse struct {
+	ID string `json:"id"`
+	MovieResponse
+}
+
+// NewShapedMovieResponse converts a Movie to a PublicMovieResponse
+// or an AdminMovieResponse, depending on shape
+func NewShapedMovieResponse(m *movie.Movie, shape MovieShape) interface{} {
+	if shape == MovieShapeAdmin {
+		return AdminMovieResponse{ID: m.ID.String(), MovieResponse: NewMovieResponse(m)}
+	}
+	return PublicMovieResponse{
+		ExternalID: m.ExternalID,
+		Title:      m.Title,
+		Rated:      m.Rated,
+		Released:   m.Released.Format(time.RFC3339),
+		RunTime:    m.RunTime,
+		Director:   m.Director,
+		Writer:     m.Writer,
+	}
+}
+
+// NewShapedMovieResponses converts a slice of Movies using
+// NewShapedMovieResponse
+func NewShapedMovieResponses(movies []*movie.Movie, shape MovieShape) []interface{} {
+	s := make([]interface{}, 0, len(movies))
+	for _, m := range movies {
+		s = append(s, NewShapedMovieResponse(m, shape))
+	}
+	return s
+}
+
+// MovieColumns returns the names of the fields of a Movie in shape,
+// as the columns of a CSV export, in the order of MovieFields
+func MovieColumns(shape MovieShape) []string {
+	columns := []string{"external_id", "title", "rated", "release_date", "run_time", "director", "writer"}
+	if shape == MovieShapeAdmin {
+		columns = append([]string{"id"}, columns...)
+		columns = append(columns, "create_username", "create_timestamp", "update_username", "update_timestamp")
+	}
+	return columns
+}
+
+// MovieFields returns the fields of m in shape, as a record of a CSV
+// export, in the order of MovieColumns
+func MovieFields(m *movie.Movie, shape MovieShape) []string {
+	fields := []string{m.ExternalID, m.Title, m.Rated, m.Released.Format(time.RFC3339), strconv.Itoa(m.RunTime), m.Director, m.Writer}
+	if shape == MovieShapeAdmin {
+		fields = append([]string{m.ID.String()}, fields...)
+		fields = append(fields, m.CreateUser.Email, m.CreateTime.Format(time.RFC3339), m.UpdateUser.Email, m.UpdateTime.Format(time.RFC3339))
+	}
+	return fields
+}
+
+// MovieVersionResponse is a version of a Movie in a
+// MovieDiffResponse: its number and the request which made it
+type MovieVersionResponse struct {
+	Version   int    `json:"version"`
+	RequestID string `json:"request_id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// NewMovieVersionResponse is an initializer for MovieVersionResponse
+func NewMovieVersionResponse(number int, requestID string, ts time.Time) MovieVersionResponse {
+	return MovieVersionResponse{Version: number, RequestID: requestID, Timestamp: ts.Format(time.RFC3339)}
+}
+
+// FieldChangeResponse is a field with a different value in two
+// versions of a Movie, with its JSON value in each
+type FieldChangeResponse struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// MovieDiffResponse is the response body for the changes to a Movie
+// between two of its versions
+type MovieDiffResponse struct {
+	ExternalID string                `json:"external_id"`
+	From       MovieVersionResponse  `json:"from"`
+	To         MovieVersionResponse  `json:"to"`
+	Changes    []FieldChangeResponse `json:"changes"`
+}
+
+// DiffMovies returns the fields of a Movie in shape whose values
+// differ between from and to, in the order of MovieColumns. The
+// values are compared as they are sent in a response, so a field
+// not in shape is never a change.
+func DiffMovies(from, to *movie.Movie, shape MovieShape) ([]FieldChangeResponse, error) {
+	var fields [2]map[string]json.RawMessage
+	for i, m := range []*movie.Movie{from, to} {
+		b, err := json.Marshal(NewShapedMovieResponse(m, shape))
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &fields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make([]FieldChangeResponse, 0)
+	for _, col := range MovieColumns(shape) {
+		f, t := fields[0][col], fields[1][col]
+		if bytes.Equal(f, t) {
+			continue
+		}
+		changes = append(changes, FieldChangeResponse{Field: col, From: f, To: t})
+	}
+
+	return changes, nil
+}
+
+// DeleteMovieResponse is the response body for a deleted Movie
+type DeleteMovieResponse struct {
+	ExternalID string `json:"extl_id"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// GroupCountResponse is the response body for a count of movies
+// for a group value
+type GroupCountResponse struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// MovieStatsResponse is the response body for Movie statistics
+type MovieStatsResponse struct {
+	ByRating   []GroupCountResponse `json:"by_rating"`
+	ByDecade   []GroupCountResponse `json:"by_decade"`
+	ByDirector []GroupCountResponse `json:"by_director"`
+}
+
+// NewMovieStatsResponse converts Movie Stats to a MovieStatsResponse
+func NewMovieStatsResponse(s *movie.Stats) MovieStatsResponse {
+	return MovieStatsResponse{
+		ByRating:   newGroupCountResponses(s.ByRating),
+		ByDecade:   newGroupCountResponses(s.ByDecade),
+		ByDirector: newGroupCountResponses(s.ByDirector),
+	}
+}
+
+// newGroupCountResponses converts GroupCounts to GroupCountResponses
+func newGroupCountResponses(gcs []movie.GroupCount) []GroupCountResponse {
+	gcr := make([]GroupCountResponse, 0, len(gcs))
+	for _, gc := range gcs {
+		gcr = append(gcr, GroupCountResponse{Value: gc.Value, Count: gc.Count})
+	}
+	return gcr
+}
+
+// MovieCountResponse is the response body for the number of Movies
+type MovieCountResponse struct {
+	Count     int64 `json:"count"`
+	Estimated bool  `json:"estimated"`
+}
+
+// NewMovieCountResponse converts a Movie Count to a
+// MovieCountResponse
+func NewMovieCountResponse(c movie.Count) MovieCountResponse {
+	return MovieCountResponse{Count: c.Count, Estimated: c.Estimated}
+}
+
+// MovieSuggestionResponse is the response body for a Movie title
+// suggestion
+type MovieSuggestionResponse struct {
+	ExternalID string `json:"external_id"`
+	Title      string `json:"title"`
+}
+
+// NewMovieSuggestionResponses converts Movie Suggestions to
+// MovieSuggestionResponses
+func NewMovieSuggestionResponses(ss []movie.Suggestion) []MovieSuggestionResponse {
+	sr := make([]MovieSuggestionResponse, 0, len(ss))
+	for _, s := range ss {
+		sr = append(sr, MovieSuggestionResponse{ExternalID: s.ExternalID, Title: s.Title})
+	}
+	return sr
+}
@@ -0,0 +1,206 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+func TestNewMovieResponse(t *testing.T) {
+	c := qt.New(t)
+
+	u := usertest.NewUser(t)
+	m := &movie.Movie{
+		ID:         uuid.New(),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+		CreateUser: u,
+		CreateTime: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		UpdateUser: u,
+		UpdateTime: time.Date(2008, 1, 9, 6, 54, 0, 0, time.UTC),
+	}
+
+	want := MovieResponse{
+		ExternalID:      "kCBqDtyAkZIfdWjRDXQG",
+		Title:           "Repo Man",
+		Rated:           "R",
+		Released:        "1984-03-02T00:00:00Z",
+		RunTime:         92,
+		Director:        "Alex Cox",
+		Writer:          "Alex Cox",
+		CreateUsername:  u.Email,
+		CreateTimestamp: "2008-01-08T06:54:00Z",
+		UpdateUsername:  u.Email,
+		UpdateTimestamp: "2008-01-09T06:54:00Z",
+	}
+
+	c.Assert(NewMovieResponse(m), qt.Equals, want)
+	c.Assert(NewMovieResponses([]*movie.Movie{m, m}), qt.DeepEquals, []MovieResponse{want, want})
+	// no movies is an empty list, not null
+	c.Assert(NewMovieResponses(nil), qt.DeepEquals, []MovieResponse{})
+}
+
+func TestNewShapedMovieResponse(t *testing.T) {
+	c := qt.New(t)
+
+	u := usertest.NewUser(t)
+	id := uuid.MustParse("7d3ad0e8-1c5b-4ab3-8c3a-3a6d1d3e9f10")
+	m := &movie.Movie{
+		ID:         id,
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+		CreateUser: u,
+		CreateTime: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		UpdateUser: u,
+		UpdateTime: time.Date(2008, 1, 9, 6, 54, 0, 0, time.UTC),
+	}
+
+	wantPublic := PublicMovieResponse{
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   "1984-03-02T00:00:00Z",
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+	}
+	wantAdmin := AdminMovieResponse{ID: id.String(), MovieResponse: NewMovieResponse(m)}
+
+	c.Assert(NewShapedMovieResponse(m, MovieShapePublic), qt.Equals, wantPublic)
+	c.Assert(NewShapedMovieResponse(m, MovieShapeAdmin), qt.Equals, wantAdmin)
+	c.Assert(NewShapedMovieResponses([]*movie.Movie{m}, MovieShapeAdmin), qt.DeepEquals, []interface{}{wantAdmin})
+	// no movies is an empty list, not null
+	c.Assert(NewShapedMovieResponses(nil, MovieShapePublic), qt.DeepEquals, []interface{}{})
+
+	// the admin shape has the UUID at the same level as the other
+	// fields of the movie
+	b, err := json.Marshal(NewShapedMovieResponse(m, MovieShapeAdmin))
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(b), qt.Contains, `{"id":"7d3ad0e8-1c5b-4ab3-8c3a-3a6d1d3e9f10","external_id":"kCBqDtyAkZIfdWjRDXQG",`)
+	c.Assert(string(b), qt.Contains, `"create_username":"`+u.Email+`"`)
+}
+
+func TestNewMovieStatsResponse(t *testing.T) {
+	c := qt.New(t)
+
+	s := &movie.Stats{
+		ByRating: []movie.GroupCount{{Value: "R", Count: 2}},
+		ByDecade: []movie.GroupCount{{Value: "1980s", Count: 2}},
+	}
+
+	c.Assert(NewMovieStatsResponse(s), qt.DeepEquals, MovieStatsResponse{
+		ByRating:   []GroupCountResponse{{Value: "R", Count: 2}},
+		ByDecade:   []GroupCountResponse{{Value: "1980s", Count: 2}},
+		ByDirector: []GroupCountResponse{},
+	})
+}
+
+func TestMovieFields(t *testing.T) {
+	u := usertest.NewUser(t)
+	m := &movie.Movie{
+		ID:         uuid.MustParse("7d3ad0e8-1c5b-4ab3-8c3a-3a6d1d3e9f10"),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    92,
+		Director:   "Alex Cox",
+		Writer:     "Alex Cox",
+		CreateUser: u,
+		CreateTime: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		UpdateUser: u,
+		UpdateTime: time.Date(2008, 1, 9, 6, 54, 0, 0, time.UTC),
+	}
+
+	for _, shape := range []MovieShape{MovieShapePublic, MovieShapeAdmin} {
+		t.Run(fmt.Sprint(shape), func(t *testing.T) {
+			c := qt.New(t)
+
+			// the columns and fields of a CSV export are those of the
+			// JSON of the movie in the same shape
+			b, err := json.Marshal(NewShapedMovieResponse(m, shape))
+			c.Assert(err, qt.IsNil)
+			var want map[string]interface{}
+			c.Assert(json.Unmarshal(b, &want), qt.IsNil)
+
+			columns := MovieColumns(shape)
+			fields := MovieFields(m, shape)
+			c.Assert(columns, qt.HasLen, len(want))
+			c.Assert(fields, qt.HasLen, len(columns))
+			for i, col := range columns {
+				c.Assert(fields[i], qt.Equals, fmt.Sprint(want[col]), qt.Commentf("column %s", col))
+			}
+		})
+	}
+}
+
+func TestDiffMovies(t *testing.T) {
+	u := usertest.NewUser(t)
+	from := &movie.Movie{
+		ID:         uuid.MustParse("7d3ad0e8-1c5b-4ab3-8c3a-3a6d1d3e9f10"),
+		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
+		Title:      "Repo Man",
+		Rated:      "R",
+		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
+		RunTime:    90,
+		Director:   "Alex Cox",
+		Writer:     "Alex",
+		CreateUser: u,
+		CreateTime: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+		UpdateUser: u,
+		UpdateTime: time.Date(2008, 1, 8, 6, 54, 0, 0, time.UTC),
+	}
+	to := *from
+	to.RunTime = 92
+	to.Writer = "Alex Cox"
+	to.UpdateTime = time.Date(2008, 1, 9, 6, 54, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		from  *movie.Movie
+		to    *movie.Movie
+		shape MovieShape
+		want  []FieldChangeResponse
+	}{
+		{"public", from, &to, MovieShapePublic, []FieldChangeResponse{
+			{Field: "run_time", From: json.RawMessage(`90`), To: json.RawMessage(`92`)},
+			{Field: "writer", From: json.RawMessage(`"Alex"`), To: json.RawMessage(`"Alex Cox"`)},
+		}},
+		{"admin", from, &to, MovieShapeAdmin, []FieldChangeResponse{
+			{Field: "run_time", From: json.RawMessage(`90`), To: json.RawMessage(`92`)},
+			{Field: "writer", From: json.RawMessage(`"Alex"`), To: json.RawMessage(`"Alex Cox"`)},
+			{Field: "update_timestamp", From: json.RawMessage(`"2008-01-08T06:54:00Z"`), To: json.RawMessage(`"2008-01-09T06:54:00Z"`)},
+		}},
+		{"reversed", &to, from, MovieShapePublic, []FieldChangeResponse{
+			{Field: "run_time", From: json.RawMessage(`92`), To: json.RawMessage(`90`)},
+			{Field: "writer", From: json.RawMessage(`"Alex Cox"`), To: json.RawMessage(`"Alex"`)},
+		}},
+		{"same", from, from, MovieShapeAdmin, []FieldChangeResponse{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := DiffMovies(tt.from, tt.to, tt.shape)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package dto
+
+// PermissionsResponse is the response body for the permissions of a
+// user. Scopes are the scopes (e.g. movies:write) of the routes the
+// user is authorized to call.
+type PermissionsResponse struct {
+	Username string   `json:"username"`
+	Scopes   []string `json:"scopes"`
+}
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/person"
+)
+
+// PersonRequest is the request body to create or update a Person
+type PersonRequest struct {
+	Name string `json:"name"`
+}
+
+// PersonResponse is the response body for a Person
+type PersonResponse struct {
+	ExternalID      string `json:"external_id"`
+	Name            string `json:"name"`
+	CreateUsername  string `json:"create_username"`
+	CreateTimestamp string `json:"create_timestamp"`
+	UpdateUsername  string `json:"update_username"`
+	UpdateTimestamp string `json:"update_timestamp"`
+}
+
+// NewPersonResponse converts a Person to a PersonResponse
+func NewPersonResponse(p *person.Person) PersonResponse {
+	return PersonResponse{
+		ExternalID:      p.ExternalID,
+		Name:            p.Name,
+		CreateUsername:  p.CreateUser.Email,
+		CreateTimestamp: p.CreateTime.Format(time.RFC3339),
+		UpdateUsername:  p.UpdateUser.Email,
+		UpdateTimestamp: p.UpdateTime.Format(time.RFC3339),
+	}
+}
+
+// NewPersonResponses converts a slice of People to PersonResponses
+func NewPersonResponses(s []*person.Person) []PersonResponse {
+	rs := make([]PersonResponse, 0, len(s))
+	for _, p := range s {
+		rs = append(rs, NewPersonResponse(p))
+	}
+	return rs
+}
+
+// DeletePersonResponse is the response body for a deleted Person
+type DeletePersonResponse struct {
+	ExternalID string `json:"extl_id"`
+	Deleted    bool   `json:"deleted"`
+}
+
+// PersonMovieResponse is the response body for a Person set as the
+// director of a Movie
+type PersonMovieResponse struct {
+	PersonExternalID string `json:"person_external_id"`
+	MovieExternalID  string `json:"movie_external_id"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+// PingResponse is the response body for the ping endpoint
+type PingResponse struct {
+	DBUp bool `json:"db_up"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+// RouteResponse is the response body for a registered route
+type RouteResponse struct {
+	Methods       []string `json:"methods"`
+	Path          string   `json:"path"`
+	Queries       []string `json:"queries,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	Role          string   `json:"role,omitempty"`
+	Resource      string   `json:"resource,omitempty"`
+	AuditCategory string   `json:"audit_category,omitempty"`
+	Public        bool     `json:"public,omitempty"`
+	Middleware    []string `json:"middleware"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+)
+
+// CreateSessionRequest is the request body to create an integration
+// session. Either AuthorizationCode, along with the RedirectURI it
+// was given to, or RefreshToken must be set.
+type CreateSessionRequest struct {
+	Name              string `json:"name"`
+	AuthorizationCode string `json:"authorization_code"`
+	RedirectURI       string `json:"redirect_uri"`
+	RefreshToken      string `json:"refresh_token"`
+}
+
+// SessionResponse is the response body of a created session. The
+// secret is only ever sent in this response.
+type SessionResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Secret     string `json:"secret"`
+	CreateTime string `json:"create_time"`
+}
+
+// NewSessionResponse converts an auth.Session to a SessionResponse
+func NewSessionResponse(s auth.Session) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID.String(),
+		Name:       s.Name,
+		Secret:     s.Secret,
+		CreateTime: s.CreateTime.Format(time.RFC3339),
+	}
+}
+
+// SessionTokenRequest is the request body to get an access token for
+// a session
+type SessionTokenRequest struct {
+	Secret string `json:"secret"`
+}
+
+// SessionTokenResponse is the response body of an access token
+// issued for a session, in the form of an OAuth 2.0 token response
+type SessionTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Expiry      string `json:"expiry"`
+}
+
+// NewSessionTokenResponse converts an auth.SessionToken to a
+// SessionTokenResponse, expiring in the number of seconds from now
+func NewSessionTokenResponse(st auth.SessionToken, now time.Time) SessionTokenResponse {
+	return SessionTokenResponse{
+		AccessToken: st.Token,
+		TokenType:   st.TokenType,
+		ExpiresIn:   int64(st.Expiry.Sub(now) / time.Second),
+		Expiry:      st.Expiry.UTC().Format(time.RFC3339),
+	}
+}
+
+// DeleteSessionResponse is the response body of a deleted session
+type DeleteSessionResponse struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+}
@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+// WindowResponse is the response body for the usage of a single
+// quota window. Limit and Remaining are omitted if the window has
+// no limit.
+type WindowResponse struct {
+	Window    string `json:"window"`
+	Limit     int64  `json:"limit,omitempty"`
+	Used      int64  `json:"used"`
+	Remaining *int64 `json:"remaining,omitempty"`
+	ResetsAt  string `json:"resets_at"`
+}
+
+// UsageResponse is the response body for a user's quota usage
+type UsageResponse struct {
+	Username string           `json:"username"`
+	Usage    []WindowResponse `json:"usage"`
+}
+
+// NewUsageResponse converts a user's quota Usage to a UsageResponse
+func NewUsageResponse(username string, usage []quota.Usage) UsageResponse {
+	ur := UsageResponse{Username: username}
+	for _, wu := range usage {
+		wr := WindowResponse{
+			Window:   string(wu.Window),
+			Limit:    wu.Limit,
+			Used:     wu.Used,
+			ResetsAt: wu.ResetsAt.Format(time.RFC3339),
+		}
+		if remaining := wu.Remaining(); remaining >= 0 {
+			wr.Remaining = &remaining
+		}
+		ur.Usage = append(ur.Usage, wr)
+	}
+	return ur
+}
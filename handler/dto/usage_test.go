@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+func TestNewUsageResponse(t *testing.T) {
+	c := qt.New(t)
+
+	usage := []quota.Usage{
+		{Window: quota.Day, Limit: 1000, Used: 1, ResetsAt: time.Date(2008, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{Window: quota.Month, Used: 1, ResetsAt: time.Date(2008, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// the monthly window has no limit, so no remaining either
+	remaining := int64(999)
+	c.Assert(NewUsageResponse("otto.maddox@helpinghandacceptanceco.com", usage), qt.DeepEquals, UsageResponse{
+		Username: "otto.maddox@helpinghandacceptanceco.com",
+		Usage: []WindowResponse{
+			{Window: "day", Limit: 1000, Used: 1, Remaining: &remaining, ResetsAt: "2008-01-09T00:00:00Z"},
+			{Window: "month", Used: 1, ResetsAt: "2008-02-01T00:00:00Z"},
+		},
+	})
+}
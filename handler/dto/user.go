@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// ErasureReportResponse is the response body for the erasure of a
+// user's personal data
+type ErasureReportResponse struct {
+	UserID        string `json:"user_id"`
+	Tombstone     string `json:"tombstone"`
+	AuditRecords  int64  `json:"audit_records"`
+	Movies        int64  `json:"movies"`
+	People        int64  `json:"people"`
+	RequestCounts int64  `json:"request_counts"`
+	Sessions      int64  `json:"sessions"`
+	ExportJobs    int64  `json:"export_jobs"`
+	ErasedAt      string `json:"erased_at"`
+}
+
+// NewErasureReportResponse converts a user.ErasureReport to an
+// ErasureReportResponse
+func NewErasureReportResponse(rpt user.ErasureReport) ErasureReportResponse {
+	return ErasureReportResponse{
+		UserID:        rpt.UserID.String(),
+		Tombstone:     rpt.Tombstone,
+		AuditRecords:  rpt.AuditRecords,
+		Movies:        rpt.Movies,
+		People:        rpt.People,
+		RequestCounts: rpt.RequestCounts,
+		Sessions:      rpt.Sessions,
+		ExportJobs:    rpt.ExportJobs,
+		ErasedAt:      rpt.EraseTime.Format(time.RFC3339),
+	}
+}
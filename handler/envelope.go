@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// EnvelopeField is a field of the envelope a StandardResponse is
+// written in. Its value is the default name of the field.
+type EnvelopeField string
+
+// Envelope fields, in the order they are written. No route sends
+// Links yet, so its name can be configured before it is used.
+const (
+	EnvelopePath      EnvelopeField = "path"
+	EnvelopeRequestID EnvelopeField = "request_id"
+	EnvelopeData      EnvelopeField = "data"
+	EnvelopeMeta      EnvelopeField = "meta"
+	EnvelopeLinks     EnvelopeField = "links"
+)
+
+// envelopeFields are the EnvelopeFields, in the order they are
+// written
+var envelopeFields = []EnvelopeField{EnvelopePath, EnvelopeRequestID, EnvelopeData, EnvelopeMeta, EnvelopeLinks}
+
+// omitEnvelopeField is the name which leaves a field out of the
+// envelope, as in a json struct tag
+const omitEnvelopeField = "-"
+
+// Envelope configures the fields of the envelope a StandardResponse
+// is written in, so the envelope can follow an organization's API
+// guidelines. The zero Envelope writes every field with its default
+// name.
+type Envelope struct {
+	// Names are the names the fields are written with. A field
+	// missing from Names has its default name and a field named "-"
+	// is left out, apart from Data (see EncodeOptions.NoEnvelope to
+	// write the Data only). Names are written before the FieldNaming
+	// is applied, so they are converted to camelCase like the other
+	// fields.
+	Names map[EnvelopeField]string
+}
+
+// name returns the name field f is written with, empty if it is
+// left out
+func (e Envelope) name(f EnvelopeField) string {
+	n, ok := e.Names[f]
+	switch {
+	case !ok || n == "":
+		return string(f)
+	case n == omitEnvelopeField && f != EnvelopeData:
+		return ""
+	case n == omitEnvelopeField:
+		return string(f)
+	}
+	return n
+}
+
+// ParseEnvelope returns the Envelope of s, a comma separated list of
+// field=name pairs, e.g. request_id=trace_id,path=- to rename
+// request_id and leave path out. The fields are path, request_id,
+// data, meta and links, and each name must be unique and made of
+// letters, digits and underscores.
+func ParseEnvelope(s string) (Envelope, error) {
+	var e Envelope
+	used := make(map[string]EnvelopeField)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return Envelope{}, envelopeErr(errors.Errorf("%q must be field=name", pair))
+		}
+		f, n := EnvelopeField(pair[:i]), pair[i+1:]
+
+		if !isEnvelopeField(f) {
+			return Envelope{}, envelopeErr(errors.Errorf("unknown envelope field %q, must be one of path, request_id, data, meta or links", f))
+		}
+		if _, ok := e.Names[f]; ok {
+			return Envelope{}, envelopeErr(errors.Errorf("envelope field %q is named more than once", f))
+		}
+		switch {
+		case n == omitEnvelopeField && f == EnvelopeData:
+			return Envelope{}, envelopeErr(errors.New("the data field cannot be left out, use no-envelope to write the data only"))
+		case n != omitEnvelopeField && !isEnvelopeName(n):
+			return Envelope{}, envelopeErr(errors.Errorf("envelope field name %q must be letters, digits and underscores", n))
+		}
+
+		if e.Names == nil {
+			e.Names = make(map[EnvelopeField]string)
+		}
+		e.Names[f] = n
+	}
+
+	// two fields written with the same name would be a duplicate key
+	for _, f := range envelopeFields {
+		n := e.name(f)
+		if n == "" {
+			continue
+		}
+		if other, ok := used[n]; ok {
+			return Envelope{}, envelopeErr(errors.Errorf("envelope fields %q and %q are both named %q", other, f, n))
+		}
+		used[n] = f
+	}
+
+	return e, nil
+}
+
+// String returns the field=name pairs of e which differ from the
+// defaults, in the format parsed by ParseEnvelope
+func (e Envelope) String() string {
+	var pairs []string
+	for _, f := range envelopeFields {
+		if n, ok := e.Names[f]; ok && n != string(f) {
+			pairs = append(pairs, string(f)+"="+n)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// envelopeErr returns err as an error of the envelope-fields flag
+func envelopeErr(err error) error {
+	return errs.E(errs.Validation, errs.Parameter("envelope-fields"), err)
+}
+
+// isEnvelopeField reports whether f is an EnvelopeField
+func isEnvelopeField(f EnvelopeField) bool {
+	for _, ef := range envelopeFields {
+		if f == ef {
+			return true
+		}
+	}
+	return false
+}
+
+// isEnvelopeName reports whether n is a valid field name: letters,
+// digits and underscores only, so it needs no escaping in JSON
+func isEnvelopeName(n string) bool {
+	if n == "" {
+		return false
+	}
+	for _, c := range n {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// writeEnvelope adds sr, with its Data in its envelope, to the
+// buffer of jb
+func writeEnvelope(jb *jsonBuffer, sr *StandardResponse) error {
+	if err := writeEnvelopeHead(jb, sr); err != nil {
+		return err
+	}
+	if err := jb.encode(sr.Data); err != nil {
+		return err
+	}
+	return writeEnvelopeTail(jb, sr, sr.Meta, sr.Links)
+}
+
+// writeEnvelopeHead adds the start of the envelope of sr, up to the
+// Data value, to the buffer of jb
+func writeEnvelopeHead(jb *jsonBuffer, sr *StandardResponse) error {
+	jb.buf.WriteByte('{')
+	if err := writeEnvelopeField(jb, sr.Envelope, EnvelopePath, sr.Path, sr.Path != ""); err != nil {
+		return err
+	}
+	if err := writeEnvelopeField(jb, sr.Envelope, EnvelopeRequestID, sr.RequestID, sr.RequestID != ""); err != nil {
+		return err
+	}
+
+	return writeEnvelopeKey(jb, sr.Envelope.name(EnvelopeData))
+}
+
+// writeEnvelopeTail adds the end of the envelope of sr, after the
+// Data value, to the buffer of jb, with meta and links written
+// unless they are nil
+func writeEnvelopeTail(jb *jsonBuffer, sr *StandardResponse, meta, links interface{}) error {
+	for _, f := range []struct {
+		field EnvelopeField
+		v     interface{}
+	}{
+		{EnvelopeMeta, meta},
+		{EnvelopeLinks, links},
+	} {
+		n := sr.Envelope.name(f.field)
+		if n == "" || f.v == nil {
+			continue
+		}
+		jb.buf.WriteByte(',')
+		if err := writeEnvelopeKey(jb, n); err != nil {
+			return err
+		}
+		if err := jb.encode(f.v); err != nil {
+			return err
+		}
+	}
+	// end with a newline, as json.Encoder does
+	jb.buf.WriteString("}\n")
+
+	return nil
+}
+
+// writeEnvelopeField adds field f with value v, followed by a comma,
+// to the buffer of jb if ok and f is not left out of e
+func writeEnvelopeField(jb *jsonBuffer, e Envelope, f EnvelopeField, v interface{}, ok bool) error {
+	n := e.name(f)
+	if !ok || n == "" {
+		return nil
+	}
+	if err := writeEnvelopeKey(jb, n); err != nil {
+		return err
+	}
+	if err := jb.encode(v); err != nil {
+		return err
+	}
+	jb.buf.WriteByte(',')
+
+	return nil
+}
+
+// writeEnvelopeKey adds the key n to the buffer of jb. A name
+// parsed by ParseEnvelope needs no escaping, any other is encoded.
+func writeEnvelopeKey(jb *jsonBuffer, n string) error {
+	if !isEnvelopeName(n) {
+		if err := jb.encode(n); err != nil {
+			return err
+		}
+		jb.buf.WriteByte(':')
+		return nil
+	}
+	jb.buf.WriteByte('"')
+	jb.buf.WriteString(n)
+	jb.buf.WriteString(`":`)
+
+	return nil
+}
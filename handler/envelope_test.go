@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Envelope
+		wantErr bool
+	}{
+		{"empty", "", Envelope{}, false},
+		{"rename and omit", "request_id=trace_id, path=-", Envelope{Names: map[EnvelopeField]string{EnvelopeRequestID: "trace_id", EnvelopePath: "-"}}, false},
+		{"swap", "data=meta,meta=data", Envelope{Names: map[EnvelopeField]string{EnvelopeData: "meta", EnvelopeMeta: "data"}}, false},
+		{"no name", "path=", Envelope{}, true},
+		{"no pair", "path", Envelope{}, true},
+		{"unknown field", "errors=errs", Envelope{}, true},
+		{"named twice", "path=p,path=q", Envelope{}, true},
+		{"omit data", "data=-", Envelope{}, true},
+		{"invalid name", `path=a"b`, Envelope{}, true},
+		{"same name", "request_id=id,links=id", Envelope{}, true},
+		{"default name", "path=data", Envelope{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ParseEnvelope(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue, qt.Commentf("error: %v", err))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+
+			// the String of an Envelope parses to the same Envelope
+			again, err := ParseEnvelope(got.String())
+			c.Assert(err, qt.IsNil)
+			c.Assert(again, qt.DeepEquals, got)
+		})
+	}
+}
+
+func Test_writeJSON_envelope(t *testing.T) {
+	type item struct {
+		Title string `json:"title"`
+	}
+
+	tests := []struct {
+		name     string
+		envelope string
+		naming   FieldNaming
+		want     string
+	}{
+		{"default", "", SnakeCase, `{"path":"/api/v1/movies","request_id":"c0mm0nrequest1d","data":[{"title":"Repo Man"}],"meta":{"page":1},"links":{"next":"/api/v1/movies?page=2"}}` + "\n"},
+		{"renamed", "request_id=trace_id,data=items,links=_links", SnakeCase, `{"path":"/api/v1/movies","trace_id":"c0mm0nrequest1d","items":[{"title":"Repo Man"}],"meta":{"page":1},"_links":{"next":"/api/v1/movies?page=2"}}` + "\n"},
+		{"omitted", "path=-,request_id=-,meta=-,links=-", SnakeCase, `{"data":[{"title":"Repo Man"}]}` + "\n"},
+		{"camel", "request_id=trace_id", CamelCase, `{"path":"/api/v1/movies","traceId":"c0mm0nrequest1d","data":[{"title":"Repo Man"}],"meta":{"page":1},"links":{"next":"/api/v1/movies?page=2"}}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			e, err := ParseEnvelope(tt.envelope)
+			c.Assert(err, qt.IsNil)
+
+			sr := StandardResponse{
+				Path:        "/api/v1/movies",
+				RequestID:   "c0mm0nrequest1d",
+				Data:        []item{{"Repo Man"}},
+				Meta:        map[string]int{"page": 1},
+				Links:       map[string]string{"next": "/api/v1/movies?page=2"},
+				Envelope:    e,
+				FieldNaming: tt.naming,
+			}
+			rr := httptest.NewRecorder()
+			c.Assert(writeJSON(rr, &sr), qt.IsNil)
+			c.Assert(rr.Body.String(), qt.Equals, tt.want)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
+)
+
+// exportJobsV1PathRoot is the path of the export jobs, which are
+// created for a resource, e.g. at /api/v1/movies/export-jobs
+const exportJobsV1PathRoot string = "/v1/export-jobs"
+
+// ExportURLExpiry is how long the download URL of an export file
+// works once it is sent
+type ExportURLExpiry time.Duration
+
+// CreateMovieExportJobHandler is a Handler that creates a job
+// exporting movies
+type CreateMovieExportJobHandler http.Handler
+
+// ProvideCreateMovieExportJobHandler is a provider for the
+// CreateMovieExportJobHandler for wire
+func ProvideCreateMovieExportJobHandler(h DefaultExportJobHandlers) CreateMovieExportJobHandler {
+	return http.HandlerFunc(h.CreateMovieExportJob)
+}
+
+// FindExportJobHandler is a Handler that finds an export job
+type FindExportJobHandler http.Handler
+
+// ProvideFindExportJobHandler is a provider for the
+// FindExportJobHandler for wire
+func ProvideFindExportJobHandler(h DefaultExportJobHandlers) FindExportJobHandler {
+	return http.HandlerFunc(h.FindExportJob)
+}
+
+// DefaultExportJobHandlers handles requests for export jobs, which
+// write exports too large to be sent in a response to a file in
+// object storage in the background (see export.Runner)
+type DefaultExportJobHandlers struct {
+	Service       moviesvc.Service
+	Runner        *export.Runner
+	Store         export.JobStore
+	QuotaTracker  quota.Tracker
+	Pagination    Pagination
+	DecodeOptions DecodeOptions
+	URLExpiry     ExportURLExpiry
+	Clock         clock.Clock
+}
+
+// CreateMovieExportJob handles POST requests for the
+// /movies/export-jobs endpoint. A job exporting the movies matching
+// the filter in the request body, in the format asked for, is
+// started and sent with 202 Accepted, its URL in the Location
+// header. The movies are exported as the list route would send
+// them to the user. An export can hold as many movies as the
+// route's MaxExportRows, more is an errs.Unprocessable error.
+func (h DefaultExportJobHandlers) CreateMovieExportJob(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(dto.CreateExportJobRequest)
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
+	defer r.Body.Close()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	format, err := export.ParseFormat(rb.Format)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	f, err := parseFilter(rb.Filter)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// no job is created if it could not run
+	if !h.Runner.Enabled() {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Unavailable, errs.Code("exports_unavailable"), errors.New("exports are not configured")))
+		return
+	}
+
+	// an export too large is turned away now rather than failing
+	// once it has run
+	maxRows := h.Pagination.limits(r).MaxExportRows
+	v, err := h.Service.ListVersion(ctx, u, f)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	if v.Count > maxRows {
+		errs.HTTPErrorResponse(w, logger, tooManyRowsErr(v.Count, maxRows))
+		return
+	}
+
+	// admins are exported the internal fields of the movies
+	shape, err := movieShape(ctx, h.Service, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the movies are read again when the job runs, and may have
+	// changed in between, so the limit is checked again
+	src := func(ctx context.Context, write func(export.Record) error) error {
+		_, err := h.Service.StreamPage(ctx, u, f, maxRows, 0, func(m *movie.Movie, total int) error {
+			if total > maxRows {
+				return tooManyRowsErr(total, maxRows)
+			}
+			return write(movieRecord{m: m, shape: shape})
+		})
+		return err
+	}
+
+	j := export.NewJob(uuid.New(), "movies", format, u.Email, h.Clock.Now().UTC())
+	err = h.Runner.Start(ctx, j, dto.MovieColumns(shape), src)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	logger.Info().
+		Str("export_job_id", j.ID.String()).
+		Str("export_format", string(j.Format)).
+		Msg("export job created")
+
+	response, err := NewStandardResponse(r, dto.NewExportJobResponse(j, "", time.Time{}))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	w.Header().Set("Location", pathPrefix+exportJobsV1PathRoot+"/"+j.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// FindExportJob handles GET requests for the /export-jobs/{id}
+// endpoint and finds an export job created by the user. Once the
+// job has succeeded, the response has a signed URL its file can be
+// downloaded from until the URLExpiry has passed, a new one for
+// each request.
+func (h DefaultExportJobHandlers) FindExportJob(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("id"), errors.New("id must be the UUID of an export job")))
+		return
+	}
+
+	// another user's job is not found
+	j, err := h.Store.FindByID(ctx, id, u.Email)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	var (
+		downloadURL string
+		expiry      time.Time
+	)
+	if j.Status == export.StatusSucceeded {
+		d := time.Duration(h.URLExpiry)
+		expiry = h.Clock.Now().Add(d)
+		downloadURL, err = h.Runner.SignedURL(ctx, j, d)
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, err)
+			return
+		}
+	}
+
+	response, err := NewStandardResponse(r, dto.NewExportJobResponse(j, downloadURL, expiry))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the status changes and the download URL expires, so the
+	// response must not be kept by any cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// tooManyRowsErr returns the error for an export of n movies, more
+// than the max an export can hold
+func tooManyRowsErr(n, max int) error {
+	return errs.E(errs.Unprocessable, errs.Code("export_too_large"), errs.Parameter("filter"),
+		errors.Errorf("%d movies match the filter, more than the %d an export can hold", n, max))
+}
+
+// movieRecord is a movie in an export file, shaped as in a list of
+// movies response
+type movieRecord struct {
+	m     *movie.Movie
+	shape dto.MovieShape
+}
+
+// MarshalJSON encodes the movie as in a list of movies response
+func (mr movieRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dto.NewShapedMovieResponse(mr.m, mr.shape))
+}
+
+// Fields returns the fields of the movie in the order of
+// dto.MovieColumns
+func (mr movieRecord) Fields() []string {
+	return dto.MovieFields(mr.m, mr.shape)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/export/exporttest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
+)
+
+// newExportRouter returns a router with the export job handlers
+// writing to bucket (none if nil), with their jobs in store
+func newExportRouter(t *testing.T, bucket export.Bucket, store export.JobStore, p Pagination) http.Handler {
+	lgr := logger.NewLogger(ioutil.Discard, true)
+
+	clk := clocktest.NewMockClock(t)
+	runner, stop := export.NewRunner(bucket, store, clk, lgr)
+	t.Cleanup(stop)
+
+	deh := DefaultExportJobHandlers{
+		Service: moviesvc.Service{
+			Authorizer: authtest.NewMockAuthorizer(t),
+			Selector:   moviestoretest.NewMockSelector(t),
+		},
+		Runner:       runner,
+		Store:        store,
+		QuotaTracker: quotatest.NewMockTracker(t),
+		Pagination:   p,
+		URLExpiry:    ExportURLExpiry(15 * time.Minute),
+		Clock:        clk,
+	}
+	am := AuthMiddleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		Authorizer:           authtest.NewMockAuthorizer(t),
+	}
+	handlers := Handlers{
+		CreateMovieExportJobHandler: ProvideCreateMovieExportJobHandler(deh),
+		FindExportJobHandler:        ProvideFindExportJobHandler(deh),
+	}
+
+	return NewMuxRouter(lgr, handlers, am, audittest.NewMockWriter(t), errs.NopReporter{}, NewRouteList(), EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+}
+
+// serveExport serves an authenticated request and decodes the data
+// of a 200 or 202 response to an ExportJobResponse
+func serveExport(t *testing.T, h http.Handler, method, path, body string) (*httptest.ResponseRecorder, dto.ExportJobResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", auth.BearerTokenType+" abc123def1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var sr struct {
+		Data dto.ExportJobResponse `json:"data"`
+	}
+	if rr.Code == http.StatusOK || rr.Code == http.StatusAccepted {
+		if err := json.Unmarshal(rr.Body.Bytes(), &sr); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+	}
+	return rr, sr.Data
+}
+
+// waitExportJob finds the export job at path until it is done
+func waitExportJob(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, dto.ExportJobResponse) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rr, got := serveExport(t, h, http.MethodGet, path, "")
+		if rr.Code != http.StatusOK || export.Status(got.Status).Done() {
+			return rr, got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("export job %s not done, status %s", got.ID, got.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDefaultExportJobHandlers_CreateMovieExportJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		maxRows    int
+		wantStatus int
+		wantParam  string
+		wantRows   int
+		wantFile   string
+	}{
+		{"csv", `{}`, 0, http.StatusAccepted, "", 2, "id,external_id,title,"},
+		{"ndjson", `{"format": "ndjson", "filter": "title==Repo*"}`, 0, http.StatusAccepted, "", 1, `{"id":"f118f4bb-b345-4517-b463-f237630b1a07","external_id":"kCBqDtyAkZIfdWjRDXQG","title":"Repo Man"`},
+		{"unknown format", `{"format": "xlsx"}`, 0, http.StatusBadRequest, "format", 0, ""},
+		{"invalid filter", `{"filter": "title=="}`, 0, http.StatusBadRequest, "filter", 0, ""},
+		{"too many rows", `{}`, 1, http.StatusUnprocessableEntity, "filter", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			bucket := exporttest.NewBucket()
+			store := memstore.NewExportJobStore()
+			h := newExportRouter(t, bucket, store, Pagination{Defaults: PageLimits{MaxExportRows: tt.maxRows}})
+
+			rr, created := serveExport(t, h, http.MethodPost, pathPrefix+moviesV1PathRoot+"/export-jobs", tt.body)
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus, qt.Commentf("body: %s", rr.Body.String()))
+			if tt.wantStatus != http.StatusAccepted {
+				c.Assert(rr.Body.String(), qt.Contains, `"param":"`+tt.wantParam+`"`)
+				return
+			}
+			c.Assert(created.Status, qt.Equals, string(export.StatusPending))
+			c.Assert(created.DownloadURL, qt.Equals, "")
+			c.Assert(created.CreateTime, qt.Equals, clocktest.Time.Format(time.RFC3339))
+
+			location := rr.Header().Get("Location")
+			c.Assert(location, qt.Equals, pathPrefix+exportJobsV1PathRoot+"/"+created.ID)
+
+			rr, got := waitExportJob(t, h, location)
+			c.Assert(rr.Code, qt.Equals, http.StatusOK)
+			c.Assert(rr.Header().Get("Cache-Control"), qt.Equals, "no-store")
+			c.Assert(got.Status, qt.Equals, string(export.StatusSucceeded), qt.Commentf("error: %s", got.Error))
+			c.Assert(got.Rows, qt.Equals, tt.wantRows)
+			c.Assert(got.FinishTime, qt.Equals, clocktest.Time.Format(time.RFC3339))
+
+			key := "exports/movies/" + created.ID + "." + got.Format
+			c.Assert(got.DownloadURL, qt.Equals, "https://storage.example.com/"+key+"?expiry=15m0s")
+			c.Assert(got.DownloadURLExpiry, qt.Equals, clocktest.Time.Add(15*time.Minute).Format(time.RFC3339))
+
+			file, ok := bucket.Object(key)
+			c.Assert(ok, qt.IsTrue)
+			c.Assert(strings.HasPrefix(string(file), tt.wantFile), qt.IsTrue, qt.Commentf("file: %s", file))
+		})
+	}
+
+	t.Run("no bucket", func(t *testing.T) {
+		h := newExportRouter(t, nil, memstore.NewExportJobStore(), Pagination{})
+
+		rr, _ := serveExport(t, h, http.MethodPost, pathPrefix+moviesV1PathRoot+"/export-jobs", `{}`)
+		qt.Assert(t, rr.Code, qt.Equals, http.StatusServiceUnavailable)
+		qt.Assert(t, rr.Body.String(), qt.Contains, `"exports_unavailable"`)
+	})
+}
+
+func TestDefaultExportJobHandlers_FindExportJob(t *testing.T) {
+	store := memstore.NewExportJobStore()
+	h := newExportRouter(t, exporttest.NewBucket(), store, Pagination{})
+
+	// a job is only found by the user who created it
+	own := export.NewJob(uuid.New(), "movies", export.FormatCSV, "Otto.Maddox711@gmail.com", time.Now())
+	other := export.NewJob(uuid.New(), "movies", export.FormatCSV, "someone@example.com", time.Now())
+	for _, j := range []export.Job{own, other} {
+		if err := store.Create(context.Background(), j); err != nil {
+			t.Fatalf("store.Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"own", own.ID.String(), http.StatusOK},
+		{"another user's", other.ID.String(), http.StatusNotFound},
+		{"not found", uuid.New().String(), http.StatusNotFound},
+		{"not a uuid", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rr, got := serveExport(t, h, http.MethodGet, pathPrefix+exportJobsV1PathRoot+"/"+tt.id, "")
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			// a pending job has no file to download yet
+			c.Assert(got.Status, qt.Equals, string(export.StatusPending))
+			c.Assert(got.DownloadURL, qt.Equals, "")
+		})
+	}
+}
@@ -2,13 +2,22 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
 	"github.com/justinas/alice"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -18,19 +27,36 @@ import (
 // Handlers is a bundled set of all the application's HTTP handlers
 // and HandlerFuncs
 type Handlers struct {
-	CreateMovieHandler   CreateMovieHandler
-	FindMovieByIDHandler FindMovieByIDHandler
-	FindAllMoviesHandler FindAllMoviesHandler
-	UpdateMovieHandler   UpdateMovieHandler
-	DeleteMovieHandler   DeleteMovieHandler
-	PingHandler          PingHandler
+	CreateMovieHandler          CreateMovieHandler
+	FindMovieByIDHandler        FindMovieByIDHandler
+	MovieDiffHandler            MovieDiffHandler
+	FindAllMoviesHandler        FindAllMoviesHandler
+	FindMoviesByIDsHandler      FindMoviesByIDsHandler
+	UpdateMovieHandler          UpdateMovieHandler
+	DeleteMovieHandler          DeleteMovieHandler
+	MovieStatsHandler           MovieStatsHandler
+	SuggestMoviesHandler        SuggestMoviesHandler
+	CountMoviesHandler          CountMoviesHandler
+	PersonHandlers              PersonHandlers
+	UsageHandler                UsageHandler
+	PermissionsHandler          PermissionsHandler
+	EraseUserDataHandler        EraseUserDataHandler
+	CreateSessionHandler        CreateSessionHandler
+	SessionTokenHandler         SessionTokenHandler
+	DeleteSessionHandler        DeleteSessionHandler
+	CreateMovieExportJobHandler CreateMovieExportJobHandler
+	FindExportJobHandler        FindExportJobHandler
+	FindAuditRecordsHandler     FindAuditRecordsHandler
+	FindRoutesHandler           FindRoutesHandler
+	PingHandler                 PingHandler
+	MetricsHandler              MetricsHandler
 }
 
 // LoggerHandlerChain returns a handler chain (via alice.Chain)
 // initialized with all the standard handlers for logging. The logger
 // will be added to the request context for subsequent use with pre-populated
 // fields, including the request method, url, status, size, duration, remote IP,
-// user agent, referer. A unique Request ID is also added to the logger, context
+// scheme, user agent, referer. A unique Request ID is also added to the logger, context
 // and response headers.
 func LoggerHandlerChain(logger zerolog.Logger, c alice.Chain) alice.Chain {
 
@@ -48,48 +74,102 @@ func LoggerHandlerChain(logger zerolog.Logger, c alice.Chain) alice.Chain {
 			Dur("duration", duration).
 			Msg("")
 	})).
-		Append(hlog.RemoteAddrHandler("remote_ip")).
+		Append(remoteIPHandler("remote_ip")).
 		Append(hlog.UserAgentHandler("user_agent")).
+		Append(clientVersionHandler).
 		Append(hlog.RefererHandler("referer")).
-		Append(hlog.RequestIDHandler("request_id", "Request-Id"))
+		Append(hlog.RequestIDHandler("request_id", errs.RequestIDHeader))
 
 	return c
 }
 
+// acceptedContentTypes are the media types of the request bodies
+// accepted by JSONContentTypeHandler
+var acceptedContentTypes = []string{"application/json"}
+
 // JSONContentTypeHandler middleware is used to add the application/json
-// Content-Type Header for responses
+// Content-Type Header for responses. A request with a body must have
+// one of the acceptedContentTypes as its Content-Type, with a charset
+// (if any) of UTF-8, or is sent a 415 Unsupported Media Type listing
+// the accepted types.
 func JSONContentTypeHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			if err := checkContentType(r); err != nil {
+				accepted := strings.Join(acceptedContentTypes, ", ")
+				switch r.Method {
+				case http.MethodPost:
+					w.Header().Set("Accept-Post", accepted)
+				case http.MethodPatch:
+					w.Header().Set("Accept-Patch", accepted)
+				}
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+				return
+			}
 			w.Header().Add("Content-Type", "application/json")
 			h.ServeHTTP(w, r) // call original
 		})
 }
 
+// checkContentType returns an error if r has a body and its
+// Content-Type is not one of the acceptedContentTypes. Media types
+// and charsets are case-insensitive, so "Application/JSON;
+// charset=UTF-8" is accepted.
+func checkContentType(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+
+	accepted := strings.Join(acceptedContentTypes, ", ")
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return errs.E(errs.UnsupportedMediaType, errs.Code("unsupported_media_type"), errors.Errorf("Content-Type header is required, the accepted types are %s", accepted))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return errs.E(errs.UnsupportedMediaType, errs.Code("unsupported_media_type"), errors.Errorf("Content-Type %q is invalid, the accepted types are %s", ct, accepted))
+	}
+	if cs, ok := params["charset"]; ok && !strings.EqualFold(cs, "utf-8") {
+		return errs.E(errs.UnsupportedMediaType, errs.Code("unsupported_charset"), errors.Errorf("charset %q is not supported, the request body must be UTF-8", cs))
+	}
+	for _, t := range acceptedContentTypes {
+		if mediaType == t {
+			return nil
+		}
+	}
+
+	return errs.E(errs.UnsupportedMediaType, errs.Code("unsupported_media_type"), errors.Errorf("Content-Type %s is not supported, the accepted types are %s", mediaType, accepted))
+}
+
 // AccessTokenHandler middleware is used to pull the Bearer token
 // from the Authorization header and set it to the request context
-// as an auth.AccessToken
+// as an auth.AccessToken. A request to a public route (see
+// RouteMeta) without an Authorization header is passed on without a
+// token, for AuthMiddleware to decide whether it is served
+// anonymously.
 func AccessTokenHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			logger := *hlog.FromRequest(r)
-			var token string
-
-			// retrieve the context from the http.Request
-			ctx := r.Context()
-
 			// Pull the token from the Authorization header
 			// by retrieving the value from the Header map with
-			// "Authorization" as the key
-			// format: Authorization: Bearer
-			headerValue, ok := r.Header["Authorization"]
-			if ok && len(headerValue) >= 1 {
-				token = headerValue[0]
-				token = strings.TrimPrefix(token, auth.BearerTokenType+" ")
+			// "Authorization" as the key (already canonical, so
+			// the key is not canonicalized on every request)
+			// format: Authorization: Bearer <token>
+			var (
+				token string
+				ok    bool
+			)
+			if hv := r.Header["Authorization"]; len(hv) >= 1 {
+				token, ok = auth.ParseBearerToken(hv[0])
 			}
 
-			// If the token is empty...
-			if token == "" {
+			// If there is no bearer token (or it is empty)...
+			if !ok {
+				if len(r.Header["Authorization"]) == 0 && routeMeta(r.Context()).Public {
+					h.ServeHTTP(w, r)
+					return
+				}
 				// For Unauthenticated and Unauthorized errors,
 				// the response body should be empty. Use logger
 				// to log the error and then just send
@@ -99,24 +179,128 @@ func AccessTokenHandler(h http.Handler) http.Handler {
 				// and a 403 Forbidden response should be used afterwards, when the user is
 				// authenticated but isn’t authorized to perform the requested operation on
 				// the given resource."
-				errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("Unauthenticated - empty Bearer token")))
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), errs.E(errs.Unauthenticated, errors.New("Unauthenticated - no Bearer token")))
 				return
 			}
 
 			// add access token to context
-			ctx = auth.SetAccessToken2Context(ctx, token, auth.BearerTokenType)
+			ctx := requestinfo.WithAccessToken(r.Context(), auth.AccessToken{Token: token, TokenType: auth.BearerTokenType})
 
 			// call original, adding access token to request context
 			h.ServeHTTP(w, r.WithContext(ctx))
 		})
 }
 
+// panicsTotal counts the panics recovered by RecoveryHandler
+var panicsTotal = metrics.NewCounter("go_api_basic_http_panics_total",
+	"Total number of panics recovered while handling HTTP requests.")
+
+// RecoveryHandler middleware recovers from a panic in a subsequent
+// handler. The panic and stack trace are logged, the panic metric is
+// incremented, the panic is recorded to be reported (see
+// ErrorReportingHandler) and, if the response has not been started, an
+// errs.Internal error response (HTTP 500) is sent. It must be added
+// after LoggerHandlerChain so the logger in the request context has
+// the request ID.
+func RecoveryHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// http.ErrAbortHandler is used to abort a response
+				// on purpose and is not logged by net/http either
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				panicsTotal.Inc()
+
+				logger := *hlog.FromRequest(r)
+				logger.Error().
+					Str("panic", fmt.Sprint(p)).
+					Bytes("stack", debug.Stack()).
+					Msg("panic recovered")
+
+				// record the panic to be reported, rather than the
+				// generic error sent to the client. The stack of an
+				// error created while panicking includes where the
+				// panic happened
+				sr.RecordError(errors.Errorf("panic: %v", p))
+
+				if sr.wroteHeader {
+					// the status and possibly part of the body have
+					// already been sent, there is nothing more that
+					// can be done for the client
+					return
+				}
+				errs.HTTPErrorResponse(sr, logger, errs.E(errs.Internal, errors.New("Internal server error")))
+			}()
+
+			h.ServeHTTP(sr, r) // call original
+		})
+}
+
+// statusRecorder is an http.ResponseWriter which records the status
+// code written and whether the response has been started
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and writes it to the
+// underlying ResponseWriter
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.wroteHeader {
+		return
+	}
+	sr.status = status
+	sr.wroteHeader = true
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes b to the underlying ResponseWriter, writing the
+// header with a 200 status first if not already written
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if !sr.wroteHeader {
+		sr.WriteHeader(http.StatusOK)
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// RecordError records err to the underlying ResponseWriter if it is
+// an errs.ErrorRecorder, so the error sent by errs.HTTPErrorResponse
+// reaches ErrorReportingHandler through any statusRecorder in between
+func (sr *statusRecorder) RecordError(err error) {
+	if rec, ok := sr.ResponseWriter.(errs.ErrorRecorder); ok {
+		rec.RecordError(err)
+	}
+}
+
 // StandardResponse is meant to be included in all non-error
 // response bodies and includes "standard" response fields
 type StandardResponse struct {
 	Path      string      `json:"path,omitempty"`
 	RequestID string      `json:"request_id,omitempty"`
 	Data      interface{} `json:"data"`
+	Meta      interface{} `json:"meta,omitempty"`
+	Links     interface{} `json:"links,omitempty"`
+
+	// Envelope is the naming of the fields above when the response
+	// is written
+	Envelope Envelope `json:"-"`
+
+	// FieldNaming is the naming of the fields when the response is
+	// written
+	FieldNaming FieldNaming `json:"-"`
+
+	// NoEnvelope writes only Data when the response is written
+	NoEnvelope bool `json:"-"`
 }
 
 // NewStandardResponse is an initializer for the StandardResponse struct
@@ -124,20 +308,147 @@ func NewStandardResponse(r *http.Request, d interface{}) (*StandardResponse, err
 	var sr StandardResponse
 	sr.Path = r.URL.EscapedPath()
 	// gets Trace ID from request
-	id, ok := hlog.IDFromRequest(r)
+	id, ok := requestinfo.RequestIDFromContext(r.Context())
 	if !ok {
 		return nil, errs.E(errors.New("request ID not properly set to request context"))
 	}
-	sr.RequestID = id.String()
+	sr.RequestID = id
 
 	sr.Data = d
 
+	opts := encodeOptionsFromContext(r.Context())
+	sr.Envelope = opts.Envelope
+	sr.FieldNaming = opts.FieldNaming
+	sr.NoEnvelope = opts.NoEnvelope
+
 	return &sr, nil
 }
 
+// DecodeOptions configures how JSON request bodies are decoded
+type DecodeOptions struct {
+	// Strict rejects a request body with a field which is not part
+	// of the request (e.g. a typo like "realease_date") instead of
+	// silently ignoring it
+	Strict bool
+	// MaxArrayLen is the most elements an array in a request body
+	// can have, at any depth. Zero is no limit.
+	MaxArrayLen int
+	// MaxDepth is the deepest the arrays and objects of a request
+	// body can nest, the outermost being at depth 1. Zero is no
+	// limit.
+	MaxDepth int
+	// MaxBodyBytes is the largest request body accepted, in bytes
+	// (see BodyLimitHandler). A larger body is sent a 413 Request
+	// Entity Too Large. Zero is no limit.
+	MaxBodyBytes int64
+}
+
+// decodeJSON decodes the JSON request body into v according to
+// opts. Any error is handled by DecoderErr, apart from a body
+// exceeding the limits of opts, which is rejected before it is
+// decoded (see checkDecodeLimits)
+func decodeJSON(body io.Reader, v interface{}, opts DecodeOptions) error {
+	if opts.MaxArrayLen > 0 || opts.MaxDepth > 0 {
+		// the body is read while it is checked, so it is kept to be
+		// decoded after. The check stops at the first limit
+		// exceeded, and BodyLimitHandler stops the body at
+		// MaxBodyBytes, so no more than that is kept.
+		var buf bytes.Buffer
+		if err := checkDecodeLimits(json.NewDecoder(io.TeeReader(body, &buf)), opts); err != nil {
+			return err
+		}
+		body = io.MultiReader(&buf, body)
+	}
+
+	dec := json.NewDecoder(body)
+	if opts.Strict {
+		dec.DisallowUnknownFields()
+	}
+	return DecoderErr(dec.Decode(v))
+}
+
+// jsonBuffer is a buffer with a JSON encoder writing to it
+type jsonBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// encode adds v as JSON to the buffer, without the newline
+// json.Encoder adds after each value
+func (jb *jsonBuffer) encode(v interface{}) error {
+	if err := jb.enc.Encode(v); err != nil {
+		return err
+	}
+	jb.buf.Truncate(jb.buf.Len() - 1)
+
+	return nil
+}
+
+// jsonBufferPool holds the buffers used to encode response bodies,
+// so a buffer and encoder are not allocated for every response
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		jb := new(jsonBuffer)
+		jb.enc = json.NewEncoder(&jb.buf)
+		return jb
+	},
+}
+
+// maxPooledJSONBuffer is the capacity above which a buffer is not
+// returned to jsonBufferPool, so one large response does not keep
+// a large buffer in memory
+const maxPooledJSONBuffer = 64 << 10
+
+// writeJSON encodes v as JSON and writes it as the response body.
+// The body is encoded before anything is written, so if encoding
+// fails the caller can still send an error response. If v is a
+// StandardResponse, it is written in its Envelope, its fields are
+// named using its FieldNaming and only its Data is written if
+// NoEnvelope is set.
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	jb := jsonBufferPool.Get().(*jsonBuffer)
+	defer func() {
+		if jb.buf.Cap() <= maxPooledJSONBuffer {
+			jb.buf.Reset()
+			jsonBufferPool.Put(jb)
+		}
+	}()
+
+	sr, isSR := v.(*StandardResponse)
+	var err error
+	switch {
+	case isSR && sr.NoEnvelope:
+		err = jb.enc.Encode(sr.Data)
+	case isSR:
+		err = writeEnvelope(jb, sr)
+	default:
+		err = jb.enc.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	b := jb.buf.Bytes()
+	if isSR && sr.FieldNaming == CamelCase {
+		b = camelKeys(b)
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
 // DecoderErr handles an error returned by json.NewDecoder(r.Body).Decode(&data)
-// this function will determine the appropriate error response
+// this function will determine the appropriate error response. Errors
+// from a field with the wrong JSON type, an unknown field (when
+// DisallowUnknownFields is used) or invalid JSON syntax are returned
+// as Validation errors naming the field (as the error Parameter) and
+// the position in the request body, so the caller knows what to fix.
 func DecoderErr(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
 	switch {
 	// If the request body is empty (io.EOF)
 	// return an error
@@ -147,9 +458,61 @@ func DecoderErr(err error) error {
 	// return an error
 	case err == io.ErrUnexpectedEOF:
 		return errs.E(errs.InvalidRequest, errors.New("Malformed JSON"))
+	// If the request body has invalid JSON syntax, return the
+	// position of the error
+	case errors.As(err, &syntaxErr):
+		return errs.E(errs.Validation, errs.Code("malformed_json"),
+			errors.Errorf("Malformed JSON at position %d: %s", syntaxErr.Offset, syntaxErr.Error()))
+	// If a JSON value is the wrong type for the field, return the
+	// field, the type expected and the position of the value
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return errs.E(errs.Validation, errs.Parameter(field), errs.Code("invalid_json_type"),
+			errors.Errorf("%s must be a JSON %s, got %s at position %d", field, jsonTypeName(typeErr.Type), typeErr.Value, typeErr.Offset))
+	// If the request body has a field not in the struct being
+	// decoded into (when DisallowUnknownFields is set), return the
+	// field. encoding/json has no error type for this, so the field
+	// has to be taken from the error message
+	case err != nil && strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)
+		return errs.E(errs.Validation, errs.Parameter(field), errs.Code("unknown_field"),
+			errors.Errorf("%s is not a known field", field))
 	// return all other errors
 	case err != nil:
 		return errs.E(err)
 	}
 	return nil
 }
+
+// unknownFieldPrefix is the start of the error message returned by
+// encoding/json when a field is not known
+const unknownFieldPrefix = "json: unknown field "
+
+// jsonTypeName returns the name of the JSON type which is decoded
+// into a value of Go type t
+func jsonTypeName(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	}
+	return "value"
+}
@@ -0,0 +1,49 @@
+//go:build go1.18
+// +build go1.18
+
+package handler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func Fuzz_decodeJSON(f *testing.F) {
+	// requestBody has the field types of the movie request bodies
+	type requestBody struct {
+		Title    string `json:"title"`
+		Rated    string `json:"rated"`
+		Released string `json:"release_date"`
+		RunTime  int    `json:"run_time"`
+		Director string `json:"director"`
+		Writer   string `json:"writer"`
+	}
+
+	for _, s := range []string{
+		`{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`,
+		`{"run_time": 1e400}`,
+		`{"run_time": "92"}`,
+		`{"title": "Repo Man"`,
+		`{"realease_date": "1984-03-02T00:00:00Z"}`,
+		`[]`,
+		``,
+	} {
+		f.Add([]byte(s), false)
+		f.Add([]byte(s), true)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte, strict bool) {
+		var v requestBody
+		err := decodeJSON(bytes.NewReader(body), &v, DecodeOptions{Strict: strict})
+		if err == nil {
+			return
+		}
+		// a bad request body is the client's error, it must never
+		// be reported as an internal error
+		if !errs.KindIs(errs.Validation, err) && !errs.KindIs(errs.InvalidRequest, err) {
+			t.Fatalf("decodeJSON(%q) error = %v, want a Validation or InvalidRequest error", body, err)
+		}
+	})
+}
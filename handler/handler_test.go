@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gilcrest/go-api-basic/domain/user/usertest"
@@ -18,6 +19,9 @@ import (
 	qt "github.com/frankban/quicktest"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+	"github.com/justinas/alice"
 )
 
 func TestJSONContentTypeHandler(t *testing.T) {
@@ -39,6 +43,52 @@ func TestJSONContentTypeHandler(t *testing.T) {
 	handlers.ServeHTTP(rr, req)
 }
 
+func TestJSONContentTypeHandler_requestContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		contentType string
+		wantStatus  int
+		wantCode    string
+		wantAccept  string
+	}{
+		{"json", http.MethodPost, "{}", "application/json", http.StatusOK, "", ""},
+		{"utf-8 charset", http.MethodPut, "{}", "application/json; charset=utf-8", http.StatusOK, "", ""},
+		{"case insensitive", http.MethodPost, "{}", "Application/JSON; Charset=UTF-8", http.StatusOK, "", ""},
+		{"no body", http.MethodPut, "", "", http.StatusOK, "", ""},
+		{"get without content type", http.MethodGet, "", "", http.StatusOK, "", ""},
+		{"missing", http.MethodPost, "{}", "", http.StatusUnsupportedMediaType, "unsupported_media_type", "application/json"},
+		{"form", http.MethodPost, "a=b", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType, "unsupported_media_type", "application/json"},
+		{"invalid", http.MethodPut, "{}", "application/", http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"other charset", http.MethodPatch, "{}", "application/json; charset=iso-8859-1", http.StatusUnsupportedMediaType, "unsupported_charset", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(tt.method, "/api/v1/movies", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			JSONContentTypeHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/json")
+			if tt.wantCode == "" {
+				return
+			}
+			c.Assert(rr.Header().Get("Accept-"+strings.Title(strings.ToLower(tt.method))), qt.Equals, tt.wantAccept)
+
+			var er errs.ErrResponse
+			c.Assert(json.NewDecoder(rr.Body).Decode(&er), qt.IsNil)
+			c.Assert(er.Error.Code, qt.Equals, tt.wantCode)
+		})
+	}
+}
+
 func TestAccessTokenHandler(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
 		c := qt.New(t)
@@ -50,9 +100,9 @@ func TestAccessTokenHandler(t *testing.T) {
 		req.Header.Add("Authorization", auth.BearerTokenType+" abcdef123")
 
 		testAccessTokenHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			token, err := auth.FromRequest(r)
-			if err != nil {
-				t.Fatalf("auth.FromRequest() error = %v", err)
+			token, ok := requestinfo.AccessTokenFromContext(r.Context())
+			if !ok {
+				t.Fatal("requestinfo.AccessTokenFromContext() ok = false")
 			}
 			wantToken := auth.AccessToken{
 				Token:     "abcdef123",
@@ -100,6 +150,106 @@ func TestAccessTokenHandler(t *testing.T) {
 	})
 }
 
+func TestAccessTokenHandler_unauthenticated(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"empty token", auth.BearerTokenType + " "},
+		{"no token", auth.BearerTokenType},
+		{"other scheme", "Basic b3R0bzpzZWNyZXQ="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.Header.Add("Authorization", tt.header)
+
+			handlers := AccessTokenHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Fatal("handler should not make it here")
+			}))
+			rr := httptest.NewRecorder()
+			handlers.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+			c.Assert(rr.Body.String(), qt.Equals, "")
+		})
+	}
+}
+
+func BenchmarkAccessTokenHandler(b *testing.B) {
+	handlers := AccessTokenHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+	req.Header.Add("Authorization", auth.BearerTokenType+" ya29.a0AfH6SMBx3kVtq9fOq2zTq")
+	rr := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		handlers.ServeHTTP(rr, req)
+	}
+}
+
+func TestRecoveryHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+		wantBody string
+		wantInc  float64
+	}{
+		{"no panic", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, http.StatusNoContent, "", 0},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}, http.StatusInternalServerError, `{"error":{"kind":"internal_error","message":"Internal server error","request_id":"<request_id>"}}` + "\n", 1},
+		{"panic after response started", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("partial"))
+			panic("boom")
+		}, http.StatusOK, "partial", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(ioutil.Discard, true)
+			h := LoggerHandlerChain(lgr, alice.New()).
+				Append(RecoveryHandler).
+				Then(tt.handler)
+
+			before := panicsTotal.Value()
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			// the request ID of an error response is the one sent in
+			// the response header
+			wantBody := strings.Replace(tt.wantBody, "<request_id>", rr.Header().Get(errs.RequestIDHeader), 1)
+			c.Assert(rr.Body.String(), qt.Equals, wantBody)
+			c.Assert(panicsTotal.Value()-before, qt.Equals, tt.wantInc)
+		})
+	}
+}
+
+func TestRecoveryHandler_abort(t *testing.T) {
+	c := qt.New(t)
+
+	h := RecoveryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	// http.ErrAbortHandler is re-raised for net/http to abort the
+	// response
+	c.Assert(func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}, qt.PanicMatches, http.ErrAbortHandler.Error())
+}
+
 func TestNewStandardResponse(t *testing.T) {
 	t.Run("no request id", func(t *testing.T) {
 		c := qt.New(t)
@@ -265,7 +415,81 @@ func TestDecoderErr(t *testing.T) {
 		err = DecoderErr(decoder.Decode(&wantBody))
 		defer r.Body.Close()
 
-		// check to make sure I have an error
-		c.Assert(err != nil, qt.Equals, true)
+		// check to make sure I have a validation error naming the
+		// unknown field
+		wantErr := errs.E(errs.Validation, errs.Parameter("unknown_field"), errs.Code("unknown_field"), errors.New("unknown_field is not a known field"))
+		c.Assert(errs.Match(err, wantErr), qt.IsTrue)
 	})
+
+	t.Run("wrong JSON type", func(t *testing.T) {
+		c := qt.New(t)
+
+		type testBody struct {
+			Director string `json:"director"`
+			RunTime  int    `json:"run_time"`
+		}
+
+		// run_time is a string rather than a number
+		requestBody := []byte(`{"director": "Alex Cox", "run_time": "92"}`)
+
+		wantBody := new(testBody)
+		err := DecoderErr(json.NewDecoder(bytes.NewReader(requestBody)).Decode(&wantBody))
+
+		// the position reported differs between Go versions, so
+		// only the start of the message is checked
+		wantErr := errs.E(errs.Validation, errs.Parameter("run_time"), errs.Code("invalid_json_type"))
+		c.Assert(errs.Match(wantErr, err), qt.IsTrue, qt.Commentf("got %v", err))
+		c.Assert(err, qt.ErrorMatches, "run_time must be a JSON number, got string at position [0-9]+")
+	})
+
+	t.Run("invalid JSON syntax", func(t *testing.T) {
+		c := qt.New(t)
+
+		type testBody struct {
+			Director string `json:"director"`
+		}
+
+		// director value is not quoted
+		requestBody := []byte(`{"director": Alex Cox}`)
+
+		wantBody := new(testBody)
+		err := DecoderErr(json.NewDecoder(bytes.NewReader(requestBody)).Decode(&wantBody))
+
+		wantErr := errs.E(errs.Validation, errs.Code("malformed_json"), errors.New("Malformed JSON at position 14: invalid character 'A' looking for beginning of value"))
+		c.Assert(errs.Match(err, wantErr), qt.IsTrue, qt.Commentf("got %v", err))
+	})
+}
+
+func Test_decodeJSON(t *testing.T) {
+	type requestBody struct {
+		Title    string `json:"title"`
+		Released string `json:"release_date"`
+	}
+
+	const body = `{"title": "Repo Man", "realease_date": "1984-03-02T00:00:00Z"}`
+
+	tests := []struct {
+		name    string
+		opts    DecodeOptions
+		want    requestBody
+		wantErr error
+	}{
+		{"lenient", DecodeOptions{}, requestBody{Title: "Repo Man"}, nil},
+		{"strict", DecodeOptions{Strict: true}, requestBody{Title: "Repo Man"},
+			errs.E(errs.Validation, errs.Parameter("realease_date"), errs.Code("unknown_field"), errors.New("realease_date is not a known field"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var got requestBody
+			err := decodeJSON(bytes.NewBufferString(body), &got, tt.opts)
+			c.Assert(got, qt.DeepEquals, tt.want)
+			if tt.wantErr == nil {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.Match(tt.wantErr, err), qt.IsTrue)
+		})
+	}
 }
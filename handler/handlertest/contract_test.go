@@ -0,0 +1,284 @@
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// operation is a documented operation of the API: a sample request
+// to one of the registered routes and the types its response body
+// decodes into. {movie} and {person} in target are replaced by the
+// external IDs of a movie and person created before the operations
+// are replayed, {session} in target and {secret} in body by the ID
+// and secret of a session, and {exportJob} in target by the ID of an
+// export job. The movie is given two versions in its audit history.
+type operation struct {
+	name   string
+	method string
+	// route is the path template of the route, followed by its query
+	// templates (if any) as they are listed by handler.Routes
+	route  string
+	target string
+	body   string
+	status int
+	// data and meta point to values of the types of the data and meta
+	// fields of the response envelope, or are nil if there is no such
+	// field. If data is nil and there is no error, the response is not
+	// JSON.
+	data interface{}
+	meta interface{}
+	// err is true if the response is an errs.ErrResponse
+	err bool
+}
+
+// contractEraser reports the erasure of any user, as if it had
+// nothing to erase
+type contractEraser struct{}
+
+func (contractEraser) Erase(ctx context.Context, id uuid.UUID) (user.ErasureReport, error) {
+	return user.ErasureReport{UserID: id, Tombstone: user.TombstoneUsername}, nil
+}
+
+// contractOperations are the documented operations, in the order
+// they are replayed: the reads come before the writes which change
+// what they read
+var contractOperations = []operation{
+	{name: "find movie", method: http.MethodGet, route: "/api/v1/movies/{extlID}", target: "/api/v1/movies/{movie}", status: http.StatusOK, data: new(dto.MovieResponse)},
+	{name: "find movie not found", method: http.MethodGet, route: "/api/v1/movies/{extlID}", target: "/api/v1/movies/notAMovie", status: http.StatusNotFound, err: true},
+	{name: "find movies by ids", method: http.MethodGet, route: "/api/v1/movies ids={ids}", target: "/api/v1/movies?ids={movie}", status: http.StatusOK, data: new([]dto.AdminMovieResponse)},
+	{name: "find all movies", method: http.MethodGet, route: "/api/v1/movies", target: "/api/v1/movies?page_size=10", status: http.StatusOK, data: new([]dto.AdminMovieResponse), meta: new(handler.PageMeta)},
+	{name: "movie stats", method: http.MethodGet, route: "/api/v1/movies/stats", target: "/api/v1/movies/stats", status: http.StatusOK, data: new(dto.MovieStatsResponse)},
+	{name: "suggest movies", method: http.MethodGet, route: "/api/v1/movies/suggest", target: "/api/v1/movies/suggest?q=rep", status: http.StatusOK, data: new([]dto.MovieSuggestionResponse)},
+	{name: "suggest movies without q", method: http.MethodGet, route: "/api/v1/movies/suggest", target: "/api/v1/movies/suggest", status: http.StatusBadRequest, err: true},
+	{name: "count movies", method: http.MethodGet, route: "/api/v1/movies/count", target: "/api/v1/movies/count", status: http.StatusOK, data: new(dto.MovieCountResponse)},
+	{name: "movie history diff", method: http.MethodGet, route: "/api/v1/movies/{extlID}/history/{v1}/diff/{v2}", target: "/api/v1/movies/{movie}/history/1/diff/2", status: http.StatusOK, data: new(dto.MovieDiffResponse)},
+	{name: "movie history diff version not found", method: http.MethodGet, route: "/api/v1/movies/{extlID}/history/{v1}/diff/{v2}", target: "/api/v1/movies/{movie}/history/1/diff/3", status: http.StatusNotFound, err: true},
+	{name: "find person", method: http.MethodGet, route: "/api/v1/people/{extlID}", target: "/api/v1/people/{person}", status: http.StatusOK, data: new(dto.PersonResponse)},
+	{name: "find all people", method: http.MethodGet, route: "/api/v1/people", target: "/api/v1/people", status: http.StatusOK, data: new([]dto.PersonResponse)},
+	{name: "find person movies", method: http.MethodGet, route: "/api/v1/people/{extlID}/movies", target: "/api/v1/people/{person}/movies", status: http.StatusOK, data: new([]dto.MovieResponse)},
+	{name: "find export job", method: http.MethodGet, route: "/api/v1/export-jobs/{id}", target: "/api/v1/export-jobs/{exportJob}", status: http.StatusOK, data: new(dto.ExportJobResponse)},
+	{name: "find export job not found", method: http.MethodGet, route: "/api/v1/export-jobs/{id}", target: "/api/v1/export-jobs/" + uuid.Nil.String(), status: http.StatusNotFound, err: true},
+	{name: "usage", method: http.MethodGet, route: "/api/v1/users/me/usage", target: "/api/v1/users/me/usage", status: http.StatusOK, data: new(dto.UsageResponse)},
+	{name: "permissions", method: http.MethodGet, route: "/api/v1/users/me/permissions", target: "/api/v1/users/me/permissions", status: http.StatusOK, data: new(dto.PermissionsResponse)},
+	{name: "audit", method: http.MethodGet, route: "/api/v1/admin/audit", target: "/api/v1/admin/audit", status: http.StatusOK, data: new([]dto.AuditRecordResponse)},
+	{name: "routes", method: http.MethodGet, route: "/api/v1/admin/routes", target: "/api/v1/admin/routes", status: http.StatusOK, data: new([]dto.RouteResponse)},
+	{name: "ping", method: http.MethodGet, route: "/api/v1/ping", target: "/api/v1/ping", status: http.StatusOK, data: new(dto.PingResponse)},
+	{name: "metrics", method: http.MethodGet, route: "/api/v1/metrics", target: "/api/v1/metrics", status: http.StatusOK},
+	{name: "create movie", method: http.MethodPost, route: "/api/v1/movies", target: "/api/v1/movies", body: contractMovieBody, status: http.StatusOK, data: new(dto.MovieResponse)},
+	{name: "create movie invalid", method: http.MethodPost, route: "/api/v1/movies", target: "/api/v1/movies", body: `{"title": "Repo Man"}`, status: http.StatusBadRequest, err: true},
+	{name: "create movie export job", method: http.MethodPost, route: "/api/v1/movies/export-jobs", target: "/api/v1/movies/export-jobs", body: `{"format": "ndjson", "filter": "rated==R"}`, status: http.StatusAccepted, data: new(dto.ExportJobResponse)},
+	{name: "create movie export job unknown format", method: http.MethodPost, route: "/api/v1/movies/export-jobs", target: "/api/v1/movies/export-jobs", body: `{"format": "xlsx"}`, status: http.StatusBadRequest, err: true},
+	{name: "update movie", method: http.MethodPut, route: "/api/v1/movies/{extlID}", target: "/api/v1/movies/{movie}", body: contractMovieBody, status: http.StatusOK, data: new(dto.MovieResponse)},
+	{name: "create person", method: http.MethodPost, route: "/api/v1/people", target: "/api/v1/people", body: `{"name": "Dan O'Bannon"}`, status: http.StatusOK, data: new(dto.PersonResponse)},
+	{name: "update person", method: http.MethodPut, route: "/api/v1/people/{extlID}", target: "/api/v1/people/{person}", body: `{"name": "Alexander Cox"}`, status: http.StatusOK, data: new(dto.PersonResponse)},
+	{name: "add person movie", method: http.MethodPut, route: "/api/v1/people/{extlID}/movies/{movieExtlID}", target: "/api/v1/people/{person}/movies/{movie}", status: http.StatusOK, data: new(dto.PersonMovieResponse)},
+	{name: "create session", method: http.MethodPost, route: "/api/v1/sessions", target: "/api/v1/sessions", body: `{"name": "nightly import", "refresh_token": "1//refreshToken"}`, status: http.StatusOK, data: new(dto.SessionResponse)},
+	{name: "create session without grant", method: http.MethodPost, route: "/api/v1/sessions", target: "/api/v1/sessions", body: `{"name": "nightly import"}`, status: http.StatusBadRequest, err: true},
+	{name: "session token", method: http.MethodPost, route: "/api/v1/sessions/{id}/token", target: "/api/v1/sessions/{session}/token", body: `{"secret": "{secret}"}`, status: http.StatusOK, data: new(dto.SessionTokenResponse)},
+	{name: "delete session", method: http.MethodDelete, route: "/api/v1/sessions/{id}", target: "/api/v1/sessions/{session}", status: http.StatusOK, data: new(dto.DeleteSessionResponse)},
+	{name: "erase user data", method: http.MethodDelete, route: "/api/v1/users/{id}/data", target: "/api/v1/users/" + uuid.Nil.String() + "/data", status: http.StatusOK, data: new(dto.ErasureReportResponse)},
+	{name: "delete person", method: http.MethodDelete, route: "/api/v1/people/{extlID}", target: "/api/v1/people/{person}", status: http.StatusOK, data: new(dto.DeletePersonResponse)},
+	{name: "delete movie", method: http.MethodDelete, route: "/api/v1/movies/{extlID}", target: "/api/v1/movies/{movie}", status: http.StatusOK, data: new(dto.DeleteMovieResponse)},
+}
+
+const contractMovieBody = `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+
+// TestContract replays every documented operation against the
+// router, with in-memory stores, and checks each response against
+// the types documented for it. A response field which is not in the
+// documented type, or a field of the type missing from the response,
+// fails the test, as does a registered route with no documented
+// operation, so the documentation cannot drift from the handlers.
+func TestContract(t *testing.T) {
+	auditStore := memstore.NewAuditStore()
+	rtr := NewRouter(t, Deps{Eraser: contractEraser{}, AuditStore: auditStore})
+
+	t.Run("every route is documented", func(t *testing.T) {
+		c := qt.New(t)
+
+		routes, err := handler.Routes(rtr)
+		c.Assert(err, qt.IsNil)
+
+		documented := make(map[string]bool)
+		for _, op := range contractOperations {
+			documented[op.method+" "+op.route] = true
+		}
+		registered := make(map[string]bool)
+		for _, rt := range routes {
+			key := strings.Join(append([]string{rt.Path}, rt.Queries...), " ")
+			for _, m := range rt.Methods {
+				registered[m+" "+key] = true
+				c.Check(documented[m+" "+key], qt.IsTrue, qt.Commentf("route %s %s has no documented operation", m, key))
+			}
+		}
+		for op := range documented {
+			c.Check(registered[op], qt.IsTrue, qt.Commentf("operation %s has no registered route", op))
+		}
+	})
+
+	// create the movie and person the operations act on
+	movieID := createForContract(t, rtr, "/api/v1/movies", contractMovieBody)
+	insertVersionsForContract(t, auditStore, movieID)
+	personID := createForContract(t, rtr, "/api/v1/people", `{"name": "Alex Cox"}`)
+	sessionID, secret := createSessionForContract(t, rtr)
+	exportJobID := createExportJobForContract(t, rtr)
+	targets := strings.NewReplacer("{movie}", movieID, "{person}", personID, "{session}", sessionID, "{secret}", secret, "{exportJob}", exportJobID)
+
+	for _, op := range contractOperations {
+		op := op
+		t.Run(op.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var body io.Reader
+			if op.body != "" {
+				body = strings.NewReader(targets.Replace(op.body))
+			}
+			rr := Serve(t, rtr, NewRequest(t, op.method, targets.Replace(op.target), body))
+			c.Assert(rr.Code, qt.Equals, op.status, qt.Commentf("body: %s", rr.Body.String()))
+
+			if op.err {
+				c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/json")
+				assertDocumented(c, rr.Body.Bytes(), new(errs.ErrResponse))
+				return
+			}
+			if op.data == nil {
+				c.Assert(rr.Header().Get("Content-Type"), qt.Not(qt.Contains), "json")
+				return
+			}
+
+			c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/json")
+			var envelope struct {
+				Path      string          `json:"path"`
+				RequestID string          `json:"request_id"`
+				Data      json.RawMessage `json:"data"`
+				Meta      json.RawMessage `json:"meta"`
+			}
+			dec := json.NewDecoder(bytes.NewReader(rr.Body.Bytes()))
+			dec.DisallowUnknownFields()
+			c.Assert(dec.Decode(&envelope), qt.IsNil)
+			c.Assert(envelope.RequestID, qt.Not(qt.Equals), "")
+
+			assertDocumented(c, envelope.Data, op.data)
+			if op.meta == nil {
+				c.Assert(envelope.Meta, qt.IsNil)
+				return
+			}
+			assertDocumented(c, envelope.Meta, op.meta)
+		})
+	}
+}
+
+// createForContract creates a resource by POSTing body to path and
+// returns its external ID
+func createForContract(t *testing.T, h http.Handler, path, body string) string {
+	t.Helper()
+
+	rr := Serve(t, h, NewRequest(t, http.MethodPost, path, strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST %s status = %d, body: %s", path, rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Data struct {
+			ExternalID string `json:"external_id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("json.Decode() error = %v", err)
+	}
+
+	return created.Data.ExternalID
+}
+
+// insertVersionsForContract adds the audittest versions of a movie
+// to store as the audit history of the movie with extlID, as the
+// MockWriter records no history
+func insertVersionsForContract(t *testing.T, store audit.Store, extlID string) {
+	t.Helper()
+
+	var records []audit.Record
+	for _, v := range audittest.NewVersions(t) {
+		records = append(records, audit.Record{
+			RequestID:    v.RequestID,
+			Method:       http.MethodPut,
+			Path:         "/api/v1/movies/" + extlID,
+			Username:     "otto.maddox711@gmail.com",
+			Status:       http.StatusOK,
+			Timestamp:    v.Timestamp,
+			ResourceType: "movies",
+			ResourceID:   extlID,
+			State:        v.State,
+		})
+	}
+	if err := store.Insert(context.Background(), records); err != nil {
+		t.Fatalf("store.Insert() error = %v", err)
+	}
+}
+
+// createSessionForContract creates a session and returns its ID and
+// secret
+func createSessionForContract(t *testing.T, h http.Handler) (id, secret string) {
+	t.Helper()
+
+	rr := Serve(t, h, NewRequest(t, http.MethodPost, "/api/v1/sessions", strings.NewReader(`{"name": "contract", "refresh_token": "1//refreshToken"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST /api/v1/sessions status = %d, body: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Data dto.SessionResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("json.Decode() error = %v", err)
+	}
+
+	return created.Data.ID, created.Data.Secret
+}
+
+// createExportJobForContract creates a job exporting movies and
+// returns its ID
+func createExportJobForContract(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	rr := Serve(t, h, NewRequest(t, http.MethodPost, "/api/v1/movies/export-jobs", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/v1/movies/export-jobs status = %d, body: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Data dto.ExportJobResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+		t.Fatalf("json.Decode() error = %v", err)
+	}
+
+	return created.Data.ID
+}
+
+// assertDocumented asserts b decodes into v, a pointer to a value of
+// the documented type, without unknown fields, and that v encodes
+// back to the same JSON, so no field of the type is missing from b
+func assertDocumented(c *qt.C, b []byte, v interface{}) {
+	c.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	c.Assert(dec.Decode(v), qt.IsNil, qt.Commentf("json: %s", b))
+
+	got, err := json.Marshal(v)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.JSONEquals, json.RawMessage(b))
+}
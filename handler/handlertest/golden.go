@@ -0,0 +1,115 @@
+package handlertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// updateGolden rewrites the golden files with the responses received
+// instead of comparing them, e.g. go test ./... -update-golden
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files with the responses received")
+
+// goldenDir is the directory, relative to the package being tested,
+// holding the golden files
+const goldenDir string = "testdata"
+
+// AssertGolden compares the status and body of the response recorded
+// in rr to the golden file testdata/<name>.golden, failing the test
+// if they differ. Run the tests with -update-golden to write the
+// golden files.
+//
+// JSON bodies are indented and the values which change on every
+// request are replaced before comparing: request_id by
+// "<request_id>" and fields ending in _timestamp by "<timestamp>".
+// The values of any fields named in normalize are replaced by
+// "<field name>" as well, e.g. for generated IDs.
+func AssertGolden(t *testing.T, rr *httptest.ResponseRecorder, name string, normalize ...string) {
+	t.Helper()
+
+	got, err := goldenResponse(rr.Code, rr.Body.Bytes(), normalize)
+	if err != nil {
+		t.Fatalf("golden response error = %v", err)
+	}
+
+	path := filepath.Join(goldenDir, name+".golden")
+
+	if *updateGolden {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll() error = %v", err)
+		}
+		if err = os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("os.WriteFile() error = %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v (run the tests with -update-golden to create it)", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("response does not match %s (run the tests with -update-golden if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// goldenResponse returns the status line and normalized body used
+// for golden files
+func goldenResponse(status int, body []byte, normalize []string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "status: %d\n\n", status)
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		// not JSON, the body is compared as is
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	keys := make(map[string]bool, len(normalize))
+	for _, k := range normalize {
+		keys[k] = true
+	}
+	v = normalizeJSON(v, keys)
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// normalizeJSON replaces the values of the request_id field, fields
+// ending in _timestamp and fields in keys throughout v
+func normalizeJSON(v interface{}, keys map[string]bool) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range vv {
+			switch {
+			case k == "request_id":
+				vv[k] = "<request_id>"
+			case strings.HasSuffix(k, "_timestamp"):
+				vv[k] = "<timestamp>"
+			case keys[k]:
+				vv[k] = "<" + k + ">"
+			default:
+				vv[k] = normalizeJSON(fv, keys)
+			}
+		}
+	case []interface{}:
+		for i := range vv {
+			vv[i] = normalizeJSON(vv[i], keys)
+		}
+	}
+	return v
+}
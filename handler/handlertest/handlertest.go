@@ -0,0 +1,336 @@
+// Package handlertest provides testing helper functions for the
+// handler package. The helpers assemble the same router and
+// middleware chain as the server, with mocks (or in-memory stores)
+// for each dependency, so handlers can be tested end to end.
+package handlertest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
+	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/export/exporttest"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
+	"github.com/gilcrest/go-api-basic/service/personsvc"
+)
+
+// Deps are the dependencies the handlers are built with. Any
+// dependency left nil is set to a default:
+//
+//	AccessTokenConverter  authtest.MockAccessTokenConverter
+//	Authorizer            authtest.MockAuthorizer
+//	ExternalIDGenerator   random.DefaultStringGenerator
+//	Clock                 clock.DefaultClock
+//	UUIDGenerator         idgen.DefaultUUIDGenerator using Clock
+//	Transactor, Selector  an empty memstore.MovieStore (the same one)
+//	PersonTransactor,
+//	PersonSelector        an empty memstore.PersonStore (the same one)
+//	                      finding movies with Selector
+//	QuotaTracker          quotatest.MockTracker
+//	Eraser                a memstore.UserEraser with no users, using
+//	                      Clock
+//	SessionStore          an empty memstore.SessionStore
+//	TokenRefresher        auth.StaticTokenRefresher
+//	ExportJobStore        an empty memstore.ExportJobStore
+//	ExportBucket          an empty exporttest.Bucket
+//	ExportURLExpiry       15 minutes
+//	AuditStore            an empty memstore.AuditStore
+//	AuditWriter           audittest.MockWriter
+//	ErrorReporter         errs.NopReporter
+//	Pinger                memstore.Pinger
+//	Logger                a logger writing to os.Stdout
+type Deps struct {
+	AccessTokenConverter auth.AccessTokenConverter
+	Authorizer           auth.Authorizer
+	ExternalIDGenerator  random.ExternalIDGenerator
+	Clock                clock.Clock
+	UUIDGenerator        idgen.UUIDGenerator
+	Transactor           moviestore.Transactor
+	Selector             moviestore.Selector
+	PersonTransactor     personstore.Transactor
+	PersonSelector       personstore.Selector
+	QuotaTracker         quota.Tracker
+	Eraser               user.Eraser
+	SessionStore         auth.SessionStore
+	TokenRefresher       auth.TokenRefresher
+	ExportJobStore       export.JobStore
+	ExportBucket         export.Bucket
+	ExportURLExpiry      handler.ExportURLExpiry
+	AuditStore           audit.Store
+	AuditWriter          audit.Writer
+	ErrorReporter        errs.ErrorReporter
+	Pinger               pingstore.Pinger
+	CachePolicies        handler.CachePolicies
+	Pagination           handler.Pagination
+	DecodeOptions        handler.DecodeOptions
+	EncodeOptions        handler.EncodeOptions
+	ValidationPolicy     movie.ValidationPolicy
+	Logger               *zerolog.Logger
+}
+
+// withDefaults returns d with any nil dependency set to its default
+func (d Deps) withDefaults(t testing.TB) Deps {
+	t.Helper()
+
+	if d.AccessTokenConverter == nil {
+		d.AccessTokenConverter = authtest.NewMockAccessTokenConverter(t)
+	}
+	if d.Authorizer == nil {
+		d.Authorizer = authtest.NewMockAuthorizer(t)
+	}
+	if d.ExternalIDGenerator == nil {
+		d.ExternalIDGenerator = random.DefaultStringGenerator{}
+	}
+	if d.Clock == nil {
+		d.Clock = clock.DefaultClock{}
+	}
+	if d.UUIDGenerator == nil {
+		d.UUIDGenerator = idgen.DefaultUUIDGenerator{Clock: d.Clock}
+	}
+	if d.Transactor == nil || d.Selector == nil {
+		ms := memstore.NewMovieStore()
+		if d.Transactor == nil {
+			d.Transactor = ms
+		}
+		if d.Selector == nil {
+			d.Selector = ms
+		}
+	}
+	if d.PersonTransactor == nil || d.PersonSelector == nil {
+		ps := memstore.NewPersonStore(d.Selector)
+		if d.PersonTransactor == nil {
+			d.PersonTransactor = ps
+		}
+		if d.PersonSelector == nil {
+			d.PersonSelector = ps
+		}
+	}
+	if d.QuotaTracker == nil {
+		d.QuotaTracker = quotatest.NewMockTracker(t)
+	}
+	if d.Eraser == nil {
+		d.Eraser = memstore.NewUserEraser(memstore.NewUserStore(d.Clock), memstore.NewMovieStore(), memstore.NewPersonStore(nil), memstore.NewAuditStore(), memstore.NewCounter(), memstore.NewSessionStore(), memstore.NewExportJobStore(), d.Clock)
+	}
+	if d.SessionStore == nil {
+		d.SessionStore = memstore.NewSessionStore()
+	}
+	if d.TokenRefresher == nil {
+		d.TokenRefresher = auth.StaticTokenRefresher{Token: "mockAccessToken"}
+	}
+	if d.ExportJobStore == nil {
+		d.ExportJobStore = memstore.NewExportJobStore()
+	}
+	if d.ExportBucket == nil {
+		d.ExportBucket = exporttest.NewBucket()
+	}
+	if d.ExportURLExpiry == 0 {
+		d.ExportURLExpiry = handler.ExportURLExpiry(15 * time.Minute)
+	}
+	if d.AuditStore == nil {
+		d.AuditStore = memstore.NewAuditStore()
+	}
+	if d.AuditWriter == nil {
+		d.AuditWriter = audittest.NewMockWriter(t)
+	}
+	if d.ErrorReporter == nil {
+		d.ErrorReporter = errs.NopReporter{}
+	}
+	if d.Pinger == nil {
+		d.Pinger = memstore.Pinger{}
+	}
+	if d.Logger == nil {
+		lgr := logger.NewLogger(os.Stdout, true)
+		d.Logger = &lgr
+	}
+
+	return d
+}
+
+// NewHandlers returns every handler built with d, as well as the
+// RouteList used by the routes handler, which must be passed to
+// handler.NewMuxRouter. The export jobs started by the handlers are
+// stopped when the test completes.
+func NewHandlers(t testing.TB, d Deps) (handler.Handlers, *handler.RouteList) {
+	t.Helper()
+
+	d = d.withDefaults(t)
+
+	ms := moviesvc.Service{
+		Authorizer:          d.Authorizer,
+		ExternalIDGenerator: d.ExternalIDGenerator,
+		UUIDGenerator:       d.UUIDGenerator,
+		Clock:               d.Clock,
+		Transactor:          d.Transactor,
+		Selector:            d.Selector,
+		ValidationPolicy:    d.ValidationPolicy,
+		History:             d.AuditStore,
+	}
+
+	mh := handler.DefaultMovieHandlers{
+		Service:       ms,
+		QuotaTracker:  d.QuotaTracker,
+		CachePolicies: d.CachePolicies,
+		DecodeOptions: d.DecodeOptions,
+		Pagination:    d.Pagination,
+	}
+
+	ph := handler.DefaultPersonHandlers{
+		Service: personsvc.Service{
+			Authorizer:          d.Authorizer,
+			ExternalIDGenerator: d.ExternalIDGenerator,
+			Clock:               d.Clock,
+			Transactor:          d.PersonTransactor,
+			Selector:            d.PersonSelector,
+		},
+		QuotaTracker:  d.QuotaTracker,
+		DecodeOptions: d.DecodeOptions,
+	}
+
+	sh := handler.DefaultSessionHandlers{
+		Store:           d.SessionStore,
+		Refresher:       d.TokenRefresher,
+		SecretGenerator: random.DefaultStringGenerator{},
+		Clock:           d.Clock,
+		DecodeOptions:   d.DecodeOptions,
+	}
+
+	runner, stop := export.NewRunner(d.ExportBucket, d.ExportJobStore, d.Clock, *d.Logger)
+	t.Cleanup(stop)
+	eh := handler.DefaultExportJobHandlers{
+		Service:       ms,
+		Runner:        runner,
+		Store:         d.ExportJobStore,
+		QuotaTracker:  d.QuotaTracker,
+		Pagination:    d.Pagination,
+		DecodeOptions: d.DecodeOptions,
+		URLExpiry:     d.ExportURLExpiry,
+		Clock:         d.Clock,
+	}
+
+	rl := handler.NewRouteList()
+
+	return handler.Handlers{
+		CreateMovieHandler:     handler.ProvideCreateMovieHandler(mh),
+		FindMovieByIDHandler:   handler.ProvideFindMovieByIDHandler(mh),
+		MovieDiffHandler:       handler.ProvideMovieDiffHandler(mh),
+		FindAllMoviesHandler:   handler.ProvideFindAllMoviesHandler(mh),
+		FindMoviesByIDsHandler: handler.ProvideFindMoviesByIDsHandler(mh),
+		UpdateMovieHandler:     handler.ProvideUpdateMovieHandler(mh),
+		DeleteMovieHandler:     handler.ProvideDeleteMovieHandler(mh),
+		MovieStatsHandler:      handler.ProvideMovieStatsHandler(mh),
+		SuggestMoviesHandler:   handler.ProvideSuggestMoviesHandler(mh),
+		CountMoviesHandler:     handler.ProvideCountMoviesHandler(mh),
+		PersonHandlers: handler.PersonHandlers{
+			CreatePersonHandler:     handler.ProvideCreatePersonHandler(ph),
+			UpdatePersonHandler:     handler.ProvideUpdatePersonHandler(ph),
+			DeletePersonHandler:     handler.ProvideDeletePersonHandler(ph),
+			FindPersonByIDHandler:   handler.ProvideFindPersonByIDHandler(ph),
+			FindAllPeopleHandler:    handler.ProvideFindAllPeopleHandler(ph),
+			FindPersonMoviesHandler: handler.ProvideFindPersonMoviesHandler(ph),
+			AddPersonMovieHandler:   handler.ProvideAddPersonMovieHandler(ph),
+		},
+		UsageHandler: handler.ProvideUsageHandler(handler.DefaultUsageHandler{
+			QuotaTracker: d.QuotaTracker,
+		}),
+		EraseUserDataHandler: handler.ProvideEraseUserDataHandler(handler.DefaultUserHandlers{
+			Eraser: d.Eraser,
+		}),
+		CreateSessionHandler:        handler.ProvideCreateSessionHandler(sh),
+		SessionTokenHandler:         handler.ProvideSessionTokenHandler(sh),
+		DeleteSessionHandler:        handler.ProvideDeleteSessionHandler(sh),
+		CreateMovieExportJobHandler: handler.ProvideCreateMovieExportJobHandler(eh),
+		FindExportJobHandler:        handler.ProvideFindExportJobHandler(eh),
+		PermissionsHandler: handler.ProvidePermissionsHandler(handler.DefaultPermissionsHandler{
+			Authorizer: d.Authorizer,
+			RouteList:  rl,
+		}),
+		FindAuditRecordsHandler: handler.ProvideFindAuditRecordsHandler(handler.DefaultAuditHandler{
+			Store: d.AuditStore,
+		}),
+		FindRoutesHandler: handler.ProvideFindRoutesHandler(handler.DefaultRoutesHandler{
+			RouteList: rl,
+		}),
+		PingHandler:    handler.ProvidePingHandler(handler.DefaultPingHandler{Pinger: d.Pinger}),
+		MetricsHandler: handler.ProvideMetricsHandler(),
+	}, rl
+}
+
+// NewRouter returns the router used by the server, with the full
+// middleware chain, for handlers built with d
+func NewRouter(t testing.TB, d Deps) *mux.Router {
+	t.Helper()
+
+	d = d.withDefaults(t)
+	handlers, rl := NewHandlers(t, d)
+
+	am := handler.AuthMiddleware{
+		AccessTokenConverter: d.AccessTokenConverter,
+		Authorizer:           d.Authorizer,
+	}
+
+	return handler.NewMuxRouter(*d.Logger, handlers, am, d.AuditWriter, d.ErrorReporter, rl, d.EncodeOptions, d.DecodeOptions, nil, handler.Tenants{}, nil, false, false)
+}
+
+// NewServer starts an httptest.Server using the router from
+// NewRouter. The server is closed when the test completes.
+func NewServer(t testing.TB, d Deps) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(NewRouter(t, d))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// NewRequest returns a request for the path (starting with /api)
+// with the mock access token from authtest.NewAccessToken set to the
+// Authorization header. If body is not nil, the Content-Type header
+// is set to application/json.
+func NewRequest(t testing.TB, method, path string, body io.Reader) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, body)
+
+	at := authtest.NewAccessToken(t)
+	req.Header.Set("Authorization", at.TokenType+" "+at.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req
+}
+
+// Serve serves req using h and returns the recorded response
+func Serve(t testing.TB, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr
+}
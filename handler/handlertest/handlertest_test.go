@@ -0,0 +1,400 @@
+package handlertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
+	"github.com/gilcrest/go-api-basic/handler"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func TestNewRouter(t *testing.T) {
+	c := qt.New(t)
+
+	aw := audittest.NewMockWriter(t)
+	rtr := NewRouter(t, Deps{AuditWriter: aw})
+
+	type response struct {
+		Data dto.MovieResponse `json:"data"`
+	}
+
+	// create a movie in the in-memory store
+	body := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+	rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var created response
+	c.Assert(json.NewDecoder(rr.Body).Decode(&created), qt.IsNil)
+	c.Assert(created.Data.Title, qt.Equals, "Repo Man")
+
+	// the create was audited, with the movie created
+	events := aw.Events()
+	c.Assert(events, qt.HasLen, 1)
+	c.Assert(events[0].Action, qt.Equals, "create")
+	c.Assert(events[0].Resource.Type, qt.Equals, "movies")
+	c.Assert(events[0].Resource.ID, qt.Equals, created.Data.ExternalID)
+	c.Assert(events[0].Before, qt.IsNil)
+	c.Assert(string(events[0].After), qt.Contains, `"Title":"Repo Man"`)
+
+	// and can be read back through the same router
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies/"+created.Data.ExternalID, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var found response
+	c.Assert(json.NewDecoder(rr.Body).Decode(&found), qt.IsNil)
+	c.Assert(found.Data, qt.Equals, created.Data)
+}
+
+func TestNewRouter_personMovies(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{})
+
+	// create a movie and a person in the in-memory stores
+	body := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+	rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	var m struct {
+		Data dto.MovieResponse `json:"data"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&m), qt.IsNil)
+
+	rr = Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/people", strings.NewReader(`{"name": "Alex Cox"}`)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	var p struct {
+		Data dto.PersonResponse `json:"data"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&p), qt.IsNil)
+
+	// the person has directed no movies yet
+	moviesPath := "/api/v1/people/" + p.Data.ExternalID + "/movies"
+	var directed struct {
+		Data []dto.MovieResponse `json:"data"`
+	}
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, moviesPath, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(json.NewDecoder(rr.Body).Decode(&directed), qt.IsNil)
+	c.Assert(directed.Data, qt.HasLen, 0)
+
+	// set the person as the director of the movie
+	rr = Serve(t, rtr, NewRequest(t, http.MethodPut, moviesPath+"/"+m.Data.ExternalID, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, moviesPath, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(json.NewDecoder(rr.Body).Decode(&directed), qt.IsNil)
+	c.Assert(directed.Data, qt.DeepEquals, []dto.MovieResponse{m.Data})
+
+	// a movie which does not exist cannot be linked
+	rr = Serve(t, rtr, NewRequest(t, http.MethodPut, moviesPath+"/notAMovie", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusNotFound, qt.Commentf("body: %s", rr.Body.String()))
+}
+
+func TestNewRouter_dryRun(t *testing.T) {
+	c := qt.New(t)
+
+	aw := audittest.NewMockWriter(t)
+	rtr := NewRouter(t, Deps{AuditWriter: aw})
+
+	dryRun := func(req *http.Request) *http.Request {
+		req.Header.Set(handler.DryRunHeader, "true")
+		return req
+	}
+	type response struct {
+		Data dto.MovieResponse `json:"data"`
+	}
+
+	// a dry run create responds with the movie which would have been
+	// created, which is neither kept nor audited
+	body := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+	rr := Serve(t, rtr, dryRun(NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body))))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(rr.Header().Get(handler.DryRunHeader), qt.Equals, "true")
+	var created response
+	c.Assert(json.NewDecoder(rr.Body).Decode(&created), qt.IsNil)
+	c.Assert(created.Data.Title, qt.Equals, "Repo Man")
+	c.Assert(aw.Events(), qt.HasLen, 0)
+
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies/"+created.Data.ExternalID, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusNotFound)
+
+	// a dry run is validated as usual
+	rr = Serve(t, rtr, dryRun(NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(`{"title": "Repo Man"}`))))
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest, qt.Commentf("body: %s", rr.Body.String()))
+
+	// a dry run delete leaves the movie in place
+	rr = Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(json.NewDecoder(rr.Body).Decode(&created), qt.IsNil)
+	moviePath := "/api/v1/movies/" + created.Data.ExternalID
+
+	rr = Serve(t, rtr, dryRun(NewRequest(t, http.MethodDelete, moviePath, nil)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	rr = Serve(t, rtr, dryRun(NewRequest(t, http.MethodDelete, "/api/v1/movies/notAMovie", nil)))
+	c.Assert(rr.Code, qt.Equals, http.StatusNotFound)
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, moviePath, nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	// only the create which was made is audited
+	c.Assert(aw.Events(), qt.HasLen, 1)
+}
+
+func TestNewRouter_unmatched(t *testing.T) {
+	rtr := NewRouter(t, Deps{})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{"no route", http.MethodGet, "/api/v1/actors", http.StatusNotFound, "route_not_found", ""},
+		{"not under api", http.MethodGet, "/v1/movies", http.StatusNotFound, "route_not_found", ""},
+		{"method not allowed", http.MethodPatch, "/api/v1/movies/abc", http.StatusMethodNotAllowed, "method_not_allowed", "DELETE, GET, PUT"},
+		{"method not allowed on list", http.MethodDelete, "/api/v1/movies", http.StatusMethodNotAllowed, "method_not_allowed", "GET, POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rr := Serve(t, rtr, NewRequest(t, tt.method, tt.target, nil))
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus, qt.Commentf("body: %s", rr.Body.String()))
+			c.Assert(rr.Header().Get("Content-Type"), qt.Equals, "application/json")
+			c.Assert(rr.Header().Get("Allow"), qt.Equals, tt.wantAllow)
+
+			var er errs.ErrResponse
+			c.Assert(json.NewDecoder(rr.Body).Decode(&er), qt.IsNil)
+			c.Assert(er.Error.Code, qt.Equals, tt.wantCode)
+			c.Assert(er.Error.RequestID, qt.Not(qt.Equals), "")
+			c.Assert(er.Error.RequestID, qt.Equals, rr.Header().Get(errs.RequestIDHeader))
+		})
+	}
+}
+
+func TestNewRouter_suggest(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{})
+
+	for _, title := range []string{"Repo Man", "Blade Runner"} {
+		body := `{"title": "` + title + `", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+		rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+		c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	}
+
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies/suggest?q=rep", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var got struct {
+		Data []dto.MovieSuggestionResponse `json:"data"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&got), qt.IsNil)
+	c.Assert(got.Data, qt.HasLen, 1)
+	c.Assert(got.Data[0].Title, qt.Equals, "Repo Man")
+
+	// q is required
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies/suggest", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest, qt.Commentf("body: %s", rr.Body.String()))
+}
+
+func TestNewRouter_count(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{})
+
+	for _, title := range []string{"Repo Man", "Blade Runner"} {
+		body := `{"title": "` + title + `", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+		rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+		c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	}
+
+	for _, path := range []string{"/api/v1/movies/count", "/api/v1/movies/count?mode=exact"} {
+		rr := Serve(t, rtr, NewRequest(t, http.MethodGet, path, nil))
+		c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+		var got struct {
+			Data dto.MovieCountResponse `json:"data"`
+		}
+		c.Assert(json.NewDecoder(rr.Body).Decode(&got), qt.IsNil)
+		c.Assert(got.Data, qt.DeepEquals, dto.MovieCountResponse{Count: 2})
+	}
+
+	// mode must be exact or estimate
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies/count?mode=guess", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest, qt.Commentf("body: %s", rr.Body.String()))
+}
+
+func TestNewRouter_filter(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{})
+
+	for _, m := range []struct {
+		title, rated string
+		runTime      int
+	}{{"Repo Man", "R", 92}, {"Sid and Nancy", "R", 112}, {"The Muppet Movie", "G", 95}} {
+		body := fmt.Sprintf(`{"title": %q, "rated": %q, "release_date": "1984-03-02T00:00:00Z", "run_time": %d, "director": "Alex Cox", "writer": "Alex Cox"}`, m.title, m.rated, m.runTime)
+		rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+		c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	}
+
+	q := url.Values{"filter": {"rated==R;run_time=gt=100"}}
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies?"+q.Encode(), nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var got struct {
+		Data []dto.MovieResponse `json:"data"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&got), qt.IsNil)
+	c.Assert(got.Data, qt.HasLen, 1)
+	c.Assert(got.Data[0].Title, qt.Equals, "Sid and Nancy")
+
+	// an invalid filter is a bad request
+	q = url.Values{"filter": {"budget=gt=100"}}
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies?"+q.Encode(), nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest, qt.Commentf("body: %s", rr.Body.String()))
+}
+
+func TestNewRouter_camelCase(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{EncodeOptions: handler.EncodeOptions{FieldNaming: handler.CamelCase}})
+
+	body := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+	rr := Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(rr.Body.String(), qt.Contains, `"releaseDate":"1984-03-02T00:00:00Z"`)
+
+	// the streamed list is renamed as well
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(rr.Body.String(), qt.Contains, `"requestId":`)
+	c.Assert(rr.Body.String(), qt.Contains, `"runTime":92`)
+	c.Assert(rr.Body.String(), qt.Contains, `"totalCount":1`)
+	c.Assert(rr.Body.String(), qt.Not(qt.Contains), `"release_date"`)
+}
+
+func TestNewRouter_emptyList(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{})
+
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(rr.Body.String(), qt.Contains, `"data":[]`)
+}
+
+func TestNewRouter_noEnvelope(t *testing.T) {
+	c := qt.New(t)
+
+	rtr := NewRouter(t, Deps{EncodeOptions: handler.EncodeOptions{NoEnvelope: true}})
+
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(rr.Body.String(), qt.Equals, "[]\n")
+
+	body := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+	rr = Serve(t, rtr, NewRequest(t, http.MethodPost, "/api/v1/movies", strings.NewReader(body)))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var created dto.MovieResponse
+	c.Assert(json.NewDecoder(rr.Body).Decode(&created), qt.IsNil)
+	c.Assert(created.Title, qt.Equals, "Repo Man")
+
+	rr = Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var list []dto.MovieResponse
+	c.Assert(json.NewDecoder(rr.Body).Decode(&list), qt.IsNil)
+	c.Assert(list, qt.HasLen, 1)
+	c.Assert(list[0].ExternalID, qt.Equals, created.ExternalID)
+}
+
+func TestNewRouter_envelope(t *testing.T) {
+	c := qt.New(t)
+
+	e, err := handler.ParseEnvelope("request_id=trace_id,path=-,meta=pagination")
+	c.Assert(err, qt.IsNil)
+	rtr := NewRouter(t, Deps{EncodeOptions: handler.EncodeOptions{Envelope: e}})
+
+	rr := Serve(t, rtr, NewRequest(t, http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+
+	var got map[string]json.RawMessage
+	c.Assert(json.Unmarshal(rr.Body.Bytes(), &got), qt.IsNil)
+	c.Assert(got, qt.HasLen, 3)
+	for _, k := range []string{"trace_id", "data", "pagination"} {
+		_, ok := got[k]
+		c.Assert(ok, qt.IsTrue, qt.Commentf("field %s not in %s", k, rr.Body.String()))
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	c := qt.New(t)
+
+	srv := NewServer(t, Deps{})
+
+	resp, err := http.Get(srv.URL + "/api/v1/ping")
+	c.Assert(err, qt.IsNil)
+	defer resp.Body.Close()
+
+	c.Assert(resp.StatusCode, qt.Equals, http.StatusOK)
+}
+
+func TestAssertGolden(t *testing.T) {
+	// the mocks return static data, so every response is the same on
+	// each run apart from the values normalized by AssertGolden
+	rtr := NewRouter(t, Deps{
+		ExternalIDGenerator: randomtest.NewMockStringGenerator(t),
+		Transactor:          moviestoretest.NewMockTransactor(t),
+		Selector:            moviestoretest.NewMockSelector(t),
+		AuditStore:          audittest.NewMockStore(t),
+	})
+
+	movieBody := `{"title": "Repo Man", "rated": "R", "release_date": "1984-03-02T00:00:00Z", "run_time": 92, "director": "Alex Cox", "writer": "Alex Cox"}`
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		normalize []string
+	}{
+		{"create_movie", http.MethodPost, "/api/v1/movies", movieBody, nil},
+		{"create_movie_invalid", http.MethodPost, "/api/v1/movies", `{"title": "Repo Man"`, nil},
+		{"update_movie", http.MethodPut, "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG", movieBody, nil},
+		{"delete_movie", http.MethodDelete, "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG", "", nil},
+		{"find_movie_by_id", http.MethodGet, "/api/v1/movies/kCBqDtyAkZIfdWjRDXQG", "", nil},
+		{"find_movies_by_ids", http.MethodGet, "/api/v1/movies?ids=RWn8zcaTA1gk3ybrBdQV,kCBqDtyAkZIfdWjRDXQG", "", []string{"id"}},
+		{"find_all_movies", http.MethodGet, "/api/v1/movies", "", []string{"id"}},
+		{"movie_stats", http.MethodGet, "/api/v1/movies/stats", "", nil},
+		{"usage", http.MethodGet, "/api/v1/users/me/usage", "", nil},
+		{"audit", http.MethodGet, "/api/v1/admin/audit", "", nil},
+		{"routes", http.MethodGet, "/api/v1/admin/routes", "", nil},
+		{"ping", http.MethodGet, "/api/v1/ping", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			rr := Serve(t, rtr, NewRequest(t, tt.method, tt.path, body))
+
+			AssertGolden(t, rr, tt.name, tt.normalize...)
+		})
+	}
+}
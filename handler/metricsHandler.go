@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opencensus.io/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+// MetricsHandler is a Handler which serves application metrics
+// in the Prometheus text exposition format
+type MetricsHandler http.Handler
+
+// ProvideMetricsHandler is a provider for the MetricsHandler for wire
+func ProvideMetricsHandler() MetricsHandler {
+	return metrics.DefaultRegistry.Handler()
+}
+
+// requestDuration is the latency of requests by route
+var requestDuration = metrics.NewHistogram("go_api_basic_http_request_duration_seconds",
+	"Latency of HTTP requests in seconds by method, route and status.",
+	metrics.DefBuckets, "method", "route", "status")
+
+// RequestMetricsHandler middleware records the latency of each
+// request in a histogram labeled by the route's path template (so
+// /api/v1/movies/{extlID} and not each movie). If the request is
+// part of a sampled trace, the trace ID is kept as the exemplar of
+// the latency bucket so a slow bucket can be followed to a trace.
+//
+// The trace span is started by the server (see gocloud.dev/server)
+// before the request reaches the router, so it is already in the
+// request context. RequestMetricsHandler must be added before
+// RecoveryHandler so a recovered panic is recorded as a 500.
+func RequestMetricsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			h.ServeHTTP(sr, r) // call original
+
+			d := time.Since(start).Seconds()
+			labels := []string{r.Method, routeTemplate(r), strconv.Itoa(sr.status)}
+			if sc, ok := sampledSpanContext(r); ok {
+				requestDuration.ObserveWithExemplar(d, metrics.Labels{"trace_id": sc.TraceID.String()}, labels...)
+				return
+			}
+			requestDuration.Observe(d, labels...)
+		})
+}
+
+// routeTemplate returns the path template of the route matched for
+// r, or "unmatched" if there is none
+func routeTemplate(r *http.Request) string {
+	if rt := mux.CurrentRoute(r); rt != nil {
+		if tpl, err := rt.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// sampledSpanContext returns the SpanContext of the trace span in the
+// request context, if there is one and it is sampled (and so will be
+// exported)
+func sampledSpanContext(r *http.Request) (trace.SpanContext, bool) {
+	span := trace.FromContext(r.Context())
+	if span == nil {
+		return trace.SpanContext{}, false
+	}
+	sc := span.SpanContext()
+	return sc, sc.IsSampled()
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"go.opencensus.io/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+func TestRequestMetricsHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		sampled    bool
+		wantStatus string
+	}{
+		{"no trace", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, false, "204"},
+		{"sampled trace", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, true, "204"},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}, true, "500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(ioutil.Discard, true)
+			rtr := mux.NewRouter()
+			rtr.Handle("/api/v1/movies/{extlID}", LoggerHandlerChain(lgr, alice.New()).
+				Append(RequestMetricsHandler).
+				Append(RecoveryHandler).
+				Then(tt.handler))
+
+			labels := []string{http.MethodGet, "/api/v1/movies/{extlID}", tt.wantStatus}
+			before := requestDuration.Count(labels...)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies/abc", nil)
+			var span *trace.Span
+			if tt.sampled {
+				// the server starts the span before the router
+				ctx := req.Context()
+				ctx, span = trace.StartSpan(ctx, "test", trace.WithSampler(trace.AlwaysSample()))
+				defer span.End()
+				req = req.WithContext(ctx)
+			}
+			rtr.ServeHTTP(httptest.NewRecorder(), req)
+
+			// the route is labeled by its template, not the path
+			c.Assert(requestDuration.Count(labels...)-before, qt.Equals, uint64(1))
+
+			if tt.sampled {
+				var buf bytes.Buffer
+				metrics.DefaultRegistry.WriteOpenMetrics(&buf)
+				c.Assert(buf.String(), qt.Contains, `# {trace_id="`+span.SpanContext().TraceID.String()+`"}`)
+			}
+		})
+	}
+}
@@ -1,17 +1,25 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gilcrest/go-api-basic/datastore/moviestore"
-	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
 	"github.com/gilcrest/go-api-basic/domain/movie"
-	"github.com/gilcrest/go-api-basic/domain/random"
-	"github.com/google/uuid"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/hlog"
 )
 
@@ -27,129 +35,66 @@ func ProvideCreateMovieHandler(h DefaultMovieHandlers) CreateMovieHandler {
 // DefaultMovieHandlers are the default handlers for CRUD operations
 // for a Movie. Each method on the struct is a separate handler.
 type DefaultMovieHandlers struct {
-	AccessTokenConverter  auth.AccessTokenConverter
-	Authorizer            auth.Authorizer
-	RandomStringGenerator random.StringGenerator
-	Transactor            moviestore.Transactor
-	Selector              moviestore.Selector
+	Service       moviesvc.Service
+	QuotaTracker  quota.Tracker
+	CachePolicies CachePolicies
+	DecodeOptions DecodeOptions
+	Pagination    Pagination
 }
 
-// CreateMovie is a HandlerFunc used to create a Movie
-func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request) {
-	// createMovieRequestBody is the request struct for Create
-	type createMovieRequestBody struct {
-		Title    string `json:"title"`
-		Rated    string `json:"rated"`
-		Released string `json:"release_date"`
-		RunTime  int    `json:"run_time"`
-		Director string `json:"director"`
-		Writer   string `json:"writer"`
-	}
-
-	// CreateMovieResponse is the response struct for a Movie
-	type createMovieResponse struct {
-		ExternalID      string `json:"external_id"`
-		Title           string `json:"title"`
-		Rated           string `json:"rated"`
-		Released        string `json:"release_date"`
-		RunTime         int    `json:"run_time"`
-		Director        string `json:"director"`
-		Writer          string `json:"writer"`
-		CreateUsername  string `json:"create_username"`
-		CreateTimestamp string `json:"create_timestamp"`
-		UpdateUsername  string `json:"update_username"`
-		UpdateTimestamp string `json:"update_timestamp"`
+// newMovieInput converts the request body to create or update a
+// Movie to the input for the movie service
+func newMovieInput(rb *dto.MovieRequest) moviesvc.MovieInput {
+	return moviesvc.MovieInput{
+		Title:    rb.Title,
+		Rated:    rb.Rated,
+		Released: rb.Released,
+		RunTime:  rb.RunTime,
+		Director: rb.Director,
+		Writer:   rb.Writer,
 	}
+}
 
+// CreateMovie is a HandlerFunc used to create a Movie
+func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request) {
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	// Declare requestBody as an instance of createMovieRequestBody
-	rb := new(createMovieRequestBody)
+	// Declare requestBody as an instance of dto.MovieRequest
+	rb := new(dto.MovieRequest)
 
-	// Decode JSON HTTP request body into a Decoder type
-	// and unmarshal that into the MovieRequest struct in the
-	// AddMovieHandler
-	err = json.NewDecoder(r.Body).Decode(&rb)
+	// Decode JSON HTTP request body into the dto.MovieRequest
+	// struct. decodeJSON determines if body is nil, json is malformed,
+	// has unknown fields (when strict) or any other error
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
 	defer r.Body.Close()
-	// Call DecoderErr to determine if body is nil, json is malformed
-	// or any other error
-	err = DecoderErr(err)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	extlID, err := h.RandomStringGenerator.CryptoString(15)
+	// Create the Movie using the movie service, which authorizes
+	// the user, generates the IDs, validates and stores the Movie
+	m, err := h.Service.Create(ctx, u, newMovieInput(rb))
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	// Call the NewMovie method to perform domain business logic
-	m, err := movie.NewMovie(uuid.New(), extlID, u)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	m, err = m.SetReleased(rb.Released)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-	m.SetTitle(rb.Title).
-		SetRated(rb.Rated).
-		SetRunTime(rb.RunTime).
-		SetDirector(rb.Director).
-		SetWriter(rb.Writer)
-
-	err = m.IsValid()
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	// Call the Create method of the Transactor to insert data to
-	// the database (unless mocked, of course). If an error occurs,
-	// rollback the transaction
-	err = h.Transactor.Create(ctx, m)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	cmr := createMovieResponse{
-		ExternalID:      m.ExternalID,
-		Title:           m.Title,
-		Rated:           m.Rated,
-		Released:        m.Released.Format(time.RFC3339),
-		RunTime:         m.RunTime,
-		Director:        m.Director,
-		Writer:          m.Writer,
-		CreateUsername:  m.CreateUser.Email,
-		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
-		UpdateUsername:  m.UpdateUser.Email,
-		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
-	}
+	cmr := dto.NewMovieResponse(m)
 
 	// Populate the response
 	response, err := NewStandardResponse(r, cmr)
@@ -159,7 +104,7 @@ func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(*response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
@@ -178,47 +123,17 @@ type UpdateMovieHandler http.Handler
 // UpdateMovie handles PUT requests for the /movies/{id} endpoint
 // and updates the given movie
 func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request) {
-	// updateMovieRequestBody is the request struct for Update
-	type updateMovieRequestBody struct {
-		Title    string `json:"title"`
-		Rated    string `json:"rated"`
-		Released string `json:"release_date"`
-		RunTime  int    `json:"run_time"`
-		Director string `json:"director"`
-		Writer   string `json:"writer"`
-	}
-
-	// updateMovieResponse is the response struct for a Movie
-	type updateMovieResponse struct {
-		ExternalID      string `json:"external_id"`
-		Title           string `json:"title"`
-		Rated           string `json:"rated"`
-		Released        string `json:"release_date"`
-		RunTime         int    `json:"run_time"`
-		Director        string `json:"director"`
-		Writer          string `json:"writer"`
-		CreateUsername  string `json:"create_username"`
-		CreateTimestamp string `json:"create_timestamp"`
-		UpdateUsername  string `json:"update_username"`
-		UpdateTimestamp string `json:"update_timestamp"`
-	}
-
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -230,64 +145,27 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	extlid := vars["extlID"]
 
-	// Declare rb as an instance of updateMovieRequestBody
-	rb := new(updateMovieRequestBody)
+	// Declare rb as an instance of dto.MovieRequest
+	rb := new(dto.MovieRequest)
 
-	// Decode JSON HTTP request body into a Decoder type
-	// and unmarshal that into requestData
-	err = json.NewDecoder(r.Body).Decode(&rb)
+	// Decode JSON HTTP request body into the dto.MovieRequest
+	// struct. decodeJSON determines if body is nil, json is malformed,
+	// has unknown fields (when strict) or any other error
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
 	defer r.Body.Close()
-	// Call DecoderErr to determine if body is nil, json is malformed
-	// or any other error
-	err = DecoderErr(err)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	// Convert request into a Movie struct
-	m := new(movie.Movie)
-	m.SetExternalID(extlid)
-	m.SetTitle(rb.Title)
-	m.SetRated(rb.Rated)
-	m, err = m.SetReleased(rb.Released)
+	// Update the Movie using the movie service
+	m, err := h.Service.Update(ctx, u, extlid, newMovieInput(rb))
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
-	m.SetRunTime(rb.RunTime)
-	m.SetDirector(rb.Director)
-	m.SetWriter(rb.Writer)
-	m.SetUpdateUser(u)
-	m.SetUpdateTime()
 
-	err = m.IsValid()
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	// Call the Update method of the Transactor to update the record
-	// in the database.
-	err = h.Transactor.Update(ctx, m)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	mr := updateMovieResponse{
-		ExternalID:      m.ExternalID,
-		Title:           m.Title,
-		Rated:           m.Rated,
-		Released:        m.Released.Format(time.RFC3339),
-		RunTime:         m.RunTime,
-		Director:        m.Director,
-		Writer:          m.Writer,
-		CreateUsername:  m.CreateUser.Email,
-		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
-		UpdateUsername:  m.UpdateUser.Email,
-		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
-	}
+	mr := dto.NewMovieResponse(m)
 
 	// Populate the response
 	response, err := NewStandardResponse(r, mr)
@@ -297,7 +175,7 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(*response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
@@ -316,28 +194,17 @@ type DeleteMovieHandler http.Handler
 // DeleteMovie handles DELETE requests for the /movies/{id} endpoint
 // and updates the given movie
 func (h DefaultMovieHandlers) DeleteMovie(w http.ResponseWriter, r *http.Request) {
-	// deleteMovieResponse is the response struct for deleted Movies
-	type deleteMovieResponse struct {
-		ExternalID string `json:"extl_id"`
-		Deleted    bool   `json:"deleted"`
-	}
-
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -349,26 +216,15 @@ func (h DefaultMovieHandlers) DeleteMovie(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	extlid := vars["extlID"]
 
-	// Find the Movie by ID using the selector.FindByID method
-	// It's arguable I don't need to do this and can just send
-	// the external ID to the database Transactor directly instead,
-	// (I'd have to rework it slightly) but this way works as an
-	// example
-	m, err := h.Selector.FindByID(ctx, extlid)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	// Delete method of Transactor physically deletes the record
-	// from the DB, unless mocked
-	err = h.Transactor.Delete(ctx, m)
+	// Delete the Movie using the movie service, which finds the
+	// Movie first so a Movie which does not exist is a 404
+	m, err := h.Service.Delete(ctx, u, extlid)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	dmr := deleteMovieResponse{
+	dmr := dto.DeleteMovieResponse{
 		ExternalID: m.ExternalID,
 		Deleted:    true,
 	}
@@ -381,7 +237,7 @@ func (h DefaultMovieHandlers) DeleteMovie(w http.ResponseWriter, r *http.Request
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(*response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
@@ -400,78 +256,137 @@ type FindMovieByIDHandler http.Handler
 // FindByID handles GET requests for the /movies/{id} endpoint
 // and finds a movie by it's ID
 func (h DefaultMovieHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
-	// movieResponse is the response struct for a Movie
-	type movieResponse struct {
-		ExternalID      string `json:"external_id"`
-		Title           string `json:"title"`
-		Rated           string `json:"rated"`
-		Released        string `json:"release_date"`
-		RunTime         int    `json:"run_time"`
-		Director        string `json:"director"`
-		Writer          string `json:"writer"`
-		CreateUsername  string `json:"create_username"`
-		CreateTimestamp string `json:"create_timestamp"`
-		UpdateUsername  string `json:"update_username"`
-		UpdateTimestamp string `json:"update_timestamp"`
-	}
-
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. id is the external id given for the
+	// movie
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+
+	// Find the Movie by ID using the movie service
+	m, err := h.Service.FindByID(ctx, u, extlid)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Set the caching headers. If the client's copy is still
+	// current, a 304 Not Modified has been sent and we're done
+	if setCacheHeaders(w, r, h.CachePolicies.FindMovieByID, m.UpdateTime) {
+		return
+	}
+
+	mr := dto.NewMovieResponse(m)
+
+	// Populate the response
+	response, err := NewStandardResponse(r, mr)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// ProvideMovieDiffHandler is a provider for the MovieDiffHandler for
+// wire
+func ProvideMovieDiffHandler(h DefaultMovieHandlers) MovieDiffHandler {
+	return http.HandlerFunc(h.Diff)
+}
+
+// MovieDiffHandler is a Handler that compares two versions of a Movie
+type MovieDiffHandler http.Handler
+
+// Diff handles GET requests for the
+// /movies/{id}/history/{v1}/diff/{v2} endpoint and returns the
+// fields of the movie changed between versions v1 and v2, as
+// recorded in its audit history. Version 1 is the movie as it was
+// created and each successful update adds a version. The fields are
+// those the user is shown in a list of movies, so admins are shown
+// the changes to the internal fields too.
+func (h DefaultMovieHandlers) Diff(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	// gorilla mux Vars function returns the route variables for the
-	// current request, if any. id is the external id given for the
-	// movie
 	vars := mux.Vars(r)
 	extlid := vars["extlID"]
+	var numbers [2]int
+	for i, name := range []string{"v1", "v2"} {
+		numbers[i], err = strconv.Atoi(vars[name])
+		if err != nil || numbers[i] < 1 {
+			errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter(name), errors.Errorf("%s must be a version number, starting from 1", name)))
+			return
+		}
+	}
 
-	// Find the Movie by ID using the selector.FindByID method
-	m, err := h.Selector.FindByID(ctx, extlid)
+	from, to, err := h.Service.CompareVersions(ctx, u, extlid, numbers[0], numbers[1])
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	mr := movieResponse{
-		ExternalID:      m.ExternalID,
-		Title:           m.Title,
-		Rated:           m.Rated,
-		Released:        m.Released.Format(time.RFC3339),
-		RunTime:         m.RunTime,
-		Director:        m.Director,
-		Writer:          m.Writer,
-		CreateUsername:  m.CreateUser.Email,
-		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
-		UpdateUsername:  m.UpdateUser.Email,
-		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
+	// admins are shown the changes to the internal fields
+	shape, err := movieShape(ctx, h.Service, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	changes, err := dto.DiffMovies(from.Movie, to.Movie, shape)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+
+	dr := dto.MovieDiffResponse{
+		ExternalID: extlid,
+		From:       dto.NewMovieVersionResponse(from.Number, from.RequestID, from.Timestamp),
+		To:         dto.NewMovieVersionResponse(to.Number, to.RequestID, to.Timestamp),
+		Changes:    changes,
 	}
 
 	// Populate the response
-	response, err := NewStandardResponse(r, mr)
+	response, err := NewStandardResponse(r, dr)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(*response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
@@ -484,84 +399,437 @@ func ProvideFindAllMoviesHandler(h DefaultMovieHandlers) FindAllMoviesHandler {
 	return http.HandlerFunc(h.FindAllMovies)
 }
 
-// FindAllMoviesHandler is a Handler that returns the entire set of Movies
+// FindAllMoviesHandler is a Handler that returns a page of Movies
 type FindAllMoviesHandler http.Handler
 
 // FindAllMovies handles GET requests for the /movies endpoint and finds
-// all movies
+// a page of movies. The page is requested using the page and page_size
+// query parameters, pagination metadata is returned in the response
+// body and the links to other pages in the Link header. The movies
+// can be narrowed using an RSQL expression in the filter query
+// parameter, e.g. filter=rated==R;run_time=gt=90.
 func (h DefaultMovieHandlers) FindAllMovies(w http.ResponseWriter, r *http.Request) {
-	// movieResponse is the response struct for a Movie
-	type movieResponse struct {
-		ExternalID      string `json:"external_id"`
-		Title           string `json:"title"`
-		Rated           string `json:"rated"`
-		Released        string `json:"release_date"`
-		RunTime         int    `json:"run_time"`
-		Director        string `json:"director"`
-		Writer          string `json:"writer"`
-		CreateUsername  string `json:"create_username"`
-		CreateTimestamp string `json:"create_timestamp"`
-		UpdateUsername  string `json:"update_username"`
-		UpdateTimestamp string `json:"update_timestamp"`
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// get the page requested using the page and page_size query
+	// parameters, within the page limits of the route
+	page, err := newPageRequest(r, h.Pagination.limits(r))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	f, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// admins are shown the internal fields of the movies
+	shape, err := movieShape(ctx, h.Service, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Set the Cache-Control header only. Deleting a movie does not
+	// change the latest update timestamp of the remaining movies, so
+	// it cannot be used as Last-Modified for the list
+	setCacheHeaders(w, r, h.CachePolicies.FindAllMovies, time.Time{})
+
+	// The ETag is computed from the version of the list (the latest
+	// update timestamp and count of the matching movies), which a
+	// delete does change. If the client's copy is still current, a
+	// 304 Not Modified has been sent and we're done. The version is
+	// read before the page, so a change made in between only makes
+	// the ETag older than the body, and the next request gets the
+	// list again.
+	v, err := h.Service.ListVersion(ctx, u, f)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	if setETag(w, r, listETag(v, r, shape)) {
+		return
+	}
+
+	// Populate the response path and request ID, the movies are
+	// written to the response as they are read
+	response, err := NewStandardResponse(r, nil)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	lw := newListWriter(w, response)
+
+	// Stream the page of Movies using the movie service, each Movie
+	// is encoded and written as its row is scanned
+	total, err := h.Service.StreamPage(ctx, u, f, page.Size, page.Offset(), func(m *movie.Movie, total int) error {
+		// the Link header has to be set before the first write, the
+		// total is known once the first row is read
+		if !lw.Started() {
+			setLinkHeader(w, r, newPageMeta(page, total))
+		}
+		return lw.Write(dto.NewShapedMovieResponse(m, shape))
+	})
+	if err != nil {
+		streamErr(w, logger, lw, err)
+		return
+	}
+
+	meta := newPageMeta(page, total)
+	if !lw.Started() {
+		setLinkHeader(w, r, meta)
 	}
 
+	// End the list and add the pagination metadata
+	err = lw.Close(meta)
+	if err != nil {
+		streamErr(w, logger, lw, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// listETag returns the weak entity tag of a list of movies response:
+// a hash of the version of the list and of everything else the
+// response body depends on, i.e. the tenant, the query parameters
+// (page and filter), the shape of the movies and the Accept header
+// (field naming)
+func listETag(v movie.ListVersion, r *http.Request, shape dto.MovieShape) string {
+	tenant, _ := requestinfo.TenantFromContext(r.Context())
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s|%d|%d|%s|%v|%s", tenant, v.LastUpdate.UnixNano(), v.Count, r.URL.RawQuery, shape, r.Header.Get("Accept"))
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// movieShape returns the shape of the movies in a list of movies
+// response (or export) for u: admins see the internal fields of a
+// movie (its UUID and audit fields), anyone else the public fields
+// only
+func movieShape(ctx context.Context, s moviesvc.Service, u user.User) (dto.MovieShape, error) {
+	admin, err := s.IsAdmin(ctx, u)
+	if err != nil {
+		return dto.MovieShapePublic, err
+	}
+	if admin {
+		return dto.MovieShapeAdmin, nil
+	}
+	return dto.MovieShapePublic, nil
+}
+
+// parseFilter parses the RSQL expression s using the
+// movie.FilterFields. If s is empty, the filter is nil and every
+// movie matches.
+func parseFilter(s string) (*filter.Expr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return filter.Parse(s, movie.FilterFields)
+}
+
+// maxBatchIDs is the maximum number of external IDs which can be
+// requested at once from the FindMoviesByIDs handler
+const maxBatchIDs = 100
+
+// ProvideFindMoviesByIDsHandler is a provider for the
+// FindMoviesByIDsHandler for wire
+func ProvideFindMoviesByIDsHandler(h DefaultMovieHandlers) FindMoviesByIDsHandler {
+	return http.HandlerFunc(h.FindByIDs)
+}
+
+// FindMoviesByIDsHandler is a Handler that returns the Movies for a
+// set of external IDs
+type FindMoviesByIDsHandler http.Handler
+
+// FindByIDs handles GET requests for the /movies?ids={ids} endpoint
+// and finds the movies for a comma separated list of external IDs in
+// one query, saving clients a round trip per movie
+func (h DefaultMovieHandlers) FindByIDs(w http.ResponseWriter, r *http.Request) {
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	extlIDs, err := parseIDs(r.URL.Query().Get("ids"))
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	// Find the list of all Movies using the selector.FindAll method
-	movies, err := h.Selector.FindAll(ctx)
+	// Find the Movies for the list of IDs using the movie service
+	movies, err := h.Service.FindByIDs(ctx, u, extlIDs)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	var smr []movieResponse
-	for _, m := range movies {
-		mr := movieResponse{
-			ExternalID:      m.ExternalID,
-			Title:           m.Title,
-			Rated:           m.Rated,
-			Released:        m.Released.Format(time.RFC3339),
-			RunTime:         m.RunTime,
-			Director:        m.Director,
-			Writer:          m.Writer,
-			CreateUsername:  m.CreateUser.Email,
-			CreateTimestamp: m.CreateTime.Format(time.RFC3339),
-			UpdateUsername:  m.UpdateUser.Email,
-			UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
+	// admins are shown the internal fields of the movies
+	shape, err := movieShape(ctx, h.Service, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewShapedMovieResponses(movies, shape))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// parseIDs splits a comma separated list of IDs, removing blanks and
+// duplicates. At least one and at most maxBatchIDs IDs are allowed.
+func parseIDs(s string) ([]string, error) {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
 		}
-		smr = append(smr, mr)
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	switch {
+	case len(ids) == 0:
+		return nil, errs.E(errs.Validation, errs.Parameter("ids"), errs.MissingField("ids"))
+	case len(ids) > maxBatchIDs:
+		return nil, errs.E(errs.Validation, errs.Parameter("ids"), errors.Errorf("ids cannot have more than %d values", maxBatchIDs))
+	}
+
+	return ids, nil
+}
+
+// ProvideMovieStatsHandler is a provider for the
+// MovieStatsHandler for wire
+func ProvideMovieStatsHandler(h DefaultMovieHandlers) MovieStatsHandler {
+	return http.HandlerFunc(h.MovieStats)
+}
+
+// MovieStatsHandler is a Handler that returns aggregate statistics
+// for the set of Movies
+type MovieStatsHandler http.Handler
+
+// MovieStats handles GET requests for the /movies/stats endpoint and
+// returns counts of movies grouped by rating, decade and director
+func (h DefaultMovieHandlers) MovieStats(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Get the aggregate statistics using the movie service
+	stats, err := h.Service.Stats(ctx, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewMovieStatsResponse(stats))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// ProvideCountMoviesHandler is a provider for the
+// CountMoviesHandler for wire
+func ProvideCountMoviesHandler(h DefaultMovieHandlers) CountMoviesHandler {
+	return http.HandlerFunc(h.CountMovies)
+}
+
+// CountMoviesHandler is a Handler that returns the number of Movies
+type CountMoviesHandler http.Handler
+
+// CountMovies handles GET requests for the /movies/count endpoint
+// and returns the number of movies. With mode=estimate, the count is
+// estimated from the database's table statistics, which is much
+// faster for a large table than the default exact count. The
+// response says whether the count is an estimate.
+func (h DefaultMovieHandlers) CountMovies(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the mode is validated by QueryParamsHandler
+	mode := movie.CountExact
+	if m := r.URL.Query().Get("mode"); m != "" {
+		mode = movie.CountMode(m)
+	}
+
+	// Count the movies using the movie service
+	count, err := h.Service.Count(ctx, u, mode)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
 	}
 
 	// Populate the response
-	response, err := NewStandardResponse(r, smr)
+	response, err := NewStandardResponse(r, dto.NewMovieCountResponse(count))
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
 	}
 }
+
+const (
+	// defaultSuggestLimit is the number of suggestions returned by
+	// the SuggestMovies handler when no limit is given
+	defaultSuggestLimit = 10
+	// maxSuggestLimit is the maximum number of suggestions which can
+	// be requested from the SuggestMovies handler
+	maxSuggestLimit = 25
+)
+
+// ProvideSuggestMoviesHandler is a provider for the
+// SuggestMoviesHandler for wire
+func ProvideSuggestMoviesHandler(h DefaultMovieHandlers) SuggestMoviesHandler {
+	return http.HandlerFunc(h.Suggest)
+}
+
+// SuggestMoviesHandler is a Handler that returns Movies with a title
+// starting with a prefix
+type SuggestMoviesHandler http.Handler
+
+// Suggest handles GET requests for the /movies/suggest?q={prefix}
+// endpoint and returns the external ID and title of up to limit
+// movies with a title starting with the prefix, ignoring case. It is
+// meant for autocomplete, so only the title is matched, using the
+// title prefix index.
+func (h DefaultMovieHandlers) Suggest(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	prefix, limit, err := parseSuggestQuery(r.URL.Query())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Find the suggestions using the movie service
+	suggestions, err := h.Service.Suggest(ctx, u, prefix, limit)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewMovieSuggestionResponses(suggestions))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// parseSuggestQuery returns the prefix (q) and limit query
+// parameters for the Suggest handler. The prefix is required and the
+// limit must be between 1 and maxSuggestLimit, defaulting to
+// defaultSuggestLimit.
+func parseSuggestQuery(q url.Values) (prefix string, limit int, err error) {
+	prefix = strings.TrimSpace(q.Get("q"))
+	if prefix == "" {
+		return "", 0, errs.E(errs.Validation, errs.Parameter("q"), errs.MissingField("q"))
+	}
+
+	limit = defaultSuggestLimit
+	if l := q.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxSuggestLimit {
+			return "", 0, errs.E(errs.Validation, errs.Parameter("limit"), errors.Errorf("limit must be a number between 1 and %d", maxSuggestLimit))
+		}
+	}
+
+	return prefix, limit, nil
+}
@@ -4,38 +4,46 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	qt "github.com/frankban/quicktest"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/rs/zerolog/hlog"
 
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/idgen/idgentest"
 	"github.com/gilcrest/go-api-basic/domain/logger"
 	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
 	"github.com/gilcrest/go-api-basic/domain/random"
 	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
-	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
 )
 
 func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		// set environment variable NO_DB to true if you don't
-		// have database connectivity and this test will be skipped
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		// initialize quickest checker
 		c := qt.New(t)
 
@@ -58,26 +66,23 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            transactor,
-			Selector:              selector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          transactor,
+				Selector:            selector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
-		// setup request body using anonymous struct
-		requestBody := struct {
-			Title    string `json:"title"`
-			Rated    string `json:"rated"`
-			Released string `json:"release_date"`
-			RunTime  int    `json:"run_time"`
-			Director string `json:"director"`
-			Writer   string `json:"writer"`
-		}{
+		// setup request body using dto.MovieRequest
+		requestBody := dto.MovieRequest{
 			Title:    "Repo Man",
 			Rated:    "R",
 			Released: "1984-03-02T00:00:00Z",
@@ -131,6 +136,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesWrite)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(createMovieHandler)
@@ -142,32 +148,15 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// createMovieResponse is the response struct for a Movie
-		// the response struct is tucked inside the handler, so we
-		// have to recreate it here
-		type createMovieResponse struct {
-			ExternalID      string `json:"external_id"`
-			Title           string `json:"title"`
-			Rated           string `json:"rated"`
-			Released        string `json:"release_date"`
-			RunTime         int    `json:"run_time"`
-			Director        string `json:"director"`
-			Writer          string `json:"writer"`
-			CreateUsername  string `json:"create_username"`
-			CreateTimestamp string `json:"create_timestamp"`
-			UpdateUsername  string `json:"update_username"`
-			UpdateTimestamp string `json:"update_timestamp"`
-		}
-
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
 		// interface{} in the real struct (handler.StandardResponse),
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string              `json:"path"`
-			RequestID string              `json:"request_id"`
-			Data      createMovieResponse `json:"data"`
+			Path      string            `json:"path"`
+			RequestID string            `json:"request_id"`
+			Data      dto.MovieResponse `json:"data"`
 		}
 
 		// retrieve the mock User that is used for testing
@@ -177,7 +166,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		wantBody := standardResponse{
 			Path:      path,
 			RequestID: requestID,
-			Data: createMovieResponse{
+			Data: dto.MovieResponse{
 				ExternalID:      "superRandomString",
 				Title:           "Repo Man",
 				Rated:           "R",
@@ -227,32 +216,29 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		lgr := logger.NewLogger(os.Stdout, true)
 
 		// initialize MockTransactor for the moviestore
-		mockTransactor := newMockTransactor(t)
+		mockTransactor := moviestoretest.NewMockTransactor(t)
 
 		// initialize MockSelector for the moviestore
-		mockSelector := newMockSelector(t)
+		mockSelector := moviestoretest.NewMockSelector(t)
 
 		// initialize mockAccessTokenConverter
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomtest.NewMockStringGenerator(t),
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            mockTransactor,
-			Selector:              mockSelector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: randomtest.NewMockStringGenerator(t),
+				UUIDGenerator:       idgentest.NewMockUUIDGenerator(t),
+				Clock:               clocktest.NewMockClock(t),
+				Transactor:          mockTransactor,
+				Selector:            mockSelector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
-		// setup request body using anonymous struct
-		requestBody := struct {
-			Title    string `json:"title"`
-			Rated    string `json:"rated"`
-			Released string `json:"release_date"`
-			RunTime  int    `json:"run_time"`
-			Director string `json:"director"`
-			Writer   string `json:"writer"`
-		}{
+		// setup request body using dto.MovieRequest
+		requestBody := dto.MovieRequest{
 			Title:    "Repo Man",
 			Rated:    "R",
 			Released: "1984-03-02T00:00:00Z",
@@ -276,6 +262,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 
 		// add test access token
 		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+		req.Header.Add("Content-Type", "application/json")
 
 		// create middleware to extract the request ID from
 		// the request context for testing comparison
@@ -306,6 +293,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesWrite)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(createMovieHandler)
@@ -317,32 +305,15 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// createMovieResponse is the response struct for a Movie
-		// the response struct is tucked inside the handler, so we
-		// have to recreate it here
-		type createMovieResponse struct {
-			ExternalID      string `json:"external_id"`
-			Title           string `json:"title"`
-			Rated           string `json:"rated"`
-			Released        string `json:"release_date"`
-			RunTime         int    `json:"run_time"`
-			Director        string `json:"director"`
-			Writer          string `json:"writer"`
-			CreateUsername  string `json:"create_username"`
-			CreateTimestamp string `json:"create_timestamp"`
-			UpdateUsername  string `json:"update_username"`
-			UpdateTimestamp string `json:"update_timestamp"`
-		}
-
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
 		// interface{} in the real struct (handler.StandardResponse),
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string              `json:"path"`
-			RequestID string              `json:"request_id"`
-			Data      createMovieResponse `json:"data"`
+			Path      string            `json:"path"`
+			RequestID string            `json:"request_id"`
+			Data      dto.MovieResponse `json:"data"`
 		}
 
 		// retrieve the mock User that is used for testing
@@ -352,7 +323,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		wantBody := standardResponse{
 			Path:      path,
 			RequestID: requestID,
-			Data: createMovieResponse{
+			Data: dto.MovieResponse{
 				ExternalID:      "superRandomString",
 				Title:           "Repo Man",
 				Rated:           "R",
@@ -361,9 +332,9 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 				Director:        "Alex Cox",
 				Writer:          "Alex Cox",
 				CreateUsername:  u.Email,
-				CreateTimestamp: time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC).String(),
+				CreateTimestamp: clocktest.Time.Format(time.RFC3339),
 				UpdateUsername:  u.Email,
-				UpdateTimestamp: time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC).String(),
+				UpdateTimestamp: clocktest.Time.Format(time.RFC3339),
 			},
 		}
 
@@ -377,28 +348,14 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// Assert that there is no error after decoding the response body
 		c.Assert(err, qt.IsNil)
 
-		// quicktest uses Google's cmp library for DeepEqual comparisons. It
-		// has some great options included with it. Below is an example of
-		// ignoring certain fields...
-		ignoreFields := cmpopts.IgnoreFields(standardResponse{},
-			"Data.CreateTimestamp", "Data.UpdateTimestamp")
-
 		// Assert that the response body (gotBody) is as expected (wantBody).
-		// The Create/Update timestamps are ignored as they are always unique.
-		// I could put another interface into the domain logic to solve
-		// for this and may do so later.
-		c.Assert(gotBody, qt.CmpEquals(ignoreFields), wantBody)
+		// The Create/Update timestamps are the time of the mock Clock.
+		c.Assert(gotBody, qt.DeepEquals, wantBody)
 	})
 }
 
 func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		// set environment variable NO_DB to skip database
-		// dependent tests
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		// initialize quickest checker
 		c := qt.New(t)
 
@@ -429,26 +386,23 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            transactor,
-			Selector:              selector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          transactor,
+				Selector:            selector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
-		// setup request body using anonymous struct
-		requestBody := struct {
-			Title    string `json:"title"`
-			Rated    string `json:"rated"`
-			Released string `json:"release_date"`
-			RunTime  int    `json:"run_time"`
-			Director string `json:"director"`
-			Writer   string `json:"writer"`
-		}{
+		// setup request body using dto.MovieRequest
+		requestBody := dto.MovieRequest{
 			Title:    "Repo Man",
 			Rated:    "R",
 			Released: "1984-03-02T00:00:00Z",
@@ -502,6 +456,7 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesWrite)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(updateMovieHandler)
@@ -517,32 +472,15 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// updateMovieResponse is the response struct for updating a
-		// Movie. The response struct is tucked inside the handler,
-		// so we have to recreate it here
-		type updateMovieResponse struct {
-			ExternalID      string `json:"external_id"`
-			Title           string `json:"title"`
-			Rated           string `json:"rated"`
-			Released        string `json:"release_date"`
-			RunTime         int    `json:"run_time"`
-			Director        string `json:"director"`
-			Writer          string `json:"writer"`
-			CreateUsername  string `json:"create_username"`
-			CreateTimestamp string `json:"create_timestamp"`
-			UpdateUsername  string `json:"update_username"`
-			UpdateTimestamp string `json:"update_timestamp"`
-		}
-
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
 		// interface{} in the real struct (handler.StandardResponse),
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string              `json:"path"`
-			RequestID string              `json:"request_id"`
-			Data      updateMovieResponse `json:"data"`
+			Path      string            `json:"path"`
+			RequestID string            `json:"request_id"`
+			Data      dto.MovieResponse `json:"data"`
 		}
 
 		// retrieve the mock User that is used for testing
@@ -552,7 +490,7 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		wantBody := standardResponse{
 			Path:      path,
 			RequestID: requestID,
-			Data: updateMovieResponse{
+			Data: dto.MovieResponse{
 				//ExternalID:      "superRandomString",
 				Title:          "Repo Man",
 				Rated:          "R",
@@ -597,12 +535,6 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 
 func TestDefaultMovieHandlers_DeleteMovie(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		// set environment variable NO_DB to skip database
-		// dependent tests
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		// initialize quickest checker
 		c := qt.New(t)
 
@@ -625,19 +557,20 @@ func TestDefaultMovieHandlers_DeleteMovie(t *testing.T) {
 		// initialize the DefaultSelector for the moviestore
 		selector := moviestore.NewDefaultSelector(ds)
 
-		// initialize mockAccessTokenConverter
-		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
-
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            transactor,
-			Selector:              selector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          transactor,
+				Selector:            selector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
 		// setup path
@@ -678,6 +611,7 @@ func TestDefaultMovieHandlers_DeleteMovie(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesWrite)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(deleteMovieHandler)
@@ -693,30 +627,22 @@ func TestDefaultMovieHandlers_DeleteMovie(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// deleteMovieResponse is the response struct for deleting a
-		// Movie. The response struct is tucked inside the handler,
-		// so we have to recreate it here
-		type deleteMovieResponse struct {
-			ExternalID string `json:"extl_id"`
-			Deleted    bool   `json:"deleted"`
-		}
-
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
 		// interface{} in the real struct (handler.StandardResponse),
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string              `json:"path"`
-			RequestID string              `json:"request_id"`
-			Data      deleteMovieResponse `json:"data"`
+			Path      string                  `json:"path"`
+			RequestID string                  `json:"request_id"`
+			Data      dto.DeleteMovieResponse `json:"data"`
 		}
 
 		// setup the expected response data
 		wantBody := standardResponse{
 			Path:      path,
 			RequestID: requestID,
-			Data: deleteMovieResponse{
+			Data: dto.DeleteMovieResponse{
 				ExternalID: m.ExternalID,
 				Deleted:    true,
 			},
@@ -746,12 +672,6 @@ func TestDefaultMovieHandlers_DeleteMovie(t *testing.T) {
 
 func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		// set environment variable NO_DB to skip database
-		// dependent tests
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		// initialize quickest checker
 		c := qt.New(t)
 
@@ -780,26 +700,23 @@ func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            transactor,
-			Selector:              selector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          transactor,
+				Selector:            selector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
-		// setup request body using anonymous struct
-		requestBody := struct {
-			Title    string `json:"title"`
-			Rated    string `json:"rated"`
-			Released string `json:"release_date"`
-			RunTime  int    `json:"run_time"`
-			Director string `json:"director"`
-			Writer   string `json:"writer"`
-		}{
+		// setup request body using dto.MovieRequest
+		requestBody := dto.MovieRequest{
 			Title:    "Repo Man",
 			Rated:    "R",
 			Released: "1984-03-02T00:00:00Z",
@@ -853,6 +770,7 @@ func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesRead)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(findMovieByIDHandler)
@@ -868,32 +786,15 @@ func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// movieResponse is the response struct for a
-		// Movie. The response struct is tucked inside the handler,
-		// so we have to recreate it here
-		type movieResponse struct {
-			ExternalID      string `json:"external_id"`
-			Title           string `json:"title"`
-			Rated           string `json:"rated"`
-			Released        string `json:"release_date"`
-			RunTime         int    `json:"run_time"`
-			Director        string `json:"director"`
-			Writer          string `json:"writer"`
-			CreateUsername  string `json:"create_username"`
-			CreateTimestamp string `json:"create_timestamp"`
-			UpdateUsername  string `json:"update_username"`
-			UpdateTimestamp string `json:"update_timestamp"`
-		}
-
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
 		// interface{} in the real struct (handler.StandardResponse),
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string        `json:"path"`
-			RequestID string        `json:"request_id"`
-			Data      movieResponse `json:"data"`
+			Path      string            `json:"path"`
+			RequestID string            `json:"request_id"`
+			Data      dto.MovieResponse `json:"data"`
 		}
 
 		// retrieve the mock User that is used for testing
@@ -903,7 +804,7 @@ func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 		wantBody := standardResponse{
 			Path:      path,
 			RequestID: requestID,
-			Data: movieResponse{
+			Data: dto.MovieResponse{
 				ExternalID:     m.ExternalID,
 				Title:          "Repo Man",
 				Rated:          "R",
@@ -948,12 +849,6 @@ func TestDefaultMovieHandlers_FindByID(t *testing.T) {
 
 func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		// set environment variable NO_DB to skip database
-		// dependent tests
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		// initialize quickest checker
 		c := qt.New(t)
 
@@ -961,24 +856,25 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 		lgr := logger.NewLogger(os.Stdout, true)
 
 		// initialize MockTransactor for the moviestore
-		mockTransactor := newMockTransactor(t)
+		mockTransactor := moviestoretest.NewMockTransactor(t)
 
 		// initialize MockSelector for the moviestore
-		mockSelector := newMockSelector(t)
-
-		// initialize mockAccessTokenConverter
-		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
+		mockSelector := moviestoretest.NewMockSelector(t)
 
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            mockTransactor,
-			Selector:              mockSelector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          mockTransactor,
+				Selector:            mockSelector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
 		// setup path
@@ -1019,6 +915,7 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 		// setup full handler chain needed for request
 		h := LoggerHandlerChain(lgr, ac).
 			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesRead)).
 			Append(JSONContentTypeHandler).
 			Append(requestIDMiddleware).
 			Then(findAllMoviesHandler)
@@ -1034,22 +931,9 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
 
-		// movieResponse is the response struct for a
-		// Movie. The response struct is tucked inside the handler,
-		// so we have to recreate it here
-		type movieResponse struct {
-			ExternalID      string `json:"external_id"`
-			Title           string `json:"title"`
-			Rated           string `json:"rated"`
-			Released        string `json:"release_date"`
-			RunTime         int    `json:"run_time"`
-			Director        string `json:"director"`
-			Writer          string `json:"writer"`
-			CreateUsername  string `json:"create_username"`
-			CreateTimestamp string `json:"create_timestamp"`
-			UpdateUsername  string `json:"update_username"`
-			UpdateTimestamp string `json:"update_timestamp"`
-		}
+		// Assert the Link header has the first and last page links
+		wantLink := fmt.Sprintf(`<%[1]s?page=1&page_size=%[2]d>; rel="first", <%[1]s?page=1&page_size=%[2]d>; rel="last"`, path, DefaultPageLimits.DefaultSize)
+		c.Assert(rr.Header().Get("Link"), qt.Equals, wantLink)
 
 		// standardResponse is the standard response struct used for
 		// all response bodies, the Data field is actually an
@@ -1057,9 +941,10 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 		// but it's easiest to decode to JSON using a proper struct
 		// as below
 		type standardResponse struct {
-			Path      string          `json:"path"`
-			RequestID string          `json:"request_id"`
-			Data      []movieResponse `json:"data"`
+			Path      string              `json:"path"`
+			RequestID string              `json:"request_id"`
+			Data      []dto.MovieResponse `json:"data"`
+			Meta      PageMeta            `json:"meta"`
 		}
 
 		// get mocked slice of movies that should be returned
@@ -1068,9 +953,9 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 			t.Fatalf("mockSelector.FindAll error = %v", err)
 		}
 
-		var smr []movieResponse
+		var smr []dto.MovieResponse
 		for _, m := range movies {
-			mr := movieResponse{
+			mr := dto.MovieResponse{
 				ExternalID:      m.ExternalID,
 				Title:           m.Title,
 				Rated:           m.Rated,
@@ -1091,6 +976,12 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 			Path:      path,
 			RequestID: requestID,
 			Data:      smr,
+			Meta: PageMeta{
+				Page:       1,
+				PageSize:   DefaultPageLimits.DefaultSize,
+				TotalCount: len(movies),
+				TotalPages: 1,
+			},
 		}
 
 		// initialize standardResponse
@@ -1108,102 +999,497 @@ func TestDefaultMovieHandlers_FindAll(t *testing.T) {
 	})
 }
 
-// NewMockTransactor is an initializer for MockTransactor
-func newMockTransactor(t *testing.T) mockTransactor {
-	return mockTransactor{t: t}
-}
+func TestDefaultMovieHandlers_FindAll_conditional(t *testing.T) {
+	c := qt.New(t)
 
-// MockTransactor is a mock which satisfies the moviestore.Transactor
-// interface
-type mockTransactor struct {
-	t *testing.T
-}
+	dmh := DefaultMovieHandlers{
+		Service: moviesvc.Service{
+			Authorizer: authtest.NewMockAuthorizer(t),
+			Selector:   moviestoretest.NewMockSelector(t),
+		},
+		QuotaTracker: quotatest.NewMockTracker(t),
+	}
+	h := LoggerHandlerChain(logger.NewLogger(ioutil.Discard, true), alice.New()).
+		Append(AccessTokenHandler).
+		Append(newMockAuthHandler(t, scopeMoviesRead)).
+		Then(ProvideFindAllMoviesHandler(dmh))
 
-func (mt mockTransactor) Create(ctx context.Context, m *movie.Movie) error {
-	return nil
+	get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot+query, nil)
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	etag := rr.Header().Get("ETag")
+	c.Assert(strings.HasPrefix(etag, `W/"`), qt.IsTrue, qt.Commentf("ETag %s", etag))
+
+	// the list has not changed
+	rr = get("", etag)
+	c.Assert(rr.Code, qt.Equals, http.StatusNotModified)
+	c.Assert(rr.Body.Len(), qt.Equals, 0)
+	c.Assert(rr.Header().Get("ETag"), qt.Equals, etag)
+
+	// another page (or filter) is another response
+	rr = get("?page_size=1", etag)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("ETag"), qt.Not(qt.Equals), etag)
 }
 
-func (mt mockTransactor) Update(ctx context.Context, m *movie.Movie) error {
-	return nil
+func Test_listETag(t *testing.T) {
+	c := qt.New(t)
+
+	v := movie.ListVersion{LastUpdate: time.Date(2021, 1, 8, 6, 54, 0, 0, time.UTC), Count: 3}
+	r := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot+"?page=2", nil)
+	etag := listETag(v, r, dto.MovieShapePublic)
+
+	// the same list is the same tag
+	c.Assert(listETag(v, r, dto.MovieShapePublic), qt.Equals, etag)
+
+	// a movie created or updated
+	updated := v
+	updated.LastUpdate = updated.LastUpdate.Add(time.Second)
+	c.Assert(listETag(updated, r, dto.MovieShapePublic), qt.Not(qt.Equals), etag)
+
+	// a movie deleted
+	deleted := v
+	deleted.Count--
+	c.Assert(listETag(deleted, r, dto.MovieShapePublic), qt.Not(qt.Equals), etag)
+
+	// the internal fields shown to admins
+	c.Assert(listETag(v, r, dto.MovieShapeAdmin), qt.Not(qt.Equals), etag)
+
+	// camelCase field names
+	camel := r.Clone(r.Context())
+	camel.Header.Set("Accept", CamelCaseMediaType)
+	c.Assert(listETag(v, camel, dto.MovieShapePublic), qt.Not(qt.Equals), etag)
+
+	// the same version of another tenant's list
+	acme := r.WithContext(requestinfo.WithTenant(r.Context(), "acme"))
+	c.Assert(listETag(v, acme, dto.MovieShapePublic), qt.Not(qt.Equals), etag)
 }
 
-func (mt mockTransactor) Delete(ctx context.Context, m *movie.Movie) error {
-	return nil
+func TestDefaultMovieHandlers_FindByIDs(t *testing.T) {
+	t.Run("mock DB", func(t *testing.T) {
+		// initialize quickest checker
+		c := qt.New(t)
+
+		// initialize a zerolog Logger
+		lgr := logger.NewLogger(os.Stdout, true)
+
+		// initialize MockSelector for the moviestore
+		mockSelector := moviestoretest.NewMockSelector(t)
+
+		// initialize DefaultMovieHandlers
+		dmh := DefaultMovieHandlers{
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: random.DefaultStringGenerator{},
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          moviestoretest.NewMockTransactor(t),
+				Selector:            mockSelector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
+		}
+
+		// setup path
+		path := pathPrefix + moviesV1PathRoot
+
+		// form request using httptest
+		req := httptest.NewRequest(http.MethodGet, path+"?ids=RWn8zcaTA1gk3ybrBdQV,kCBqDtyAkZIfdWjRDXQG", nil)
+
+		// add test access token
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+		// create middleware to extract the request ID from
+		// the request context for testing comparison
+		var requestID string
+		requestIDMiddleware := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rID, ok := hlog.IDFromRequest(r)
+				if !ok {
+					t.Fatal("Request ID not set to request context")
+				}
+				requestID = rID.String()
+
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		// retrieve findMoviesByIDsHandler HTTP handler
+		findMoviesByIDsHandler := ProvideFindMoviesByIDsHandler(dmh)
+
+		// initialize ResponseRecorder to use with ServeHTTP as it
+		// satisfies ResponseWriter interface and records the response
+		// for testing
+		rr := httptest.NewRecorder()
+
+		// setup full handler chain needed for request
+		h := LoggerHandlerChain(lgr, alice.New()).
+			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesRead)).
+			Append(JSONContentTypeHandler).
+			Append(requestIDMiddleware).
+			Then(findMoviesByIDsHandler)
+
+		// call the handler ServeHTTP method to execute the request
+		// and record the response
+		h.ServeHTTP(rr, req)
+
+		// Assert that Response Status Code equals 200 (StatusOK)
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Path      string              `json:"path"`
+			RequestID string              `json:"request_id"`
+			Data      []dto.MovieResponse `json:"data"`
+		}
+
+		// get mocked slice of movies that should be returned
+		movies, err := mockSelector.FindByIDs(req.Context(), []string{"RWn8zcaTA1gk3ybrBdQV", "kCBqDtyAkZIfdWjRDXQG"})
+		if err != nil {
+			t.Fatalf("mockSelector.FindByIDs error = %v", err)
+		}
+
+		var smr []dto.MovieResponse
+		for _, m := range movies {
+			mr := dto.MovieResponse{
+				ExternalID:      m.ExternalID,
+				Title:           m.Title,
+				Rated:           m.Rated,
+				Released:        m.Released.Format(time.RFC3339),
+				RunTime:         m.RunTime,
+				Director:        m.Director,
+				Writer:          m.Writer,
+				CreateUsername:  m.CreateUser.Email,
+				CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+				UpdateUsername:  m.UpdateUser.Email,
+				UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
+			}
+			smr = append(smr, mr)
+		}
+
+		// setup the expected response data
+		wantBody := standardResponse{
+			Path:      path,
+			RequestID: requestID,
+			Data:      smr,
+		}
+
+		// initialize standardResponse
+		gotBody := standardResponse{}
+
+		// decode the response body into the standardResponse (gotBody)
+		err = DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+		defer rr.Result().Body.Close()
+
+		// Assert that there is no error after decoding the response body
+		c.Assert(err, qt.IsNil)
+
+		// Assert that the response body (gotBody) is as expected (wantBody).
+		c.Assert(gotBody, qt.DeepEquals, wantBody)
+		c.Assert(gotBody.Data[0].ExternalID, qt.Equals, "RWn8zcaTA1gk3ybrBdQV")
+	})
 }
 
-// NewMockSelector is an initializer for MockSelector
-func newMockSelector(t *testing.T) mockSelector {
-	return mockSelector{t: t}
+func TestDefaultMovieHandlers_FindByIDs_shape(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer auth.Authorizer
+		wantKeys   []string
+	}{
+		{"admin", authtest.NewMockAuthorizer(t), []string{
+			"create_timestamp", "create_username", "director", "external_id", "id", "rated",
+			"release_date", "run_time", "title", "update_timestamp", "update_username", "writer"}},
+		{"viewer", readOnlyAuthorizer{}, []string{
+			"director", "external_id", "rated", "release_date", "run_time", "title", "writer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			dmh := DefaultMovieHandlers{
+				Service: moviesvc.Service{
+					Authorizer: tt.authorizer,
+					Selector:   moviestoretest.NewMockSelector(t),
+				},
+				QuotaTracker: quotatest.NewMockTracker(t),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot+"?ids=RWn8zcaTA1gk3ybrBdQV", nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+
+			h := LoggerHandlerChain(logger.NewLogger(ioutil.Discard, true), alice.New()).
+				Append(AccessTokenHandler).
+				Append(newMockAuthHandler(t, scopeMoviesRead)).
+				Then(ProvideFindMoviesByIDsHandler(dmh))
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+			var body struct {
+				Data []map[string]interface{} `json:"data"`
+			}
+			c.Assert(json.NewDecoder(rr.Body).Decode(&body), qt.IsNil)
+			c.Assert(body.Data, qt.HasLen, 1)
+
+			var gotKeys []string
+			for k := range body.Data[0] {
+				gotKeys = append(gotKeys, k)
+			}
+			sort.Strings(gotKeys)
+			c.Assert(gotKeys, qt.DeepEquals, tt.wantKeys)
+		})
+	}
 }
 
-// MockSelector is a mock which satisfies the moviestore.Selector
-// interface
-type mockSelector struct {
-	t *testing.T
+func TestDefaultMovieHandlers_Diff(t *testing.T) {
+	tests := []struct {
+		name        string
+		authorizer  auth.Authorizer
+		path        string
+		wantStatus  int
+		wantParam   string
+		wantFields  []string
+		wantVersion [2]int
+	}{
+		{"admin", authtest.NewMockAuthorizer(t), "/kCBqDtyAkZIfdWjRDXQG/history/1/diff/2", http.StatusOK, "", []string{"run_time", "writer", "update_timestamp"}, [2]int{1, 2}},
+		{"viewer", readOnlyAuthorizer{}, "/kCBqDtyAkZIfdWjRDXQG/history/1/diff/2", http.StatusOK, "", []string{"run_time", "writer"}, [2]int{1, 2}},
+		{"backwards", readOnlyAuthorizer{}, "/kCBqDtyAkZIfdWjRDXQG/history/2/diff/1", http.StatusOK, "", []string{"run_time", "writer"}, [2]int{2, 1}},
+		{"same version", authtest.NewMockAuthorizer(t), "/kCBqDtyAkZIfdWjRDXQG/history/2/diff/2", http.StatusOK, "", []string{}, [2]int{2, 2}},
+		{"v1 not a number", authtest.NewMockAuthorizer(t), "/kCBqDtyAkZIfdWjRDXQG/history/one/diff/2", http.StatusBadRequest, "v1", nil, [2]int{}},
+		{"v2 zero", authtest.NewMockAuthorizer(t), "/kCBqDtyAkZIfdWjRDXQG/history/1/diff/0", http.StatusBadRequest, "v2", nil, [2]int{}},
+		{"no such version", authtest.NewMockAuthorizer(t), "/kCBqDtyAkZIfdWjRDXQG/history/1/diff/3", http.StatusNotFound, "v2", nil, [2]int{}},
+		{"no history", authtest.NewMockAuthorizer(t), "/notAMovie/history/1/diff/2", http.StatusNotFound, "", nil, [2]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			dmh := DefaultMovieHandlers{
+				Service: moviesvc.Service{
+					Authorizer: tt.authorizer,
+					History:    audittest.NewMockStore(t),
+				},
+				QuotaTracker: quotatest.NewMockTracker(t),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot+tt.path, nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+
+			// the route variables are set by a mux Router
+			rtr := mux.NewRouter()
+			rtr.Handle(pathPrefix+moviesV1PathRoot+"/{extlID}/history/{v1}/diff/{v2}",
+				LoggerHandlerChain(logger.NewLogger(ioutil.Discard, true), alice.New()).
+					Append(AccessTokenHandler).
+					Append(newMockAuthHandler(t, scopeMoviesRead)).
+					Then(ProvideMovieDiffHandler(dmh)))
+			rtr.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus, qt.Commentf("body: %s", rr.Body.String()))
+			if tt.wantStatus != http.StatusOK {
+				if tt.wantParam != "" {
+					c.Assert(rr.Body.String(), qt.Contains, `"param":"`+tt.wantParam+`"`)
+				}
+				return
+			}
+
+			var body struct {
+				Data dto.MovieDiffResponse `json:"data"`
+			}
+			c.Assert(json.NewDecoder(rr.Body).Decode(&body), qt.IsNil)
+			c.Assert(body.Data.ExternalID, qt.Equals, "kCBqDtyAkZIfdWjRDXQG")
+			c.Assert([2]int{body.Data.From.Version, body.Data.To.Version}, qt.Equals, tt.wantVersion)
+
+			gotFields := make([]string, 0)
+			for _, ch := range body.Data.Changes {
+				gotFields = append(gotFields, ch.Field)
+			}
+			c.Assert(gotFields, qt.DeepEquals, tt.wantFields)
+		})
+	}
 }
 
-// FindByID mocks finding a movie by External ID
-func (ms mockSelector) FindByID(ctx context.Context, s string) (*movie.Movie, error) {
-
-	// get test user
-	u := usertest.NewUser(ms.t)
-
-	// mock create/update timestamp
-	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
-
-	return &movie.Movie{
-		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
-		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
-		Title:      "Repo Man",
-		Rated:      "R",
-		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
-		RunTime:    92,
-		Director:   "Alex Cox",
-		Writer:     "Alex Cox",
-		CreateUser: u,
-		CreateTime: cuTime,
-		UpdateUser: u,
-		UpdateTime: cuTime,
-	}, nil
+func Test_parseIDs(t *testing.T) {
+	c := qt.New(t)
+
+	// build a list of one more unique ID than is allowed
+	ids := make([]string, 0, maxBatchIDs+1)
+	for i := 0; i <= maxBatchIDs; i++ {
+		ids = append(ids, fmt.Sprintf("id%d", i))
+	}
+	tooMany := strings.Join(ids, ",")
+
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"typical", "a,b,c", []string{"a", "b", "c"}, false},
+		{"blanks and duplicates", " a, ,b,a,", []string{"a", "b"}, false},
+		{"empty", "", nil, true},
+		{"only commas", ",,", nil, true},
+		{"too many", tooMany, nil, true},
+	}
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			got, err := parseIDs(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
 }
 
-// FindAll mocks finding multiple movies by External ID
-func (ms mockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
-	// get test user
-	u := usertest.NewUser(ms.t)
-
-	// mock create/update timestamp
-	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
-
-	m1 := &movie.Movie{
-		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
-		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
-		Title:      "Repo Man",
-		Rated:      "R",
-		Released:   time.Date(1984, 3, 2, 0, 0, 0, 0, time.UTC),
-		RunTime:    92,
-		Director:   "Alex Cox",
-		Writer:     "Alex Cox",
-		CreateUser: u,
-		CreateTime: cuTime,
-		UpdateUser: u,
-		UpdateTime: cuTime,
+func Test_parseSuggestQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantPrefix string
+		wantLimit  int
+		wantErr    bool
+	}{
+		{"default limit", "q=rep", "rep", defaultSuggestLimit, false},
+		{"limit", "q=+rep+&limit=5", "rep", 5, false},
+		{"max limit", "q=rep&limit=25", "rep", maxSuggestLimit, false},
+		{"missing q", "limit=5", "", 0, true},
+		{"blank q", "q=+", "", 0, true},
+		{"limit not a number", "q=rep&limit=five", "", 0, true},
+		{"limit zero", "q=rep&limit=0", "", 0, true},
+		{"limit too big", "q=rep&limit=26", "", 0, true},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			q, err := url.ParseQuery(tt.query)
+			c.Assert(err, qt.IsNil)
 
-	m2 := &movie.Movie{
-		ID:         uuid.MustParse("e883ebbb-c021-423b-954a-e94edb8b85b8"),
-		ExternalID: "RWn8zcaTA1gk3ybrBdQV",
-		Title:      "The Return of the Living Dead",
-		Rated:      "R",
-		Released:   time.Date(1985, 8, 16, 0, 0, 0, 0, time.UTC),
-		RunTime:    91,
-		Director:   "Dan O'Bannon",
-		Writer:     "Russell Streiner",
-		CreateUser: u,
-		CreateTime: cuTime,
-		UpdateUser: u,
-		UpdateTime: cuTime,
+			prefix, limit, err := parseSuggestQuery(q)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(prefix, qt.Equals, tt.wantPrefix)
+			c.Assert(limit, qt.Equals, tt.wantLimit)
+		})
 	}
+}
+
+func TestDefaultMovieHandlers_MovieStats(t *testing.T) {
+	t.Run("mock DB", func(t *testing.T) {
+		// initialize quickest checker
+		c := qt.New(t)
+
+		// initialize a zerolog Logger
+		lgr := logger.NewLogger(os.Stdout, true)
+
+		// initialize MockSelector for the moviestore
+		mockSelector := moviestoretest.NewMockSelector(t)
+
+		// initialize DefaultMovieHandlers
+		dmh := DefaultMovieHandlers{
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: random.DefaultStringGenerator{},
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          moviestoretest.NewMockTransactor(t),
+				Selector:            mockSelector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
+		}
+
+		// setup path
+		path := pathPrefix + moviesV1PathRoot + "/stats"
+
+		// form request using httptest
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+
+		// add test access token
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+		// create middleware to extract the request ID from
+		// the request context for testing comparison
+		var requestID string
+		requestIDMiddleware := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rID, ok := hlog.IDFromRequest(r)
+				if !ok {
+					t.Fatal("Request ID not set to request context")
+				}
+				requestID = rID.String()
+
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		// retrieve movieStatsHandler HTTP handler
+		movieStatsHandler := ProvideMovieStatsHandler(dmh)
+
+		// initialize ResponseRecorder to use with ServeHTTP as it
+		// satisfies ResponseWriter interface and records the response
+		// for testing
+		rr := httptest.NewRecorder()
 
-	return []*movie.Movie{m1, m2}, nil
+		// setup full handler chain needed for request
+		h := LoggerHandlerChain(lgr, alice.New()).
+			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeMoviesRead)).
+			Append(JSONContentTypeHandler).
+			Append(requestIDMiddleware).
+			Then(movieStatsHandler)
+
+		// call the handler ServeHTTP method to execute the request
+		// and record the response
+		h.ServeHTTP(rr, req)
+
+		// Assert that Response Status Code equals 200 (StatusOK)
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		// standardResponse decodes the response with Data as the dto type
+		type standardResponse struct {
+			Path      string                 `json:"path"`
+			RequestID string                 `json:"request_id"`
+			Data      dto.MovieStatsResponse `json:"data"`
+		}
+
+		// setup the expected response data from the mocked stats
+		wantBody := standardResponse{
+			Path:      path,
+			RequestID: requestID,
+			Data: dto.MovieStatsResponse{
+				ByRating:   []dto.GroupCountResponse{{Value: "R", Count: 2}},
+				ByDecade:   []dto.GroupCountResponse{{Value: "1980s", Count: 2}},
+				ByDirector: []dto.GroupCountResponse{{Value: "Alex Cox", Count: 1}, {Value: "Dan O'Bannon", Count: 1}},
+			},
+		}
+
+		// initialize standardResponse
+		gotBody := standardResponse{}
+
+		// decode the response body into the standardResponse (gotBody)
+		err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+		defer rr.Result().Body.Close()
+
+		// Assert that there is no error after decoding the response body
+		c.Assert(err, qt.IsNil)
+
+		// Assert that the response body (gotBody) is as expected (wantBody).
+		c.Assert(gotBody, qt.DeepEquals, wantBody)
+	})
 }
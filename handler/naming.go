@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// FieldNaming is how the fields of JSON response bodies are named
+type FieldNaming int
+
+// Field namings. The DTOs are tagged in snake_case, so CamelCase is
+// applied when a response body is written, e.g. release_date is
+// written as releaseDate.
+const (
+	SnakeCase FieldNaming = iota
+	CamelCase
+)
+
+// String returns the name of n, as parsed by ParseFieldNaming
+func (n FieldNaming) String() string {
+	if n == CamelCase {
+		return "camel"
+	}
+	return "snake"
+}
+
+// ParseFieldNaming returns the FieldNaming named s, either snake or
+// camel
+func ParseFieldNaming(s string) (FieldNaming, error) {
+	switch strings.ToLower(s) {
+	case "snake":
+		return SnakeCase, nil
+	case "camel":
+		return CamelCase, nil
+	}
+	return SnakeCase, errs.E(errs.Validation, errs.Parameter("json-field-naming"), errors.Errorf("unknown JSON field naming %q, must be snake or camel", s))
+}
+
+// Vendor media types a client can send in the Accept header to
+// choose the field naming of the response body, whatever the
+// configured default
+const (
+	SnakeCaseMediaType = "application/vnd.go-api-basic.snake+json"
+	CamelCaseMediaType = "application/vnd.go-api-basic.camel+json"
+)
+
+// EncodeOptions configures how JSON response bodies are encoded
+type EncodeOptions struct {
+	// FieldNaming is the naming of response body fields when the
+	// request does not ask for one using a vendor media type
+	FieldNaming FieldNaming
+
+	// NoEnvelope writes the Data of a StandardResponse as the
+	// response body, without the path, request_id and meta fields
+	// around it, for clients which want bare resources
+	NoEnvelope bool
+
+	// Envelope configures which fields of the envelope around the
+	// Data are written, and their names
+	Envelope Envelope
+}
+
+// encodeOptionsKey is the request context key for the EncodeOptions
+// of the response
+type encodeOptionsKey struct{}
+
+// encodeOptionsFromContext returns the EncodeOptions set to ctx by
+// FieldNamingHandler, or the zero EncodeOptions if none were set
+func encodeOptionsFromContext(ctx context.Context) EncodeOptions {
+	opts, _ := ctx.Value(encodeOptionsKey{}).(EncodeOptions)
+	return opts
+}
+
+// negotiateFieldNaming returns the FieldNaming of the first vendor
+// media type in the Accept header accept, or def if there is none
+func negotiateFieldNaming(accept string, def FieldNaming) FieldNaming {
+	for _, mt := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(mt)
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case SnakeCaseMediaType:
+			return SnakeCase
+		case CamelCaseMediaType:
+			return CamelCase
+		}
+	}
+	return def
+}
+
+// FieldNamingHandler returns middleware which sets opts to the
+// request context, with the FieldNaming of the response body being
+// the naming of a vendor media type in the Accept header or else the
+// naming in opts. The options are applied by NewStandardResponse.
+func FieldNamingHandler(opts EncodeOptions) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				// the body depends on the Accept header, so caches
+				// must not reuse it for a different Accept header
+				w.Header().Add("Vary", "Accept")
+
+				ro := opts
+				ro.FieldNaming = negotiateFieldNaming(r.Header.Get("Accept"), opts.FieldNaming)
+				ctx := context.WithValue(r.Context(), encodeOptionsKey{}, ro)
+				h.ServeHTTP(w, r.WithContext(ctx)) // call original
+			})
+	}
+}
+
+// camelKeys rewrites the object keys in the JSON b from snake_case
+// to camelCase in place and returns the rewritten JSON. Values are
+// left as is. b must be compact JSON, as written by json.Encoder,
+// so a key is a string directly followed by a colon. Leading and
+// trailing underscores are kept.
+func camelKeys(b []byte) []byte {
+	n := 0
+	for i := 0; i < len(b); {
+		if b[i] != '"' {
+			b[n] = b[i]
+			n++
+			i++
+			continue
+		}
+
+		// find the closing quote of the string starting at i
+		j := i + 1
+		for j < len(b) && b[j] != '"' {
+			if b[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(b) {
+			n += copy(b[n:], b[i:])
+			break
+		}
+
+		if j+1 >= len(b) || b[j+1] != ':' {
+			// not a key
+			n += copy(b[n:], b[i:j+1])
+			i = j + 1
+			continue
+		}
+
+		b[n] = '"'
+		n++
+		upper := false
+		for k := i + 1; k < j; k++ {
+			c := b[k]
+			if c == '_' && k > i+1 && k < j-1 {
+				upper = true
+				continue
+			}
+			if upper && 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			upper = false
+			b[n] = c
+			n++
+		}
+		b[n] = '"'
+		n++
+		i = j + 1
+	}
+
+	return b[:n]
+}
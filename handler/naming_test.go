@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestParseFieldNaming(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    FieldNaming
+		wantErr bool
+	}{
+		{"snake", SnakeCase, false},
+		{"Camel", CamelCase, false},
+		{"kebab", SnakeCase, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ParseFieldNaming(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.want)
+			c.Assert(got.String(), qt.Equals, strings.ToLower(tt.s))
+		})
+	}
+}
+
+func Test_negotiateFieldNaming(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		def    FieldNaming
+		want   FieldNaming
+	}{
+		{"none", "", CamelCase, CamelCase},
+		{"json", "application/json", SnakeCase, SnakeCase},
+		{"camel", CamelCaseMediaType, SnakeCase, CamelCase},
+		{"snake", SnakeCaseMediaType, CamelCase, SnakeCase},
+		{"first vendor type", "application/json, " + CamelCaseMediaType + "; q=0.9, " + SnakeCaseMediaType, SnakeCase, CamelCase},
+		{"malformed", "/;", SnakeCase, SnakeCase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, negotiateFieldNaming(tt.accept, tt.def), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_camelKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"keys", `{"request_id":"a","data":{"release_date":"b","run_time":1}}`, `{"requestId":"a","data":{"releaseDate":"b","runTime":1}}`},
+		{"values are kept", `{"extl_id":"snake_case_value","list":["a_b"]}`, `{"extlId":"snake_case_value","list":["a_b"]}`},
+		{"escaped quote", `{"a_b":"x\":y","c_d":"\\"}`, `{"aB":"x\":y","cD":"\\"}`},
+		{"outer underscores", `{"_a_b_":1}`, `{"_aB_":1}`},
+		{"partial", `,{"total_count":2}]`, `,{"totalCount":2}]`},
+		{"unterminated", `{"a_b`, `{"a_b`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, string(camelKeys([]byte(tt.in))), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestFieldNamingHandler(t *testing.T) {
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	tests := []struct {
+		name   string
+		opts   EncodeOptions
+		accept string
+		want   string
+	}{
+		{"default", EncodeOptions{}, "", `"request_id"`},
+		{"configured", EncodeOptions{FieldNaming: CamelCase}, "", `"requestId"`},
+		{"vendor media type", EncodeOptions{}, CamelCaseMediaType, `"requestId"`},
+		{"vendor media type over configured", EncodeOptions{FieldNaming: CamelCase}, SnakeCaseMediaType, `"request_id"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := LoggerHandlerChain(lgr, alice.New()).
+				Append(FieldNamingHandler(tt.opts)).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					sr, err := NewStandardResponse(r, map[string]int{"total_count": 1})
+					c.Assert(err, qt.IsNil)
+					c.Assert(writeJSON(w, sr), qt.IsNil)
+				})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			c.Assert(rr.Body.String(), qt.Contains, tt.want)
+			c.Assert(rr.Header().Get("Vary"), qt.Equals, "Accept")
+		})
+	}
+}
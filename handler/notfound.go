@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// notFoundHandler sends the standard error response with a 404 for a
+// request whose path matches no route, in place of gorilla/mux's
+// plain text response
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	err := errs.E(errs.NotExist, errs.Code("route_not_found"), errors.Errorf("no route matches path %s", r.URL.Path))
+	errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+}
+
+// pathMethods are the path, as a regexp, and the methods of a route
+type pathMethods struct {
+	path    *regexp.Regexp
+	methods []string
+}
+
+// methodNotAllowedHandler returns a handler sending the standard
+// error response with a 405 for a request whose path matches a route
+// of rtr but whose method does not, with the Allow header listing
+// the methods of the routes matching the path. The routes must all
+// be registered before it is called.
+func methodNotAllowedHandler(rtr *mux.Router) http.Handler {
+	routes := routePathMethods(rtr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allow := allowedMethods(routes, r.URL.Path)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+
+		err := errs.E(errs.MethodNotAllowed, errs.Code("method_not_allowed"), errors.Errorf("method %s is not allowed, the allowed methods are %s", r.Method, strings.Join(allow, ", ")))
+		errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+	})
+}
+
+// routePathMethods returns the path and methods of each route of rtr
+// which has both
+func routePathMethods(rtr *mux.Router) []pathMethods {
+	var routes []pathMethods
+	_ = rtr.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		re, err := route.GetPathRegexp()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		routes = append(routes, pathMethods{path: regexp.MustCompile(re), methods: methods})
+		return nil
+	})
+	return routes
+}
+
+// allowedMethods returns the methods of the routes matching path,
+// sorted
+func allowedMethods(routes []pathMethods, path string) []string {
+	seen := make(map[string]bool)
+	var allow []string
+	for _, rt := range routes {
+		if !rt.path.MatchString(path) {
+			continue
+		}
+		for _, m := range rt.methods {
+			if !seen[m] {
+				seen[m] = true
+				allow = append(allow, m)
+			}
+		}
+	}
+	sort.Strings(allow)
+	return allow
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+)
+
+func Test_allowedMethods(t *testing.T) {
+	rtr := mux.NewRouter()
+	h := http.NotFoundHandler()
+	rtr.Handle("/movies", h).Methods(http.MethodPost)
+	rtr.Handle("/movies/{id}", h).Methods(http.MethodPut)
+	rtr.Handle("/movies/{id}", h).Methods(http.MethodGet, http.MethodHead)
+	rtr.Handle("/movies/stats", h).Methods(http.MethodGet)
+
+	routes := routePathMethods(rtr)
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"one route", "/movies", []string{http.MethodPost}},
+		{"routes merged and sorted", "/movies/abc", []string{http.MethodGet, http.MethodHead, http.MethodPut}},
+		{"fixed and variable routes", "/movies/stats", []string{http.MethodGet, http.MethodHead, http.MethodPut}},
+		{"no route", "/people", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, allowedMethods(routes, tt.path), qt.DeepEquals, tt.want)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// PageLimits are the limits on the pages of a list route
+type PageLimits struct {
+	// DefaultSize is the number of items in a page if the page_size
+	// query parameter is not given
+	DefaultSize int
+	// MaxSize is the largest page_size allowed
+	MaxSize int
+	// MaxExportRows is the most items an export of the route can
+	// hold
+	MaxExportRows int
+}
+
+// DefaultPageLimits are the PageLimits of a route unless others are
+// configured
+var DefaultPageLimits = PageLimits{DefaultSize: 20, MaxSize: 100, MaxExportRows: 10000}
+
+// override returns l with the fields of o which are set (not zero)
+func (l PageLimits) override(o PageLimits) PageLimits {
+	if o.DefaultSize > 0 {
+		l.DefaultSize = o.DefaultSize
+	}
+	if o.MaxSize > 0 {
+		l.MaxSize = o.MaxSize
+	}
+	if o.MaxExportRows > 0 {
+		l.MaxExportRows = o.MaxExportRows
+	}
+	return l
+}
+
+// Pagination holds the PageLimits of the list routes. The zero value
+// is DefaultPageLimits for every route.
+type Pagination struct {
+	// Defaults are the limits of every route, any field not set is
+	// the field of DefaultPageLimits
+	Defaults PageLimits
+	// Routes are the limits of routes by path template, e.g.
+	// /api/v1/movies, overriding the fields of Defaults they set
+	Routes map[string]PageLimits
+}
+
+// Limits returns the PageLimits of the route with the path template
+// tpl. The default page size is at most the maximum page size.
+func (p Pagination) Limits(tpl string) PageLimits {
+	l := DefaultPageLimits.override(p.Defaults).override(p.Routes[tpl])
+	if l.DefaultSize > l.MaxSize {
+		l.DefaultSize = l.MaxSize
+	}
+	return l
+}
+
+// limits returns the PageLimits of the route matched for r
+func (p Pagination) limits(r *http.Request) PageLimits {
+	return p.Limits(routeTemplate(r))
+}
+
+// ParsePageLimits parses s, a comma separated list of the page
+// limits of routes as path=default:max:export, e.g.
+// /api/v1/movies=50:500:100000. A limit left empty, as in
+// /api/v1/movies=:500:, is not overridden.
+func ParsePageLimits(s string) (map[string]PageLimits, error) {
+	routes := make(map[string]PageLimits)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i := strings.LastIndex(v, "=")
+		if i < 1 {
+			return nil, errs.E(errs.Validation, errs.Parameter("page-limits"), errors.Errorf("page limits %q must be path=default:max:export", v))
+		}
+		path := strings.TrimSpace(v[:i])
+		fields := strings.Split(v[i+1:], ":")
+		if len(fields) != 3 {
+			return nil, errs.E(errs.Validation, errs.Parameter("page-limits"), errors.Errorf("page limits %q must be path=default:max:export", v))
+		}
+		var n [3]int
+		for j, f := range fields {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			var err error
+			if n[j], err = strconv.Atoi(f); err != nil || n[j] < 1 {
+				return nil, errs.E(errs.Validation, errs.Parameter("page-limits"), errors.Errorf("page limit %q of %s must be a number greater than 0", f, path))
+			}
+		}
+		if _, ok := routes[path]; ok {
+			return nil, errs.E(errs.Validation, errs.Parameter("page-limits"), errors.Errorf("route %s is listed twice", path))
+		}
+		routes[path] = PageLimits{DefaultSize: n[0], MaxSize: n[1], MaxExportRows: n[2]}
+	}
+	return routes, nil
+}
+
+// maxPageOffset is the largest number of items before a requested
+// page. It is far beyond any list served, and keeps the offset of a
+// page from overflowing.
+const maxPageOffset = math.MaxInt32
+
+// pageRequest is the page requested using the page and page_size
+// query parameters
+type pageRequest struct {
+	Page int
+	Size int
+}
+
+// newPageRequest parses the page and page_size query parameters
+// within the limits l. Pages are numbered starting from 1, and a page
+// may not start more than maxPageOffset items into the list.
+func newPageRequest(r *http.Request, l PageLimits) (pageRequest, error) {
+	p := pageRequest{Page: 1, Size: l.DefaultSize}
+	q := r.URL.Query()
+
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return pageRequest{}, errs.E(errs.Validation, errs.Parameter("page"), errors.New("page must be a number greater than 0"))
+		}
+		p.Page = page
+	}
+
+	if v := q.Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > l.MaxSize {
+			return pageRequest{}, errs.E(errs.Validation, errs.Parameter("page_size"), errors.Errorf("page_size must be a number between 1 and %d", l.MaxSize))
+		}
+		p.Size = size
+	}
+
+	if p.Page-1 > maxPageOffset/p.Size {
+		return pageRequest{}, errs.E(errs.Validation, errs.Parameter("page"), errors.Errorf("page must be a number between 1 and %d", maxPageOffset/p.Size+1))
+	}
+
+	return p, nil
+}
+
+// Offset returns the number of items before the page
+func (p pageRequest) Offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// PageMeta is the pagination metadata sent in the response body of
+// a list response
+type PageMeta struct {
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalCount int `json:"total_count"`
+	TotalPages int `json:"total_pages"`
+}
+
+// newPageMeta returns the PageMeta for the requested page given the
+// total number of items
+func newPageMeta(p pageRequest, total int) PageMeta {
+	pages := (total + p.Size - 1) / p.Size
+	if pages < 1 {
+		pages = 1
+	}
+
+	return PageMeta{
+		Page:       p.Page,
+		PageSize:   p.Size,
+		TotalCount: total,
+		TotalPages: pages,
+	}
+}
+
+// setLinkHeader sets the Link header (RFC 5988) with the first,
+// prev, next and last page links so generic HTTP clients can
+// paginate without parsing the response body. The links are
+// relative to the request, keeping any other query parameters.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, m PageMeta) {
+	// the query is parsed once and only the page parameter changes
+	// from link to link
+	q := r.URL.Query()
+	q.Set("page_size", strconv.Itoa(m.PageSize))
+
+	var b strings.Builder
+	link := func(page int, rel string) {
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		q.Set("page", strconv.Itoa(page))
+		b.WriteByte('<')
+		b.WriteString(r.URL.EscapedPath())
+		b.WriteByte('?')
+		b.WriteString(q.Encode())
+		b.WriteString(`>; rel="`)
+		b.WriteString(rel)
+		b.WriteByte('"')
+	}
+
+	link(1, "first")
+	if m.Page > 1 {
+		// a page past the end links back to the last page
+		prev := m.Page - 1
+		if prev > m.TotalPages {
+			prev = m.TotalPages
+		}
+		link(prev, "prev")
+	}
+	if m.Page < m.TotalPages {
+		link(m.Page+1, "next")
+	}
+	link(m.TotalPages, "last")
+
+	w.Header().Set("Link", b.String())
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func Test_newPageRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      pageRequest
+		wantParam errs.Parameter
+	}{
+		{"defaults", "", pageRequest{Page: 1, Size: DefaultPageLimits.DefaultSize}, ""},
+		{"page and size", "?page=3&page_size=10", pageRequest{Page: 3, Size: 10}, ""},
+		{"max size", "?page_size=100", pageRequest{Page: 1, Size: DefaultPageLimits.MaxSize}, ""},
+		{"page zero", "?page=0", pageRequest{}, "page"},
+		{"page not a number", "?page=abc", pageRequest{}, "page"},
+		{"last page", "?page=107374183&page_size=20", pageRequest{Page: 107374183, Size: 20}, ""},
+		{"page past max offset", "?page=107374184&page_size=20", pageRequest{}, "page"},
+		{"page offset overflows", "?page=9223372036854775807", pageRequest{}, "page"},
+		{"size zero", "?page_size=0", pageRequest{}, "page_size"},
+		{"size too large", "?page_size=101", pageRequest{}, "page_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies"+tt.query, nil)
+
+			got, err := newPageRequest(req, DefaultPageLimits)
+			c.Assert(got, qt.Equals, tt.want)
+			if tt.wantParam == "" {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, tt.wantParam)
+		})
+	}
+}
+
+func TestPagination_Limits(t *testing.T) {
+	p := Pagination{
+		Defaults: PageLimits{DefaultSize: 50, MaxExportRows: 5000},
+		Routes: map[string]PageLimits{
+			"/api/v1/movies": {MaxSize: 500},
+			"/api/v1/people": {MaxSize: 10},
+		},
+	}
+
+	tests := []struct {
+		name string
+		p    Pagination
+		tpl  string
+		want PageLimits
+	}{
+		{"zero value", Pagination{}, "/api/v1/movies", DefaultPageLimits},
+		{"defaults", p, "/api/v1/audit", PageLimits{DefaultSize: 50, MaxSize: 100, MaxExportRows: 5000}},
+		{"route override", p, "/api/v1/movies", PageLimits{DefaultSize: 50, MaxSize: 500, MaxExportRows: 5000}},
+		{"default size above max", p, "/api/v1/people", PageLimits{DefaultSize: 10, MaxSize: 10, MaxExportRows: 5000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, tt.p.Limits(tt.tpl), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestPagination_limits(t *testing.T) {
+	c := qt.New(t)
+
+	p := Pagination{Routes: map[string]PageLimits{"/api/v1/movies": {MaxSize: 500}}}
+
+	var got PageLimits
+	rtr := mux.NewRouter()
+	rtr.HandleFunc("/api/v1/movies", func(w http.ResponseWriter, r *http.Request) {
+		got = p.limits(r)
+	})
+	rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies?page_size=500", nil))
+
+	c.Assert(got.MaxSize, qt.Equals, 500)
+}
+
+func TestParsePageLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]PageLimits
+		wantErr bool
+	}{
+		{"empty", "", map[string]PageLimits{}, false},
+		{"routes", "/api/v1/movies=50:500:100000, /api/v1/people=:10:", map[string]PageLimits{
+			"/api/v1/movies": {DefaultSize: 50, MaxSize: 500, MaxExportRows: 100000},
+			"/api/v1/people": {MaxSize: 10},
+		}, false},
+		{"no path", "=1:2:3", nil, true},
+		{"too few limits", "/api/v1/movies=50:500", nil, true},
+		{"not a number", "/api/v1/movies=fifty::", nil, true},
+		{"zero", "/api/v1/movies=0::", nil, true},
+		{"listed twice", "/api/v1/movies=::1,/api/v1/movies=::2", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ParsePageLimits(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func Test_newPageMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  pageRequest
+		total int
+		want  PageMeta
+	}{
+		{"empty", pageRequest{Page: 1, Size: 20}, 0, PageMeta{Page: 1, PageSize: 20, TotalCount: 0, TotalPages: 1}},
+		{"exact", pageRequest{Page: 2, Size: 10}, 30, PageMeta{Page: 2, PageSize: 10, TotalCount: 30, TotalPages: 3}},
+		{"partial last page", pageRequest{Page: 1, Size: 10}, 31, PageMeta{Page: 1, PageSize: 10, TotalCount: 31, TotalPages: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(newPageMeta(tt.page, tt.total), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_setLinkHeader(t *testing.T) {
+	const (
+		first = `</api/v1/movies?page=1&page_size=10>; rel="first"`
+		last  = `</api/v1/movies?page=3&page_size=10>; rel="last"`
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		meta  PageMeta
+		want  string
+	}{
+		{"first page", "?page_size=10", PageMeta{Page: 1, PageSize: 10, TotalPages: 3},
+			first + `, </api/v1/movies?page=2&page_size=10>; rel="next", ` + last},
+		{"middle page", "?page=2&page_size=10", PageMeta{Page: 2, PageSize: 10, TotalPages: 3},
+			first + `, </api/v1/movies?page=1&page_size=10>; rel="prev", </api/v1/movies?page=3&page_size=10>; rel="next", ` + last},
+		{"last page", "?page=3&page_size=10", PageMeta{Page: 3, PageSize: 10, TotalPages: 3},
+			first + `, </api/v1/movies?page=2&page_size=10>; rel="prev", ` + last},
+		{"past last page", "?page=9&page_size=10", PageMeta{Page: 9, PageSize: 10, TotalPages: 3},
+			first + `, </api/v1/movies?page=3&page_size=10>; rel="prev", ` + last},
+		{"other params kept", "?page_size=10&sort=title", PageMeta{Page: 3, PageSize: 10, TotalPages: 3},
+			`</api/v1/movies?page=1&page_size=10&sort=title>; rel="first", </api/v1/movies?page=2&page_size=10&sort=title>; rel="prev", </api/v1/movies?page=3&page_size=10&sort=title>; rel="last"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			setLinkHeader(rr, req, tt.meta)
+			c.Assert(rr.Header().Get("Link"), qt.Equals, tt.want)
+		})
+	}
+}
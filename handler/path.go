@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// PathNormalization configures how request paths are normalized
+// before they are routed
+type PathNormalization struct {
+	// TrimTrailingSlash removes a trailing slash, so /api/v1/movies/
+	// is routed as /api/v1/movies
+	TrimTrailingSlash bool
+	// CollapseSlashes replaces each run of slashes by one, so
+	// /api//v1/movies is routed as /api/v1/movies
+	CollapseSlashes bool
+	// CaseInsensitive matches the fixed segments of the route paths
+	// regardless of case, so /API/V1/Movies is routed as
+	// /api/v1/movies. Other segments, such as the ID of a movie,
+	// keep their case unless they spell a fixed segment.
+	CaseInsensitive bool
+}
+
+// normalizePathHandler returns rtr wrapped to normalize the path of
+// each request using opts before it is routed. The request is routed
+// (and logged) using the normalized path; the client is not
+// redirected.
+func normalizePathHandler(rtr *mux.Router, opts PathNormalization) (http.Handler, error) {
+	if !opts.TrimTrailingSlash && !opts.CollapseSlashes && !opts.CaseInsensitive {
+		return rtr, nil
+	}
+
+	n := pathNormalizer{opts: opts}
+	if opts.CaseInsensitive {
+		// the segments are taken from the routes, so the router must
+		// be set up before it is wrapped
+		routes, err := Routes(rtr)
+		if err != nil {
+			return nil, err
+		}
+		n.segments = pathSegments(routes)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := n.normalize(r.URL.Path); p != r.URL.Path {
+			r.URL.Path = p
+			// the escaped path is computed from Path again
+			r.URL.RawPath = ""
+		}
+		rtr.ServeHTTP(w, r)
+	}), nil
+}
+
+// pathSegments returns the fixed segments of the paths of routes by
+// their lower case form, e.g. movies for /api/v1/movies/{extlID}
+func pathSegments(routes []Route) map[string]string {
+	segments := make(map[string]string)
+	for _, rt := range routes {
+		for _, s := range strings.Split(rt.Path, "/") {
+			if s == "" || strings.Contains(s, "{") {
+				continue
+			}
+			segments[strings.ToLower(s)] = s
+		}
+	}
+	return segments
+}
+
+// pathNormalizer normalizes request paths
+type pathNormalizer struct {
+	opts PathNormalization
+	// segments holds the fixed segments of the route paths by their
+	// lower case form, if CaseInsensitive is set
+	segments map[string]string
+}
+
+// normalize returns the normalized form of the path p, or p itself
+// if it is already normal. The root path is never changed.
+func (n pathNormalizer) normalize(p string) string {
+	if n.opts.CollapseSlashes && strings.Contains(p, "//") {
+		var b strings.Builder
+		b.Grow(len(p))
+		for i := 0; i < len(p); i++ {
+			if p[i] == '/' && i > 0 && p[i-1] == '/' {
+				continue
+			}
+			b.WriteByte(p[i])
+		}
+		p = b.String()
+	}
+
+	if n.opts.TrimTrailingSlash {
+		for len(p) > 1 && strings.HasSuffix(p, "/") {
+			p = p[:len(p)-1]
+		}
+	}
+
+	if n.segments != nil {
+		parts := strings.Split(p, "/")
+		changed := false
+		for i, s := range parts {
+			if c, ok := n.segments[strings.ToLower(s)]; ok && c != s {
+				parts[i] = c
+				changed = true
+			}
+		}
+		if changed {
+			p = strings.Join(parts, "/")
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+)
+
+func Test_pathNormalizer_normalize(t *testing.T) {
+	all := PathNormalization{TrimTrailingSlash: true, CollapseSlashes: true, CaseInsensitive: true}
+	segments := pathSegments([]Route{
+		{Path: "/api/v1/movies"},
+		{Path: "/api/v1/movies/{extlID}"},
+	})
+
+	tests := []struct {
+		name string
+		opts PathNormalization
+		path string
+		want string
+	}{
+		{"normal", all, "/api/v1/movies", "/api/v1/movies"},
+		{"root", all, "/", "/"},
+		{"root slashes", all, "//", "/"},
+		{"trailing slash", all, "/api/v1/movies/", "/api/v1/movies"},
+		{"trailing slashes", PathNormalization{TrimTrailingSlash: true}, "/api/v1/movies//", "/api/v1/movies"},
+		{"trailing slash kept", PathNormalization{CollapseSlashes: true}, "/api/v1/movies/", "/api/v1/movies/"},
+		{"double slashes", all, "//api//v1///movies", "/api/v1/movies"},
+		{"double slashes kept", PathNormalization{TrimTrailingSlash: true}, "/api//v1/movies", "/api//v1/movies"},
+		{"case", all, "/API/V1/Movies/RWn8zcaTA1gk3ybrBdQV", "/api/v1/movies/RWn8zcaTA1gk3ybrBdQV"},
+		{"case kept", PathNormalization{CollapseSlashes: true}, "/API/v1/movies", "/API/v1/movies"},
+		{"unknown segment", all, "/api/v1/Films", "/api/v1/Films"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := pathNormalizer{opts: tt.opts}
+			if tt.opts.CaseInsensitive {
+				n.segments = segments
+			}
+			qt.Assert(t, n.normalize(tt.path), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_normalizePathHandler(t *testing.T) {
+	rtr := mux.NewRouter()
+	rtr.HandleFunc("/api/v1/movies", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("list"))
+	})
+	rtr.HandleFunc("/api/v1/movies/{extlID}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(mux.Vars(r)["extlID"]))
+	})
+
+	tests := []struct {
+		name       string
+		opts       PathNormalization
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"none", PathNormalization{}, "/api/v1/movies/", http.StatusNotFound, ""},
+		{"trailing slash", PathNormalization{TrimTrailingSlash: true}, "/api/v1/movies/", http.StatusOK, "list"},
+		// gorilla/mux redirects a path which is not clean
+		{"double slashes redirected", PathNormalization{}, "/api//v1/movies", http.StatusMovedPermanently, ""},
+		{"double slashes", PathNormalization{CollapseSlashes: true}, "/api//v1/movies", http.StatusOK, "list"},
+		{"case", PathNormalization{CaseInsensitive: true}, "/Api/V1/MOVIES/RWn8zcaTA1gk3ybrBdQV", http.StatusOK, "RWn8zcaTA1gk3ybrBdQV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h, err := normalizePathHandler(rtr, tt.opts)
+			c.Assert(err, qt.IsNil)
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			if tt.wantBody != "" {
+				c.Assert(rr.Body.String(), qt.Equals, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// PermissionsHandler is a Handler that returns the permissions of
+// the authenticated user
+type PermissionsHandler http.Handler
+
+// ProvidePermissionsHandler is a provider for the PermissionsHandler
+// for wire
+func ProvidePermissionsHandler(h DefaultPermissionsHandler) PermissionsHandler {
+	return http.HandlerFunc(h.Permissions)
+}
+
+// DefaultPermissionsHandler handles requests for a user's
+// permissions
+type DefaultPermissionsHandler struct {
+	Authorizer auth.Authorizer
+	RouteList  *RouteList
+}
+
+// Permissions handles GET requests for the /users/me/permissions
+// endpoint and returns the scopes of the registered routes the
+// authenticated user is authorized to call, so a UI can hide the
+// actions the user cannot perform. The scopes are computed by asking
+// the Authorizer about each of them.
+func (h DefaultPermissionsHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	routes, err := h.RouteList.Routes()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	scopes, err := grantedScopes(r.Context(), h.Authorizer, u, routes)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	response, err := NewStandardResponse(r, dto.PermissionsResponse{Username: u.Email, Scopes: scopes})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// grantedScopes returns the distinct scopes of routes which az
+// authorizes u for, sorted. The scopes of a route are only granted
+// if u also has the route's role.
+func grantedScopes(ctx context.Context, az auth.Authorizer, u user.User, routes []Route) ([]string, error) {
+	// asking about a scope is not an attempt to use it, so the
+	// Authorizer's logging of granted and denied requests is
+	// turned off
+	nop := zerolog.Nop()
+	ctx = nop.WithContext(ctx)
+
+	seen := make(map[string]bool)
+	hasRole := make(map[string]bool)
+	scopes := make([]string, 0)
+	for _, rt := range routes {
+		// the scopes of a route needing a role the user does not
+		// have are not granted by the route
+		if rt.Role != "" {
+			ok, checked := hasRole[rt.Role]
+			if !checked {
+				err := az.Authorize(ctx, u, auth.RoleResource, rt.Role)
+				if err != nil && !errs.KindIs(errs.Unauthorized, err) {
+					return nil, err
+				}
+				ok = err == nil
+				hasRole[rt.Role] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		for _, scope := range rt.Scopes {
+			if seen[scope] {
+				continue
+			}
+			seen[scope] = true
+
+			err := authorizeScopes(ctx, az, u, []string{scope})
+			if errs.KindIs(errs.Unauthorized, err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+
+	return scopes, nil
+}
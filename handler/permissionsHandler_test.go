@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// readOnlyAuthorizer authorizes every user to read and no user to
+// write or to have a role
+type readOnlyAuthorizer struct{}
+
+func (a readOnlyAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
+	if action == auth.ActionRead {
+		return nil
+	}
+	return errs.E(errs.Unauthorized, errors.New("read only"))
+}
+
+func TestDefaultPermissionsHandler_Permissions(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer auth.Authorizer
+		want       []string
+	}{
+		{"all", authtest.NewMockAuthorizer(t), []string{
+			scopeAdminRead, scopeExportsRead, scopeMoviesRead, scopeMoviesWrite, scopePeopleRead,
+			scopePeopleWrite, scopePermissionsRead, scopeSessionsWrite, scopeUsageRead, scopeUsersWrite,
+		}},
+		// the admin routes need the admin role as well as admin:read
+		{"read only", readOnlyAuthorizer{}, []string{
+			scopeExportsRead, scopeMoviesRead, scopePeopleRead, scopePermissionsRead, scopeUsageRead,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// initialize quickest checker
+			c := qt.New(t)
+
+			// initialize a zerolog Logger
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			// setup the router with the permissions handler, which
+			// asks the Authorizer about the scopes of every route of
+			// the router it is registered with
+			rl := NewRouteList()
+			dph := DefaultPermissionsHandler{
+				Authorizer: tt.authorizer,
+				RouteList:  rl,
+			}
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           tt.authorizer,
+			}
+			rtr := NewMuxRouter(lgr, Handlers{PermissionsHandler: ProvidePermissionsHandler(dph)}, am, audittest.NewMockWriter(t), errs.NopReporter{}, rl, EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+
+			// form request using httptest
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+usersV1PathRoot+"/me/permissions", nil)
+
+			// add test access token
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+			// initialize ResponseRecorder to use with ServeHTTP as it
+			// satisfies ResponseWriter interface and records the response
+			// for testing
+			rr := httptest.NewRecorder()
+
+			// route the request through the full handler chain
+			rtr.ServeHTTP(rr, req)
+
+			// Assert that Response Status Code equals 200 (StatusOK)
+			c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+			// standardResponse decodes the response with Data as the dto type
+			type standardResponse struct {
+				Data dto.PermissionsResponse `json:"data"`
+			}
+
+			// decode the response body into the standardResponse (gotBody)
+			gotBody := standardResponse{}
+			err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+			defer rr.Result().Body.Close()
+			c.Assert(err, qt.IsNil)
+
+			c.Assert(gotBody.Data, qt.DeepEquals, dto.PermissionsResponse{
+				Username: usertest.NewUser(t).Email,
+				Scopes:   tt.want,
+			})
+		})
+	}
+}
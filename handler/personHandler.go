@@ -0,0 +1,436 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/service/personsvc"
+)
+
+// CreatePersonHandler is a Handler that creates a Person
+type CreatePersonHandler http.Handler
+
+// ProvideCreatePersonHandler is a provider for the
+// CreatePersonHandler for wire
+func ProvideCreatePersonHandler(h DefaultPersonHandlers) CreatePersonHandler {
+	return http.HandlerFunc(h.CreatePerson)
+}
+
+// UpdatePersonHandler is a Handler that updates a Person
+type UpdatePersonHandler http.Handler
+
+// ProvideUpdatePersonHandler is a provider for the
+// UpdatePersonHandler for wire
+func ProvideUpdatePersonHandler(h DefaultPersonHandlers) UpdatePersonHandler {
+	return http.HandlerFunc(h.UpdatePerson)
+}
+
+// DeletePersonHandler is a Handler that deletes a Person
+type DeletePersonHandler http.Handler
+
+// ProvideDeletePersonHandler is a provider for the
+// DeletePersonHandler for wire
+func ProvideDeletePersonHandler(h DefaultPersonHandlers) DeletePersonHandler {
+	return http.HandlerFunc(h.DeletePerson)
+}
+
+// FindPersonByIDHandler is a Handler that finds a Person by ID
+type FindPersonByIDHandler http.Handler
+
+// ProvideFindPersonByIDHandler is a provider for the
+// FindPersonByIDHandler for wire
+func ProvideFindPersonByIDHandler(h DefaultPersonHandlers) FindPersonByIDHandler {
+	return http.HandlerFunc(h.FindByID)
+}
+
+// FindAllPeopleHandler is a Handler that returns all People
+type FindAllPeopleHandler http.Handler
+
+// ProvideFindAllPeopleHandler is a provider for the
+// FindAllPeopleHandler for wire
+func ProvideFindAllPeopleHandler(h DefaultPersonHandlers) FindAllPeopleHandler {
+	return http.HandlerFunc(h.FindAll)
+}
+
+// FindPersonMoviesHandler is a Handler that returns the Movies
+// directed by a Person
+type FindPersonMoviesHandler http.Handler
+
+// ProvideFindPersonMoviesHandler is a provider for the
+// FindPersonMoviesHandler for wire
+func ProvideFindPersonMoviesHandler(h DefaultPersonHandlers) FindPersonMoviesHandler {
+	return http.HandlerFunc(h.FindMovies)
+}
+
+// AddPersonMovieHandler is a Handler that sets a Person as the
+// director of a Movie
+type AddPersonMovieHandler http.Handler
+
+// ProvideAddPersonMovieHandler is a provider for the
+// AddPersonMovieHandler for wire
+func ProvideAddPersonMovieHandler(h DefaultPersonHandlers) AddPersonMovieHandler {
+	return http.HandlerFunc(h.AddMovie)
+}
+
+// DefaultPersonHandlers are the default handlers for CRUD operations
+// for a Person. Each method on the struct is a separate handler.
+type DefaultPersonHandlers struct {
+	Service       personsvc.Service
+	QuotaTracker  quota.Tracker
+	DecodeOptions DecodeOptions
+}
+
+// CreatePerson handles POST requests for the /people endpoint
+// and creates a Person
+func (h DefaultPersonHandlers) CreatePerson(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(dto.PersonRequest)
+
+	// Decode JSON HTTP request body into the dto.PersonRequest
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
+	defer r.Body.Close()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Create the Person using the person service
+	p, err := h.Service.Create(ctx, u, personsvc.PersonInput{Name: rb.Name})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewPersonResponse(p))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// UpdatePerson handles PUT requests for the /people/{id} endpoint
+// and updates the given person
+func (h DefaultPersonHandlers) UpdatePerson(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. extlID is the external id given for
+	// the person
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+
+	rb := new(dto.PersonRequest)
+
+	// Decode JSON HTTP request body into the dto.PersonRequest
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
+	defer r.Body.Close()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Update the Person using the person service
+	p, err := h.Service.Update(ctx, u, extlid, personsvc.PersonInput{Name: rb.Name})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewPersonResponse(p))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// DeletePerson handles DELETE requests for the /people/{id}
+// endpoint and deletes the given person
+func (h DefaultPersonHandlers) DeletePerson(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. extlID is the external id given for
+	// the person
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+
+	// Delete the Person using the person service
+	p, err := h.Service.Delete(ctx, u, extlid)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	dr := dto.DeletePersonResponse{
+		ExternalID: p.ExternalID,
+		Deleted:    true,
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dr)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// FindByID handles GET requests for the /people/{id} endpoint
+// and returns the given person
+func (h DefaultPersonHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. extlID is the external id given for
+	// the person
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+
+	// Find the Person by ID using the person service
+	p, err := h.Service.FindByID(ctx, u, extlid)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewPersonResponse(p))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// FindAll handles GET requests for the /people endpoint and
+// returns all people
+func (h DefaultPersonHandlers) FindAll(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Find all People using the person service
+	s, err := h.Service.FindAll(ctx, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewPersonResponses(s))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// FindMovies handles GET requests for the /people/{id}/movies
+// endpoint and returns the movies directed by the given person
+func (h DefaultPersonHandlers) FindMovies(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. extlID is the external id given for
+	// the person
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+
+	// Find the Movies directed by the Person using the person service
+	movies, err := h.Service.FindMovies(ctx, u, extlid)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, dto.NewMovieResponses(movies))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// AddMovie handles PUT requests for the
+// /people/{id}/movies/{movieID} endpoint and sets the given person
+// as the director of the given movie
+func (h DefaultPersonHandlers) AddMovie(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = trackQuota(ctx, w, h.QuotaTracker, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// gorilla mux Vars function returns the route variables for the
+	// current request, if any. extlID is the external id given for
+	// the person and movieExtlID the external id of the movie
+	vars := mux.Vars(r)
+	extlid := vars["extlID"]
+	movieExtlID := vars["movieExtlID"]
+
+	// Set the Person as the director of the Movie using the person
+	// service
+	p, err := h.Service.AddMovie(ctx, u, extlid, movieExtlID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	pmr := dto.PersonMovieResponse{
+		PersonExternalID: p.ExternalID,
+		MovieExternalID:  movieExtlID,
+	}
+
+	// Populate the response
+	response, err := NewStandardResponse(r, pmr)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
+	"github.com/gilcrest/go-api-basic/datastore/personstore/personstoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
+	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+	"github.com/gilcrest/go-api-basic/service/personsvc"
+)
+
+// newPersonRouter returns a router with the person routes
+// registered using the mock store
+func newPersonRouter(t *testing.T) *mux.Router {
+	t.Helper()
+
+	dh := DefaultPersonHandlers{
+		Service: personsvc.Service{
+			Authorizer:          authtest.NewMockAuthorizer(t),
+			ExternalIDGenerator: randomtest.NewMockStringGenerator(t),
+			Clock:               clocktest.NewMockClock(t),
+			Transactor:          personstoretest.NewMockTransactor(t),
+			Selector:            personstoretest.NewMockSelector(t),
+		},
+		QuotaTracker: quotatest.NewMockTracker(t),
+	}
+	h := PersonHandlers{
+		CreatePersonHandler:     ProvideCreatePersonHandler(dh),
+		UpdatePersonHandler:     ProvideUpdatePersonHandler(dh),
+		DeletePersonHandler:     ProvideDeletePersonHandler(dh),
+		FindPersonByIDHandler:   ProvideFindPersonByIDHandler(dh),
+		FindAllPeopleHandler:    ProvideFindAllPeopleHandler(dh),
+		FindPersonMoviesHandler: ProvideFindPersonMoviesHandler(dh),
+		AddPersonMovieHandler:   ProvideAddPersonMovieHandler(dh),
+	}
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	c := routeChain{chain: alice.New()}.Extend("logger", LoggerHandlerChain(lgr, alice.New()))
+	rtr := mux.NewRouter().PathPrefix(pathPrefix).Subrouter()
+	registerPersonRoutes(rtr, c, AuditHandler(audittest.NewMockWriter(t)), newMockAuthMiddleware(t).Handler, nil, h)
+
+	return rtr
+}
+
+func TestDefaultPersonHandlers(t *testing.T) {
+	path := pathPrefix + peopleV1PathRoot
+	body := `{"name": "Alex Cox"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"create", http.MethodPost, path, body, http.StatusOK},
+		{"create invalid", http.MethodPost, path, `{"name": ""}`, http.StatusBadRequest},
+		{"update", http.MethodPut, path + "/kCBqDtyAkZIfdWjRDXQG", body, http.StatusOK},
+		{"delete", http.MethodDelete, path + "/kCBqDtyAkZIfdWjRDXQG", "", http.StatusOK},
+		{"find by id", http.MethodGet, path + "/kCBqDtyAkZIfdWjRDXQG", "", http.StatusOK},
+		{"find all", http.MethodGet, path, "", http.StatusOK},
+		{"find movies", http.MethodGet, path + "/kCBqDtyAkZIfdWjRDXQG/movies", "", http.StatusOK},
+		{"add movie", http.MethodPut, path + "/kCBqDtyAkZIfdWjRDXQG/movies/RWn8zcaTA1gk3ybrBdQV", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var rb io.Reader
+			if tt.body != "" {
+				rb = bytes.NewBufferString(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, rb)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			if tt.body != "" {
+				req.Header.Add("Content-Type", "application/json")
+			}
+
+			rr := httptest.NewRecorder()
+			newPersonRouter(t).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+		})
+	}
+}
+
+func TestDefaultPersonHandlers_FindByID(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+peopleV1PathRoot+"/kCBqDtyAkZIfdWjRDXQG", nil)
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+	rr := httptest.NewRecorder()
+	newPersonRouter(t).ServeHTTP(rr, req)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	// standardResponse decodes the response with Data as the dto type
+	type standardResponse struct {
+		Data dto.PersonResponse `json:"data"`
+	}
+
+	gotBody := standardResponse{}
+	err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+	defer rr.Result().Body.Close()
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(gotBody.Data, qt.DeepEquals, dto.NewPersonResponse(personstoretest.NewPerson(t)))
+}
+
+func TestDefaultPersonHandlers_FindMovies(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+peopleV1PathRoot+"/kCBqDtyAkZIfdWjRDXQG/movies", nil)
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+	rr := httptest.NewRecorder()
+	newPersonRouter(t).ServeHTTP(rr, req)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	// standardResponse decodes the response with Data as the dto type
+	type standardResponse struct {
+		Data []dto.MovieResponse `json:"data"`
+	}
+
+	gotBody := standardResponse{}
+	err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+	defer rr.Result().Body.Close()
+	c.Assert(err, qt.IsNil)
+
+	movies, err := moviestoretest.NewMockSelector(t).FindAll(context.Background())
+	c.Assert(err, qt.IsNil)
+	c.Assert(gotBody.Data, qt.DeepEquals, dto.NewMovieResponses(movies))
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const peopleV1PathRoot string = "/v1/people"
+
+// The scopes a user needs to call the person routes
+const (
+	scopePeopleRead  string = "people:read"
+	scopePeopleWrite string = "people:write"
+)
+
+// The metadata of the person routes
+var (
+	metaPeopleRead  = RouteMeta{Scopes: []string{scopePeopleRead}, Resource: "people", Public: true}
+	metaPeopleWrite = RouteMeta{Scopes: []string{scopePeopleWrite}, Resource: "people", AuditCategory: auditCategoryCatalog}
+)
+
+// PersonHandlers are the handlers for the person routes
+type PersonHandlers struct {
+	CreatePersonHandler     CreatePersonHandler
+	UpdatePersonHandler     UpdatePersonHandler
+	DeletePersonHandler     DeletePersonHandler
+	FindPersonByIDHandler   FindPersonByIDHandler
+	FindAllPeopleHandler    FindAllPeopleHandler
+	FindPersonMoviesHandler FindPersonMoviesHandler
+	AddPersonMovieHandler   AddPersonMovieHandler
+}
+
+// registerPersonRoutes registers the person routes to rtr using
+// the handler chain c. State-changing requests can be made as dry
+// runs and are audited using auditHandler, every request is authenticated and authorized using
+// authHandler and responses are cached using rc, if it is not nil.
+func registerPersonRoutes(rtr *mux.Router, c routeChain, auditHandler, authHandler func(http.Handler) http.Handler, rc *ResponseCache, h PersonHandlers) {
+	// Match only POST requests at /api/v1/people
+	rtr.Handle(peopleV1PathRoot,
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.CreatePersonHandler, metaPeopleWrite)).
+		Methods(http.MethodPost)
+
+	// Match only PUT requests having an ID at /api/v1/people/{id}
+	rtr.Handle(peopleV1PathRoot+"/{extlID}",
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.UpdatePersonHandler, metaPeopleWrite)).
+		Methods(http.MethodPut)
+
+	// Match only DELETE requests having an ID at /api/v1/people/{id}
+	rtr.Handle(peopleV1PathRoot+"/{extlID}",
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.DeletePersonHandler, metaPeopleWrite)).
+		Methods(http.MethodDelete)
+
+	// Match only GET requests having an ID at /api/v1/people/{id}
+	rtr.Handle(peopleV1PathRoot+"/{extlID}",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.FindPersonByIDHandler, metaPeopleRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/people
+	rtr.Handle(peopleV1PathRoot,
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.FindAllPeopleHandler, metaPeopleRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/people/{id}/movies
+	rtr.Handle(peopleV1PathRoot+"/{extlID}/movies",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.FindPersonMoviesHandler, metaPeopleRead)).
+		Methods(http.MethodGet)
+
+	// Match only PUT requests at /api/v1/people/{id}/movies/{movieID}
+	rtr.Handle(peopleV1PathRoot+"/{extlID}/movies/{movieExtlID}",
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(h.AddPersonMovieHandler, metaPeopleWrite)).
+		Methods(http.MethodPut)
+}
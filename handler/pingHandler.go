@@ -1,12 +1,12 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
 
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler/dto"
 	"github.com/rs/zerolog/hlog"
 )
 
@@ -25,10 +25,6 @@ type DefaultPingHandler struct {
 
 // Ping handles GET requests for the /ping endpoint
 func (h DefaultPingHandler) Ping(w http.ResponseWriter, r *http.Request) {
-	type pingResponseData struct {
-		DBUp bool `json:"db_up"`
-	}
-
 	// pull logger from request context
 	logger := *hlog.FromRequest(r)
 
@@ -43,7 +39,7 @@ func (h DefaultPingHandler) Ping(w http.ResponseWriter, r *http.Request) {
 		dbok = false
 	}
 
-	pr := pingResponseData{DBUp: dbok}
+	pr := dto.PingResponse{DBUp: dbok}
 
 	response, err := NewStandardResponse(r, pr)
 	if err != nil {
@@ -52,7 +48,7 @@ func (h DefaultPingHandler) Ping(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Encode response struct to JSON for the response body
-	err = json.NewEncoder(w).Encode(response)
+	err = writeJSON(w, response)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
 		return
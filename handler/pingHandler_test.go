@@ -2,7 +2,6 @@ package handler
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/datastore/pingstore/pingstoretest"
 
 	"github.com/rs/zerolog/hlog"
 
@@ -20,14 +20,11 @@ import (
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
 	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/handler/dto"
 )
 
 func TestDefaultPingHandler_Ping(t *testing.T) {
 	t.Run("typical", func(t *testing.T) {
-		if os.Getenv("NO_DB") == "true" {
-			t.Skip("skipping db dependent test")
-		}
-
 		c := qt.New(t)
 		var emptyBody []byte
 
@@ -65,15 +62,12 @@ func TestDefaultPingHandler_Ping(t *testing.T) {
 			Then(pingHandler)
 		h.ServeHTTP(rr, req)
 
-		type pingResponseData struct {
-			DBUp bool `json:"db_up"`
-		}
 		type standardResponse struct {
 			Path      string           `json:"path"`
 			RequestID string           `json:"request_id"`
-			Data      pingResponseData `json:"data"`
+			Data      dto.PingResponse `json:"data"`
 		}
-		prd := pingResponseData{DBUp: true}
+		prd := dto.PingResponse{DBUp: true}
 		wantBody := &standardResponse{Path: path, RequestID: requestID, Data: prd}
 
 		gotBody := new(standardResponse)
@@ -92,7 +86,7 @@ func TestDefaultPingHandler_Ping(t *testing.T) {
 		var emptyBody []byte
 
 		lgr := logger.NewLogger(os.Stdout, true)
-		mp := mockPinger{}
+		mp := pingstoretest.NewMockPinger(t)
 		dph := DefaultPingHandler{
 			Pinger: mp,
 		}
@@ -121,15 +115,12 @@ func TestDefaultPingHandler_Ping(t *testing.T) {
 			Then(pingHandler)
 		h.ServeHTTP(rr, req)
 
-		type pingResponseData struct {
-			DBUp bool `json:"db_up"`
-		}
 		type standardResponse struct {
 			Path      string           `json:"path"`
 			RequestID string           `json:"request_id"`
-			Data      pingResponseData `json:"data"`
+			Data      dto.PingResponse `json:"data"`
 		}
-		prd := pingResponseData{DBUp: true}
+		prd := dto.PingResponse{DBUp: true}
 		wantBody := &standardResponse{Path: path, RequestID: requestID, Data: prd}
 
 		gotBody := new(standardResponse)
@@ -144,9 +135,3 @@ func TestDefaultPingHandler_Ping(t *testing.T) {
 	})
 
 }
-
-type mockPinger struct{}
-
-func (m mockPinger) PingDB(ctx context.Context) error {
-	return nil
-}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// Headers set by proxies to pass on the client's IP address and the
+// scheme it used
+const (
+	forwardedForHeader   string = "X-Forwarded-For"
+	forwardedProtoHeader string = "X-Forwarded-Proto"
+)
+
+// TrustedProxies are the networks of the proxies (load balancers)
+// trusted to set the X-Forwarded-For and X-Forwarded-Proto headers.
+// The headers of a request from any other address are ignored, as
+// a client can set them to anything.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses s, a comma separated list of CIDRs
+// and IP addresses, e.g. 10.0.0.0/8,35.191.0.0/16,::1. An empty s
+// trusts no proxy.
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	var tp TrustedProxies
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, errs.E(errs.Validation, errs.Parameter("trusted-proxies"), errors.Errorf("invalid IP address %q", v))
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			tp = append(tp, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, errs.E(errs.Validation, errs.Parameter("trusted-proxies"), errors.Errorf("invalid CIDR %q", v))
+		}
+		tp = append(tp, ipNet)
+	}
+	return tp, nil
+}
+
+// trusts reports whether the address addr is of a trusted proxy
+func (tp TrustedProxies) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// client returns the client which made r. If r comes from a trusted
+// proxy, the client IP is the last address of X-Forwarded-For which
+// is not of a trusted proxy (the addresses before it may have been
+// set by the client) and the scheme is the first of
+// X-Forwarded-Proto. Otherwise the headers are ignored and the
+// client is the peer of the connection. The user agent and client
+// version are taken from the headers of r either way.
+func (tp TrustedProxies) client(r *http.Request) requestinfo.Client {
+	c := requestinfo.Client{
+		IP:        r.RemoteAddr,
+		Scheme:    "http",
+		UserAgent: userAgent(r),
+		Version:   clientVersion(r),
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		c.IP = host
+	}
+	if r.TLS != nil {
+		c.Scheme = "https"
+	}
+
+	if !tp.trusts(c.IP) {
+		return c
+	}
+
+	addrs := headerList(r.Header.Values(forwardedForHeader))
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if net.ParseIP(addrs[i]) == nil {
+			break
+		}
+		c.IP = addrs[i]
+		if !tp.trusts(addrs[i]) {
+			break
+		}
+	}
+
+	if protos := headerList(r.Header.Values(forwardedProtoHeader)); len(protos) > 0 {
+		switch p := strings.ToLower(protos[0]); p {
+		case "http", "https":
+			c.Scheme = p
+		}
+	}
+
+	return c
+}
+
+// headerList returns the comma separated elements of the values of
+// a header, in order
+func headerList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+	}
+	return list
+}
+
+// ClientHandler middleware sets the client which made the request
+// to the request context (see requestinfo.ClientFromContext), taking
+// the X-Forwarded-For and X-Forwarded-Proto headers into account only
+// if the request comes from one of tp
+func ClientHandler(tp TrustedProxies) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := requestinfo.WithClient(r.Context(), tp.client(r))
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// remoteIPHandler adds the IP address of the client as a field to
+// the context's logger using fieldKey as field key, and its scheme
+// as scheme. The client set by ClientHandler is used if there is
+// one, or else the peer of the connection.
+func remoteIPHandler(fieldKey string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := requestClient(r)
+			lgr := zerolog.Ctx(r.Context())
+			lgr.UpdateContext(func(zc zerolog.Context) zerolog.Context {
+				return zc.Str(fieldKey, c.IP).Str("scheme", c.Scheme)
+			})
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestClient returns the client set to the context of r by
+// ClientHandler, or else the client as seen without trusting any
+// proxy
+func requestClient(r *http.Request) requestinfo.Client {
+	if c, ok := requestinfo.ClientFromContext(r.Context()); ok {
+		return c
+	}
+	return TrustedProxies(nil).client(r)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"cidrs and ips", "10.0.0.0/8, 35.191.0.0/16,127.0.0.1,::1", []string{"10.0.0.0/8", "35.191.0.0/16", "127.0.0.1/32", "::1/128"}, false},
+		{"invalid ip", "10.0.0", nil, true},
+		{"invalid cidr", "10.0.0.0/33", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := ParseTrustedProxies(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			var nets []string
+			for _, n := range got {
+				nets = append(nets, n.String())
+			}
+			c.Assert(nets, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestTrustedProxies_client(t *testing.T) {
+	tp, err := ParseTrustedProxies("10.0.0.0/8")
+	qt.Assert(t, err, qt.IsNil)
+
+	tests := []struct {
+		name       string
+		tp         TrustedProxies
+		remoteAddr string
+		tls        bool
+		forwarded  []string
+		proto      string
+		want       requestinfo.Client
+	}{
+		{"no proxy", tp, "203.0.113.7:1234", false, nil, "", requestinfo.Client{IP: "203.0.113.7", Scheme: "http"}},
+		{"tls", tp, "203.0.113.7:1234", true, nil, "", requestinfo.Client{IP: "203.0.113.7", Scheme: "https"}},
+		{"untrusted proxy", tp, "198.51.100.1:1234", false, []string{"203.0.113.7"}, "https", requestinfo.Client{IP: "198.51.100.1", Scheme: "http"}},
+		{"no proxy trusted", nil, "10.0.0.1:1234", false, []string{"203.0.113.7"}, "https", requestinfo.Client{IP: "10.0.0.1", Scheme: "http"}},
+		{"trusted proxy", tp, "10.0.0.1:1234", false, []string{"203.0.113.7"}, "https", requestinfo.Client{IP: "203.0.113.7", Scheme: "https"}},
+		{"trusted proxies", tp, "10.0.0.1:1234", false, []string{"203.0.113.7, 10.0.0.2"}, "https,http", requestinfo.Client{IP: "203.0.113.7", Scheme: "https"}},
+		{"spoofed by client", tp, "10.0.0.1:1234", false, []string{"192.0.2.1", "203.0.113.7"}, "", requestinfo.Client{IP: "203.0.113.7", Scheme: "http"}},
+		{"all trusted", tp, "10.0.0.1:1234", false, []string{"10.0.0.3, 10.0.0.2"}, "", requestinfo.Client{IP: "10.0.0.3", Scheme: "http"}},
+		{"invalid address", tp, "10.0.0.1:1234", false, []string{"203.0.113.7, unknown"}, "", requestinfo.Client{IP: "10.0.0.1", Scheme: "http"}},
+		{"invalid proto", tp, "10.0.0.1:1234", true, nil, "gopher", requestinfo.Client{IP: "10.0.0.1", Scheme: "https"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for _, v := range tt.forwarded {
+				r.Header.Add(forwardedForHeader, v)
+			}
+			if tt.proto != "" {
+				r.Header.Set(forwardedProtoHeader, tt.proto)
+			}
+
+			qt.Assert(t, tt.tp.client(r), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestClientHandler(t *testing.T) {
+	c := qt.New(t)
+
+	tp, err := ParseTrustedProxies("10.0.0.1")
+	c.Assert(err, qt.IsNil)
+
+	var buf bytes.Buffer
+	lgr := zerolog.New(&buf)
+
+	var got requestinfo.Client
+	h := alice.New(ClientHandler(tp)).
+		Extend(LoggerHandlerChain(lgr, alice.New())).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = requestinfo.ClientFromContext(r.Context())
+		})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set(forwardedForHeader, "203.0.113.7")
+	r.Header.Set(forwardedProtoHeader, "https")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	c.Assert(got, qt.Equals, requestinfo.Client{IP: "203.0.113.7", Scheme: "https"})
+	c.Assert(buf.String(), qt.Contains, `"remote_ip":"203.0.113.7","scheme":"https"`)
+}
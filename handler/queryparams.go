@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+)
+
+// ParamType is the type the value of a query parameter must have
+type ParamType int
+
+// Query parameter types. TimeParam values are RFC 3339 timestamps.
+const (
+	StringParam ParamType = iota
+	IntParam
+	BoolParam
+	TimeParam
+)
+
+// QueryParam declares a query parameter accepted by a route and
+// what its value must be. Min and Max bound an IntParam when they
+// are not zero. If Enum is set, a StringParam must be one of its
+// values.
+type QueryParam struct {
+	Name     string
+	Type     ParamType
+	Required bool
+	Min      int
+	Max      int
+	Enum     []string
+}
+
+// validate returns an error if the value v is not valid for p
+func (p QueryParam) validate(v string) error {
+	switch p.Type {
+	case IntParam:
+		i, err := strconv.Atoi(v)
+		switch {
+		case p.Min != 0 && p.Max != 0:
+			if err != nil || i < p.Min || i > p.Max {
+				return errors.Errorf("%s must be a number between %d and %d", p.Name, p.Min, p.Max)
+			}
+		case p.Min != 0:
+			if err != nil || i < p.Min {
+				return errors.Errorf("%s must be a number of at least %d", p.Name, p.Min)
+			}
+		case p.Max != 0:
+			if err != nil || i > p.Max {
+				return errors.Errorf("%s must be a number of at most %d", p.Name, p.Max)
+			}
+		case err != nil:
+			return errors.Errorf("%s must be a number", p.Name)
+		}
+	case BoolParam:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return errors.Errorf("%s must be true or false", p.Name)
+		}
+	case TimeParam:
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return errors.Errorf("%s must be an RFC 3339 timestamp", p.Name)
+		}
+	default:
+		if len(p.Enum) > 0 && !contains(p.Enum, v) {
+			return errors.Errorf("%s must be one of %s", p.Name, strings.Join(p.Enum, ", "))
+		}
+	}
+
+	return nil
+}
+
+// contains reports whether s is in values
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateQuery validates the query parameters q against params.
+// Every parameter failing validation is returned in a single
+// Validation error wrapping errs.InvalidParams. Parameters not in
+// params are ignored.
+func validateQuery(q url.Values, params []QueryParam) error {
+	var ip errs.InvalidParams
+	for _, p := range params {
+		v := strings.TrimSpace(q.Get(p.Name))
+		if v == "" {
+			if p.Required {
+				ip = append(ip, errs.E(errs.Validation, errs.Parameter(p.Name), errs.MissingField(p.Name)))
+			}
+			continue
+		}
+		if err := p.validate(v); err != nil {
+			ip = append(ip, errs.E(errs.Validation, errs.Parameter(p.Name), err))
+		}
+	}
+
+	switch len(ip) {
+	case 0:
+		return nil
+	case 1:
+		return errs.E(errs.Validation, errs.Code("invalid_parameters"), errs.Parameter(ip[0].(*errs.Error).Param), ip)
+	}
+	return errs.E(errs.Validation, errs.Code("invalid_parameters"), ip)
+}
+
+// QueryParamsHandler returns middleware which validates the query
+// parameters of a request against params before the handler runs,
+// so handlers can rely on the values being well formed. If any are
+// not valid, a 400 is sent naming each bad parameter.
+func QueryParamsHandler(params ...QueryParam) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				err := validateQuery(r.URL.Query(), params)
+				if err != nil {
+					errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+					return
+				}
+				h.ServeHTTP(w, r) // call original
+			})
+	}
+}
+
+// The query parameters accepted by routes
+var (
+	// pageParams are the pagination query parameters. The maximum
+	// page_size is configured per route, so is checked by
+	// newPageRequest.
+	pageParams = []QueryParam{
+		{Name: "page", Type: IntParam, Min: 1},
+		{Name: "page_size", Type: IntParam, Min: 1},
+	}
+	// findAllMoviesParams are the query parameters of the
+	// FindAllMovies handler
+	findAllMoviesParams = append([]QueryParam{{Name: "filter"}}, pageParams...)
+	// findMoviesByIDsParams are the query parameters of the
+	// FindMoviesByIDs handler
+	findMoviesByIDsParams = []QueryParam{
+		{Name: "ids", Required: true},
+	}
+	// suggestMoviesParams are the query parameters of the Suggest
+	// handler
+	suggestMoviesParams = []QueryParam{
+		{Name: "q", Required: true},
+		{Name: "limit", Type: IntParam, Min: 1, Max: maxSuggestLimit},
+	}
+	// countMoviesParams are the query parameters of the CountMovies
+	// handler
+	countMoviesParams = []QueryParam{
+		{Name: "mode", Enum: []string{string(movie.CountExact), string(movie.CountEstimate)}},
+	}
+	// findAuditRecordsParams are the query parameters of the
+	// FindAuditRecords handler
+	findAuditRecordsParams = []QueryParam{
+		{Name: "since", Type: TimeParam},
+		{Name: "until", Type: TimeParam},
+		{Name: "limit", Type: IntParam, Min: 1, Max: maxAuditLimit},
+		{Name: "method", Enum: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}},
+	}
+)
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func Test_validateQuery(t *testing.T) {
+	params := []QueryParam{
+		{Name: "q", Required: true},
+		{Name: "page", Type: IntParam, Min: 1},
+		{Name: "size", Type: IntParam, Min: 1, Max: 10},
+		{Name: "count", Type: IntParam},
+		{Name: "on", Type: BoolParam},
+		{Name: "since", Type: TimeParam},
+		{Name: "order", Enum: []string{"asc", "desc"}},
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantParams []string
+	}{
+		{"valid", "q=a&page=2&size=10&count=-1&on=true&since=2008-01-08T06:54:00Z&order=asc", nil},
+		{"only required", "q=a", nil},
+		{"unknown ignored", "q=a&other=x", nil},
+		{"missing required", "page=1", []string{"q"}},
+		{"blank required", "q=+", []string{"q"}},
+		{"below min", "q=a&page=0", []string{"page"}},
+		{"above max", "q=a&size=11", []string{"size"}},
+		{"not a number", "q=a&count=x", []string{"count"}},
+		{"not a bool", "q=a&on=maybe", []string{"on"}},
+		{"not a time", "q=a&since=yesterday", []string{"since"}},
+		{"not in enum", "q=a&order=up", []string{"order"}},
+		{"several", "page=x&size=0&order=up", []string{"q", "page", "size", "order"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			q, err := url.ParseQuery(tt.query)
+			c.Assert(err, qt.IsNil)
+
+			err = validateQuery(q, params)
+			if tt.wantParams == nil {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+
+			var ip errs.InvalidParams
+			c.Assert(errors.As(err, &ip), qt.IsTrue)
+			var got []string
+			for _, e := range ip {
+				got = append(got, string(e.(*errs.Error).Param))
+			}
+			c.Assert(got, qt.DeepEquals, tt.wantParams)
+		})
+	}
+}
+
+func TestQueryParamsHandler(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	var called bool
+	h := LoggerHandlerChain(lgr, alice.New()).
+		Append(QueryParamsHandler(suggestMoviesParams...)).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+
+	// the handler is not called when a parameter is not valid and
+	// each bad parameter is named in the response
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/?limit=100", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	c.Assert(called, qt.IsFalse)
+
+	var body errs.ErrResponse
+	c.Assert(json.NewDecoder(rr.Body).Decode(&body), qt.IsNil)
+	c.Assert(body.Error.Code, qt.Equals, "invalid_parameters")
+	c.Assert(body.Error.Errors, qt.HasLen, 2)
+	c.Assert(body.Error.Errors[0].Param, qt.Equals, "q")
+	c.Assert(body.Error.Errors[1].Param, qt.Equals, "limit")
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/?q=rep&limit=5", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(called, qt.IsTrue)
+}
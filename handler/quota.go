@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// trackQuota counts the request against the user's quota. If the
+// user has exceeded their quota, the Retry-After header is set to
+// the number of seconds until the exceeded window resets and the
+// error is returned. Requests of the anonymous user are not counted,
+// as they would all share one quota.
+func trackQuota(ctx context.Context, w http.ResponseWriter, t quota.Tracker, u user.User) error {
+	if u.IsAnonymous() {
+		return nil
+	}
+
+	usage, err := t.Track(ctx, u)
+	if err == nil || !errs.KindIs(errs.TooManyRequests, err) {
+		return err
+	}
+
+	// if more than one window is exceeded, the request can only be
+	// retried once the last of them resets
+	var resetsAt time.Time
+	for _, wu := range usage {
+		if wu.Exceeded() && wu.ResetsAt.After(resetsAt) {
+			resetsAt = wu.ResetsAt
+		}
+	}
+	if !resetsAt.IsZero() {
+		secs := int64(time.Until(resetsAt)/time.Second) + 1
+		w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	}
+
+	return err
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// stubTracker returns the given usage and error from Track
+type stubTracker struct {
+	usage []quota.Usage
+	err   error
+}
+
+func (s stubTracker) Track(ctx context.Context, u user.User) ([]quota.Usage, error) {
+	return s.usage, s.err
+}
+
+func (s stubTracker) Usage(ctx context.Context, u user.User) ([]quota.Usage, error) {
+	return s.usage, nil
+}
+
+func Test_trackQuota(t *testing.T) {
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+	now := time.Now()
+
+	dayUsage := quota.Usage{Window: quota.Day, Limit: 10, Used: 11, ResetsAt: now.Add(time.Hour)}
+	monthUsage := quota.Usage{Window: quota.Month, Limit: 100, Used: 101, ResetsAt: now.Add(48 * time.Hour)}
+	underUsage := quota.Usage{Window: quota.Month, Limit: 100, Used: 11, ResetsAt: now.Add(48 * time.Hour)}
+	quotaErr := errs.E(errs.TooManyRequests, "quota exceeded")
+
+	tests := []struct {
+		name           string
+		tracker        stubTracker
+		wantKind       errs.Kind
+		wantRetryAfter time.Duration
+	}{
+		{"under quota", stubTracker{usage: []quota.Usage{underUsage}}, errs.Other, 0},
+		{"daily exceeded", stubTracker{[]quota.Usage{dayUsage, underUsage}, quotaErr}, errs.TooManyRequests, time.Hour},
+		{"daily and monthly exceeded", stubTracker{[]quota.Usage{dayUsage, monthUsage}, quotaErr}, errs.TooManyRequests, 48 * time.Hour},
+		{"database error", stubTracker{err: errs.E(errs.Database, "db down")}, errs.Database, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rr := httptest.NewRecorder()
+
+			err := trackQuota(ctx, rr, tt.tracker, u)
+			if tt.wantKind == errs.Other {
+				c.Assert(err, qt.IsNil)
+			} else {
+				c.Assert(errs.KindIs(tt.wantKind, err), qt.IsTrue)
+			}
+
+			if tt.wantRetryAfter == 0 {
+				c.Assert(rr.Header().Get("Retry-After"), qt.Equals, "")
+				return
+			}
+			secs, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+			c.Assert(err, qt.IsNil)
+			c.Assert(time.Duration(secs)*time.Second > tt.wantRetryAfter-time.Minute, qt.IsTrue)
+			c.Assert(time.Duration(secs)*time.Second <= tt.wantRetryAfter+time.Second, qt.IsTrue)
+		})
+	}
+}
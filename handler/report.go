@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// reportTimeout is how long an ErrorReporter is given to report an
+// error
+const reportTimeout = 10 * time.Second
+
+// ErrorReportingHandler returns middleware which reports server
+// errors (HTTP 5xx responses) using rep, with the request ID, user
+// and route of the request. The error reported is the one sent by
+// errs.HTTPErrorResponse, or the panic recovered by RecoveryHandler,
+// which must be added after this middleware. Errors are reported
+// once the response is sent, without holding up the request; an
+// error reporting the error is logged. It must be added after
+// LoggerHandlerChain so the request ID is set.
+func ErrorReportingHandler(rep errs.ErrorReporter) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				er := &errorRecorder{statusRecorder: &statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+				ctx := context.WithValue(r.Context(), contextKeyErrorRecorder, er)
+
+				h.ServeHTTP(er, r.WithContext(ctx)) // call original
+
+				if er.err == nil && er.status < http.StatusInternalServerError {
+					return
+				}
+
+				report := errs.Report{
+					Err:    er.err,
+					Time:   time.Now(),
+					Status: er.status,
+					User:   er.user,
+					Method: r.Method,
+					Route:  routeTemplate(r),
+					URL:    r.URL.String(),
+				}
+				if report.Err == nil {
+					report.Err = errors.Errorf("%d response sent without an error", er.status)
+				}
+				report.RequestID, _ = requestinfo.RequestIDFromContext(r.Context())
+
+				logger := *hlog.FromRequest(r)
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+					defer cancel()
+
+					if err := rep.Report(ctx, report); err != nil {
+						logger.Error().Err(err).Msg("error reporting error")
+					}
+				}()
+			})
+	}
+}
+
+// errorRecorder is an http.ResponseWriter which records the status
+// code, the server error sent and the user who made the request,
+// for ErrorReportingHandler
+type errorRecorder struct {
+	*statusRecorder
+	err  error
+	user string
+}
+
+// RecordError records err, unless an error has already been recorded
+func (er *errorRecorder) RecordError(err error) {
+	if er.err == nil {
+		er.err = err
+	}
+}
+
+// contextKey is the type of the keys of the values the handler
+// middleware keeps to itself in the request context. The values
+// shared with other packages are set using requestinfo.
+type contextKey string
+
+const contextKeyErrorRecorder = contextKey("error-recorder")
+
+// setReportUser sets the user who made the request for the report
+// of a server error. It does nothing if the request is not handled
+// by ErrorReportingHandler.
+func setReportUser(ctx context.Context, email string) {
+	if er, ok := ctx.Value(contextKeyErrorRecorder).(*errorRecorder); ok {
+		er.user = email
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// chanReporter is an errs.ErrorReporter sending reports to a channel
+type chanReporter chan errs.Report
+
+func (ch chanReporter) Report(ctx context.Context, r errs.Report) error {
+	ch <- r
+	return nil
+}
+
+func TestErrorReportingHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantReport bool
+		wantStatus int
+		wantErr    string
+		wantUser   string
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			setReportUser(r.Context(), "otto.maddox711@gmail.com")
+			errs.HTTPErrorResponse(w, logger.NewLogger(ioutil.Discard, true), errs.E(errs.Database, errors.New("connection reset")))
+		}, true, http.StatusInternalServerError, "connection reset", "otto.maddox711@gmail.com"},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}, true, http.StatusInternalServerError, "panic: boom", ""},
+		{"5xx without error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, true, http.StatusBadGateway, "502 response sent without an error", ""},
+		{"client error", func(w http.ResponseWriter, r *http.Request) {
+			errs.HTTPErrorResponse(w, logger.NewLogger(ioutil.Discard, true), errs.E(errs.Validation, errors.New("bad title")))
+		}, false, 0, "", ""},
+		{"ok", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, false, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rep := make(chanReporter, 1)
+			lgr := logger.NewLogger(ioutil.Discard, true)
+			chain := LoggerHandlerChain(lgr, alice.New()).
+				Append(ErrorReportingHandler(rep)).
+				Append(RecoveryHandler)
+
+			rtr := mux.NewRouter()
+			rtr.Handle("/api/v1/movies/{extlID}", chain.Then(tt.handler))
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/movies/abc", nil)
+			rtr.ServeHTTP(rr, req)
+
+			if !tt.wantReport {
+				select {
+				case r := <-rep:
+					c.Fatalf("unexpected report %+v", r)
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			var r errs.Report
+			select {
+			case r = <-rep:
+			case <-time.After(time.Second):
+				c.Fatal("no error reported")
+			}
+			c.Assert(r.Err, qt.ErrorMatches, tt.wantErr)
+			c.Assert(r.Status, qt.Equals, tt.wantStatus)
+			c.Assert(r.User, qt.Equals, tt.wantUser)
+			c.Assert(r.Method, qt.Equals, http.MethodGet)
+			c.Assert(r.Route, qt.Equals, "/api/v1/movies/{extlID}")
+			c.Assert(r.URL, qt.Equals, "/api/v1/movies/abc")
+			c.Assert(r.RequestID, qt.Equals, rr.Header().Get(errs.RequestIDHeader))
+			c.Assert(r.RequestID, qt.Not(qt.Equals), "")
+			c.Assert(r.Time.IsZero(), qt.IsFalse)
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// ResponseStore stores the responses cached by a ResponseCache
+type ResponseStore interface {
+	// Get returns the value stored for key. The boolean is false if
+	// there is none or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for key, to expire after ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// DeletePrefix deletes the values of every key starting with
+	// prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// DefaultResponseCacheTTL is how long a response is cached if no TTL
+// is given
+const DefaultResponseCacheTTL = 30 * time.Second
+
+// maxCachedBodyBytes is the size of the largest response body cached
+const maxCachedBodyBytes int = 1 << 20
+
+// responseCacheKeyPrefix is the prefix of the keys of every cached
+// response
+const responseCacheKeyPrefix string = "resp:"
+
+// responseCacheHeader tells the client whether the response was
+// served from the cache (HIT) or not (MISS)
+const responseCacheHeader string = "X-Cache"
+
+// invalidatedResources are the resources whose cached responses are
+// invalidated by a write to a resource, other than the resource
+// itself. The movies of a person are listed under people, so a
+// change to a movie invalidates them, and erasing a user's data
+// changes the movies and people the user created or updated.
+var invalidatedResources = map[string][]string{
+	"movies": {"people"},
+	"users":  {"movies", "people"},
+}
+
+// uncachedHeaders are the response headers which are not cached, as
+// they are set for each request
+var uncachedHeaders = []string{errs.RequestIDHeader, responseCacheHeader, "Retry-After"}
+
+// ResponseCache caches the successful responses of GET requests in a
+// ResponseStore, keyed by the resource of the route, the tenant, the
+// user, the path and query and the Accept header, for TTL. A
+// successful write to a resource deletes the cached responses of the
+// resource, as does a change published on the event bus (see
+// Subscribe). The request quota of a user is tracked for a cached
+// response too.
+type ResponseCache struct {
+	Store        ResponseStore
+	TTL          time.Duration
+	QuotaTracker quota.Tracker
+}
+
+// NewResponseCache is an initializer for ResponseCache. A ttl of
+// zero is DefaultResponseCacheTTL.
+func NewResponseCache(store ResponseStore, ttl time.Duration, t quota.Tracker) *ResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	return &ResponseCache{Store: store, TTL: ttl, QuotaTracker: t}
+}
+
+// Enabled reports whether responses are cached, i.e. rc is not nil
+func (rc *ResponseCache) Enabled() bool {
+	return rc != nil && rc.Store != nil
+}
+
+// cachedResponse is a response held in the ResponseStore
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// RequestID is the ID of the request the response was written
+	// for, which is replaced by the ID of the request it is served to
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Handler middleware serves GET requests from the cache and caches
+// their successful responses, and invalidates the cache on
+// successful writes, other than dry runs. It must be added after the
+// auth middleware, as responses are cached for each user.
+func (rc *ResponseCache) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		lgr := *hlog.FromRequest(r)
+		meta, _ := requestinfo.RouteMetaFromContext(ctx)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(sr, r)
+			if sr.status < http.StatusBadRequest && !requestinfo.DryRun(ctx) {
+				rc.invalidate(ctx, meta.Resource, lgr)
+			}
+			return
+		}
+
+		u, ok := requestinfo.UserFromContext(ctx)
+		if !ok || meta.Resource == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key := responseCacheKey(meta.Resource, u, r)
+
+		// Cache-Control: no-cache asks for a fresh response, which is
+		// cached for the requests which follow
+		if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			if cr, ok := rc.get(ctx, key, lgr); ok {
+				rc.serve(w, r, cr, u, lgr)
+				return
+			}
+		}
+
+		w.Header().Set(responseCacheHeader, "MISS")
+		rr := &responseRecorder{statusRecorder: &statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+		h.ServeHTTP(rr, r)
+
+		// a list whose streaming failed part way has a 200 status but
+		// a truncated body, which is not valid JSON
+		if rr.status != http.StatusOK || rr.tooLarge || r.Method == http.MethodHead || !json.Valid(rr.body.Bytes()) {
+			return
+		}
+		cr := cachedResponse{Status: rr.status, Header: rr.Header().Clone(), Body: rr.body.Bytes()}
+		for _, name := range uncachedHeaders {
+			cr.Header.Del(name)
+		}
+		if id, ok := requestinfo.RequestIDFromContext(ctx); ok {
+			cr.RequestID = id
+		}
+		rc.set(ctx, key, cr, lgr)
+	})
+}
+
+// Subscribe invalidates the cached responses of movies on each
+// event.MovieChanged of s, which is published for the changes made
+// by every instance of the server, and the cached responses of a
+// resource on each event.ChangesMissed. The returned function
+// unsubscribes.
+func (rc *ResponseCache) Subscribe(s event.Subscriber) func() {
+	unsubChanged := event.OnMovieChanged(s, func(ctx context.Context, e event.MovieChanged) {
+		rc.invalidate(ctx, "movies", *zerolog.Ctx(ctx))
+	})
+	unsubMissed := event.OnChangesMissed(s, func(ctx context.Context, e event.ChangesMissed) {
+		rc.invalidate(ctx, e.Resource, *zerolog.Ctx(ctx))
+	})
+
+	return func() {
+		unsubChanged()
+		unsubMissed()
+	}
+}
+
+// Purge deletes every cached response, e.g. after the data has been
+// restored, so no response read before the restore is served
+func (rc *ResponseCache) Purge(ctx context.Context) error {
+	return rc.Store.DeletePrefix(ctx, responseCacheKeyPrefix)
+}
+
+// get returns the response cached for key. A response which cannot
+// be read from the store is logged and treated as not cached, so
+// the store being down only makes requests slower.
+func (rc *ResponseCache) get(ctx context.Context, key string, lgr zerolog.Logger) (cachedResponse, bool) {
+	b, ok, err := rc.Store.Get(ctx, key)
+	if err != nil {
+		lgr.Warn().Err(err).Msg("response cache get failed")
+		return cachedResponse{}, false
+	}
+	if !ok {
+		return cachedResponse{}, false
+	}
+
+	var cr cachedResponse
+	if err = json.Unmarshal(b, &cr); err != nil {
+		lgr.Warn().Err(err).Msg("response cache entry invalid")
+		return cachedResponse{}, false
+	}
+	return cr, true
+}
+
+// set caches cr for key. A failure is logged only.
+func (rc *ResponseCache) set(ctx context.Context, key string, cr cachedResponse, lgr zerolog.Logger) {
+	b, err := json.Marshal(cr)
+	if err != nil {
+		lgr.Warn().Err(err).Msg("response cache entry cannot be encoded")
+		return
+	}
+	if err = rc.Store.Set(ctx, key, b, rc.TTL); err != nil {
+		lgr.Warn().Err(err).Msg("response cache set failed")
+	}
+}
+
+// invalidate deletes the cached responses of resource and of the
+// resources listed for it in invalidatedResources. A failure is
+// logged only: the responses expire after the TTL anyway.
+func (rc *ResponseCache) invalidate(ctx context.Context, resource string, lgr zerolog.Logger) {
+	if resource == "" {
+		return
+	}
+	for _, res := range append([]string{resource}, invalidatedResources[resource]...) {
+		if err := rc.Store.DeletePrefix(ctx, responseCachePrefix(res)); err != nil {
+			lgr.Warn().Err(err).Str("resource", res).Msg("response cache invalidation failed")
+		}
+	}
+}
+
+// serve writes the cached response cr for r. The quota of u is
+// tracked as for a response which is not cached, and a request whose
+// copy is still current (by ETag or Last-Modified) is sent a 304 Not
+// Modified.
+func (rc *ResponseCache) serve(w http.ResponseWriter, r *http.Request, cr cachedResponse, u user.User, lgr zerolog.Logger) {
+	if rc.QuotaTracker != nil {
+		if err := trackQuota(r.Context(), w, rc.QuotaTracker, u); err != nil {
+			errs.HTTPErrorResponse(w, lgr, err)
+			return
+		}
+	}
+
+	for k, v := range cr.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(responseCacheHeader, "HIT")
+
+	if notModified(r, w.Header()) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := cr.Body
+	if id, ok := requestinfo.RequestIDFromContext(r.Context()); ok && cr.RequestID != "" {
+		// request IDs are xids, which are only letters and digits,
+		// so the quoted ID can only be the request_id field
+		body = bytes.Replace(body, []byte(`"`+cr.RequestID+`"`), []byte(`"`+id+`"`), 1)
+	}
+
+	w.WriteHeader(cr.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// notModified reports whether the client's copy of a response with
+// header h is still current, per the If-None-Match or, if it is not
+// sent, the If-Modified-Since header of r
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		return etag != "" && etagMatch(inm, etag)
+	}
+
+	ims, lm := r.Header.Get("If-Modified-Since"), h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	imsTime, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	lmTime, err := http.ParseTime(lm)
+	return err == nil && !lmTime.After(imsTime)
+}
+
+// responseCachePrefix returns the prefix of the keys of the responses
+// cached for resource
+func responseCachePrefix(resource string) string {
+	return responseCacheKeyPrefix + resource + ":"
+}
+
+// responseCacheKey returns the key of the response to r for u, with
+// r routed to a route acting on resource. The tenant of r is part of
+// the key, as the same user can read the data of more than one
+// tenant. The user is hashed, so the keys do not hold personal data.
+func responseCacheKey(resource string, u user.User, r *http.Request) string {
+	id := u.Subject
+	if id == "" {
+		id = u.Email
+	}
+	sum := sha256.Sum256([]byte(id))
+	tenant, _ := requestinfo.TenantFromContext(r.Context())
+
+	return responseCachePrefix(resource) + tenant + ":" + hex.EncodeToString(sum[:16]) + ":" + r.URL.RequestURI() + "|" + r.Header.Get("Accept")
+}
+
+// responseRecorder is an http.ResponseWriter which captures the
+// response body written, up to maxCachedBodyBytes, for ResponseCache
+type responseRecorder struct {
+	*statusRecorder
+	body bytes.Buffer
+	// tooLarge is true if the body is larger than maxCachedBodyBytes,
+	// in which case it is not cached
+	tooLarge bool
+}
+
+// Write captures b and writes it to the underlying ResponseWriter
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if !rr.tooLarge {
+		if rr.body.Len()+len(b) > maxCachedBodyBytes {
+			rr.tooLarge = true
+			rr.body = bytes.Buffer{}
+		} else {
+			rr.body.Write(b)
+		}
+	}
+	return rr.statusRecorder.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter, if it can, so a list
+// streamed to the client is still written as it is read
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// newResponseCacheTestHandler returns a handler serving the movies
+// resource through rc for the user with email, and a pointer to the
+// number of requests which reached the movies handler
+func newResponseCacheTestHandler(rc *ResponseCache, email string) (http.Handler, *int) {
+	var calls int
+	movies := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		id, _ := requestinfo.RequestIDFromContext(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = fmt.Fprintf(w, `{"request_id":%q,"calls":%d}`, id, calls)
+	})
+
+	withUser := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := requestinfo.WithUser(r.Context(), user.User{Email: email})
+			ctx = requestinfo.WithRouteMeta(ctx, requestinfo.RouteMeta{Resource: "movies"})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	return alice.New(
+		hlog.NewHandler(zerolog.Nop()),
+		hlog.RequestIDHandler("request_id", errs.RequestIDHeader),
+		withUser,
+		rc.Handler,
+	).Then(movies), &calls
+}
+
+func TestResponseCache_Handler(t *testing.T) {
+	t.Run("miss then hit", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, calls := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/movies?limit=2", nil))
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+		c.Assert(rr.Header().Get(responseCacheHeader), qt.Equals, "MISS")
+
+		rr2 := httptest.NewRecorder()
+		h.ServeHTTP(rr2, httptest.NewRequest(http.MethodGet, "/api/v1/movies?limit=2", nil))
+		c.Assert(rr2.Code, qt.Equals, http.StatusOK)
+		c.Assert(rr2.Header().Get(responseCacheHeader), qt.Equals, "HIT")
+		c.Assert(rr2.Header().Get("Content-Type"), qt.Equals, "application/json")
+		c.Assert(*calls, qt.Equals, 1)
+
+		// the request ID in the body and header is that of the request
+		// the response is served to
+		id := rr2.Header().Get(errs.RequestIDHeader)
+		c.Assert(id, qt.Not(qt.Equals), rr.Header().Get(errs.RequestIDHeader))
+		c.Assert(rr2.Body.String(), qt.Equals, fmt.Sprintf(`{"request_id":%q,"calls":1}`, id))
+	})
+
+	t.Run("keyed by query and user", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, calls := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+		h2, calls2 := newResponseCacheTestHandler(rc, "miller@example.com")
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies?limit=2", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies?limit=3", nil))
+		h2.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies?limit=2", nil))
+
+		c.Assert(*calls, qt.Equals, 2)
+		c.Assert(*calls2, qt.Equals, 1)
+	})
+
+	t.Run("keyed by tenant", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, calls := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+
+		for _, tenant := range []string{"acme", "globex", "", "acme"} {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tenant != "" {
+				r = r.WithContext(requestinfo.WithTenant(r.Context(), tenant))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}
+
+		// the response cached for one tenant is not served to another
+		c.Assert(*calls, qt.Equals, 3)
+	})
+
+	t.Run("no-cache", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, calls := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+		r.Header.Set("Cache-Control", "no-cache")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+
+		c.Assert(rr.Header().Get(responseCacheHeader), qt.Equals, "MISS")
+		c.Assert(*calls, qt.Equals, 2)
+	})
+
+	t.Run("invalidated by write", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, calls := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/v1/movies/RWn8zcaTA1gk3ybrBdQV", nil))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+
+		c.Assert(rr.Header().Get(responseCacheHeader), qt.Equals, "MISS")
+		c.Assert(*calls, qt.Equals, 3)
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		c := qt.New(t)
+
+		rc := NewResponseCache(memstore.NewResponseStore(0), time.Minute, nil)
+		h, _ := newResponseCacheTestHandler(rc, "otto.maddox@example.com")
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+		r.Header.Set("If-None-Match", `W/"v1"`)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusNotModified)
+		c.Assert(rr.Body.Len(), qt.Equals, 0)
+	})
+}
+
+func TestResponseCache_Enabled(t *testing.T) {
+	var rc *ResponseCache
+	qt.Assert(t, rc.Enabled(), qt.IsFalse)
+	qt.Assert(t, NewResponseCache(memstore.NewResponseStore(0), 0, nil).Enabled(), qt.IsTrue)
+}
+
+func Test_invalidate(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	store := memstore.NewResponseStore(0)
+	rc := NewResponseCache(store, time.Minute, nil)
+	for _, res := range []string{"movies", "people", "users"} {
+		c.Assert(store.Set(ctx, responseCachePrefix(res)+"key", []byte("{}"), time.Minute), qt.IsNil)
+	}
+
+	rc.invalidate(ctx, "movies", zerolog.Nop())
+
+	for res, want := range map[string]bool{"movies": false, "people": false, "users": true} {
+		_, ok, err := store.Get(ctx, responseCachePrefix(res)+"key")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.Equals, want, qt.Commentf("resource %s", res))
+	}
+}
+
+func TestResponseCache_Purge(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	store := memstore.NewResponseStore(0)
+	rc := NewResponseCache(store, time.Minute, nil)
+	for _, res := range []string{"movies", "people", "users"} {
+		c.Assert(store.Set(ctx, responseCachePrefix(res)+"key", []byte("{}"), time.Minute), qt.IsNil)
+	}
+	c.Assert(store.Set(ctx, "other", []byte("{}"), time.Minute), qt.IsNil)
+
+	c.Assert(rc.Purge(ctx), qt.IsNil)
+
+	for _, res := range []string{"movies", "people", "users"} {
+		_, ok, err := store.Get(ctx, responseCachePrefix(res)+"key")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsFalse, qt.Commentf("resource %s", res))
+	}
+	_, ok, err := store.Get(ctx, "other")
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+}
+
+func TestResponseCache_Subscribe(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.Background()
+	store := memstore.NewResponseStore(0)
+	rc := NewResponseCache(store, time.Minute, nil)
+	bus := event.NewInProcessBus()
+	unsubscribe := rc.Subscribe(bus)
+
+	set := func() {
+		for _, res := range []string{"movies", "people", "usage"} {
+			c.Assert(store.Set(ctx, responseCachePrefix(res)+"key", []byte("{}"), time.Minute), qt.IsNil)
+		}
+	}
+	cached := func(res string) bool {
+		_, ok, err := store.Get(ctx, responseCachePrefix(res)+"key")
+		c.Assert(err, qt.IsNil)
+		return ok
+	}
+
+	// a movie changed by another server invalidates the movies and
+	// the people listing them
+	set()
+	bus.Publish(ctx, event.MovieChanged{Action: "update", ExternalID: "abc"})
+	c.Assert(cached("movies"), qt.IsFalse)
+	c.Assert(cached("people"), qt.IsFalse)
+	c.Assert(cached("usage"), qt.IsTrue)
+
+	set()
+	bus.Publish(ctx, event.ChangesMissed{Resource: "people"})
+	c.Assert(cached("movies"), qt.IsTrue)
+	c.Assert(cached("people"), qt.IsFalse)
+
+	set()
+	unsubscribe()
+	bus.Publish(ctx, event.MovieChanged{Action: "delete", ExternalID: "abc"})
+	c.Assert(cached("movies"), qt.IsTrue)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// ResyncStep is a step of the admin resync endpoint, which rebuilds
+// something derived from the data of the database, e.g. a cache
+type ResyncStep struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// ResyncSteps are the steps of the admin resync endpoint, run in
+// order
+type ResyncSteps []ResyncStep
+
+// resyncHandler returns a handler for POST requests for the /resync
+// endpoint, which runs steps in order, e.g. after an incident or a
+// restore of the database. The result of each step is written to
+// the response (and logged) as soon as it finishes, so the progress
+// of a long resync can be followed. A step which fails does not stop
+// the steps after it; the failed steps are listed in meta.
+func resyncHandler(steps ResyncSteps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := *hlog.FromRequest(r)
+
+		response, err := NewStandardResponse(r, nil)
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, err)
+			return
+		}
+		lw := newListWriter(w, response)
+		flusher, _ := w.(http.Flusher)
+
+		meta := dto.ResyncMeta{Failed: []string{}}
+		for _, step := range steps {
+			start := time.Now()
+			err = step.Run(ctx)
+			result := dto.ResyncStep{
+				Step:       step.Name,
+				Status:     "complete",
+				DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+				meta.Failed = append(meta.Failed, step.Name)
+				logger.Error().Err(errors.Wrapf(err, "resync %s", step.Name)).Dur("duration", time.Since(start)).Msg("resync step failed")
+			} else {
+				logger.Info().Dur("duration", time.Since(start)).Msgf("resync %s complete", step.Name)
+			}
+
+			if err = lw.Write(result); err != nil {
+				streamErr(w, logger, lw, errs.E(errs.Internal, err))
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if err = lw.Close(meta); err != nil {
+			streamErr(w, logger, lw, errs.E(errs.Internal, err))
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func Test_resyncHandler(t *testing.T) {
+	c := qt.New(t)
+
+	var ran []string
+	step := func(name string, err error) ResyncStep {
+		return ResyncStep{Name: name, Run: func(ctx context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	steps := ResyncSteps{
+		step("aggregates", nil),
+		step("response_cache", errors.New("store down")),
+		step("events", nil),
+	}
+
+	h := LoggerHandlerChain(zerolog.Nop(), alice.New()).Then(resyncHandler(steps))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resync", nil))
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	// a failed step does not stop the steps after it
+	c.Assert(ran, qt.DeepEquals, []string{"aggregates", "response_cache", "events"})
+
+	var got struct {
+		Data []dto.ResyncStep `json:"data"`
+		Meta dto.ResyncMeta   `json:"meta"`
+	}
+	c.Assert(json.NewDecoder(rr.Body).Decode(&got), qt.IsNil)
+	c.Assert(got.Data, qt.HasLen, 3)
+	for i, want := range []struct{ step, status, err string }{
+		{"aggregates", "complete", ""},
+		{"response_cache", "failed", "store down"},
+		{"events", "complete", ""},
+	} {
+		c.Assert(got.Data[i].Step, qt.Equals, want.step)
+		c.Assert(got.Data[i].Status, qt.Equals, want.status)
+		c.Assert(got.Data[i].Error, qt.Equals, want.err)
+	}
+	c.Assert(got.Meta.Failed, qt.DeepEquals, []string{"response_cache"})
+}
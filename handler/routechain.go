@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// routeChain is an alice.Chain which also keeps the name of each
+// middleware added to it, so the middleware for a route can be
+// listed (see Routes)
+type routeChain struct {
+	chain alice.Chain
+	names []string
+}
+
+// Extend returns a new routeChain with the handlers of c added under
+// a single name
+func (rc routeChain) Extend(name string, c alice.Chain) routeChain {
+	return routeChain{chain: rc.chain.Extend(c), names: rc.appendName(name)}
+}
+
+// Append returns a new routeChain with the middleware mw added
+// under name
+func (rc routeChain) Append(name string, mw alice.Constructor) routeChain {
+	return routeChain{chain: rc.chain.Append(mw), names: rc.appendName(name)}
+}
+
+// AppendIf returns a new routeChain with the middleware mw added
+// under name if ok is true, or else rc itself, for middleware which
+// is only used if enabled
+func (rc routeChain) AppendIf(ok bool, name string, mw alice.Constructor) routeChain {
+	if !ok {
+		return rc
+	}
+	return rc.Append(name, mw)
+}
+
+// appendName returns a copy of the chain's names with name added,
+// leaving the names of rc untouched as chains are shared by routes
+func (rc routeChain) appendName(name string) []string {
+	names := make([]string, len(rc.names), len(rc.names)+1)
+	copy(names, rc.names)
+	return append(names, name)
+}
+
+// RouteMeta declares what a user needs to call a route and how the
+// route is audited. It is kept with the route's handler, so the
+// security declarations of a route are in one place: AuthMiddleware
+// authorizes requests using it, AuditHandler records it with the
+// audit event and Routes lists it.
+type RouteMeta = requestinfo.RouteMeta
+
+// Then returns h wrapped by the chain's middleware. The middleware
+// names and the route's metadata are kept with the handler for the
+// route listing.
+func (rc routeChain) Then(h http.Handler, meta RouteMeta) http.Handler {
+	return routeHandler{
+		Handler:    rc.chain.Then(h),
+		middleware: rc.names,
+		meta:       meta,
+	}
+}
+
+// routeHandler is a route's handler along with the names of its
+// middleware and its metadata
+type routeHandler struct {
+	http.Handler
+	middleware []string
+	meta       RouteMeta
+}
+
+// ServeHTTP sets the route's metadata to the request context, where
+// it is read by AuthMiddleware and AuditHandler, and calls the
+// route's handler
+func (rh routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := requestinfo.WithRouteMeta(r.Context(), rh.meta)
+	rh.Handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// routeMeta returns the metadata of the route set to ctx by
+// routeHandler
+func routeMeta(ctx context.Context) RouteMeta {
+	meta, _ := requestinfo.RouteMetaFromContext(ctx)
+	return meta
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Route describes a route registered with the router
+type Route struct {
+	Methods []string
+	Path    string
+	// Queries holds the query parameter templates the route
+	// requires to match, e.g. ids={ids}
+	Queries []string
+	// Scopes holds the scopes a user needs to call the route
+	Scopes []string
+	// Role is the role a user needs to call the route, if any
+	Role string
+	// Resource is the type of resource the route acts on
+	Resource string
+	// AuditCategory is the category of the route's audit events
+	AuditCategory string
+	// Public is whether the route can be called without an access
+	// token in public read mode
+	Public bool
+	// Middleware holds the names of the middleware the route's
+	// handler is wrapped by, in the order they are called
+	Middleware []string
+}
+
+// RouteList holds the router set up by NewMuxRouter so the
+// registered routes can be listed by a handler, which has to be
+// created before the router
+type RouteList struct {
+	router *mux.Router
+}
+
+// NewRouteList is an initializer for RouteList
+func NewRouteList() *RouteList {
+	return &RouteList{}
+}
+
+// Routes returns the Routes registered with the router
+func (rl *RouteList) Routes() ([]Route, error) {
+	if rl.router == nil {
+		return nil, errs.E(errs.Internal, errors.New("router not set to RouteList"))
+	}
+	return Routes(rl.router)
+}
+
+// Routes walks rtr and returns each registered Route in the order
+// they were registered (which is also the order they are matched)
+func Routes(rtr *mux.Router) ([]Route, error) {
+	var routes []Route
+
+	err := rtr.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		// routes registered without a method restriction match all
+		// methods and return an error here, which can be ignored
+		methods, _ := route.GetMethods()
+
+		// likewise, routes without query parameters return an error
+		// (or an empty slice if the route has other matchers)
+		queries, _ := route.GetQueriesTemplates()
+		if len(queries) == 0 {
+			queries = nil
+		}
+
+		rt := Route{Methods: methods, Path: path, Queries: queries}
+		if rh, ok := route.GetHandler().(routeHandler); ok {
+			rt.Scopes = rh.meta.Scopes
+			rt.Role = rh.meta.Role
+			rt.Resource = rh.meta.Resource
+			rt.AuditCategory = rh.meta.AuditCategory
+			rt.Public = rh.meta.Public
+			rt.Middleware = rh.middleware
+		}
+
+		routes = append(routes, rt)
+
+		return nil
+	})
+	if err != nil {
+		return nil, errs.E(errs.Internal, err)
+	}
+
+	return routes, nil
+}
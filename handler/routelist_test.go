@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestRoutes(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// the handlers are never called, so they can be left nil
+	rl := NewRouteList()
+	_ = NewMuxRouter(lgr, Handlers{}, AuthMiddleware{}, audittest.NewMockWriter(t), errs.NopReporter{}, rl, EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+
+	got, err := rl.Routes()
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 29)
+	c.Assert(got[0], qt.DeepEquals, Route{
+		Methods:       []string{http.MethodPost},
+		Path:          pathPrefix + moviesV1PathRoot,
+		Scopes:        []string{scopeMoviesWrite},
+		Resource:      "movies",
+		AuditCategory: auditCategoryCatalog,
+		Middleware:    []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "dry_run", "audit", "access_token", "auth", "json_content_type"},
+	})
+	// an export job reads movies, it is neither public nor audited
+	c.Assert(got[1], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodPost},
+		Path:       pathPrefix + moviesV1PathRoot + "/export-jobs",
+		Scopes:     []string{scopeMoviesRead},
+		Resource:   "movies",
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "access_token", "auth", "json_content_type"},
+	})
+	// the history of a movie is not public
+	c.Assert(got[8], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodGet},
+		Path:       pathPrefix + moviesV1PathRoot + "/{extlID}/history/{v1}/diff/{v2}",
+		Scopes:     []string{scopeMoviesRead},
+		Resource:   "movies",
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "access_token", "auth", "json_content_type"},
+	})
+	c.Assert(got[9], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodGet},
+		Path:       pathPrefix + moviesV1PathRoot,
+		Queries:    []string{"ids={ids}"},
+		Scopes:     []string{scopeMoviesRead},
+		Resource:   "movies",
+		Public:     true,
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "access_token", "auth", "json_content_type", "query_params"},
+	})
+	c.Assert(got[20], qt.DeepEquals, Route{
+		Methods:       []string{http.MethodDelete},
+		Path:          pathPrefix + usersV1PathRoot + "/{id}/data",
+		Scopes:        []string{scopeUsersWrite},
+		Role:          auth.RoleAdmin,
+		Resource:      "users",
+		AuditCategory: auditCategoryPrivacy,
+		Middleware:    []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "audit", "access_token", "auth", "json_content_type"},
+	})
+	// the session token route is authenticated by the session's
+	// secret rather than an access token
+	c.Assert(got[22], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodPost},
+		Path:       pathPrefix + sessionsV1PathRoot + "/{id}/token",
+		Resource:   "sessions",
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "json_content_type"},
+	})
+	c.Assert(got[24], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodGet},
+		Path:       pathPrefix + exportJobsV1PathRoot + "/{id}",
+		Scopes:     []string{scopeExportsRead},
+		Resource:   "exports",
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "access_token", "auth", "json_content_type"},
+	})
+	c.Assert(got[28], qt.DeepEquals, Route{
+		Methods:    []string{http.MethodGet},
+		Path:       pathPrefix + "/v1/metrics",
+		Middleware: []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming"},
+	})
+
+	c.Run("router not set", func(c *qt.C) {
+		_, err := NewRouteList().Routes()
+		c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+	})
+
+	c.Run("no methods", func(c *qt.C) {
+		rtr := mux.NewRouter()
+		rtr.Handle("/anything", http.NotFoundHandler())
+
+		got, err := Routes(rtr)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []Route{{Path: "/anything"}})
+	})
+}
@@ -6,24 +6,145 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+const (
+	pathPrefix         string = "/api"
+	moviesV1PathRoot   string = "/v1/movies"
+	usersV1PathRoot    string = "/v1/users"
+	sessionsV1PathRoot string = "/v1/sessions"
+	adminV1PathRoot    string = "/v1/admin"
 )
 
+// The scopes a user needs to call a route. They are used to describe
+// routes (see Routes) and, as resource:action, name the resource and
+// action checked for the route by the Authorizer (see AuthMiddleware).
 const (
-	pathPrefix       string = "/api"
-	moviesV1PathRoot string = "/v1/movies"
+	scopeMoviesRead      string = "movies:read"
+	scopeMoviesWrite     string = "movies:write"
+	scopeUsageRead       string = "usage:read"
+	scopePermissionsRead string = "permissions:read"
+	scopeAdminRead       string = "admin:read"
+	scopeUsersWrite      string = "users:write"
+	scopeSessionsWrite   string = "sessions:write"
+	scopeExportsRead     string = "exports:read"
 )
 
+// auditCategoryCatalog is the audit category of changes to the
+// catalog of movies and the people who make them
+const auditCategoryCatalog string = "catalog"
+
+// auditCategoryPrivacy is the audit category of the erasure of
+// users' personal data
+const auditCategoryPrivacy string = "privacy"
+
+// auditCategoryAccess is the audit category of the creation and
+// deletion of the sessions giving integrations access to the API
+const auditCategoryAccess string = "access"
+
+// The metadata of the routes, declaring what a user needs to call
+// each route and how it is audited
+var (
+	metaMoviesRead      = RouteMeta{Scopes: []string{scopeMoviesRead}, Resource: "movies", Public: true}
+	metaMoviesWrite     = RouteMeta{Scopes: []string{scopeMoviesWrite}, Resource: "movies", AuditCategory: auditCategoryCatalog}
+	metaUsageRead       = RouteMeta{Scopes: []string{scopeUsageRead}, Resource: "usage"}
+	metaPermissionsRead = RouteMeta{Scopes: []string{scopePermissionsRead}, Resource: "permissions"}
+	metaAdminRead       = RouteMeta{Scopes: []string{scopeAdminRead}, Role: auth.RoleAdmin, Resource: "admin"}
+	metaUsersWrite      = RouteMeta{Scopes: []string{scopeUsersWrite}, Role: auth.RoleAdmin, Resource: "users", AuditCategory: auditCategoryPrivacy}
+	metaSessionsWrite   = RouteMeta{Scopes: []string{scopeSessionsWrite}, Resource: "sessions", AuditCategory: auditCategoryAccess}
+	// an export job reads movies, but is neither public nor audited
+	metaMoviesExport = RouteMeta{Scopes: []string{scopeMoviesRead}, Resource: "movies"}
+	// the history of a movie is not public
+	metaMovieHistory = RouteMeta{Scopes: []string{scopeMoviesRead}, Resource: "movies"}
+	metaExportsRead  = RouteMeta{Scopes: []string{scopeExportsRead}, Resource: "exports"}
+)
+
+// NewAPIHandler returns the handler of the API requests: rtr, wrapped
+// to set the client of each request, as seen through proxies, to its
+// context (see ClientHandler) and to normalize its path using opts
+// before it is routed
+func NewAPIHandler(rtr *mux.Router, opts PathNormalization, proxies TrustedProxies) (http.Handler, error) {
+	h, err := normalizePathHandler(rtr, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ClientHandler(proxies)(h), nil
+}
+
 // NewMuxRouter sets up the mux.Router and registers routes to URL paths
-// using the available handlers
-func NewMuxRouter(logger zerolog.Logger, handlers Handlers) *mux.Router {
+// using the available handlers. Requests needing a user are
+// authenticated and authorized by am. State-changing requests are
+// audited using aw. Server errors are reported using rep. Response
+// bodies are encoded using opts, and request bodies larger than the
+// MaxBodyBytes of dec are turned away. The responses of the movie and
+// person routes are cached using rc, if it is not nil. Requests are
+// made for the tenant the user belongs to, if there are tenants, and
+// consistency tokens are exchanged with clients if ct is true.
+// Requests other than ping and metrics are turned away while av, if
+// not nil, is not available. Ping and metrics are only registered if admin is false,
+// as they are otherwise served by the admin server. The router is
+// set to rl so the registered routes can be listed.
+func NewMuxRouter(logger zerolog.Logger, handlers Handlers, am AuthMiddleware, aw audit.Writer, rep errs.ErrorReporter, rl *RouteList, opts EncodeOptions, dec DecodeOptions, rc *ResponseCache, tenants Tenants, av Availability, ct ConsistencyTokens, admin AdminServed) *mux.Router {
 	// create a new gorilla/mux router
 	rtr := mux.NewRouter()
 
-	// Start a new alice handler chain
-	c := alice.New()
+	// Start a new handler chain. routeChain wraps an alice.Chain,
+	// keeping the name of each middleware for the route listing
+	c := routeChain{chain: alice.New()}
 
 	// add LoggerHandlerChain handler chain and zerolog logger to Context
-	c = LoggerHandlerChain(logger, c)
+	c = c.Extend("logger", LoggerHandlerChain(logger, alice.New()))
+
+	// record the latency of the request by route, with the trace ID
+	// as an exemplar
+	c = c.Append("metrics", RequestMetricsHandler)
+
+	// report server errors, including panics recovered below, to
+	// the error reporting service
+	c = c.Append("error_reporting", ErrorReportingHandler(rep))
+
+	// recover from any panic in the handlers below, sending an
+	// HTTP 500 response instead of dropping the connection
+	c = c.Append("recovery", RecoveryHandler)
+
+	// exchange consistency tokens with the client, so it reads its
+	// own writes from the read replica
+	c = c.AppendIf(bool(ct), "consistency", ConsistencyHandler)
+
+	// turn away request bodies larger than the limit, before any
+	// of the body is read
+	c = c.AppendIf(dec.MaxBodyBytes > 0, "body_limit", BodyLimitHandler(dec.MaxBodyBytes))
+
+	// log the request and response bodies of the requests chosen
+	// by SetBodyLogging
+	c = c.Append("body_logging", BodyLoggingHandler)
+
+	// choose the naming of response body fields, from the Accept
+	// header or opts
+	c = c.Append("json_naming", FieldNamingHandler(opts))
+
+	// ping and metrics are served while the database is unavailable,
+	// the other routes need it
+	base := c
+	c = c.AppendIf(av != nil, "availability", AvailabilityHandler(av))
+
+	// add the audit handler for state-changing requests
+	auditHandler := AuditHandler(aw)
+
+	// add the handler which authenticates the user from the access
+	// token and authorizes the user for the route. If there are
+	// tenants, it then sets the tenant the user belongs to, whose
+	// database the queries of the request are run in.
+	authHandler := am.Handler
+	if len(tenants.Names) > 0 {
+		authHandler = func(h http.Handler) http.Handler {
+			return am.Handler(TenantHandler(tenants)(h))
+		}
+	}
 
 	// send Router through PathPrefix method to validate any standard
 	// subroutes you may want for your APIs. e.g. I always want to be
@@ -33,49 +154,226 @@ func NewMuxRouter(logger zerolog.Logger, handlers Handlers) *mux.Router {
 	rtr = rtr.PathPrefix(pathPrefix).Subrouter()
 
 	// Match only POST requests at /api/v1/movies
-	// with Content-Type header = application/json
 	rtr.Handle(moviesV1PathRoot,
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.CreateMovieHandler)).
-		Methods(http.MethodPost).
-		Headers("Content-Type", "application/json")
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.CreateMovieHandler, metaMoviesWrite)).
+		Methods(http.MethodPost)
+
+	// Match only POST requests at /api/v1/movies/export-jobs. The
+	// job exports movies in the background, it does not change them
+	rtr.Handle(moviesV1PathRoot+"/export-jobs",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.CreateMovieExportJobHandler, metaMoviesExport)).
+		Methods(http.MethodPost)
 
 	// Match only PUT requests having an ID at /api/v1/movies/{id}
-	// with the Content-Type header = application/json
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.UpdateMovieHandler)).
-		Methods(http.MethodPut).
-		Headers("Content-Type", "application/json")
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.UpdateMovieHandler, metaMoviesWrite)).
+		Methods(http.MethodPut)
 
 	// Match only DELETE requests having an ID at /api/v1/movies/{id}
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.DeleteMovieHandler)).
+		c.Append("dry_run", DryRunHandler).
+			Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.DeleteMovieHandler, metaMoviesWrite)).
 		Methods(http.MethodDelete)
 
+	// Match only GET requests at /api/v1/movies/stats. This route
+	// must be registered before /api/v1/movies/{id} or "stats" would
+	// be matched as an ID
+	rtr.Handle(moviesV1PathRoot+"/stats",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.MovieStatsHandler, metaMoviesRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/movies/suggest, e.g.
+	// /api/v1/movies/suggest?q=rep. Like stats, this route must be
+	// registered before /api/v1/movies/{id}
+	rtr.Handle(moviesV1PathRoot+"/suggest",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Append("query_params", QueryParamsHandler(suggestMoviesParams...)).
+			Then(handlers.SuggestMoviesHandler, metaMoviesRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/movies/count, e.g.
+	// /api/v1/movies/count?mode=estimate. Like stats, this route
+	// must be registered before /api/v1/movies/{id}
+	rtr.Handle(moviesV1PathRoot+"/count",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Append("query_params", QueryParamsHandler(countMoviesParams...)).
+			Then(handlers.CountMoviesHandler, metaMoviesRead)).
+		Methods(http.MethodGet)
+
 	// Match only GET requests having an ID at /api/v1/movies/{id}
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.FindMovieByIDHandler)).
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.FindMovieByIDHandler, metaMoviesRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at
+	// /api/v1/movies/{id}/history/{v1}/diff/{v2}
+	rtr.Handle(moviesV1PathRoot+"/{extlID}/history/{v1}/diff/{v2}",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.MovieDiffHandler, metaMovieHistory)).
 		Methods(http.MethodGet)
 
+	// Match only GET requests /api/v1/movies having an ids query
+	// parameter, e.g. /api/v1/movies?ids=a,b,c. This route must be
+	// registered before /api/v1/movies (all movies)
+	rtr.Handle(moviesV1PathRoot,
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Append("query_params", QueryParamsHandler(findMoviesByIDsParams...)).
+			Then(handlers.FindMoviesByIDsHandler, metaMoviesRead)).
+		Methods(http.MethodGet).
+		Queries("ids", "{ids}")
+
 	// Match only GET requests /api/v1/movies
 	rtr.Handle(moviesV1PathRoot,
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.FindAllMoviesHandler)).
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Append("query_params", QueryParamsHandler(findAllMoviesParams...)).
+			Then(handlers.FindAllMoviesHandler, metaMoviesRead)).
 		Methods(http.MethodGet)
 
-	// Match only GET requests at /api/v1/ping
-	rtr.Handle("/v1/ping",
-		c.Append(JSONContentTypeHandler).
-			Then(handlers.PingHandler)).
+	// register the /api/v1/people routes
+	registerPersonRoutes(rtr, c, auditHandler, authHandler, rc, handlers.PersonHandlers)
+
+	// Match only GET requests at /api/v1/users/me/usage
+	rtr.Handle(usersV1PathRoot+"/me/usage",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.UsageHandler, metaUsageRead)).
 		Methods(http.MethodGet)
 
+	// Match only GET requests at /api/v1/users/me/permissions
+	rtr.Handle(usersV1PathRoot+"/me/permissions",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.PermissionsHandler, metaPermissionsRead)).
+		Methods(http.MethodGet)
+
+	// Match only DELETE requests at /api/v1/users/{id}/data
+	rtr.Handle(usersV1PathRoot+"/{id}/data",
+		c.Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			AppendIf(rc.Enabled(), "response_cache", rc.Handler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.EraseUserDataHandler, metaUsersWrite)).
+		Methods(http.MethodDelete)
+
+	// Match only POST requests at /api/v1/sessions
+	rtr.Handle(sessionsV1PathRoot,
+		c.Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.CreateSessionHandler, metaSessionsWrite)).
+		Methods(http.MethodPost)
+
+	// Match only POST requests at /api/v1/sessions/{id}/token. The
+	// request is authenticated by the secret of the session in its
+	// body, as the integration's access token may have expired
+	rtr.Handle(sessionsV1PathRoot+"/{id}/token",
+		c.Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.SessionTokenHandler, RouteMeta{Resource: "sessions"})).
+		Methods(http.MethodPost)
+
+	// Match only DELETE requests at /api/v1/sessions/{id}
+	rtr.Handle(sessionsV1PathRoot+"/{id}",
+		c.Append("audit", auditHandler).
+			Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.DeleteSessionHandler, metaSessionsWrite)).
+		Methods(http.MethodDelete)
+
+	// Match only GET requests at /api/v1/export-jobs/{id}
+	rtr.Handle(exportJobsV1PathRoot+"/{id}",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.FindExportJobHandler, metaExportsRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/admin/audit
+	rtr.Handle(adminV1PathRoot+"/audit",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Append("query_params", QueryParamsHandler(findAuditRecordsParams...)).
+			Then(handlers.FindAuditRecordsHandler, metaAdminRead)).
+		Methods(http.MethodGet)
+
+	// Match only GET requests at /api/v1/admin/routes
+	rtr.Handle(adminV1PathRoot+"/routes",
+		c.Append("access_token", AccessTokenHandler).
+			Append("auth", authHandler).
+			Append("json_content_type", JSONContentTypeHandler).
+			Then(handlers.FindRoutesHandler, metaAdminRead)).
+		Methods(http.MethodGet)
+
+	// ping and metrics have no authentication, so they are only
+	// served by the API when there is no admin server to serve them
+	if !admin {
+		// Match only GET requests at /api/v1/ping
+		rtr.Handle("/v1/ping",
+			base.Append("json_content_type", JSONContentTypeHandler).
+				Then(handlers.PingHandler, RouteMeta{})).
+			Methods(http.MethodGet)
+
+		// Match only GET requests at /api/v1/metrics
+		rtr.Handle("/v1/metrics",
+			base.Then(handlers.MetricsHandler, RouteMeta{})).
+			Methods(http.MethodGet)
+	}
+
+	// send the standard error response for a path matching no route
+	// and for a method not allowed by the routes matching the path
+	rtr.NotFoundHandler = base.Then(http.HandlerFunc(notFoundHandler), RouteMeta{})
+	rtr.MethodNotAllowedHandler = base.Then(methodNotAllowedHandler(rtr), RouteMeta{})
+
+	// set the router to the RouteList for the routes handler
+	rl.router = rtr
+
 	return rtr
 }
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// FindRoutesHandler is a Handler that returns the registered routes
+type FindRoutesHandler http.Handler
+
+// ProvideFindRoutesHandler is a provider for the FindRoutesHandler
+// for wire
+func ProvideFindRoutesHandler(h DefaultRoutesHandler) FindRoutesHandler {
+	return http.HandlerFunc(h.FindRoutes)
+}
+
+// DefaultRoutesHandler handles requests for the registered routes
+type DefaultRoutesHandler struct {
+	RouteList *RouteList
+}
+
+// FindRoutes handles GET requests for the /admin/routes endpoint
+// and returns the method(s), path, required query parameters,
+// metadata and middleware of every registered route, in the order
+// they are matched.
+func (h DefaultRoutesHandler) FindRoutes(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	routes, err := h.RouteList.Routes()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rr := make([]dto.RouteResponse, 0, len(routes))
+	for _, rt := range routes {
+		rr = append(rr, dto.RouteResponse{
+			Methods:       rt.Methods,
+			Path:          rt.Path,
+			Queries:       rt.Queries,
+			Scopes:        rt.Scopes,
+			Role:          rt.Role,
+			Resource:      rt.Resource,
+			AuditCategory: rt.AuditCategory,
+			Public:        rt.Public,
+			Middleware:    rt.Middleware,
+		})
+	}
+
+	response, err := NewStandardResponse(r, rr)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func TestDefaultRoutesHandler_FindRoutes(t *testing.T) {
+	// initialize quickest checker
+	c := qt.New(t)
+
+	// initialize a zerolog Logger
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	// setup the router with the routes handler, which lists the
+	// routes of the router it is registered with
+	rl := NewRouteList()
+	drh := DefaultRoutesHandler{
+		RouteList: rl,
+	}
+	rtr := NewMuxRouter(lgr, Handlers{FindRoutesHandler: ProvideFindRoutesHandler(drh)}, newMockAuthMiddleware(t), audittest.NewMockWriter(t), errs.NopReporter{}, rl, EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+
+	// form request using httptest
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+adminV1PathRoot+"/routes", nil)
+
+	// add test access token
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+	// initialize ResponseRecorder to use with ServeHTTP as it
+	// satisfies ResponseWriter interface and records the response
+	// for testing
+	rr := httptest.NewRecorder()
+
+	// route the request through the full handler chain
+	rtr.ServeHTTP(rr, req)
+
+	// Assert that Response Status Code equals 200 (StatusOK)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	// standardResponse decodes the response with Data as the dto type
+	type standardResponse struct {
+		Data []dto.RouteResponse `json:"data"`
+	}
+
+	// decode the response body into the standardResponse (gotBody)
+	gotBody := standardResponse{}
+	err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+	defer rr.Result().Body.Close()
+	c.Assert(err, qt.IsNil)
+
+	routes, err := rl.Routes()
+	c.Assert(err, qt.IsNil)
+	c.Assert(gotBody.Data, qt.HasLen, len(routes))
+
+	// the routes route should list itself
+	var found bool
+	for _, rt := range gotBody.Data {
+		if rt.Path == pathPrefix+adminV1PathRoot+"/routes" {
+			found = true
+			c.Assert(rt.Methods, qt.DeepEquals, []string{http.MethodGet})
+			c.Assert(rt.Scopes, qt.DeepEquals, []string{scopeAdminRead})
+			c.Assert(rt.Role, qt.Equals, auth.RoleAdmin)
+			c.Assert(rt.Resource, qt.Equals, "admin")
+			c.Assert(rt.Middleware, qt.DeepEquals, []string{"logger", "metrics", "error_reporting", "recovery", "body_logging", "json_naming", "access_token", "auth", "json_content_type"})
+		}
+	}
+	c.Assert(found, qt.IsTrue)
+}
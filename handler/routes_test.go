@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -10,11 +12,18 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
 
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
 	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
 	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
 	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
 )
 
 func TestNewMuxRouter(t *testing.T) {
@@ -30,48 +39,86 @@ func TestNewMuxRouter(t *testing.T) {
 		t.Cleanup(cleanup)
 
 		// initialize MockTransactor for the moviestore
-		mockTransactor := newMockTransactor(t)
+		mockTransactor := moviestoretest.NewMockTransactor(t)
 
 		// initialize MockSelector for the moviestore
-		mockSelector := newMockSelector(t)
+		mockSelector := moviestoretest.NewMockSelector(t)
 
 		// initialize mockAccessTokenConverter
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
 		// initialize DefaultStringGenerator
-		randomStringGenerator := random.DefaultStringGenerator{}
+		externalIDGenerator := random.DefaultStringGenerator{}
 
 		// initialize DefaultMovieHandlers
 		defaultMovieHandlers := DefaultMovieHandlers{
-			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
-			Transactor:            mockTransactor,
-			Selector:              mockSelector,
+			Service: moviesvc.Service{
+				Authorizer:          authtest.NewMockAuthorizer(t),
+				ExternalIDGenerator: externalIDGenerator,
+				UUIDGenerator:       idgen.DefaultUUIDGenerator{Clock: clock.DefaultClock{}},
+				Clock:               clock.DefaultClock{},
+				Transactor:          mockTransactor,
+				Selector:            mockSelector,
+			},
+			QuotaTracker: quotatest.NewMockTracker(t),
 		}
 
 		// setup handlers
 		createMovieHandler := ProvideCreateMovieHandler(defaultMovieHandlers)
 		findMovieByIDHandler := ProvideFindMovieByIDHandler(defaultMovieHandlers)
 		findAllMoviesHandler := ProvideFindAllMoviesHandler(defaultMovieHandlers)
+		findMoviesByIDsHandler := ProvideFindMoviesByIDsHandler(defaultMovieHandlers)
 		updateMovieHandler := ProvideUpdateMovieHandler(defaultMovieHandlers)
 		deleteMovieHandler := ProvideDeleteMovieHandler(defaultMovieHandlers)
+		movieStatsHandler := ProvideMovieStatsHandler(defaultMovieHandlers)
+		suggestMoviesHandler := ProvideSuggestMoviesHandler(defaultMovieHandlers)
+		countMoviesHandler := ProvideCountMoviesHandler(defaultMovieHandlers)
+		findAuditRecordsHandler := ProvideFindAuditRecordsHandler(DefaultAuditHandler{
+			Store: audittest.NewMockStore(t),
+		})
+		routeList := NewRouteList()
+		findRoutesHandler := ProvideFindRoutesHandler(DefaultRoutesHandler{
+			RouteList: routeList,
+		})
+		usageHandler := ProvideUsageHandler(DefaultUsageHandler{
+			QuotaTracker: quotatest.NewMockTracker(t),
+		})
+		permissionsHandler := ProvidePermissionsHandler(DefaultPermissionsHandler{
+			Authorizer: authtest.NewMockAuthorizer(t),
+			RouteList:  routeList,
+		})
 		defaultPinger := pingstore.NewDefaultPinger(defaultDatastore)
 		defaultPingHandler := DefaultPingHandler{
 			Pinger: defaultPinger,
 		}
 		pingHandler := ProvidePingHandler(defaultPingHandler)
+		metricsHandler := ProvideMetricsHandler()
 		handlers := Handlers{
-			CreateMovieHandler:   createMovieHandler,
-			FindMovieByIDHandler: findMovieByIDHandler,
-			FindAllMoviesHandler: findAllMoviesHandler,
-			UpdateMovieHandler:   updateMovieHandler,
-			DeleteMovieHandler:   deleteMovieHandler,
-			PingHandler:          pingHandler,
+			CreateMovieHandler:      createMovieHandler,
+			FindMovieByIDHandler:    findMovieByIDHandler,
+			FindAllMoviesHandler:    findAllMoviesHandler,
+			FindMoviesByIDsHandler:  findMoviesByIDsHandler,
+			UpdateMovieHandler:      updateMovieHandler,
+			DeleteMovieHandler:      deleteMovieHandler,
+			MovieStatsHandler:       movieStatsHandler,
+			SuggestMoviesHandler:    suggestMoviesHandler,
+			CountMoviesHandler:      countMoviesHandler,
+			UsageHandler:            usageHandler,
+			PermissionsHandler:      permissionsHandler,
+			FindAuditRecordsHandler: findAuditRecordsHandler,
+			FindRoutesHandler:       findRoutesHandler,
+			PingHandler:             pingHandler,
+			MetricsHandler:          metricsHandler,
+		}
+
+		// initialize the AuthMiddleware
+		am := AuthMiddleware{
+			AccessTokenConverter: mockAccessTokenConverter,
+			Authorizer:           authtest.NewMockAuthorizer(t),
 		}
 
 		// get a new router
-		router := NewMuxRouter(lgr, handlers)
+		router := NewMuxRouter(lgr, handlers, am, audittest.NewMockWriter(t), errs.NopReporter{}, routeList, EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
 
 		// r holds the path and http method to be tested
 		type r struct {
@@ -85,9 +132,26 @@ func TestNewMuxRouter(t *testing.T) {
 			{pathPrefix + moviesV1PathRoot, []string{http.MethodPost}},
 			{pathPrefix + moviesV1PathRoot + "/{extlID}", []string{http.MethodPut}},
 			{pathPrefix + moviesV1PathRoot + "/{extlID}", []string{http.MethodDelete}},
+			{pathPrefix + moviesV1PathRoot + "/stats", []string{http.MethodGet}},
+			{pathPrefix + moviesV1PathRoot + "/suggest", []string{http.MethodGet}},
+			{pathPrefix + moviesV1PathRoot + "/count", []string{http.MethodGet}},
 			{pathPrefix + moviesV1PathRoot + "/{extlID}", []string{http.MethodGet}},
 			{pathPrefix + moviesV1PathRoot, []string{http.MethodGet}},
+			{pathPrefix + moviesV1PathRoot, []string{http.MethodGet}},
+			{pathPrefix + peopleV1PathRoot, []string{http.MethodPost}},
+			{pathPrefix + peopleV1PathRoot + "/{extlID}", []string{http.MethodPut}},
+			{pathPrefix + peopleV1PathRoot + "/{extlID}", []string{http.MethodDelete}},
+			{pathPrefix + peopleV1PathRoot + "/{extlID}", []string{http.MethodGet}},
+			{pathPrefix + peopleV1PathRoot, []string{http.MethodGet}},
+			{pathPrefix + peopleV1PathRoot + "/{extlID}/movies", []string{http.MethodGet}},
+			{pathPrefix + peopleV1PathRoot + "/{extlID}/movies/{movieExtlID}", []string{http.MethodPut}},
+			{pathPrefix + usersV1PathRoot + "/me/usage", []string{http.MethodGet}},
+			{pathPrefix + usersV1PathRoot + "/me/permissions", []string{http.MethodGet}},
+			{pathPrefix + usersV1PathRoot + "/{id}/data", []string{http.MethodDelete}},
+			{pathPrefix + adminV1PathRoot + "/audit", []string{http.MethodGet}},
+			{pathPrefix + adminV1PathRoot + "/routes", []string{http.MethodGet}},
 			{pathPrefix + "/v1/ping", []string{http.MethodGet}},
+			{pathPrefix + "/v1/metrics", []string{http.MethodGet}},
 		}
 
 		// make a slice of r for use in the Walk function
@@ -118,3 +182,28 @@ func TestNewMuxRouter(t *testing.T) {
 
 	})
 }
+
+func TestNewMuxRouter_AdminServed(t *testing.T) {
+	lgr := logger.NewLogger(ioutil.Discard, true)
+	handlers := Handlers{MetricsHandler: ProvideMetricsHandler()}
+
+	tests := []struct {
+		name       string
+		admin      AdminServed
+		wantStatus int
+	}{
+		{"no admin server", false, http.StatusOK},
+		{"admin server", true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			router := NewMuxRouter(lgr, handlers, AuthMiddleware{}, audittest.NewMockWriter(t), errs.NopReporter{}, NewRouteList(), EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, tt.admin)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, pathPrefix+"/v1/metrics", nil))
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// CreateSessionHandler is a Handler that creates an integration
+// session
+type CreateSessionHandler http.Handler
+
+// ProvideCreateSessionHandler is a provider for the
+// CreateSessionHandler for wire
+func ProvideCreateSessionHandler(h DefaultSessionHandlers) CreateSessionHandler {
+	return http.HandlerFunc(h.CreateSession)
+}
+
+// SessionTokenHandler is a Handler that issues an access token for
+// an integration session
+type SessionTokenHandler http.Handler
+
+// ProvideSessionTokenHandler is a provider for the
+// SessionTokenHandler for wire
+func ProvideSessionTokenHandler(h DefaultSessionHandlers) SessionTokenHandler {
+	return http.HandlerFunc(h.SessionToken)
+}
+
+// DeleteSessionHandler is a Handler that deletes an integration
+// session
+type DeleteSessionHandler http.Handler
+
+// ProvideDeleteSessionHandler is a provider for the
+// DeleteSessionHandler for wire
+func ProvideDeleteSessionHandler(h DefaultSessionHandlers) DeleteSessionHandler {
+	return http.HandlerFunc(h.DeleteSession)
+}
+
+// DefaultSessionHandlers handles requests for the long-lived
+// sessions of server-side integrations (see auth.Session)
+type DefaultSessionHandlers struct {
+	Store           auth.SessionStore
+	Refresher       auth.TokenRefresher
+	SecretGenerator random.SecretGenerator
+	Clock           clock.Clock
+	DecodeOptions   DecodeOptions
+}
+
+// CreateSession handles POST requests for the /sessions endpoint.
+// The authenticated user's refresh token, given as is or exchanged
+// server-side for an authorization code, is stored for a session
+// named in the request body, and a secret for the session is sent.
+// The integration gets access tokens with the secret (see
+// SessionToken), so it keeps working after its access token
+// expires. The secret is only ever sent in this response.
+func (h DefaultSessionHandlers) CreateSession(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(dto.CreateSessionRequest)
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
+	defer r.Body.Close()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	refreshToken, err := h.refreshToken(r, rb)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	secret, err := h.SecretGenerator.NewSecret()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	s := auth.Session{
+		ID:           uuid.New(),
+		Name:         rb.Name,
+		Username:     u.Email,
+		RefreshToken: refreshToken,
+		Secret:       secret,
+		CreateTime:   h.Clock.Now().UTC(),
+	}
+	if err = h.Store.Create(ctx, s); err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	logger.Info().
+		Str("session_id", s.ID.String()).
+		Str("session_name", s.Name).
+		Msg("integration session created")
+
+	response, err := NewStandardResponse(r, dto.NewSessionResponse(s))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the secret must not be kept by any cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// refreshToken validates the request body rb of CreateSession and
+// returns the refresh token to store for the session. An
+// authorization code is exchanged for a refresh token and a refresh
+// token is used once, so one which does not work is rejected now
+// rather than when the integration first needs an access token.
+func (h DefaultSessionHandlers) refreshToken(r *http.Request, rb *dto.CreateSessionRequest) (string, error) {
+	ctx := r.Context()
+
+	switch {
+	case rb.Name == "":
+		return "", errs.E(errs.Validation, errs.Parameter("name"), errors.New("name is required"))
+	case (rb.AuthorizationCode == "") == (rb.RefreshToken == ""):
+		return "", errs.E(errs.Validation, errs.Parameter("authorization_code"), errors.New("one of authorization_code or refresh_token is required"))
+	case rb.AuthorizationCode != "" && rb.RedirectURI == "":
+		return "", errs.E(errs.Validation, errs.Parameter("redirect_uri"), errors.New("redirect_uri is required with authorization_code"))
+	}
+
+	if rb.AuthorizationCode != "" {
+		st, err := h.Refresher.Exchange(ctx, rb.AuthorizationCode, rb.RedirectURI)
+		if err != nil {
+			return "", grantError(err, "authorization_code")
+		}
+		if st.RefreshToken == "" {
+			return "", errs.E(errs.Validation, errs.Parameter("authorization_code"), errors.New("no refresh token was issued for authorization_code, it must be requested for offline access"))
+		}
+		return st.RefreshToken, nil
+	}
+
+	st, err := h.Refresher.Refresh(ctx, rb.RefreshToken)
+	if err != nil {
+		return "", grantError(err, "refresh_token")
+	}
+	if st.RefreshToken != "" {
+		return st.RefreshToken, nil
+	}
+	return rb.RefreshToken, nil
+}
+
+// grantError returns the error of a grant given in the request body
+// of CreateSession, as param, which the identity provider did not
+// accept. The user's access token was accepted, so it is a
+// validation error rather than the errs.Unauthenticated error of the
+// TokenRefresher.
+func grantError(err error, param string) error {
+	if errs.KindIs(errs.Unauthenticated, err) {
+		return errs.E(errs.Validation, errs.Parameter(param), auth.CodeInvalidGrant, errors.Errorf("%s is not accepted by the identity provider", param))
+	}
+	return err
+}
+
+// SessionToken handles POST requests for the /sessions/{id}/token
+// endpoint. The request is made with the secret of the session
+// rather than an access token: a new access token is issued for the
+// session's refresh token and sent in the response. A session whose
+// refresh token is no longer accepted, e.g. as the user revoked the
+// API's access, is sent a 401 with the invalid_grant code, and must
+// be created again.
+func (h DefaultSessionHandlers) SessionToken(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("id"), errors.New("id must be a session ID")))
+		return
+	}
+
+	rb := new(dto.SessionTokenRequest)
+	err = decodeJSON(r.Body, &rb, h.DecodeOptions)
+	defer r.Body.Close()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	s, err := h.Store.FindBySecret(ctx, id, rb.Secret)
+	if err != nil {
+		if errs.KindIs(errs.NotExist, err) {
+			err = errs.E(errs.Unauthenticated, errors.New("session not found for the ID and secret"))
+		}
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	st, err := h.Refresher.Refresh(ctx, s.RefreshToken)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	now := h.Clock.Now()
+	if err = h.Store.Refreshed(ctx, s.ID, st.RefreshToken, now.UTC()); err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	logger.Info().
+		Str("session_id", s.ID.String()).
+		Str("user", s.Username).
+		Msg("integration session access token issued")
+
+	response, err := NewStandardResponse(r, dto.NewSessionTokenResponse(st, now))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the access token must not be kept by any cache
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// DeleteSession handles DELETE requests for the /sessions/{id}
+// endpoint, deleting a session of the authenticated user along with
+// its refresh token
+func (h DefaultSessionHandlers) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("id"), errors.New("id must be a session ID")))
+		return
+	}
+
+	if err = h.Store.Delete(ctx, id, u.Email); err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	response, err := NewStandardResponse(r, dto.DeleteSessionResponse{ID: id.String(), Deleted: true})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
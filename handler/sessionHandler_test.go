@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// fakeRefresher issues tokens for goodCode and any refresh token
+// which has not been revoked. onlineCode is exchanged without a
+// refresh token.
+type fakeRefresher struct {
+	revoked map[string]bool
+}
+
+func (f fakeRefresher) Exchange(ctx context.Context, code, redirectURI string) (auth.SessionToken, error) {
+	switch code {
+	case "goodCode":
+		return auth.SessionToken{AccessToken: auth.AccessToken{Token: "accessToken", TokenType: auth.BearerTokenType}, RefreshToken: "codeRefreshToken"}, nil
+	case "onlineCode":
+		return auth.SessionToken{AccessToken: auth.AccessToken{Token: "accessToken", TokenType: auth.BearerTokenType}}, nil
+	}
+	return auth.SessionToken{}, errs.E(errs.Unauthenticated, auth.CodeInvalidGrant, errors.New("invalid_grant"))
+}
+
+func (f fakeRefresher) Refresh(ctx context.Context, refreshToken string) (auth.SessionToken, error) {
+	if f.revoked[refreshToken] {
+		return auth.SessionToken{}, errs.E(errs.Unauthenticated, auth.CodeInvalidGrant, errors.New("invalid_grant"))
+	}
+	return auth.SessionToken{
+		AccessToken: auth.AccessToken{Token: "accessToken", TokenType: auth.BearerTokenType},
+		Expiry:      clocktest.Time.Add(time.Hour),
+	}, nil
+}
+
+// newSessionRouter returns a router with the session handlers using
+// store and refresher, at the time of clocktest.MockClock
+func newSessionRouter(t *testing.T, store auth.SessionStore, refresher auth.TokenRefresher) http.Handler {
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	dsh := DefaultSessionHandlers{
+		Store:           store,
+		Refresher:       refresher,
+		SecretGenerator: random.DefaultStringGenerator{},
+		Clock:           clocktest.NewMockClock(t),
+	}
+	am := AuthMiddleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		Authorizer:           authtest.NewMockAuthorizer(t),
+	}
+	handlers := Handlers{
+		CreateSessionHandler: ProvideCreateSessionHandler(dsh),
+		SessionTokenHandler:  ProvideSessionTokenHandler(dsh),
+		DeleteSessionHandler: ProvideDeleteSessionHandler(dsh),
+	}
+
+	return NewMuxRouter(lgr, handlers, am, audittest.NewMockWriter(t), errs.NopReporter{}, NewRouteList(), EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+}
+
+// serveSession serves a request with body, authenticated with an
+// access token if token is true, and decodes the data of a 200
+// response to data
+func serveSession(t *testing.T, h http.Handler, method, path, body string, token bool, data interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token {
+		req.Header.Set("Authorization", auth.BearerTokenType+" abc123def1")
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code == http.StatusOK && data != nil {
+		sr := struct {
+			Data interface{} `json:"data"`
+		}{Data: data}
+		if err := json.NewDecoder(rr.Body).Decode(&sr); err != nil {
+			t.Fatalf("json.Decode() error = %v", err)
+		}
+	}
+	return rr
+}
+
+func TestDefaultSessionHandlers_CreateSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		token      bool
+		wantStatus int
+		wantParam  string
+	}{
+		{"refresh token", `{"name": "nightly import", "refresh_token": "goodRefreshToken"}`, true, http.StatusOK, ""},
+		{"authorization code", `{"name": "nightly import", "authorization_code": "goodCode", "redirect_uri": "https://example.com/callback"}`, true, http.StatusOK, ""},
+		{"no access token", `{"name": "nightly import", "refresh_token": "goodRefreshToken"}`, false, http.StatusUnauthorized, ""},
+		{"no name", `{"refresh_token": "goodRefreshToken"}`, true, http.StatusBadRequest, "name"},
+		{"no grant", `{"name": "nightly import"}`, true, http.StatusBadRequest, "authorization_code"},
+		{"both grants", `{"name": "nightly import", "authorization_code": "goodCode", "redirect_uri": "https://example.com/callback", "refresh_token": "goodRefreshToken"}`, true, http.StatusBadRequest, "authorization_code"},
+		{"no redirect uri", `{"name": "nightly import", "authorization_code": "goodCode"}`, true, http.StatusBadRequest, "redirect_uri"},
+		{"code not offline", `{"name": "nightly import", "authorization_code": "onlineCode", "redirect_uri": "https://example.com/callback"}`, true, http.StatusBadRequest, "authorization_code"},
+		{"code not accepted", `{"name": "nightly import", "authorization_code": "usedCode", "redirect_uri": "https://example.com/callback"}`, true, http.StatusBadRequest, "authorization_code"},
+		{"refresh token revoked", `{"name": "nightly import", "refresh_token": "revokedRefreshToken"}`, true, http.StatusBadRequest, "refresh_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rtr := newSessionRouter(t, memstore.NewSessionStore(), fakeRefresher{revoked: map[string]bool{"revokedRefreshToken": true}})
+
+			var got dto.SessionResponse
+			rr := serveSession(t, rtr, http.MethodPost, pathPrefix+sessionsV1PathRoot, tt.body, tt.token, &got)
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus, qt.Commentf("body: %s", rr.Body.String()))
+
+			switch {
+			case tt.wantStatus == http.StatusOK:
+				c.Assert(got.Name, qt.Equals, "nightly import")
+				c.Assert(got.ID, qt.Not(qt.Equals), "")
+				c.Assert(got.Secret, qt.Not(qt.Equals), "")
+				c.Assert(got.CreateTime, qt.Equals, clocktest.Time.Format(time.RFC3339))
+				c.Assert(rr.Header().Get("Cache-Control"), qt.Equals, "no-store")
+			case tt.wantParam != "":
+				var er errs.ErrResponse
+				c.Assert(json.NewDecoder(rr.Body).Decode(&er), qt.IsNil)
+				c.Assert(er.Error.Param, qt.Equals, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestDefaultSessionHandlers_SessionToken(t *testing.T) {
+	c := qt.New(t)
+
+	store := memstore.NewSessionStore()
+	refresher := fakeRefresher{revoked: make(map[string]bool)}
+	rtr := newSessionRouter(t, store, refresher)
+
+	var s dto.SessionResponse
+	rr := serveSession(t, rtr, http.MethodPost, pathPrefix+sessionsV1PathRoot, `{"name": "nightly import", "refresh_token": "goodRefreshToken"}`, true, &s)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	tokenPath := pathPrefix + sessionsV1PathRoot + "/" + s.ID + "/token"
+	secretBody := `{"secret": "` + s.Secret + `"}`
+
+	// an access token is issued for the session's secret, without an
+	// access token
+	var got dto.SessionTokenResponse
+	rr = serveSession(t, rtr, http.MethodPost, tokenPath, secretBody, false, &got)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK, qt.Commentf("body: %s", rr.Body.String()))
+	c.Assert(got.AccessToken, qt.Equals, "accessToken")
+	c.Assert(got.TokenType, qt.Equals, auth.BearerTokenType)
+	c.Assert(got.ExpiresIn, qt.Equals, int64(3600))
+	c.Assert(got.Expiry, qt.Equals, clocktest.Time.Add(time.Hour).Format(time.RFC3339))
+	c.Assert(rr.Header().Get("Cache-Control"), qt.Equals, "no-store")
+
+	rr = serveSession(t, rtr, http.MethodPost, tokenPath, `{"secret": "wrongSecret"}`, false, nil)
+	c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+
+	rr = serveSession(t, rtr, http.MethodPost, pathPrefix+sessionsV1PathRoot+"/notAnID/token", secretBody, false, nil)
+	c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+
+	// once the refresh token is revoked, the session must be created
+	// again
+	refresher.revoked["goodRefreshToken"] = true
+	rr = serveSession(t, rtr, http.MethodPost, tokenPath, secretBody, false, nil)
+	c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+	c.Assert(rr.Header().Get("WWW-Authenticate"), qt.Contains, `error_description="invalid_grant"`)
+
+	// a deleted session is not issued access tokens
+	refresher.revoked["goodRefreshToken"] = false
+	var deleted dto.DeleteSessionResponse
+	rr = serveSession(t, rtr, http.MethodDelete, pathPrefix+sessionsV1PathRoot+"/"+s.ID, "", true, &deleted)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(deleted, qt.DeepEquals, dto.DeleteSessionResponse{ID: s.ID, Deleted: true})
+	rr = serveSession(t, rtr, http.MethodPost, tokenPath, secretBody, false, nil)
+	c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+	rr = serveSession(t, rtr, http.MethodDelete, pathPrefix+sessionsV1PathRoot+"/"+s.ID, "", true, nil)
+	c.Assert(rr.Code, qt.Equals, http.StatusNotFound)
+}
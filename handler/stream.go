@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// listWriter writes a StandardResponse with a list as its Data to
+// the response body one item at a time, so a large list is never
+// held in memory. Once anything has been written (see Started), the
+// status and headers have been sent and an error can no longer be
+// sent as the response.
+//
+// Items are written with Write and the response is finished with
+// Close. If no items are written, Data is written as an empty list,
+// never null. If the StandardResponse has NoEnvelope set, only the
+// list is written.
+type listWriter struct {
+	w       http.ResponseWriter
+	jb      *jsonBuffer
+	sr      *StandardResponse
+	n       int
+	started bool
+}
+
+// newListWriter is an initializer for listWriter. The Path and
+// RequestID from sr are written before the list and its Links after
+// it, in its Envelope. Data and Meta are ignored.
+func newListWriter(w http.ResponseWriter, sr *StandardResponse) *listWriter {
+	return &listWriter{
+		w:  w,
+		jb: jsonBufferPool.Get().(*jsonBuffer),
+		sr: sr,
+	}
+}
+
+// Started reports whether any of the response body has been written
+func (lw *listWriter) Started() bool {
+	return lw.started
+}
+
+// Write encodes v as JSON and writes it to the response body as the
+// next item in the list
+func (lw *listWriter) Write(v interface{}) error {
+	lw.jb.buf.Reset()
+
+	if lw.n == 0 {
+		if err := lw.head(); err != nil {
+			return err
+		}
+		lw.jb.buf.WriteByte('[')
+	} else {
+		lw.jb.buf.WriteByte(',')
+	}
+
+	if err := lw.jb.encode(v); err != nil {
+		return err
+	}
+	lw.n++
+
+	return lw.flush()
+}
+
+// Close ends the list, writes meta and the Links of the
+// StandardResponse (if not nil and the response has an envelope) and
+// releases the buffer used to encode items. lw cannot be used after
+// Close.
+func (lw *listWriter) Close(meta interface{}) error {
+	defer lw.release()
+
+	lw.jb.buf.Reset()
+
+	if lw.n == 0 {
+		if err := lw.head(); err != nil {
+			return err
+		}
+		lw.jb.buf.WriteString("[]")
+	} else {
+		lw.jb.buf.WriteByte(']')
+	}
+
+	if lw.sr.NoEnvelope {
+		// end with a newline, as json.Encoder does for writeJSON
+		lw.jb.buf.WriteByte('\n')
+		return lw.flush()
+	}
+
+	if err := writeEnvelopeTail(lw.jb, lw.sr, meta, lw.sr.Links); err != nil {
+		return err
+	}
+
+	return lw.flush()
+}
+
+// head adds the start of the StandardResponse, up to the Data value,
+// to the buffer. There is nothing before the Data value if the
+// StandardResponse has NoEnvelope set.
+func (lw *listWriter) head() error {
+	if lw.sr.NoEnvelope {
+		return nil
+	}
+	return writeEnvelopeHead(lw.jb, lw.sr)
+}
+
+// flush writes the buffer to the response body, naming the fields
+// using the FieldNaming of the StandardResponse
+func (lw *listWriter) flush() error {
+	lw.started = true
+
+	b := lw.jb.buf.Bytes()
+	if lw.sr.FieldNaming == CamelCase {
+		b = camelKeys(b)
+	}
+
+	_, err := lw.w.Write(b)
+	return err
+}
+
+// release returns the buffer to jsonBufferPool
+func (lw *listWriter) release() {
+	if lw.jb.buf.Cap() <= maxPooledJSONBuffer {
+		lw.jb.buf.Reset()
+		jsonBufferPool.Put(lw.jb)
+	}
+	lw.jb = nil
+}
+
+// streamErr sends err as the error response if nothing has been
+// written by lw yet. Otherwise the status has been sent and part of
+// the body written, so err can only be logged and the client receives
+// a truncated (invalid JSON) body.
+func streamErr(w http.ResponseWriter, logger zerolog.Logger, lw *listWriter, err error) {
+	if !lw.Started() {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	logger.Error().Err(err).Msg("error writing streamed response")
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func Test_listWriter(t *testing.T) {
+	type item struct {
+		Title string `json:"title"`
+	}
+
+	tests := []struct {
+		name  string
+		sr    StandardResponse
+		items []item
+		meta  interface{}
+	}{
+		{"empty", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d"}, []item{}, nil},
+		{"one item", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d"}, []item{{"Repo Man"}}, nil},
+		{"items and meta", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d"}, []item{{"Repo Man"}, {"<Alien>"}}, PageMeta{Page: 1, PageSize: 20, TotalCount: 2, TotalPages: 1}},
+		{"empty with meta", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d"}, []item{}, PageMeta{Page: 2, PageSize: 20, TotalCount: 2, TotalPages: 1}},
+		{"no path or request ID", StandardResponse{}, []item{{"Repo Man"}}, nil},
+		{"no envelope", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d", NoEnvelope: true}, []item{{"Repo Man"}, {"<Alien>"}}, PageMeta{Page: 1, PageSize: 20, TotalCount: 2, TotalPages: 1}},
+		{"empty no envelope", StandardResponse{NoEnvelope: true}, []item{}, nil},
+		{"renamed fields", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d", Links: map[string]string{"next": "/api/v1/movies?page=2"}, Envelope: Envelope{Names: map[EnvelopeField]string{EnvelopeRequestID: "trace_id", EnvelopeData: "items", EnvelopeMeta: "pagination"}}}, []item{{"Repo Man"}}, PageMeta{Page: 1, PageSize: 20, TotalCount: 1, TotalPages: 1}},
+		{"omitted fields", StandardResponse{Path: "/api/v1/movies", RequestID: "c0mm0nrequest1d", Envelope: Envelope{Names: map[EnvelopeField]string{EnvelopePath: "-", EnvelopeRequestID: "-", EnvelopeMeta: "-"}}}, []item{}, PageMeta{Page: 1, PageSize: 20, TotalCount: 0, TotalPages: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			// the streamed body must be the same as writing the whole
+			// StandardResponse at once
+			want := httptest.NewRecorder()
+			sr := tt.sr
+			sr.Data = tt.items
+			sr.Meta = tt.meta
+			c.Assert(writeJSON(want, &sr), qt.IsNil)
+
+			got := httptest.NewRecorder()
+			lw := newListWriter(got, &tt.sr)
+			c.Assert(lw.Started(), qt.IsFalse)
+			for _, it := range tt.items {
+				c.Assert(lw.Write(it), qt.IsNil)
+				c.Assert(lw.Started(), qt.IsTrue)
+			}
+			c.Assert(lw.Close(tt.meta), qt.IsNil)
+
+			c.Assert(got.Body.String(), qt.Equals, want.Body.String())
+		})
+	}
+}
+
+func Test_streamErr(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := zerolog.Nop()
+	err := errs.E(errs.Database, "connection reset")
+
+	// nothing written, the error is the response
+	rr := httptest.NewRecorder()
+	lw := newListWriter(rr, &StandardResponse{})
+	streamErr(rr, lgr, lw, err)
+	c.Assert(rr.Code, qt.Equals, http.StatusInternalServerError)
+
+	// after the first item the error can only be logged
+	rr = httptest.NewRecorder()
+	lw = newListWriter(rr, &StandardResponse{})
+	c.Assert(lw.Write("Repo Man"), qt.IsNil)
+	streamErr(rr, lgr, lw, err)
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Body.String(), qt.Equals, `{"data":["Repo Man"`)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// TenantHeader is the header naming the tenant a request is made for
+const TenantHeader string = "X-Tenant-ID"
+
+// Tenants are the tenants requests can be made for, each with its
+// own database, and the tenant the users of each Google Workspace
+// domain belong to
+type Tenants struct {
+	// Names are the names of the tenants
+	Names []string
+	// Domains are the names of the tenants by the hosted domain of
+	// the users who belong to them. A user whose hosted domain is not
+	// one of Domains belongs to no tenant.
+	Domains map[string]string
+}
+
+// has reports whether tenant is one of ts
+func (ts Tenants) has(tenant string) bool {
+	for _, t := range ts.Names {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantOf returns the tenant u belongs to, if any
+func (ts Tenants) tenantOf(u user.User) (string, bool) {
+	if u.HostedDomain == "" {
+		return "", false
+	}
+	tenant, ok := ts.Domains[strings.ToLower(u.HostedDomain)]
+	return tenant, ok
+}
+
+// resolve returns the tenant the request r of u is made for, which
+// is the tenant u belongs to. The X-Tenant-ID header of r, if it is
+// set, must name that tenant: a tenant which is not one of ts is a
+// Validation error and a tenant u does not belong to is an
+// Unauthorized error. ok is false if u belongs to no tenant.
+func (ts Tenants) resolve(r *http.Request, u user.User) (tenant string, ok bool, err error) {
+	tenant, ok = ts.tenantOf(u)
+
+	header := r.Header.Get(TenantHeader)
+	if header == "" {
+		return tenant, ok, nil
+	}
+	if !ts.has(header) {
+		return "", false, errs.E(errs.Validation, errs.Parameter(TenantHeader), errs.Code("unknown_tenant"), errors.Errorf("unknown tenant %q", header))
+	}
+	if !ok || header != tenant {
+		return "", false, errs.E(errs.Unauthorized, errs.Code("tenant_forbidden"), errors.Errorf("user does not belong to tenant %q", header))
+	}
+
+	return tenant, true, nil
+}
+
+// TenantHandler middleware sets the tenant the authenticated user
+// belongs to (see Tenants.Domains) to the request context (see
+// requestinfo.TenantFromContext), so the queries of the request are
+// run in the tenant's database. A user who belongs to no tenant uses
+// the default database. The tenant is never taken from the client:
+// an X-Tenant-ID header naming a tenant which is not one of ts is
+// rejected with a 400 Bad Request, and one naming a tenant the user
+// does not belong to with a 403 Forbidden, rather than falling back
+// to another database. As the tenant comes from the user, the header
+// does not change the response, so responses do not vary by it. The
+// tenant is also set to the audit event of the request (see
+// audit.SetTenant). It must be added after AuthMiddleware.
+func TenantHandler(ts Tenants) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, err := userFromRequest(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+				return
+			}
+
+			tenant, ok, err := ts.resolve(r, u)
+			if err != nil {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), err)
+				return
+			}
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			lgr := zerolog.Ctx(r.Context())
+			lgr.UpdateContext(func(zc zerolog.Context) zerolog.Context {
+				return zc.Str("tenant", tenant)
+			})
+			audit.SetTenant(r.Context(), tenant)
+			h.ServeHTTP(w, r.WithContext(requestinfo.WithTenant(r.Context(), tenant)))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+func TestTenantHandler(t *testing.T) {
+	ts := Tenants{
+		Names:   []string{"acme", "globex"},
+		Domains: map[string]string{"acme.com": "acme", "globex.com": "globex"},
+	}
+
+	// the data of each tenant's database, the default database has
+	// no tenant
+	databases := map[string]string{"": "default data", "acme": "acme data", "globex": "globex data"}
+
+	acmeUser := user.User{Email: "otto.maddox@acme.com", HostedDomain: "acme.com"}
+	globexUser := user.User{Email: "bud@globex.com", HostedDomain: "globex.com"}
+	gmailUser := user.User{Email: "leila@gmail.com"}
+
+	tests := []struct {
+		name       string
+		u          user.User
+		header     string
+		wantStatus int
+		wantCode   string
+		wantTenant string
+		wantOK     bool
+	}{
+		{"tenant from hosted domain", acmeUser, "", http.StatusOK, "", "acme", true},
+		{"header of own tenant", acmeUser, "acme", http.StatusOK, "", "acme", true},
+		{"hosted domain case", user.User{Email: "otto.maddox@acme.com", HostedDomain: "ACME.com"}, "acme", http.StatusOK, "", "acme", true},
+		{"other tenant", globexUser, "", http.StatusOK, "", "globex", true},
+		{"no tenant", gmailUser, "", http.StatusOK, "", "", false},
+		// the header cannot switch a user to another tenant's database
+		{"header of another tenant", acmeUser, "globex", http.StatusForbidden, "", "", false},
+		{"header of another tenant by other user", globexUser, "acme", http.StatusForbidden, "", "", false},
+		{"header without tenant", gmailUser, "acme", http.StatusForbidden, "", "", false},
+		{"header by anonymous user", user.Anonymous(), "acme", http.StatusForbidden, "", "", false},
+		{"unknown tenant", acmeUser, "initech", http.StatusBadRequest, "unknown_tenant", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			var (
+				gotTenant      string
+				gotOK          bool
+				gotAuditTenant string
+				called         bool
+			)
+			withUser := func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					ctx := audit.NewContext(requestinfo.WithUser(r.Context(), tt.u))
+					h.ServeHTTP(w, r.WithContext(ctx))
+				})
+			}
+			h := LoggerHandlerChain(zerolog.New(&buf), alice.New()).
+				Append(withUser, TenantHandler(ts)).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					called = true
+					gotTenant, gotOK = requestinfo.TenantFromContext(r.Context())
+					gotAuditTenant = audit.EventFromContext(r.Context()).Tenant
+					zerolog.Ctx(r.Context()).Info().Msg("handled")
+					_, _ = w.Write([]byte(databases[gotTenant]))
+				})
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			if tt.header != "" {
+				r.Header.Set(TenantHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			c.Assert(rr.Header().Values("Vary"), qt.Not(qt.Contains), TenantHeader)
+			if tt.wantStatus != http.StatusOK {
+				// the handler, and so the tenant's database, is
+				// never reached
+				c.Assert(called, qt.IsFalse)
+				c.Assert(rr.Body.String(), qt.Not(qt.Contains), "data")
+				if tt.wantCode != "" {
+					c.Assert(rr.Body.String(), qt.Contains, `"`+tt.wantCode+`"`)
+				}
+				return
+			}
+			c.Assert(gotTenant, qt.Equals, tt.wantTenant)
+			c.Assert(gotOK, qt.Equals, tt.wantOK)
+			c.Assert(gotAuditTenant, qt.Equals, tt.wantTenant)
+			c.Assert(rr.Body.String(), qt.Equals, databases[tt.wantTenant])
+			if tt.wantOK {
+				c.Assert(buf.String(), qt.Contains, `"tenant":"`+tt.wantTenant+`"`)
+			}
+		})
+	}
+}
+
+func TestTenantHandler_NoUser(t *testing.T) {
+	c := qt.New(t)
+
+	h := LoggerHandlerChain(zerolog.Nop(), alice.New()).
+		Append(TenantHandler(Tenants{Names: []string{"acme"}})).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler called without a user")
+		})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+	r.Header.Set(TenantHeader, "acme")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// UsageHandler is a Handler that returns the request quota usage
+// for the authenticated user
+type UsageHandler http.Handler
+
+// ProvideUsageHandler is a provider for the UsageHandler for wire
+func ProvideUsageHandler(h DefaultUsageHandler) UsageHandler {
+	return http.HandlerFunc(h.Usage)
+}
+
+// DefaultUsageHandler handles requests for a user's quota usage
+type DefaultUsageHandler struct {
+	QuotaTracker quota.Tracker
+}
+
+// Usage handles GET requests for the /users/me/usage endpoint and
+// returns the authenticated user's request counts and remaining
+// quota for the current day and month. Calling Usage does not count
+// against the quota.
+func (h DefaultUsageHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	// the user is authenticated and authorized by AuthMiddleware
+	u, err := userFromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	usage, err := h.QuotaTracker.Usage(ctx, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	response, err := NewStandardResponse(r, dto.NewUsageResponse(u.Email, usage))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
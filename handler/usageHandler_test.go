@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/quota/quotatest"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+func TestDefaultUsageHandler_Usage(t *testing.T) {
+	t.Run("mock DB", func(t *testing.T) {
+		// initialize quickest checker
+		c := qt.New(t)
+
+		// initialize a zerolog Logger
+		lgr := logger.NewLogger(os.Stdout, true)
+
+		// initialize DefaultUsageHandler
+		duh := DefaultUsageHandler{
+			QuotaTracker: quotatest.NewMockTracker(t),
+		}
+
+		// setup path
+		path := pathPrefix + usersV1PathRoot + "/me/usage"
+
+		// form request using httptest
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+
+		// add test access token
+		req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+		// create middleware to extract the request ID from
+		// the request context for testing comparison
+		var requestID string
+		requestIDMiddleware := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rID, ok := hlog.IDFromRequest(r)
+				if !ok {
+					t.Fatal("Request ID not set to request context")
+				}
+				requestID = rID.String()
+
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		// initialize ResponseRecorder to use with ServeHTTP as it
+		// satisfies ResponseWriter interface and records the response
+		// for testing
+		rr := httptest.NewRecorder()
+
+		// setup full handler chain needed for request
+		h := LoggerHandlerChain(lgr, alice.New()).
+			Append(AccessTokenHandler).
+			Append(newMockAuthHandler(t, scopeUsageRead)).
+			Append(JSONContentTypeHandler).
+			Append(requestIDMiddleware).
+			Then(ProvideUsageHandler(duh))
+
+		// call the handler ServeHTTP method to execute the request
+		// and record the response
+		h.ServeHTTP(rr, req)
+
+		// Assert that Response Status Code equals 200 (StatusOK)
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		// standardResponse decodes the response with Data as the dto type
+		type standardResponse struct {
+			Path      string            `json:"path"`
+			RequestID string            `json:"request_id"`
+			Data      dto.UsageResponse `json:"data"`
+		}
+
+		// setup the expected response data from the mocked usage,
+		// the monthly window has no limit, so no remaining either
+		remaining := int64(999)
+		wantBody := standardResponse{
+			Path:      path,
+			RequestID: requestID,
+			Data: dto.UsageResponse{
+				Username: usertest.NewUser(t).Email,
+				Usage: []dto.WindowResponse{
+					{Window: "day", Limit: 1000, Used: 1, Remaining: &remaining, ResetsAt: "2008-01-09T00:00:00Z"},
+					{Window: "month", Used: 1, ResetsAt: "2008-02-01T00:00:00Z"},
+				},
+			},
+		}
+
+		// decode the response body into the standardResponse (gotBody)
+		gotBody := standardResponse{}
+		err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+		defer rr.Result().Body.Close()
+
+		// Assert that there is no error after decoding the response body
+		c.Assert(err, qt.IsNil)
+
+		// Assert that the response body (gotBody) is as expected (wantBody).
+		c.Assert(gotBody, qt.DeepEquals, wantBody)
+	})
+}
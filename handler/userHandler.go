@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// EraseUserDataHandler is a Handler that erases the personal data of
+// a user
+type EraseUserDataHandler http.Handler
+
+// ProvideEraseUserDataHandler is a provider for the
+// EraseUserDataHandler for wire
+func ProvideEraseUserDataHandler(h DefaultUserHandlers) EraseUserDataHandler {
+	return http.HandlerFunc(h.EraseUserData)
+}
+
+// DefaultUserHandlers handles requests for the users of the API
+type DefaultUserHandlers struct {
+	Eraser user.Eraser
+}
+
+// EraseUserData handles DELETE requests for the /users/{id}/data
+// endpoint. The email and names of the user with the ID (from the
+// users table) are erased and every reference to the user is
+// replaced by a tombstone identity, e.g. to fulfil a GDPR erasure
+// request. The response body reports what was erased. Erasing a
+// user who was already erased changes nothing.
+func (h DefaultUserHandlers) EraseUserData(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("id"), errors.New("id must be a user ID")))
+		return
+	}
+
+	rpt, err := h.Eraser.Erase(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// the erasure is logged without any personal data, so there is
+	// a record of it once the data is gone
+	logger.Info().
+		Str("erased_user_id", rpt.UserID.String()).
+		Int64("audit_records", rpt.AuditRecords).
+		Int64("movies", rpt.Movies).
+		Int64("people", rpt.People).
+		Int64("request_counts", rpt.RequestCounts).
+		Int64("sessions", rpt.Sessions).
+		Int64("export_jobs", rpt.ExportJobs).
+		Msg("user data erased")
+
+	response, err := NewStandardResponse(r, dto.NewErasureReportResponse(rpt))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// Encode response struct to JSON for the response body
+	err = writeJSON(w, response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
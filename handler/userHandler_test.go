@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/handler/dto"
+)
+
+// mockEraser erases the user with id, reporting rpt
+type mockEraser struct {
+	id  uuid.UUID
+	rpt user.ErasureReport
+}
+
+func (m mockEraser) Erase(ctx context.Context, id uuid.UUID) (user.ErasureReport, error) {
+	if id != m.id {
+		return user.ErasureReport{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return m.rpt, nil
+}
+
+func TestDefaultUserHandlers_EraseUserData(t *testing.T) {
+	id := uuid.New()
+	rpt := user.ErasureReport{
+		UserID:        id,
+		Tombstone:     user.TombstoneUsername,
+		AuditRecords:  12,
+		Movies:        2,
+		RequestCounts: 4,
+		Sessions:      1,
+		ExportJobs:    3,
+		EraseTime:     time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name       string
+		authorizer auth.Authorizer
+		id         string
+		wantStatus int
+	}{
+		{"erased", authtest.NewMockAuthorizer(t), id.String(), http.StatusOK},
+		{"not found", authtest.NewMockAuthorizer(t), uuid.New().String(), http.StatusNotFound},
+		{"bad id", authtest.NewMockAuthorizer(t), "otto", http.StatusBadRequest},
+		// erasing users needs the admin role
+		{"not admin", readOnlyAuthorizer{}, id.String(), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			duh := DefaultUserHandlers{Eraser: mockEraser{id: id, rpt: rpt}}
+			am := AuthMiddleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           tt.authorizer,
+			}
+			rtr := NewMuxRouter(lgr, Handlers{EraseUserDataHandler: ProvideEraseUserDataHandler(duh)}, am, audittest.NewMockWriter(t), errs.NopReporter{}, NewRouteList(), EncodeOptions{}, DecodeOptions{}, nil, Tenants{}, nil, false, false)
+
+			path := pathPrefix + usersV1PathRoot + "/" + tt.id + "/data"
+			req := httptest.NewRequest(http.MethodDelete, path, nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+
+			rtr.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantStatus)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			// standardResponse decodes the response with Data as the dto type
+			type standardResponse struct {
+				Path string                    `json:"path"`
+				Data dto.ErasureReportResponse `json:"data"`
+			}
+			gotBody := standardResponse{}
+			err := DecoderErr(json.NewDecoder(rr.Result().Body).Decode(&gotBody))
+			defer rr.Result().Body.Close()
+			c.Assert(err, qt.IsNil)
+
+			c.Assert(gotBody, qt.DeepEquals, standardResponse{
+				Path: path,
+				Data: dto.ErasureReportResponse{
+					UserID:        id.String(),
+					Tombstone:     user.TombstoneUsername,
+					AuditRecords:  12,
+					Movies:        2,
+					RequestCounts: 4,
+					Sessions:      1,
+					ExportJobs:    3,
+					ErasedAt:      "2021-03-01T12:00:00Z",
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/metrics"
+)
+
+// ClientVersionHeader is the header a client app can send its
+// release in, e.g. 2.3.1 or ios/2.3.1, so the requests of releases
+// which are to be deprecated can be tracked before a breaking change
+const ClientVersionHeader string = "X-Client-Version"
+
+// maxUserAgentLen is the length in bytes a User-Agent header is
+// truncated to
+const maxUserAgentLen int = 512
+
+// clientLabelRegexp matches the valid client versions, and the
+// products of user agents which are used as metric labels
+var clientLabelRegexp = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+/-]{0,63}$`)
+
+// maxClientSeries is the number of client and version pairs counted
+// by clientRequests. The requests of any other pair are counted as
+// other, as both come from the client.
+const maxClientSeries int = 200
+
+// clientRequests is the number of requests by client, the product of
+// the User-Agent, and by client version
+var clientRequests = metrics.NewCounter("go_api_basic_http_client_requests_total",
+	"Requests by client (the product of the User-Agent) and client version (the X-Client-Version header).",
+	"client", "version")
+
+// clientSeries holds the client and version pairs counted by
+// clientRequests
+var clientSeries = struct {
+	sync.Mutex
+	seen map[[2]string]bool
+}{seen: make(map[[2]string]bool)}
+
+// userAgent returns the User-Agent header of r, truncated to
+// maxUserAgentLen. A header can hold any byte, so invalid UTF-8 is
+// replaced, and the header is cut on a rune boundary, as it is
+// stored as text with the audit record of the request.
+func userAgent(r *http.Request) string {
+	ua := strings.ToValidUTF8(r.Header.Get("User-Agent"), "\uFFFD")
+	if len(ua) > maxUserAgentLen {
+		n := maxUserAgentLen
+		for n > 0 && !utf8.RuneStart(ua[n]) {
+			n--
+		}
+		ua = ua[:n]
+	}
+	return ua
+}
+
+// clientVersion returns the X-Client-Version header of r, or an
+// empty string if it is not sent or is not a valid version
+func clientVersion(r *http.Request) string {
+	v := strings.TrimSpace(r.Header.Get(ClientVersionHeader))
+	if !clientLabelRegexp.MatchString(v) {
+		return ""
+	}
+	return v
+}
+
+// parseUserAgent returns the name and version of the first product
+// of the User-Agent ua (RFC 7231, section 5.5.3), e.g. curl and
+// 7.64.1 for curl/7.64.1, which names the client making the request
+func parseUserAgent(ua string) (product, version string) {
+	ua = strings.TrimSpace(ua)
+	if i := strings.IndexAny(ua, " \t("); i >= 0 {
+		ua = ua[:i]
+	}
+	if i := strings.IndexByte(ua, '/'); i >= 0 {
+		return ua[:i], ua[i+1:]
+	}
+	return ua, ""
+}
+
+// clientLabels returns the labels the requests of the client product
+// with the client version are counted with by clientRequests
+func clientLabels(product, version string) (string, string) {
+	if product == "" {
+		product = "none"
+	} else if !clientLabelRegexp.MatchString(product) {
+		product = "invalid"
+	}
+	if version == "" {
+		version = "none"
+	}
+
+	key := [2]string{product, version}
+	clientSeries.Lock()
+	defer clientSeries.Unlock()
+	if !clientSeries.seen[key] {
+		if len(clientSeries.seen) >= maxClientSeries {
+			return "other", "other"
+		}
+		clientSeries.seen[key] = true
+	}
+	return product, version
+}
+
+// clientVersionHandler middleware adds the product and product
+// version of the user agent and the client version as fields to the
+// context's logger, and counts the request by client and client
+// version, so requests from old releases of a client can be found
+func clientVersionHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := requestClient(r)
+		product, productVersion := parseUserAgent(c.UserAgent)
+
+		lgr := zerolog.Ctx(r.Context())
+		lgr.UpdateContext(func(zc zerolog.Context) zerolog.Context {
+			if product != "" {
+				zc = zc.Str("client", product).Str("client_product_version", productVersion)
+			}
+			if c.Version != "" {
+				zc = zc.Str("client_version", c.Version)
+			}
+			return zc
+		})
+		clientRequests.Inc(clientLabels(product, c.Version))
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+)
+
+func Test_parseUserAgent(t *testing.T) {
+	tests := []struct {
+		name        string
+		ua          string
+		wantProduct string
+		wantVersion string
+	}{
+		{"empty", "", "", ""},
+		{"product", "curl/7.64.1", "curl", "7.64.1"},
+		{"no version", "movie-app", "movie-app", ""},
+		{"comment", "movie-app/2.3.1 (iOS 15.2)", "movie-app", "2.3.1"},
+		{"browser", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15", "Mozilla", "5.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			product, version := parseUserAgent(tt.ua)
+			c.Assert(product, qt.Equals, tt.wantProduct)
+			c.Assert(version, qt.Equals, tt.wantVersion)
+		})
+	}
+}
+
+func Test_clientVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want string
+	}{
+		{"not sent", "", ""},
+		{"version", "2.3.1", "2.3.1"},
+		{"platform and version", " ios/2.3.1-beta+42 ", "ios/2.3.1-beta+42"},
+		{"invalid", "2.3.1; drop table", ""},
+		{"too long", strings.Repeat("1", 65), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			r.Header.Set(ClientVersionHeader, tt.v)
+
+			qt.Assert(t, clientVersion(r), qt.Equals, tt.want)
+		})
+	}
+}
+
+func Test_userAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{"valid", "curl/7.64.1", "curl/7.64.1"},
+		{"truncated", strings.Repeat("a", maxUserAgentLen+1), strings.Repeat("a", maxUserAgentLen)},
+		{"invalid UTF-8", "curl/\xff\xfe", "curl/\uFFFD"},
+		// the 3 byte rune across the limit is left out whole
+		{"rune boundary", strings.Repeat("a", maxUserAgentLen-1) + "€", strings.Repeat("a", maxUserAgentLen-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+			r.Header.Set("User-Agent", tt.ua)
+
+			got := userAgent(r)
+			qt.Assert(t, got, qt.Equals, tt.want)
+			qt.Assert(t, utf8.ValidString(got), qt.IsTrue)
+		})
+	}
+}
+
+func TestClientVersionHandler(t *testing.T) {
+	c := qt.New(t)
+
+	var buf bytes.Buffer
+	lgr := zerolog.New(&buf)
+
+	h := LoggerHandlerChain(lgr, alice.New()).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			zerolog.Ctx(r.Context()).Info().Msg("handled")
+		})
+
+	before := clientRequests.Value("movie-app", "ios/2.3.1")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+	r.Header.Set("User-Agent", "movie-app/2.3.1 (iOS)")
+	r.Header.Set(ClientVersionHeader, "ios/2.3.1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	c.Assert(clientRequests.Value("movie-app", "ios/2.3.1")-before, qt.Equals, float64(1))
+	c.Assert(buf.String(), qt.Contains, `"client":"movie-app","client_product_version":"2.3.1","client_version":"ios/2.3.1"`)
+
+	// a request without either is counted as none
+	before = clientRequests.Value("none", "none")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+	c.Assert(clientRequests.Value("none", "none")-before, qt.Equals, float64(1))
+}
+
+func Test_clientLabels(t *testing.T) {
+	c := qt.New(t)
+
+	// the pairs seen are restored, so the pairs of other tests are
+	// not counted as other
+	clientSeries.Lock()
+	seen := clientSeries.seen
+	clientSeries.seen = make(map[[2]string]bool)
+	clientSeries.Unlock()
+	c.Cleanup(func() {
+		clientSeries.Lock()
+		clientSeries.seen = seen
+		clientSeries.Unlock()
+	})
+
+	c.Assert(labelPair(clientLabels("curl", "")), qt.Equals, [2]string{"curl", "none"})
+	c.Assert(labelPair(clientLabels("<script>", "1.0")), qt.Equals, [2]string{"invalid", "1.0"})
+
+	// past maxClientSeries pairs, new pairs are counted as other
+	for i := 0; i < maxClientSeries; i++ {
+		clientLabels("flood", strconv.Itoa(i))
+	}
+	c.Assert(labelPair(clientLabels("flood", "new")), qt.Equals, [2]string{"other", "other"})
+	c.Assert(labelPair(clientLabels("curl", "")), qt.Equals, [2]string{"curl", "none"})
+}
+
+// labelPair returns the labels returned by clientLabels as an array
+func labelPair(client, version string) [2]string {
+	return [2]string{client, version}
+}
@@ -5,19 +5,37 @@ package main
 import (
 	"context"
 	"database/sql"
-	"net/http"
 
 	"github.com/gilcrest/go-api-basic/domain/random"
 
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/datastore/exportstore"
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
+	"github.com/gilcrest/go-api-basic/datastore/quotastore"
+	"github.com/gilcrest/go-api-basic/datastore/sessionstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
 
+	"github.com/gilcrest/go-api-basic/domain/audit"
 	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/export"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/domain/user"
 	"github.com/gilcrest/go-api-basic/gateway/authgateway"
+	"github.com/gilcrest/go-api-basic/gateway/errorgateway"
+	"github.com/gilcrest/go-api-basic/gateway/httpclient"
 
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/handler"
-	"github.com/gorilla/mux"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
+	"github.com/gilcrest/go-api-basic/service/personsvc"
 	"github.com/rs/zerolog"
 	"go.opencensus.io/trace"
 
@@ -28,55 +46,233 @@ import (
 	"gocloud.dev/server/health/sqlhealth"
 )
 
+var httpClientSet = wire.NewSet(
+	httpclient.DefaultConfig,
+	httpclient.New,
+)
+
+var errorReportingSet = wire.NewSet(
+	errorgateway.NewReporter,
+)
+
+var metricsHandlerSet = wire.NewSet(
+	handler.ProvideMetricsHandler,
+)
+
 var pingHandlerSet = wire.NewSet(
-	pingstore.NewDefaultPinger,
-	wire.Bind(new(pingstore.Pinger), new(pingstore.DefaultPinger)),
 	wire.Struct(new(handler.DefaultPingHandler), "*"),
 	handler.ProvidePingHandler,
 )
 
+var quotaSet = wire.NewSet(
+	wire.Struct(new(quota.DefaultTracker), "*"),
+	wire.Bind(new(quota.Tracker), new(quota.DefaultTracker)),
+)
+
+var usageHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultUsageHandler), "*"),
+	handler.ProvideUsageHandler,
+)
+
+var permissionsHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultPermissionsHandler), "*"),
+	handler.ProvidePermissionsHandler,
+)
+
+var userHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultUserHandlers), "*"),
+	handler.ProvideEraseUserDataHandler,
+)
+
+var sessionHandlerSet = wire.NewSet(
+	wire.Bind(new(random.SecretGenerator), new(random.DefaultStringGenerator)),
+	wire.Struct(new(handler.DefaultSessionHandlers), "*"),
+	handler.ProvideCreateSessionHandler,
+	handler.ProvideSessionTokenHandler,
+	handler.ProvideDeleteSessionHandler,
+)
+
+// exportJobHandlerSet runs export jobs in the background
+var exportJobHandlerSet = wire.NewSet(
+	newExportRunner,
+	newExportURLExpiry,
+	wire.Struct(new(handler.DefaultExportJobHandlers), "*"),
+	handler.ProvideCreateMovieExportJobHandler,
+	handler.ProvideFindExportJobHandler,
+)
+
+var auditSet = wire.NewSet(
+	audit.NewSink,
+	audit.NewAsyncWriter,
+	wire.Bind(new(audit.Writer), new(*audit.AsyncWriter)),
+	wire.Struct(new(handler.DefaultAuditHandler), "*"),
+	handler.ProvideFindAuditRecordsHandler,
+)
+
+var routesHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultRoutesHandler), "*"),
+	handler.ProvideFindRoutesHandler,
+)
+
 var movieHandlerSet = wire.NewSet(
 	wire.Struct(new(random.DefaultStringGenerator), "*"),
-	wire.Bind(new(random.StringGenerator), new(random.DefaultStringGenerator)),
-	wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"),
-	wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)),
-	wire.Struct(new(auth.DefaultAuthorizer), "*"),
-	wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)),
-	moviestore.NewDefaultTransactor,
-	wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)),
-	moviestore.NewDefaultSelector,
-	wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)),
+	wire.Bind(new(random.ExternalIDGenerator), new(random.DefaultStringGenerator)),
+	wire.Struct(new(clock.DefaultClock), "*"),
+	wire.Bind(new(clock.Clock), new(clock.DefaultClock)),
+	wire.Struct(new(idgen.DefaultUUIDGenerator), "*"),
+	wire.Bind(new(idgen.UUIDGenerator), new(idgen.DefaultUUIDGenerator)),
+	wire.Struct(new(moviesvc.Service), "*"),
 	wire.Struct(new(handler.DefaultMovieHandlers), "*"),
 	handler.ProvideCreateMovieHandler,
 	handler.ProvideFindMovieByIDHandler,
+	handler.ProvideMovieDiffHandler,
 	handler.ProvideFindAllMoviesHandler,
+	handler.ProvideFindMoviesByIDsHandler,
 	handler.ProvideUpdateMovieHandler,
 	handler.ProvideDeleteMovieHandler,
+	handler.ProvideMovieStatsHandler,
+	handler.ProvideSuggestMoviesHandler,
+	handler.ProvideCountMoviesHandler,
 	wire.Struct(new(handler.Handlers), "*"),
 )
 
+var personHandlerSet = wire.NewSet(
+	wire.Struct(new(personsvc.Service), "*"),
+	wire.Struct(new(handler.DefaultPersonHandlers), "*"),
+	handler.ProvideCreatePersonHandler,
+	handler.ProvideUpdatePersonHandler,
+	handler.ProvideDeletePersonHandler,
+	handler.ProvideFindPersonByIDHandler,
+	handler.ProvideFindAllPeopleHandler,
+	handler.ProvideFindPersonMoviesHandler,
+	handler.ProvideAddPersonMovieHandler,
+	wire.Struct(new(handler.PersonHandlers), "*"),
+)
+
+// authSet authenticates access tokens with Google and authorizes
+// users with the DefaultAuthorizer
+var authSet = wire.NewSet(
+	authgateway.NewGoogleMetadataCache,
+	wire.Bind(new(metadataRefresher), new(*authgateway.MetadataCache)),
+	wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"),
+	wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)),
+	wire.Struct(new(authgateway.GoogleTokenRefresher), "*"),
+	wire.Bind(new(auth.TokenRefresher), new(authgateway.GoogleTokenRefresher)),
+	wire.Struct(new(auth.DefaultAuthorizer), "*"),
+	wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)),
+)
+
+// mockAuthSet accepts any access token and authorizes every user,
+// see the -mock flag
+var mockAuthSet = wire.NewSet(
+	newMockAccessTokenConverter,
+	wire.Bind(new(auth.AccessTokenConverter), new(auth.StaticAccessTokenConverter)),
+	newMockTokenRefresher,
+	wire.Bind(new(auth.TokenRefresher), new(auth.StaticTokenRefresher)),
+	wire.Struct(new(auth.AllowAllAuthorizer)),
+	wire.Bind(new(auth.Authorizer), new(auth.AllowAllAuthorizer)),
+	wire.InterfaceValue(new(metadataRefresher), metadataRefresher(nil)),
+)
+
 var datastoreSet = wire.NewSet(
-	datastore.NewDB,
+	newDB,
 	datastore.NewDefaultDatastore,
 	wire.Bind(new(datastore.Datastorer), new(datastore.DefaultDatastore)),
+	newTenantDatastore,
+	newReplicaDatastore,
+	newConsistencyTokens,
+	newBreaker,
+	wire.Bind(new(handler.Availability), new(*datastore.Breaker)),
+)
+
+// pgStoreSet has the PostgreSQL implementations of the stores
+var pgStoreSet = wire.NewSet(
+	newMovieTransactor,
+	wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)),
+	newMovieSelector,
+	wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)),
+	wire.Bind(new(statsRefresher), new(moviestore.DefaultSelector)),
+	newPersonTransactor,
+	wire.Bind(new(personstore.Transactor), new(personstore.DefaultTransactor)),
+	newPersonSelector,
+	wire.Bind(new(personstore.Selector), new(personstore.DefaultSelector)),
+	quotastore.NewDefaultCounter,
+	wire.Bind(new(quota.Counter), new(quotastore.DefaultCounter)),
+	auditstore.NewDefaultStore,
+	wire.Bind(new(audit.Store), new(auditstore.DefaultStore)),
+	pingstore.NewDefaultPinger,
+	wire.Bind(new(pingstore.Pinger), new(pingstore.DefaultPinger)),
+	userstore.NewDefaultStore,
+	wire.Bind(new(user.Eraser), new(userstore.DefaultStore)),
+	newUserRecorder,
+	sessionstore.NewDefaultStore,
+	wire.Bind(new(auth.SessionStore), new(sessionstore.DefaultStore)),
+	exportstore.NewDefaultStore,
+	wire.Bind(new(export.JobStore), new(exportstore.DefaultStore)),
+)
+
+// memStoreSet has the in-memory implementations of the stores, see
+// the -mock flag
+var memStoreSet = wire.NewSet(
+	newMockMovieStore,
+	wire.Bind(new(moviestore.Transactor), new(*memstore.MovieStore)),
+	wire.Bind(new(moviestore.Selector), new(*memstore.MovieStore)),
+	memstore.NewPersonStore,
+	wire.Bind(new(personstore.Transactor), new(*memstore.PersonStore)),
+	wire.Bind(new(personstore.Selector), new(*memstore.PersonStore)),
+	memstore.NewCounter,
+	wire.Bind(new(quota.Counter), new(*memstore.Counter)),
+	memstore.NewAuditStore,
+	wire.Bind(new(audit.Store), new(*memstore.AuditStore)),
+	wire.Struct(new(memstore.Pinger)),
+	wire.Bind(new(pingstore.Pinger), new(memstore.Pinger)),
+	memstore.NewUserStore,
+	wire.Bind(new(user.Recorder), new(*memstore.UserStore)),
+	memstore.NewUserEraser,
+	wire.Bind(new(user.Eraser), new(*memstore.UserEraser)),
+	memstore.NewSessionStore,
+	wire.Bind(new(auth.SessionStore), new(*memstore.SessionStore)),
+	memstore.NewExportJobStore,
+	wire.Bind(new(export.JobStore), new(*memstore.ExportJobStore)),
 )
 
 // goCloudServerSet
 var goCloudServerSet = wire.NewSet(
 	trace.AlwaysSample,
 	server.New,
-	server.NewDefaultDriver,
-	wire.Bind(new(driver.Server), new(*server.DefaultDriver)),
+	newServerDriver,
+	wire.Bind(new(driver.Server), new(*protocolDriver)),
+)
+
+// adminSet is the admin server, served on its own port
+var adminSet = wire.NewSet(
+	wire.Struct(new(handler.AdminHandlers), "*"),
+	handler.NewAdminRouter,
+	newAdminServer,
+	newWarmupHooks,
+	newResyncSteps,
+	wire.Struct(new(application), "*"),
+)
+
+// eventSet is the bus the events of the domain are published on
+var eventSet = wire.NewSet(
+	event.NewInProcessBus,
+	wire.Bind(new(event.Subscriber), new(*event.InProcessBus)),
+	wire.Bind(new(event.Publisher), new(*event.InProcessBus)),
 )
 
 var routerSet = wire.NewSet(
+	wire.Struct(new(handler.AuthMiddleware), "*"),
+	handler.NewRouteList,
+	newResponseCache,
+	newTenants,
 	handler.NewMuxRouter,
-	wire.Bind(new(http.Handler), new(*mux.Router)),
+	handler.NewAPIHandler,
 )
 
 // newServer is a Wire injector function that sets up the
 // application using a PostgreSQL implementation
-func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName) (*server.Server, func(), error) {
+func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName, poolCfg datastore.PoolStatsConfig, breakerCfg datastore.BreakerConfig, cachePolicies handler.CachePolicies, pagination handler.Pagination, limits *quota.ReloadableLimits, decodeOpts handler.DecodeOptions, encodeOpts handler.EncodeOptions, policy movie.ValidationPolicy, auditCfg audit.SinkConfig, reportCfg errorgateway.Config, piiCipher pii.Cipher, tokenCfg authgateway.TokenValidation, oauthClient authgateway.OAuthClient, protoCfg protocolConfig, pathOpts handler.PathNormalization, proxies handler.TrustedProxies, publicRead handler.PublicRead, respCacheCfg responseCacheConfig, tenantCfg tenantConfig, replicaCfg replicaConfig, exportCfg exportConfig, settings handler.Settings, adminServed handler.AdminServed) (*application, func(), error) {
 	// This will be filled in by Wire with providers from the provider sets in
 	// wire.Build.
 	wire.Build(
@@ -85,9 +281,61 @@ func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatas
 		appHealthChecks,
 		wire.Struct(new(server.Options), "HealthChecks", "TraceExporter", "DefaultSamplingPolicy", "Driver"),
 		datastoreSet,
+		pgStoreSet,
+		httpClientSet,
+		authSet,
+		quotaSet,
+		auditSet,
+		errorReportingSet,
+		movieHandlerSet,
+		personHandlerSet,
+		usageHandlerSet,
+		permissionsHandlerSet,
+		userHandlerSet,
+		sessionHandlerSet,
+		exportJobHandlerSet,
+		routesHandlerSet,
+		pingHandlerSet,
+		metricsHandlerSet,
+		eventSet,
+		routerSet,
+		adminSet,
+	)
+	return nil, nil, nil
+}
+
+// newMockServer is a Wire injector function that sets up the
+// application using in-memory stores and no authentication
+func newMockServer(ctx context.Context, logger zerolog.Logger, cachePolicies handler.CachePolicies, pagination handler.Pagination, limits *quota.ReloadableLimits, decodeOpts handler.DecodeOptions, encodeOpts handler.EncodeOptions, policy movie.ValidationPolicy, auditCfg audit.SinkConfig, reportCfg errorgateway.Config, protoCfg protocolConfig, pathOpts handler.PathNormalization, proxies handler.TrustedProxies, publicRead handler.PublicRead, respCacheCfg responseCacheConfig, tenantCfg tenantConfig, exportCfg exportConfig, settings handler.Settings, adminServed handler.AdminServed) (*application, func(), error) {
+	// This will be filled in by Wire with providers from the provider sets in
+	// wire.Build.
+	wire.Build(
+		wire.InterfaceValue(new(trace.Exporter), trace.Exporter(nil)),
+		goCloudServerSet,
+		wire.Value([]health.Checker(nil)),
+		wire.InterfaceValue(new(handler.Availability), handler.Availability(nil)),
+		wire.InterfaceValue(new(statsRefresher), statsRefresher(nil)),
+		wire.Value(handler.ConsistencyTokens(false)),
+		wire.Struct(new(server.Options), "HealthChecks", "TraceExporter", "DefaultSamplingPolicy", "Driver"),
+		memStoreSet,
+		mockAuthSet,
+		httpClientSet,
+		quotaSet,
+		auditSet,
+		errorReportingSet,
 		movieHandlerSet,
+		personHandlerSet,
+		usageHandlerSet,
+		permissionsHandlerSet,
+		userHandlerSet,
+		sessionHandlerSet,
+		exportJobHandlerSet,
+		routesHandlerSet,
 		pingHandlerSet,
+		metricsHandlerSet,
+		eventSet,
 		routerSet,
+		adminSet,
 	)
 	return nil, nil, nil
 }
@@ -122,10 +370,34 @@ func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatas
 // appHealthChecks returns a health check for the database. This will signal
 // to Kubernetes or other orchestrators that the server should not receive
 // traffic until the server is able to connect to its database.
-func appHealthChecks(db *sql.DB) ([]health.Checker, func()) {
+func appHealthChecks(db *sql.DB, b *datastore.Breaker) ([]health.Checker, func()) {
 	dbCheck := sqlhealth.New(db)
-	list := []health.Checker{dbCheck}
+	list := []health.Checker{dbCheck, b}
 	return list, func() {
 		dbCheck.Stop()
 	}
 }
+
+// newUserRecorder records users in the users table using s, at most
+// once an hour for each user
+func newUserRecorder(s userstore.DefaultStore) user.Recorder {
+	return user.NewThrottledRecorder(s, user.DefaultRecordInterval)
+}
+
+// newDB opens the database using datastore.NewDB and reports
+// connection pool statistics in the background until the returned
+// cleanup function is called
+func newDB(dsn datastore.PGDatasourceName, poolCfg datastore.PoolStatsConfig, logger zerolog.Logger) (*sql.DB, func(), error) {
+	db, cleanup, err := datastore.NewDB(dsn, logger)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go datastore.ReportPoolStats(ctx, db, poolCfg, logger)
+
+	return db, func() {
+		cancel()
+		cleanup()
+	}, nil
+}
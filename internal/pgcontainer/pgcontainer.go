@@ -0,0 +1,149 @@
+// Package pgcontainer starts a throwaway PostgreSQL database in a
+// Docker container for the tests, using testcontainers-go. The
+// database is started the first time a test of a package asks for it
+// and shared by the rest of the tests of the package.
+package pgcontainer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Environment variables used to choose the container
+const (
+	// noDBEnv is set to true to skip the database tests rather than
+	// start a container
+	noDBEnv string = "NO_DB"
+	// imageEnv optionally overrides the PostgreSQL image
+	imageEnv string = "DB_CONTAINER_IMAGE"
+)
+
+const (
+	defaultImage string = "postgres:13-alpine"
+	dbName       string = "go_api_basic"
+	dbUser       string = "postgres"
+	dbPassword   string = "postgres"
+
+	// startTimeout is how long to wait for the database in the
+	// container to accept connections
+	startTimeout = time.Minute
+
+	// dockerCheckTimeout is how long to wait for the Docker daemon to
+	// answer when checking it is available
+	dockerCheckTimeout = 10 * time.Second
+)
+
+// ErrUnavailable is the cause of the error returned by Start when no
+// container can be started, because Docker is not available or
+// NO_DB is true. The tests needing a database are skipped rather
+// than failed.
+var ErrUnavailable = errors.New("no database container")
+
+// DB is the connection details of the database in the container
+type DB struct {
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+}
+
+// BootstrapFunc creates the database objects used by the tests in
+// the database d, once it accepts connections
+type BootstrapFunc func(ctx context.Context, d DB) error
+
+var (
+	once     sync.Once
+	started  DB
+	startErr error
+)
+
+// Start returns the database in the container, starting it and
+// running bootstrap against it on the first call. The container is
+// shared by the tests of the test binary and removed by the
+// testcontainers reaper once the binary exits. The image is
+// postgres:13-alpine unless DB_CONTAINER_IMAGE is set.
+func Start(bootstrap BootstrapFunc) (DB, error) {
+	once.Do(func() {
+		started, startErr = start(bootstrap)
+		if startErr != nil {
+			fmt.Fprintf(os.Stderr, "pgcontainer: %v\n", startErr)
+		}
+	})
+	return started, startErr
+}
+
+// start starts the container, waits for the database to accept
+// connections and bootstraps it
+func start(bootstrap BootstrapFunc) (DB, error) {
+	if os.Getenv(noDBEnv) == "true" {
+		return DB{}, errors.Wrap(ErrUnavailable, noDBEnv+" is true")
+	}
+	if err := dockerAvailable(); err != nil {
+		return DB{}, errors.Wrapf(ErrUnavailable, "Docker is not available: %v", err)
+	}
+
+	image := os.Getenv(imageEnv)
+	if image == "" {
+		image = defaultImage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	req := testcontainers.ContainerRequest{
+		Image: image,
+		Env: map[string]string{
+			"POSTGRES_DB":       dbName,
+			"POSTGRES_USER":     dbUser,
+			"POSTGRES_PASSWORD": dbPassword,
+		},
+		ExposedPorts: []string{"5432/tcp"},
+		// the database restarts once after it is initialized, but
+		// only accepts TCP connections after the restart
+		WaitingFor: wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(startTimeout),
+	}
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{ContainerRequest: req, Started: true})
+	if err != nil {
+		return DB{}, errors.Wrapf(err, "start %s container", image)
+	}
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return DB{}, errors.Wrap(err, "container host")
+	}
+	port, err := c.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return DB{}, errors.Wrap(err, "container port")
+	}
+
+	d := DB{Host: host, Port: port.Int(), Name: dbName, User: dbUser, Password: dbPassword}
+
+	if err = bootstrap(ctx, d); err != nil {
+		return DB{}, errors.Wrap(err, "bootstrap container database")
+	}
+
+	return d, nil
+}
+
+// dockerAvailable returns an error if the Docker daemon does not
+// answer
+func dockerAvailable() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerCheckTimeout)
+	defer cancel()
+
+	p, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	return p.Health(ctx)
+}
@@ -0,0 +1,242 @@
+// Package requestinfo has typed accessors for the information about a
+// request held in its context: the request ID, the client, the
+// tenant, the access token, the user, the metadata of the route,
+// whether the request is a dry run and its consistency tokens.
+// Every value is set and read through this package, using keys of an
+// unexported type, so the keys cannot collide and the type of a
+// value cannot be mistaken. The user is the exception: it is kept
+// by the user package (see user.CtxWithUser), which this package
+// imports, and WithUser and UserFromContext call it.
+package requestinfo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// key is the type of the keys of the values set by this package
+type key int
+
+const (
+	keyAccessToken key = iota
+	keyRouteMeta
+	keyClient
+	keyTenant
+	keyDryRun
+	keyConsistency
+	keyPrimary
+)
+
+// RequestIDFromContext returns the ID of the request, set to the
+// request context by the logger middleware (see
+// hlog.RequestIDHandler). The boolean is false if no ID has been set.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := hlog.IDFromCtx(ctx)
+	if !ok {
+		return "", false
+	}
+	return id.String(), true
+}
+
+// Client is the client which made a request, as seen through any
+// trusted proxies
+type Client struct {
+	// IP is the IP address of the client
+	IP string
+	// Scheme is the scheme the client used to make the request,
+	// http or https
+	Scheme string
+	// UserAgent is the User-Agent header of the request
+	UserAgent string
+	// Version is the release of the client app, as given in the
+	// X-Client-Version header, if any
+	Version string
+}
+
+// WithClient returns a copy of ctx with c set as the client which
+// made the request
+func WithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, keyClient, c)
+}
+
+// ClientFromContext returns the client set to ctx using WithClient.
+// The boolean is false if no client has been set.
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	c, ok := ctx.Value(keyClient).(Client)
+	return c, ok
+}
+
+// WithTenant returns a copy of ctx with tenant set as the tenant the
+// request is made for
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, keyTenant, tenant)
+}
+
+// TenantFromContext returns the tenant set to ctx using WithTenant.
+// The boolean is false if no tenant has been set, in which case the
+// request is not made for a tenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(keyTenant).(string)
+	return t, ok
+}
+
+// WithDryRun returns a copy of ctx marking the request as a dry run:
+// its writes are checked as usual, by the service and the database,
+// but never kept, as each transaction is rolled back rather than
+// committed
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyDryRun, true)
+}
+
+// DryRun reports whether ctx has been marked a dry run using
+// WithDryRun
+func DryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(keyDryRun).(bool)
+	return dryRun
+}
+
+// WithPrimary returns a copy of ctx whose reads are made in the
+// primary database, never a read replica, e.g. the reads a write
+// depends on, which must not see a replica lagging behind it
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keyPrimary, true)
+}
+
+// Primary reports whether the reads of ctx must be made in the
+// primary database, see WithPrimary
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(keyPrimary).(bool)
+	return primary
+}
+
+// Consistency holds the consistency tokens of a request, which let
+// a client read its own writes when reads are served by a read
+// replica. A token is the position in the write-ahead log of the
+// primary database (an LSN) after a write.
+type Consistency struct {
+	// ReadAfter is the token sent with the request: its reads must
+	// see the writes made up to the token
+	ReadAfter string
+
+	mu      sync.Mutex
+	written string
+}
+
+// SetWritten records token as the token of the last write of the
+// request
+func (c *Consistency) SetWritten(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = token
+}
+
+// Written returns the token of the last write of the request, empty
+// if it has made none
+func (c *Consistency) Written() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written
+}
+
+// WithConsistency returns a copy of ctx holding c, the consistency
+// tokens of the request
+func WithConsistency(ctx context.Context, c *Consistency) context.Context {
+	return context.WithValue(ctx, keyConsistency, c)
+}
+
+// ConsistencyFromContext returns the consistency tokens set to ctx
+// using WithConsistency. The boolean is false if none have been set,
+// in which case the tokens are not exchanged with the client.
+func ConsistencyFromContext(ctx context.Context) (*Consistency, bool) {
+	c, ok := ctx.Value(keyConsistency).(*Consistency)
+	return c, ok
+}
+
+// accessTokenContext is a context holding an access token. The
+// token is held in the context itself, so adding a token to a
+// context allocates once, instead of once for the context and once
+// for the token as with context.WithValue.
+type accessTokenContext struct {
+	context.Context
+	at auth.AccessToken
+}
+
+// Value returns a pointer to the access token for its key, which
+// does not allocate, or else the value of the parent context
+func (c *accessTokenContext) Value(k interface{}) interface{} {
+	if k == keyAccessToken {
+		return &c.at
+	}
+	return c.Context.Value(k)
+}
+
+// WithAccessToken returns a copy of ctx holding the access token at
+func WithAccessToken(ctx context.Context, at auth.AccessToken) context.Context {
+	return &accessTokenContext{Context: ctx, at: at}
+}
+
+// AccessTokenFromContext returns the access token set to ctx using
+// WithAccessToken. The boolean is false if no token has been set.
+func AccessTokenFromContext(ctx context.Context) (auth.AccessToken, bool) {
+	at, ok := ctx.Value(keyAccessToken).(*auth.AccessToken)
+	if !ok {
+		return auth.AccessToken{}, false
+	}
+	return *at, true
+}
+
+// WithUser returns a copy of ctx with u set as the user making the
+// request, the same as user.CtxWithUser
+func WithUser(ctx context.Context, u user.User) context.Context {
+	return user.CtxWithUser(ctx, u)
+}
+
+// UserFromContext returns the user set to ctx using WithUser or
+// user.CtxWithUser. The boolean is false if no user has been set,
+// e.g. the request has not been authenticated.
+func UserFromContext(ctx context.Context) (user.User, bool) {
+	return user.FromContext(ctx)
+}
+
+// RouteMeta is the metadata of a route, used to authorize and audit
+// its requests
+type RouteMeta struct {
+	// Scopes holds the scopes a user needs to call the route, each
+	// as resource:action, e.g. movies:write
+	Scopes []string
+	// Role is the role a user needs to call the route, if any. It is
+	// checked using the Authorizer with auth.RoleResource as the
+	// resource and the role as the action.
+	Role string
+	// Resource is the type of resource the route acts on, e.g.
+	// movies. It is the resource type of the route's audit events
+	// unless the service handling the request sets another.
+	Resource string
+	// AuditCategory groups the audit events of the route, e.g.
+	// catalog for changes to movies and people
+	AuditCategory string
+	// Public is whether the route can be called without an access
+	// token, as the anonymous user, when the API runs in public
+	// read mode. The anonymous user still needs to be authorized
+	// for the route's scopes.
+	Public bool
+}
+
+// WithRouteMeta returns a copy of ctx with meta set as the metadata
+// of the route matched for the request
+func WithRouteMeta(ctx context.Context, meta RouteMeta) context.Context {
+	return context.WithValue(ctx, keyRouteMeta, meta)
+}
+
+// RouteMetaFromContext returns the route metadata set to ctx using
+// WithRouteMeta. The boolean is false if none has been set, e.g. no
+// route was matched.
+func RouteMetaFromContext(ctx context.Context) (RouteMeta, bool) {
+	meta, ok := ctx.Value(keyRouteMeta).(RouteMeta)
+	return meta, ok
+}
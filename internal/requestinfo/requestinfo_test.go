@@ -0,0 +1,148 @@
+package requestinfo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+func TestRequestIDFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := RequestIDFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	var (
+		got   string
+		gotOK bool
+	)
+	h := hlog.RequestIDHandler("request_id", "Request-Id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, gotOK = RequestIDFromContext(r.Context())
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil))
+
+	c.Assert(gotOK, qt.IsTrue)
+	c.Assert(got, qt.Equals, rr.Header().Get("Request-Id"))
+}
+
+func TestClientFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := ClientFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	cl := Client{IP: "203.0.113.7", Scheme: "https"}
+	got, ok := ClientFromContext(WithClient(context.Background(), cl))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, cl)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := TenantFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	got, ok := TenantFromContext(WithTenant(context.Background(), "acme"))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, "acme")
+}
+
+func TestDryRun(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(DryRun(context.Background()), qt.IsFalse)
+	c.Assert(DryRun(WithDryRun(context.Background())), qt.IsTrue)
+}
+
+func TestPrimary(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(Primary(context.Background()), qt.IsFalse)
+	c.Assert(Primary(WithPrimary(context.Background())), qt.IsTrue)
+}
+
+func TestConsistencyFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := ConsistencyFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	cons := &Consistency{ReadAfter: "0/16B3748"}
+	got, ok := ConsistencyFromContext(WithConsistency(context.Background(), cons))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got.ReadAfter, qt.Equals, "0/16B3748")
+	c.Assert(got.Written(), qt.Equals, "")
+
+	// the token written is seen through the context
+	cons.SetWritten("0/16B37F0")
+	c.Assert(got.Written(), qt.Equals, "0/16B37F0")
+}
+
+func TestAccessTokenFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := AccessTokenFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	type otherKey struct{}
+	ctx := context.WithValue(context.Background(), otherKey{}, "other")
+	at := auth.AccessToken{Token: "abcdef123", TokenType: auth.BearerTokenType}
+	ctx = WithAccessToken(ctx, at)
+
+	got, ok := AccessTokenFromContext(ctx)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, at)
+	// the values of the parent are kept
+	c.Assert(ctx.Value(otherKey{}), qt.Equals, "other")
+}
+
+func TestUserFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := UserFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	u := user.User{Email: "otto.maddox@helpinghandacceptanceco.com", FirstName: "Otto", LastName: "Maddox"}
+	got, ok := UserFromContext(WithUser(context.Background(), u))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, u)
+
+	// the user package sets and reads the same user
+	got, ok = user.FromContext(WithUser(context.Background(), u))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, u)
+	got, ok = UserFromContext(user.CtxWithUser(context.Background(), u))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.Equals, u)
+}
+
+func TestRouteMetaFromContext(t *testing.T) {
+	c := qt.New(t)
+
+	_, ok := RouteMetaFromContext(context.Background())
+	c.Assert(ok, qt.IsFalse)
+
+	meta := RouteMeta{Scopes: []string{"movies:write"}, Resource: "movies", AuditCategory: "catalog"}
+	got, ok := RouteMetaFromContext(WithRouteMeta(context.Background(), meta))
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got, qt.DeepEquals, meta)
+}
+
+// the keys are distinct from keys of other types holding the same
+// value, e.g. another package's int key
+func TestKeysDoNotCollide(t *testing.T) {
+	c := qt.New(t)
+
+	ctx := context.WithValue(context.Background(), int(keyRouteMeta), "not route metadata")
+	_, ok := RouteMetaFromContext(ctx)
+	c.Assert(ok, qt.IsFalse)
+}
@@ -5,14 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/peterbourgon/ff/v3"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/backfill"
 	"github.com/gilcrest/go-api-basic/domain/errs"
-	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/gateway/errorgateway"
+	"github.com/gilcrest/go-api-basic/handler"
 )
 
 const (
@@ -29,60 +33,136 @@ func main() {
 }
 
 func run(args []string) error {
+	root := newRootCommand(args[0], os.Stdout)
 
-	flgs, err := newFlags(args)
-	if err != nil {
-		return err
-	}
-
-	// setup logger with appropriate defaults
-	lgr := logger.NewLogger(os.Stdout, true)
-
-	// determine logging level
-	loglevel := newLogLevel(flgs.loglvl)
-
-	// set global logging level based on flag input
-	zerolog.SetGlobalLevel(loglevel)
-	lgr.Info().Msgf("logging level set to %s", loglevel)
-
-	// set global logging time field format to Unix timestamp
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-
-	// validate port in acceptable range
-	err = portRange(flgs.port)
-	if err != nil {
-		lgr.Fatal().Err(err).Msg("portRange() error")
+	err := root.ParseAndRun(context.Background(), args[1:])
+	// the usage has already been printed when help is requested
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
 	}
 
-	//get struct holding PostgreSQL datasource name details
-	dsn := datastore.NewPGDatasourceName(flgs.dbhost, flgs.dbname, flgs.dbuser, flgs.dbpassword, flgs.dbport)
-
-	// initialize a non-nil, empty context
-	ctx := context.Background()
-
-	// newServer function returns a pointer to a gocloud server, a
-	// cleanup function and an error
-	srv, cleanup, err := newServer(ctx, lgr, dsn)
-	if err != nil {
-		lgr.Fatal().Err(err).Msg("Error returned from newServer")
-	}
-	defer cleanup()
-
-	// Listen and serve HTTP
-	lgr.Fatal().Err(srv.ListenAndServe(fmt.Sprintf(":%d", flgs.port))).Msg("Fatal Server Error")
-
-	return nil
+	return err
 }
 
 type flags struct {
+	// config is the path to a config file holding flags, one per line
+	// as the flag name followed by its value. The log level and
+	// request quotas are reloaded from it on SIGHUP
+	config string
+
 	// log-level flag allows for setting logging level, e.g. to run the server
 	// with level set to debug, it'd be: ./server -log-level=debug
 	// If not set, defaults to error
 	loglvl string
 
+	// logsql flag enables logging of SQL statements and their
+	// (redacted) bind parameters at debug level
+	logsql bool
+
+	// logasync flag writes logs from a background goroutine so
+	// logging never blocks, dropping lines if the buffer is full
+	logasync bool
+
+	// logbodyroutes is a comma separated list of the path templates
+	// of the routes whose request and response bodies are logged and
+	// logbodyheader logs the bodies of requests with the
+	// Debug-Log-Body header set to true
+	logbodyroutes string
+	logbodyheader bool
+
 	// port flag is what http.ListenAndServe will listen on. default is 8080 if not set
 	port int
 
+	// listenhost is the host (interface) the server listens on. The
+	// default, 0.0.0.0, listens on every IPv4 interface as required
+	// by platforms such as Cloud Run
+	listenhost string
+
+	// adminport is the port the admin server (metrics, profiling,
+	// configuration and log level) listens on, or 0 to not serve it,
+	// and adminlistenhost is the host it listens on. It has no
+	// authentication, so it listens on localhost by default.
+	adminport       int
+	adminlistenhost string
+
+	// tlscertfile and tlskeyfile are the files holding the TLS
+	// certificate and key the server is served with. The server is
+	// served without TLS if they are not set.
+	tlscertfile string
+	tlskeyfile  string
+
+	// http2 enables HTTP/2 when the server is served with TLS and
+	// h2c enables HTTP/2 without TLS (h2c) when it is not, e.g.
+	// behind a GCP load balancer speaking HTTP/2 to its backends
+	http2 bool
+	h2c   bool
+
+	// pathtrimtrailingslash, pathcollapseslashes and
+	// pathcaseinsensitive normalize request paths before routing by
+	// removing a trailing slash, collapsing duplicate slashes and
+	// matching the fixed segments of the route paths regardless of
+	// case
+	pathtrimtrailingslash bool
+	pathcollapseslashes   bool
+	pathcaseinsensitive   bool
+
+	// trustedproxies is a comma separated list of the CIDRs (or IP
+	// addresses) of the proxies trusted to set the X-Forwarded-For
+	// and X-Forwarded-Proto headers. The headers are ignored if it is
+	// empty
+	trustedproxies string
+
+	// publicread serves the reads of the movie and person catalog
+	// without an access token
+	publicread bool
+
+	// tokenaudiences is a comma separated list of the OAuth client IDs
+	// an access token must have been issued to, and tokenissuer the
+	// issuer the identity provider must have, checked at startup.
+	// Neither is checked if empty
+	tokenaudiences string
+	tokenissuer    string
+
+	// googleclientid and googleclientsecret are the API's OAuth
+	// client at Google, which the authorization codes and refresh
+	// tokens of integration sessions are issued to. Sessions cannot
+	// be created if either is empty
+	googleclientid     string
+	googleclientsecret string
+
+	// responsecache is where the responses of GET requests are
+	// cached, if anywhere: none, memory or redis. responsecachettl
+	// is how long they are cached and redisurl is the URL of the
+	// Redis server used by the redis response cache
+	responsecache    string
+	responsecachettl time.Duration
+	redisurl         string
+
+	// tenants is a comma separated list of the tenants requests can
+	// be made for and the names of their databases, as
+	// name=database. tenantdomains is a comma separated list of the
+	// Google Workspace domains of the users of each tenant, as
+	// domain=tenant. tenantmaxopenconns is the maximum number of
+	// open connections to the database of each tenant
+	tenants            string
+	tenantdomains      string
+	tenantmaxopenconns int
+
+	// exportbucket is the URL of the bucket the files of export jobs
+	// are written to, export jobs are not available if it is empty.
+	// exporturlexpiry is how long the download URL of a file works
+	exportbucket    string
+	exporturlexpiry time.Duration
+
+	// warmuptimeout is how long the warm-up hooks are given to run
+	// on startup, before the server listens. If zero, they are not
+	// run
+	warmuptimeout time.Duration
+
+	// shutdowntimeout is how long in-flight requests are given to
+	// finish once a SIGTERM is received
+	shutdowntimeout time.Duration
+
 	// dbhost is the database host
 	dbhost string
 
@@ -97,6 +177,277 @@ type flags struct {
 
 	// dbpassword is the database user's password
 	dbpassword string
+
+	// dbpasswordfile is the path to a file holding the database
+	// user's password. If set, the file is watched and credentials
+	// are rotated without a restart when the file changes or the
+	// process receives a SIGHUP
+	dbpasswordfile string
+
+	// dbapplicationname is the application_name of the database
+	// connections, as shown in pg_stat_activity
+	dbapplicationname string
+
+	// dbcommentrequestid names each transaction begun for a request
+	// with the request ID, and prefixes each SQL statement which is
+	// not a cached prepared statement with a comment holding it, so
+	// statements in pg_stat_activity and the slow query log can be
+	// matched to requests
+	dbcommentrequestid bool
+
+	// dbstatementcache is how many prepared statements are cached per
+	// database connection, 0 to prepare none
+	dbstatementcache int
+
+	// dbmaxidleconns is the maximum number of idle connections kept
+	// in the database connection pool, also restored after idle
+	// connections are recycled for a credential rotation
+	dbmaxidleconns int
+
+	// dbreplicahost and dbreplicaport are the address of a read
+	// replica of the database, which the movie and person reads are
+	// served by. dbreplicawait is how long a read sent with a
+	// consistency token waits for the replica to catch up before it
+	// is sent to the primary
+	dbreplicahost string
+	dbreplicaport int
+	dbreplicawait time.Duration
+
+	// dbconnectwait is how long to retry connecting to a database
+	// which is not available yet at startup
+	dbconnectwait time.Duration
+
+	// dbstatsinterval is how often database connection pool
+	// statistics are recorded as metrics
+	dbstatsinterval time.Duration
+
+	// dbwaitthreshold is the time spent waiting for a database
+	// connection within a stats interval that triggers a warning
+	dbwaitthreshold time.Duration
+
+	// dbprobeinterval is how often the database is probed by its
+	// circuit breaker, which opens after dbbreakerthreshold
+	// consecutive probes fail (or time out after dbprobetimeout).
+	// While it is open, or the pool is exhausted (see
+	// dbwaitthreshold), requests are sent a 503
+	dbprobeinterval    time.Duration
+	dbprobetimeout     time.Duration
+	dbbreakerthreshold int
+
+	// moviecachemaxage is the Cache-Control max-age for a single
+	// movie response. If zero, clients must revalidate (no-cache)
+	moviecachemaxage time.Duration
+
+	// moviescachemaxage is the Cache-Control max-age for the list
+	// of movies response. If zero, clients must revalidate (no-cache)
+	moviescachemaxage time.Duration
+
+	// pagesizedefault is the number of items in a page of a list if
+	// the page_size query parameter is not given, pagesizemax is the
+	// largest page_size allowed and exportmaxrows is the most items
+	// an export can hold. pagelimits overrides them for routes, as a
+	// comma separated list of path=default:max:export
+	pagesizedefault int
+	pagesizemax     int
+	exportmaxrows   int
+	pagelimits      string
+
+	// quotadaily is the maximum number of requests a user can make
+	// per day (UTC). If zero, there is no daily quota
+	quotadaily int64
+
+	// quotamonthly is the maximum number of requests a user can
+	// make per month (UTC). If zero, there is no monthly quota
+	quotamonthly int64
+
+	// strictjson rejects JSON request bodies which have fields
+	// that are not part of the request instead of ignoring them
+	strictjson bool
+
+	// jsonmaxarraylen is the most elements an array in a JSON
+	// request body can have and jsonmaxdepth is the deepest its
+	// arrays and objects can nest. A body exceeding either is
+	// rejected with a 422 before it is decoded
+	jsonmaxarraylen int
+	jsonmaxdepth    int
+
+	// maxbodybytes is the largest request body accepted, in bytes.
+	// A larger body is rejected with a 413
+	maxbodybytes int64
+
+	// jsonfieldnaming is the naming of JSON response body fields,
+	// snake or camel, unless a request asks for one using a vendor
+	// media type
+	jsonfieldnaming string
+
+	// noenvelope writes the data of a response as the response body,
+	// without the standard response fields around it
+	noenvelope bool
+
+	// envelopefields is a comma separated list of field=name pairs
+	// renaming the fields of the response envelope, or leaving them
+	// out with a name of -
+	envelopefields string
+
+	// ratings is a comma separated list of the ratings a movie can
+	// be rated. If empty, the MPAA ratings are accepted
+	ratings string
+
+	// minreleaseyear is the earliest year a movie can be released
+	minreleaseyear int
+
+	// maxyearsahead is how many years in the future a movie can be
+	// released
+	maxyearsahead int
+
+	// minruntime and maxruntime are the range of movie run times in
+	// minutes
+	minruntime int
+	maxruntime int
+
+	// auditsinks is a comma separated list of the sinks audit
+	// events are sent to: db and/or file:<path>
+	auditsinks string
+
+	// supporturl is the documentation or support URL sent with
+	// error responses and errorurls is a comma separated list of
+	// code=url pairs overriding it for the given error codes (or
+	// kinds)
+	supporturl string
+	errorurls  string
+
+	// errorreporter is the service server errors are reported to:
+	// none, sentry or google, and sentrydsn is the DSN of the Sentry
+	// project for the sentry reporter
+	errorreporter string
+	sentrydsn     string
+
+	// backfillbatchsize is the number of rows filled in each batch
+	// by the backfill command, backfillpause is the time waited
+	// between batches and backfillafter is the key of the last row
+	// already filled, to resume a backfill which was stopped
+	backfillbatchsize int
+	backfillpause     time.Duration
+	backfillafter     string
+
+	// piikey is the base64 encoded key the email and names of users
+	// are encrypted with in the database, and piikeyfile is the path
+	// to a file holding it, e.g. a Secret Manager secret mounted as a
+	// volume. PII is stored in plain text if neither is set.
+	piikey     string
+	piikeyfile string
+
+	// bootstrapdb creates any missing database objects (schema,
+	// tables, indexes and functions) on startup
+	bootstrapdb bool
+
+	// mock runs the server with the sample movies held in memory and
+	// no authentication instead of using the database, for local
+	// development and demos
+	mock bool
+}
+
+// newFlagSet returns a FlagSet named name holding the configuration
+// flags shared by all commands. The flag values are set to flgs when
+// the FlagSet is parsed.
+func newFlagSet(name string, flgs *flags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(&flgs.config, "config", "", "config file of flags, one \"name value\" per line, reloaded on SIGHUP (also via CONFIG)")
+	fs.StringVar(&flgs.loglvl, "log-level", "info", "sets log level (debug, warn, error, fatal, panic, disabled), (also via LOG_LEVEL)")
+	fs.BoolVar(&flgs.logsql, "log-sql", false, "log sql statements and redacted bind parameters at debug level (also via LOG_SQL)")
+	fs.BoolVar(&flgs.logasync, "log-async", false, "write logs from a background goroutine, dropping lines rather than blocking when the buffer is full (also via LOG_ASYNC)")
+	fs.StringVar(&flgs.logbodyroutes, "log-body-routes", "", "comma separated path templates of the routes whose redacted request and response bodies are logged, * for all (also via LOG_BODY_ROUTES)")
+	fs.BoolVar(&flgs.logbodyheader, "log-body-header", false, "log the redacted request and response bodies of requests with the Debug-Log-Body header set to true (also via LOG_BODY_HEADER)")
+	fs.IntVar(&flgs.port, "port", 8080, "listen port for server (also via PORT)")
+	fs.StringVar(&flgs.listenhost, "listen-host", "0.0.0.0", "host (interface) the server listens on (also via LISTEN_HOST)")
+	fs.IntVar(&flgs.adminport, "admin-port", 0, "listen port for the admin server (metrics, pprof, config, log level and health), 0 does not serve it (also via ADMIN_PORT)")
+	fs.StringVar(&flgs.adminlistenhost, "admin-listen-host", "127.0.0.1", "host (interface) the admin server listens on, it has no authentication (also via ADMIN_LISTEN_HOST)")
+	fs.StringVar(&flgs.tlscertfile, "tls-cert-file", "", "file holding the TLS certificate to serve with, unset serves without TLS (also via TLS_CERT_FILE)")
+	fs.StringVar(&flgs.tlskeyfile, "tls-key-file", "", "file holding the TLS private key to serve with (also via TLS_KEY_FILE)")
+	fs.BoolVar(&flgs.http2, "http2", true, "enable HTTP/2 when serving with TLS (also via HTTP2)")
+	fs.BoolVar(&flgs.h2c, "h2c", false, "enable HTTP/2 without TLS (h2c) when serving without TLS, e.g. behind a load balancer speaking HTTP/2 to backends (also via H2C)")
+	fs.BoolVar(&flgs.pathtrimtrailingslash, "path-trim-trailing-slash", true, "route request paths without their trailing slash, e.g. /api/v1/movies/ as /api/v1/movies (also via PATH_TRIM_TRAILING_SLASH)")
+	fs.BoolVar(&flgs.pathcollapseslashes, "path-collapse-slashes", true, "route request paths with duplicate slashes collapsed, e.g. /api//v1/movies as /api/v1/movies (also via PATH_COLLAPSE_SLASHES)")
+	fs.BoolVar(&flgs.pathcaseinsensitive, "path-case-insensitive", false, "match the fixed segments of request paths regardless of case, e.g. /API/V1/Movies as /api/v1/movies (also via PATH_CASE_INSENSITIVE)")
+	fs.StringVar(&flgs.trustedproxies, "trusted-proxies", "", "comma separated CIDRs or IPs of the proxies trusted to set X-Forwarded-For and X-Forwarded-Proto, unset ignores them (also via TRUSTED_PROXIES)")
+	fs.BoolVar(&flgs.publicread, "public-read", false, "serve the GET requests of movies and people without an access token, writes still need one (also via PUBLIC_READ)")
+	fs.StringVar(&flgs.tokenaudiences, "token-audiences", "", "comma separated OAuth client IDs an access token must have been issued to, unset accepts any (also via TOKEN_AUDIENCES)")
+	fs.StringVar(&flgs.tokenissuer, "token-issuer", "", "issuer the identity provider must have, e.g. https://accounts.google.com, checked at startup, unset accepts any (also via TOKEN_ISSUER)")
+	fs.StringVar(&flgs.googleclientid, "google-client-id", "", "OAuth client ID of the API at Google, which integration session refresh tokens are issued to (also via GOOGLE_CLIENT_ID)")
+	fs.StringVar(&flgs.googleclientsecret, "google-client-secret", "", "OAuth client secret of the API at Google, unset disables integration sessions (also via GOOGLE_CLIENT_SECRET)")
+	fs.StringVar(&flgs.responsecache, "response-cache", "none", "where the responses of GET requests are cached: none, memory (each server on its own) or redis (also via RESPONSE_CACHE)")
+	fs.DurationVar(&flgs.responsecachettl, "response-cache-ttl", handler.DefaultResponseCacheTTL, "how long a response is cached, writes to its resource invalidate it sooner (also via RESPONSE_CACHE_TTL)")
+	fs.StringVar(&flgs.redisurl, "redis-url", "", "URL of the Redis server of the redis response cache, e.g. redis://:password@localhost:6379/0 (also via REDIS_URL)")
+	fs.StringVar(&flgs.tenants, "tenants", "", "comma separated tenants and their databases on the db-host server, as name=database, unset serves no tenants (also via TENANTS)")
+	fs.StringVar(&flgs.tenantdomains, "tenant-domains", "", "comma separated google workspace domains of the users of each tenant, as domain=tenant, users of any other domain use the default database (also via TENANT_DOMAINS)")
+	fs.IntVar(&flgs.tenantmaxopenconns, "tenant-max-open-conns", 10, "maximum open connections to the database of each tenant (also via TENANT_MAX_OPEN_CONNS)")
+	fs.StringVar(&flgs.exportbucket, "export-bucket", "", "URL of the bucket export job files are written to, e.g. gs://my-exports or file:///var/exports?base_url=...&secret_key_path=..., unset disables export jobs (also via EXPORT_BUCKET)")
+	fs.DurationVar(&flgs.exporturlexpiry, "export-url-expiry", 15*time.Minute, "how long the download URL of an export job file works (also via EXPORT_URL_EXPIRY)")
+	fs.DurationVar(&flgs.warmuptimeout, "warmup-timeout", 10*time.Second, "time given to the warm-up hooks (database connection, first page of movies) on startup, 0 skips them (also via WARMUP_TIMEOUT)")
+	fs.DurationVar(&flgs.shutdowntimeout, "shutdown-timeout", 8*time.Second, "time given to in-flight requests to finish after a SIGTERM (also via SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&flgs.dbhost, "db-host", "", "postgresql database host (also via DB_HOST)")
+	fs.IntVar(&flgs.dbport, "db-port", 5432, "postgresql database port (also via DB_PORT)")
+	fs.StringVar(&flgs.dbname, "db-name", "", "postgresql database name (also via DB_NAME)")
+	fs.StringVar(&flgs.dbuser, "db-user", "", "postgresql database user (also via DB_USER)")
+	fs.StringVar(&flgs.dbpassword, "db-password", "", "postgresql database password (also via DB_PASSWORD)")
+	fs.StringVar(&flgs.dbpasswordfile, "db-password-file", "", "file holding the postgresql database password, re-read on change or SIGHUP (also via DB_PASSWORD_FILE)")
+	fs.StringVar(&flgs.dbapplicationname, "db-application-name", "go-api-basic", "application_name of the postgresql connections, shown in pg_stat_activity (also via DB_APPLICATION_NAME)")
+	fs.BoolVar(&flgs.dbcommentrequestid, "db-comment-request-id", true, "set the request ID as the application_name of the transactions of a request, and comment its sql statements with it (also via DB_COMMENT_REQUEST_ID)")
+	fs.IntVar(&flgs.dbstatementcache, "db-statement-cache", 100, "how many prepared statements are cached per database connection, 0 to disable (also via DB_STATEMENT_CACHE)")
+	fs.IntVar(&flgs.dbmaxidleconns, "db-max-idle-conns", 2, "maximum idle connections kept in the database connection pool (also via DB_MAX_IDLE_CONNS)")
+	fs.StringVar(&flgs.dbreplicahost, "db-replica-host", "", "host of a read replica of the postgresql database serving the movie and person reads, with consistency tokens to read your writes, unset reads from the primary (also via DB_REPLICA_HOST)")
+	fs.IntVar(&flgs.dbreplicaport, "db-replica-port", 5432, "port of the read replica (also via DB_REPLICA_PORT)")
+	fs.DurationVar(&flgs.dbreplicawait, "db-replica-wait", 250*time.Millisecond, "how long a read sent with a consistency token waits for the replica to catch up before reading from the primary (also via DB_REPLICA_WAIT)")
+	fs.DurationVar(&flgs.dbconnectwait, "db-connect-wait", 30*time.Second, "how long to retry connecting to the database at startup, 0 fails on the first error (also via DB_CONNECT_WAIT)")
+	fs.DurationVar(&flgs.dbstatsinterval, "db-stats-interval", 15*time.Second, "how often database connection pool statistics are recorded, 0 disables (also via DB_STATS_INTERVAL)")
+	fs.DurationVar(&flgs.dbwaitthreshold, "db-wait-threshold", time.Second, "connection wait time per stats interval which logs a warning, 0 disables (also via DB_WAIT_THRESHOLD)")
+	fs.DurationVar(&flgs.dbprobeinterval, "db-probe-interval", 5*time.Second, "how often the database circuit breaker probes the database and checks the pool, also the Retry-After of its 503 responses, 0 disables (also via DB_PROBE_INTERVAL)")
+	fs.DurationVar(&flgs.dbprobetimeout, "db-probe-timeout", 2*time.Second, "time a database probe may take before it fails (also via DB_PROBE_TIMEOUT)")
+	fs.IntVar(&flgs.dbbreakerthreshold, "db-breaker-threshold", 3, "consecutive failed database probes which open the circuit breaker (also via DB_BREAKER_THRESHOLD)")
+	fs.DurationVar(&flgs.moviecachemaxage, "movie-cache-max-age", 0, "Cache-Control max-age for GET /movies/{id}, 0 requires revalidation (also via MOVIE_CACHE_MAX_AGE)")
+	fs.DurationVar(&flgs.moviescachemaxage, "movies-cache-max-age", 0, "Cache-Control max-age for GET /movies, 0 requires revalidation (also via MOVIES_CACHE_MAX_AGE)")
+	fs.IntVar(&flgs.pagesizedefault, "page-size-default", handler.DefaultPageLimits.DefaultSize, "items in a page of a list if page_size is not given (also via PAGE_SIZE_DEFAULT)")
+	fs.IntVar(&flgs.pagesizemax, "page-size-max", handler.DefaultPageLimits.MaxSize, "largest page_size of a list (also via PAGE_SIZE_MAX)")
+	fs.IntVar(&flgs.exportmaxrows, "export-max-rows", handler.DefaultPageLimits.MaxExportRows, "most items an export can hold (also via EXPORT_MAX_ROWS)")
+	fs.StringVar(&flgs.pagelimits, "page-limits", "", "comma separated page limits of routes overriding the above, as path=default:max:export with any left empty not overridden, e.g. /api/v1/movies=50:500: (also via PAGE_LIMITS)")
+	fs.Int64Var(&flgs.quotadaily, "quota-daily", 0, "maximum requests per user per day, 0 is unlimited (also via QUOTA_DAILY)")
+	fs.Int64Var(&flgs.quotamonthly, "quota-monthly", 0, "maximum requests per user per month, 0 is unlimited (also via QUOTA_MONTHLY)")
+	fs.StringVar(&flgs.auditsinks, "audit-sinks", "db", "comma separated sinks audit events are sent to, db and/or file:<path> (also via AUDIT_SINKS)")
+	fs.StringVar(&flgs.supporturl, "support-url", "", "documentation or support URL sent with error responses (also via SUPPORT_URL)")
+	fs.StringVar(&flgs.errorurls, "error-urls", "", "comma separated code=url pairs of the URL sent with errors of a code or kind, overriding support-url (also via ERROR_URLS)")
+	fs.StringVar(&flgs.errorreporter, "error-reporter", errorgateway.ReporterNone, "service server errors are reported to: none, sentry or google (also via ERROR_REPORTER)")
+	fs.StringVar(&flgs.sentrydsn, "sentry-dsn", "", "DSN of the Sentry project errors are reported to by the sentry error reporter (also via SENTRY_DSN)")
+	fs.IntVar(&flgs.backfillbatchsize, "backfill-batch-size", backfill.DefaultBatchSize, "rows filled in each batch by the backfill command (also via BACKFILL_BATCH_SIZE)")
+	fs.DurationVar(&flgs.backfillpause, "backfill-pause", backfill.DefaultPause, "time waited between batches by the backfill command, 0 does not wait (also via BACKFILL_PAUSE)")
+	fs.StringVar(&flgs.backfillafter, "backfill-after", "", "key of the last row already filled, to resume a stopped backfill (also via BACKFILL_AFTER)")
+	fs.StringVar(&flgs.piikey, "pii-key", "", "base64 encoded 32 byte key user emails and names are encrypted with in the database, unset stores them in plain text (also via PII_KEY)")
+	fs.StringVar(&flgs.piikeyfile, "pii-key-file", "", "file holding the pii-key, e.g. a mounted Secret Manager secret (also via PII_KEY_FILE)")
+	fs.BoolVar(&flgs.bootstrapdb, "bootstrap-db", false, "create any missing database objects on startup (also via BOOTSTRAP_DB)")
+	fs.BoolVar(&flgs.strictjson, "strict-json", false, "reject JSON request bodies with unknown fields (also via STRICT_JSON)")
+	fs.IntVar(&flgs.jsonmaxarraylen, "json-max-array-len", 1000, "most elements an array in a JSON request body can have, 0 is no limit (also via JSON_MAX_ARRAY_LEN)")
+	fs.IntVar(&flgs.jsonmaxdepth, "json-max-depth", 32, "deepest the arrays and objects of a JSON request body can nest, 0 is no limit (also via JSON_MAX_DEPTH)")
+	fs.Int64Var(&flgs.maxbodybytes, "max-body-bytes", 1<<20, "largest request body accepted in bytes, 0 is no limit (also via MAX_BODY_BYTES)")
+	fs.StringVar(&flgs.jsonfieldnaming, "json-field-naming", "snake", "naming of JSON response body fields, snake or camel (also via JSON_FIELD_NAMING)")
+	fs.BoolVar(&flgs.noenvelope, "no-envelope", false, "write bare resources as response bodies, without the path, request_id and meta fields (also via NO_ENVELOPE)")
+	fs.StringVar(&flgs.envelopefields, "envelope-fields", "", "comma separated field=name pairs renaming the path, request_id, data, meta and links fields of the response envelope, a name of - leaves the field out (also via ENVELOPE_FIELDS)")
+	fs.StringVar(&flgs.ratings, "ratings", "", "comma separated movie ratings accepted (default G,PG,PG-13,R,NC-17,NR) (also via RATINGS)")
+	fs.IntVar(&flgs.minreleaseyear, "min-release-year", movie.DefaultMinReleaseYear, "earliest year a movie can be released (also via MIN_RELEASE_YEAR)")
+	fs.IntVar(&flgs.maxyearsahead, "max-years-ahead", movie.DefaultMaxYearsAhead, "years in the future a movie can be released (also via MAX_YEARS_AHEAD)")
+	fs.IntVar(&flgs.minruntime, "min-run-time", movie.DefaultMinRunTime, "shortest movie run time in minutes (also via MIN_RUN_TIME)")
+	fs.IntVar(&flgs.maxruntime, "max-run-time", movie.DefaultMaxRunTime, "longest movie run time in minutes (also via MAX_RUN_TIME)")
+	fs.BoolVar(&flgs.mock, "mock", false, "serve sample data from memory without a database and accept any access token, for local development only (also via MOCK)")
+
+	return fs
+}
+
+// ffOptions are the options flags are parsed with: flags given on
+// the command line take precedence over environment variables, which
+// take precedence over the config file.
+var ffOptions = []ff.Option{
+	ff.WithEnvVarNoPrefix(),
+	ff.WithConfigFileFlag("config"),
+	ff.WithConfigFileParser(ff.PlainParser),
 }
 
 // newFlags parses the command line flags using ff and returns
@@ -104,33 +455,36 @@ type flags struct {
 func newFlags(args []string) (flgs flags, err error) {
 	// create new FlagSet using the program name being executed (args[0])
 	// as the name of the FlagSet
-	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
-
-	var (
-		loglvl     = fs.String("log-level", "info", "sets log level (debug, warn, error, fatal, panic, disabled), (also via LOG_LEVEL)")
-		port       = fs.Int("port", 8080, "listen port for server (also via PORT)")
-		dbhost     = fs.String("db-host", "", "postgresql database host (also via DB_HOST)")
-		dbport     = fs.Int("db-port", 5432, "postgresql database port (also via DB_PORT)")
-		dbname     = fs.String("db-name", "", "postgresql database name (also via DB_NAME)")
-		dbuser     = fs.String("db-user", "", "postgresql database user (also via DB_USER)")
-		dbpassword = fs.String("db-password", "", "postgresql database password (also via DB_PASSWORD)")
-	)
+	var parsed flags
+	fs := newFlagSet(args[0], &parsed)
 
 	// Parse the command line flags from above
-	err = ff.Parse(fs, args[1:], ff.WithEnvVarNoPrefix())
+	err = ff.Parse(fs, args[1:], ffOptions...)
 	if err != nil {
 		return flgs, err
 	}
 
-	return flags{
-		loglvl:     *loglvl,
-		port:       *port,
-		dbhost:     *dbhost,
-		dbport:     *dbport,
-		dbname:     *dbname,
-		dbuser:     *dbuser,
-		dbpassword: *dbpassword,
-	}, nil
+	return parsed, nil
+}
+
+// bootstrapDB opens a separate connection to the database to create
+// any missing database objects using datastore.Bootstrap. The
+// connection is closed once complete.
+func bootstrapDB(ctx context.Context, dsn datastore.PGDatasourceName, lgr zerolog.Logger) error {
+	db, cleanup, err := datastore.NewDB(dsn, lgr)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	return datastore.Bootstrap(ctx, datastore.NewDefaultDatastore(db), lgr)
+}
+
+// onCloudRun reports whether the program is running on Cloud Run,
+// which sets the K_SERVICE environment variable to the name of the
+// service
+func onCloudRun() bool {
+	return os.Getenv("K_SERVICE") != ""
 }
 
 // newLogLevel sets up the logging level (e.g. Debug, Info, Error, etc.)
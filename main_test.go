@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/gilcrest/go-api-basic/datastore/backfill"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/handler"
 	"github.com/pkg/errors"
 
 	qt "github.com/frankban/quicktest"
@@ -76,13 +80,51 @@ func Test_newFlags(t *testing.T) {
 	a1 := args{args: []string{"server", "-log-level=debug", "-port=8080", "-db-host=localhost", "-db-port=5432", "-db-name=go_api_basic", "-db-user=postgres", "-db-password=sosecret"}}
 
 	f1 := flags{
-		loglvl:     "debug",
-		port:       8080,
-		dbhost:     "localhost",
-		dbport:     5432,
-		dbname:     "go_api_basic",
-		dbuser:     "postgres",
-		dbpassword: "sosecret",
+		loglvl:                "debug",
+		port:                  8080,
+		listenhost:            "0.0.0.0",
+		adminlistenhost:       "127.0.0.1",
+		http2:                 true,
+		pathtrimtrailingslash: true,
+		pathcollapseslashes:   true,
+		responsecache:         "none",
+		responsecachettl:      30 * time.Second,
+		tenantmaxopenconns:    10,
+		exporturlexpiry:       15 * time.Minute,
+		warmuptimeout:         10 * time.Second,
+		shutdowntimeout:       8 * time.Second,
+		dbhost:                "localhost",
+		dbport:                5432,
+		dbname:                "go_api_basic",
+		dbuser:                "postgres",
+		dbpassword:            "sosecret",
+		dbapplicationname:     "go-api-basic",
+		dbcommentrequestid:    true,
+		dbstatementcache:      100,
+		dbmaxidleconns:        2,
+		dbreplicaport:         5432,
+		dbreplicawait:         250 * time.Millisecond,
+		dbconnectwait:         30 * time.Second,
+		dbstatsinterval:       15 * time.Second,
+		dbwaitthreshold:       time.Second,
+		dbprobeinterval:       5 * time.Second,
+		dbprobetimeout:        2 * time.Second,
+		dbbreakerthreshold:    3,
+		pagesizedefault:       handler.DefaultPageLimits.DefaultSize,
+		pagesizemax:           handler.DefaultPageLimits.MaxSize,
+		exportmaxrows:         handler.DefaultPageLimits.MaxExportRows,
+		minreleaseyear:        movie.DefaultMinReleaseYear,
+		maxyearsahead:         movie.DefaultMaxYearsAhead,
+		minruntime:            movie.DefaultMinRunTime,
+		maxruntime:            movie.DefaultMaxRunTime,
+		jsonmaxarraylen:       1000,
+		jsonmaxdepth:          32,
+		maxbodybytes:          1 << 20,
+		jsonfieldnaming:       "snake",
+		auditsinks:            "db",
+		errorreporter:         "none",
+		backfillbatchsize:     backfill.DefaultBatchSize,
+		backfillpause:         backfill.DefaultPause,
 	}
 
 	type envLookup struct {
@@ -138,48 +180,138 @@ func Test_newFlags(t *testing.T) {
 	cleanup := func() {
 		if ogEnvs.logLevel.ok {
 			os.Setenv(loglevelEnv, ogEnvs.logLevel.value)
+		} else {
+			os.Unsetenv(loglevelEnv)
 		}
 		if ogEnvs.port.ok {
 			os.Setenv(portEnv, ogEnvs.port.value)
+		} else {
+			os.Unsetenv(portEnv)
 		}
 		if ogEnvs.dbhost.ok {
 			os.Setenv(dbHostEnv, ogEnvs.dbhost.value)
+		} else {
+			os.Unsetenv(dbHostEnv)
 		}
 		if ogEnvs.dbport.ok {
 			os.Setenv(dbPortEnv, ogEnvs.dbport.value)
+		} else {
+			os.Unsetenv(dbPortEnv)
 		}
 		if ogEnvs.dbname.ok {
 			os.Setenv(dbNameEnv, ogEnvs.dbname.value)
+		} else {
+			os.Unsetenv(dbNameEnv)
 		}
 		if ogEnvs.dbuser.ok {
 			os.Setenv(dbUserEnv, ogEnvs.dbuser.value)
+		} else {
+			os.Unsetenv(dbUserEnv)
 		}
 		if ogEnvs.dbpassword.ok {
 			os.Setenv(dbPasswordEnv, ogEnvs.dbpassword.value)
+		} else {
+			os.Unsetenv(dbPasswordEnv)
 		}
 	}
 	t.Cleanup(cleanup)
 
 	a2 := args{args: []string{"server"}}
 	f2 := flags{
-		loglvl:     "warn",
-		port:       8081,
-		dbhost:     "hostwiththemost",
-		dbport:     5150,
-		dbname:     "whatisinaname",
-		dbuser:     "usersarelosers",
-		dbpassword: "yeet",
+		loglvl:                "warn",
+		port:                  8081,
+		listenhost:            "0.0.0.0",
+		adminlistenhost:       "127.0.0.1",
+		http2:                 true,
+		pathtrimtrailingslash: true,
+		pathcollapseslashes:   true,
+		responsecache:         "none",
+		responsecachettl:      30 * time.Second,
+		tenantmaxopenconns:    10,
+		exporturlexpiry:       15 * time.Minute,
+		warmuptimeout:         10 * time.Second,
+		shutdowntimeout:       8 * time.Second,
+		dbhost:                "hostwiththemost",
+		dbport:                5150,
+		dbname:                "whatisinaname",
+		dbuser:                "usersarelosers",
+		dbpassword:            "yeet",
+		dbapplicationname:     "go-api-basic",
+		dbcommentrequestid:    true,
+		dbstatementcache:      100,
+		dbmaxidleconns:        2,
+		dbreplicaport:         5432,
+		dbreplicawait:         250 * time.Millisecond,
+		dbconnectwait:         30 * time.Second,
+		dbstatsinterval:       15 * time.Second,
+		dbwaitthreshold:       time.Second,
+		dbprobeinterval:       5 * time.Second,
+		dbprobetimeout:        2 * time.Second,
+		dbbreakerthreshold:    3,
+		pagesizedefault:       handler.DefaultPageLimits.DefaultSize,
+		pagesizemax:           handler.DefaultPageLimits.MaxSize,
+		exportmaxrows:         handler.DefaultPageLimits.MaxExportRows,
+		minreleaseyear:        movie.DefaultMinReleaseYear,
+		maxyearsahead:         movie.DefaultMaxYearsAhead,
+		minruntime:            movie.DefaultMinRunTime,
+		maxruntime:            movie.DefaultMaxRunTime,
+		jsonmaxarraylen:       1000,
+		jsonmaxdepth:          32,
+		maxbodybytes:          1 << 20,
+		jsonfieldnaming:       "snake",
+		auditsinks:            "db",
+		errorreporter:         "none",
+		backfillbatchsize:     backfill.DefaultBatchSize,
+		backfillpause:         backfill.DefaultPause,
 	}
 
 	a3 := args{args: []string{"server", "-log-level=error"}}
 	f3 := flags{
-		loglvl:     "error",
-		port:       8081,
-		dbhost:     "hostwiththemost",
-		dbport:     5150,
-		dbname:     "whatisinaname",
-		dbuser:     "usersarelosers",
-		dbpassword: "yeet",
+		loglvl:                "error",
+		port:                  8081,
+		listenhost:            "0.0.0.0",
+		adminlistenhost:       "127.0.0.1",
+		http2:                 true,
+		pathtrimtrailingslash: true,
+		pathcollapseslashes:   true,
+		responsecache:         "none",
+		responsecachettl:      30 * time.Second,
+		tenantmaxopenconns:    10,
+		exporturlexpiry:       15 * time.Minute,
+		warmuptimeout:         10 * time.Second,
+		shutdowntimeout:       8 * time.Second,
+		dbhost:                "hostwiththemost",
+		dbport:                5150,
+		dbname:                "whatisinaname",
+		dbuser:                "usersarelosers",
+		dbpassword:            "yeet",
+		dbapplicationname:     "go-api-basic",
+		dbcommentrequestid:    true,
+		dbstatementcache:      100,
+		dbmaxidleconns:        2,
+		dbreplicaport:         5432,
+		dbreplicawait:         250 * time.Millisecond,
+		dbconnectwait:         30 * time.Second,
+		dbstatsinterval:       15 * time.Second,
+		dbwaitthreshold:       time.Second,
+		dbprobeinterval:       5 * time.Second,
+		dbprobetimeout:        2 * time.Second,
+		dbbreakerthreshold:    3,
+		pagesizedefault:       handler.DefaultPageLimits.DefaultSize,
+		pagesizemax:           handler.DefaultPageLimits.MaxSize,
+		exportmaxrows:         handler.DefaultPageLimits.MaxExportRows,
+		minreleaseyear:        movie.DefaultMinReleaseYear,
+		maxyearsahead:         movie.DefaultMaxYearsAhead,
+		minruntime:            movie.DefaultMinRunTime,
+		maxruntime:            movie.DefaultMaxRunTime,
+		jsonmaxarraylen:       1000,
+		jsonmaxdepth:          32,
+		maxbodybytes:          1 << 20,
+		jsonfieldnaming:       "snake",
+		auditsinks:            "db",
+		errorreporter:         "none",
+		backfillbatchsize:     backfill.DefaultBatchSize,
+		backfillpause:         backfill.DefaultPause,
 	}
 
 	a4 := args{args: []string{"server", "-badflag=true"}}
@@ -217,7 +349,10 @@ func Test_run(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{"version", args{args: []string{"server", "version"}}, false},
+		{"help", args{args: []string{"server", "-h"}}, false},
+		{"unknown argument", args{args: []string{"server", "version", "bogus"}}, true},
+		{"invalid flag", args{args: []string{"server", "routes", "-badflag=true"}}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
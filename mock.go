@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// mockUser is the user every access token is converted to when the
+// server is run with the -mock flag
+var mockUser = user.User{
+	Email:     "mock@go-api-basic.local",
+	FirstName: "Mock",
+	LastName:  "User",
+	FullName:  "Mock User",
+}
+
+// newMockAccessTokenConverter returns an AccessTokenConverter which
+// converts any access token to mockUser
+func newMockAccessTokenConverter() auth.StaticAccessTokenConverter {
+	return auth.StaticAccessTokenConverter{User: mockUser}
+}
+
+// newMockTokenRefresher returns a TokenRefresher which issues an
+// access token, accepted by the mock AccessTokenConverter, for any
+// integration session
+func newMockTokenRefresher() auth.StaticTokenRefresher {
+	return auth.StaticTokenRefresher{Token: "mock-access-token"}
+}
+
+// newMockMovieStore returns an in-memory movie store loaded with the
+// sample movies added by the seed command
+func newMockMovieStore() (*memstore.MovieStore, error) {
+	movies, err := newSeedMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	return memstore.NewMovieStore(movies...), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"gocloud.dev/server"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// protocolConfig sets the HTTP versions the server speaks
+type protocolConfig struct {
+	// HTTP2 enables HTTP/2 on the TLS listener, negotiated with the
+	// client using ALPN. HTTP/1.1 is always available.
+	HTTP2 bool
+	// H2C enables HTTP/2 without TLS (h2c) on the plaintext listener,
+	// for use behind a load balancer which terminates TLS and speaks
+	// HTTP/2 to its backends, such as a GCP load balancer or Cloud
+	// Run with end-to-end HTTP/2. HTTP/1.1 is always available.
+	H2C bool
+}
+
+// newServerDriver returns the driver the server listens with: a
+// server.DefaultDriver speaking the HTTP versions of cfg
+func newServerDriver(cfg protocolConfig) *protocolDriver {
+	d := &protocolDriver{DefaultDriver: server.NewDefaultDriver()}
+
+	if cfg.H2C {
+		// configuring the server lets Shutdown gracefully close the
+		// HTTP/2 connections served by h2c too
+		d.h2s = &http2.Server{}
+		// ConfigureServer only fails when TLSConfig is set with
+		// cipher suites HTTP/2 does not allow, and it is not set
+		_ = http2.ConfigureServer(&d.Server, d.h2s)
+	}
+
+	if !cfg.HTTP2 {
+		// a non-nil, empty TLSNextProto disables HTTP/2 over TLS
+		d.Server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	return d
+}
+
+// protocolDriver is a server.DefaultDriver which also serves h2c on
+// the plaintext listener, if enabled. It satisfies the driver.Server
+// and driver.TLSServer interfaces.
+type protocolDriver struct {
+	*server.DefaultDriver
+	// h2s serves h2c connections, or is nil if h2c is disabled
+	h2s *http2.Server
+}
+
+// ListenAndServe serves h on addr without TLS, using HTTP/1.1 and,
+// if enabled, h2c
+func (d *protocolDriver) ListenAndServe(addr string, h http.Handler) error {
+	return d.DefaultDriver.ListenAndServe(addr, d.handler(h))
+}
+
+// handler returns h, wrapped to serve h2c requests if h2c is enabled
+func (d *protocolDriver) handler(h http.Handler) http.Handler {
+	if d.h2s == nil {
+		return h
+	}
+	return h2c.NewHandler(h, d.h2s)
+}
+
+// tlsServer serves a server.Server using TLS with the certificate
+// and key in the given files. It satisfies the httpServer interface
+// used by listenAndServe.
+type tlsServer struct {
+	*server.Server
+	certFile string
+	keyFile  string
+}
+
+// ListenAndServe serves on addr using TLS
+func (s tlsServer) ListenAndServe(addr string) error {
+	return s.Server.ListenAndServeTLS(addr, s.certFile, s.keyFile)
+}
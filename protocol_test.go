@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"golang.org/x/net/http2"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and
+// its key to files in a temporary directory
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	for f, b := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err = ioutil.WriteFile(f, pem.EncodeToMemory(b), 0600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	return certFile, keyFile
+}
+
+// protoHandler responds with the protocol of the request
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(r.Proto))
+})
+
+// serveDriver serves protoHandler with the driver on a random port,
+// using TLS if certFile is set, and returns the address served on
+func serveDriver(t *testing.T, d *protocolDriver, certFile, keyFile string) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	// as by the driver's ListenAndServe and ListenAndServeTLS, which
+	// cannot be given a listener
+	d.Server.Handler = protoHandler
+	go func() {
+		if certFile != "" {
+			_ = d.Server.ServeTLS(ln, certFile, keyFile)
+			return
+		}
+		d.Server.Handler = d.handler(protoHandler)
+		_ = d.Server.Serve(ln)
+	}()
+	t.Cleanup(func() { _ = d.Server.Close() })
+
+	return ln.Addr().String()
+}
+
+// get returns the body of a GET request for url using client
+func get(c *qt.C, client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, qt.IsNil)
+	return string(b), nil
+}
+
+func Test_newServerDriver_TLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name      string
+		cfg       protocolConfig
+		wantProto string
+	}{
+		{"http2", protocolConfig{HTTP2: true}, "HTTP/2.0"},
+		{"http2 disabled", protocolConfig{}, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			addr := serveDriver(t, newServerDriver(tt.cfg), certFile, keyFile)
+
+			// the client offers HTTP/2 and HTTP/1.1, and the server
+			// chooses
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				ForceAttemptHTTP2: true,
+			}}
+			got, err := get(c, client, "https://"+addr+"/")
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, tt.wantProto)
+		})
+	}
+}
+
+func Test_newServerDriver_h2c(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     protocolConfig
+		wantH2C bool
+	}{
+		{"h2c", protocolConfig{H2C: true}, true},
+		{"h2c disabled", protocolConfig{HTTP2: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			addr := serveDriver(t, newServerDriver(tt.cfg), "", "")
+
+			// HTTP/1.1 is always served
+			got, err := get(c, http.DefaultClient, "http://"+addr+"/")
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, "HTTP/1.1")
+
+			// an HTTP/2 client with prior knowledge, as a load
+			// balancer speaking HTTP/2 to its backends
+			h2cClient := &http.Client{Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+					return net.Dial(network, addr)
+				},
+			}}
+			got, err = get(c, h2cClient, "http://"+addr+"/")
+			if !tt.wantH2C {
+				c.Assert(err, qt.Not(qt.IsNil))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.Equals, "HTTP/2.0")
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/peterbourgon/ff/v3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+// reloadable are the settings which are reloaded on SIGHUP, without
+// restarting the server
+type reloadable struct {
+	loglvl string
+	limits quota.Limits
+}
+
+// newReloadable returns the reloadable settings of flgs
+func newReloadable(flgs flags) reloadable {
+	return reloadable{
+		loglvl: flgs.loglvl,
+		limits: quota.Limits{
+			Daily:   flgs.quotadaily,
+			Monthly: flgs.quotamonthly,
+		},
+	}
+}
+
+// loadReloadable reads the reloadable settings from the environment
+// and the config file, leaving out the command line
+func loadReloadable(config string) (reloadable, error) {
+	var flgs flags
+	fs := newFlagSet("reload", &flgs)
+
+	var args []string
+	if config != "" {
+		args = []string{"-config", config}
+	}
+	if err := ff.Parse(fs, args, ffOptions...); err != nil {
+		return reloadable{}, errors.Wrap(err, "reloading configuration")
+	}
+
+	return newReloadable(flgs), nil
+}
+
+// newReloader is an initializer for reloader. The settings are read
+// from the config file (and environment) once, so changes to them
+// are known on reload.
+func newReloader(flgs flags, limits *quota.ReloadableLimits, lgr zerolog.Logger) (*reloader, error) {
+	loaded, err := loadReloadable(flgs.config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reloader{
+		config: flgs.config,
+		loaded: loaded,
+		limits: limits,
+		logger: lgr,
+	}, nil
+}
+
+// reloader reloads the log level and request quotas from the config
+// file. Only settings changed since they were last read are applied,
+// so a setting given on the command line is kept until it is changed
+// in the config file.
+type reloader struct {
+	config string
+	// loaded are the settings last read
+	loaded reloadable
+	limits *quota.ReloadableLimits
+	logger zerolog.Logger
+}
+
+// reload reads the config file and applies the settings changed in it
+func (r *reloader) reload() error {
+	next, err := loadReloadable(r.config)
+	if err != nil {
+		return err
+	}
+
+	if next.loglvl != r.loaded.loglvl {
+		zerolog.SetGlobalLevel(newLogLevel(next.loglvl))
+		r.logger.Info().Msgf("logging level reloaded, set to %s", zerolog.GlobalLevel())
+	}
+	if next.limits != r.loaded.limits {
+		r.limits.Store(next.limits)
+		r.logger.Info().Msgf("request quotas reloaded, set to %d daily and %d monthly", next.limits.Daily, next.limits.Monthly)
+	}
+	r.loaded = next
+
+	return nil
+}
+
+// watch reloads the settings each time a signal is received on sigs,
+// until ctx is done. A config file which cannot be read is logged
+// and the current settings are kept.
+func (r *reloader) watch(ctx context.Context, sigs <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+			if err := r.reload(); err != nil {
+				r.logger.Error().Err(err).Msg("configuration reload failed, keeping current settings")
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/quota"
+)
+
+func Test_reloader_reload(t *testing.T) {
+	c := qt.New(t)
+
+	lvl := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(lvl) })
+
+	config := filepath.Join(t.TempDir(), "config")
+	writeConfig := func(s string) {
+		c.Assert(os.WriteFile(config, []byte(s), 0600), qt.IsNil)
+	}
+	writeConfig("log-level warn\nquota-daily 5\n")
+
+	// the log level is given on the command line, overriding the
+	// config file
+	flgs := flags{config: config, loglvl: "debug", quotadaily: 5}
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	limits := quota.NewReloadableLimits(quota.Limits{Daily: 5})
+
+	rl, err := newReloader(flgs, limits, zerolog.Nop())
+	c.Assert(err, qt.IsNil)
+
+	// only the changed quota is applied
+	writeConfig("log-level warn\nquota-daily 10\nquota-monthly 100\n")
+	c.Assert(rl.reload(), qt.IsNil)
+	c.Assert(limits.Load(), qt.Equals, quota.Limits{Daily: 10, Monthly: 100})
+	c.Assert(zerolog.GlobalLevel(), qt.Equals, zerolog.DebugLevel)
+
+	// changing the log level in the config file applies it
+	writeConfig("log-level error\nquota-daily 10\nquota-monthly 100\n")
+	c.Assert(rl.reload(), qt.IsNil)
+	c.Assert(zerolog.GlobalLevel(), qt.Equals, zerolog.ErrorLevel)
+
+	// a config file which cannot be parsed keeps the settings
+	writeConfig("no-such-flag 1\n")
+	c.Assert(rl.reload(), qt.Not(qt.IsNil))
+	c.Assert(limits.Load(), qt.Equals, quota.Limits{Daily: 10, Monthly: 100})
+	c.Assert(zerolog.GlobalLevel(), qt.Equals, zerolog.ErrorLevel)
+}
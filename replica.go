@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// replicaConfig sets the read replica of the default database, if
+// there is one
+type replicaConfig struct {
+	// Host and Port are the address of the replica, which has the
+	// database name and credentials of the primary. No Host means
+	// there is no replica.
+	Host string
+	Port int
+	// Wait is how long a read sent with a consistency token is held
+	// for the replica to replay the writes of the token, before it
+	// is sent to the primary instead
+	Wait time.Duration
+}
+
+// newReplicaDatastore opens a pool of connections to the replica of
+// cfg, using dsn with the host and port replaced, and returns a
+// datastore.ReplicaDatastore beginning the reads in the replica and
+// the other transactions in ds. Without a replica, every
+// transaction is begun in ds. The returned function closes the pool.
+func newReplicaDatastore(ds datastore.TenantDatastore, dsn datastore.PGDatasourceName, cfg replicaConfig, logger zerolog.Logger) (datastore.ReplicaDatastore, func(), error) {
+	if cfg.Host == "" {
+		return datastore.NewReplicaDatastore(ds, nil, 0), func() {}, nil
+	}
+
+	rdsn := dsn
+	rdsn.Host = cfg.Host
+	rdsn.Port = cfg.Port
+	db, cleanup, err := datastore.NewDB(rdsn, logger.With().Str("db", "replica").Logger())
+	if err != nil {
+		return datastore.ReplicaDatastore{}, func() {}, err
+	}
+	logger.Info().Msgf("movie and person reads are served by the replica on %s, waiting up to %s for consistency tokens", cfg.Host, cfg.Wait)
+
+	return datastore.NewReplicaDatastore(ds, db, cfg.Wait), cleanup, nil
+}
+
+// newConsistencyTokens exchanges consistency tokens with clients
+// when there is a replica to read from
+func newConsistencyTokens(cfg replicaConfig) handler.ConsistencyTokens {
+	return cfg.Host != ""
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/quota"
+	"github.com/gilcrest/go-api-basic/gateway/redisgateway"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// responseCacheConfig sets where responses are cached, if anywhere,
+// and for how long
+type responseCacheConfig struct {
+	// Store is where responses are cached: none (the default), memory
+	// or redis
+	Store string
+	// TTL is how long a response is cached
+	TTL time.Duration
+	// RedisURL is the URL of the Redis server, if Store is redis
+	RedisURL string
+}
+
+// newResponseCache returns the handler.ResponseCache configured by
+// cfg, or nil if responses are not cached. The request quota of a
+// user is tracked using t for the responses served from the cache.
+// The cache is invalidated by the changes published on events.
+func newResponseCache(ctx context.Context, cfg responseCacheConfig, t quota.Tracker, events event.Subscriber) (*handler.ResponseCache, func(), error) {
+	switch cfg.Store {
+	case "", "none":
+		return nil, func() {}, nil
+	case "memory":
+		rc := handler.NewResponseCache(memstore.NewResponseStore(0), cfg.TTL, t)
+		return rc, rc.Subscribe(events), nil
+	case "redis":
+		s, cleanup, err := redisgateway.NewStore(ctx, redisgateway.Config{URL: cfg.RedisURL})
+		if err != nil {
+			return nil, cleanup, err
+		}
+		rc := handler.NewResponseCache(s, cfg.TTL, t)
+		unsubscribe := rc.Subscribe(events)
+		return rc, func() {
+			unsubscribe()
+			cleanup()
+		}, nil
+	}
+	return nil, func() {}, errs.E(errs.Validation, errs.Parameter("response-cache"), errors.Errorf("unknown response cache %q, must be none, memory or redis", cfg.Store))
+}
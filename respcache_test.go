@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/event"
+)
+
+func Test_newResponseCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         responseCacheConfig
+		wantEnabled bool
+		wantErr     bool
+	}{
+		{"none", responseCacheConfig{Store: "none"}, false, false},
+		{"empty", responseCacheConfig{}, false, false},
+		{"memory", responseCacheConfig{Store: "memory", TTL: time.Minute}, true, false},
+		{"redis url", responseCacheConfig{Store: "redis", RedisURL: "localhost:6379"}, false, true},
+		{"unknown", responseCacheConfig{Store: "memcached"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rc, cleanup, err := newResponseCache(context.Background(), tt.cfg, nil, event.NewInProcessBus())
+			defer cleanup()
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(rc.Enabled(), qt.Equals, tt.wantEnabled)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// statsRefresher refreshes the table statistics the estimates of the
+// API are read from, see moviestore.DefaultSelector.RefreshStats
+type statsRefresher interface {
+	RefreshStats(ctx context.Context) error
+}
+
+// resyncResources are the resources an event.ChangesMissed is
+// published for by the events resync step
+var resyncResources = []string{"movies", "people"}
+
+// newResyncSteps returns the steps of the admin resync endpoint:
+//
+//   - aggregates analyzes the movie table, so the estimated movie
+//     count is current (if sr is not nil, it is nil in mock mode)
+//   - response_cache deletes every cached response (if responses are
+//     cached by rc)
+//   - events publishes an event.ChangesMissed for each of the
+//     resyncResources on pub, so anything else derived from them
+//     and subscribed to the bus is rebuilt, as it is when the change
+//     notifications are lost
+//
+// The steps act on the instance of the server the request is sent
+// to, and on the shared Redis cache, if used.
+func newResyncSteps(sr statsRefresher, rc *handler.ResponseCache, pub event.Publisher) handler.ResyncSteps {
+	var steps handler.ResyncSteps
+	if sr != nil {
+		steps = append(steps, handler.ResyncStep{Name: "aggregates", Run: sr.RefreshStats})
+	}
+	if rc.Enabled() {
+		steps = append(steps, handler.ResyncStep{Name: "response_cache", Run: rc.Purge})
+	}
+	steps = append(steps, handler.ResyncStep{Name: "events", Run: func(ctx context.Context) error {
+		for _, res := range resyncResources {
+			pub.Publish(ctx, event.ChangesMissed{Resource: res})
+		}
+		return nil
+	}})
+
+	return steps
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// fakeStatsRefresher counts the calls to RefreshStats
+type fakeStatsRefresher struct {
+	calls int
+}
+
+func (f *fakeStatsRefresher) RefreshStats(ctx context.Context) error {
+	f.calls++
+	return nil
+}
+
+func Test_newResyncSteps(t *testing.T) {
+	names := func(steps handler.ResyncSteps) []string {
+		var ns []string
+		for _, s := range steps {
+			ns = append(ns, s.Name)
+		}
+		return ns
+	}
+
+	t.Run("mock mode without cache", func(t *testing.T) {
+		steps := newResyncSteps(nil, nil, event.NewInProcessBus())
+		qt.Assert(t, names(steps), qt.DeepEquals, []string{"events"})
+	})
+
+	t.Run("all steps", func(t *testing.T) {
+		c := qt.New(t)
+
+		ctx := context.Background()
+		sr := &fakeStatsRefresher{}
+		rc := handler.NewResponseCache(memstore.NewResponseStore(0), 0, nil)
+		bus := event.NewInProcessBus()
+		var missed []string
+		event.OnChangesMissed(bus, func(ctx context.Context, e event.ChangesMissed) {
+			missed = append(missed, e.Resource)
+		})
+
+		steps := newResyncSteps(sr, rc, bus)
+		c.Assert(names(steps), qt.DeepEquals, []string{"aggregates", "response_cache", "events"})
+		for _, s := range steps {
+			c.Assert(s.Run(ctx), qt.IsNil)
+		}
+		c.Assert(sr.calls, qt.Equals, 1)
+		c.Assert(missed, qt.DeepEquals, resyncResources)
+	})
+}
@@ -28,6 +28,40 @@ alter table demo.movie owner to postgres;
 create unique index movie_extl_id_uindex
     on demo.movie (extl_id);
 
+-- title suggestions match a prefix of the lower case title, which
+-- text_pattern_ops lets an index be used for whatever the collation
+create index movie_title_prefix_index
+    on demo.movie (lower(title) text_pattern_ops);
+
+create table demo.person
+(
+    person_id uuid not null
+        constraint person_pk
+            primary key,
+    extl_id varchar(250) not null,
+    name varchar(1000) not null,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+alter table demo.person owner to postgres;
+
+create unique index person_extl_id_uindex
+    on demo.person (extl_id);
+
+-- the person who directed the movie. The movie is kept when the
+-- person is deleted.
+alter table demo.movie
+    add director_id uuid
+        constraint movie_director_fk
+            references demo.person (person_id)
+            on delete set null;
+
+create index movie_director_id_index
+    on demo.movie (director_id);
+
 create function demo.create_movie(p_id uuid, p_extl_id character varying, p_title character varying, p_rated character varying, p_released date, p_run_time integer, p_director character varying, p_writer character varying, p_create_client_id uuid, p_create_username character varying)
     returns TABLE(o_create_timestamp timestamp without time zone, o_update_timestamp timestamp without time zone)
     language plpgsql
@@ -82,3 +116,95 @@ END;
 $$;
 
 alter function demo.create_movie(uuid, varchar, varchar, varchar, date, integer, varchar, varchar, uuid, varchar) owner to postgres;
+
+create table demo.user_request_count
+(
+    username varchar not null,
+    window_type varchar(10) not null,
+    window_start date not null,
+    request_count bigint not null,
+    constraint user_request_count_pk
+        primary key (username, window_type, window_start)
+);
+
+alter table demo.user_request_count owner to postgres;
+
+create table demo.api_audit
+(
+    audit_id bigserial not null
+        constraint api_audit_pk
+            primary key,
+    request_id varchar(100),
+    method varchar(10) not null,
+    path varchar(2000) not null,
+    username varchar,
+    status integer not null,
+    latency_us bigint not null,
+    request_timestamp timestamp with time zone not null
+);
+
+alter table demo.api_audit owner to postgres;
+
+create index api_audit_request_timestamp_index
+    on demo.api_audit (request_timestamp);
+
+alter table demo.movie
+    add search_vector tsvector;
+
+create index movie_search_vector_index
+    on demo.movie using gin (search_vector);
+
+create function demo.movie_search_vector(p_title character varying, p_director character varying, p_writer character varying)
+    returns tsvector
+    language sql
+    immutable
+as
+$$
+SELECT setweight(to_tsvector('pg_catalog.english', coalesce(p_title, '')), 'A') ||
+       to_tsvector('pg_catalog.english', coalesce(p_director, '') || ' ' || coalesce(p_writer, ''));
+$$;
+
+alter function demo.movie_search_vector(varchar, varchar, varchar) owner to postgres;
+
+create function demo.movie_search_vector_trigger()
+    returns trigger
+    language plpgsql
+as
+$$
+BEGIN
+    NEW.search_vector := demo.movie_search_vector(NEW.title, NEW.director, NEW.writer);
+    RETURN NEW;
+END;
+$$;
+
+alter function demo.movie_search_vector_trigger() owner to postgres;
+
+create trigger movie_search_vector_trigger
+    before insert or update of title, director, writer
+    on demo.movie
+    for each row
+execute procedure demo.movie_search_vector_trigger();
+
+create table demo.app_user
+(
+    user_id uuid not null
+        constraint app_user_pk
+            primary key,
+    email_hash varchar(64) not null,
+    email text not null,
+    first_name text,
+    last_name text,
+    full_name text,
+    subject varchar(255),
+    hosted_domain varchar(255),
+    create_timestamp timestamp with time zone not null,
+    update_timestamp timestamp with time zone not null
+);
+
+alter table demo.app_user owner to postgres;
+
+create unique index app_user_email_hash_uindex
+    on demo.app_user (email_hash);
+
+alter table demo.app_user
+    add erase_timestamp timestamp with time zone;
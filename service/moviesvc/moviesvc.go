@@ -0,0 +1,326 @@
+// Package moviesvc is the service layer for movies. It holds the
+// steps of each movie operation (authorization, ID generation,
+// domain logic and calls to the store) so the same business logic
+// backs every entry point (the HTTP handlers today, a gRPC server or
+// CLI command tomorrow). Entry points authenticate the user and
+// decode/encode their own formats, then call the Service.
+package moviesvc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/filter"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// resource is given to the Authorizer as the resource for movie
+// operations, with auth.ActionRead or auth.ActionWrite as the
+// action. It matches the movie routes' scopes (e.g. movies:write), so
+// the same permissions apply whichever entry point calls the Service.
+const resource string = "movies"
+
+// Service performs the operations for movies
+type Service struct {
+	Authorizer          auth.Authorizer
+	ExternalIDGenerator random.ExternalIDGenerator
+	UUIDGenerator       idgen.UUIDGenerator
+	Clock               clock.Clock
+	Transactor          moviestore.Transactor
+	Selector            moviestore.Selector
+	// ValidationPolicy holds the bounds a Movie is validated
+	// against. The zero value is the default policy.
+	ValidationPolicy movie.ValidationPolicy
+	// History finds the versions of a Movie, as recorded by the
+	// audit events of its changes
+	History audit.Store
+}
+
+// Version is a Movie as it was after one of the successful changes
+// to it, numbered from 1 for its creation
+type Version struct {
+	Number    int
+	RequestID string
+	Timestamp time.Time
+	Movie     *movie.Movie
+}
+
+// MovieInput holds the values given to create or update a Movie
+type MovieInput struct {
+	Title    string
+	Rated    string
+	Released string
+	RunTime  int
+	Director string
+	Writer   string
+}
+
+// IsAdmin reports whether u has the admin role, e.g. to choose
+// which fields of a movie u is shown
+func (s Service) IsAdmin(ctx context.Context, u user.User) (bool, error) {
+	return auth.HasRole(ctx, s.Authorizer, u, auth.RoleAdmin)
+}
+
+// Create creates a Movie from in for the user u
+func (s Service) Create(ctx context.Context, u user.User, in MovieInput) (*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	extlID, err := s.ExternalIDGenerator.NewExternalID()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.UUIDGenerator.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the NewMovie method to perform domain business logic
+	m, err := movie.NewMovie(id, extlID, u, s.Clock)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err = s.setInput(m, in)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// Call the Create method of the Transactor to insert data to
+	// the database (unless mocked, of course)
+	err = s.Transactor.Create(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetAfter(ctx, m)
+
+	return m, nil
+}
+
+// Update updates the Movie with the external ID extlID from in for
+// the user u
+func (s Service) Update(ctx context.Context, u user.User, extlID string, in MovieInput) (*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// the Movie before the update is only read for the audit event,
+	// from the primary, as a replica may not have the last update
+	if audit.Recording(ctx) {
+		before, err := s.Selector.FindByID(requestinfo.WithPrimary(ctx), extlID)
+		if err != nil {
+			return nil, err
+		}
+		audit.SetBefore(ctx, before)
+	}
+
+	m := new(movie.Movie)
+	m.SetExternalID(extlID)
+	m.SetUpdateUser(u)
+	m.SetUpdateTime(s.Clock)
+
+	m, err = s.setInput(m, in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the Update method of the Transactor to update the record
+	// in the database.
+	err = s.Transactor.Update(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetAfter(ctx, m)
+
+	return m, nil
+}
+
+// setInput sets the values from in to m and validates m using the
+// ValidationPolicy
+func (s Service) setInput(m *movie.Movie, in MovieInput) (*movie.Movie, error) {
+	m, err := m.SetReleased(in.Released)
+	if err != nil {
+		return nil, err
+	}
+	m.SetTitle(in.Title).
+		SetRated(in.Rated).
+		SetRunTime(in.RunTime).
+		SetDirector(in.Director).
+		SetWriter(in.Writer)
+
+	err = m.Validate(s.ValidationPolicy, s.Clock)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Delete deletes the Movie with the external ID extlID and returns
+// the Movie deleted
+func (s Service) Delete(ctx context.Context, u user.User, extlID string) (*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// Find the Movie first, so a Movie which does not exist is a
+	// NotExist error. It is found in the primary, as a replica may
+	// not have it yet.
+	m, err := s.Selector.FindByID(requestinfo.WithPrimary(ctx), extlID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete method of Transactor physically deletes the record
+	// from the DB, unless mocked
+	err = s.Transactor.Delete(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetBefore(ctx, m)
+
+	return m, nil
+}
+
+// FindByID returns the Movie with the external ID extlID
+func (s Service) FindByID(ctx context.Context, u user.User, extlID string) (*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.FindByID(ctx, extlID)
+}
+
+// FindByIDs returns the Movies with the external IDs, in the order
+// of the IDs given. IDs which are not found are omitted.
+func (s Service) FindByIDs(ctx context.Context, u user.User, extlIDs []string) ([]*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.FindByIDs(ctx, extlIDs)
+}
+
+// StreamPage calls fn for each of up to limit Movies matching the
+// filter f, skipping the first offset Movies, and returns the total
+// number of matching Movies (see moviestore.Selector.StreamPage)
+func (s Service) StreamPage(ctx context.Context, u user.User, f *filter.Expr, limit, offset int, fn moviestore.PageFunc) (int, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.Selector.StreamPage(ctx, f, limit, offset, fn)
+}
+
+// ListVersion returns the version of the list of Movies matching the
+// filter f, which changes whenever one of them is created, updated or
+// deleted
+func (s Service) ListVersion(ctx context.Context, u user.User, f *filter.Expr) (movie.ListVersion, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return movie.ListVersion{}, err
+	}
+
+	return s.Selector.ListVersion(ctx, f)
+}
+
+// Stats returns counts of Movies grouped by rating, decade and
+// director
+func (s Service) Stats(ctx context.Context, u user.User) (*movie.Stats, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.Stats(ctx)
+}
+
+// Count returns the number of Movies, exact or estimated as given by
+// mode
+func (s Service) Count(ctx context.Context, u user.User, mode movie.CountMode) (movie.Count, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return movie.Count{}, err
+	}
+
+	return s.Selector.Count(ctx, mode)
+}
+
+// Suggest returns up to limit Movies whose title starts with prefix,
+// ignoring case, ordered by title
+func (s Service) Suggest(ctx context.Context, u user.User, prefix string, limit int) ([]movie.Suggestion, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.Suggest(ctx, prefix, limit)
+}
+
+// CompareVersions returns versions v1 and v2 of the Movie with the
+// external ID extlID, to be compared. The versions are found in the
+// audit history, so they are found after the Movie is deleted. A
+// Movie with no versions, or a version it does not have, is an
+// errs.NotExist error, the latter with v1 or v2 as its Parameter.
+func (s Service) CompareVersions(ctx context.Context, u user.User, extlID string, v1, v2 int) (Version, Version, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return Version{}, Version{}, err
+	}
+
+	history, err := s.History.Versions(ctx, resource, extlID)
+	if err != nil {
+		return Version{}, Version{}, err
+	}
+	if len(history) == 0 {
+		return Version{}, Version{}, errs.E(errs.NotExist, "No record found for given ID")
+	}
+
+	var versions [2]Version
+	for i, n := range []int{v1, v2} {
+		param := fmt.Sprintf("v%d", i+1)
+		if n < 1 || n > len(history) {
+			return Version{}, Version{}, errs.E(errs.NotExist, errs.Parameter(param),
+				errors.Errorf("movie %s has no version %d, its versions are 1 to %d", extlID, n, len(history)))
+		}
+		hv := history[n-1]
+
+		m := new(movie.Movie)
+		if err = json.Unmarshal(hv.State, m); err != nil {
+			return Version{}, Version{}, errs.E(errs.Internal, errors.Wrapf(err, "version %d of movie %s", n, extlID))
+		}
+		versions[i] = Version{Number: hv.Number, RequestID: hv.RequestID, Timestamp: hv.Timestamp, Movie: m}
+	}
+
+	return versions[0], versions[1], nil
+}
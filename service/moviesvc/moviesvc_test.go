@@ -0,0 +1,314 @@
+package moviesvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/audit/audittest"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/idgen/idgentest"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// denyAuthorizer denies every user, recording the resource and action
+// it was asked to authorize
+type denyAuthorizer struct {
+	resource, action *string
+}
+
+func (a denyAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
+	*a.resource, *a.action = resource, action
+	return errs.E(errs.Unauthorized, "denied")
+}
+
+// newService returns a Service using an empty in-memory store
+func newService(t *testing.T) Service {
+	ms := memstore.NewMovieStore()
+	return Service{
+		Authorizer:          authtest.NewMockAuthorizer(t),
+		ExternalIDGenerator: randomtest.NewMockStringGenerator(t),
+		UUIDGenerator:       idgentest.NewMockUUIDGenerator(t),
+		Clock:               clocktest.NewMockClock(t),
+		Transactor:          ms,
+		Selector:            ms,
+		History:             audittest.NewMockStore(t),
+	}
+}
+
+var repoMan = MovieInput{
+	Title:    "Repo Man",
+	Rated:    "R",
+	Released: "1984-03-02T00:00:00Z",
+	RunTime:  92,
+	Director: "Alex Cox",
+	Writer:   "Alex Cox",
+}
+
+func TestService_Create(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	m, err := s.Create(ctx, u, repoMan)
+	c.Assert(err, qt.IsNil)
+	c.Assert(m.ExternalID, qt.Not(qt.Equals), "")
+	c.Assert(m.Title, qt.Equals, "Repo Man")
+	c.Assert(m.CreateUser, qt.Equals, u)
+	c.Assert(m.ID, qt.Equals, idgentest.UUID)
+	c.Assert(m.CreateTime, qt.Equals, clocktest.Time)
+
+	// the Movie was stored
+	got, err := s.FindByID(ctx, u, m.ExternalID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Title, qt.Equals, "Repo Man")
+
+	// an invalid Movie is not stored
+	in := repoMan
+	in.Title = ""
+	_, err = s.Create(ctx, u, in)
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+	all, err := s.Selector.FindAll(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(all, qt.HasLen, 1)
+}
+
+func TestService_Update(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	m, err := s.Create(ctx, u, repoMan)
+	c.Assert(err, qt.IsNil)
+
+	in := repoMan
+	in.RunTime = 93
+	got, err := s.Update(ctx, u, m.ExternalID, in)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.RunTime, qt.Equals, 93)
+
+	_, err = s.Update(ctx, u, "notAnID", in)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+func TestService_Update_audit(t *testing.T) {
+	c := qt.New(t)
+	ctx := audit.NewContext(context.Background())
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	m, err := s.Create(context.Background(), u, repoMan)
+	c.Assert(err, qt.IsNil)
+
+	in := repoMan
+	in.RunTime = 93
+	_, err = s.Update(ctx, u, m.ExternalID, in)
+	c.Assert(err, qt.IsNil)
+
+	// the audit event has the Movie before and after the update
+	e := audit.EventFromContext(ctx)
+	c.Assert(e.Resource, qt.Equals, audit.Resource{Type: "movies", ID: m.ExternalID})
+	c.Assert(string(e.Before), qt.Contains, `"RunTime":92`)
+	c.Assert(string(e.After), qt.Contains, `"RunTime":93`)
+}
+
+func TestService_Ratings(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings movie.Ratings
+		rated   string
+		wantErr bool
+	}{
+		{"MPAA", nil, "PG-13", false},
+		{"not MPAA", nil, "TV-MA", true},
+		{"lower case", nil, "pg-13", true},
+		{"allowed", movie.Ratings{"TV-MA"}, "TV-MA", false},
+		{"not allowed", movie.Ratings{"TV-MA"}, "R", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			ctx := context.Background()
+			s := newService(t)
+			s.ValidationPolicy = movie.ValidationPolicy{Ratings: tt.ratings}
+			u := usertest.NewUser(t)
+
+			in := repoMan
+			in.Rated = tt.rated
+			_, err := s.Create(ctx, u, in)
+			if !tt.wantErr {
+				c.Assert(err, qt.IsNil)
+				return
+			}
+			c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			c.Assert(err.(*errs.Error).Param, qt.Equals, errs.Parameter("rated"))
+		})
+	}
+}
+
+func TestService_Delete(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	m, err := s.Create(ctx, u, repoMan)
+	c.Assert(err, qt.IsNil)
+
+	got, err := s.Delete(ctx, u, m.ExternalID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.ExternalID, qt.Equals, m.ExternalID)
+
+	// the Movie is gone, so deleting again is NotExist
+	_, err = s.Delete(ctx, u, m.ExternalID)
+	c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+}
+
+// primarySelector is a Selector recording, for each Movie found by
+// ID, whether it was read from the primary database
+type primarySelector struct {
+	moviestore.Selector
+	primary *[]bool
+}
+
+func (s primarySelector) FindByID(ctx context.Context, extlID string) (*movie.Movie, error) {
+	*s.primary = append(*s.primary, requestinfo.Primary(ctx))
+	return s.Selector.FindByID(ctx, extlID)
+}
+
+// the reads a write depends on are made in the primary, and the
+// reads of a request changing nothing may be made in a replica
+func TestService_primaryReads(t *testing.T) {
+	c := qt.New(t)
+	ctx := audit.NewContext(context.Background())
+	s := newService(t)
+	var primary []bool
+	s.Selector = primarySelector{Selector: s.Selector, primary: &primary}
+	u := usertest.NewUser(t)
+
+	m, err := s.Create(ctx, u, repoMan)
+	c.Assert(err, qt.IsNil)
+	_, err = s.FindByID(ctx, u, m.ExternalID)
+	c.Assert(err, qt.IsNil)
+	_, err = s.Update(ctx, u, m.ExternalID, repoMan)
+	c.Assert(err, qt.IsNil)
+	_, err = s.Delete(ctx, u, m.ExternalID)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(primary, qt.DeepEquals, []bool{false, true, true})
+}
+
+func TestService_CompareVersions(t *testing.T) {
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+
+	tests := []struct {
+		name      string
+		extlID    string
+		v1, v2    int
+		wantErr   bool
+		wantParam errs.Parameter
+	}{
+		{"versions", "kCBqDtyAkZIfdWjRDXQG", 1, 2, false, ""},
+		{"reversed", "kCBqDtyAkZIfdWjRDXQG", 2, 1, false, ""},
+		{"no movie", "abc", 1, 2, true, ""},
+		{"no v1", "kCBqDtyAkZIfdWjRDXQG", 0, 2, true, "v1"},
+		{"no v2", "kCBqDtyAkZIfdWjRDXQG", 1, 3, true, "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			from, to, err := newService(t).CompareVersions(ctx, u, tt.extlID, tt.v1, tt.v2)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.NotExist, err), qt.IsTrue)
+				var e *errs.Error
+				c.Assert(errors.As(err, &e), qt.IsTrue)
+				c.Assert(e.Param, qt.Equals, tt.wantParam)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(from.Number, qt.Equals, tt.v1)
+			c.Assert(to.Number, qt.Equals, tt.v2)
+
+			// the run time was changed from 90 to 92 minutes
+			versions := map[int]Version{from.Number: from, to.Number: to}
+			c.Assert(versions[1].Movie.RunTime, qt.Equals, 90)
+			c.Assert(versions[2].Movie.RunTime, qt.Equals, 92)
+			c.Assert(versions[2].Movie.ExternalID, qt.Equals, "kCBqDtyAkZIfdWjRDXQG")
+			c.Assert(versions[2].RequestID, qt.Equals, "c0mcc0st9kr3sp1ulle0")
+		})
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+
+	tests := []struct {
+		name         string
+		call         func(s Service) error
+		wantResource string
+		wantAction   string
+	}{
+		{"create", func(s Service) error {
+			_, err := s.Create(ctx, u, repoMan)
+			return err
+		}, "movies", "write"},
+		{"update", func(s Service) error {
+			_, err := s.Update(ctx, u, "abc", repoMan)
+			return err
+		}, "movies", "write"},
+		{"delete", func(s Service) error {
+			_, err := s.Delete(ctx, u, "abc")
+			return err
+		}, "movies", "write"},
+		{"find by id", func(s Service) error {
+			_, err := s.FindByID(ctx, u, "abc")
+			return err
+		}, "movies", "read"},
+		{"find by ids", func(s Service) error {
+			_, err := s.FindByIDs(ctx, u, []string{"abc"})
+			return err
+		}, "movies", "read"},
+		{"stream page", func(s Service) error {
+			_, err := s.StreamPage(ctx, u, nil, 20, 0, func(m *movie.Movie, total int) error { return nil })
+			return err
+		}, "movies", "read"},
+		{"stats", func(s Service) error {
+			_, err := s.Stats(ctx, u)
+			return err
+		}, "movies", "read"},
+		{"compare versions", func(s Service) error {
+			_, _, err := s.CompareVersions(ctx, u, "kCBqDtyAkZIfdWjRDXQG", 1, 2)
+			return err
+		}, "movies", "read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var gotResource, gotAction string
+			s := newService(t)
+			s.Authorizer = denyAuthorizer{resource: &gotResource, action: &gotAction}
+
+			err := tt.call(s)
+			c.Assert(errs.KindIs(errs.Unauthorized, err), qt.IsTrue)
+			c.Assert(gotResource, qt.Equals, tt.wantResource)
+			c.Assert(gotAction, qt.Equals, tt.wantAction)
+		})
+	}
+}
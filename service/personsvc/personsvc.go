@@ -0,0 +1,218 @@
+// Package personsvc is the service layer for people. It holds the
+// steps of each person operation (authorization, ID generation,
+// domain logic and calls to the store), called by the entry points.
+// A person is linked to the movies they directed, see AddMovie.
+package personsvc
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// resource is given to the Authorizer as the resource for person
+// operations, with auth.ActionRead or auth.ActionWrite as the
+// action.
+const resource string = "people"
+
+// Service performs the operations for people
+type Service struct {
+	Authorizer          auth.Authorizer
+	ExternalIDGenerator random.ExternalIDGenerator
+	Clock               clock.Clock
+	Transactor          personstore.Transactor
+	Selector            personstore.Selector
+}
+
+// PersonInput holds the values given to create or update a Person
+type PersonInput struct {
+	Name string
+}
+
+// Create creates a Person from in for the user u
+func (s Service) Create(ctx context.Context, u user.User, in PersonInput) (*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	extlID, err := s.ExternalIDGenerator.NewExternalID()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := person.NewPerson(uuid.New(), extlID, u, s.Clock)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err = setInput(p, in)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	err = s.Transactor.Create(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetAfter(ctx, p)
+
+	return p, nil
+}
+
+// Update updates the Person with the external ID extlID from in for
+// the user u
+func (s Service) Update(ctx context.Context, u user.User, extlID string, in PersonInput) (*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// the Person before the update is only read for the audit event,
+	// from the primary, as a replica may not have the last update
+	if audit.Recording(ctx) {
+		before, err := s.Selector.FindByID(requestinfo.WithPrimary(ctx), extlID)
+		if err != nil {
+			return nil, err
+		}
+		audit.SetBefore(ctx, before)
+	}
+
+	p := new(person.Person)
+	p.SetExternalID(extlID)
+	p.SetUpdateUser(u)
+	p.SetUpdateTime(s.Clock)
+
+	p, err = setInput(p, in)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Transactor.Update(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetAfter(ctx, p)
+
+	return p, nil
+}
+
+// setInput sets the values from in to p and validates p
+func setInput(p *person.Person, in PersonInput) (*person.Person, error) {
+	p.SetName(in.Name)
+
+	err := p.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Delete deletes the Person with the external ID extlID and returns
+// the Person deleted
+func (s Service) Delete(ctx context.Context, u user.User, extlID string) (*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// Find the Person first, so a Person which does not exist
+	// is a NotExist error. It is found in the primary, as a replica
+	// may not have it yet.
+	p, err := s.Selector.FindByID(requestinfo.WithPrimary(ctx), extlID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Transactor.Delete(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetBefore(ctx, p)
+
+	return p, nil
+}
+
+// FindByID returns the Person with the external ID extlID
+func (s Service) FindByID(ctx context.Context, u user.User, extlID string) (*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.FindByID(ctx, extlID)
+}
+
+// FindAll returns all People
+func (s Service) FindAll(ctx context.Context, u user.User) ([]*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.FindAll(ctx)
+}
+
+// FindMovies returns the Movies directed by the Person with the
+// external ID extlID
+func (s Service) FindMovies(ctx context.Context, u user.User, extlID string) ([]*movie.Movie, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the Person first, so a Person which does not exist is a
+	// NotExist error rather than an empty list
+	p, err := s.Selector.FindByID(ctx, extlID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Selector.FindMovies(ctx, p)
+}
+
+// AddMovie sets the Person with the external ID extlID as the
+// director of the Movie with the external ID movieExtlID and returns
+// the Person
+func (s Service) AddMovie(ctx context.Context, u user.User, extlID, movieExtlID string) (*person.Person, error) {
+	err := s.Authorizer.Authorize(ctx, u, resource, auth.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetResource(ctx, resource, extlID)
+
+	// the Person is found in the primary, as a replica may not have
+	// it yet
+	p, err := s.Selector.FindByID(requestinfo.WithPrimary(ctx), extlID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Transactor.AddMovie(ctx, p, movieExtlID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
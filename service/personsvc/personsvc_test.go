@@ -0,0 +1,177 @@
+package personsvc
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
+	"github.com/gilcrest/go-api-basic/datastore/personstore/personstoretest"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/clock/clocktest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/person"
+	"github.com/gilcrest/go-api-basic/domain/random/randomtest"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+	"github.com/gilcrest/go-api-basic/internal/requestinfo"
+)
+
+// denyAuthorizer denies every user, recording the resource and action
+// it was asked to authorize
+type denyAuthorizer struct {
+	resource, action *string
+}
+
+func (a denyAuthorizer) Authorize(ctx context.Context, sub user.User, resource string, action string) error {
+	*a.resource, *a.action = resource, action
+	return errs.E(errs.Unauthorized, "denied")
+}
+
+// newService returns a Service using the mock store
+func newService(t *testing.T) Service {
+	return Service{
+		Authorizer:          authtest.NewMockAuthorizer(t),
+		ExternalIDGenerator: randomtest.NewMockStringGenerator(t),
+		Clock:               clocktest.NewMockClock(t),
+		Transactor:          personstoretest.NewMockTransactor(t),
+		Selector:            personstoretest.NewMockSelector(t),
+	}
+}
+
+func TestService_Create(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	got, err := s.Create(ctx, u, PersonInput{Name: "Alex Cox"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.ExternalID, qt.Equals, "superRandomString")
+	c.Assert(got.Name, qt.Equals, "Alex Cox")
+	c.Assert(got.CreateUser, qt.Equals, u)
+	c.Assert(got.CreateTime, qt.Equals, clocktest.Time)
+
+	// an invalid Person is not created
+	_, err = s.Create(ctx, u, PersonInput{})
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+}
+
+func TestService_Update(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	got, err := s.Update(ctx, u, "kCBqDtyAkZIfdWjRDXQG", PersonInput{Name: "Alex Cox Jr."})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.ExternalID, qt.Equals, "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(got.Name, qt.Equals, "Alex Cox Jr.")
+	c.Assert(got.UpdateUser, qt.Equals, u)
+}
+
+// primarySelector is a Selector recording, for each Person found by
+// ID, whether it was read from the primary database
+type primarySelector struct {
+	personstore.Selector
+	primary *[]bool
+}
+
+func (s primarySelector) FindByID(ctx context.Context, extlID string) (*person.Person, error) {
+	*s.primary = append(*s.primary, requestinfo.Primary(ctx))
+	return s.Selector.FindByID(ctx, extlID)
+}
+
+// the reads a write depends on are made in the primary, and the
+// reads of a request changing nothing may be made in a replica
+func TestService_primaryReads(t *testing.T) {
+	c := qt.New(t)
+	ctx := audit.NewContext(context.Background())
+	s := newService(t)
+	var primary []bool
+	s.Selector = primarySelector{Selector: s.Selector, primary: &primary}
+	u := usertest.NewUser(t)
+
+	_, err := s.FindByID(ctx, u, "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(err, qt.IsNil)
+	_, err = s.FindMovies(ctx, u, "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(err, qt.IsNil)
+	_, err = s.Update(ctx, u, "kCBqDtyAkZIfdWjRDXQG", PersonInput{Name: "Alex Cox Jr."})
+	c.Assert(err, qt.IsNil)
+	_, err = s.AddMovie(ctx, u, "kCBqDtyAkZIfdWjRDXQG", "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(err, qt.IsNil)
+	_, err = s.Delete(ctx, u, "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(primary, qt.DeepEquals, []bool{false, false, true, true, true})
+}
+
+func TestService_FindMovies(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	s := newService(t)
+	u := usertest.NewUser(t)
+
+	got, err := s.FindMovies(ctx, u, "kCBqDtyAkZIfdWjRDXQG")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.HasLen, 2)
+	c.Assert(got[0].Title, qt.Equals, "Repo Man")
+}
+
+func TestService_Authorize(t *testing.T) {
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+	in := PersonInput{Name: "Alex Cox"}
+
+	tests := []struct {
+		name         string
+		call         func(s Service) error
+		wantResource string
+		wantAction   string
+	}{
+		{"create", func(s Service) error {
+			_, err := s.Create(ctx, u, in)
+			return err
+		}, "people", "write"},
+		{"update", func(s Service) error {
+			_, err := s.Update(ctx, u, "abc", in)
+			return err
+		}, "people", "write"},
+		{"delete", func(s Service) error {
+			_, err := s.Delete(ctx, u, "abc")
+			return err
+		}, "people", "write"},
+		{"find by id", func(s Service) error {
+			_, err := s.FindByID(ctx, u, "abc")
+			return err
+		}, "people", "read"},
+		{"find all", func(s Service) error {
+			_, err := s.FindAll(ctx, u)
+			return err
+		}, "people", "read"},
+		{"find movies", func(s Service) error {
+			_, err := s.FindMovies(ctx, u, "abc")
+			return err
+		}, "people", "read"},
+		{"add movie", func(s Service) error {
+			_, err := s.AddMovie(ctx, u, "abc", "def")
+			return err
+		}, "people", "write"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var gotResource, gotAction string
+			s := newService(t)
+			s.Authorizer = denyAuthorizer{resource: &gotResource, action: &gotAction}
+
+			err := tt.call(s)
+			c.Assert(errs.KindIs(errs.Unauthorized, err), qt.IsTrue)
+			c.Assert(gotResource, qt.Equals, tt.wantResource)
+			c.Assert(gotAction, qt.Equals, tt.wantAction)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+// tenantNameRegexp matches the valid names of tenants
+var tenantNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// tenantConfig sets the tenants requests can be made for and the
+// database of each
+type tenantConfig struct {
+	// Databases are the names of the databases of the tenants, by
+	// the names of the tenants. The databases are on the server of
+	// the default database.
+	Databases map[string]string
+	// Domains are the names of the tenants by the Google Workspace
+	// domain of their users, see handler.Tenants
+	Domains map[string]string
+	// MaxOpenConns is the maximum number of open connections of the
+	// pool of each tenant's database, so one tenant cannot use all
+	// the connections of the server
+	MaxOpenConns int
+}
+
+// parseTenants parses s, a comma separated list of tenants and the
+// names of their databases, e.g. acme=acme_db,globex=globex_db
+func parseTenants(s string) (map[string]string, error) {
+	tenants := make(map[string]string)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i := strings.Index(v, "=")
+		if i < 0 {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenants"), errors.Errorf("tenant %q must be name=database", v))
+		}
+		name, db := strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:])
+		if !tenantNameRegexp.MatchString(name) {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenants"), errors.Errorf("invalid tenant name %q, must be lower case letters, digits, _ and -", name))
+		}
+		if db == "" {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenants"), errors.Errorf("tenant %q has no database", name))
+		}
+		if _, ok := tenants[name]; ok {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenants"), errors.Errorf("tenant %q is listed twice", name))
+		}
+		tenants[name] = db
+	}
+	return tenants, nil
+}
+
+// parseTenantDomains parses s, a comma separated list of the Google
+// Workspace domains of the users of each tenant, e.g.
+// acme.com=acme,globex.com=globex. Each tenant must be one of
+// tenants.
+func parseTenantDomains(s string, tenants map[string]string) (map[string]string, error) {
+	domains := make(map[string]string)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i := strings.Index(v, "=")
+		if i < 0 {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenant-domains"), errors.Errorf("tenant domain %q must be domain=tenant", v))
+		}
+		domain, name := strings.ToLower(strings.TrimSpace(v[:i])), strings.TrimSpace(v[i+1:])
+		if domain == "" {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenant-domains"), errors.Errorf("tenant domain %q has no domain", v))
+		}
+		if _, ok := tenants[name]; !ok {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenant-domains"), errors.Errorf("domain %s is of unknown tenant %q", domain, name))
+		}
+		if _, ok := domains[domain]; ok {
+			return nil, errs.E(errs.Validation, errs.Parameter("tenant-domains"), errors.Errorf("domain %s is listed twice", domain))
+		}
+		domains[domain] = name
+	}
+	return domains, nil
+}
+
+// newTenants returns the tenants of cfg, with their names sorted
+func newTenants(cfg tenantConfig) handler.Tenants {
+	var ts handler.Tenants
+	for name := range cfg.Databases {
+		ts.Names = append(ts.Names, name)
+	}
+	sort.Strings(ts.Names)
+	ts.Domains = cfg.Domains
+	return ts
+}
+
+// newTenantDatastore opens a pool of connections to the database of
+// each tenant of cfg, using dsn with the database name replaced, and
+// returns a datastore.TenantDatastore with db as its default
+// database. The returned function closes the pools.
+func newTenantDatastore(db *sql.DB, dsn datastore.PGDatasourceName, cfg tenantConfig, logger zerolog.Logger) (datastore.TenantDatastore, func(), error) {
+	tenants := make(map[string]*sql.DB, len(cfg.Databases))
+	var cleanups []func()
+	cleanup := func() {
+		for _, f := range cleanups {
+			f()
+		}
+	}
+
+	for _, name := range newTenants(cfg).Names {
+		tdsn := dsn
+		tdsn.DBName = cfg.Databases[name]
+		tdb, f, err := datastore.NewDB(tdsn, logger.With().Str("tenant", name).Logger())
+		if err != nil {
+			cleanup()
+			return datastore.TenantDatastore{}, func() {}, err
+		}
+		if cfg.MaxOpenConns > 0 {
+			tdb.SetMaxOpenConns(cfg.MaxOpenConns)
+		}
+		tenants[name] = tdb
+		cleanups = append(cleanups, f)
+	}
+
+	return datastore.NewTenantDatastore(db, tenants), cleanup, nil
+}
+
+// newMovieTransactor returns a movie Transactor using the tenant's database
+func newMovieTransactor(ds datastore.ReplicaDatastore) moviestore.DefaultTransactor {
+	return moviestore.NewDefaultTransactor(ds)
+}
+
+// newMovieSelector returns a movie Selector using the tenant's database or the replica
+func newMovieSelector(ds datastore.ReplicaDatastore) moviestore.DefaultSelector {
+	return moviestore.NewDefaultSelector(ds)
+}
+
+// newPersonTransactor returns a person Transactor using the tenant's database
+func newPersonTransactor(ds datastore.ReplicaDatastore) personstore.DefaultTransactor {
+	return personstore.NewDefaultTransactor(ds)
+}
+
+// newPersonSelector returns a person Selector using the tenant's database or the replica
+func newPersonSelector(ds datastore.ReplicaDatastore) personstore.DefaultSelector {
+	return personstore.NewDefaultSelector(ds)
+}
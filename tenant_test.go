@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/handler"
+)
+
+func Test_parseTenants(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"tenants", "acme=acme_db, globex = globex_db,", map[string]string{"acme": "acme_db", "globex": "globex_db"}, false},
+		{"no database", "acme", nil, true},
+		{"empty database", "acme=", nil, true},
+		{"invalid name", "Acme Corp=acme_db", nil, true},
+		{"listed twice", "acme=acme_db,acme=other_db", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := parseTenants(tt.s)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func Test_parseTenantDomains(t *testing.T) {
+	tenants := map[string]string{"acme": "acme_db", "globex": "globex_db"}
+
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"domains", "acme.com=acme, Globex.com = globex, acme.org=acme", map[string]string{"acme.com": "acme", "globex.com": "globex", "acme.org": "acme"}, false},
+		{"no tenant", "acme.com", nil, true},
+		{"no domain", "=acme", nil, true},
+		{"unknown tenant", "initech.com=initech", nil, true},
+		{"listed twice", "acme.com=acme,acme.com=globex", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := parseTenantDomains(tt.s, tenants)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func Test_newTenants(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(newTenants(tenantConfig{}), qt.DeepEquals, handler.Tenants{})
+	cfg := tenantConfig{
+		Databases: map[string]string{"globex": "g", "acme": "a"},
+		Domains:   map[string]string{"acme.com": "acme"},
+	}
+	c.Assert(newTenants(cfg), qt.DeepEquals, handler.Tenants{Names: []string{"acme", "globex"}, Domains: map[string]string{"acme.com": "acme"}})
+}
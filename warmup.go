@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+)
+
+// metadataRefresher fetches the metadata of the identity provider,
+// see authgateway.MetadataCache
+type metadataRefresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// warmupHook is run once on startup, before the server listens, to
+// do the work which would otherwise make the first requests after a
+// deploy slow, e.g. opening database connections
+type warmupHook struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// warmupHooks are the hooks run on startup
+type warmupHooks []warmupHook
+
+// warmupPageSize is the number of movies read by the movies hook, the
+// size of the first page of the movie list
+const warmupPageSize int = 20
+
+// newWarmupHooks returns the hooks run on startup:
+//
+//   - db opens a database connection, which the connection pool
+//     otherwise opens on the first request
+//   - movies reads the first page of the movie list, the page most
+//     clients ask for first, so its rows and the query plan are
+//     cached by the database
+//   - oauth fetches the identity provider's metadata (if mr is not
+//     nil, it is nil in mock mode), which is otherwise fetched in the
+//     background on the first request
+func newWarmupHooks(p pingstore.Pinger, sel moviestore.Selector, mr metadataRefresher) warmupHooks {
+	hs := warmupHooks{
+		{name: "db", run: p.PingDB},
+		{name: "movies", run: func(ctx context.Context) error {
+			_, _, err := sel.FindPage(ctx, nil, warmupPageSize, 0)
+			return err
+		}},
+	}
+	if mr != nil {
+		hs = append(hs, warmupHook{name: "oauth", run: mr.Refresh})
+	}
+	return hs
+}
+
+// run runs the hooks at the same time, giving them up to timeout to
+// finish. A hook which fails (or does not finish in time) is logged
+// and does not stop the others or the server from starting: it only
+// means the first requests may be slower. The names of the failed
+// hooks are returned.
+func (hs warmupHooks) run(ctx context.Context, timeout time.Duration, lgr zerolog.Logger) []string {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	for _, h := range hs {
+		wg.Add(1)
+		go func(h warmupHook) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := h.run(ctx)
+			if err == nil {
+				lgr.Info().Dur("duration", time.Since(start)).Msgf("warm-up %s complete", h.name)
+				return
+			}
+
+			lgr.Warn().Err(errors.Wrapf(err, "warm-up %s", h.name)).Dur("duration", time.Since(start)).Msg("warm-up failed")
+			mu.Lock()
+			failed = append(failed, h.name)
+			mu.Unlock()
+		}(h)
+	}
+	wg.Wait()
+	sort.Strings(failed)
+
+	return failed
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+)
+
+func Test_warmupHooks_run(t *testing.T) {
+	c := qt.New(t)
+
+	var ran []string
+	ok := func(name string) warmupHook {
+		return warmupHook{name: name, run: func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+	hs := warmupHooks{
+		ok("db"),
+		{name: "auth", run: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}},
+		// a hook which does not finish in time is given up on
+		{name: "movies", run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+
+	start := time.Now()
+	failed := hs.run(context.Background(), 50*time.Millisecond, zerolog.Nop())
+
+	c.Assert(failed, qt.DeepEquals, []string{"auth", "movies"})
+	c.Assert(ran, qt.DeepEquals, []string{"db"})
+	c.Assert(time.Since(start) < time.Second, qt.IsTrue)
+	c.Assert(warmupHooks{ok("db")}.run(context.Background(), time.Second, zerolog.Nop()), qt.IsNil)
+}
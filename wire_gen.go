@@ -9,74 +9,320 @@ import (
 	"context"
 	"database/sql"
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/datastore/exportstore"
+	"github.com/gilcrest/go-api-basic/datastore/memstore"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/personstore"
+	"github.com/gilcrest/go-api-basic/datastore/pii"
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/datastore/quotastore"
+	"github.com/gilcrest/go-api-basic/datastore/sessionstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
 	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/clock"
+	"github.com/gilcrest/go-api-basic/domain/event"
+	"github.com/gilcrest/go-api-basic/domain/idgen"
+	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/quota"
 	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
 	"github.com/gilcrest/go-api-basic/gateway/authgateway"
+	"github.com/gilcrest/go-api-basic/gateway/errorgateway"
+	"github.com/gilcrest/go-api-basic/gateway/httpclient"
 	"github.com/gilcrest/go-api-basic/handler"
+	"github.com/gilcrest/go-api-basic/service/moviesvc"
+	"github.com/gilcrest/go-api-basic/service/personsvc"
 	"github.com/google/wire"
-	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 	"go.opencensus.io/trace"
 	"gocloud.dev/server"
 	"gocloud.dev/server/driver"
 	"gocloud.dev/server/health"
 	"gocloud.dev/server/health/sqlhealth"
-	"net/http"
 )
 
 // Injectors from inject_main.go:
 
-func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName) (*server.Server, func(), error) {
-	googleAccessTokenConverter := authgateway.GoogleAccessTokenConverter{}
+func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName, poolCfg datastore.PoolStatsConfig, breakerCfg datastore.BreakerConfig, cachePolicies handler.CachePolicies, pagination handler.Pagination, limits *quota.ReloadableLimits, decodeOpts handler.DecodeOptions, encodeOpts handler.EncodeOptions, policy movie.ValidationPolicy, auditCfg audit.SinkConfig, reportCfg errorgateway.Config, piiCipher pii.Cipher, tokenCfg authgateway.TokenValidation, oauthClient authgateway.OAuthClient, protoCfg protocolConfig, pathOpts handler.PathNormalization, proxies handler.TrustedProxies, publicRead handler.PublicRead, respCacheCfg responseCacheConfig, tenantCfg tenantConfig, replicaCfg replicaConfig, exportCfg exportConfig, settings handler.Settings, adminServed handler.AdminServed) (*application, func(), error) {
 	defaultAuthorizer := auth.DefaultAuthorizer{}
 	defaultStringGenerator := random.DefaultStringGenerator{}
-	db, cleanup, err := datastore.NewDB(dsn, logger)
+	defaultClock := clock.DefaultClock{}
+	defaultUUIDGenerator := idgen.DefaultUUIDGenerator{
+		Clock: defaultClock,
+	}
+	db, cleanup, err := newDB(dsn, poolCfg, logger)
 	if err != nil {
 		return nil, nil, err
 	}
 	defaultDatastore := datastore.NewDefaultDatastore(db)
-	defaultTransactor := moviestore.NewDefaultTransactor(defaultDatastore)
-	defaultSelector := moviestore.NewDefaultSelector(defaultDatastore)
+	tenantDatastore, cleanup2, err := newTenantDatastore(db, dsn, tenantCfg, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	breaker, cleanup3 := newBreaker(db, breakerCfg, logger)
+	replicaDatastore, cleanup4, err := newReplicaDatastore(tenantDatastore, dsn, replicaCfg, logger)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	defaultTransactor := newMovieTransactor(replicaDatastore)
+	defaultSelector := newMovieSelector(replicaDatastore)
+	defaultCounter := quotastore.NewDefaultCounter(defaultDatastore)
+	defaultTracker := quota.DefaultTracker{
+		Counter: defaultCounter,
+		Limits:  limits,
+	}
+	defaultStore := auditstore.NewDefaultStore(defaultDatastore)
+	service := moviesvc.Service{
+		Authorizer:          defaultAuthorizer,
+		ExternalIDGenerator: defaultStringGenerator,
+		UUIDGenerator:       defaultUUIDGenerator,
+		Clock:               defaultClock,
+		Transactor:          defaultTransactor,
+		Selector:            defaultSelector,
+		ValidationPolicy:    policy,
+		History:             defaultStore,
+	}
 	defaultMovieHandlers := handler.DefaultMovieHandlers{
-		AccessTokenConverter:  googleAccessTokenConverter,
-		Authorizer:            defaultAuthorizer,
-		RandomStringGenerator: defaultStringGenerator,
-		Transactor:            defaultTransactor,
-		Selector:              defaultSelector,
+		Service:       service,
+		QuotaTracker:  defaultTracker,
+		CachePolicies: cachePolicies,
+		DecodeOptions: decodeOpts,
+		Pagination:    pagination,
 	}
 	createMovieHandler := handler.ProvideCreateMovieHandler(defaultMovieHandlers)
 	findMovieByIDHandler := handler.ProvideFindMovieByIDHandler(defaultMovieHandlers)
+	movieDiffHandler := handler.ProvideMovieDiffHandler(defaultMovieHandlers)
 	findAllMoviesHandler := handler.ProvideFindAllMoviesHandler(defaultMovieHandlers)
+	findMoviesByIDsHandler := handler.ProvideFindMoviesByIDsHandler(defaultMovieHandlers)
 	updateMovieHandler := handler.ProvideUpdateMovieHandler(defaultMovieHandlers)
 	deleteMovieHandler := handler.ProvideDeleteMovieHandler(defaultMovieHandlers)
+	movieStatsHandler := handler.ProvideMovieStatsHandler(defaultMovieHandlers)
+	suggestMoviesHandler := handler.ProvideSuggestMoviesHandler(defaultMovieHandlers)
+	countMoviesHandler := handler.ProvideCountMoviesHandler(defaultMovieHandlers)
+	personstoreDefaultTransactor := newPersonTransactor(replicaDatastore)
+	personstoreDefaultSelector := newPersonSelector(replicaDatastore)
+	personsvcService := personsvc.Service{
+		Authorizer:          defaultAuthorizer,
+		ExternalIDGenerator: defaultStringGenerator,
+		Clock:               defaultClock,
+		Transactor:          personstoreDefaultTransactor,
+		Selector:            personstoreDefaultSelector,
+	}
+	defaultPersonHandlers := handler.DefaultPersonHandlers{
+		Service:       personsvcService,
+		QuotaTracker:  defaultTracker,
+		DecodeOptions: decodeOpts,
+	}
+	createPersonHandler := handler.ProvideCreatePersonHandler(defaultPersonHandlers)
+	updatePersonHandler := handler.ProvideUpdatePersonHandler(defaultPersonHandlers)
+	deletePersonHandler := handler.ProvideDeletePersonHandler(defaultPersonHandlers)
+	findPersonByIDHandler := handler.ProvideFindPersonByIDHandler(defaultPersonHandlers)
+	findAllPeopleHandler := handler.ProvideFindAllPeopleHandler(defaultPersonHandlers)
+	findPersonMoviesHandler := handler.ProvideFindPersonMoviesHandler(defaultPersonHandlers)
+	addPersonMovieHandler := handler.ProvideAddPersonMovieHandler(defaultPersonHandlers)
+	personHandlers := handler.PersonHandlers{
+		CreatePersonHandler:     createPersonHandler,
+		UpdatePersonHandler:     updatePersonHandler,
+		DeletePersonHandler:     deletePersonHandler,
+		FindPersonByIDHandler:   findPersonByIDHandler,
+		FindAllPeopleHandler:    findAllPeopleHandler,
+		FindPersonMoviesHandler: findPersonMoviesHandler,
+		AddPersonMovieHandler:   addPersonMovieHandler,
+	}
+	defaultUsageHandler := handler.DefaultUsageHandler{
+		QuotaTracker: defaultTracker,
+	}
+	usageHandler := handler.ProvideUsageHandler(defaultUsageHandler)
+	routeList := handler.NewRouteList()
+	defaultPermissionsHandler := handler.DefaultPermissionsHandler{
+		Authorizer: defaultAuthorizer,
+		RouteList:  routeList,
+	}
+	permissionsHandler := handler.ProvidePermissionsHandler(defaultPermissionsHandler)
+	userstoreDefaultStore := userstore.NewDefaultStore(defaultDatastore, piiCipher, defaultClock)
+	defaultUserHandlers := handler.DefaultUserHandlers{
+		Eraser: userstoreDefaultStore,
+	}
+	eraseUserDataHandler := handler.ProvideEraseUserDataHandler(defaultUserHandlers)
+	sessionstoreDefaultStore := sessionstore.NewDefaultStore(defaultDatastore, piiCipher)
+	config := httpclient.DefaultConfig()
+	client := httpclient.New(config)
+	metadataCache := authgateway.NewGoogleMetadataCache(client, logger)
+	googleTokenRefresher := authgateway.GoogleTokenRefresher{
+		Client:      client,
+		Metadata:    metadataCache,
+		OAuthClient: oauthClient,
+	}
+	defaultSessionHandlers := handler.DefaultSessionHandlers{
+		Store:           sessionstoreDefaultStore,
+		Refresher:       googleTokenRefresher,
+		SecretGenerator: defaultStringGenerator,
+		Clock:           defaultClock,
+		DecodeOptions:   decodeOpts,
+	}
+	createSessionHandler := handler.ProvideCreateSessionHandler(defaultSessionHandlers)
+	sessionTokenHandler := handler.ProvideSessionTokenHandler(defaultSessionHandlers)
+	deleteSessionHandler := handler.ProvideDeleteSessionHandler(defaultSessionHandlers)
+	exportstoreDefaultStore := exportstore.NewDefaultStore(defaultDatastore)
+	runner, cleanup5, err := newExportRunner(ctx, exportCfg, exportstoreDefaultStore, defaultClock, logger)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	exportURLExpiry := newExportURLExpiry(exportCfg)
+	defaultExportJobHandlers := handler.DefaultExportJobHandlers{
+		Service:       service,
+		Runner:        runner,
+		Store:         exportstoreDefaultStore,
+		QuotaTracker:  defaultTracker,
+		Pagination:    pagination,
+		DecodeOptions: decodeOpts,
+		URLExpiry:     exportURLExpiry,
+		Clock:         defaultClock,
+	}
+	createMovieExportJobHandler := handler.ProvideCreateMovieExportJobHandler(defaultExportJobHandlers)
+	findExportJobHandler := handler.ProvideFindExportJobHandler(defaultExportJobHandlers)
+	defaultAuditHandler := handler.DefaultAuditHandler{
+		Store: defaultStore,
+	}
+	findAuditRecordsHandler := handler.ProvideFindAuditRecordsHandler(defaultAuditHandler)
+	defaultRoutesHandler := handler.DefaultRoutesHandler{
+		RouteList: routeList,
+	}
+	findRoutesHandler := handler.ProvideFindRoutesHandler(defaultRoutesHandler)
 	defaultPinger := pingstore.NewDefaultPinger(defaultDatastore)
 	defaultPingHandler := handler.DefaultPingHandler{
 		Pinger: defaultPinger,
 	}
 	pingHandler := handler.ProvidePingHandler(defaultPingHandler)
+	metricsHandler := handler.ProvideMetricsHandler()
 	handlers := handler.Handlers{
-		CreateMovieHandler:   createMovieHandler,
-		FindMovieByIDHandler: findMovieByIDHandler,
-		FindAllMoviesHandler: findAllMoviesHandler,
-		UpdateMovieHandler:   updateMovieHandler,
-		DeleteMovieHandler:   deleteMovieHandler,
-		PingHandler:          pingHandler,
-	}
-	router := handler.NewMuxRouter(logger, handlers)
-	v, cleanup2 := appHealthChecks(db)
+		CreateMovieHandler:          createMovieHandler,
+		FindMovieByIDHandler:        findMovieByIDHandler,
+		MovieDiffHandler:            movieDiffHandler,
+		FindAllMoviesHandler:        findAllMoviesHandler,
+		FindMoviesByIDsHandler:      findMoviesByIDsHandler,
+		UpdateMovieHandler:          updateMovieHandler,
+		DeleteMovieHandler:          deleteMovieHandler,
+		MovieStatsHandler:           movieStatsHandler,
+		SuggestMoviesHandler:        suggestMoviesHandler,
+		CountMoviesHandler:          countMoviesHandler,
+		PersonHandlers:              personHandlers,
+		UsageHandler:                usageHandler,
+		PermissionsHandler:          permissionsHandler,
+		EraseUserDataHandler:        eraseUserDataHandler,
+		CreateSessionHandler:        createSessionHandler,
+		SessionTokenHandler:         sessionTokenHandler,
+		DeleteSessionHandler:        deleteSessionHandler,
+		CreateMovieExportJobHandler: createMovieExportJobHandler,
+		FindExportJobHandler:        findExportJobHandler,
+		FindAuditRecordsHandler:     findAuditRecordsHandler,
+		FindRoutesHandler:           findRoutesHandler,
+		PingHandler:                 pingHandler,
+		MetricsHandler:              metricsHandler,
+	}
+	googleAccessTokenConverter := authgateway.GoogleAccessTokenConverter{
+		Client:     client,
+		Metadata:   metadataCache,
+		Validation: tokenCfg,
+	}
+	recorder := newUserRecorder(userstoreDefaultStore)
+	authMiddleware := handler.AuthMiddleware{
+		AccessTokenConverter: googleAccessTokenConverter,
+		Authorizer:           defaultAuthorizer,
+		UserRecorder:         recorder,
+		PublicRead:           publicRead,
+	}
+	sink, cleanup6, err := audit.NewSink(defaultStore, auditCfg)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	asyncWriter, cleanup7 := audit.NewAsyncWriter(sink, logger)
+	errorReporter, err := errorgateway.NewReporter(reportCfg, client)
+	if err != nil {
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	inProcessBus := event.NewInProcessBus()
+	responseCache, cleanup8, err := newResponseCache(ctx, respCacheCfg, defaultTracker, inProcessBus)
+	if err != nil {
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	tenants := newTenants(tenantCfg)
+	consistencyTokens := newConsistencyTokens(replicaCfg)
+	router := handler.NewMuxRouter(logger, handlers, authMiddleware, asyncWriter, errorReporter, routeList, encodeOpts, decodeOpts, responseCache, tenants, breaker, consistencyTokens, adminServed)
+	httpHandler, err := handler.NewAPIHandler(router, pathOpts, proxies)
+	if err != nil {
+		cleanup8()
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	v, cleanup9 := appHealthChecks(db, breaker)
 	exporter := _wireExporterValue
 	sampler := trace.AlwaysSample()
-	defaultDriver := server.NewDefaultDriver()
+	mainProtocolDriver := newServerDriver(protoCfg)
 	options := &server.Options{
 		HealthChecks:          v,
 		TraceExporter:         exporter,
 		DefaultSamplingPolicy: sampler,
-		Driver:                defaultDriver,
+		Driver:                mainProtocolDriver,
+	}
+	serverServer := server.New(httpHandler, options)
+	adminHandlers := handler.AdminHandlers{
+		PingHandler:    pingHandler,
+		MetricsHandler: metricsHandler,
 	}
-	serverServer := server.New(router, options)
-	return serverServer, func() {
+	resyncSteps := newResyncSteps(defaultSelector, responseCache, inProcessBus)
+	adminRouter := handler.NewAdminRouter(logger, adminHandlers, settings, resyncSteps)
+	mainAdminServer := newAdminServer(adminRouter, v)
+	mainWarmupHooks := newWarmupHooks(defaultPinger, defaultSelector, metadataCache)
+	mainApplication := &application{
+		API:     serverServer,
+		Admin:   mainAdminServer,
+		Warmups: mainWarmupHooks,
+		Events:  inProcessBus,
+	}
+	return mainApplication, func() {
+		cleanup9()
+		cleanup8()
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
 		cleanup2()
 		cleanup()
 	}, nil
@@ -86,26 +332,337 @@ var (
 	_wireExporterValue = trace.Exporter(nil)
 )
 
+func newMockServer(ctx context.Context, logger zerolog.Logger, cachePolicies handler.CachePolicies, pagination handler.Pagination, limits *quota.ReloadableLimits, decodeOpts handler.DecodeOptions, encodeOpts handler.EncodeOptions, policy movie.ValidationPolicy, auditCfg audit.SinkConfig, reportCfg errorgateway.Config, protoCfg protocolConfig, pathOpts handler.PathNormalization, proxies handler.TrustedProxies, publicRead handler.PublicRead, respCacheCfg responseCacheConfig, tenantCfg tenantConfig, exportCfg exportConfig, settings handler.Settings, adminServed handler.AdminServed) (*application, func(), error) {
+	allowAllAuthorizer := auth.AllowAllAuthorizer{}
+	defaultStringGenerator := random.DefaultStringGenerator{}
+	defaultClock := clock.DefaultClock{}
+	defaultUUIDGenerator := idgen.DefaultUUIDGenerator{
+		Clock: defaultClock,
+	}
+	movieStore, err := newMockMovieStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	counter := memstore.NewCounter()
+	defaultTracker := quota.DefaultTracker{
+		Counter: counter,
+		Limits:  limits,
+	}
+	auditStore := memstore.NewAuditStore()
+	service := moviesvc.Service{
+		Authorizer:          allowAllAuthorizer,
+		ExternalIDGenerator: defaultStringGenerator,
+		UUIDGenerator:       defaultUUIDGenerator,
+		Clock:               defaultClock,
+		Transactor:          movieStore,
+		Selector:            movieStore,
+		ValidationPolicy:    policy,
+		History:             auditStore,
+	}
+	defaultMovieHandlers := handler.DefaultMovieHandlers{
+		Service:       service,
+		QuotaTracker:  defaultTracker,
+		CachePolicies: cachePolicies,
+		DecodeOptions: decodeOpts,
+		Pagination:    pagination,
+	}
+	createMovieHandler := handler.ProvideCreateMovieHandler(defaultMovieHandlers)
+	findMovieByIDHandler := handler.ProvideFindMovieByIDHandler(defaultMovieHandlers)
+	movieDiffHandler := handler.ProvideMovieDiffHandler(defaultMovieHandlers)
+	findAllMoviesHandler := handler.ProvideFindAllMoviesHandler(defaultMovieHandlers)
+	findMoviesByIDsHandler := handler.ProvideFindMoviesByIDsHandler(defaultMovieHandlers)
+	updateMovieHandler := handler.ProvideUpdateMovieHandler(defaultMovieHandlers)
+	deleteMovieHandler := handler.ProvideDeleteMovieHandler(defaultMovieHandlers)
+	movieStatsHandler := handler.ProvideMovieStatsHandler(defaultMovieHandlers)
+	suggestMoviesHandler := handler.ProvideSuggestMoviesHandler(defaultMovieHandlers)
+	countMoviesHandler := handler.ProvideCountMoviesHandler(defaultMovieHandlers)
+	personStore := memstore.NewPersonStore(movieStore)
+	personsvcService := personsvc.Service{
+		Authorizer:          allowAllAuthorizer,
+		ExternalIDGenerator: defaultStringGenerator,
+		Clock:               defaultClock,
+		Transactor:          personStore,
+		Selector:            personStore,
+	}
+	defaultPersonHandlers := handler.DefaultPersonHandlers{
+		Service:       personsvcService,
+		QuotaTracker:  defaultTracker,
+		DecodeOptions: decodeOpts,
+	}
+	createPersonHandler := handler.ProvideCreatePersonHandler(defaultPersonHandlers)
+	updatePersonHandler := handler.ProvideUpdatePersonHandler(defaultPersonHandlers)
+	deletePersonHandler := handler.ProvideDeletePersonHandler(defaultPersonHandlers)
+	findPersonByIDHandler := handler.ProvideFindPersonByIDHandler(defaultPersonHandlers)
+	findAllPeopleHandler := handler.ProvideFindAllPeopleHandler(defaultPersonHandlers)
+	findPersonMoviesHandler := handler.ProvideFindPersonMoviesHandler(defaultPersonHandlers)
+	addPersonMovieHandler := handler.ProvideAddPersonMovieHandler(defaultPersonHandlers)
+	personHandlers := handler.PersonHandlers{
+		CreatePersonHandler:     createPersonHandler,
+		UpdatePersonHandler:     updatePersonHandler,
+		DeletePersonHandler:     deletePersonHandler,
+		FindPersonByIDHandler:   findPersonByIDHandler,
+		FindAllPeopleHandler:    findAllPeopleHandler,
+		FindPersonMoviesHandler: findPersonMoviesHandler,
+		AddPersonMovieHandler:   addPersonMovieHandler,
+	}
+	defaultUsageHandler := handler.DefaultUsageHandler{
+		QuotaTracker: defaultTracker,
+	}
+	usageHandler := handler.ProvideUsageHandler(defaultUsageHandler)
+	routeList := handler.NewRouteList()
+	defaultPermissionsHandler := handler.DefaultPermissionsHandler{
+		Authorizer: allowAllAuthorizer,
+		RouteList:  routeList,
+	}
+	permissionsHandler := handler.ProvidePermissionsHandler(defaultPermissionsHandler)
+	userStore := memstore.NewUserStore(defaultClock)
+	sessionStore := memstore.NewSessionStore()
+	exportJobStore := memstore.NewExportJobStore()
+	userEraser := memstore.NewUserEraser(userStore, movieStore, personStore, auditStore, counter, sessionStore, exportJobStore, defaultClock)
+	defaultUserHandlers := handler.DefaultUserHandlers{
+		Eraser: userEraser,
+	}
+	eraseUserDataHandler := handler.ProvideEraseUserDataHandler(defaultUserHandlers)
+	staticTokenRefresher := newMockTokenRefresher()
+	defaultSessionHandlers := handler.DefaultSessionHandlers{
+		Store:           sessionStore,
+		Refresher:       staticTokenRefresher,
+		SecretGenerator: defaultStringGenerator,
+		Clock:           defaultClock,
+		DecodeOptions:   decodeOpts,
+	}
+	createSessionHandler := handler.ProvideCreateSessionHandler(defaultSessionHandlers)
+	sessionTokenHandler := handler.ProvideSessionTokenHandler(defaultSessionHandlers)
+	deleteSessionHandler := handler.ProvideDeleteSessionHandler(defaultSessionHandlers)
+	runner, cleanup, err := newExportRunner(ctx, exportCfg, exportJobStore, defaultClock, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	exportURLExpiry := newExportURLExpiry(exportCfg)
+	defaultExportJobHandlers := handler.DefaultExportJobHandlers{
+		Service:       service,
+		Runner:        runner,
+		Store:         exportJobStore,
+		QuotaTracker:  defaultTracker,
+		Pagination:    pagination,
+		DecodeOptions: decodeOpts,
+		URLExpiry:     exportURLExpiry,
+		Clock:         defaultClock,
+	}
+	createMovieExportJobHandler := handler.ProvideCreateMovieExportJobHandler(defaultExportJobHandlers)
+	findExportJobHandler := handler.ProvideFindExportJobHandler(defaultExportJobHandlers)
+	defaultAuditHandler := handler.DefaultAuditHandler{
+		Store: auditStore,
+	}
+	findAuditRecordsHandler := handler.ProvideFindAuditRecordsHandler(defaultAuditHandler)
+	defaultRoutesHandler := handler.DefaultRoutesHandler{
+		RouteList: routeList,
+	}
+	findRoutesHandler := handler.ProvideFindRoutesHandler(defaultRoutesHandler)
+	pinger := memstore.Pinger{}
+	defaultPingHandler := handler.DefaultPingHandler{
+		Pinger: pinger,
+	}
+	pingHandler := handler.ProvidePingHandler(defaultPingHandler)
+	metricsHandler := handler.ProvideMetricsHandler()
+	handlers := handler.Handlers{
+		CreateMovieHandler:          createMovieHandler,
+		FindMovieByIDHandler:        findMovieByIDHandler,
+		MovieDiffHandler:            movieDiffHandler,
+		FindAllMoviesHandler:        findAllMoviesHandler,
+		FindMoviesByIDsHandler:      findMoviesByIDsHandler,
+		UpdateMovieHandler:          updateMovieHandler,
+		DeleteMovieHandler:          deleteMovieHandler,
+		MovieStatsHandler:           movieStatsHandler,
+		SuggestMoviesHandler:        suggestMoviesHandler,
+		CountMoviesHandler:          countMoviesHandler,
+		PersonHandlers:              personHandlers,
+		UsageHandler:                usageHandler,
+		PermissionsHandler:          permissionsHandler,
+		EraseUserDataHandler:        eraseUserDataHandler,
+		CreateSessionHandler:        createSessionHandler,
+		SessionTokenHandler:         sessionTokenHandler,
+		DeleteSessionHandler:        deleteSessionHandler,
+		CreateMovieExportJobHandler: createMovieExportJobHandler,
+		FindExportJobHandler:        findExportJobHandler,
+		FindAuditRecordsHandler:     findAuditRecordsHandler,
+		FindRoutesHandler:           findRoutesHandler,
+		PingHandler:                 pingHandler,
+		MetricsHandler:              metricsHandler,
+	}
+	staticAccessTokenConverter := newMockAccessTokenConverter()
+	authMiddleware := handler.AuthMiddleware{
+		AccessTokenConverter: staticAccessTokenConverter,
+		Authorizer:           allowAllAuthorizer,
+		UserRecorder:         userStore,
+		PublicRead:           publicRead,
+	}
+	sink, cleanup2, err := audit.NewSink(auditStore, auditCfg)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	asyncWriter, cleanup3 := audit.NewAsyncWriter(sink, logger)
+	config := httpclient.DefaultConfig()
+	client := httpclient.New(config)
+	errorReporter, err := errorgateway.NewReporter(reportCfg, client)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	inProcessBus := event.NewInProcessBus()
+	responseCache, cleanup4, err := newResponseCache(ctx, respCacheCfg, defaultTracker, inProcessBus)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	tenants := newTenants(tenantCfg)
+	availability := _wireAvailabilityValue
+	consistencyTokens := _wireConsistencyTokensValue
+	router := handler.NewMuxRouter(logger, handlers, authMiddleware, asyncWriter, errorReporter, routeList, encodeOpts, decodeOpts, responseCache, tenants, availability, consistencyTokens, adminServed)
+	httpHandler, err := handler.NewAPIHandler(router, pathOpts, proxies)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	v := _wireValue
+	exporter := _wireExporterValue2
+	sampler := trace.AlwaysSample()
+	mainProtocolDriver := newServerDriver(protoCfg)
+	options := &server.Options{
+		HealthChecks:          v,
+		TraceExporter:         exporter,
+		DefaultSamplingPolicy: sampler,
+		Driver:                mainProtocolDriver,
+	}
+	serverServer := server.New(httpHandler, options)
+	adminHandlers := handler.AdminHandlers{
+		PingHandler:    pingHandler,
+		MetricsHandler: metricsHandler,
+	}
+	mainStatsRefresher := _wireMainStatsRefresherValue
+	resyncSteps := newResyncSteps(mainStatsRefresher, responseCache, inProcessBus)
+	adminRouter := handler.NewAdminRouter(logger, adminHandlers, settings, resyncSteps)
+	mainAdminServer := newAdminServer(adminRouter, v)
+	mainMetadataRefresher := _wireMainMetadataRefresherValue
+	mainWarmupHooks := newWarmupHooks(pinger, movieStore, mainMetadataRefresher)
+	mainApplication := &application{
+		API:     serverServer,
+		Admin:   mainAdminServer,
+		Warmups: mainWarmupHooks,
+		Events:  inProcessBus,
+	}
+	return mainApplication, func() {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+var (
+	_wireValue                      = []health.Checker(nil)
+	_wireExporterValue2             = trace.Exporter(nil)
+	_wireAvailabilityValue          = handler.Availability(nil)
+	_wireMainStatsRefresherValue    = statsRefresher(nil)
+	_wireMainMetadataRefresherValue = metadataRefresher(nil)
+	_wireConsistencyTokensValue     = handler.ConsistencyTokens(false)
+)
+
 // inject_main.go:
 
-var pingHandlerSet = wire.NewSet(pingstore.NewDefaultPinger, wire.Bind(new(pingstore.Pinger), new(pingstore.DefaultPinger)), wire.Struct(new(handler.DefaultPingHandler), "*"), handler.ProvidePingHandler)
+var httpClientSet = wire.NewSet(httpclient.DefaultConfig, httpclient.New)
+
+var errorReportingSet = wire.NewSet(errorgateway.NewReporter)
+
+var metricsHandlerSet = wire.NewSet(handler.ProvideMetricsHandler)
+
+var pingHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultPingHandler), "*"), handler.ProvidePingHandler)
+
+var quotaSet = wire.NewSet(wire.Struct(new(quota.DefaultTracker), "*"), wire.Bind(new(quota.Tracker), new(quota.DefaultTracker)))
+
+var usageHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultUsageHandler), "*"), handler.ProvideUsageHandler)
+
+var permissionsHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultPermissionsHandler), "*"), handler.ProvidePermissionsHandler)
+
+var userHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultUserHandlers), "*"), handler.ProvideEraseUserDataHandler)
+
+var sessionHandlerSet = wire.NewSet(wire.Bind(new(random.SecretGenerator), new(random.DefaultStringGenerator)), wire.Struct(new(handler.DefaultSessionHandlers), "*"), handler.ProvideCreateSessionHandler, handler.ProvideSessionTokenHandler, handler.ProvideDeleteSessionHandler)
+
+// exportJobHandlerSet runs export jobs in the background
+var exportJobHandlerSet = wire.NewSet(newExportRunner, newExportURLExpiry, wire.Struct(new(handler.DefaultExportJobHandlers), "*"), handler.ProvideCreateMovieExportJobHandler, handler.ProvideFindExportJobHandler)
+
+var auditSet = wire.NewSet(audit.NewSink, audit.NewAsyncWriter, wire.Bind(new(audit.Writer), new(*audit.AsyncWriter)), wire.Struct(new(handler.DefaultAuditHandler), "*"), handler.ProvideFindAuditRecordsHandler)
 
-var movieHandlerSet = wire.NewSet(wire.Struct(new(random.DefaultStringGenerator), "*"), wire.Bind(new(random.StringGenerator), new(random.DefaultStringGenerator)), wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"), wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)), wire.Struct(new(auth.DefaultAuthorizer), "*"), wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)), moviestore.NewDefaultTransactor, wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)), moviestore.NewDefaultSelector, wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)), wire.Struct(new(handler.DefaultMovieHandlers), "*"), handler.ProvideCreateMovieHandler, handler.ProvideFindMovieByIDHandler, handler.ProvideFindAllMoviesHandler, handler.ProvideUpdateMovieHandler, handler.ProvideDeleteMovieHandler, wire.Struct(new(handler.Handlers), "*"))
+var routesHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultRoutesHandler), "*"), handler.ProvideFindRoutesHandler)
 
-var datastoreSet = wire.NewSet(datastore.NewDB, datastore.NewDefaultDatastore, wire.Bind(new(datastore.Datastorer), new(datastore.DefaultDatastore)))
+var movieHandlerSet = wire.NewSet(wire.Struct(new(random.DefaultStringGenerator), "*"), wire.Bind(new(random.ExternalIDGenerator), new(random.DefaultStringGenerator)), wire.Struct(new(clock.DefaultClock), "*"), wire.Bind(new(clock.Clock), new(clock.DefaultClock)), wire.Struct(new(idgen.DefaultUUIDGenerator), "*"), wire.Bind(new(idgen.UUIDGenerator), new(idgen.DefaultUUIDGenerator)), wire.Struct(new(moviesvc.Service), "*"), wire.Struct(new(handler.DefaultMovieHandlers), "*"), handler.ProvideCreateMovieHandler, handler.ProvideFindMovieByIDHandler, handler.ProvideMovieDiffHandler, handler.ProvideFindAllMoviesHandler, handler.ProvideFindMoviesByIDsHandler, handler.ProvideUpdateMovieHandler, handler.ProvideDeleteMovieHandler, handler.ProvideMovieStatsHandler, handler.ProvideSuggestMoviesHandler, handler.ProvideCountMoviesHandler, wire.Struct(new(handler.Handlers), "*"))
+
+var personHandlerSet = wire.NewSet(wire.Struct(new(personsvc.Service), "*"), wire.Struct(new(handler.DefaultPersonHandlers), "*"), handler.ProvideCreatePersonHandler, handler.ProvideUpdatePersonHandler, handler.ProvideDeletePersonHandler, handler.ProvideFindPersonByIDHandler, handler.ProvideFindAllPeopleHandler, handler.ProvideFindPersonMoviesHandler, handler.ProvideAddPersonMovieHandler, wire.Struct(new(handler.PersonHandlers), "*"))
+
+// authSet authenticates access tokens with Google and authorizes
+// users with the DefaultAuthorizer
+var authSet = wire.NewSet(wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"), wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)), wire.Struct(new(auth.DefaultAuthorizer), "*"), wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)))
+
+// mockAuthSet accepts any access token and authorizes every user,
+// see the -mock flag
+var mockAuthSet = wire.NewSet(newMockAccessTokenConverter, wire.Bind(new(auth.AccessTokenConverter), new(auth.StaticAccessTokenConverter)), wire.Struct(new(auth.AllowAllAuthorizer)), wire.Bind(new(auth.Authorizer), new(auth.AllowAllAuthorizer)))
+
+var datastoreSet = wire.NewSet(newDB, datastore.NewDefaultDatastore, wire.Bind(new(datastore.Datastorer), new(datastore.DefaultDatastore)))
+
+// pgStoreSet has the PostgreSQL implementations of the stores
+var pgStoreSet = wire.NewSet(moviestore.NewDefaultTransactor, wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)), moviestore.NewDefaultSelector, wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)), personstore.NewDefaultTransactor, wire.Bind(new(personstore.Transactor), new(personstore.DefaultTransactor)), personstore.NewDefaultSelector, wire.Bind(new(personstore.Selector), new(personstore.DefaultSelector)), quotastore.NewDefaultCounter, wire.Bind(new(quota.Counter), new(quotastore.DefaultCounter)), auditstore.NewDefaultStore, wire.Bind(new(audit.Store), new(auditstore.DefaultStore)), pingstore.NewDefaultPinger, wire.Bind(new(pingstore.Pinger), new(pingstore.DefaultPinger)), userstore.NewDefaultStore, wire.Bind(new(user.Eraser), new(userstore.DefaultStore)), newUserRecorder)
+
+// memStoreSet has the in-memory implementations of the stores, see
+// the -mock flag
+var memStoreSet = wire.NewSet(newMockMovieStore, wire.Bind(new(moviestore.Transactor), new(*memstore.MovieStore)), wire.Bind(new(moviestore.Selector), new(*memstore.MovieStore)), memstore.NewPersonStore, wire.Bind(new(personstore.Transactor), new(*memstore.PersonStore)), wire.Bind(new(personstore.Selector), new(*memstore.PersonStore)), memstore.NewCounter, wire.Bind(new(quota.Counter), new(*memstore.Counter)), memstore.NewAuditStore, wire.Bind(new(audit.Store), new(*memstore.AuditStore)), wire.Struct(new(memstore.Pinger)), wire.Bind(new(pingstore.Pinger), new(memstore.Pinger)), memstore.NewUserStore, wire.Bind(new(user.Recorder), new(*memstore.UserStore)), memstore.NewUserEraser, wire.Bind(new(user.Eraser), new(*memstore.UserEraser)))
 
 // goCloudServerSet
-var goCloudServerSet = wire.NewSet(trace.AlwaysSample, server.New, server.NewDefaultDriver, wire.Bind(new(driver.Server), new(*server.DefaultDriver)))
+var goCloudServerSet = wire.NewSet(trace.AlwaysSample, server.New, newServerDriver, wire.Bind(new(driver.Server), new(*protocolDriver)))
+
+// adminSet is the admin server, served on its own port
+var adminSet = wire.NewSet(wire.Struct(new(handler.AdminHandlers), "*"), handler.NewAdminRouter, newAdminServer, newWarmupHooks, newResyncSteps, wire.Struct(new(application), "*"))
 
-var routerSet = wire.NewSet(handler.NewMuxRouter, wire.Bind(new(http.Handler), new(*mux.Router)))
+var routerSet = wire.NewSet(wire.Struct(new(handler.AuthMiddleware), "*"), handler.NewRouteList, handler.NewMuxRouter, handler.NewAPIHandler)
 
 // appHealthChecks returns a health check for the database. This will signal
 // to Kubernetes or other orchestrators that the server should not receive
 // traffic until the server is able to connect to its database.
-func appHealthChecks(db *sql.DB) ([]health.Checker, func()) {
+func appHealthChecks(db *sql.DB, b *datastore.Breaker) ([]health.Checker, func()) {
 	dbCheck := sqlhealth.New(db)
-	list := []health.Checker{dbCheck}
+	list := []health.Checker{dbCheck, b}
 	return list, func() {
 		dbCheck.Stop()
 	}
 }
+
+// newUserRecorder records users in the users table using s, at most
+// once an hour for each user
+func newUserRecorder(s userstore.DefaultStore) user.Recorder {
+	return user.NewThrottledRecorder(s, user.DefaultRecordInterval)
+}
+
+// newDB opens the database using datastore.NewDB and reports
+// connection pool statistics in the background until the returned
+// cleanup function is called
+func newDB(dsn datastore.PGDatasourceName, poolCfg datastore.PoolStatsConfig, logger zerolog.Logger) (*sql.DB, func(), error) {
+	db, cleanup, err := datastore.NewDB(dsn, logger)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go datastore.ReportPoolStats(ctx, db, poolCfg, logger)
+
+	return db, func() {
+		cancel()
+		cleanup()
+	}, nil
+}